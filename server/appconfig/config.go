@@ -0,0 +1,883 @@
+package appconfig
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config goauth 自身的扩展配置
+// gokit 的 config.Config 结构固定，业务侧新增的配置项统一放在这里，与其共用同一个配置文件
+type Config struct {
+	Security SecurityConfig `json:"security" yaml:"security" mapstructure:"security"`
+	OAuth    OAuthConfig    `json:"oauth" yaml:"oauth" mapstructure:"oauth"`
+	Janitor  JanitorConfig  `json:"janitor" yaml:"janitor" mapstructure:"janitor"`
+	MFA      MFAConfig      `json:"mfa" yaml:"mfa" mapstructure:"mfa"`
+	WebAuthn WebAuthnConfig `json:"webauthn" yaml:"webauthn" mapstructure:"webauthn"`
+	// LoginProtection 登录防暴力破解配置
+	LoginProtection LoginProtectionConfig `json:"login_protection" yaml:"login_protection" mapstructure:"login_protection"`
+	// PasswordPolicy 密码策略配置
+	PasswordPolicy PasswordPolicyConfig `json:"password_policy" yaml:"password_policy" mapstructure:"password_policy"`
+	// Account 账号激活、找回密码与邮箱验证配置
+	Account AccountConfig `json:"account" yaml:"account" mapstructure:"account"`
+	// Mail 邮件发送配置
+	Mail MailConfig `json:"mail" yaml:"mail" mapstructure:"mail"`
+	// Federation 通过外部身份提供方登录的配置
+	Federation FederationConfig `json:"federation" yaml:"federation" mapstructure:"federation"`
+	// LDAP 通过 LDAP / Active Directory 校验账号密码的配置
+	LDAP LDAPConfig `json:"ldap" yaml:"ldap" mapstructure:"ldap"`
+	// SAML goauth 作为 SAML 2.0 身份提供方的配置
+	SAML SAMLConfig `json:"saml" yaml:"saml" mapstructure:"saml"`
+	// Authorization 接口授权策略
+	Authorization AuthorizationConfig `json:"authorization" yaml:"authorization" mapstructure:"authorization"`
+	// Audit 安全审计事件配置
+	Audit AuditConfig `json:"audit" yaml:"audit" mapstructure:"audit"`
+	// Webhook 身份生命周期事件推送配置
+	Webhook WebhookConfig `json:"webhook" yaml:"webhook" mapstructure:"webhook"`
+	// Logout OpenID Connect 登出配置
+	Logout LogoutConfig `json:"logout" yaml:"logout" mapstructure:"logout"`
+	// Middleware 与 gokit 的 middleware 配置共用同一节点，这里只放 gokit 未提供的项
+	Middleware MiddlewareConfig `json:"middleware" yaml:"middleware" mapstructure:"middleware"`
+}
+
+// SecurityConfig 安全相关配置
+type SecurityConfig struct {
+	// TokenHashSecret 令牌摘要密钥，数据库中只保存 HMAC-SHA256(secret, token)
+	// 为空时回退到 auth_token.access_token_secret
+	TokenHashSecret string `json:"token_hash_secret" yaml:"token_hash_secret" mapstructure:"token_hash_secret"`
+	// SecretEncryptionKey 数据库中需还原明文的字段（如 TOTP 密钥）的加密密钥
+	// 为空时回退到令牌摘要密钥
+	SecretEncryptionKey string `json:"secret_encryption_key" yaml:"secret_encryption_key" mapstructure:"secret_encryption_key"`
+}
+
+// Bearer 令牌校验模式
+const (
+	BearerModeStateful  = "stateful"  // 每次请求查库判活
+	BearerModeStateless = "stateless" // 本地校验 JWT 签名、过期与受众，撤销通过 Redis 黑名单判断
+)
+
+// OAuthConfig OAuth 相关配置
+type OAuthConfig struct {
+	// BearerMode Bearer 令牌校验模式，stateful 或 stateless
+	BearerMode string `json:"bearer_mode" yaml:"bearer_mode" mapstructure:"bearer_mode"`
+	// Audience 访问令牌的受众（aud），为空时使用 server.base_url
+	Audience string `json:"audience" yaml:"audience" mapstructure:"audience"`
+}
+
+// JanitorConfig 过期授权码与令牌清理配置
+type JanitorConfig struct {
+	// Enabled 是否在服务内定时清理，关闭后仍可通过 janitor 子命令手动执行
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// Interval 定时清理间隔
+	Interval time.Duration `json:"interval" yaml:"interval" mapstructure:"interval"`
+	// Retention 过期、已撤销或已使用的记录保留时长，超过后物理删除
+	Retention time.Duration `json:"retention" yaml:"retention" mapstructure:"retention"`
+	// BatchSize 每批删除的行数
+	BatchSize int `json:"batch_size" yaml:"batch_size" mapstructure:"batch_size"`
+}
+
+// MFAConfig 两步验证配置
+type MFAConfig struct {
+	// Issuer 验证器 App 中显示的发行方名称
+	Issuer string `json:"issuer" yaml:"issuer" mapstructure:"issuer"`
+	// ChallengeTTL 密码验证通过后完成两步验证的时限
+	ChallengeTTL time.Duration `json:"challenge_ttl" yaml:"challenge_ttl" mapstructure:"challenge_ttl"`
+	// MaxAttempts 单次登录挑战允许的验证码错误次数，超过后须重新输入密码
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" mapstructure:"max_attempts"`
+}
+
+// WebAuthnConfig 通行密钥配置
+type WebAuthnConfig struct {
+	// RPID 依赖方标识，须为前端页面域名或其上级域名，为空时使用 server.frontend_url 的主机名
+	RPID string `json:"rp_id" yaml:"rp_id" mapstructure:"rp_id"`
+	// RPName 浏览器与认证器中显示的依赖方名称
+	RPName string `json:"rp_name" yaml:"rp_name" mapstructure:"rp_name"`
+	// Origins 允许发起认证的页面来源，为空时使用 server.frontend_url
+	Origins []string `json:"origins" yaml:"origins" mapstructure:"origins"`
+	// Timeout 单次注册或认证的时限
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	// MaxCredentials 每个用户最多注册的通行密钥数量
+	MaxCredentials int `json:"max_credentials" yaml:"max_credentials" mapstructure:"max_credentials"`
+}
+
+// LoginProtectionConfig 登录防暴力破解配置
+// 按用户名与 IP 分别统计滑动窗口内的失败次数：超过 DelayAfter 后每次须等待逐次翻倍的时间，
+// 达到上限后临时锁定
+type LoginProtectionConfig struct {
+	// Window 统计失败次数的滑动窗口
+	Window time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	// UsernameMaxFailures 同一用户名在窗口内允许的失败次数，达到后锁定该用户名
+	UsernameMaxFailures int `json:"username_max_failures" yaml:"username_max_failures" mapstructure:"username_max_failures"`
+	// IPMaxFailures 同一 IP 在窗口内允许的失败次数，达到后锁定该 IP
+	IPMaxFailures int `json:"ip_max_failures" yaml:"ip_max_failures" mapstructure:"ip_max_failures"`
+	// LockoutDuration 锁定时长
+	LockoutDuration time.Duration `json:"lockout_duration" yaml:"lockout_duration" mapstructure:"lockout_duration"`
+	// DelayAfter 从第几次失败开始要求等待
+	DelayAfter int `json:"delay_after" yaml:"delay_after" mapstructure:"delay_after"`
+	// BaseDelay 首次等待时长，此后每次失败翻倍
+	BaseDelay time.Duration `json:"base_delay" yaml:"base_delay" mapstructure:"base_delay"`
+	// MaxDelay 单次等待时长上限
+	MaxDelay time.Duration `json:"max_delay" yaml:"max_delay" mapstructure:"max_delay"`
+}
+
+// PasswordPolicyConfig 密码策略配置
+// 只约束新设置的密码，已有密码不受影响；超过 MaxAge 的密码在登录时提示修改
+type PasswordPolicyConfig struct {
+	// MinLength 最小长度（按字符计）
+	MinLength int `json:"min_length" yaml:"min_length" mapstructure:"min_length"`
+	// MaxLength 最大长度（按字符计），另受 bcrypt 72 字节的限制
+	MaxLength int `json:"max_length" yaml:"max_length" mapstructure:"max_length"`
+	// MinCharClasses 至少包含的字符类别数，类别为大写字母、小写字母、数字与符号
+	MinCharClasses int `json:"min_char_classes" yaml:"min_char_classes" mapstructure:"min_char_classes"`
+	// DisallowUserInfo 是否禁止密码包含用户名或昵称（不区分大小写）
+	DisallowUserInfo bool `json:"disallow_user_info" yaml:"disallow_user_info" mapstructure:"disallow_user_info"`
+	// HistoryCount 最近 N 次使用过的密码（含当前密码）不能再次使用，0 表示不限制
+	HistoryCount int `json:"history_count" yaml:"history_count" mapstructure:"history_count"`
+	// MaxAge 密码最长使用期限，超过后登录时要求修改，0 表示不限制
+	MaxAge time.Duration `json:"max_age" yaml:"max_age" mapstructure:"max_age"`
+	// BreachedCheck 是否拒绝已泄露的密码
+	BreachedCheck bool `json:"breached_check" yaml:"breached_check" mapstructure:"breached_check"`
+	// BreachedListPath 额外的泄露密码列表，每行一个大写 SHA-1 摘要，兼容 Have I Been Pwned 的 "摘要:次数" 格式；
+	// 为空时只使用内置的常见泄露密码
+	BreachedListPath string `json:"breached_list_path" yaml:"breached_list_path" mapstructure:"breached_list_path"`
+}
+
+// 新注册账号的激活方式
+const (
+	ActivationModeAuto     = "auto"     // 注册后立即可用
+	ActivationModeEmail    = "email"    // 通过邮件中的链接激活，注册时必须填写邮箱
+	ActivationModeApproval = "approval" // 管理员审核通过后可用
+)
+
+// AccountConfig 账号激活、找回密码与邮箱验证配置
+type AccountConfig struct {
+	// ActivationMode 新注册账号的激活方式：auto、email 或 approval
+	ActivationMode string `json:"activation_mode" yaml:"activation_mode" mapstructure:"activation_mode"`
+	// PasswordResetTTL 重置密码链接的有效期
+	PasswordResetTTL time.Duration `json:"password_reset_ttl" yaml:"password_reset_ttl" mapstructure:"password_reset_ttl"`
+	// EmailVerificationTTL 邮箱验证链接的有效期
+	EmailVerificationTTL time.Duration `json:"email_verification_ttl" yaml:"email_verification_ttl" mapstructure:"email_verification_ttl"`
+}
+
+// 邮件发送方式
+const (
+	MailDriverSMTP   = "smtp"   // 通过 SMTP 发送
+	MailDriverMemory = "memory" // 只保存在内存中，用于测试与开发环境
+)
+
+// MailConfig 邮件发送配置
+type MailConfig struct {
+	// Driver 发送方式，smtp 或 memory；为 smtp 但未配置 smtp.host 时回退到 memory
+	Driver string `json:"driver" yaml:"driver" mapstructure:"driver"`
+	// From 发件人地址
+	From string `json:"from" yaml:"from" mapstructure:"from"`
+	// FromName 发件人显示名称
+	FromName string `json:"from_name" yaml:"from_name" mapstructure:"from_name"`
+	// SMTP SMTP 服务器配置
+	SMTP SMTPConfig `json:"smtp" yaml:"smtp" mapstructure:"smtp"`
+}
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host     string `json:"host" yaml:"host" mapstructure:"host"`
+	Port     int    `json:"port" yaml:"port" mapstructure:"port"`
+	Username string `json:"username" yaml:"username" mapstructure:"username"`
+	Password string `json:"password" yaml:"password" mapstructure:"password"`
+	// TLS 加密方式：starttls、tls（465 端口直连 TLS）或 none
+	TLS string `json:"tls" yaml:"tls" mapstructure:"tls"`
+	// Timeout 单封邮件从连接到发送完成的时限
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+}
+
+// 外部身份提供方类型
+const (
+	FederatedProviderOIDC   = "oidc"   // 标准 OIDC，通过发现文档获取端点，校验 ID Token
+	FederatedProviderGitHub = "github" // GitHub 风格的 OAuth2，无 ID Token，通过用户信息接口获取身份
+)
+
+// FederationConfig 外部身份提供方登录配置
+type FederationConfig struct {
+	// StateTTL 跳转到身份提供方后完成登录的时限
+	StateTTL time.Duration `json:"state_ttl" yaml:"state_ttl" mapstructure:"state_ttl"`
+	// HTTPTimeout 请求身份提供方的超时时间
+	HTTPTimeout time.Duration `json:"http_timeout" yaml:"http_timeout" mapstructure:"http_timeout"`
+	// Providers 身份提供方列表
+	Providers []FederatedProviderConfig `json:"providers" yaml:"providers" mapstructure:"providers"`
+}
+
+// FederatedProviderConfig 单个外部身份提供方
+type FederatedProviderConfig struct {
+	// Name 提供方标识，只能包含小写字母、数字、下划线与连字符，出现在回调地址中，配置后不应修改
+	Name string `json:"name" yaml:"name" mapstructure:"name"`
+	// DisplayName 登录按钮上显示的名称
+	DisplayName string `json:"display_name" yaml:"display_name" mapstructure:"display_name"`
+	// Type 提供方类型：oidc 或 github
+	Type string `json:"type" yaml:"type" mapstructure:"type"`
+	// Issuer 签发方；oidc 类型据此获取发现文档，github 类型只作为身份的命名空间，默认 https://github.com
+	Issuer       string `json:"issuer" yaml:"issuer" mapstructure:"issuer"`
+	ClientID     string `json:"client_id" yaml:"client_id" mapstructure:"client_id"`
+	ClientSecret string `json:"client_secret" yaml:"client_secret" mapstructure:"client_secret"`
+	// Scopes 申请的权限范围，为空时 oidc 使用 openid profile email，github 使用 read:user user:email
+	Scopes []string `json:"scopes" yaml:"scopes" mapstructure:"scopes"`
+	// AuthorizationURL、TokenURL、UserInfoURL 端点地址；oidc 类型为空时从发现文档获取，github 类型为空时使用 GitHub 的地址
+	AuthorizationURL string `json:"authorization_url" yaml:"authorization_url" mapstructure:"authorization_url"`
+	TokenURL         string `json:"token_url" yaml:"token_url" mapstructure:"token_url"`
+	UserInfoURL      string `json:"userinfo_url" yaml:"userinfo_url" mapstructure:"userinfo_url"`
+	// EmailsURL github 类型获取已验证邮箱的地址，为空时使用 GitHub 的地址
+	EmailsURL string `json:"emails_url" yaml:"emails_url" mapstructure:"emails_url"`
+	// AllowSignup 是否为首次登录的外部身份自动创建本地账号，关闭时只能登录已关联的账号
+	AllowSignup bool `json:"allow_signup" yaml:"allow_signup" mapstructure:"allow_signup"`
+}
+
+// Validate 校验身份提供方配置：标识合法且不重复，类型受支持，必填项已填写
+func (c FederationConfig) Validate() error {
+	names := make(map[string]bool, len(c.Providers))
+	for _, provider := range c.Providers {
+		if !federatedProviderNamePattern.MatchString(provider.Name) {
+			return fmt.Errorf("身份提供方标识无效: %q", provider.Name)
+		}
+		if names[provider.Name] {
+			return fmt.Errorf("身份提供方标识重复: %s", provider.Name)
+		}
+		names[provider.Name] = true
+
+		switch provider.Type {
+		case FederatedProviderOIDC:
+			if provider.Issuer == "" {
+				return fmt.Errorf("身份提供方 %s 未配置 issuer", provider.Name)
+			}
+		case FederatedProviderGitHub:
+		default:
+			return fmt.Errorf("身份提供方 %s 的类型不受支持: %s", provider.Name, provider.Type)
+		}
+		if provider.ClientID == "" {
+			return fmt.Errorf("身份提供方 %s 未配置 client_id", provider.Name)
+		}
+	}
+	return nil
+}
+
+var federatedProviderNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// 启用 LDAP 后本地密码的回退策略
+const (
+	LocalFallbackAlways      = "always"      // 目录中不存在的用户名或目录不可用时使用本地账号
+	LocalFallbackUnavailable = "unavailable" // 只在目录不可用时使用本地账号，用于应急
+	LocalFallbackNever       = "never"       // 只通过目录登录
+)
+
+// LDAPConfig LDAP / Active Directory 认证配置
+// 先以服务账号绑定并按用户名搜索用户条目，再以该条目的 DN 与用户输入的密码绑定校验
+type LDAPConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// URL 服务器地址，如 ldap://dc.example.com:389 或 ldaps://dc.example.com:636
+	URL string `json:"url" yaml:"url" mapstructure:"url"`
+	// StartTLS 是否在 ldap:// 连接上升级为 TLS
+	StartTLS bool `json:"start_tls" yaml:"start_tls" mapstructure:"start_tls"`
+	// InsecureSkipVerify 是否跳过证书校验，只应用于测试环境
+	InsecureSkipVerify bool `json:"insecure_skip_verify" yaml:"insecure_skip_verify" mapstructure:"insecure_skip_verify"`
+	// Timeout 连接与单次操作的超时时间
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	// BindDN、BindPassword 搜索用户使用的服务账号，为空时匿名搜索
+	BindDN       string `json:"bind_dn" yaml:"bind_dn" mapstructure:"bind_dn"`
+	BindPassword string `json:"bind_password" yaml:"bind_password" mapstructure:"bind_password"`
+	// BaseDN 搜索用户的起点
+	BaseDN string `json:"base_dn" yaml:"base_dn" mapstructure:"base_dn"`
+	// UserFilter 搜索用户的过滤器，%s 替换为转义后的用户名；AD 通常为 (&(objectClass=user)(sAMAccountName=%s))
+	UserFilter string `json:"user_filter" yaml:"user_filter" mapstructure:"user_filter"`
+	// Attributes 目录属性与本地用户字段的对应关系
+	Attributes LDAPAttributeMapping `json:"attributes" yaml:"attributes" mapstructure:"attributes"`
+	// GroupAttribute 用户条目上记录所属组 DN 的属性，AD 与 OpenLDAP（memberOf overlay）均为 memberOf
+	GroupAttribute string `json:"group_attribute" yaml:"group_attribute" mapstructure:"group_attribute"`
+	// RoleMapping 组与角色的对应关系，按顺序取第一个匹配的组，均不匹配时使用 DefaultRole
+	// 角色按名称对应角色管理中的角色，名称不存在时不分配
+	RoleMapping []LDAPRoleMapping `json:"role_mapping" yaml:"role_mapping" mapstructure:"role_mapping"`
+	DefaultRole string            `json:"default_role" yaml:"default_role" mapstructure:"default_role"`
+	// AllowSignup 目录用户首次登录时是否自动创建本地账号
+	AllowSignup bool `json:"allow_signup" yaml:"allow_signup" mapstructure:"allow_signup"`
+	// LocalFallback 本地密码的回退策略：always、unavailable 或 never
+	LocalFallback string `json:"local_fallback" yaml:"local_fallback" mapstructure:"local_fallback"`
+}
+
+// Validate 校验启用的 LDAP 配置：必填项已填写，过滤器包含用户名占位符，角色与回退策略取值合法
+func (c LDAPConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.URL == "" {
+		return errors.New("LDAP 未配置 url")
+	}
+	if c.StartTLS && strings.HasPrefix(strings.ToLower(c.URL), "ldaps://") {
+		return errors.New("LDAP 使用 ldaps:// 时不能同时启用 start_tls")
+	}
+	if c.BaseDN == "" {
+		return errors.New("LDAP 未配置 base_dn")
+	}
+	if strings.Count(c.UserFilter, "%s") != 1 || strings.Count(c.UserFilter, "%") != 1 {
+		return fmt.Errorf("LDAP 用户过滤器须包含且只包含一个 %%s: %q", c.UserFilter)
+	}
+	if c.DefaultRole == "" {
+		return fmt.Errorf("LDAP 默认角色无效: %q", c.DefaultRole)
+	}
+	for _, mapping := range c.RoleMapping {
+		if mapping.Group == "" || mapping.Role == "" {
+			return fmt.Errorf("LDAP 组角色映射无效: %q -> %q", mapping.Group, mapping.Role)
+		}
+	}
+	switch c.LocalFallback {
+	case LocalFallbackAlways, LocalFallbackUnavailable, LocalFallbackNever:
+	default:
+		return fmt.Errorf("LDAP 本地密码回退策略无效: %q", c.LocalFallback)
+	}
+	return nil
+}
+
+// LDAPAttributeMapping 目录属性映射，为空的项不同步
+type LDAPAttributeMapping struct {
+	// Username 作为本地用户名的属性，为空时使用登录时输入的用户名
+	Username string `json:"username" yaml:"username" mapstructure:"username"`
+	Nickname string `json:"nickname" yaml:"nickname" mapstructure:"nickname"`
+	Email    string `json:"email" yaml:"email" mapstructure:"email"`
+	// Subject 作为用户标识（OIDC sub）的属性，只在创建本地账号时写入，如 entryUUID 或 objectGUID
+	Subject string `json:"subject" yaml:"subject" mapstructure:"subject"`
+}
+
+// LDAPRoleMapping 组 DN 与角色的对应关系，DN 比较不区分大小写
+type LDAPRoleMapping struct {
+	Group string `json:"group" yaml:"group" mapstructure:"group"`
+	Role  string `json:"role" yaml:"role" mapstructure:"role"`
+}
+
+// SAMLConfig SAML 2.0 身份提供方配置
+// 实体 ID 为 {server.base_url}/api/v1/saml/metadata，断言使用这里配置的证书签名
+type SAMLConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// CertificateFile、KeyFile 签名证书与私钥的 PEM 文件路径，私钥支持 RSA 与 ECDSA
+	CertificateFile string `json:"certificate_file" yaml:"certificate_file" mapstructure:"certificate_file"`
+	KeyFile         string `json:"key_file" yaml:"key_file" mapstructure:"key_file"`
+	// RequestTTL 用户未登录时暂存服务提供方认证请求的时间，须在此时间内完成登录
+	RequestTTL time.Duration `json:"request_ttl" yaml:"request_ttl" mapstructure:"request_ttl"`
+	// MetadataValidDuration 元数据的有效期，服务提供方据此决定多久重新获取
+	MetadataValidDuration time.Duration `json:"metadata_valid_duration" yaml:"metadata_valid_duration" mapstructure:"metadata_valid_duration"`
+}
+
+// Validate 校验启用的 SAML 配置
+func (c SAMLConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.CertificateFile == "" || c.KeyFile == "" {
+		return errors.New("SAML 未配置签名证书 certificate_file 与私钥 key_file")
+	}
+	if c.RequestTTL <= 0 {
+		return errors.New("SAML request_ttl 须大于 0")
+	}
+	return nil
+}
+
+// 授权策略的执行模式
+const (
+	AuthorizationModeEnforce = "enforce" // 拒绝不满足策略的请求
+	AuthorizationModeDryRun  = "dry_run" // 只记录本应拒绝的请求而不拦截，用于评估新策略的影响
+)
+
+// 规则中的主体类型
+const (
+	PolicyPrincipalUser   = "user"
+	PolicyPrincipalClient = "client"
+)
+
+// 规则中资源所有者的 user_id 来源
+const (
+	PolicyOwnerParam = "param" // 路径参数 user_id
+	PolicyOwnerQuery = "query" // 查询参数 user_id
+)
+
+// AuthorizationConfig 接口授权策略配置
+// 路由在认证之后通过 Authorize 中间件按 "方法 路由模板" 查找绑定的策略，未绑定策略的路由一律拒绝
+type AuthorizationConfig struct {
+	// Mode 执行模式：enforce 或 dry_run
+	Mode string `json:"mode" yaml:"mode" mapstructure:"mode"`
+	// Policies 策略名到策略的映射，配置文件中的同名策略整体覆盖默认策略
+	Policies map[string]PolicyConfig `json:"policies" yaml:"policies" mapstructure:"policies"`
+}
+
+// PolicyConfig 单条策略，满足任一规则即允许访问，没有规则时拒绝全部请求
+type PolicyConfig struct {
+	Description string `json:"description" yaml:"description" mapstructure:"description"`
+	// Routes 绑定的路由，格式为 "方法 路由模板"，如 "GET /api/v1/users/:user_id"；
+	// 方法为 * 时匹配全部方法，模板以 * 结尾时按前缀匹配，精确匹配优先
+	Routes []string `json:"routes" yaml:"routes" mapstructure:"routes"`
+	// Rules 规则列表
+	Rules []PolicyRule `json:"rules" yaml:"rules" mapstructure:"rules"`
+	// DryRun 只对该策略启用演练模式
+	DryRun bool `json:"dry_run" yaml:"dry_run" mapstructure:"dry_run"`
+}
+
+// PolicyRule 单条规则，已配置的条件须全部满足，未配置的条件不参与判断
+type PolicyRule struct {
+	// Principal 主体类型：user 或 client，为空时不限
+	Principal string `json:"principal" yaml:"principal" mapstructure:"principal"`
+	// Scopes 须全部具备的 OAuth 范围，只约束 Bearer 令牌，Cookie 登录不受范围限制
+	Scopes []string `json:"scopes" yaml:"scopes" mapstructure:"scopes"`
+	// Roles 须具备其中任一角色
+	Roles []string `json:"roles" yaml:"roles" mapstructure:"roles"`
+	// Permissions 须全部具备的权限
+	Permissions []string `json:"permissions" yaml:"permissions" mapstructure:"permissions"`
+	// Owner 当前用户须为资源所有者：param 或 query，表示从路径参数或查询参数读取 user_id
+	Owner string `json:"owner" yaml:"owner" mapstructure:"owner"`
+	// ClientIDs 令牌须由其中任一客户端签发
+	ClientIDs []string `json:"client_ids" yaml:"client_ids" mapstructure:"client_ids"`
+}
+
+// Validate 校验授权策略配置：模式与规则取值合法，路由格式正确且不重复绑定
+func (c AuthorizationConfig) Validate() error {
+	switch c.Mode {
+	case AuthorizationModeEnforce, AuthorizationModeDryRun:
+	default:
+		return fmt.Errorf("授权策略执行模式无效: %q", c.Mode)
+	}
+
+	routes := make(map[string]string)
+	for name, policy := range c.Policies {
+		for _, route := range policy.Routes {
+			method, path, ok := strings.Cut(route, " ")
+			if !ok || method == "" || !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("策略 %s 的路由格式无效: %q", name, route)
+			}
+			if other, exists := routes[route]; exists {
+				return fmt.Errorf("路由 %q 同时绑定了策略 %s 与 %s", route, other, name)
+			}
+			routes[route] = name
+		}
+		for i, rule := range policy.Rules {
+			switch rule.Principal {
+			case "", PolicyPrincipalUser, PolicyPrincipalClient:
+			default:
+				return fmt.Errorf("策略 %s 第 %d 条规则的主体类型无效: %q", name, i+1, rule.Principal)
+			}
+			switch rule.Owner {
+			case "", PolicyOwnerParam, PolicyOwnerQuery:
+			default:
+				return fmt.Errorf("策略 %s 第 %d 条规则的资源所有者来源无效: %q", name, i+1, rule.Owner)
+			}
+		}
+	}
+	return nil
+}
+
+// 审计事件的外部输出方式，事件始终写入数据库，外部输出是额外的一份
+const (
+	AuditSinkNone   = "none"   // 只写入数据库
+	AuditSinkStdout = "stdout" // 以 JSON Lines 写到标准输出，由容器日志采集转发
+	AuditSinkFile   = "file"   // 以 JSON Lines 追加写入文件
+)
+
+// AuditConfig 安全审计事件配置
+type AuditConfig struct {
+	// Sink 外部输出方式：none、stdout 或 file
+	Sink string `json:"sink" yaml:"sink" mapstructure:"sink"`
+	// File sink 为 file 时写入的文件路径
+	File string `json:"file" yaml:"file" mapstructure:"file"`
+}
+
+// Validate 校验审计配置
+func (c AuditConfig) Validate() error {
+	switch c.Sink {
+	case "", AuditSinkNone, AuditSinkStdout:
+	case AuditSinkFile:
+		if c.File == "" {
+			return errors.New("审计输出为 file 时须配置 file")
+		}
+	default:
+		return fmt.Errorf("不支持的审计输出方式: %s", c.Sink)
+	}
+	return nil
+}
+
+// WebhookConfig Webhook 投递配置，事件随变更写入投递表，由后台任务按间隔投递
+type WebhookConfig struct {
+	// Enabled 是否在服务内投递，关闭后事件仍写入投递表，开启后继续投递
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// Interval 检查待投递记录的间隔
+	Interval time.Duration `json:"interval" yaml:"interval" mapstructure:"interval"`
+	// BatchSize 每次最多投递的记录数
+	BatchSize int `json:"batch_size" yaml:"batch_size" mapstructure:"batch_size"`
+	// Timeout 单次请求超时
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	// MaxAttempts 最多投递次数，用尽后记为 dead，可由管理员重新投递
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" mapstructure:"max_attempts"`
+	// BackoffBase 首次重试的等待时间，之后每次翻倍
+	BackoffBase time.Duration `json:"backoff_base" yaml:"backoff_base" mapstructure:"backoff_base"`
+	// BackoffMax 重试等待时间的上限
+	BackoffMax time.Duration `json:"backoff_max" yaml:"backoff_max" mapstructure:"backoff_max"`
+}
+
+// Validate 校验 Webhook 配置
+func (c WebhookConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Interval <= 0 || c.BatchSize <= 0 || c.Timeout <= 0 {
+		return errors.New("webhook 的 interval、batch_size 与 timeout 须大于 0")
+	}
+	if c.MaxAttempts <= 0 {
+		return errors.New("webhook 的 max_attempts 须大于 0")
+	}
+	if c.BackoffBase <= 0 || c.BackoffMax < c.BackoffBase {
+		return errors.New("webhook 的 backoff_base 须大于 0 且不大于 backoff_max")
+	}
+	return nil
+}
+
+// LogoutConfig OpenID Connect 登出配置，用户退出登录时通知持有其有效授权的客户端
+type LogoutConfig struct {
+	// BackchannelTimeout 向客户端投递登出令牌的单次请求超时；通知经 Webhook 投递表发送，重试次数与间隔沿用 webhook 配置
+	BackchannelTimeout time.Duration `json:"backchannel_timeout" yaml:"backchannel_timeout" mapstructure:"backchannel_timeout"`
+	// LogoutTokenTTL 登出令牌的有效期
+	LogoutTokenTTL time.Duration `json:"logout_token_ttl" yaml:"logout_token_ttl" mapstructure:"logout_token_ttl"`
+}
+
+// Validate 校验登出配置
+func (c LogoutConfig) Validate() error {
+	if c.BackchannelTimeout <= 0 || c.LogoutTokenTTL <= 0 {
+		return errors.New("logout 的 backchannel_timeout 与 logout_token_ttl 须大于 0")
+	}
+	return nil
+}
+
+// MiddlewareConfig goauth 自身的中间件配置
+// gokit 的 types.MiddlewareConfig 只有 auth 与 cors 且无法扩展，限流配置放在这里，与它共用配置文件中的 middleware 节点
+type MiddlewareConfig struct {
+	RateLimit RateLimitConfig `json:"rate_limit" yaml:"rate_limit" mapstructure:"rate_limit"`
+}
+
+// 限流维度
+const (
+	RateLimitKeyIP       = "ip"        // 按客户端 IP
+	RateLimitKeyClientID = "client_id" // 按已认证的 OAuth 客户端，须放在客户端认证之后，未认证时回退到 IP
+	RateLimitKeyUser     = "user"      // 按登录用户，未登录时回退到 IP
+	RateLimitKeyRoute    = "route"     // 整个路由共用一个令牌桶
+)
+
+// RateLimitConfig 令牌桶限流配置
+type RateLimitConfig struct {
+	// Enabled 是否启用限流，关闭后所有规则放行
+	Enabled bool `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	// Rules 规则名到限流规则的映射，路由通过规则名引用
+	Rules map[string]RateLimitRule `json:"rules" yaml:"rules" mapstructure:"rules"`
+}
+
+// RateLimitRule 单条限流规则：每个 Period 补充 Rate 个令牌，桶容量为 Burst
+type RateLimitRule struct {
+	// Key 限流维度：ip、client_id、user 或 route
+	Key string `json:"key" yaml:"key" mapstructure:"key"`
+	// Rate 每个周期补充的令牌数
+	Rate int `json:"rate" yaml:"rate" mapstructure:"rate"`
+	// Period 补充周期
+	Period time.Duration `json:"period" yaml:"period" mapstructure:"period"`
+	// Burst 桶容量，即允许的突发请求数，为 0 时等于 Rate
+	Burst int `json:"burst" yaml:"burst" mapstructure:"burst"`
+}
+
+// Load 从配置文件加载扩展配置，未配置的字段保持默认值
+func Load(configPath string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(configPath)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	cfg := DefaultConfig()
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// DefaultConfig 返回扩展配置的默认值
+func DefaultConfig() *Config {
+	return &Config{
+		OAuth: OAuthConfig{
+			BearerMode: BearerModeStateful,
+		},
+		Janitor: JanitorConfig{
+			Enabled:   true,
+			Interval:  time.Hour,
+			Retention: 7 * 24 * time.Hour,
+			BatchSize: 1000,
+		},
+		MFA: MFAConfig{
+			Issuer:       "GoAuth",
+			ChallengeTTL: 5 * time.Minute,
+			MaxAttempts:  5,
+		},
+		WebAuthn: WebAuthnConfig{
+			RPName:         "GoAuth",
+			Timeout:        5 * time.Minute,
+			MaxCredentials: 10,
+		},
+		LoginProtection: LoginProtectionConfig{
+			Window:              15 * time.Minute,
+			UsernameMaxFailures: 10,
+			IPMaxFailures:       50,
+			LockoutDuration:     15 * time.Minute,
+			DelayAfter:          3,
+			BaseDelay:           time.Second,
+			MaxDelay:            30 * time.Second,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:        8,
+			MaxLength:        64,
+			MinCharClasses:   2,
+			DisallowUserInfo: true,
+			HistoryCount:     5,
+			BreachedCheck:    true,
+		},
+		Account: AccountConfig{
+			ActivationMode:       ActivationModeAuto,
+			PasswordResetTTL:     30 * time.Minute,
+			EmailVerificationTTL: 24 * time.Hour,
+		},
+		Mail: MailConfig{
+			Driver:   MailDriverSMTP,
+			FromName: "GoAuth",
+			SMTP: SMTPConfig{
+				Port:    587,
+				TLS:     "starttls",
+				Timeout: 10 * time.Second,
+			},
+		},
+		Federation: FederationConfig{
+			StateTTL:    10 * time.Minute,
+			HTTPTimeout: 10 * time.Second,
+		},
+		LDAP: LDAPConfig{
+			Timeout:    5 * time.Second,
+			UserFilter: "(uid=%s)",
+			Attributes: LDAPAttributeMapping{
+				Username: "uid",
+				Nickname: "displayName",
+				Email:    "mail",
+			},
+			GroupAttribute: "memberOf",
+			DefaultRole:    "user",
+			AllowSignup:    true,
+			LocalFallback:  LocalFallbackAlways,
+		},
+		SAML: SAMLConfig{
+			RequestTTL:            10 * time.Minute,
+			MetadataValidDuration: 48 * time.Hour,
+		},
+		Authorization: AuthorizationConfig{
+			Mode:     AuthorizationModeEnforce,
+			Policies: defaultPolicies(),
+		},
+		Audit: AuditConfig{
+			Sink: AuditSinkNone,
+		},
+		Webhook: WebhookConfig{
+			Enabled:     true,
+			Interval:    10 * time.Second,
+			BatchSize:   100,
+			Timeout:     10 * time.Second,
+			MaxAttempts: 8,
+			BackoffBase: 30 * time.Second,
+			BackoffMax:  6 * time.Hour,
+		},
+		Logout: LogoutConfig{
+			BackchannelTimeout: 5 * time.Second,
+			LogoutTokenTTL:     2 * time.Minute,
+		},
+		Middleware: MiddlewareConfig{
+			RateLimit: RateLimitConfig{
+				Enabled: true,
+				Rules: map[string]RateLimitRule{
+					"oauth_client_auth":  {Key: RateLimitKeyIP, Rate: 300, Period: time.Minute},
+					"oauth_token":        {Key: RateLimitKeyClientID, Rate: 60, Period: time.Minute},
+					"oauth_introspect":   {Key: RateLimitKeyClientID, Rate: 600, Period: time.Minute},
+					"oauth_revoke":       {Key: RateLimitKeyClientID, Rate: 60, Period: time.Minute},
+					"register":           {Key: RateLimitKeyIP, Rate: 10, Period: time.Hour},
+					"login":              {Key: RateLimitKeyIP, Rate: 30, Period: time.Minute},
+					"mfa":                {Key: RateLimitKeyIP, Rate: 30, Period: time.Minute},
+					"passkey":            {Key: RateLimitKeyIP, Rate: 30, Period: time.Minute},
+					"refresh_token":      {Key: RateLimitKeyIP, Rate: 60, Period: time.Minute},
+					"password_reset":     {Key: RateLimitKeyIP, Rate: 10, Period: time.Hour},
+					"email_verification": {Key: RateLimitKeyUser, Rate: 5, Period: time.Hour},
+					"activation_resend":  {Key: RateLimitKeyIP, Rate: 5, Period: time.Hour},
+				},
+			},
+		},
+	}
+}
+
+// defaultPolicies 默认授权策略：管理接口按权限放行，用户资源允许本人或拥有用户管理权限者访问
+func defaultPolicies() map[string]PolicyConfig {
+	return map[string]PolicyConfig{
+		"user_profile_read": {
+			Description: "查看用户详情：本人、拥有 user:read 权限的用户，或具备 profile 范围的客户端",
+			Routes:      []string{"GET /api/v1/users/:user_id"},
+			Rules: []PolicyRule{
+				{Principal: PolicyPrincipalUser, Owner: PolicyOwnerParam, Scopes: []string{"profile"}},
+				{Principal: PolicyPrincipalUser, Permissions: []string{"user:read"}, Scopes: []string{"profile"}},
+				{Principal: PolicyPrincipalClient, Scopes: []string{"profile"}},
+			},
+		},
+		"user_profile_write": {
+			Description: "修改用户资料、发送邮箱验证邮件：本人或拥有 user:write 权限的用户",
+			Routes: []string{
+				"PATCH /api/v1/users/:user_id",
+				"POST /api/v1/users/:user_id/email/verification",
+			},
+			Rules: []PolicyRule{
+				{Principal: PolicyPrincipalUser, Owner: PolicyOwnerParam},
+				{Principal: PolicyPrincipalUser, Permissions: []string{"user:write"}},
+			},
+		},
+		"user_roles_read": {
+			Description: "查看用户的角色与权限：本人，或拥有 user:read 或 rbac:read 权限的用户",
+			Routes:      []string{"GET /api/v1/users/:user_id/roles"},
+			Rules: []PolicyRule{
+				{Principal: PolicyPrincipalUser, Owner: PolicyOwnerParam},
+				{Principal: PolicyPrincipalUser, Permissions: []string{"user:read"}},
+				{Principal: PolicyPrincipalUser, Permissions: []string{"rbac:read"}},
+			},
+		},
+		"user_admin_read": {
+			Description: "查看用户列表与账号锁定状态",
+			Routes: []string{
+				"GET /api/v1/users",
+				"GET /api/v1/users/:user_id/lockout",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"user:read"}}},
+		},
+		"user_admin_write": {
+			Description: "删除用户、重置两步验证、解除登录锁定",
+			Routes: []string{
+				"DELETE /api/v1/users/:user_id",
+				"DELETE /api/v1/users/:user_id/mfa",
+				"DELETE /api/v1/users/:user_id/lockout",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"user:write"}}},
+		},
+		"registration_review": {
+			Description: "审核注册申请",
+			Routes:      []string{"* /api/v1/registrations*"},
+			Rules:       []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"registration:review"}}},
+		},
+		"oauth_client_read": {
+			Description: "查看 OAuth 客户端",
+			Routes: []string{
+				"GET /api/v1/oauth/clients",
+				"GET /api/v1/oauth/clients/:id",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"oauth_client:read"}}},
+		},
+		"oauth_client_write": {
+			Description: "创建、修改、删除 OAuth 客户端",
+			Routes: []string{
+				"POST /api/v1/oauth/clients",
+				"PATCH /api/v1/oauth/clients/:id",
+				"DELETE /api/v1/oauth/clients/:id",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"oauth_client:write"}}},
+		},
+		"saml_sp_read": {
+			Description: "查看 SAML 服务提供方",
+			Routes: []string{
+				"GET /api/v1/saml/service-providers",
+				"GET /api/v1/saml/service-providers/:id",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"saml_sp:read"}}},
+		},
+		"saml_sp_write": {
+			Description: "创建、修改、删除 SAML 服务提供方",
+			Routes: []string{
+				"POST /api/v1/saml/service-providers",
+				"PATCH /api/v1/saml/service-providers/:id",
+				"DELETE /api/v1/saml/service-providers/:id",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"saml_sp:write"}}},
+		},
+		"rbac_read": {
+			Description: "查看权限、角色与用户组",
+			Routes: []string{
+				"GET /api/v1/permissions",
+				"GET /api/v1/roles",
+				"GET /api/v1/roles/:id",
+				"GET /api/v1/groups",
+				"GET /api/v1/groups/:id",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"rbac:read"}}},
+		},
+		"rbac_write": {
+			Description: "管理角色、用户组及其分配",
+			Routes: []string{
+				"POST /api/v1/roles",
+				"PATCH /api/v1/roles/:id",
+				"DELETE /api/v1/roles/:id",
+				"POST /api/v1/groups",
+				"PATCH /api/v1/groups/:id",
+				"DELETE /api/v1/groups/:id",
+				"PUT /api/v1/users/:user_id/roles",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"rbac:write"}}},
+		},
+		"policy_read": {
+			Description: "查看授权策略与访问矩阵，解释授权决策",
+			Routes: []string{
+				"GET /api/v1/policies",
+				"POST /api/v1/policies/explain",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"policy:read"}}},
+		},
+		"tenant_read": {
+			Description: "查看租户，仅默认租户可用",
+			Routes:      []string{"GET /api/v1/tenants"},
+			Rules:       []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"tenant:read"}}},
+		},
+		"tenant_write": {
+			Description: "创建与修改租户，仅默认租户可用",
+			Routes: []string{
+				"POST /api/v1/tenants",
+				"PATCH /api/v1/tenants/:id",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"tenant:write"}}},
+		},
+		"audit_read": {
+			Description: "查询安全审计事件",
+			Routes:      []string{"GET /api/v1/audit-events"},
+			Rules:       []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"audit:read"}}},
+		},
+		"webhook_read": {
+			Description: "查看 Webhook 订阅与投递记录",
+			Routes: []string{
+				"GET /api/v1/webhooks",
+				"GET /api/v1/webhooks/:id",
+				"GET /api/v1/webhook-deliveries",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"webhook:read"}}},
+		},
+		"webhook_write": {
+			Description: "创建、修改、删除 Webhook 订阅，重新投递失败的记录",
+			Routes: []string{
+				"POST /api/v1/webhooks",
+				"PATCH /api/v1/webhooks/:id",
+				"DELETE /api/v1/webhooks/:id",
+				"POST /api/v1/webhook-deliveries/:id/retry",
+			},
+			Rules: []PolicyRule{{Principal: PolicyPrincipalUser, Permissions: []string{"webhook:write"}}},
+		},
+		"scim": {
+			Description: "SCIM 预配接口：具备 scim 范围的客户端凭证令牌",
+			Routes:      []string{"* /scim/v2/*"},
+			Rules:       []PolicyRule{{Principal: PolicyPrincipalClient, Scopes: []string{"scim"}}},
+		},
+	}
+}
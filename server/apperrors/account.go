@@ -0,0 +1,13 @@
+package apperrors
+
+import "errors"
+
+// 找回密码与邮箱验证业务错误定义
+
+var (
+	ErrAccountTokenInvalid  = errors.New("链接无效或已过期，请重新获取")
+	ErrEmailNotSet          = errors.New("尚未设置邮箱")
+	ErrEmailAlreadyVerified = errors.New("邮箱已验证")
+	ErrEmailTaken           = errors.New("邮箱已被其他账号使用")
+	ErrMailSendFailed       = errors.New("邮件发送失败，请稍后再试")
+)
@@ -0,0 +1,19 @@
+package apperrors
+
+import "errors"
+
+// 账号激活与注册审核业务错误定义
+
+var (
+	// 账号状态，登录、刷新令牌与找回密码时按状态返回
+	ErrUserDisabled          = errors.New("账号已被禁用，请联系管理员")
+	ErrUserPendingActivation = errors.New("账号尚未激活，请通过邮件中的链接完成激活")
+	ErrUserPendingApproval   = errors.New("账号正在等待管理员审核")
+	ErrUserRejected          = errors.New("注册申请未通过审核")
+
+	// 注册
+	ErrEmailRequired = errors.New("注册需要填写邮箱，用于激活账号")
+
+	// 注册审核
+	ErrRegistrationNotPending = errors.New("该用户不在待审核状态")
+)
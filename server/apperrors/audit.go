@@ -0,0 +1,9 @@
+package apperrors
+
+import "errors"
+
+// 审计业务错误定义
+
+var (
+	ErrAuditQueryFailed = errors.New("查询审计事件失败")
+)
@@ -0,0 +1,14 @@
+package apperrors
+
+import "errors"
+
+// 认证后端业务错误定义
+
+var (
+	ErrInvalidCredentials         = errors.New("账号或密码错误")
+	ErrAuthenticatorUserNotFound  = errors.New("认证后端中不存在该用户") // 只在认证后端之间传递，不返回给客户端
+	ErrAuthenticatorUnavailable   = errors.New("认证服务暂不可用，请稍后再试")
+	ErrDirectoryAccountConflict   = errors.New("该用户名已被本地账号使用，请联系管理员")
+	ErrDirectorySignupDisabled    = errors.New("目录账号尚未开通，请联系管理员")
+	ErrPasswordManagedByDirectory = errors.New("该账号的密码由企业目录管理，请在目录中修改")
+)
@@ -0,0 +1,20 @@
+package apperrors
+
+import "errors"
+
+// 外部身份提供方登录业务错误定义
+
+var (
+	ErrFederatedProviderNotFound = errors.New("身份提供方不存在")
+	ErrFederatedStateInvalid     = errors.New("登录已超时或请求无效，请重新发起")
+	ErrFederatedUpstreamFailed   = errors.New("身份提供方验证失败，请稍后再试")
+	ErrFederatedCanceled         = errors.New("已取消外部账号授权")
+	ErrFederatedIdentityInvalid  = errors.New("身份提供方返回的身份信息无效")
+	ErrFederatedSignupDisabled   = errors.New("该外部账号尚未关联本地账号，请先登录后在个人中心关联")
+	ErrFederatedEmailConflict    = errors.New("该邮箱已被本地账号使用，请先用原账号登录后在个人中心关联")
+	ErrFederatedIdentityTaken    = errors.New("该外部账号已关联其他用户")
+	ErrFederatedProviderLinked   = errors.New("已关联该身份提供方的账号，请先解除关联")
+	ErrFederatedIdentityNotFound = errors.New("外部账号关联不存在")
+	ErrFederatedLastLoginMethod  = errors.New("这是账号唯一的登录方式，请先设置密码后再解除关联")
+	ErrFederatedTicketInvalid    = errors.New("登录凭据无效或已过期，请重新登录")
+)
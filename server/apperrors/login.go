@@ -0,0 +1,27 @@
+package apperrors
+
+import (
+	"errors"
+	"time"
+)
+
+// 登录防护业务错误定义
+
+var (
+	ErrLoginThrottled = errors.New("登录尝试过于频繁，请稍后再试")
+	ErrLockoutFailed  = errors.New("解除登录锁定失败")
+)
+
+// LoginThrottledError 登录被限制，携带可重试的等待时间
+// 用户名不存在时同样计数与锁定，调用方无法据此判断账号是否存在
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrLoginThrottled
+}
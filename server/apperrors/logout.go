@@ -0,0 +1,15 @@
+package apperrors
+
+import "errors"
+
+// OpenID Connect 登出业务错误定义
+
+var (
+	ErrLogoutURIInvalid             = errors.New("登出地址须为不含片段的 http(s) 绝对地址")
+	ErrLogoutIDTokenHintInvalid     = errors.New("id_token_hint 无效")
+	ErrLogoutClientNotFound         = errors.New("OAuth客户端不存在")
+	ErrLogoutClientMismatch         = errors.New("client_id 与 id_token_hint 不一致")
+	ErrLogoutClientRequired         = errors.New("指定 post_logout_redirect_uri 时须提供 id_token_hint 或 client_id")
+	ErrPostLogoutRedirectURIInvalid = errors.New("post_logout_redirect_uri 不在客户端的登出回调地址列表中")
+	ErrLogoutUserMismatch           = errors.New("id_token_hint 对应的用户与当前登录用户不一致")
+)
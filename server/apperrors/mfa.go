@@ -0,0 +1,17 @@
+package apperrors
+
+import "errors"
+
+// 两步验证业务错误定义
+
+var (
+	ErrMFANotEnabled            = errors.New("未启用两步验证")
+	ErrMFAAlreadyEnabled        = errors.New("已启用两步验证")
+	ErrMFARequiredForAdmin      = errors.New("管理员账号必须启用两步验证")
+	ErrMFACodeInvalid           = errors.New("验证码错误")
+	ErrMFAUpdateFailed          = errors.New("更新两步验证设置失败")
+	ErrTOTPNotSetup             = errors.New("请先发起验证器绑定")
+	ErrMFAChallengeInvalid      = errors.New("两步验证已超时，请重新登录")
+	ErrMFAChallengeExhausted    = errors.New("验证码错误次数过多，请重新登录")
+	ErrMFAChallengeCreateFailed = errors.New("创建两步验证失败")
+)
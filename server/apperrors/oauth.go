@@ -0,0 +1,17 @@
+package apperrors
+
+import "errors"
+
+// OAuth 令牌业务错误定义
+
+var (
+	// 访问令牌校验相关
+	ErrAccessTokenInvalid  = errors.New("令牌无效")
+	ErrAccessTokenRevoked  = errors.New("令牌已撤销")
+	ErrAccessTokenExpired  = errors.New("令牌已过期")
+	ErrAccessTokenAudience = errors.New("令牌受众不匹配")
+	ErrAccessTokenVerify   = errors.New("令牌校验失败，请稍后再试")
+
+	// 客户端相关
+	ErrOAuthClientDisabled = errors.New("OAuth客户端已禁用")
+)
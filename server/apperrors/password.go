@@ -0,0 +1,14 @@
+package apperrors
+
+import "errors"
+
+// 密码策略业务错误定义
+
+var (
+	ErrPasswordTooShort         = errors.New("密码长度不足")
+	ErrPasswordTooLong          = errors.New("密码过长")
+	ErrPasswordTooWeak          = errors.New("密码包含的字符类别过少")
+	ErrPasswordContainsUserInfo = errors.New("密码不能包含用户名或昵称")
+	ErrPasswordBreached         = errors.New("该密码已出现在公开泄露的密码中，请更换")
+	ErrPasswordReused           = errors.New("不能使用最近用过的密码")
+)
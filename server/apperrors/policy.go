@@ -0,0 +1,10 @@
+package apperrors
+
+import "errors"
+
+// 授权策略业务错误定义
+
+var (
+	ErrPolicyNotFound      = errors.New("授权策略不存在")
+	ErrPolicyRouteNotFound = errors.New("该路由未绑定授权策略")
+)
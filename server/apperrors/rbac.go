@@ -0,0 +1,17 @@
+package apperrors
+
+import "errors"
+
+// 角色、权限与用户组管理业务错误定义
+
+var (
+	ErrRBACSystemBusy = errors.New("系统繁忙，请稍后再试")
+
+	ErrRoleNotFound       = errors.New("角色不存在")
+	ErrRoleExists         = errors.New("角色名称已存在")
+	ErrRoleBuiltin        = errors.New("内置角色不能改名或删除，管理员角色的权限不能修改")
+	ErrPermissionNotFound = errors.New("权限不存在")
+
+	ErrGroupNotFound = errors.New("用户组不存在")
+	ErrGroupExists   = errors.New("用户组名称已存在")
+)
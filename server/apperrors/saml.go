@@ -0,0 +1,17 @@
+package apperrors
+
+import "errors"
+
+// SAML 身份提供方业务错误定义
+
+var (
+	ErrSAMLDisabled                = errors.New("SAML 身份提供方未启用")
+	ErrSAMLRequestInvalid          = errors.New("SAML 认证请求无效")
+	ErrSAMLRequestExpired          = errors.New("SAML 认证请求已过期，请回到应用重新登录")
+	ErrSAMLServiceProviderNotFound = errors.New("SAML 服务提供方不存在")
+	ErrSAMLServiceProviderDisabled = errors.New("SAML 服务提供方已禁用")
+	ErrSAMLServiceProviderExists   = errors.New("该实体ID的服务提供方已存在")
+	ErrSAMLMetadataInvalid         = errors.New("服务提供方元数据无效")
+	ErrSAMLIDPInitiatedDisabled    = errors.New("该应用不允许从本系统直接登录")
+	ErrSAMLResponseFailed          = errors.New("生成 SAML 响应失败")
+)
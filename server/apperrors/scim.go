@@ -0,0 +1,19 @@
+package apperrors
+
+import "errors"
+
+// SCIM 预配接口业务错误定义，控制器按 RFC 7644 映射为状态码与 scimType
+
+var (
+	ErrSCIMInvalidFilter      = errors.New("过滤表达式无效")
+	ErrSCIMInvalidPath        = errors.New("属性路径无效")
+	ErrSCIMInvalidValue       = errors.New("属性值无效")
+	ErrSCIMInvalidSyntax      = errors.New("请求格式无效")
+	ErrSCIMNoTarget           = errors.New("属性路径没有匹配的值")
+	ErrSCIMMutability         = errors.New("不能修改只读属性")
+	ErrSCIMUniqueness         = errors.New("属性值与已有资源冲突")
+	ErrSCIMNotFound           = errors.New("资源不存在")
+	ErrSCIMPreconditionFailed = errors.New("资源版本已变化")
+	ErrSCIMTooMany            = errors.New("请求的操作数量超出限制")
+	ErrSCIMBulkReference      = errors.New("bulkId 引用无法解析")
+)
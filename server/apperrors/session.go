@@ -0,0 +1,14 @@
+package apperrors
+
+import "errors"
+
+// 登录会话业务错误定义
+
+var (
+	ErrSessionNotFound     = errors.New("会话不存在")
+	ErrSessionInvalid      = errors.New("会话已失效，请重新登录")
+	ErrSessionCreateFailed = errors.New("创建会话失败")
+	ErrSessionRevokeFailed = errors.New("注销会话失败")
+	ErrRefreshTokenRotated = errors.New("刷新令牌已轮换，请使用新的刷新令牌")
+	ErrRefreshTokenReused  = errors.New("刷新令牌被重复使用，会话已注销，请重新登录")
+)
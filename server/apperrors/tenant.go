@@ -0,0 +1,16 @@
+package apperrors
+
+import "errors"
+
+// 租户管理业务错误定义
+
+var (
+	ErrTenantSystemBusy  = errors.New("系统繁忙，请稍后再试")
+	ErrTenantNotFound    = errors.New("租户不存在")
+	ErrTenantDisabled    = errors.New("租户已停用")
+	ErrTenantExists      = errors.New("租户标识已存在")
+	ErrTenantNameInvalid = errors.New("租户标识只能包含小写字母、数字与连字符，且以字母或数字开头")
+	ErrTenantDefault     = errors.New("默认租户不能停用")
+	ErrTenantAdminOnly   = errors.New("只能在默认租户中管理租户")
+	ErrTenantAdminFailed = errors.New("初始管理员创建失败，租户未创建")
+)
@@ -20,6 +20,7 @@ var (
 
 	// 用户创建相关
 	ErrUserCreateFailed        = errors.New("创建用户失败")
+	ErrUsernameTaken           = errors.New("用户名已被占用")
 	ErrUserSubjectGenFailed    = errors.New("生成用户标识失败")
 	ErrUserSubjectUpdateFailed = errors.New("更新用户标识失败")
 
@@ -0,0 +1,16 @@
+package apperrors
+
+import "errors"
+
+// 通行密钥业务错误定义
+
+var (
+	ErrWebAuthnCeremonyInvalid  = errors.New("通行密钥验证已超时，请重试")
+	ErrWebAuthnCeremonyFailed   = errors.New("发起通行密钥验证失败")
+	ErrWebAuthnVerifyFailed     = errors.New("通行密钥验证失败")
+	ErrWebAuthnCredentialExists = errors.New("该通行密钥已注册")
+	ErrWebAuthnCredentialCloned = errors.New("通行密钥签名计数异常，可能已被复制，请联系管理员")
+	ErrWebAuthnNotFound         = errors.New("通行密钥不存在")
+	ErrWebAuthnNoCredentials    = errors.New("尚未注册通行密钥")
+	ErrWebAuthnLimitExceeded    = errors.New("通行密钥数量已达上限")
+)
@@ -0,0 +1,14 @@
+package apperrors
+
+import "errors"
+
+// Webhook 业务错误定义
+
+var (
+	ErrWebhookSystemBusy         = errors.New("系统繁忙，请稍后再试")
+	ErrWebhookNotFound           = errors.New("Webhook 订阅不存在")
+	ErrWebhookURLInvalid         = errors.New("Webhook 地址须为 http 或 https 地址")
+	ErrWebhookEventInvalid       = errors.New("不支持的 Webhook 事件类型")
+	ErrWebhookDeliveryNotFound   = errors.New("投递记录不存在或未处于失败状态")
+	ErrWebhookEventEnqueueFailed = errors.New("写入 Webhook 事件失败")
+)
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type AccountController struct {
+	accountService   *services.AccountService
+	validatorManager *validator.Manager
+}
+
+func NewAccountController(accountService *services.AccountService, validatorManager *validator.Manager) *AccountController {
+	return &AccountController{accountService: accountService, validatorManager: validatorManager}
+}
+
+func (ctrl *AccountController) ForgotPasswordHandler(ctx *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.accountService.RequestPasswordReset(ctx.Request.Context(), req.Email); err != nil {
+		failAccount(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("如果该邮箱已绑定并验证，重置密码邮件将很快送达"))
+}
+
+func (ctrl *AccountController) ResetPasswordHandler(ctx *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.accountService.ResetPassword(ctx.Request.Context(), &req); err != nil {
+		failAccount(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("密码已重置，请使用新密码登录"))
+}
+
+func (ctrl *AccountController) SendEmailVerificationHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+		return
+	}
+
+	if err := ctrl.accountService.SendEmailVerification(ctx.Request.Context(), uint(userID)); err != nil {
+		failAccount(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("验证邮件已发送，请查收"))
+}
+
+func (ctrl *AccountController) VerifyEmailHandler(ctx *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.accountService.VerifyEmail(ctx.Request.Context(), req.Token); err != nil {
+		failAccount(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("邮箱验证成功"))
+}
+
+func (ctrl *AccountController) ResendActivationHandler(ctx *gin.Context) {
+	var req dto.ResendActivationRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.accountService.ResendActivation(ctx.Request.Context(), req.Email); err != nil {
+		failAccount(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("如果该邮箱对应的账号尚未激活，激活邮件将很快送达"))
+}
+
+func failAccount(ctx *gin.Context, err error) {
+	switch {
+	case err == apperrors.ErrAccountTokenInvalid:
+		problem.Fail(ctx, 400, "INVALID_TOKEN", err.Error(), "about:blank")
+	case err == apperrors.ErrEmailNotSet:
+		problem.Fail(ctx, 400, "EMAIL_NOT_SET", err.Error(), "about:blank")
+	case err == apperrors.ErrEmailAlreadyVerified:
+		problem.Fail(ctx, 409, "EMAIL_ALREADY_VERIFIED", err.Error(), "about:blank")
+	case err == apperrors.ErrUserNotFound:
+		problem.Fail(ctx, 404, "USER_NOT_FOUND", err.Error(), "about:blank")
+	case isPasswordPolicyError(err):
+		problem.Fail(ctx, 400, "INVALID_PASSWORD", err.Error(), "about:blank")
+	default:
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+	}
+}
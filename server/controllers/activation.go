@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type ActivationController struct {
+	activationService *services.ActivationService
+	validatorManager  *validator.Manager
+}
+
+func NewActivationController(activationService *services.ActivationService, validatorManager *validator.Manager) *ActivationController {
+	return &ActivationController{activationService: activationService, validatorManager: validatorManager}
+}
+
+func (ctrl *ActivationController) ListPendingUsersHandler(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "页码格式错误", "about:blank")
+		return
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "每页条数格式错误", "about:blank")
+		return
+	}
+
+	users, err := ctrl.activationService.ListPending(ctx.Request.Context(), page, pageSize)
+	if err != nil {
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+		return
+	}
+	response.OK(ctx, users, response.WithMessage("获取待审核注册申请成功"))
+}
+
+func (ctrl *ActivationController) ApproveUserHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+		return
+	}
+
+	if err := ctrl.activationService.Approve(ctx.Request.Context(), uint(userID)); err != nil {
+		failActivation(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("已批准注册申请"))
+}
+
+func (ctrl *ActivationController) RejectUserHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+		return
+	}
+	// 拒绝原因可选，允许不带请求体
+	var req dto.RejectRegistrationRequest
+	if ctx.Request.ContentLength > 0 && ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.activationService.Reject(ctx.Request.Context(), uint(userID), req.Reason); err != nil {
+		failActivation(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("已拒绝注册申请"))
+}
+
+func failActivation(ctx *gin.Context, err error) {
+	switch err {
+	case apperrors.ErrUserNotFound:
+		problem.Fail(ctx, 404, "USER_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrRegistrationNotPending:
+		problem.Fail(ctx, 409, "REGISTRATION_NOT_PENDING", err.Error(), "about:blank")
+	default:
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+	}
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/gin-gonic/gin"
+
+	"goauth/dto"
+	"goauth/models"
+	"goauth/services"
+)
+
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+func NewAuditController(auditService *services.AuditService) *AuditController {
+	return &AuditController{auditService: auditService}
+}
+
+// ListAuditEventsHandler 分页查询审计事件，可按用户、客户端、事件类型、结果与时间范围（RFC3339，左闭右开）过滤
+func (ctrl *AuditController) ListAuditEventsHandler(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "页码格式错误", "about:blank")
+		return
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "每页条数格式错误", "about:blank")
+		return
+	}
+
+	query := dto.AuditEventQuery{
+		ClientID: ctx.Query("client_id"),
+		Type:     ctx.Query("type"),
+		Outcome:  ctx.Query("outcome"),
+	}
+	if userID := ctx.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 64)
+		if err != nil || id == 0 {
+			problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+			return
+		}
+		query.UserID = uint(id)
+	}
+	if query.Outcome != "" && query.Outcome != models.AuditOutcomeSuccess && query.Outcome != models.AuditOutcomeFailure {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "结果只能是 success 或 failure", "about:blank")
+		return
+	}
+	if query.From, err = parseTimeQuery(ctx, "from"); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "起始时间格式错误", "about:blank")
+		return
+	}
+	if query.To, err = parseTimeQuery(ctx, "to"); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "结束时间格式错误", "about:blank")
+		return
+	}
+
+	events, err := ctrl.auditService.ListEvents(ctx.Request.Context(), page, pageSize, &query)
+	if err != nil {
+		problem.Fail(ctx, 500, "AUDIT_QUERY_FAILED", err.Error(), "about:blank")
+		return
+	}
+	response.OK(ctx, events, response.WithMessage("获取审计事件成功"))
+}
+
+// parseTimeQuery 解析 RFC3339 格式的查询参数，未传时返回零值
+func parseTimeQuery(ctx *gin.Context, key string) (time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
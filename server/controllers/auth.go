@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/3086953492/gokit/ginx/cookie"
@@ -9,18 +12,20 @@ import (
 	"github.com/3086953492/gokit/validator"
 	"github.com/gin-gonic/gin"
 
+	"goauth/apperrors"
 	"goauth/dto"
 	"goauth/services"
 )
 
 type AuthController struct {
 	authService      *services.AuthService
+	sessionService   *services.SessionService
 	validatorManager *validator.Manager
 	cookieMgr        *cookie.TokenCookies
 }
 
-func NewAuthController(authService *services.AuthService, validatorManager *validator.Manager, cookieMgr *cookie.TokenCookies) *AuthController {
-	return &AuthController{authService: authService, validatorManager: validatorManager, cookieMgr: cookieMgr}
+func NewAuthController(authService *services.AuthService, sessionService *services.SessionService, validatorManager *validator.Manager, cookieMgr *cookie.TokenCookies) *AuthController {
+	return &AuthController{authService: authService, sessionService: sessionService, validatorManager: validatorManager, cookieMgr: cookieMgr}
 }
 
 func (ctrl *AuthController) LoginHandler(ctx *gin.Context) {
@@ -34,25 +39,200 @@ func (ctrl *AuthController) LoginHandler(ctx *gin.Context) {
 		return
 	}
 
-	accessToken, accessTokenExpire, refreshToken, refreshTokenExpire, userResp, err := ctrl.authService.Login(ctx.Request.Context(), &req)
+	result, err := ctrl.authService.Login(ctx.Request.Context(), &req, ctx.ClientIP(), ctx.Request.UserAgent())
 	if err != nil {
-		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+		var throttled *apperrors.LoginThrottledError
+		if errors.As(err, &throttled) {
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			problem.Fail(ctx, 429, "TOO_MANY_REQUESTS", err.Error(), "about:blank")
+			return
+		}
+		failLogin(ctx, err)
 		return
 	}
 
-	ctrl.cookieMgr.SetAccess(ctx, accessToken)
-	ctrl.cookieMgr.SetRefresh(ctx, refreshToken)
+	if result.MFA != nil {
+		response.OK(ctx, dto.LoginResponse{MFA: result.MFA}, response.WithMessage("请完成两步验证"))
+		return
+	}
+	ctrl.completeLogin(ctx, result)
+}
+
+func (ctrl *AuthController) VerifyMFAHandler(ctx *gin.Context) {
+	var req dto.LoginMFARequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	result, err := ctrl.authService.VerifyMFA(ctx.Request.Context(), &req, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	ctrl.completeLogin(ctx, result)
+}
+
+func (ctrl *AuthController) SetupMFAEnrollmentHandler(ctx *gin.Context) {
+	var req dto.LoginMFAEnrollRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	setup, err := ctrl.authService.SetupMFAEnrollment(ctx.Request.Context(), req.MFAToken)
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, setup, response.WithMessage("请使用验证器扫描二维码"))
+}
+
+func (ctrl *AuthController) ConfirmMFAEnrollmentHandler(ctx *gin.Context) {
+	var req dto.LoginMFAEnrollConfirmRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	result, err := ctrl.authService.ConfirmMFAEnrollment(ctx.Request.Context(), &req, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	ctrl.completeLogin(ctx, result)
+}
+
+func (ctrl *AuthController) BeginMFAWebAuthnHandler(ctx *gin.Context) {
+	var req dto.LoginMFAEnrollRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	options, err := ctrl.authService.BeginMFAWebAuthn(ctx.Request.Context(), req.MFAToken)
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, options, response.WithMessage("请使用通行密钥完成验证"))
+}
+
+func (ctrl *AuthController) VerifyMFAWebAuthnHandler(ctx *gin.Context) {
+	var req dto.LoginMFAWebAuthnRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	result, err := ctrl.authService.VerifyMFAWebAuthn(ctx.Request.Context(), &req, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	ctrl.completeLogin(ctx, result)
+}
+
+func (ctrl *AuthController) BeginPasskeyLoginHandler(ctx *gin.Context) {
+	options, err := ctrl.authService.BeginPasskeyLogin(ctx.Request.Context())
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, options, response.WithMessage("请使用通行密钥登录"))
+}
+
+func (ctrl *AuthController) FinishPasskeyLoginHandler(ctx *gin.Context) {
+	var req dto.WebAuthnLoginRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	result, err := ctrl.authService.FinishPasskeyLogin(ctx.Request.Context(), &req, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+
+	if result.MFA != nil {
+		response.OK(ctx, dto.LoginResponse{MFA: result.MFA}, response.WithMessage("请完成两步验证"))
+		return
+	}
+	ctrl.completeLogin(ctx, result)
+}
+
+func (ctrl *AuthController) FederatedLoginHandler(ctx *gin.Context) {
+	var req dto.FederatedLoginRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	result, err := ctrl.authService.FederatedLogin(ctx.Request.Context(), req.Ticket, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		failFederation(ctx, err)
+		return
+	}
+
+	if result.MFA != nil {
+		response.OK(ctx, dto.LoginResponse{MFA: result.MFA}, response.WithMessage("请完成两步验证"))
+		return
+	}
+	ctrl.completeLogin(ctx, result)
+}
+
+// completeLogin 下发令牌 Cookie 并返回登录结果
+func (ctrl *AuthController) completeLogin(ctx *gin.Context, result *services.LoginResult) {
+	ctrl.cookieMgr.SetAccess(ctx, result.AccessToken)
+	ctrl.cookieMgr.SetRefresh(ctx, result.RefreshToken)
 
 	response.OK(ctx, dto.LoginResponse{
-		User:                 userResp,
-		AccessTokenExpireAt:  time.Now().Add(time.Duration(accessTokenExpire) * time.Second),
-		RefreshTokenExpireAt: time.Now().Add(time.Duration(refreshTokenExpire) * time.Second),
+		User:                 result.User,
+		AccessTokenExpireAt:  time.Now().Add(time.Duration(result.AccessTokenExpire) * time.Second),
+		RefreshTokenExpireAt: time.Now().Add(time.Duration(result.RefreshTokenExpire) * time.Second),
+		RecoveryCodes:        result.RecoveryCodes,
+		PasswordExpired:      result.PasswordExpired,
 	}, response.WithMessage("登录成功"))
 }
 
 func (ctrl *AuthController) LogoutHandler(ctx *gin.Context) {
+	frontchannelLogoutURIs, err := ctrl.authService.Logout(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), ctx.GetString("session_id"))
+	if err != nil {
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+		return
+	}
+
 	ctrl.cookieMgr.Clear(ctx)
-	response.OK(ctx, nil, response.WithMessage("退出登录成功"))
+	response.OK(ctx, dto.LogoutResponse{FrontchannelLogoutURIs: frontchannelLogoutURIs}, response.WithMessage("退出登录成功"))
 }
 
 func (ctrl *AuthController) RefreshTokenHandler(ctx *gin.Context) {
@@ -61,16 +241,40 @@ func (ctrl *AuthController) RefreshTokenHandler(ctx *gin.Context) {
 		problem.Fail(ctx, 401, "UNAUTHORIZED", "刷新令牌不存在", "about:blank")
 		return
 	}
-	accessToken, accessTokenExpire, err := ctrl.authService.RefreshToken(ctx.Request.Context(), token)
+	accessToken, accessTokenExpire, refreshToken, refreshTokenExpire, err := ctrl.authService.RefreshToken(ctx.Request.Context(), token)
 	if err != nil {
 		// 刷新令牌失败通常是令牌无效或过期，应返回 401 而非 500
 		problem.Fail(ctx, 401, "UNAUTHORIZED", err.Error(), "about:blank")
 		return
 	}
 
+	// 刷新令牌每次使用后轮换，须同时下发新的刷新令牌
 	ctrl.cookieMgr.SetAccess(ctx, accessToken)
+	ctrl.cookieMgr.SetRefresh(ctx, refreshToken)
 
 	response.OK(ctx, dto.RefreshTokenResponse{
-		AccessTokenExpireAt: time.Now().Add(time.Duration(accessTokenExpire) * time.Second),
+		AccessTokenExpireAt:  time.Now().Add(time.Duration(accessTokenExpire) * time.Second),
+		RefreshTokenExpireAt: time.Now().Add(time.Duration(refreshTokenExpire) * time.Second),
 	}, response.WithMessage("刷新令牌成功"))
 }
+
+func failLogin(ctx *gin.Context, err error) {
+	var status int
+	var title string
+	switch err {
+	case apperrors.ErrInvalidCredentials:
+		status = 401
+		title = "UNAUTHORIZED"
+	case apperrors.ErrDirectoryAccountConflict, apperrors.ErrDirectorySignupDisabled:
+		status = 403
+		title = "FORBIDDEN"
+	case apperrors.ErrAuthenticatorUnavailable:
+		status = 503
+		title = "SERVICE_UNAVAILABLE"
+	default:
+		// 账号未激活或已禁用等错误与两步验证阶段使用同一映射
+		failMFA(ctx, err)
+		return
+	}
+	problem.Fail(ctx, status, title, err.Error(), "about:blank")
+}
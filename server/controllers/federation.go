@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/3086953492/gokit/config"
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+const (
+	// federatedStateCookie 发起外部身份授权时写入浏览器的 state，回调时校验，防止登录 CSRF
+	federatedStateCookie = "goauth_federated_state"
+	// federatedStateCookiePath 只在回调时携带
+	federatedStateCookiePath = "/api/v1/auth/federated"
+)
+
+type FederationController struct {
+	federationService *services.FederationService
+	validatorManager  *validator.Manager
+	cfg               *config.Config
+}
+
+func NewFederationController(federationService *services.FederationService, validatorManager *validator.Manager, cfg *config.Config) *FederationController {
+	return &FederationController{federationService: federationService, validatorManager: validatorManager, cfg: cfg}
+}
+
+func (ctrl *FederationController) ListProvidersHandler(ctx *gin.Context) {
+	response.OK(ctx, ctrl.federationService.Providers(), response.WithMessage("获取身份提供方列表成功"))
+}
+
+func (ctrl *FederationController) AuthorizeHandler(ctx *gin.Context) {
+	var req dto.FederatedAuthorizeRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	authURL, state, err := ctrl.federationService.BeginLogin(ctx.Request.Context(), ctx.Param("provider"), req.Redirect)
+	if err != nil {
+		failFederation(ctx, err)
+		return
+	}
+	ctrl.setStateCookie(ctx, state, int(ctrl.federationService.StateTTL().Seconds()))
+	response.OK(ctx, dto.FederatedAuthorizeResponse{AuthorizationURL: authURL}, response.WithMessage("请前往身份提供方完成登录"))
+}
+
+// CallbackHandler 身份提供方回调，无论成功与否都跳转回前端，结果通过查询参数传递
+func (ctrl *FederationController) CallbackHandler(ctx *gin.Context) {
+	stateCookie, _ := ctx.Cookie(federatedStateCookie)
+	ctrl.setStateCookie(ctx, "", -1)
+
+	redirectURL := ctrl.federationService.Callback(ctx.Request.Context(), ctx.Param("provider"), ctx.Query("state"), stateCookie, ctx.Query("code"), ctx.Query("error"))
+	ctx.Redirect(http.StatusFound, redirectURL)
+}
+
+func (ctrl *FederationController) ListIdentitiesHandler(ctx *gin.Context) {
+	identities, err := ctrl.federationService.ListIdentities(ctx.Request.Context(), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		failFederation(ctx, err)
+		return
+	}
+	response.OK(ctx, identities, response.WithMessage("获取外部账号列表成功"))
+}
+
+func (ctrl *FederationController) LinkHandler(ctx *gin.Context) {
+	authURL, state, err := ctrl.federationService.BeginLink(ctx.Request.Context(), ctx.Param("provider"), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		failFederation(ctx, err)
+		return
+	}
+	ctrl.setStateCookie(ctx, state, int(ctrl.federationService.StateTTL().Seconds()))
+	response.OK(ctx, dto.FederatedAuthorizeResponse{AuthorizationURL: authURL}, response.WithMessage("请前往身份提供方完成授权"))
+}
+
+func (ctrl *FederationController) UnlinkHandler(ctx *gin.Context) {
+	identityID, err := strconv.ParseUint(ctx.Param("identity_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "外部账号关联ID格式错误", "about:blank")
+		return
+	}
+
+	if err := ctrl.federationService.Unlink(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), uint(identityID)); err != nil {
+		failFederation(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("已解除关联"))
+}
+
+// setStateCookie 写入或清除 state Cookie
+// 提供方回调是跨站的顶级跳转，SameSite=Lax 的 Cookie 会随之发送
+func (ctrl *FederationController) setStateCookie(ctx *gin.Context, state string, maxAge int) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(federatedStateCookie, state, maxAge, federatedStateCookiePath, ctrl.cfg.Server.Domain, ctrl.cfg.Server.Mode != "debug", true)
+}
+
+func failFederation(ctx *gin.Context, err error) {
+	var status int
+	var title string
+	switch err {
+	case apperrors.ErrFederatedProviderNotFound, apperrors.ErrFederatedIdentityNotFound:
+		status = 404
+		title = "NOT_FOUND"
+	case apperrors.ErrFederatedTicketInvalid:
+		status = 401
+		title = "UNAUTHORIZED"
+	case apperrors.ErrFederatedProviderLinked, apperrors.ErrFederatedLastLoginMethod:
+		status = 409
+		title = "CONFLICT"
+	case apperrors.ErrFederatedUpstreamFailed:
+		status = 502
+		title = "BAD_GATEWAY"
+	default:
+		// 账号未激活或已禁用等错误与两步验证阶段使用同一映射
+		failMFA(ctx, err)
+		return
+	}
+	problem.Fail(ctx, status, title, err.Error(), "about:blank")
+}
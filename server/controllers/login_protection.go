@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/services"
+)
+
+type LoginProtectionController struct {
+	loginProtectionService *services.LoginProtectionService
+}
+
+func NewLoginProtectionController(loginProtectionService *services.LoginProtectionService) *LoginProtectionController {
+	return &LoginProtectionController{loginProtectionService: loginProtectionService}
+}
+
+func (ctrl *LoginProtectionController) GetUserLockoutHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+		return
+	}
+
+	lockout, err := ctrl.loginProtectionService.GetLockout(ctx.Request.Context(), uint(userID))
+	if err != nil {
+		failLockout(ctx, err)
+		return
+	}
+	response.OK(ctx, lockout, response.WithMessage("获取登录锁定状态成功"))
+}
+
+func (ctrl *LoginProtectionController) UnlockUserHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+		return
+	}
+
+	if err := ctrl.loginProtectionService.Unlock(ctx.Request.Context(), uint(userID), uint(ctx.GetUint64("user_id"))); err != nil {
+		failLockout(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("解除登录锁定成功"))
+}
+
+func failLockout(ctx *gin.Context, err error) {
+	if err == apperrors.ErrUserNotFound {
+		problem.Fail(ctx, 404, "USER_NOT_FOUND", err.Error(), "about:blank")
+		return
+	}
+	problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+}
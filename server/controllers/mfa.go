@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type MFAController struct {
+	mfaService       *services.MFAService
+	validatorManager *validator.Manager
+}
+
+func NewMFAController(mfaService *services.MFAService, validatorManager *validator.Manager) *MFAController {
+	return &MFAController{mfaService: mfaService, validatorManager: validatorManager}
+}
+
+func (ctrl *MFAController) GetMFAStatusHandler(ctx *gin.Context) {
+	status, err := ctrl.mfaService.GetStatus(ctx.Request.Context(), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, status, response.WithMessage("获取两步验证状态成功"))
+}
+
+func (ctrl *MFAController) SetupTOTPHandler(ctx *gin.Context) {
+	setup, err := ctrl.mfaService.SetupTOTP(ctx.Request.Context(), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, setup, response.WithMessage("请使用验证器扫描二维码"))
+}
+
+func (ctrl *MFAController) ConfirmTOTPHandler(ctx *gin.Context) {
+	var req dto.TOTPConfirmRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	codes, err := ctrl.mfaService.ConfirmTOTP(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), req.Code)
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, dto.RecoveryCodesResponse{RecoveryCodes: codes}, response.WithMessage("启用两步验证成功"))
+}
+
+func (ctrl *MFAController) DisableTOTPHandler(ctx *gin.Context) {
+	var req dto.TOTPDisableRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.mfaService.DisableTOTP(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), req.Password); err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("关闭两步验证成功"))
+}
+
+func (ctrl *MFAController) RegenerateRecoveryCodesHandler(ctx *gin.Context) {
+	var req dto.TOTPConfirmRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	codes, err := ctrl.mfaService.RegenerateRecoveryCodes(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), req.Code)
+	if err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, dto.RecoveryCodesResponse{RecoveryCodes: codes}, response.WithMessage("生成恢复码成功"))
+}
+
+func (ctrl *MFAController) ResetUserMFAHandler(ctx *gin.Context) {
+	userID, err := strconv.ParseUint(ctx.Param("user_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "用户ID格式错误", "about:blank")
+		return
+	}
+
+	if err := ctrl.mfaService.ResetMFA(ctx.Request.Context(), uint(userID)); err != nil {
+		failMFA(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("重置两步验证成功"))
+}
+
+// failMFA 按两步验证错误类型返回对应状态码
+func failMFA(ctx *gin.Context, err error) {
+	var status int
+	var title string
+	switch err {
+	case apperrors.ErrMFACodeInvalid, apperrors.ErrMFAChallengeInvalid, apperrors.ErrMFAChallengeExhausted:
+		status = 401
+		title = "UNAUTHORIZED"
+	case apperrors.ErrMFARequiredForAdmin:
+		status = 403
+		title = "FORBIDDEN"
+	case apperrors.ErrUserDisabled, apperrors.ErrUserPendingActivation, apperrors.ErrUserPendingApproval, apperrors.ErrUserRejected:
+		status = 403
+		title = "ACCOUNT_INACTIVE"
+	case apperrors.ErrMFANotEnabled, apperrors.ErrMFAAlreadyEnabled, apperrors.ErrTOTPNotSetup:
+		status = 409
+		title = "CONFLICT"
+	case apperrors.ErrUserNotFound:
+		status = 404
+		title = "USER_NOT_FOUND"
+	default:
+		status = 500
+		title = "INTERNAL_SERVER_ERROR"
+	}
+	problem.Fail(ctx, status, title, err.Error(), "about:blank")
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/3086953492/gokit/ginx/redirect"
 	"github.com/gin-gonic/gin"
 
+	"goauth/apperrors"
 	"goauth/services/oauth"
 	"goauth/utils"
 )
@@ -42,6 +43,11 @@ func (ctrl *OAuthAuthorizeController) AuthorizationCodeHandler(ctx *gin.Context)
 		return
 	}
 
+	if oauthClient.Status == 0 {
+		redirect.Redirect(ctx, frontendErrorPageURL, redirect.WithQuery(map[string]string{"error": "unauthorized_client", "error_description": apperrors.ErrOAuthClientDisabled.Error()}))
+		return
+	}
+
 	redirectURI := ctx.Query("redirect_uri")
 	if redirectURI == "" || !utils.IsRedirectURIValid(redirectURI, oauthClient.RedirectURIs) {
 		redirect.Redirect(ctx, frontendErrorPageURL, redirect.WithQuery(map[string]string{"error": "invalid_request", "error_description": "redirect_uri为空或不在客户端的回调地址列表中"}))
@@ -58,7 +64,7 @@ func (ctrl *OAuthAuthorizeController) AuthorizationCodeHandler(ctx *gin.Context)
 
 	userID := uint(ctx.GetUint64("user_id"))
 
-	authorizationCode, err := ctrl.oauthAuthorizeService.GenerateAuthorizationCode(ctx.Request.Context(), userID, clientID, redirectURI, scope)
+	authorizationCode, err := ctrl.oauthAuthorizeService.GenerateAuthorizationCode(ctx.Request.Context(), userID, ctx.GetString("session_id"), clientID, redirectURI, scope, ctx.Query("nonce"))
 	if err != nil {
 		redirect.Redirect(ctx, redirectURI, redirect.WithQuery(map[string]string{"error": "invalid_request", "error_description": err.Error()}))
 		return
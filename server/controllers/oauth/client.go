@@ -1,6 +1,7 @@
 package oauthcontrollers
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/3086953492/gokit/ginx/problem"
@@ -8,6 +9,7 @@ import (
 	"github.com/3086953492/gokit/validator"
 	"github.com/gin-gonic/gin"
 
+	"goauth/apperrors"
 	"goauth/dto/oauth"
 	"goauth/services/oauth"
 )
@@ -33,6 +35,10 @@ func (ctrl *OAuthClientController) CreateOAuthClientHandler(ctx *gin.Context) {
 	}
 
 	if err := ctrl.oauthClientService.CreateOAuthClient(ctx.Request.Context(), &req); err != nil {
+		if errors.Is(err, apperrors.ErrLogoutURIInvalid) {
+			problem.Fail(ctx, 400, "INVALID_REQUEST", err.Error(), "about:blank")
+			return
+		}
 		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
 		return
 	}
@@ -101,6 +107,10 @@ func (ctrl *OAuthClientController) UpdateOAuthClientHandler(ctx *gin.Context) {
 	}
 
 	if err := ctrl.oauthClientService.UpdateOAuthClient(ctx.Request.Context(), uint(idUint), &req); err != nil {
+		if errors.Is(err, apperrors.ErrLogoutURIInvalid) {
+			problem.Fail(ctx, 400, "INVALID_REQUEST", err.Error(), "about:blank")
+			return
+		}
 		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
 		return
 	}
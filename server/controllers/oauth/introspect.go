@@ -5,6 +5,7 @@ import (
 	"github.com/3086953492/gokit/ginx/response"
 	"github.com/gin-gonic/gin"
 
+	"goauth/apperrors"
 	"goauth/dto/oauth"
 	"goauth/services/oauth"
 )
@@ -34,11 +35,15 @@ func (ctrl *OAuthIntrospectController) IntrospectAccessTokenHandler(ctx *gin.Con
 	}
 
 	// 验证客户端合法性
-	_, err := ctrl.oauthClientService.GetOAuthClient(ctx.Request.Context(), map[string]any{"id": clientID, "client_secret": clientSecret})
+	oauthClient, err := ctrl.oauthClientService.GetOAuthClient(ctx.Request.Context(), map[string]any{"id": clientID, "client_secret": clientSecret})
 	if err != nil {
 		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
 		return
 	}
+	if oauthClient.Status == 0 {
+		problem.Fail(ctx, 401, "UNAUTHORIZED_CLIENT", apperrors.ErrOAuthClientDisabled.Error(), "about:blank")
+		return
+	}
 
 	// 调用服务层内省访问令牌
 	resp := ctrl.oauthIntrospectService.IntrospectAccessToken(ctx.Request.Context(), form.Token)
@@ -0,0 +1,94 @@
+package oauthcontrollers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/3086953492/gokit/config"
+	"github.com/3086953492/gokit/ginx/cookie"
+	"github.com/3086953492/gokit/ginx/redirect"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto/oauth"
+	"goauth/services/oauth"
+)
+
+// frontchannelLogoutPage 在隐藏的 iframe 中加载各客户端的前端通道登出地址，全部加载完成（最多等待 5 秒）后跳转
+var frontchannelLogoutPage = template.Must(template.New("logout").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>正在退出登录</title></head>
+<body>
+{{range .FrontchannelLogoutURIs}}<iframe src="{{.}}" style="display:none" width="0" height="0"></iframe>
+{{end}}<p>正在退出登录，<a href="{{.RedirectURL}}">如未自动跳转请点击这里</a></p>
+<script>
+var done = false;
+function next() {
+  if (done) return;
+  done = true;
+  window.location.replace({{.RedirectURL}});
+}
+window.addEventListener("load", next);
+setTimeout(next, 5000);
+</script>
+</body>
+</html>
+`))
+
+type OAuthLogoutController struct {
+	oidcLogoutService *oauthservices.OIDCLogoutService
+	cookieMgr         *cookie.TokenCookies
+	cfg               *config.Config
+}
+
+func NewOAuthLogoutController(oidcLogoutService *oauthservices.OIDCLogoutService, cookieMgr *cookie.TokenCookies, cfg *config.Config) *OAuthLogoutController {
+	return &OAuthLogoutController{oidcLogoutService: oidcLogoutService, cookieMgr: cookieMgr, cfg: cfg}
+}
+
+// EndSessionHandler 依赖方发起的登出（end_session_endpoint），注销当前登录会话后跳转到 post_logout_redirect_uri
+// 未指定跳转地址时回到前端登录页；有前端通道登出地址时先渲染加载这些地址的页面再跳转
+func (ctrl *OAuthLogoutController) EndSessionHandler(ctx *gin.Context) {
+	var form oauthdto.EndSessionForm
+	if ctx.ShouldBind(&form) != nil {
+		redirect.Redirect(ctx, ctrl.cfg.Server.FrontendURL+"/error", redirect.WithQuery(map[string]string{"error": "invalid_request", "error_description": "请求参数错误"}))
+		return
+	}
+
+	result, err := ctrl.oidcLogoutService.EndSession(ctx.Request.Context(), &form, uint(ctx.GetUint64("user_id")), ctx.GetString("session_id"))
+	if err != nil {
+		ctrl.redirectError(ctx, err)
+		return
+	}
+	if result.LoggedOut {
+		ctrl.cookieMgr.Clear(ctx)
+	}
+
+	redirectURL := result.RedirectURL
+	if redirectURL == "" {
+		redirectURL = ctrl.cfg.Server.FrontendURL + "/login"
+	}
+	if len(result.FrontchannelLogoutURIs) == 0 {
+		ctx.Redirect(http.StatusFound, redirectURL)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	if err := frontchannelLogoutPage.Execute(ctx.Writer, map[string]any{"FrontchannelLogoutURIs": result.FrontchannelLogoutURIs, "RedirectURL": redirectURL}); err != nil {
+		ctx.Error(err)
+	}
+}
+
+// redirectError 登出请求无效时不跳转到依赖方提供的地址，错误统一展示在前端错误页
+func (ctrl *OAuthLogoutController) redirectError(ctx *gin.Context, err error) {
+	code := "server_error"
+	switch err {
+	case apperrors.ErrLogoutIDTokenHintInvalid, apperrors.ErrLogoutClientMismatch, apperrors.ErrLogoutClientRequired,
+		apperrors.ErrPostLogoutRedirectURIInvalid, apperrors.ErrLogoutUserMismatch:
+		code = "invalid_request"
+	case apperrors.ErrLogoutClientNotFound, apperrors.ErrOAuthClientDisabled:
+		code = "unauthorized_client"
+	}
+	redirect.Redirect(ctx, ctrl.cfg.Server.FrontendURL+"/error", redirect.WithQuery(map[string]string{"error": code, "error_description": err.Error()}))
+}
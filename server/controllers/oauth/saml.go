@@ -0,0 +1,131 @@
+package oauthcontrollers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/3086953492/gokit/config"
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/redirect"
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/services/oauth"
+)
+
+// samlPostForm 将 SAML 响应以 HTTP-POST 绑定自动提交到服务提供方
+var samlPostForm = template.Must(template.New("saml").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>正在登录</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.URL}}">
+<input type="hidden" name="SAMLResponse" value="{{.SAMLResponse}}">
+<input type="hidden" name="RelayState" value="{{.RelayState}}">
+<noscript><button type="submit">继续</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+type SAMLController struct {
+	samlIdentityProviderService *oauthservices.SAMLIdentityProviderService
+	cfg                         *config.Config
+}
+
+func NewSAMLController(samlIdentityProviderService *oauthservices.SAMLIdentityProviderService, cfg *config.Config) *SAMLController {
+	return &SAMLController{samlIdentityProviderService: samlIdentityProviderService, cfg: cfg}
+}
+
+func (ctrl *SAMLController) MetadataHandler(ctx *gin.Context) {
+	metadata, err := ctrl.samlIdentityProviderService.Metadata(ctx.Request.Context())
+	if err != nil {
+		failSAML(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SSOHandler 接收服务提供方发起的认证请求，暂存后跳转到前端确认登录状态
+func (ctrl *SAMLController) SSOHandler(ctx *gin.Context) {
+	redirectURL, err := ctrl.samlIdentityProviderService.BeginSSO(ctx.Request.Context(), ctx.Request)
+	if err != nil {
+		ctrl.redirectError(ctx, err)
+		return
+	}
+	ctx.Redirect(http.StatusFound, redirectURL)
+}
+
+// ContinueSSOHandler 已登录用户凭暂存的认证请求换取断言
+func (ctrl *SAMLController) ContinueSSOHandler(ctx *gin.Context) {
+	form, err := ctrl.samlIdentityProviderService.ContinueSSO(ctx.Request.Context(), ctx.Request, ctx.Query("request"), uint(ctx.GetUint64("user_id")), ctx.GetString("session_id"))
+	if err != nil {
+		ctrl.redirectError(ctx, err)
+		return
+	}
+	ctrl.postForm(ctx, form)
+}
+
+// IDPInitiatedHandler 已登录用户从本系统直接登录到服务提供方
+func (ctrl *SAMLController) IDPInitiatedHandler(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctrl.redirectError(ctx, apperrors.ErrSAMLServiceProviderNotFound)
+		return
+	}
+
+	form, err := ctrl.samlIdentityProviderService.IDPInitiated(ctx.Request.Context(), ctx.Request, uint(id), ctx.Query("relay_state"), uint(ctx.GetUint64("user_id")), ctx.GetString("session_id"))
+	if err != nil {
+		ctrl.redirectError(ctx, err)
+		return
+	}
+	ctrl.postForm(ctx, form)
+}
+
+// postForm 响应包含断言，不能被缓存
+func (ctrl *SAMLController) postForm(ctx *gin.Context, form *saml.IdpAuthnRequestForm) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.Status(http.StatusOK)
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	if err := samlPostForm.Execute(ctx.Writer, form); err != nil {
+		ctx.Error(err)
+	}
+}
+
+// redirectError 浏览器跳转场景的错误统一展示在前端错误页
+func (ctrl *SAMLController) redirectError(ctx *gin.Context, err error) {
+	code := "server_error"
+	switch err {
+	case apperrors.ErrSAMLRequestInvalid, apperrors.ErrSAMLRequestExpired:
+		code = "invalid_request"
+	case apperrors.ErrSAMLServiceProviderNotFound, apperrors.ErrSAMLServiceProviderDisabled, apperrors.ErrSAMLIDPInitiatedDisabled:
+		code = "unauthorized_client"
+	case apperrors.ErrUserDisabled, apperrors.ErrUserPendingActivation, apperrors.ErrUserPendingApproval, apperrors.ErrUserRejected:
+		code = "access_denied"
+	case apperrors.ErrSAMLDisabled:
+		code = "temporarily_unavailable"
+	}
+	redirect.Redirect(ctx, ctrl.cfg.Server.FrontendURL+"/error", redirect.WithQuery(map[string]string{"error": code, "error_description": err.Error()}))
+}
+
+func failSAML(ctx *gin.Context, err error) {
+	var status int
+	var title string
+	switch err {
+	case apperrors.ErrSAMLDisabled, apperrors.ErrSAMLServiceProviderNotFound:
+		status = 404
+		title = "NOT_FOUND"
+	case apperrors.ErrSAMLServiceProviderExists:
+		status = 409
+		title = "CONFLICT"
+	case apperrors.ErrSAMLMetadataInvalid:
+		status = 400
+		title = "INVALID_REQUEST"
+	default:
+		status = 500
+		title = "INTERNAL_SERVER_ERROR"
+	}
+	problem.Fail(ctx, status, title, err.Error(), "about:blank")
+}
@@ -0,0 +1,119 @@
+package oauthcontrollers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/dto/oauth"
+	"goauth/services/oauth"
+)
+
+type SAMLServiceProviderController struct {
+	samlServiceProviderService *oauthservices.SAMLServiceProviderService
+	validatorManager           *validator.Manager
+}
+
+func NewSAMLServiceProviderController(samlServiceProviderService *oauthservices.SAMLServiceProviderService, validatorManager *validator.Manager) *SAMLServiceProviderController {
+	return &SAMLServiceProviderController{samlServiceProviderService: samlServiceProviderService, validatorManager: validatorManager}
+}
+
+func (ctrl *SAMLServiceProviderController) CreateSAMLServiceProviderHandler(ctx *gin.Context) {
+	var req oauthdto.CreateSAMLServiceProviderRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.samlServiceProviderService.CreateSAMLServiceProvider(ctx.Request.Context(), &req); err != nil {
+		failSAML(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("创建SAML服务提供方成功"))
+}
+
+func (ctrl *SAMLServiceProviderController) ListSAMLServiceProvidersHandler(ctx *gin.Context) {
+	page, pageSize := ctx.Query("page"), ctx.Query("page_size")
+	pageInt, err := strconv.Atoi(page)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "页码格式错误", "about:blank")
+		return
+	}
+	pageSizeInt, err := strconv.Atoi(pageSize)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "每页条数格式错误", "about:blank")
+		return
+	}
+	conds := map[string]any{}
+	if name := ctx.Query("name"); name != "" {
+		conds["name LIKE ?"] = "%" + name + "%"
+	}
+	if status := ctx.Query("status"); status != "" {
+		conds["status"] = status
+	}
+
+	pagination, err := ctrl.samlServiceProviderService.ListSAMLServiceProviders(ctx.Request.Context(), pageInt, pageSizeInt, conds)
+	if err != nil {
+		failSAML(ctx, err)
+		return
+	}
+	response.OK(ctx, pagination, response.WithMessage("获取SAML服务提供方列表成功"))
+}
+
+func (ctrl *SAMLServiceProviderController) GetSAMLServiceProviderHandler(ctx *gin.Context) {
+	idUint, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "ID格式错误", "about:blank")
+		return
+	}
+	sp, err := ctrl.samlServiceProviderService.GetSAMLServiceProvider(ctx.Request.Context(), uint(idUint))
+	if err != nil {
+		failSAML(ctx, err)
+		return
+	}
+	response.OK(ctx, sp, response.WithMessage("获取SAML服务提供方详情成功"))
+}
+
+func (ctrl *SAMLServiceProviderController) UpdateSAMLServiceProviderHandler(ctx *gin.Context) {
+	idUint, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "ID格式错误", "about:blank")
+		return
+	}
+
+	var req oauthdto.UpdateSAMLServiceProviderRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.samlServiceProviderService.UpdateSAMLServiceProvider(ctx.Request.Context(), uint(idUint), &req); err != nil {
+		failSAML(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("更新SAML服务提供方成功"))
+}
+
+func (ctrl *SAMLServiceProviderController) DeleteSAMLServiceProviderHandler(ctx *gin.Context) {
+	idUint, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "ID格式错误", "about:blank")
+		return
+	}
+	if err := ctrl.samlServiceProviderService.DeleteSAMLServiceProvider(ctx.Request.Context(), uint(idUint)); err != nil {
+		failSAML(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("删除SAML服务提供方成功"))
+}
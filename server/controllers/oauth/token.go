@@ -1,10 +1,13 @@
 package oauthcontrollers
 
 import (
+	"errors"
+
 	"github.com/3086953492/gokit/ginx/problem"
 	"github.com/3086953492/gokit/ginx/response"
 	"github.com/gin-gonic/gin"
 
+	"goauth/apperrors"
 	"goauth/dto/oauth"
 	"goauth/services/oauth"
 )
@@ -39,7 +42,7 @@ func (ctrl *OAuthTokenController) ExchangeAccessTokenHandler(ctx *gin.Context) {
 
 		accessToken, err := ctrl.oauthTokenService.ExchangeAccessToken(ctx.Request.Context(), &form, clientID, clientSecret)
 		if err != nil {
-			problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+			failTokenRequest(ctx, err)
 			return
 		}
 
@@ -54,7 +57,7 @@ func (ctrl *OAuthTokenController) ExchangeAccessTokenHandler(ctx *gin.Context) {
 
 		accessToken, err := ctrl.oauthTokenService.RefreshAccessToken(ctx.Request.Context(), &form, clientID, clientSecret)
 		if err != nil {
-			problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+			failTokenRequest(ctx, err)
 			return
 		}
 
@@ -69,7 +72,7 @@ func (ctrl *OAuthTokenController) ExchangeAccessTokenHandler(ctx *gin.Context) {
 
 		accessToken, err := ctrl.oauthTokenService.IssueClientCredentialsAccessToken(ctx.Request.Context(), &form, clientID, clientSecret)
 		if err != nil {
-			problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+			failTokenRequest(ctx, err)
 			return
 		}
 
@@ -79,3 +82,12 @@ func (ctrl *OAuthTokenController) ExchangeAccessTokenHandler(ctx *gin.Context) {
 		problem.Fail(ctx, 400, "INVALID_REQUEST", "授权类型不支持", "about:blank")
 	}
 }
+
+// failTokenRequest 将令牌端点的服务层错误转换为问题响应
+func failTokenRequest(ctx *gin.Context, err error) {
+	if errors.Is(err, apperrors.ErrOAuthClientDisabled) {
+		problem.Fail(ctx, 401, "UNAUTHORIZED_CLIENT", err.Error(), "about:blank")
+		return
+	}
+	problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+}
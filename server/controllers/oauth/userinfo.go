@@ -42,7 +42,7 @@ func (ctrl *OAuthUserInfoController) GetUserInfoHandler(ctx *gin.Context) {
 	}
 
 	// 获取用户信息
-	userInfo := ctrl.oauthUserInfoService.GetUserInfo(ctx.Request.Context(), introspectResp.Username)
+	userInfo := ctrl.oauthUserInfoService.GetUserInfo(ctx.Request.Context(), introspectResp.Username, introspectResp.Scope)
 	if userInfo == nil {
 		problem.Fail(ctx, 404, "USER_NOT_FOUND", "用户不存在", "about:blank")
 		return
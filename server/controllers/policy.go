@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type PolicyController struct {
+	policyEngine     *services.PolicyEngine
+	validatorManager *validator.Manager
+}
+
+func NewPolicyController(policyEngine *services.PolicyEngine, validatorManager *validator.Manager) *PolicyController {
+	return &PolicyController{policyEngine: policyEngine, validatorManager: validatorManager}
+}
+
+func (ctrl *PolicyController) GetPolicyMatrixHandler(ctx *gin.Context) {
+	matrix, err := ctrl.policyEngine.Matrix(ctx.Request.Context())
+	if err != nil {
+		failPolicy(ctx, err)
+		return
+	}
+	response.OK(ctx, matrix, response.WithMessage("获取授权策略成功"))
+}
+
+func (ctrl *PolicyController) ExplainPolicyHandler(ctx *gin.Context) {
+	var req dto.PolicyExplainRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	decision, err := ctrl.policyEngine.Explain(ctx.Request.Context(), &req)
+	if err != nil {
+		failPolicy(ctx, err)
+		return
+	}
+	response.OK(ctx, decision, response.WithMessage("授权决策解释成功"))
+}
+
+func failPolicy(ctx *gin.Context, err error) {
+	switch err {
+	case apperrors.ErrPolicyNotFound:
+		problem.Fail(ctx, 404, "POLICY_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrPolicyRouteNotFound:
+		problem.Fail(ctx, 404, "POLICY_ROUTE_NOT_FOUND", err.Error(), "about:blank")
+	default:
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+	}
+}
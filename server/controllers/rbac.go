@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type RBACController struct {
+	rbacService      *services.RBACService
+	groupService     *services.GroupService
+	userService      *services.UserService
+	validatorManager *validator.Manager
+}
+
+func NewRBACController(rbacService *services.RBACService, groupService *services.GroupService, userService *services.UserService, validatorManager *validator.Manager) *RBACController {
+	return &RBACController{rbacService: rbacService, groupService: groupService, userService: userService, validatorManager: validatorManager}
+}
+
+func (ctrl *RBACController) ListPermissionsHandler(ctx *gin.Context) {
+	permissions, err := ctrl.rbacService.ListPermissions(ctx.Request.Context())
+	if err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, permissions, response.WithMessage("获取权限列表成功"))
+}
+
+func (ctrl *RBACController) ListRolesHandler(ctx *gin.Context) {
+	roles, err := ctrl.rbacService.ListRoles(ctx.Request.Context())
+	if err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, roles, response.WithMessage("获取角色列表成功"))
+}
+
+func (ctrl *RBACController) GetRoleHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "角色ID格式错误")
+	if !ok {
+		return
+	}
+
+	role, err := ctrl.rbacService.GetRole(ctx.Request.Context(), id)
+	if err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, role, response.WithMessage("获取角色成功"))
+}
+
+func (ctrl *RBACController) CreateRoleHandler(ctx *gin.Context) {
+	var req dto.CreateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.rbacService.CreateRole(ctx.Request.Context(), &req); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("角色创建成功"))
+}
+
+func (ctrl *RBACController) UpdateRoleHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "角色ID格式错误")
+	if !ok {
+		return
+	}
+	var req dto.UpdateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.rbacService.UpdateRole(ctx.Request.Context(), id, &req); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("角色更新成功"))
+}
+
+func (ctrl *RBACController) DeleteRoleHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "角色ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.rbacService.DeleteRole(ctx.Request.Context(), id); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("角色删除成功"))
+}
+
+func (ctrl *RBACController) GetUserRolesHandler(ctx *gin.Context) {
+	userID, ok := parseID(ctx, "user_id", "用户ID格式错误")
+	if !ok {
+		return
+	}
+	if _, err := ctrl.userService.GetUser(ctx.Request.Context(), map[string]any{"id": userID}); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+
+	roles, err := ctrl.rbacService.GetUserRoles(ctx.Request.Context(), userID)
+	if err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, roles, response.WithMessage("获取用户角色成功"))
+}
+
+func (ctrl *RBACController) SetUserRolesHandler(ctx *gin.Context) {
+	userID, ok := parseID(ctx, "user_id", "用户ID格式错误")
+	if !ok {
+		return
+	}
+	var req dto.SetUserRolesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+	if _, err := ctrl.userService.GetUser(ctx.Request.Context(), map[string]any{"id": userID}); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+
+	if err := ctrl.rbacService.SetUserRoles(ctx.Request.Context(), userID, req.Roles); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("用户角色更新成功"))
+}
+
+func (ctrl *RBACController) ListGroupsHandler(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "页码格式错误", "about:blank")
+		return
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "每页条数格式错误", "about:blank")
+		return
+	}
+
+	groups, err := ctrl.groupService.ListGroups(ctx.Request.Context(), page, pageSize, ctx.Query("display_name"))
+	if err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, groups, response.WithMessage("获取用户组列表成功"))
+}
+
+func (ctrl *RBACController) GetGroupHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "用户组ID格式错误")
+	if !ok {
+		return
+	}
+
+	group, err := ctrl.groupService.GetGroup(ctx.Request.Context(), id)
+	if err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, group, response.WithMessage("获取用户组成功"))
+}
+
+func (ctrl *RBACController) CreateGroupHandler(ctx *gin.Context) {
+	var req dto.CreateGroupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.groupService.CreateGroup(ctx.Request.Context(), &req); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("用户组创建成功"))
+}
+
+func (ctrl *RBACController) UpdateGroupHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "用户组ID格式错误")
+	if !ok {
+		return
+	}
+	var req dto.UpdateGroupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.groupService.UpdateGroup(ctx.Request.Context(), id, &req); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("用户组更新成功"))
+}
+
+func (ctrl *RBACController) DeleteGroupHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "用户组ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.groupService.DeleteGroup(ctx.Request.Context(), id); err != nil {
+		failRBAC(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("用户组删除成功"))
+}
+
+func parseID(ctx *gin.Context, param, message string) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param(param), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", message, "about:blank")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func failRBAC(ctx *gin.Context, err error) {
+	switch err {
+	case apperrors.ErrRoleNotFound:
+		problem.Fail(ctx, 404, "ROLE_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrGroupNotFound:
+		problem.Fail(ctx, 404, "GROUP_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrUserNotFound:
+		problem.Fail(ctx, 404, "USER_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrRoleExists:
+		problem.Fail(ctx, 409, "ROLE_EXISTS", err.Error(), "about:blank")
+	case apperrors.ErrGroupExists:
+		problem.Fail(ctx, 409, "GROUP_EXISTS", err.Error(), "about:blank")
+	case apperrors.ErrRoleBuiltin:
+		problem.Fail(ctx, 409, "ROLE_BUILTIN", err.Error(), "about:blank")
+	case apperrors.ErrPermissionNotFound:
+		problem.Fail(ctx, 400, "PERMISSION_NOT_FOUND", err.Error(), "about:blank")
+	default:
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+	}
+}
@@ -0,0 +1,324 @@
+package scimcontrollers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+	"goauth/services/scim"
+	"goauth/utils"
+)
+
+// contentType RFC 7644 3.1 规定的媒体类型
+const contentType = "application/scim+json"
+
+type SCIMController struct {
+	scimUserService  *scimservices.SCIMUserService
+	scimGroupService *scimservices.SCIMGroupService
+	scimBulkService  *scimservices.SCIMBulkService
+	baseURL          string
+}
+
+func NewSCIMController(scimUserService *scimservices.SCIMUserService, scimGroupService *scimservices.SCIMGroupService, scimBulkService *scimservices.SCIMBulkService, baseURL string) *SCIMController {
+	return &SCIMController{scimUserService: scimUserService, scimGroupService: scimGroupService, scimBulkService: scimBulkService, baseURL: baseURL}
+}
+
+func (ctrl *SCIMController) ListUsersHandler(ctx *gin.Context) {
+	startIndex, count := listParams(ctx)
+	result, err := ctrl.scimUserService.List(ctx.Request.Context(), ctx.Query("filter"), startIndex, count)
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respond(ctx, http.StatusOK, result)
+}
+
+func (ctrl *SCIMController) GetUserHandler(ctx *gin.Context) {
+	user, err := ctrl.scimUserService.Get(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusOK, user, user.Meta)
+}
+
+func (ctrl *SCIMController) CreateUserHandler(ctx *gin.Context) {
+	var req scimdto.User
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+	user, err := ctrl.scimUserService.Create(ctx.Request.Context(), &req)
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusCreated, user, user.Meta)
+}
+
+func (ctrl *SCIMController) ReplaceUserHandler(ctx *gin.Context) {
+	var req scimdto.User
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+	user, err := ctrl.scimUserService.Replace(ctx.Request.Context(), ctx.Param("id"), &req, ctx.GetHeader("If-Match"))
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusOK, user, user.Meta)
+}
+
+func (ctrl *SCIMController) PatchUserHandler(ctx *gin.Context) {
+	var req scimdto.PatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+	user, err := ctrl.scimUserService.Patch(ctx.Request.Context(), ctx.Param("id"), &req, ctx.GetHeader("If-Match"))
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusOK, user, user.Meta)
+}
+
+func (ctrl *SCIMController) DeleteUserHandler(ctx *gin.Context) {
+	if err := ctrl.scimUserService.Delete(ctx.Request.Context(), ctx.Param("id"), ctx.GetHeader("If-Match")); err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (ctrl *SCIMController) ListGroupsHandler(ctx *gin.Context) {
+	startIndex, count := listParams(ctx)
+	result, err := ctrl.scimGroupService.List(ctx.Request.Context(), ctx.Query("filter"), startIndex, count)
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respond(ctx, http.StatusOK, result)
+}
+
+func (ctrl *SCIMController) GetGroupHandler(ctx *gin.Context) {
+	group, err := ctrl.scimGroupService.Get(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusOK, group, group.Meta)
+}
+
+func (ctrl *SCIMController) CreateGroupHandler(ctx *gin.Context) {
+	var req scimdto.Group
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+	group, err := ctrl.scimGroupService.Create(ctx.Request.Context(), &req)
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusCreated, group, group.Meta)
+}
+
+func (ctrl *SCIMController) ReplaceGroupHandler(ctx *gin.Context) {
+	var req scimdto.Group
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+	group, err := ctrl.scimGroupService.Replace(ctx.Request.Context(), ctx.Param("id"), &req, ctx.GetHeader("If-Match"))
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusOK, group, group.Meta)
+}
+
+func (ctrl *SCIMController) PatchGroupHandler(ctx *gin.Context) {
+	var req scimdto.PatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+	group, err := ctrl.scimGroupService.Patch(ctx.Request.Context(), ctx.Param("id"), &req, ctx.GetHeader("If-Match"))
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	respondResource(ctx, http.StatusOK, group, group.Meta)
+}
+
+func (ctrl *SCIMController) DeleteGroupHandler(ctx *gin.Context) {
+	if err := ctrl.scimGroupService.Delete(ctx.Request.Context(), ctx.Param("id"), ctx.GetHeader("If-Match")); err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// BulkHandler 批量操作，单个操作的失败记录在各自的结果中
+func (ctrl *SCIMController) BulkHandler(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, scimservices.MaxBulkPayloadSize)
+	var req scimdto.BulkRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			failSCIM(ctx, apperrors.ErrSCIMTooMany)
+			return
+		}
+		failSCIM(ctx, apperrors.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	results, err := ctrl.scimBulkService.Process(ctx.Request.Context(), &req)
+	if err != nil {
+		failSCIM(ctx, err)
+		return
+	}
+	operations := make([]scimdto.BulkOperationResult, len(results))
+	for i, result := range results {
+		operations[i] = result.BulkOperationResult
+		if result.Err != nil {
+			status, body := scimError(result.Err)
+			operations[i].Status = strconv.Itoa(status)
+			operations[i].Response = body
+		}
+	}
+	respond(ctx, http.StatusOK, &scimdto.BulkResponse{Schemas: []string{scimdto.SchemaBulkResponse}, Operations: operations})
+}
+
+func (ctrl *SCIMController) ServiceProviderConfigHandler(ctx *gin.Context) {
+	respond(ctx, http.StatusOK, &scimdto.ServiceProviderConfig{
+		Schemas:        []string{scimdto.SchemaServiceProviderConfig},
+		Patch:          scimdto.Supported{Supported: true},
+		Bulk:           scimdto.BulkSupported{Supported: true, MaxOperations: scimservices.MaxBulkOperations, MaxPayloadSize: scimservices.MaxBulkPayloadSize},
+		Filter:         scimdto.FilterSupported{Supported: true, MaxResults: scimservices.MaxResults},
+		ChangePassword: scimdto.Supported{Supported: true},
+		Sort:           scimdto.Supported{Supported: false},
+		ETag:           scimdto.Supported{Supported: true},
+		AuthenticationSchemes: []scimdto.AuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "使用 client_credentials 授权获取的、包含 scim 范围的访问令牌",
+			Primary:     true,
+		}},
+	})
+}
+
+func (ctrl *SCIMController) ResourceTypesHandler(ctx *gin.Context) {
+	endpoint := utils.RealmURL(ctx.Request.Context(), ctrl.baseURL, "/scim/v2")
+	resourceTypes := []any{
+		&scimdto.ResourceType{
+			Schemas:  []string{scimdto.SchemaResourceType},
+			ID:       "User",
+			Name:     "User",
+			Endpoint: "/Users",
+			Schema:   scimdto.SchemaUser,
+			Meta:     &scimdto.Meta{ResourceType: "ResourceType", Location: endpoint + "/ResourceTypes/User"},
+		},
+		&scimdto.ResourceType{
+			Schemas:  []string{scimdto.SchemaResourceType},
+			ID:       "Group",
+			Name:     "Group",
+			Endpoint: "/Groups",
+			Schema:   scimdto.SchemaGroup,
+			Meta:     &scimdto.Meta{ResourceType: "ResourceType", Location: endpoint + "/ResourceTypes/Group"},
+		},
+	}
+	respond(ctx, http.StatusOK, &scimdto.ListResponse{
+		Schemas:      []string{scimdto.SchemaListResponse},
+		TotalResults: int64(len(resourceTypes)),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+// listParams startIndex 从 1 开始，count 未传时使用默认值，格式错误时同样按未传处理
+func listParams(ctx *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(ctx.Query("startIndex"))
+	if err != nil {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(ctx.Query("count"))
+	if err != nil {
+		count = scimservices.DefaultCount
+	}
+	return startIndex, count
+}
+
+func respond(ctx *gin.Context, status int, body any) {
+	ctx.Header("Content-Type", contentType)
+	ctx.JSON(status, body)
+}
+
+// respondResource 响应单个资源，附带 ETag 与 Location；If-None-Match 命中时返回 304
+func respondResource(ctx *gin.Context, status int, body any, meta *scimdto.Meta) {
+	ctx.Header("ETag", meta.Version)
+	if status == http.StatusCreated {
+		ctx.Header("Location", meta.Location)
+	}
+	if status == http.StatusOK && ctx.Request.Method == http.MethodGet && scimservices.NotModified(ctx.GetHeader("If-None-Match"), meta.Version) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	respond(ctx, status, body)
+}
+
+func failSCIM(ctx *gin.Context, err error) {
+	status, body := scimError(err)
+	respond(ctx, status, body)
+}
+
+// scimError 将业务错误映射为 RFC 7644 3.12 的错误响应
+func scimError(err error) (int, *scimdto.Error) {
+	var status int
+	var scimType string
+	switch {
+	case errors.Is(err, apperrors.ErrSCIMInvalidFilter):
+		status, scimType = http.StatusBadRequest, "invalidFilter"
+	case errors.Is(err, apperrors.ErrSCIMInvalidPath):
+		status, scimType = http.StatusBadRequest, "invalidPath"
+	case errors.Is(err, apperrors.ErrSCIMInvalidValue), isPasswordPolicyError(err):
+		status, scimType = http.StatusBadRequest, "invalidValue"
+	case errors.Is(err, apperrors.ErrSCIMInvalidSyntax):
+		status, scimType = http.StatusBadRequest, "invalidSyntax"
+	case errors.Is(err, apperrors.ErrSCIMNoTarget):
+		status, scimType = http.StatusBadRequest, "noTarget"
+	case errors.Is(err, apperrors.ErrSCIMMutability), errors.Is(err, apperrors.ErrPasswordManagedByDirectory):
+		status, scimType = http.StatusBadRequest, "mutability"
+	case errors.Is(err, apperrors.ErrSCIMUniqueness):
+		status, scimType = http.StatusConflict, "uniqueness"
+	case errors.Is(err, apperrors.ErrSCIMBulkReference):
+		status, scimType = http.StatusConflict, "invalidValue"
+	case errors.Is(err, apperrors.ErrSCIMNotFound), errors.Is(err, apperrors.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, apperrors.ErrSCIMPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, apperrors.ErrSCIMTooMany):
+		status, scimType = http.StatusRequestEntityTooLarge, "tooMany"
+	default:
+		status = http.StatusInternalServerError
+	}
+	return status, &scimdto.Error{
+		Schemas:  []string{scimdto.SchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   err.Error(),
+	}
+}
+
+func isPasswordPolicyError(err error) bool {
+	return errors.Is(err, apperrors.ErrPasswordTooShort) || errors.Is(err, apperrors.ErrPasswordTooLong) ||
+		errors.Is(err, apperrors.ErrPasswordTooWeak) || errors.Is(err, apperrors.ErrPasswordContainsUserInfo) ||
+		errors.Is(err, apperrors.ErrPasswordBreached) || errors.Is(err, apperrors.ErrPasswordReused)
+}
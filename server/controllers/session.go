@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"github.com/3086953492/gokit/ginx/cookie"
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type SessionController struct {
+	sessionService *services.SessionService
+	cookieMgr      *cookie.TokenCookies
+}
+
+func NewSessionController(sessionService *services.SessionService, cookieMgr *cookie.TokenCookies) *SessionController {
+	return &SessionController{sessionService: sessionService, cookieMgr: cookieMgr}
+}
+
+func (ctrl *SessionController) ListSessionsHandler(ctx *gin.Context) {
+	sessions, err := ctrl.sessionService.ListSessions(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), ctx.GetString("session_id"))
+	if err != nil {
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+		return
+	}
+	response.OK(ctx, sessions, response.WithMessage("获取会话列表成功"))
+}
+
+func (ctrl *SessionController) RevokeSessionHandler(ctx *gin.Context) {
+	sessionID := ctx.Param("session_id")
+	if err := ctrl.sessionService.RevokeSession(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), sessionID); err != nil {
+		var status int
+		var title string
+		switch err {
+		case apperrors.ErrSessionNotFound:
+			status = 404
+			title = "SESSION_NOT_FOUND"
+		default:
+			status = 500
+			title = "INTERNAL_SERVER_ERROR"
+		}
+		problem.Fail(ctx, status, title, err.Error(), "about:blank")
+		return
+	}
+
+	// 注销当前会话等同于退出登录
+	if sessionID == ctx.GetString("session_id") {
+		ctrl.cookieMgr.Clear(ctx)
+	}
+	response.OK(ctx, nil, response.WithMessage("注销会话成功"))
+}
+
+func (ctrl *SessionController) RevokeAllSessionsHandler(ctx *gin.Context) {
+	frontchannelLogoutURIs, err := ctrl.sessionService.RevokeAllSessions(ctx.Request.Context(), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+		return
+	}
+
+	ctrl.cookieMgr.Clear(ctx)
+	response.OK(ctx, dto.LogoutResponse{FrontchannelLogoutURIs: frontchannelLogoutURIs}, response.WithMessage("注销全部会话成功"))
+}
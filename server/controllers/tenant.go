@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type TenantController struct {
+	tenantService    *services.TenantService
+	validatorManager *validator.Manager
+}
+
+func NewTenantController(tenantService *services.TenantService, validatorManager *validator.Manager) *TenantController {
+	return &TenantController{tenantService: tenantService, validatorManager: validatorManager}
+}
+
+func (ctrl *TenantController) ListTenantsHandler(ctx *gin.Context) {
+	tenants, err := ctrl.tenantService.ListTenants(ctx.Request.Context())
+	if err != nil {
+		failTenant(ctx, err)
+		return
+	}
+	response.OK(ctx, tenants, response.WithMessage("获取租户列表成功"))
+}
+
+func (ctrl *TenantController) CreateTenantHandler(ctx *gin.Context) {
+	var req dto.CreateTenantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	tenant, err := ctrl.tenantService.CreateTenant(ctx.Request.Context(), &req)
+	if err != nil {
+		failTenant(ctx, err)
+		return
+	}
+	response.OK(ctx, tenant, response.WithMessage("租户创建成功"))
+}
+
+func (ctrl *TenantController) UpdateTenantHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "租户ID格式错误")
+	if !ok {
+		return
+	}
+	var req dto.UpdateTenantRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.tenantService.UpdateTenant(ctx.Request.Context(), id, &req); err != nil {
+		failTenant(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("租户更新成功"))
+}
+
+func failTenant(ctx *gin.Context, err error) {
+	// 初始管理员的密码不符合策略时租户未创建，可修改密码后重试
+	if isPasswordPolicyError(err) {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", err.Error(), "about:blank")
+		return
+	}
+	switch err {
+	case apperrors.ErrTenantNotFound:
+		problem.Fail(ctx, 404, "TENANT_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrTenantExists:
+		problem.Fail(ctx, 409, "TENANT_EXISTS", err.Error(), "about:blank")
+	case apperrors.ErrTenantNameInvalid:
+		problem.Fail(ctx, 400, "INVALID_REQUEST", err.Error(), "about:blank")
+	case apperrors.ErrTenantDefault:
+		problem.Fail(ctx, 409, "CONFLICT", err.Error(), "about:blank")
+	case apperrors.ErrTenantAdminOnly:
+		problem.Fail(ctx, 403, "FORBIDDEN", err.Error(), "about:blank")
+	default:
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/3086953492/gokit/ginx/problem"
@@ -10,6 +11,7 @@ import (
 
 	"goauth/apperrors"
 	"goauth/dto"
+	"goauth/models"
 	"goauth/services"
 	"goauth/utils"
 )
@@ -41,12 +43,33 @@ func (ctrl *UserController) CreateUserHandler(ctx *gin.Context) {
 		return
 	}
 
-	if err := ctrl.userService.CreateUser(ctx.Request.Context(), &form, avatarFile); err != nil {
+	user, err := ctrl.userService.CreateUser(ctx.Request.Context(), &form, avatarFile)
+	if err != nil {
+		if err == apperrors.ErrEmailRequired {
+			problem.Fail(ctx, 400, "EMAIL_REQUIRED", err.Error(), "about:blank")
+			return
+		}
+		if err == apperrors.ErrUsernameTaken {
+			problem.Fail(ctx, 409, "USERNAME_TAKEN", err.Error(), "about:blank")
+			return
+		}
+		if err == apperrors.ErrEmailTaken {
+			problem.Fail(ctx, 409, "EMAIL_TAKEN", err.Error(), "about:blank")
+			return
+		}
 		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
 		return
 	}
 
-	response.OK(ctx, nil, response.WithMessage("创建用户成功"))
+	// 需要激活的账号提示下一步操作
+	message := "创建用户成功"
+	switch user.Status {
+	case models.UserStatusPendingEmail:
+		message = "注册成功，请查收激活邮件完成激活"
+	case models.UserStatusPendingApproval:
+		message = "注册成功，请等待管理员审核"
+	}
+	response.OK(ctx, dto.CreateUserResponse{ID: user.ID, Status: user.Status}, response.WithMessage(message))
 }
 
 func (ctrl *UserController) UpdateUserHandler(ctx *gin.Context) {
@@ -60,9 +83,8 @@ func (ctrl *UserController) UpdateUserHandler(ctx *gin.Context) {
 		return
 	}
 
-	if !utils.IsRole(ctx, "admin") { // 非管理员不能修改状态和角色
+	if !utils.HasPermission(ctx, models.PermissionUserWrite) { // 没有用户管理权限不能修改状态，角色通过角色分配接口修改
 		form.Status = nil
-		form.Role = ""
 	}
 
 	userID := ctx.Param("user_id")
@@ -79,6 +101,18 @@ func (ctrl *UserController) UpdateUserHandler(ctx *gin.Context) {
 	}
 
 	if err := ctrl.userService.UpdateUser(ctx.Request.Context(), uint(userIDUint), &form, avatarFile); err != nil {
+		if isPasswordPolicyError(err) {
+			problem.Fail(ctx, 400, "INVALID_PASSWORD", err.Error(), "about:blank")
+			return
+		}
+		if err == apperrors.ErrEmailTaken {
+			problem.Fail(ctx, 409, "EMAIL_TAKEN", err.Error(), "about:blank")
+			return
+		}
+		if err == apperrors.ErrPasswordManagedByDirectory {
+			problem.Fail(ctx, 409, "CONFLICT", err.Error(), "about:blank")
+			return
+		}
 		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
 		return
 	}
@@ -159,3 +193,10 @@ func (ctrl *UserController) DeleteUserHandler(ctx *gin.Context) {
 	}
 	response.OK(ctx, nil, response.WithMessage("删除用户成功"))
 }
+
+// isPasswordPolicyError 是否为新密码不符合密码策略
+func isPasswordPolicyError(err error) bool {
+	return errors.Is(err, apperrors.ErrPasswordTooShort) || errors.Is(err, apperrors.ErrPasswordTooLong) ||
+		errors.Is(err, apperrors.ErrPasswordTooWeak) || errors.Is(err, apperrors.ErrPasswordContainsUserInfo) ||
+		errors.Is(err, apperrors.ErrPasswordBreached) || errors.Is(err, apperrors.ErrPasswordReused)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/services"
+)
+
+type WebAuthnController struct {
+	webAuthnService  *services.WebAuthnService
+	validatorManager *validator.Manager
+}
+
+func NewWebAuthnController(webAuthnService *services.WebAuthnService, validatorManager *validator.Manager) *WebAuthnController {
+	return &WebAuthnController{webAuthnService: webAuthnService, validatorManager: validatorManager}
+}
+
+func (ctrl *WebAuthnController) ListPasskeysHandler(ctx *gin.Context) {
+	passkeys, err := ctrl.webAuthnService.ListCredentials(ctx.Request.Context(), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, passkeys, response.WithMessage("获取通行密钥列表成功"))
+}
+
+func (ctrl *WebAuthnController) BeginRegistrationHandler(ctx *gin.Context) {
+	options, err := ctrl.webAuthnService.BeginRegistration(ctx.Request.Context(), uint(ctx.GetUint64("user_id")))
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, options, response.WithMessage("请按浏览器提示创建通行密钥"))
+}
+
+func (ctrl *WebAuthnController) FinishRegistrationHandler(ctx *gin.Context) {
+	var req dto.WebAuthnRegisterRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	passkey, err := ctrl.webAuthnService.FinishRegistration(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), &req)
+	if err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, passkey, response.WithMessage("添加通行密钥成功"))
+}
+
+func (ctrl *WebAuthnController) RenamePasskeyHandler(ctx *gin.Context) {
+	passkeyID, err := strconv.ParseUint(ctx.Param("passkey_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "通行密钥ID格式错误", "about:blank")
+		return
+	}
+
+	var req dto.WebAuthnRenameRequest
+	if ctx.ShouldBindJSON(&req) != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.webAuthnService.RenameCredential(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), uint(passkeyID), req.Name); err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("修改通行密钥成功"))
+}
+
+func (ctrl *WebAuthnController) DeletePasskeyHandler(ctx *gin.Context) {
+	passkeyID, err := strconv.ParseUint(ctx.Param("passkey_id"), 10, 64)
+	if err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "通行密钥ID格式错误", "about:blank")
+		return
+	}
+
+	if err := ctrl.webAuthnService.DeleteCredential(ctx.Request.Context(), uint(ctx.GetUint64("user_id")), uint(passkeyID)); err != nil {
+		failWebAuthn(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("删除通行密钥成功"))
+}
+
+// failWebAuthn 按通行密钥错误类型返回对应状态码，其余错误按两步验证错误处理
+func failWebAuthn(ctx *gin.Context, err error) {
+	switch err {
+	case apperrors.ErrWebAuthnCeremonyInvalid, apperrors.ErrWebAuthnVerifyFailed, apperrors.ErrWebAuthnCredentialCloned:
+		problem.Fail(ctx, 401, "UNAUTHORIZED", err.Error(), "about:blank")
+	case apperrors.ErrWebAuthnNotFound:
+		problem.Fail(ctx, 404, "PASSKEY_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrWebAuthnCredentialExists, apperrors.ErrWebAuthnNoCredentials, apperrors.ErrWebAuthnLimitExceeded:
+		problem.Fail(ctx, 409, "CONFLICT", err.Error(), "about:blank")
+	default:
+		failMFA(ctx, err)
+	}
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/ginx/response"
+	"github.com/3086953492/gokit/validator"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/services"
+)
+
+type WebhookController struct {
+	webhookService   *services.WebhookService
+	validatorManager *validator.Manager
+}
+
+func NewWebhookController(webhookService *services.WebhookService, validatorManager *validator.Manager) *WebhookController {
+	return &WebhookController{webhookService: webhookService, validatorManager: validatorManager}
+}
+
+func (ctrl *WebhookController) ListWebhooksHandler(ctx *gin.Context) {
+	webhooks, err := ctrl.webhookService.ListWebhooks(ctx.Request.Context())
+	if err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, webhooks, response.WithMessage("获取 Webhook 列表成功"))
+}
+
+func (ctrl *WebhookController) GetWebhookHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+
+	webhook, err := ctrl.webhookService.GetWebhook(ctx.Request.Context(), id)
+	if err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, webhook, response.WithMessage("获取 Webhook 成功"))
+}
+
+func (ctrl *WebhookController) CreateWebhookHandler(ctx *gin.Context) {
+	var req dto.CreateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	webhook, err := ctrl.webhookService.CreateWebhook(ctx.Request.Context(), &req)
+	if err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, webhook, response.WithMessage("Webhook 创建成功，签名密钥只显示一次，请妥善保存"))
+}
+
+func (ctrl *WebhookController) UpdateWebhookHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+	var req dto.UpdateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "请求参数错误", "about:blank")
+		return
+	}
+	if result := ctrl.validatorManager.Validate(req); !result.Valid {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", result.Message, "about:blank")
+		return
+	}
+
+	if err := ctrl.webhookService.UpdateWebhook(ctx.Request.Context(), id, &req); err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("Webhook 更新成功"))
+}
+
+func (ctrl *WebhookController) DeleteWebhookHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "订阅ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.webhookService.DeleteWebhook(ctx.Request.Context(), id); err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("Webhook 删除成功"))
+}
+
+// ListDeliveriesHandler 分页查询投递记录，可按订阅与状态过滤，status=dead 即为死信列表
+func (ctrl *WebhookController) ListDeliveriesHandler(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "页码格式错误", "about:blank")
+		return
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "每页条数格式错误", "about:blank")
+		return
+	}
+
+	var subscriptionID uint
+	if value := ctx.Query("subscription_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			problem.Fail(ctx, 400, "INVALID_REQUEST", "订阅ID格式错误", "about:blank")
+			return
+		}
+		subscriptionID = uint(id)
+	}
+	status := ctx.Query("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		problem.Fail(ctx, 400, "INVALID_REQUEST", "状态只能是 pending、delivered 或 dead", "about:blank")
+		return
+	}
+
+	deliveries, err := ctrl.webhookService.ListDeliveries(ctx.Request.Context(), page, pageSize, subscriptionID, status)
+	if err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, deliveries, response.WithMessage("获取投递记录成功"))
+}
+
+func (ctrl *WebhookController) RetryDeliveryHandler(ctx *gin.Context) {
+	id, ok := parseID(ctx, "id", "投递ID格式错误")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.webhookService.RetryDelivery(ctx.Request.Context(), id); err != nil {
+		failWebhook(ctx, err)
+		return
+	}
+	response.OK(ctx, nil, response.WithMessage("已重新加入投递队列"))
+}
+
+func failWebhook(ctx *gin.Context, err error) {
+	switch err {
+	case apperrors.ErrWebhookNotFound:
+		problem.Fail(ctx, 404, "WEBHOOK_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrWebhookDeliveryNotFound:
+		problem.Fail(ctx, 404, "WEBHOOK_DELIVERY_NOT_FOUND", err.Error(), "about:blank")
+	case apperrors.ErrWebhookURLInvalid, apperrors.ErrWebhookEventInvalid:
+		problem.Fail(ctx, 400, "INVALID_REQUEST", err.Error(), "about:blank")
+	default:
+		problem.Fail(ctx, 500, "INTERNAL_SERVER_ERROR", err.Error(), "about:blank")
+	}
+}
@@ -0,0 +1,19 @@
+package dto
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordRequest struct {
+	Token           string `json:"token" validate:"required,max=1024"`
+	Password        string `json:"password" validate:"required,password_policy,password_not_breached"`
+	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=Password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=1024"`
+}
+
+type ResendActivationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
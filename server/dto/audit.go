@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEventQuery 审计事件查询条件，时间范围为左闭右开
+type AuditEventQuery struct {
+	UserID   uint      // 作为操作者或被操作用户出现的用户
+	ClientID string    // 客户端ID
+	Type     string    // 事件类型
+	Outcome  string    // 结果：success 或 failure
+	From     time.Time // 起始时间，零值表示不限
+	To       time.Time // 截止时间，零值表示不限
+}
+
+type AuditEventResponse struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	Outcome    string          `json:"outcome"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *uint           `json:"actor_id"`
+	UserID     *uint           `json:"user_id"`
+	Username   string          `json:"username"`
+	ClientID   string          `json:"client_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Reason     string          `json:"reason"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
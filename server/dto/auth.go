@@ -4,15 +4,23 @@ import "time"
 
 type LoginRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=20"`
-	Password string `json:"password" validate:"required,min=6,max=20"`
+	Password string `json:"password" validate:"required,max=128"` // 登录不校验密码策略，策略收紧前设置的密码仍可登录
 }
 
 type LoginResponse struct {
-	User                 *UserResponse `json:"user"`
-	AccessTokenExpireAt  time.Time     `json:"access_token_expire_at"`
-	RefreshTokenExpireAt time.Time     `json:"refresh_token_expire_at"`
+	User                 *UserResponse         `json:"user,omitempty"`
+	AccessTokenExpireAt  time.Time             `json:"access_token_expire_at"`
+	RefreshTokenExpireAt time.Time             `json:"refresh_token_expire_at"`
+	MFA                  *MFAChallengeResponse `json:"mfa,omitempty"`              // 需要两步验证时返回，此时不签发令牌
+	RecoveryCodes        []string              `json:"recovery_codes,omitempty"`   // 登录时完成验证器绑定后返回一次
+	PasswordExpired      bool                  `json:"password_expired,omitempty"` // 密码超过最长使用期限，应提示用户修改
 }
 
 type RefreshTokenResponse struct {
-	AccessTokenExpireAt time.Time `json:"access_token_expire_at"`
-}
\ No newline at end of file
+	AccessTokenExpireAt  time.Time `json:"access_token_expire_at"`
+	RefreshTokenExpireAt time.Time `json:"refresh_token_expire_at"`
+}
+
+type LogoutResponse struct {
+	FrontchannelLogoutURIs []string `json:"frontchannel_logout_uris,omitempty"` // 需在隐藏的 iframe 中加载，通知客户端清除浏览器中的登录状态
+}
@@ -0,0 +1,33 @@
+package dto
+
+import "time"
+
+// FederatedProviderResponse 登录页可用的外部身份提供方
+type FederatedProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"`
+}
+
+type FederatedAuthorizeRequest struct {
+	Redirect string `json:"redirect" validate:"omitempty,max=500"` // 登录完成后返回的前端路径
+}
+
+type FederatedAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type FederatedLoginRequest struct {
+	Ticket string `json:"ticket" validate:"required,max=128"`
+}
+
+// FederatedIdentityResponse 已关联的外部账号
+type FederatedIdentityResponse struct {
+	ID                  uint       `json:"id"`
+	Provider            string     `json:"provider"`
+	ProviderDisplayName string     `json:"provider_display_name"`
+	Email               string     `json:"email"`
+	Username            string     `json:"username"`
+	CreatedAt           time.Time  `json:"created_at"`
+	LastLoginAt         *time.Time `json:"last_login_at"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type LoginLockoutResponse struct {
+	Locked      bool       `json:"locked"`
+	LockedUntil *time.Time `json:"locked_until"`
+	Failures    int64      `json:"failures"` // 滑动窗口内的失败次数
+}
@@ -0,0 +1,49 @@
+package dto
+
+import "time"
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`      // Base32 密钥，供无法扫码时手动输入
+	OTPAuthURI string `json:"otpauth_uri"` // otpauth URI，用于生成二维码
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type TOTPDisableRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"` // 仅在生成时返回一次
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"` // 是否为安全策略强制启用（管理员）
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// MFAChallengeResponse 密码验证通过后返回的两步验证挑战
+type MFAChallengeResponse struct {
+	MFAToken           string    `json:"mfa_token"`
+	Methods            []string  `json:"methods"`             // 可用的验证方式：totp、recovery_code、webauthn
+	EnrollmentRequired bool      `json:"enrollment_required"` // 账号须先绑定验证器才能完成登录
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+type LoginMFARequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=20"`
+}
+
+type LoginMFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+type LoginMFAEnrollConfirmRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,len=6,numeric"`
+}
@@ -27,6 +27,11 @@ type CreateOAuthClientRequest struct {
 	AuthCodeExpire     *int `json:"auth_code_expire" validate:"omitempty,min=60,max=600"`
 	AccessTokenExpire  *int `json:"access_token_expire" validate:"omitempty,min=300,max=86400"`
 	RefreshTokenExpire *int `json:"refresh_token_expire" validate:"omitempty,min=3600,max=31536000"`
+
+	// 可选登出字段
+	PostLogoutRedirectURIs datatypes.JSON `json:"post_logout_redirect_uris" validate:"omitempty"`
+	BackchannelLogoutURI   string         `json:"backchannel_logout_uri" validate:"omitempty,url,max=500"`
+	FrontchannelLogoutURI  string         `json:"frontchannel_logout_uri" validate:"omitempty,url,max=500"`
 }
 
 type OAuthClientListResponse struct {
@@ -53,6 +58,11 @@ type OAuthClientDetailResponse struct {
 	RefreshTokenSecret string `json:"-"`
 	RefreshTokenExpire int    `json:"refresh_token_expire"`
 
+	// 登出字段
+	PostLogoutRedirectURIs datatypes.JSON `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   string         `json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  string         `json:"frontchannel_logout_uri"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -78,4 +88,9 @@ type UpdateOAuthClientRequest struct {
 	AuthCodeExpire     *int `json:"auth_code_expire" validate:"omitempty,min=60,max=600"`
 	AccessTokenExpire  *int `json:"access_token_expire" validate:"omitempty,min=300,max=86400"`
 	RefreshTokenExpire *int `json:"refresh_token_expire" validate:"omitempty,min=3600,max=31536000"`
+
+	// 可选登出字段（传空字符串表示清除）
+	PostLogoutRedirectURIs *datatypes.JSON `json:"post_logout_redirect_uris" validate:"omitempty"`
+	BackchannelLogoutURI   *string         `json:"backchannel_logout_uri" validate:"omitempty,max=500"`
+	FrontchannelLogoutURI  *string         `json:"frontchannel_logout_uri" validate:"omitempty,max=500"`
 }
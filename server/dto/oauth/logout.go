@@ -0,0 +1,16 @@
+package oauthdto
+
+// EndSessionForm OpenID Connect 依赖方发起的登出请求（RP-Initiated Logout）
+type EndSessionForm struct {
+	IDTokenHint           string `form:"id_token_hint"`
+	ClientID              string `form:"client_id"`
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri"`
+	State                 string `form:"state"`
+}
+
+// BackchannelLogoutData 后端通道登出通知的投递数据，登出令牌在每次发送时重新签发
+type BackchannelLogoutData struct {
+	UserID    uint   `json:"user_id"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid,omitempty"` // 结束的登录会话对客户端公开的标识，注销全部会话时为空
+}
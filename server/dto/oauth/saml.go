@@ -0,0 +1,68 @@
+package oauthdto
+
+import "time"
+
+type SAMLAttribute struct {
+	Name         string `json:"name" validate:"required,max=255"`
+	FriendlyName string `json:"friendly_name" validate:"omitempty,max=100"`
+	Source       string `json:"source" validate:"required,oneof=subject username nickname email role"`
+}
+
+type CreateSAMLServiceProviderRequest struct {
+	// 必填基本字段，实体ID从元数据中读取
+	Name     string `json:"name" validate:"required,min=3,max=50"`
+	Metadata string `json:"metadata" validate:"required,max=1048576"`
+	Status   int    `json:"status" validate:"oneof=1 0"`
+
+	// 可选基本字段
+	Description string `json:"description" validate:"omitempty,max=255"`
+	Logo        string `json:"logo" validate:"omitempty,url"`
+
+	// 可选断言配置，NameID 格式不传时使用 persistent
+	NameIDFormat      string          `json:"name_id_format" validate:"omitempty,oneof=urn:oasis:names:tc:SAML:2.0:nameid-format:persistent urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"`
+	Attributes        []SAMLAttribute `json:"attributes" validate:"omitempty,max=20,dive"`
+	AllowIDPInitiated bool            `json:"allow_idp_initiated"`
+	DefaultRelayState string          `json:"default_relay_state" validate:"omitempty,max=500"`
+}
+
+type UpdateSAMLServiceProviderRequest struct {
+	Name        *string `json:"name" validate:"omitempty,min=3,max=50"`
+	Metadata    *string `json:"metadata" validate:"omitempty,max=1048576"`
+	Status      *int    `json:"status" validate:"omitempty,oneof=1 0"`
+	Description *string `json:"description" validate:"omitempty,max=255"`
+	Logo        *string `json:"logo" validate:"omitempty,url"`
+
+	NameIDFormat      *string          `json:"name_id_format" validate:"omitempty,oneof=urn:oasis:names:tc:SAML:2.0:nameid-format:persistent urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"`
+	Attributes        *[]SAMLAttribute `json:"attributes" validate:"omitempty,max=20,dive"`
+	AllowIDPInitiated *bool            `json:"allow_idp_initiated"`
+	DefaultRelayState *string          `json:"default_relay_state" validate:"omitempty,max=500"`
+}
+
+type SAMLServiceProviderListResponse struct {
+	ID                uint   `json:"id"`
+	EntityID          string `json:"entity_id"`
+	Name              string `json:"name"`
+	Logo              string `json:"logo"`
+	AllowIDPInitiated bool   `json:"allow_idp_initiated"`
+	Status            int    `json:"status"`
+}
+
+type SAMLServiceProviderDetailResponse struct {
+	ID                uint            `json:"id"`
+	EntityID          string          `json:"entity_id"`
+	Name              string          `json:"name"`
+	Description       string          `json:"description"`
+	Logo              string          `json:"logo"`
+	Metadata          string          `json:"metadata"`
+	NameIDFormat      string          `json:"name_id_format"`
+	Attributes        []SAMLAttribute `json:"attributes"`
+	AllowIDPInitiated bool            `json:"allow_idp_initiated"`
+	DefaultRelayState string          `json:"default_relay_state"`
+	Status            int             `json:"status"`
+
+	// IDPInitiatedURL 从 goauth 直接登录到该应用的地址，未允许 IdP 发起登录时为空
+	IDPInitiatedURL string `json:"idp_initiated_url"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -5,6 +5,7 @@ type ExchangeAccessTokenResponse struct {
 	RefreshToken OAuthRefreshTokenResponse `json:"refresh_token"`
 	TokenType    string                    `json:"token_type"`
 	Scope        string                    `json:"scope"`
+	IDToken      string                    `json:"id_token,omitempty"` // 授予 openid 范围时签发
 }
 
 type OAuthAccessTokenResponse struct {
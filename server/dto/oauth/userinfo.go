@@ -1,8 +1,10 @@
 package oauthdto
 
 type UserInfoResponse struct {
-	Sub       string `json:"sub"`
-	Nickname  string `json:"nickname"`
-	Picture   string `json:"picture"`
-	UpdatedAt int64  `json:"updated_at"`
+	Sub       string   `json:"sub"`
+	Nickname  string   `json:"nickname"`
+	Picture   string   `json:"picture"`
+	UpdatedAt int64    `json:"updated_at"`
+	Roles     []string `json:"roles,omitempty"`  // 授予 roles 范围时返回
+	Groups    []string `json:"groups,omitempty"` // 授予 groups 范围时返回
 }
@@ -0,0 +1,78 @@
+package dto
+
+// PolicyExplainRequest 解释某个主体访问某条策略或路由的授权决策
+// Policy 与 Method、Path 二选一，Path 为路由模板，如 /api/v1/users/:user_id；
+// 主体为用户且未给出角色与权限时，按其当前实际拥有的角色与权限计算
+type PolicyExplainRequest struct {
+	Policy      string    `json:"policy" validate:"omitempty,max=100"`
+	Method      string    `json:"method" validate:"required_without=Policy,omitempty,max=10"`
+	Path        string    `json:"path" validate:"required_without=Policy,omitempty,max=255"`
+	Principal   string    `json:"principal" validate:"required,oneof=user client"`
+	UserID      uint64    `json:"user_id"`
+	ClientID    string    `json:"client_id" validate:"omitempty,max=100"`
+	Scope       string    `json:"scope" validate:"omitempty,max=1000"`
+	Roles       *[]string `json:"roles" validate:"omitempty,max=50,dive,max=50"`
+	Permissions *[]string `json:"permissions" validate:"omitempty,max=100,dive,max=100"`
+	PathUserID  uint64    `json:"path_user_id"`  // 路径参数 user_id
+	QueryUserID uint64    `json:"query_user_id"` // 查询参数 user_id
+}
+
+// PolicyConditionResult 规则中单个条件的判断结果
+type PolicyConditionResult struct {
+	Name     string `json:"name"` // principal、scopes、roles、permissions、owner、client_ids
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
+}
+
+// PolicyRuleResult 单条规则的判断结果，条件全部满足时规则通过
+type PolicyRuleResult struct {
+	Index      int                     `json:"index"`
+	Passed     bool                    `json:"passed"`
+	Conditions []PolicyConditionResult `json:"conditions"`
+}
+
+// PolicyDecision 授权决策，Enforced 为 false 时策略处于演练模式，拒绝只记录日志
+type PolicyDecision struct {
+	Policy      string             `json:"policy"`
+	Allowed     bool               `json:"allowed"`
+	Enforced    bool               `json:"enforced"`
+	MatchedRule int                `json:"matched_rule"` // 第一条通过的规则序号，未通过时为 -1
+	Rules       []PolicyRuleResult `json:"rules"`
+}
+
+type PolicyRuleResponse struct {
+	Principal   string   `json:"principal"`
+	Scopes      []string `json:"scopes"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Owner       string   `json:"owner"`
+	ClientIDs   []string `json:"client_ids"`
+}
+
+type PolicyResponse struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	DryRun      bool                 `json:"dry_run"`
+	Routes      []string             `json:"routes"`
+	Rules       []PolicyRuleResponse `json:"rules"`
+}
+
+// PolicyRouteResponse 访问矩阵中的一行：路由绑定的策略，以及哪些角色、本人、客户端可以访问
+// Roles 按仅拥有该角色的 Cookie 登录用户、且不是资源所有者来计算
+type PolicyRouteResponse struct {
+	Method        string   `json:"method"`
+	Path          string   `json:"path"`
+	Policy        string   `json:"policy"`
+	Enforced      bool     `json:"enforced"`
+	Roles         []string `json:"roles"`
+	OwnerAllowed  bool     `json:"owner_allowed"`  // 没有任何角色的用户访问自己的资源
+	ClientAllowed bool     `json:"client_allowed"` // 存在允许客户端凭证令牌访问的规则
+}
+
+// PolicyMatrixResponse 当前生效的授权策略与访问矩阵
+type PolicyMatrixResponse struct {
+	Mode     string                `json:"mode"`
+	Policies []PolicyResponse      `json:"policies"`
+	Routes   []PolicyRouteResponse `json:"routes"`
+}
@@ -0,0 +1,76 @@
+package dto
+
+import "time"
+
+type PermissionResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Builtin     bool      `json:"builtin"` // 内置角色不能改名或删除
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50"`
+	Description string   `json:"description" validate:"omitempty,max=255"`
+	Permissions []string `json:"permissions" validate:"omitempty,max=100,dive,max=100"`
+}
+
+type UpdateRoleRequest struct {
+	Name        *string   `json:"name" validate:"omitempty,min=2,max=50"`
+	Description *string   `json:"description" validate:"omitempty,max=255"`
+	Permissions *[]string `json:"permissions" validate:"omitempty,max=100,dive,max=100"`
+}
+
+// UserRolesResponse 用户的角色，Roles 为直接分配的角色，EffectiveRoles 另含经由用户组获得的角色
+type UserRolesResponse struct {
+	Roles          []string `json:"roles"`
+	EffectiveRoles []string `json:"effective_roles"`
+	Permissions    []string `json:"permissions"`
+}
+
+type SetUserRolesRequest struct {
+	Roles []string `json:"roles" validate:"max=50,dive,max=50"`
+}
+
+type GroupMemberResponse struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+}
+
+type GroupListResponse struct {
+	ID          uint      `json:"id"`
+	DisplayName string    `json:"display_name"`
+	MemberCount int       `json:"member_count"`
+	Roles       []string  `json:"roles"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type GroupDetailResponse struct {
+	ID          uint                  `json:"id"`
+	DisplayName string                `json:"display_name"`
+	Members     []GroupMemberResponse `json:"members"`
+	Roles       []string              `json:"roles"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+type CreateGroupRequest struct {
+	DisplayName string   `json:"display_name" validate:"required,min=1,max=255"`
+	Members     []uint   `json:"members" validate:"omitempty,max=1000"`
+	Roles       []string `json:"roles" validate:"omitempty,max=50,dive,max=50"`
+}
+
+type UpdateGroupRequest struct {
+	DisplayName *string   `json:"display_name" validate:"omitempty,min=1,max=255"`
+	Members     *[]uint   `json:"members" validate:"omitempty,max=1000"`
+	Roles       *[]string `json:"roles" validate:"omitempty,max=50,dive,max=50"`
+}
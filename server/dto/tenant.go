@@ -0,0 +1,32 @@
+package dto
+
+import "time"
+
+type TenantResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Status      int       `json:"status"`
+	Issuer      string    `json:"issuer"` // 租户签发的令牌中的 iss
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TenantAdminRequest 新租户的初始管理员，租户之间相互隔离，新租户只能由它登录后继续管理
+type TenantAdminRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=20"`
+	Email    string `json:"email" validate:"omitempty,email,max=255"`
+	Password string `json:"password" validate:"required,password_policy,password_not_breached"`
+}
+
+// CreateTenantRequest Name 出现在 /realms/{name} 路径中，创建后不能修改
+type CreateTenantRequest struct {
+	Name        string             `json:"name" validate:"required,min=2,max=50"`
+	DisplayName string             `json:"display_name" validate:"required,min=1,max=100"`
+	Admin       TenantAdminRequest `json:"admin" validate:"required"`
+}
+
+type UpdateTenantRequest struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,min=1,max=100"`
+	Status      *int    `json:"status" validate:"omitempty,oneof=0 1"`
+}
@@ -3,29 +3,40 @@ package dto
 import "time"
 
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	Nickname  string    `json:"nickname"`
-	Avatar    string    `json:"avatar"`
-	Status    int       `json:"status"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint      `json:"id"`
+	Username      string    `json:"username"`
+	Nickname      string    `json:"nickname"`
+	Avatar        string    `json:"avatar"`
+	Status        int       `json:"status"`
+	Roles         []string  `json:"roles"`       // 实际拥有的角色，包括经由用户组获得的
+	Permissions   []string  `json:"permissions"` // 角色对应的全部权限，前端据此显示管理入口
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 type UpdateUserForm struct { // *字段传递空值会更新为空值，不传递则不更新
 	Nickname        string `form:"nickname" validate:"omitempty,min=1,max=20"`
-	Password        string `form:"password" validate:"omitempty,min=6,max=20"`
+	Email           string `form:"email" validate:"omitempty,email,max=255"` // 修改后须重新验证
+	Password        string `form:"password" validate:"omitempty,password_policy,password_not_breached"`
 	ConfirmPassword string `form:"confirm_password" validate:"omitempty,eqfield=Password"`
 	Status          *int   `form:"status" validate:"omitempty,oneof=1 0"`
-	Role            string `form:"role" validate:"omitempty,oneof=admin user"`
 }
 
 type CreateUserForm struct {
-	Username        string `form:"username" validate:"required,min=3,max=20,username_unique"`
-	Password        string `form:"password" validate:"required,min=6,max=20"`
+	Username        string `form:"username" validate:"required,min=3,max=20"`
+	Password        string `form:"password" validate:"required,password_policy,password_not_breached"`
 	ConfirmPassword string `form:"confirm_password" validate:"required,eqfield=Password"`
 	Nickname        string `form:"nickname" validate:"required,min=1,max=20"`
+	Email           string `form:"email" validate:"omitempty,email,max=255"`
+}
+
+// CreateUserResponse 注册结果，Status 不为正常时须先完成激活
+type CreateUserResponse struct {
+	ID     uint `json:"id"`
+	Status int  `json:"status"`
 }
 
 type UserListResponse struct {
@@ -33,5 +44,20 @@ type UserListResponse struct {
 	Nickname  string    `json:"nickname"`
 	Avatar    string    `json:"avatar"`
 	Status    int       `json:"status"`
-	Role      string    `json:"role"`
+	Roles     []string  `json:"roles"`
+}
+
+// PendingUserResponse 待审核的注册申请
+type PendingUserResponse struct {
+	ID            uint      `json:"id"`
+	Username      string    `json:"username"`
+	Nickname      string    `json:"nickname"`
+	Avatar        string    `json:"avatar"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type RejectRegistrationRequest struct {
+	Reason string `json:"reason" validate:"omitempty,max=200"` // 写入通知邮件
 }
@@ -0,0 +1,98 @@
+package dto
+
+import "time"
+
+// 通行密钥请求与响应的字段沿用 WebAuthn JSON 序列化格式，
+// 前端可直接传给 PublicKeyCredential.parseCreationOptionsFromJSON / parseRequestOptionsFromJSON，
+// 二进制字段均为无填充的 base64url
+
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"` // 用户句柄，不含用户信息
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// WebAuthnCreationOptions 注册通行密钥的参数
+type WebAuthnCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions 使用通行密钥认证的参数
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnCredentialResponse 认证器返回的数据，注册时包含 AttestationObject，认证时包含 AuthenticatorData 与 Signature
+type WebAuthnCredentialResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" validate:"required"`
+	AttestationObject string   `json:"attestationObject"`
+	AuthenticatorData string   `json:"authenticatorData"`
+	Signature         string   `json:"signature"`
+	UserHandle        string   `json:"userHandle"`
+	Transports        []string `json:"transports" validate:"max=10"`
+}
+
+// WebAuthnCredential 浏览器 PublicKeyCredential.toJSON() 的结果
+type WebAuthnCredential struct {
+	ID       string                     `json:"id" validate:"required,max=1400"`
+	RawID    string                     `json:"rawId"`
+	Type     string                     `json:"type" validate:"required,eq=public-key"`
+	Response WebAuthnCredentialResponse `json:"response"`
+}
+
+type WebAuthnRegisterRequest struct {
+	Name       string             `json:"name" validate:"required,max=50"`
+	Credential WebAuthnCredential `json:"credential"`
+}
+
+type WebAuthnRenameRequest struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+type WebAuthnLoginRequest struct {
+	Credential WebAuthnCredential `json:"credential"`
+}
+
+type LoginMFAWebAuthnRequest struct {
+	MFAToken   string             `json:"mfa_token" validate:"required"`
+	Credential WebAuthnCredential `json:"credential"`
+}
+
+type PasskeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
@@ -0,0 +1,89 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type CreateWebhookRequest struct {
+	Name    string   `json:"name" validate:"required,min=1,max=100"`
+	URL     string   `json:"url" validate:"required,url,max=500"`
+	Events  []string `json:"events" validate:"required,min=1"`
+	Enabled *bool    `json:"enabled"` // 为空时启用
+}
+
+type UpdateWebhookRequest struct {
+	Name    *string  `json:"name" validate:"omitempty,min=1,max=100"`
+	URL     *string  `json:"url" validate:"omitempty,url,max=500"`
+	Events  []string `json:"events" validate:"omitempty,min=1"`
+	Enabled *bool    `json:"enabled"`
+}
+
+type WebhookResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateWebhookResponse 签名密钥只在创建时返回，之后无法再次查看
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uint            `json:"id"`
+	SubscriptionID uint            `json:"subscription_id"`
+	ClientID       string          `json:"client_id,omitempty"` // 后端通道登出通知的客户端，此时 subscription_id 为 0
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookEvent 推送给接收方的请求体，ID 在重试与各订阅之间保持不变，接收方据此去重
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Tenant    string    `json:"tenant"` // 事件所属租户的标识
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookUserData user.* 事件的数据，Changed 为本次变更的字段名，不含字段值
+type WebhookUserData struct {
+	UserID   uint     `json:"user_id"`
+	Subject  string   `json:"sub"`
+	Username string   `json:"username"`
+	Email    *string  `json:"email,omitempty"`
+	Status   int      `json:"status"`
+	Changed  []string `json:"changed,omitempty"`
+}
+
+// WebhookClientData oauth_client.* 事件的数据，Changed 为本次变更的字段名，不含字段值
+type WebhookClientData struct {
+	ClientID string   `json:"client_id"`
+	Name     string   `json:"name,omitempty"`
+	Status   *int     `json:"status,omitempty"`
+	Changed  []string `json:"changed,omitempty"`
+}
+
+// WebhookGrantData grant.revoked 事件的数据
+// 客户端撤销单个令牌时带有令牌类型与范围；批量撤销时每个客户端与用户的授权一条事件，不带令牌类型与范围，表示该授权下的令牌全部失效
+type WebhookGrantData struct {
+	ClientID  string `json:"client_id"`
+	UserID    *uint  `json:"user_id,omitempty"`    // 客户端凭证模式签发的令牌没有用户
+	TokenType string `json:"token_type,omitempty"` // 客户端撤销的令牌类型：access_token 或 refresh_token
+	Scope     string `json:"scope,omitempty"`
+	Reason    string `json:"reason,omitempty"` // 批量撤销的原因：client（客户端被禁用或删除）或 user（用户被停用、删除、改密或退出全部登录）
+}
@@ -1,6 +1,8 @@
 package initialize
 
 import (
+	"net/url"
+
 	"github.com/3086953492/gokit/cache"
 	"github.com/3086953492/gokit/config"
 	"github.com/3086953492/gokit/ginx/cookie"
@@ -13,13 +15,18 @@ import (
 	"github.com/3086953492/gokit/validator"
 	"gorm.io/gorm"
 
+	"goauth/appconfig"
 	"goauth/controllers"
 	"goauth/controllers/oauth"
+	"goauth/controllers/scim"
 	"goauth/middleware"
+	"goauth/models"
 	"goauth/repositories"
 	"goauth/repositories/oauth"
 	"goauth/services"
 	"goauth/services/oauth"
+	"goauth/services/scim"
+	"goauth/utils"
 	"goauth/validations"
 )
 
@@ -28,15 +35,74 @@ type Container struct {
 	CookieMgr      *cookie.TokenCookies
 	LogManager     *logger.Manager
 	SubjectManager *subject.Manager
+	TokenHasher    *utils.TokenHasher
+
+	AuditEventRepository *repositories.AuditEventRepository
+	AuditService         *services.AuditService
+	AuditController      *controllers.AuditController
+
+	WebhookSubscriptionRepository *repositories.WebhookSubscriptionRepository
+	WebhookDeliveryRepository     *repositories.WebhookDeliveryRepository
+	WebhookService                *services.WebhookService
+	WebhookDispatcher             *services.WebhookDispatcher
+	WebhookController             *controllers.WebhookController
 
 	UserRepository *repositories.UserRepository
 	UserService    *services.UserService
 	UserController *controllers.UserController
-	UserValidator  *validations.UserValidators
+	UserTokenEpoch *services.UserTokenEpoch
+
+	UserGroupRepository  *repositories.UserGroupRepository
+	RoleRepository       *repositories.RoleRepository
+	PermissionRepository *repositories.PermissionRepository
+	RBACService          *services.RBACService
+	GroupService         *services.GroupService
+	RBACController       *controllers.RBACController
+	PolicyEngine         *services.PolicyEngine
+	PolicyController     *controllers.PolicyController
+
+	TenantRepository *repositories.TenantRepository
+	TenantService    *services.TenantService
+	TenantController *controllers.TenantController
+
+	PasswordHistoryRepository *repositories.PasswordHistoryRepository
+	PasswordPolicy            *services.PasswordPolicy
+	PasswordValidator         *validations.PasswordValidators
 
 	AuthService    *services.AuthService
 	AuthController *controllers.AuthController
 
+	SessionRepository      *repositories.SessionRepository
+	RefreshTokenRepository *repositories.RefreshTokenRepository
+	SessionService         *services.SessionService
+	SessionController      *controllers.SessionController
+
+	UserTOTPRepository     *repositories.UserTOTPRepository
+	RecoveryCodeRepository *repositories.RecoveryCodeRepository
+	MFAService             *services.MFAService
+	MFAChallengeStore      *services.MFAChallengeStore
+	MFAController          *controllers.MFAController
+
+	WebAuthnCredentialRepository *repositories.WebAuthnCredentialRepository
+	WebAuthnService              *services.WebAuthnService
+	WebAuthnController           *controllers.WebAuthnController
+
+	LoginProtectionService    *services.LoginProtectionService
+	LoginProtectionController *controllers.LoginProtectionController
+
+	AccountTokenManager *services.AccountTokenManager
+	AccountService      *services.AccountService
+	AccountController   *controllers.AccountController
+
+	ActivationService    *services.ActivationService
+	ActivationController *controllers.ActivationController
+
+	FederatedIdentityRepository *repositories.FederatedIdentityRepository
+	FederationService           *services.FederationService
+	FederationController        *controllers.FederationController
+
+	AuthenticatorChain *services.AuthenticatorChain
+
 	OAuthClientRepository *oauthrepositories.OAuthClientRepository
 	OAuthClientService    *oauthservices.OAuthClientService
 	OAuthClientController *oauthcontrollers.OAuthClientController
@@ -47,6 +113,10 @@ type Container struct {
 
 	OAuthRefreshTokenRepository *oauthrepositories.OAuthRefreshTokenRepository
 	OAuthAccessTokenRepository  *oauthrepositories.OAuthAccessTokenRepository
+	AccessTokenCache            *services.AccessTokenCache
+	OAuthTokenDenylist          *oauthservices.OAuthTokenDenylist
+	OAuthJanitorService         *oauthservices.OAuthJanitorService
+	OAuthAccessTokenService     *oauthservices.OAuthAccessTokenService
 	OAuthTokenService           *oauthservices.OAuthTokenService
 	OAuthTokenController        *oauthcontrollers.OAuthTokenController
 
@@ -59,55 +129,183 @@ type Container struct {
 	OAuthUserInfoService    *oauthservices.OAuthUserInfoService
 	OAuthUserInfoController *oauthcontrollers.OAuthUserInfoController
 
+	OIDCLogoutService     *oauthservices.OIDCLogoutService
+	OAuthLogoutController *oauthcontrollers.OAuthLogoutController
+
+	SAMLServiceProviderRepository *oauthrepositories.SAMLServiceProviderRepository
+	SAMLServiceProviderService    *oauthservices.SAMLServiceProviderService
+	SAMLServiceProviderController *oauthcontrollers.SAMLServiceProviderController
+	SAMLIdentityProviderService   *oauthservices.SAMLIdentityProviderService
+	SAMLController                *oauthcontrollers.SAMLController
+
+	SCIMUserService  *scimservices.SCIMUserService
+	SCIMGroupService *scimservices.SCIMGroupService
+	SCIMBulkService  *scimservices.SCIMBulkService
+	SCIMController   *scimcontrollers.SCIMController
+
 	ValidatorManager *validator.Manager
 
 	MiddlewareManager *middleware.Manager
 }
 
-func NewContainer(db *gorm.DB, storageManager *storage.Manager, validatorManager *validator.Manager, redisMgr *redis.Manager, cacheMgr *cache.Manager, jwtMgr *jwt.Manager, logMgr *logger.Manager, passwordMgr *password.Manager, subjectMgr *subject.Manager, cookieMgr *cookie.TokenCookies, cfg *config.Config) *Container {
+func NewContainer(db *gorm.DB, storageManager *storage.Manager, validatorManager *validator.Manager, redisMgr *redis.Manager, cacheMgr *cache.Manager, tokenCacheMgr *cache.Manager, jwtMgr *jwt.Manager, logMgr *logger.Manager, passwordMgr *password.Manager, subjectMgr *subject.Manager, cookieMgr *cookie.TokenCookies, tokenHasher *utils.TokenHasher, secretBox *utils.SecretBox, breachedChecker *utils.BreachedPasswordChecker, mailSender utils.MailSender, auditSink utils.AuditSink, samlKeyPair *utils.SigningKeyPair, cfg *config.Config, appCfg *appconfig.Config) *Container {
 	c := &Container{}
 
 	c.LogManager = logMgr
 	c.CookieMgr = cookieMgr
 	c.SubjectManager = subjectMgr
+	c.TokenHasher = tokenHasher
+
+	c.AuditEventRepository = repositories.NewAuditEventRepository(db)
+	c.AuditService = services.NewAuditService(c.AuditEventRepository, auditSink, c.LogManager)
+	c.AuditController = controllers.NewAuditController(c.AuditService)
+
+	c.WebhookSubscriptionRepository = repositories.NewWebhookSubscriptionRepository(db)
+	c.WebhookDeliveryRepository = repositories.NewWebhookDeliveryRepository(db)
+	c.WebhookService = services.NewWebhookService(c.WebhookSubscriptionRepository, c.WebhookDeliveryRepository, secretBox, c.LogManager, c.AuditService)
+	c.WebhookDispatcher = services.NewWebhookDispatcher(c.WebhookSubscriptionRepository, c.WebhookDeliveryRepository, secretBox, redisMgr, c.LogManager, appCfg.Webhook)
+	c.WebhookController = controllers.NewWebhookController(c.WebhookService, validatorManager)
+
+	// 访问令牌查询缓存使用不带本地缓存的 tokenCacheMgr，保证撤销后各实例立即失效
+	c.OAuthAccessTokenRepository = oauthrepositories.NewOAuthAccessTokenRepository(db)
+	c.AccessTokenCache = services.NewAccessTokenCache(tokenCacheMgr, c.OAuthAccessTokenRepository, c.LogManager)
+
+	c.PasswordHistoryRepository = repositories.NewPasswordHistoryRepository(db)
+	c.PasswordPolicy = services.NewPasswordPolicy(c.PasswordHistoryRepository, passwordMgr, breachedChecker, c.LogManager, appCfg.PasswordPolicy)
+	c.PasswordValidator = validations.NewPasswordValidators(c.PasswordPolicy)
+
+	// 角色与权限先于用户服务创建，新账号在创建事务中分配默认角色
+	c.UserGroupRepository = repositories.NewUserGroupRepository(db)
+	c.RoleRepository = repositories.NewRoleRepository(db)
+	c.PermissionRepository = repositories.NewPermissionRepository(db)
+	c.RBACService = services.NewRBACService(c.RoleRepository, c.PermissionRepository, c.UserGroupRepository, cacheMgr, c.LogManager, c.AuditService)
 
 	c.UserRepository = repositories.NewUserRepository(db)
-	c.UserService = services.NewUserService(c.UserRepository, storageManager, redisMgr, cacheMgr, c.LogManager, passwordMgr, subjectMgr)
+	c.UserService = services.NewUserService(c.UserRepository, storageManager, redisMgr, cacheMgr, c.LogManager, passwordMgr, subjectMgr, c.PasswordPolicy, c.RBACService, c.AuditService, c.WebhookService, appCfg.Account.ActivationMode)
+	c.UserTokenEpoch = services.NewUserTokenEpoch(redisMgr, c.UserRepository, c.LogManager)
 	c.UserController = controllers.NewUserController(c.UserService, validatorManager)
-	c.UserValidator = validations.NewUserValidators(c.UserService)
+
+	c.GroupService = services.NewGroupService(c.UserGroupRepository, c.UserRepository, c.RBACService, c.LogManager)
+	c.RBACController = controllers.NewRBACController(c.RBACService, c.GroupService, c.UserService, validatorManager)
+	c.PolicyEngine = services.NewPolicyEngine(appCfg.Authorization, c.RBACService)
+	c.PolicyController = controllers.NewPolicyController(c.PolicyEngine, validatorManager)
 
 	c.JwtManager = jwtMgr
 	c.JwtManager.SetExtraResolver(c.UserService)
 
-	c.AuthService = services.NewAuthService(c.UserRepository, c.UserService, c.LogManager, c.JwtManager, passwordMgr, cfg)
-	c.AuthController = controllers.NewAuthController(c.AuthService, validatorManager, c.CookieMgr)
+	// 默认租户使用配置文件中的签名，其他租户的签名由租户服务按租户创建
+	// 租户缓存使用 tokenCacheMgr，租户被停用后各实例立即停止解析该租户
+	c.TenantRepository = repositories.NewTenantRepository(db)
+	c.TenantService = services.NewTenantService(c.TenantRepository, c.RoleRepository, c.UserService, secretBox, tokenCacheMgr, c.LogManager, c.JwtManager, c.AuditService, cfg)
+	c.TenantController = controllers.NewTenantController(c.TenantService, validatorManager)
+
+	c.SessionRepository = repositories.NewSessionRepository(db)
+	c.RefreshTokenRepository = repositories.NewRefreshTokenRepository(db)
+	c.SessionService = services.NewSessionService(c.SessionRepository, c.RefreshTokenRepository, redisMgr, c.TokenHasher, c.LogManager, c.AuditService, cfg.AuthToken.RefreshTokenExpire)
+	c.SessionController = controllers.NewSessionController(c.SessionService, c.CookieMgr)
+
+	c.UserTOTPRepository = repositories.NewUserTOTPRepository(db)
+	c.RecoveryCodeRepository = repositories.NewRecoveryCodeRepository(db)
+	c.MFAService = services.NewMFAService(c.UserRepository, c.UserTOTPRepository, c.RecoveryCodeRepository, c.UserService, secretBox, c.TokenHasher, passwordMgr, c.LogManager, c.AuditService, appCfg.MFA.Issuer)
+	c.MFAChallengeStore = services.NewMFAChallengeStore(redisMgr, c.TokenHasher, c.LogManager, appCfg.MFA.ChallengeTTL, appCfg.MFA.MaxAttempts)
+	c.MFAController = controllers.NewMFAController(c.MFAService, validatorManager)
+
+	// 通行密钥的 RP ID 与允许来源未配置时使用前端地址
+	webAuthnCfg := appCfg.WebAuthn
+	if frontendURL, err := url.Parse(cfg.Server.FrontendURL); err == nil {
+		if webAuthnCfg.RPID == "" {
+			webAuthnCfg.RPID = frontendURL.Hostname()
+		}
+		if len(webAuthnCfg.Origins) == 0 {
+			webAuthnCfg.Origins = []string{frontendURL.Scheme + "://" + frontendURL.Host}
+		}
+	}
+	c.WebAuthnCredentialRepository = repositories.NewWebAuthnCredentialRepository(db)
+	c.WebAuthnService = services.NewWebAuthnService(c.WebAuthnCredentialRepository, c.UserRepository, redisMgr, c.TokenHasher, c.LogManager, webAuthnCfg)
+	c.WebAuthnController = controllers.NewWebAuthnController(c.WebAuthnService, validatorManager)
+
+	c.LoginProtectionService = services.NewLoginProtectionService(redisMgr, c.UserRepository, c.TokenHasher, c.LogManager, c.AuditService, appCfg.LoginProtection)
+	c.LoginProtectionController = controllers.NewLoginProtectionController(c.LoginProtectionService)
+
+	c.AccountTokenManager = services.NewAccountTokenManager(redisMgr, c.TokenHasher, c.LogManager)
+	c.AccountService = services.NewAccountService(c.UserRepository, c.UserService, c.AccountTokenManager, mailSender, c.LogManager, appCfg.Account, cfg.Server.FrontendURL)
+	c.AccountController = controllers.NewAccountController(c.AccountService, validatorManager)
+	c.UserService.SetEmailVerifier(c.AccountService)
+
+	c.ActivationService = services.NewActivationService(c.UserRepository, c.UserService, mailSender, c.LogManager, cfg.Server.FrontendURL)
+	c.ActivationController = controllers.NewActivationController(c.ActivationService, validatorManager)
+
+	c.FederatedIdentityRepository = repositories.NewFederatedIdentityRepository(db)
+	c.FederationService = services.NewFederationService(c.FederatedIdentityRepository, c.UserRepository, c.UserService, redisMgr, c.TokenHasher, c.LogManager, appCfg.Federation, cfg.Server.BaseURL, cfg.Server.FrontendURL)
+	c.FederationController = controllers.NewFederationController(c.FederationService, validatorManager, cfg)
+
+	// 启用目录认证时先校验目录，本地密码是否参与由回退策略决定
+	var authenticators []services.PasswordAuthenticator
+	if appCfg.LDAP.Enabled {
+		authenticators = append(authenticators, services.NewLDAPAuthenticator(c.UserRepository, c.UserService, c.RBACService, c.LogManager, appCfg.LDAP))
+	}
+	authenticators = append(authenticators, services.NewLocalAuthenticator(c.UserRepository, passwordMgr, c.LogManager))
+	c.AuthenticatorChain = services.NewAuthenticatorChain(authenticators, appCfg.LDAP.LocalFallback, c.LogManager)
 
+	c.AuthService = services.NewAuthService(c.UserRepository, c.UserService, c.RBACService, c.UserTokenEpoch, c.SessionService, c.MFAService, c.MFAChallengeStore, c.WebAuthnService, c.FederationService, c.LoginProtectionService, c.PasswordPolicy, c.LogManager, c.TenantService, c.AuthenticatorChain, c.AuditService, cfg)
+	c.AuthController = controllers.NewAuthController(c.AuthService, c.SessionService, validatorManager, c.CookieMgr)
+
+	// 令牌撤销服务不依赖客户端服务，先行创建，供客户端禁用或删除时级联撤销
+	c.OAuthAuthorizationCodeRepository = oauthrepositories.NewOAuthAuthorizationCodeRepository(db)
+	c.OAuthRefreshTokenRepository = oauthrepositories.NewOAuthRefreshTokenRepository(db)
+	c.OAuthTokenDenylist = oauthservices.NewOAuthTokenDenylist(redisMgr)
+	c.OAuthRevokeService = oauthservices.NewOAuthRevokeService(db, c.OAuthAccessTokenRepository, c.OAuthRefreshTokenRepository, c.OAuthTokenDenylist, c.AccessTokenCache, c.UserTokenEpoch, c.TokenHasher, c.LogManager, c.AuditService, c.WebhookService)
+	c.UserService.SetTokenRevoker(c.OAuthRevokeService)
+	c.MFAService.SetTokenRevoker(c.OAuthRevokeService)
+	c.OAuthJanitorService = oauthservices.NewOAuthJanitorService(c.OAuthAuthorizationCodeRepository, c.OAuthAccessTokenRepository, c.OAuthRefreshTokenRepository, c.RefreshTokenRepository, c.WebhookDeliveryRepository, redisMgr, c.LogManager, appCfg.Janitor)
+
+	// 客户端缓存同样使用 tokenCacheMgr，客户端被禁用或删除后各实例立即停止为其签发与接受令牌
 	c.OAuthClientRepository = oauthrepositories.NewOAuthClientRepository(db)
-	c.OAuthClientService = oauthservices.NewOAuthClientService(c.OAuthClientRepository, cacheMgr, c.LogManager)
+	c.OAuthClientService = oauthservices.NewOAuthClientService(c.OAuthClientRepository, tokenCacheMgr, c.OAuthRevokeService, c.LogManager, c.AuditService, c.WebhookService)
 	c.OAuthClientController = oauthcontrollers.NewOAuthClientController(c.OAuthClientService, validatorManager)
 
-	c.OAuthAuthorizationCodeRepository = oauthrepositories.NewOAuthAuthorizationCodeRepository(db)
-	c.OAuthAuthorizeService = oauthservices.NewOAuthAuthorizeService(c.OAuthAuthorizationCodeRepository, c.OAuthClientService, c.LogManager)
+	c.OAuthAuthorizeService = oauthservices.NewOAuthAuthorizeService(c.OAuthAuthorizationCodeRepository, c.OAuthClientService, c.TokenHasher, c.LogManager)
 	c.OAuthAuthorizeController = oauthcontrollers.NewOAuthAuthorizeController(c.OAuthAuthorizeService, c.OAuthClientService, cfg)
 
-	c.OAuthAccessTokenRepository = oauthrepositories.NewOAuthAccessTokenRepository(db)
-	c.OAuthRefreshTokenRepository = oauthrepositories.NewOAuthRefreshTokenRepository(db)
+	// 访问令牌受众未配置时使用服务地址
+	audience := appCfg.OAuth.Audience
+	if audience == "" {
+		audience = cfg.Server.BaseURL
+	}
+	c.OAuthAccessTokenService = oauthservices.NewOAuthAccessTokenService(c.AccessTokenCache, c.UserTokenEpoch, c.OAuthClientService, c.TenantService, c.OAuthTokenDenylist, c.TokenHasher, c.LogManager, appCfg.OAuth.BearerMode, audience)
 
-	c.OAuthRevokeService = oauthservices.NewOAuthRevokeService(db, c.OAuthAccessTokenRepository, c.OAuthRefreshTokenRepository, c.LogManager)
 	c.OAuthRevokeController = oauthcontrollers.NewOAuthRevokeController(c.OAuthRevokeService, c.OAuthClientService)
 
-	c.OAuthTokenService = oauthservices.NewOAuthTokenService(db, c.OAuthAccessTokenRepository, c.OAuthRefreshTokenRepository, c.OAuthAuthorizeService, c.OAuthRevokeService, c.UserService, c.OAuthClientService, c.LogManager)
+	c.OAuthTokenService = oauthservices.NewOAuthTokenService(db, c.OAuthAccessTokenRepository, c.OAuthRefreshTokenRepository, c.OAuthAuthorizeService, c.OAuthRevokeService, c.UserService, c.RBACService, c.OAuthClientService, c.TenantService, c.AuditService, c.TokenHasher, c.LogManager, audience)
 	c.OAuthTokenController = oauthcontrollers.NewOAuthTokenController(c.OAuthTokenService, c.OAuthClientService)
 
-	c.OAuthIntrospectService = oauthservices.NewOAuthIntrospectService(c.OAuthAccessTokenRepository, c.UserService)
+	c.OAuthIntrospectService = oauthservices.NewOAuthIntrospectService(c.AccessTokenCache, c.UserService, c.OAuthClientService, c.TokenHasher)
 	c.OAuthIntrospectController = oauthcontrollers.NewOAuthIntrospectController(c.OAuthIntrospectService, c.OAuthClientService)
 
-	c.OAuthUserInfoService = oauthservices.NewOAuthUserInfoService(c.UserService)
+	c.OAuthUserInfoService = oauthservices.NewOAuthUserInfoService(c.UserService, c.RBACService)
 	c.OAuthUserInfoController = oauthcontrollers.NewOAuthUserInfoController(c.OAuthUserInfoService, c.OAuthIntrospectService)
 
+	// 登出服务依赖授权服务，创建后注入会话服务，用户退出登录时通知已授权的客户端
+	c.OIDCLogoutService = oauthservices.NewOIDCLogoutService(c.OAuthAccessTokenRepository, c.OAuthRefreshTokenRepository, c.OAuthClientService, c.AuthService, c.UserService, c.TenantService, c.WebhookService, c.AuditService, c.TokenHasher, c.LogManager, appCfg.Logout)
+	c.SessionService.SetLogoutNotifier(c.OIDCLogoutService)
+	c.WebhookDispatcher.SetHandler(models.WebhookBackchannelLogout, c.OIDCLogoutService)
+	c.OAuthLogoutController = oauthcontrollers.NewOAuthLogoutController(c.OIDCLogoutService, c.CookieMgr, cfg)
+
+	c.SAMLServiceProviderRepository = oauthrepositories.NewSAMLServiceProviderRepository(db)
+	c.SAMLServiceProviderService = oauthservices.NewSAMLServiceProviderService(c.SAMLServiceProviderRepository, c.LogManager, cfg.Server.FrontendURL)
+	c.SAMLServiceProviderController = oauthcontrollers.NewSAMLServiceProviderController(c.SAMLServiceProviderService, validatorManager)
+	c.SAMLIdentityProviderService = oauthservices.NewSAMLIdentityProviderService(c.SAMLServiceProviderRepository, c.SessionRepository, c.UserService, c.RBACService, c.TenantService, redisMgr, c.TokenHasher, c.LogManager, appCfg.SAML, samlKeyPair, cfg.Server.BaseURL, cfg.Server.FrontendURL)
+	c.SAMLController = oauthcontrollers.NewSAMLController(c.SAMLIdentityProviderService, cfg)
+
+	scimBaseURL := cfg.Server.BaseURL
+	c.SCIMUserService = scimservices.NewSCIMUserService(c.UserService, c.UserRepository, c.UserGroupRepository, c.LogManager, scimBaseURL)
+	c.SCIMGroupService = scimservices.NewSCIMGroupService(c.UserGroupRepository, c.UserRepository, c.RBACService, c.LogManager, scimBaseURL)
+	c.SCIMBulkService = scimservices.NewSCIMBulkService(c.SCIMUserService, c.SCIMGroupService, c.LogManager)
+	c.SCIMController = scimcontrollers.NewSCIMController(c.SCIMUserService, c.SCIMGroupService, c.SCIMBulkService, scimBaseURL)
+
 	c.ValidatorManager = validatorManager
 
-	c.MiddlewareManager = middleware.NewManager(&cfg.Middleware, c.JwtManager, c.CookieMgr, c.OAuthAccessTokenRepository)
+	c.MiddlewareManager = middleware.NewManager(&cfg.Middleware, c.TenantService, c.CookieMgr, c.UserTokenEpoch, c.SessionService, c.RBACService, c.PolicyEngine, c.OAuthAccessTokenService, c.OAuthClientService, appCfg.Middleware.RateLimit, redisMgr, c.LogManager)
 
 	return c
 }
@@ -0,0 +1,183 @@
+package initialize
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"goauth/models"
+	"goauth/models/oauth"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// legacyTokenColumn 描述一张需要从明文列迁移到摘要列的令牌表
+type legacyTokenColumn struct {
+	model      any
+	legacy     string // 旧版明文列
+	hashField  string // 新摘要列对应的结构体字段
+	hashColumn string // 新摘要列
+}
+
+// migrateBatchSize 每批重算摘要的行数
+const migrateBatchSize = 500
+
+// MigrateLegacyTokens 将旧版明文令牌列迁移为摘要列
+// 旧版本直接保存令牌原文，升级时逐批计算摘要写入新列，随后删除明文列，旧令牌在升级后依旧可用
+// 必须在 AutoMigrate 之前执行：摘要列的唯一索引要等所有行都回填完再由 AutoMigrate 创建
+func MigrateLegacyTokens(db *gorm.DB, hasher *utils.TokenHasher) error {
+	tables := []legacyTokenColumn{
+		{model: &oauthmodels.OAuthAccessToken{}, legacy: "access_token", hashField: "TokenHash", hashColumn: "token_hash"},
+		{model: &oauthmodels.OAuthRefreshToken{}, legacy: "refresh_token", hashField: "TokenHash", hashColumn: "token_hash"},
+		{model: &oauthmodels.OAuthAuthorizationCode{}, legacy: "code", hashField: "CodeHash", hashColumn: "code_hash"},
+	}
+
+	for _, t := range tables {
+		if err := migrateLegacyTokenColumn(db, hasher, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateLegacyTokenColumn(db *gorm.DB, hasher *utils.TokenHasher, t legacyTokenColumn) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(t.model) || !migrator.HasColumn(t.model, t.legacy) {
+		return nil // 新部署或已迁移
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(t.model); err != nil {
+		return fmt.Errorf("解析模型失败: %w", err)
+	}
+	table := stmt.Schema.Table
+
+	if !migrator.HasColumn(t.model, t.hashColumn) {
+		if err := migrator.AddColumn(t.model, t.hashField); err != nil {
+			return fmt.Errorf("添加摘要列 %s.%s 失败: %w", table, t.hashColumn, err)
+		}
+	}
+
+	// 逐批回填摘要，包含已软删除的行
+	for {
+		var rows []struct {
+			ID    uint
+			Token string
+		}
+		if err := db.Table(table).
+			Select("id, "+t.legacy+" AS token").
+			Where(t.hashColumn+" = ? OR "+t.hashColumn+" IS NULL", "").
+			Limit(migrateBatchSize).
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("读取 %s 旧令牌失败: %w", table, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				if err := tx.Table(table).Where("id = ?", row.ID).Update(t.hashColumn, hasher.Hash(row.Token)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("回填 %s 令牌摘要失败: %w", table, err)
+		}
+	}
+
+	// 明文列（连同其唯一索引）不再保留
+	if err := migrator.DropColumn(t.model, t.legacy); err != nil {
+		return fmt.Errorf("删除 %s.%s 明文列失败: %w", table, t.legacy, err)
+	}
+	return nil
+}
+
+// MigrateTenancy 创建默认租户，并删除升级前按全局唯一建立的用户名、邮箱、角色名与外部身份索引
+// 必须在 AutoMigrate 之后、SeedRBAC 之前执行：新增的 tenant_id 列默认值即默认租户，升级前的数据全部归入默认租户
+func MigrateTenancy(db *gorm.DB) error {
+	tenant := models.Tenant{ID: models.DefaultTenantID, Name: models.DefaultTenantName, DisplayName: "默认租户", Status: models.TenantStatusActive}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tenant).Error; err != nil {
+		return fmt.Errorf("创建默认租户失败: %w", err)
+	}
+
+	legacy := []struct {
+		model any
+		index string
+	}{
+		{model: &models.User{}, index: "idx_users_username"},
+		{model: &models.User{}, index: "idx_users_email"},
+		{model: &models.Role{}, index: "idx_roles_name"},
+		{model: &models.FederatedIdentity{}, index: "idx_federated_identity_issuer_subject"},
+	}
+	migrator := db.Migrator()
+	for _, l := range legacy {
+		if !migrator.HasIndex(l.model, l.index) {
+			continue // 新部署或已迁移
+		}
+		if err := migrator.DropIndex(l.model, l.index); err != nil {
+			return fmt.Errorf("删除索引 %s 失败: %w", l.index, err)
+		}
+	}
+	return nil
+}
+
+// SeedRBAC 同步代码中定义的权限，并为每个租户创建内置角色；管理员角色始终拥有全部权限
+// 必须在 MigrateTenancy 之后执行，每次启动都会执行，已存在的记录只更新描述
+func SeedRBAC(db *gorm.DB) error {
+	var tenants []models.Tenant
+	if err := db.Order("id ASC").Find(&tenants).Error; err != nil {
+		return fmt.Errorf("查询租户失败: %w", err)
+	}
+
+	roleRepository := repositories.NewRoleRepository(db)
+	return db.Transaction(func(tx *gorm.DB) error {
+		permissions := make([]models.Permission, len(models.Permissions))
+		copy(permissions, models.Permissions)
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(&permissions).Error; err != nil {
+			return fmt.Errorf("同步权限失败: %w", err)
+		}
+
+		// 新增的权限自动授予各租户的管理员
+		for i := range tenants {
+			ctx := utils.WithTenant(context.Background(), &tenants[i])
+			if err := roleRepository.SeedBuiltinWithTx(ctx, tx); err != nil {
+				return fmt.Errorf("创建租户 %s 的内置角色失败: %w", tenants[i].Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateLegacyRoles 将旧版用户表的 role 列迁移为角色分配，随后删除该列
+// 必须在 SeedRBAC 之后执行：旧值 admin、user 对应内置角色，其他取值没有对应角色，迁移后账号不再拥有角色
+func MigrateLegacyRoles(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&models.User{}, "role") {
+		return nil // 新部署或已迁移
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 包含已软删除的用户，恢复后保留原有角色
+		if err := tx.Exec(
+			"INSERT INTO user_roles (user_id, role_id, created_at) "+
+				"SELECT users.id, roles.id, ? FROM users JOIN roles ON roles.tenant_id = users.tenant_id AND roles.name = users.role "+
+				"WHERE NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = users.id AND ur.role_id = roles.id)",
+			time.Now(),
+		).Error; err != nil {
+			return fmt.Errorf("迁移用户角色失败: %w", err)
+		}
+		if err := tx.Migrator().DropColumn(&models.User{}, "role"); err != nil {
+			return fmt.Errorf("删除 users.role 列失败: %w", err)
+		}
+		return nil
+	})
+}
@@ -1,13 +1,19 @@
 package initialize
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 
 	"goauth/routers"
 	"goauth/routers/oauth"
+	"goauth/routers/scim"
+	"goauth/services"
 )
 
-func InitRouters(container *Container) *gin.Engine {
+// InitRouters 注册全部路由，并把授权策略绑定到已注册的路由上
+// 返回的处理器同时接受 /realms/{name} 前缀，按前缀访问对应租户
+func InitRouters(container *Container) (http.Handler, error) {
 	router := gin.Default()
 
 	container.MiddlewareManager.LoadGlobal(router)
@@ -15,13 +21,40 @@ func InitRouters(container *Container) *gin.Engine {
 	// 注册路由
 	routers.LoadAuthRoutes(router, container.AuthController, container.MiddlewareManager)
 	routers.LoadUserRoutes(router, container.UserController, container.MiddlewareManager)
+	routers.LoadSessionRoutes(router, container.SessionController, container.MiddlewareManager)
+	routers.LoadMFARoutes(router, container.MFAController, container.MiddlewareManager)
+	routers.LoadWebAuthnRoutes(router, container.WebAuthnController, container.MiddlewareManager)
+	routers.LoadLoginProtectionRoutes(router, container.LoginProtectionController, container.MiddlewareManager)
+	routers.LoadAccountRoutes(router, container.AccountController, container.MiddlewareManager)
+	routers.LoadActivationRoutes(router, container.ActivationController, container.MiddlewareManager)
+	routers.LoadFederationRoutes(router, container.FederationController, container.MiddlewareManager)
+	routers.LoadRBACRoutes(router, container.RBACController, container.MiddlewareManager)
+	routers.LoadPolicyRoutes(router, container.PolicyController, container.MiddlewareManager)
+	routers.LoadTenantRoutes(router, container.TenantController, container.MiddlewareManager)
+	routers.LoadAuditRoutes(router, container.AuditController, container.MiddlewareManager)
+	routers.LoadWebhookRoutes(router, container.WebhookController, container.MiddlewareManager)
 
 	oauthrouters.LoadOAuthClientRoutes(router, container.OAuthClientController, container.MiddlewareManager)
 	oauthrouters.LoadOAuthAuthorizeRoutes(router, container.OAuthAuthorizeController, container.MiddlewareManager)
 	oauthrouters.LoadOAuthIntrospectRoutes(router, container.OAuthIntrospectController, container.MiddlewareManager)
 	oauthrouters.LoadOAuthTokenRoutes(router, container.OAuthTokenController, container.MiddlewareManager)
-	oauthrouters.LoadOAuthRevokeRoutes(router, container.OAuthRevokeController)
+	oauthrouters.LoadOAuthRevokeRoutes(router, container.OAuthRevokeController, container.MiddlewareManager)
 	oauthrouters.LoadOAuthUserInfoRoutes(router, container.OAuthUserInfoController)
+	oauthrouters.LoadOAuthLogoutRoutes(router, container.OAuthLogoutController, container.MiddlewareManager)
+	oauthrouters.LoadSAMLRoutes(router, container.SAMLController, container.MiddlewareManager)
+	oauthrouters.LoadSAMLServiceProviderRoutes(router, container.SAMLServiceProviderController, container.MiddlewareManager)
+
+	scimrouters.LoadSCIMRoutes(router, container.SCIMController, container.MiddlewareManager)
+
+	// 策略中的路由须在全部路由注册完成后解析
+	routes := router.Routes()
+	policyRoutes := make([]services.PolicyRoute, len(routes))
+	for i, route := range routes {
+		policyRoutes[i] = services.PolicyRoute{Method: route.Method, Path: route.Path}
+	}
+	if err := container.PolicyEngine.BindRoutes(policyRoutes); err != nil {
+		return nil, err
+	}
 
-	return router
+	return container.MiddlewareManager.Realms(router), nil
 }
@@ -10,9 +10,14 @@ func RegisterValidations(container *Container) error {
 
 	if err := container.ValidatorManager.RegisterRules([]validator.Rule{
 		{
-			Tag:     "username_unique",
-			Message: "用户名（{field}） “{value}” 已被占用",
-			Func:    container.UserValidator.UsernameUnique,
+			Tag:     "password_policy",
+			Message: "密码（{field}）不符合要求：" + container.PasswordPolicy.Describe(),
+			Func:    container.PasswordValidator.PasswordPolicy,
+		},
+		{
+			Tag:     "password_not_breached",
+			Message: "密码（{field}）已出现在公开泄露的密码中，请更换",
+			Func:    container.PasswordValidator.PasswordNotBreached,
 		},
 	}); err != nil {
 		return errors.New("注册自定义规则失败")
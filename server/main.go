@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"time"
@@ -22,9 +23,14 @@ import (
 	"github.com/3086953492/gokit/validator"
 	"gorm.io/driver/mysql"
 
+	"goauth/appconfig"
 	"goauth/initialize"
 	"goauth/models"
 	oauthmodels "goauth/models/oauth"
+	"goauth/repositories"
+	oauthrepositories "goauth/repositories/oauth"
+	oauthservices "goauth/services/oauth"
+	"goauth/utils"
 )
 
 func main() {
@@ -41,6 +47,11 @@ func main() {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
+	appCfg, err := appconfig.Load(mgr.ConfigPath())
+	if err != nil {
+		log.Fatalf("加载扩展配置失败: %v", err)
+	}
+
 	// 初始化日志
 	logMgr, err := logger.NewManager(logger.WithLevelString(cfg.Log.Level), logger.WithConsole(cfg.Server.Mode != "release"), logger.WithFile(logger.FileConfig{
 		Filename:       cfg.Log.Filename,
@@ -64,12 +75,60 @@ func main() {
 	}
 	defer dbManager.Close()
 
+	// 令牌摘要密钥未单独配置时回退到访问令牌密钥
+	tokenHashSecret := appCfg.Security.TokenHashSecret
+	if tokenHashSecret == "" {
+		logMgr.Warn("未配置 security.token_hash_secret，回退使用 auth_token.access_token_secret")
+		tokenHashSecret = cfg.AuthToken.AccessTokenSecret
+	}
+	tokenHasher, err := utils.NewTokenHasher(tokenHashSecret)
+	if err != nil {
+		logMgr.Error("初始化令牌摘要失败", "error", err)
+		return
+	}
+
+	// 敏感字段加密密钥未单独配置时回退到令牌摘要密钥
+	secretEncryptionKey := appCfg.Security.SecretEncryptionKey
+	if secretEncryptionKey == "" {
+		secretEncryptionKey = tokenHashSecret
+	}
+	secretBox, err := utils.NewSecretBox(secretEncryptionKey)
+	if err != nil {
+		logMgr.Error("初始化字段加密失败", "error", err)
+		return
+	}
+
+	// 旧版明文令牌迁移为摘要，须在 AutoMigrate 之前执行
+	if err := initialize.MigrateLegacyTokens(dbManager.DB(), tokenHasher); err != nil {
+		logMgr.Error("迁移旧版令牌失败", "error", err)
+		return
+	}
+
 	models := []any{
+		models.Tenant{},
 		models.User{},
+		models.Session{},
+		models.RefreshToken{},
+		models.UserTOTP{},
+		models.UserRecoveryCode{},
+		models.WebAuthnCredential{},
+		models.UserPasswordHistory{},
+		models.FederatedIdentity{},
+		models.UserGroup{},
+		models.UserGroupMember{},
+		models.Role{},
+		models.Permission{},
+		models.RolePermission{},
+		models.UserRole{},
+		models.UserGroupRole{},
 		oauthmodels.OAuthClient{},
 		oauthmodels.OAuthAuthorizationCode{},
 		oauthmodels.OAuthAccessToken{},
 		oauthmodels.OAuthRefreshToken{},
+		oauthmodels.SAMLServiceProvider{},
+		models.AuditEvent{},
+		models.WebhookSubscription{},
+		models.WebhookDelivery{},
 	}
 
 	if err := dbManager.AutoMigrate(models...); err != nil {
@@ -77,6 +136,22 @@ func main() {
 		return
 	}
 
+	// 创建默认租户，升级前的数据归入默认租户
+	if err := initialize.MigrateTenancy(dbManager.DB()); err != nil {
+		logMgr.Error("迁移租户数据失败", "error", err)
+		return
+	}
+
+	// 同步权限与内置角色，再将旧版 role 列迁移为角色分配
+	if err := initialize.SeedRBAC(dbManager.DB()); err != nil {
+		logMgr.Error("初始化角色与权限失败", "error", err)
+		return
+	}
+	if err := initialize.MigrateLegacyRoles(dbManager.DB()); err != nil {
+		logMgr.Error("迁移旧版用户角色失败", "error", err)
+		return
+	}
+
 	// 初始化 Redis
 	redisMgr := redis.NewManager(
 		redis.WithAddress(cfg.Redis.Host+":"+strconv.Itoa(cfg.Redis.Port)),
@@ -94,6 +169,26 @@ func main() {
 		return
 	}
 
+	// janitor 子命令：执行一次过期令牌清理后退出，便于通过 cron 等外部调度
+	if len(os.Args) > 1 && os.Args[1] == "janitor" {
+		db := dbManager.DB()
+		janitor := oauthservices.NewOAuthJanitorService(
+			oauthrepositories.NewOAuthAuthorizationCodeRepository(db),
+			oauthrepositories.NewOAuthAccessTokenRepository(db),
+			oauthrepositories.NewOAuthRefreshTokenRepository(db),
+			repositories.NewRefreshTokenRepository(db),
+			repositories.NewWebhookDeliveryRepository(db),
+			redisMgr, logMgr, appCfg.Janitor,
+		)
+		result, err := janitor.Run(context.Background())
+		if err != nil {
+			logMgr.Error("清理过期令牌失败", "error", err)
+			os.Exit(1)
+		}
+		fmt.Printf("authorization_codes=%d access_tokens=%d refresh_tokens=%d user_refresh_tokens=%d webhook_deliveries=%d\n", result.AuthorizationCodes, result.AccessTokens, result.RefreshTokens, result.UserRefreshTokens, result.WebhookDeliveries)
+		return
+	}
+
 	// 初始化缓存
 	cacheMgr, err := cache.NewManager(redisMgr,
 		cache.WithDefaultTTL(5*time.Minute),
@@ -105,6 +200,17 @@ func main() {
 	}
 	defer cacheMgr.Close()
 
+	// 访问令牌、OAuth 客户端与租户的查询缓存不使用本地缓存：撤销或禁用时只能清除 Redis 与当前实例，本地缓存会在其它实例上残留
+	tokenCacheMgr, err := cache.NewManager(redisMgr,
+		cache.WithDefaultTTL(5*time.Minute),
+		cache.WithLocalCache(false),
+	)
+	if err != nil {
+		logMgr.Error("初始化令牌缓存失败", "error", err)
+		return
+	}
+	defer tokenCacheMgr.Close()
+
 	jwtMgr, err := jwt.NewManager(jwt.WithAccessSecret(cfg.AuthToken.AccessTokenSecret),
 		jwt.WithRefreshSecret(cfg.AuthToken.RefreshTokenSecret),
 		jwt.WithIssuer(cfg.AuthToken.Issuer),
@@ -151,7 +257,112 @@ func main() {
 		return
 	}
 
-	container := initialize.NewContainer(dbManager.DB(), storageManager, validatorManager, redisMgr, cacheMgr, jwtMgr, logMgr, passwordMgr, subjectMgr, cookieMgr, &cfg)
+	// 泄露密码列表启动时一次性载入内存，关闭检查时不载入
+	var breachedChecker *utils.BreachedPasswordChecker
+	if appCfg.PasswordPolicy.BreachedCheck {
+		breachedChecker, err = utils.NewBreachedPasswordChecker(appCfg.PasswordPolicy.BreachedListPath)
+		if err != nil {
+			logMgr.Error("初始化泄露密码检查失败", "error", err)
+			return
+		}
+	}
+
+	// 未配置 SMTP 时邮件只保存在内存中，找回密码与邮箱验证邮件不会真正送达
+	var mailSender utils.MailSender
+	if appCfg.Mail.Driver == appconfig.MailDriverSMTP && appCfg.Mail.SMTP.Host != "" {
+		smtpCfg := appCfg.Mail.SMTP
+		mailSender, err = utils.NewSMTPMailSender(utils.SMTPOptions{
+			Host:     smtpCfg.Host,
+			Port:     smtpCfg.Port,
+			Username: smtpCfg.Username,
+			Password: smtpCfg.Password,
+			From:     appCfg.Mail.From,
+			FromName: appCfg.Mail.FromName,
+			TLS:      smtpCfg.TLS,
+			Timeout:  smtpCfg.Timeout,
+		})
+		if err != nil {
+			logMgr.Error("初始化邮件发送失败", "error", err)
+			return
+		}
+	} else {
+		if appCfg.Mail.Driver != appconfig.MailDriverMemory {
+			logMgr.Warn("未配置 mail.smtp.host，邮件只保存在内存中，不会真正发送")
+		}
+		mailSender = utils.NewMemoryMailSender()
+	}
+
+	// 激活方式写错时拒绝启动，避免误按 auto 放行所有注册
+	switch appCfg.Account.ActivationMode {
+	case appconfig.ActivationModeAuto, appconfig.ActivationModeEmail, appconfig.ActivationModeApproval:
+	default:
+		logMgr.Error("不支持的账号激活方式", "activation_mode", appCfg.Account.ActivationMode)
+		return
+	}
+	if appCfg.Account.ActivationMode == appconfig.ActivationModeEmail {
+		if _, ok := mailSender.(*utils.MemoryMailSender); ok {
+			logMgr.Warn("激活方式为 email 但未配置 SMTP，激活邮件不会真正发送")
+		}
+	}
+
+	// 身份提供方配置有误时拒绝启动，避免回调地址或身份命名空间在运行中出错
+	if err := appCfg.Federation.Validate(); err != nil {
+		logMgr.Error("外部身份提供方配置错误", "error", err)
+		return
+	}
+	if err := appCfg.LDAP.Validate(); err != nil {
+		logMgr.Error("LDAP 配置错误", "error", err)
+		return
+	}
+	if err := appCfg.SAML.Validate(); err != nil {
+		logMgr.Error("SAML 配置错误", "error", err)
+		return
+	}
+	if err := appCfg.Authorization.Validate(); err != nil {
+		logMgr.Error("授权策略配置错误", "error", err)
+		return
+	}
+	if err := appCfg.Audit.Validate(); err != nil {
+		logMgr.Error("审计配置错误", "error", err)
+		return
+	}
+	if err := appCfg.Webhook.Validate(); err != nil {
+		logMgr.Error("Webhook 配置错误", "error", err)
+		return
+	}
+	if err := appCfg.Logout.Validate(); err != nil {
+		logMgr.Error("登出配置错误", "error", err)
+		return
+	}
+
+	// 审计事件始终写入数据库，另按配置转发到标准输出或文件供外部日志系统采集
+	var auditSink utils.AuditSink
+	switch appCfg.Audit.Sink {
+	case appconfig.AuditSinkStdout:
+		auditSink = utils.NewWriterAuditSink(os.Stdout)
+	case appconfig.AuditSinkFile:
+		fileSink, err := utils.NewFileAuditSink(appCfg.Audit.File)
+		if err != nil {
+			logMgr.Error("打开审计输出文件失败", "error", err, "file", appCfg.Audit.File)
+			return
+		}
+		auditSink = fileSink
+	}
+
+	// SAML 签名证书启动时载入，未启用时不载入
+	var samlKeyPair *utils.SigningKeyPair
+	if appCfg.SAML.Enabled {
+		samlKeyPair, err = utils.LoadSigningKeyPair(appCfg.SAML.CertificateFile, appCfg.SAML.KeyFile)
+		if err != nil {
+			logMgr.Error("载入 SAML 签名证书失败", "error", err)
+			return
+		}
+		if samlKeyPair.Expired() {
+			logMgr.Warn("SAML 签名证书已过期，服务提供方可能拒绝断言", "not_after", samlKeyPair.Certificate.NotAfter)
+		}
+	}
+
+	container := initialize.NewContainer(dbManager.DB(), storageManager, validatorManager, redisMgr, cacheMgr, tokenCacheMgr, jwtMgr, logMgr, passwordMgr, subjectMgr, cookieMgr, tokenHasher, secretBox, breachedChecker, mailSender, auditSink, samlKeyPair, &cfg, appCfg)
 
 	if err := initialize.RegisterValidations(container); err != nil {
 		logMgr.Error("注册自定义验证规则失败", "error", err)
@@ -167,10 +378,24 @@ func main() {
 		}
 	}
 
+	// 定时清理过期令牌，服务退出时停止
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	container.OAuthJanitorService.Start(janitorCtx)
+
+	// 定时投递 Webhook 事件，服务退出时停止
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	defer stopDispatcher()
+	container.WebhookDispatcher.Start(dispatcherCtx)
+
 	// 初始化 Gin 路由，传入容器
-	r := initialize.InitRouters(container)
+	r, err := initialize.InitRouters(container)
+	if err != nil {
+		logMgr.Error("授权策略配置错误", "error", err)
+		return
+	}
 
-	if err := r.Run(fmt.Sprintf(":%d", port)); err != nil {
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), r); err != nil {
 		logMgr.Error("启动服务失败", "port", port, "error", err)
 	}
 
@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/utils"
+)
+
+// RequestInfoMiddleware 把请求来源 IP 与 User-Agent 写入请求 context，服务层记录审计事件时读取
+// IP 取 gin 的 ClientIP，受 gin 可信代理配置约束，不直接信任 X-Forwarded-For
+func RequestInfoMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.WithRequestInfo(c.Request.Context(), utils.RequestInfo{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		}))
+		c.Next()
+	}
+}
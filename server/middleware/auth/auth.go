@@ -3,14 +3,14 @@ package auth
 import (
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/3086953492/gokit/ginx/cookie"
 	"github.com/3086953492/gokit/ginx/problem"
-	"github.com/3086953492/gokit/jwt"
+	"github.com/3086953492/gokit/logger"
 	"github.com/gin-gonic/gin"
 
-	"goauth/repositories/oauth"
+	"goauth/services"
+	"goauth/services/oauth"
 	"goauth/utils"
 )
 
@@ -24,38 +24,55 @@ const (
 
 // Principal 统一认证主体，写入 gin.Context
 type Principal struct {
-	Kind     PrincipalKind // user 或 client
-	UserID   uint64        // 用户ID（仅 user 主体有效）
-	ClientID string        // OAuth 客户端ID（仅 bearer 认证有效）
-	Scope    string        // OAuth scope（仅 bearer 认证有效）
-	Role     string        // 角色（仅 cookie 认证有效）
+	Kind        PrincipalKind // user 或 client
+	UserID      uint64        // 用户ID（仅 user 主体有效）
+	ClientID    string        // OAuth 客户端ID（仅 bearer 认证有效）
+	Scope       string        // OAuth scope（仅 bearer 认证有效）
+	Roles       []string      // 角色（仅 cookie 认证有效）
+	Permissions []string      // 权限（仅 cookie 认证有效）
+	SessionID   string        // 登录会话ID（仅 cookie 认证有效）
 }
 
 // setUserPrincipal 设置用户主体到 context
-func setUserPrincipal(c *gin.Context, userID uint64, role string) {
+func setUserPrincipal(c *gin.Context, userID uint64, authz *services.UserAuthorization, sessionID string) {
 	c.Set("principal_kind", string(PrincipalKindUser))
 	c.Set("user_id", userID)
-	c.Set("role", role)
+	c.Set("roles", authz.Roles)
+	c.Set("permissions", authz.Permissions)
 	c.Set("client_id", "")
 	c.Set("scope", "")
+	c.Set("session_id", sessionID)
+	setActor(c, utils.Actor{UserID: uint(userID)})
 }
 
 // setClientPrincipal 设置客户端主体到 context（client_credentials 模式，无用户）
 func setClientPrincipal(c *gin.Context, clientID, scope string) {
 	c.Set("principal_kind", string(PrincipalKindClient))
 	c.Set("user_id", uint64(0))
-	c.Set("role", "")
+	c.Set("roles", []string{})
+	c.Set("permissions", []string{})
 	c.Set("client_id", clientID)
 	c.Set("scope", scope)
+	c.Set("session_id", "")
+	setActor(c, utils.Actor{ClientID: clientID})
 }
 
 // setBearerUserPrincipal 设置 bearer token 的用户主体（授权码/刷新令牌模式）
+// 第三方应用代表用户访问时不具备用户的管理权限
 func setBearerUserPrincipal(c *gin.Context, userID uint64, clientID, scope string) {
 	c.Set("principal_kind", string(PrincipalKindUser))
 	c.Set("user_id", userID)
-	c.Set("role", "")
+	c.Set("roles", []string{})
+	c.Set("permissions", []string{})
 	c.Set("client_id", clientID)
 	c.Set("scope", scope)
+	c.Set("session_id", "")
+	setActor(c, utils.Actor{UserID: uint(userID), ClientID: clientID})
+}
+
+// setActor 把认证主体写入请求 context，服务层记录审计事件时据此确定操作者
+func setActor(c *gin.Context, actor utils.Actor) {
+	c.Request = c.Request.WithContext(utils.WithActor(c.Request.Context(), actor))
 }
 
 // ============================================================================
@@ -100,11 +117,14 @@ func BearerAllowAll() BearerOption {
 // AuthCookieMiddleware 仅 Cookie(JWT) 认证中间件
 // 不接受 Bearer token，仅校验内部 Cookie 令牌
 func AuthCookieMiddleware(
-	jwtManager *jwt.Manager,
+	tenantService *services.TenantService,
 	cookieMgr *cookie.TokenCookies,
+	userTokenEpoch *services.UserTokenEpoch,
+	sessionService *services.SessionService,
+	rbacService *services.RBACService,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if authenticateByCookie(c, jwtManager, cookieMgr) {
+		if authenticateByCookie(c, tenantService, cookieMgr, userTokenEpoch, sessionService, rbacService) {
 			c.Next()
 			return
 		}
@@ -112,6 +132,21 @@ func AuthCookieMiddleware(
 	}
 }
 
+// AuthCookieOptionalMiddleware 可选的 Cookie(JWT) 认证中间件
+// 未登录或令牌已失效时不拦截请求，以匿名身份继续处理（user_id 为 0），用于无论是否登录都须完成的浏览器跳转
+func AuthCookieOptionalMiddleware(
+	tenantService *services.TenantService,
+	cookieMgr *cookie.TokenCookies,
+	userTokenEpoch *services.UserTokenEpoch,
+	sessionService *services.SessionService,
+	rbacService *services.RBACService,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verifyCookie(c, tenantService, cookieMgr, userTokenEpoch, sessionService, rbacService)
+		c.Next()
+	}
+}
+
 // ============================================================================
 // BearerOrCookie 中间件（按组显式启用）
 // ============================================================================
@@ -120,9 +155,12 @@ func AuthCookieMiddleware(
 // 优先 Bearer，无 Bearer 时回退 Cookie
 // 通过 opts 配置允许的 Bearer 主体类型
 func AuthBearerOrCookieMiddleware(
-	jwtManager *jwt.Manager,
+	tenantService *services.TenantService,
 	cookieMgr *cookie.TokenCookies,
-	accessTokenRepo *oauthrepositories.OAuthAccessTokenRepository,
+	userTokenEpoch *services.UserTokenEpoch,
+	sessionService *services.SessionService,
+	rbacService *services.RBACService,
+	accessTokenService *oauthservices.OAuthAccessTokenService,
 	opts ...BearerOption,
 ) gin.HandlerFunc {
 	// 构建策略（默认不允许任何 Bearer）
@@ -135,7 +173,7 @@ func AuthBearerOrCookieMiddleware(
 		// 1. 优先尝试 Bearer 认证
 		authHeader := c.GetHeader("Authorization")
 		if token, found := strings.CutPrefix(authHeader, "Bearer "); found && token != "" {
-			if authenticateByBearerWithPolicy(c, token, accessTokenRepo, policy) {
+			if authenticateByBearerWithPolicy(c, token, accessTokenService, policy) {
 				c.Next()
 				return
 			}
@@ -144,7 +182,7 @@ func AuthBearerOrCookieMiddleware(
 		}
 
 		// 2. 回退到 Cookie 认证
-		if authenticateByCookie(c, jwtManager, cookieMgr) {
+		if authenticateByCookie(c, tenantService, cookieMgr, userTokenEpoch, sessionService, rbacService) {
 			c.Next()
 			return
 		}
@@ -152,32 +190,19 @@ func AuthBearerOrCookieMiddleware(
 	}
 }
 
-// authenticateByBearerWithPolicy 通过 Bearer token 认证（查库判活），并根据策略过滤主体类型
+// authenticateByBearerWithPolicy 通过 Bearer token 认证，并根据策略过滤主体类型
+// 校验方式（查库判活或本地验签）由 accessTokenService 的模式决定
 // 返回 true 表示认证成功，false 表示失败（已返回 401/403）
 func authenticateByBearerWithPolicy(
 	c *gin.Context,
 	token string,
-	accessTokenRepo *oauthrepositories.OAuthAccessTokenRepository,
+	accessTokenService *oauthservices.OAuthAccessTokenService,
 	policy *bearerPolicy,
 ) bool {
-	// 查询 access token
-	accessToken, err := accessTokenRepo.Get(c.Request.Context(), map[string]any{"access_token": token})
+	// 校验 access token（有效性、撤销、过期）
+	accessToken, err := accessTokenService.Verify(c.Request.Context(), token)
 	if err != nil {
-		problem.Fail(c, 401, "UNAUTHORIZED", "令牌无效", "about:blank")
-		c.Abort()
-		return false
-	}
-
-	// 检查是否已撤销
-	if accessToken.Revoked {
-		problem.Fail(c, 401, "UNAUTHORIZED", "令牌已撤销", "about:blank")
-		c.Abort()
-		return false
-	}
-
-	// 检查是否已过期
-	if accessToken.ExpiresAt.Before(time.Now()) {
-		problem.Fail(c, 401, "UNAUTHORIZED", "令牌已过期", "about:blank")
+		problem.Fail(c, 401, "UNAUTHORIZED", err.Error(), "about:blank")
 		c.Abort()
 		return false
 	}
@@ -208,149 +233,162 @@ func authenticateByBearerWithPolicy(
 }
 
 // authenticateByCookie 通过 Cookie 中的 JWT 认证
-// 返回 true 表示认证成功，false 表示失败（已返回 401）
+// 返回 true 表示认证成功，false 表示失败（已返回 401/503）
 func authenticateByCookie(
 	c *gin.Context,
-	jwtManager *jwt.Manager,
+	tenantService *services.TenantService,
 	cookieMgr *cookie.TokenCookies,
+	userTokenEpoch *services.UserTokenEpoch,
+	sessionService *services.SessionService,
+	rbacService *services.RBACService,
 ) bool {
+	if authErr := verifyCookie(c, tenantService, cookieMgr, userTokenEpoch, sessionService, rbacService); authErr != nil {
+		problem.Fail(c, authErr.status, authErr.title, authErr.message, "about:blank")
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// cookieAuthError Cookie 认证失败时的响应
+type cookieAuthError struct {
+	status  int
+	title   string
+	message string
+}
+
+func unauthorized(message string) *cookieAuthError {
+	return &cookieAuthError{status: 401, title: "UNAUTHORIZED", message: message}
+}
+
+func unavailable(message string) *cookieAuthError {
+	return &cookieAuthError{status: 503, title: "SERVICE_UNAVAILABLE", message: message}
+}
+
+// verifyCookie 校验 Cookie 中的 JWT 并设置用户主体，签发时间早于用户令牌纪元或所属会话已注销的令牌视为失效
+// 角色与权限按用户实时加载，分配变化后无需重新登录即生效；校验失败时不写响应，由调用方决定如何处理
+func verifyCookie(
+	c *gin.Context,
+	tenantService *services.TenantService,
+	cookieMgr *cookie.TokenCookies,
+	userTokenEpoch *services.UserTokenEpoch,
+	sessionService *services.SessionService,
+	rbacService *services.RBACService,
+) *cookieAuthError {
 	// 从 Cookie 中获取令牌
 	token, err := cookieMgr.GetAccess(c)
 	if err != nil || token == "" {
-		problem.Fail(c, 401, "UNAUTHORIZED", "令牌为空", "about:blank")
-		c.Abort()
-		return false
+		return unauthorized("令牌为空")
 	}
 
+	// 按请求所属租户的签名密钥验签，其他租户签发的令牌无法通过
+	jwtManager, err := tenantService.JwtManager(c.Request.Context())
+	if err != nil {
+		return unavailable(err.Error())
+	}
 	claims, err := jwtManager.ParseToken(token)
 	if err != nil {
-		problem.Fail(c, 401, "UNAUTHORIZED", "令牌验证失败", "about:blank")
-		c.Abort()
-		return false
+		return unauthorized("令牌验证失败")
 	}
 
 	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
 	if err != nil {
-		problem.Fail(c, 401, "UNAUTHORIZED", "用户ID格式错误", "about:blank")
-		c.Abort()
-		return false
+		return unauthorized("用户ID格式错误")
 	}
 
-	// 获取角色
-	role := ""
-	if r, ok := claims.Extra["role"]; ok {
-		if roleStr, ok := r.(string); ok {
-			role = roleStr
-		}
+	issuedAt, ok := utils.TokenIssuedAt(claims)
+	if !ok {
+		return unauthorized("令牌验证失败")
+	}
+	valid, err := userTokenEpoch.Valid(c.Request.Context(), uint(userID), issuedAt)
+	if err != nil || !valid {
+		return unauthorized("令牌已失效，请重新登录")
 	}
 
-	setUserPrincipal(c, userID, role)
-	return true
+	// 校验会话，不携带会话ID的旧令牌同样视为失效
+	sessionID, _ := claims.Extra["sid"].(string)
+	if err := sessionService.ValidateSession(c.Request.Context(), sessionID, uint(userID), c.ClientIP()); err != nil {
+		return unauthorized(err.Error())
+	}
+
+	// 获取角色与权限
+	authz, err := rbacService.GetUserAuthorization(c.Request.Context(), uint(userID))
+	if err != nil {
+		return unavailable(err.Error())
+	}
+
+	setUserPrincipal(c, userID, authz, sessionID)
+	return nil
 }
 
 // ============================================================================
-// 鉴权中间件
+// OAuth 客户端认证中间件
 // ============================================================================
 
-func RoleMiddleware(requiredRole string) gin.HandlerFunc {
+// ClientBasicAuthMiddleware 校验 Basic 认证携带的客户端凭证，通过后写入 authenticated_client_id
+// 供其后按客户端限流使用；客户端是否启用仍由各端点自行判断
+func ClientBasicAuthMiddleware(oauthClientService *oauthservices.OAuthClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !utils.IsRole(c, requiredRole) {
-			problem.Fail(c, 403, "FORBIDDEN", "无权限", "about:blank")
+		clientID, clientSecret, ok := c.Request.BasicAuth()
+		if !ok || clientID == "" || clientSecret == "" {
+			problem.Fail(c, 401, "INVALID_CLIENT", "非法的客户端凭证", "about:blank")
+			c.Abort()
+			return
+		}
+		if _, err := oauthClientService.GetOAuthClient(c.Request.Context(), map[string]any{"id": clientID, "client_secret": clientSecret}); err != nil {
+			problem.Fail(c, 401, "INVALID_CLIENT", "非法的客户端凭证", "about:blank")
 			c.Abort()
 			return
 		}
+		c.Set("authenticated_client_id", clientID)
 		c.Next()
 	}
 }
 
-func ResourceOwnerMiddleware(source string) gin.HandlerFunc {
+// ============================================================================
+// 鉴权中间件
+// ============================================================================
+
+// AuthorizeMiddleware 按路由绑定的授权策略鉴权，须放在认证中间件之后
+// 未绑定策略的路由一律拒绝；策略处于演练模式时只记录本应拒绝的请求
+func AuthorizeMiddleware(engine *services.PolicyEngine, logMgr *logger.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !utils.IsResourceOwner(c, source) {
+		name, ok := engine.PolicyFor(c.Request.Method, c.FullPath())
+		if !ok {
+			logMgr.Error("路由未绑定授权策略", "method", c.Request.Method, "path", c.FullPath())
 			problem.Fail(c, 403, "FORBIDDEN", "无权限", "about:blank")
 			c.Abort()
 			return
 		}
-		c.Next()
-	}
-}
 
-// ScopeMiddleware 检查 OAuth scope 是否包含所需权限
-func ScopeMiddleware(requiredScopes ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		scopeVal, exists := c.Get("scope")
-		if !exists {
-			// 没有 scope（可能是 cookie 认证），默认通过
+		subject := policySubject(c)
+		decision := engine.Evaluate(name, subject)
+		if decision.Allowed {
 			c.Next()
 			return
 		}
-
-		scope, ok := scopeVal.(string)
-		if !ok || scope == "" {
-			// scope 为空，检查是否需要 scope
-			if len(requiredScopes) > 0 {
-				problem.Fail(c, 403, "FORBIDDEN", "缺少必要的权限范围", "about:blank")
-				c.Abort()
-				return
-			}
+		if !decision.Enforced {
+			logMgr.Warn("授权策略演练：请求本应被拒绝", "policy", name, "method", c.Request.Method, "path", c.FullPath(),
+				"principal_kind", subject.Kind, "user_id", subject.UserID, "client_id", subject.ClientID)
 			c.Next()
 			return
 		}
-
-		// 解析当前 scope
-		currentScopes := strings.Split(scope, " ")
-		scopeSet := make(map[string]bool)
-		for _, s := range currentScopes {
-			s = strings.TrimSpace(s)
-			if s != "" {
-				scopeSet[s] = true
-			}
-		}
-
-		// 检查是否包含所有必需的 scope
-		for _, required := range requiredScopes {
-			if !scopeSet[required] {
-				problem.Fail(c, 403, "FORBIDDEN", "缺少必要的权限范围: "+required, "about:blank")
-				c.Abort()
-				return
-			}
-		}
-
-		c.Next()
+		problem.Fail(c, 403, "FORBIDDEN", "无权限访问该接口，未满足授权策略: "+name, "about:blank")
+		c.Abort()
 	}
 }
 
-// RequireUserMiddleware 要求当前主体必须是用户（非客户端）
-func RequireUserMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		kindVal, exists := c.Get("principal_kind")
-		if !exists {
-			problem.Fail(c, 403, "FORBIDDEN", "无法确定认证主体类型", "about:blank")
-			c.Abort()
-			return
-		}
-
-		kind, ok := kindVal.(string)
-		if !ok || kind != string(PrincipalKindUser) {
-			problem.Fail(c, 403, "FORBIDDEN", "此接口仅限用户访问", "about:blank")
-			c.Abort()
-			return
-		}
-
-		// 确保 user_id 有效
-		userIDVal, exists := c.Get("user_id")
-		if !exists {
-			problem.Fail(c, 403, "FORBIDDEN", "用户ID不存在", "about:blank")
-			c.Abort()
-			return
-		}
-
-		userID, ok := userIDVal.(uint64)
-		if !ok || userID == 0 {
-			problem.Fail(c, 403, "FORBIDDEN", "用户ID无效", "about:blank")
-			c.Abort()
-			return
-		}
-
-		c.Next()
+// policySubject 从认证中间件写入的主体信息构造授权主体
+func policySubject(c *gin.Context) services.PolicySubject {
+	subject := services.PolicySubject{
+		Kind:        c.GetString("principal_kind"),
+		UserID:      c.GetUint64("user_id"),
+		ClientID:    c.GetString("client_id"),
+		Scope:       c.GetString("scope"),
+		Roles:       c.GetStringSlice("roles"),
+		Permissions: c.GetStringSlice("permissions"),
 	}
+	subject.PathUserID, _ = strconv.ParseUint(c.Param("user_id"), 10, 64)
+	subject.QueryUserID, _ = strconv.ParseUint(c.Query("user_id"), 10, 64)
+	return subject
 }
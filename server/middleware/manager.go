@@ -1,36 +1,67 @@
 package middleware
 
 import (
+	"net/http"
+
 	"github.com/3086953492/gokit/config/types"
 	"github.com/3086953492/gokit/ginx/cookie"
-	"github.com/3086953492/gokit/jwt"
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
 	"github.com/gin-gonic/gin"
 
+	"goauth/appconfig"
+	"goauth/middleware/audit"
 	"goauth/middleware/auth"
 	"goauth/middleware/security"
-	"goauth/repositories/oauth"
+	"goauth/middleware/tenant"
+	"goauth/services"
+	"goauth/services/oauth"
 )
 
 // 中间件管理器
 type Manager struct {
-	config          *types.MiddlewareConfig
-	jwtManager      *jwt.Manager
-	cookieMgr       *cookie.TokenCookies
-	accessTokenRepo *oauthrepositories.OAuthAccessTokenRepository
+	config             *types.MiddlewareConfig
+	tenantService      *services.TenantService
+	cookieMgr          *cookie.TokenCookies
+	userTokenEpoch     *services.UserTokenEpoch
+	sessionService     *services.SessionService
+	rbacService        *services.RBACService
+	policyEngine       *services.PolicyEngine
+	accessTokenService *oauthservices.OAuthAccessTokenService
+	oauthClientService *oauthservices.OAuthClientService
+	rateLimitConfig    appconfig.RateLimitConfig
+	redisMgr           *redis.Manager
+	logMgr             *logger.Manager
 }
 
 // 创建管理器（通过注入配置）
 func NewManager(
 	cfg *types.MiddlewareConfig,
-	jwtManager *jwt.Manager,
+	tenantService *services.TenantService,
 	cookieMgr *cookie.TokenCookies,
-	accessTokenRepo *oauthrepositories.OAuthAccessTokenRepository,
+	userTokenEpoch *services.UserTokenEpoch,
+	sessionService *services.SessionService,
+	rbacService *services.RBACService,
+	policyEngine *services.PolicyEngine,
+	accessTokenService *oauthservices.OAuthAccessTokenService,
+	oauthClientService *oauthservices.OAuthClientService,
+	rateLimitConfig appconfig.RateLimitConfig,
+	redisMgr *redis.Manager,
+	logMgr *logger.Manager,
 ) *Manager {
 	return &Manager{
-		config:          cfg,
-		jwtManager:      jwtManager,
-		cookieMgr:       cookieMgr,
-		accessTokenRepo: accessTokenRepo,
+		config:             cfg,
+		tenantService:      tenantService,
+		cookieMgr:          cookieMgr,
+		userTokenEpoch:     userTokenEpoch,
+		sessionService:     sessionService,
+		rbacService:        rbacService,
+		policyEngine:       policyEngine,
+		accessTokenService: accessTokenService,
+		oauthClientService: oauthClientService,
+		rateLimitConfig:    rateLimitConfig,
+		redisMgr:           redisMgr,
+		logMgr:             logMgr,
 	}
 }
 
@@ -39,6 +70,12 @@ func (m *Manager) LoadGlobal(engine *gin.Engine) {
 	// CORS中间件
 	engine.Use(m.CORS())
 
+	// 记录请求来源，供审计事件使用
+	engine.Use(m.RequestInfo())
+
+	// 租户解析，须在认证之前
+	engine.Use(m.Tenant())
+
 	// 这里以后可以加更多全局中间件
 	// engine.Use(m.Logger())
 }
@@ -48,10 +85,30 @@ func (m *Manager) CORS() gin.HandlerFunc {
 	return security.NewCORSMiddleware(m.config.CORS)
 }
 
+// RequestInfo 把请求来源写入请求 context
+func (m *Manager) RequestInfo() gin.HandlerFunc {
+	return audit.RequestInfoMiddleware()
+}
+
+// Tenant 解析请求所属的租户
+func (m *Manager) Tenant() gin.HandlerFunc {
+	return tenant.TenantMiddleware(m.tenantService, m.logMgr)
+}
+
+// Realms 包装路由，使 /realms/{name} 前缀下的请求访问对应租户
+func (m *Manager) Realms(handler http.Handler) http.Handler {
+	return tenant.RealmHandler(handler)
+}
+
 // Auth 默认认证中间件，仅支持 Cookie(JWT)
 // 不接受 Bearer token，适用于内部接口
 func (m *Manager) Auth() gin.HandlerFunc {
-	return auth.AuthCookieMiddleware(m.jwtManager, m.cookieMgr)
+	return auth.AuthCookieMiddleware(m.tenantService, m.cookieMgr, m.userTokenEpoch, m.sessionService, m.rbacService)
+}
+
+// OptionalAuth 可选的 Cookie(JWT) 认证，未登录时以匿名身份继续处理
+func (m *Manager) OptionalAuth() gin.HandlerFunc {
+	return auth.AuthCookieOptionalMiddleware(m.tenantService, m.cookieMgr, m.userTokenEpoch, m.sessionService, m.rbacService)
 }
 
 // AuthBearerOrCookie 支持 Bearer(OAuth) 和 Cookie(JWT) 的认证中间件
@@ -61,23 +118,24 @@ func (m *Manager) Auth() gin.HandlerFunc {
 //   - auth.BearerAllowUser()  仅允许 Bearer-User
 //   - auth.BearerAllowClient() 仅允许 Bearer-Client（client_credentials）
 func (m *Manager) AuthBearerOrCookie(opts ...auth.BearerOption) gin.HandlerFunc {
-	return auth.AuthBearerOrCookieMiddleware(m.jwtManager, m.cookieMgr, m.accessTokenRepo, opts...)
+	return auth.AuthBearerOrCookieMiddleware(m.tenantService, m.cookieMgr, m.userTokenEpoch, m.sessionService, m.rbacService, m.accessTokenService, opts...)
 }
 
-func (m *Manager) Role(requiredRole string) gin.HandlerFunc {
-	return auth.RoleMiddleware(requiredRole)
+// ClientAuth OAuth 客户端 Basic 认证，按客户端限流的规则须放在其后
+func (m *Manager) ClientAuth() gin.HandlerFunc {
+	return auth.ClientBasicAuthMiddleware(m.oauthClientService)
 }
 
-func (m *Manager) ResourceOwner(source string) gin.HandlerFunc {
-	return auth.ResourceOwnerMiddleware(source)
-}
-
-// Scope 检查 OAuth scope 是否包含所需权限
-func (m *Manager) Scope(requiredScopes ...string) gin.HandlerFunc {
-	return auth.ScopeMiddleware(requiredScopes...)
+// RateLimit 按规则名应用限流，限流关闭或规则未配置时放行
+func (m *Manager) RateLimit(name string) gin.HandlerFunc {
+	rule, ok := m.rateLimitConfig.Rules[name]
+	if !m.rateLimitConfig.Enabled || !ok || rule.Rate <= 0 || rule.Period <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return security.NewRateLimitMiddleware(m.redisMgr, m.logMgr, name, rule)
 }
 
-// RequireUser 要求当前主体必须是用户（非客户端）
-func (m *Manager) RequireUser() gin.HandlerFunc {
-	return auth.RequireUserMiddleware()
+// Authorize 按路由绑定的授权策略鉴权，须放在认证中间件之后
+func (m *Manager) Authorize() gin.HandlerFunc {
+	return auth.AuthorizeMiddleware(m.policyEngine, m.logMgr)
 }
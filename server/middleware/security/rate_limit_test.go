@@ -0,0 +1,171 @@
+package security
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"goauth/appconfig"
+)
+
+// newRateLimitTestRouter 启动内存 Redis，返回挂载了限流中间件的路由
+// before 在限流之前执行，用于模拟客户端认证等写入上下文的中间件
+func newRateLimitTestRouter(t *testing.T, rule appconfig.RateLimitRule, before ...gin.HandlerFunc) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := miniredis.RunT(t)
+	redisMgr := redis.NewManager(redis.WithAddress(server.Addr()))
+	if err := redisMgr.Connect(context.Background()); err != nil {
+		t.Fatalf("连接测试 Redis 失败: %v", err)
+	}
+	t.Cleanup(func() { redisMgr.Close() })
+	logMgr, err := logger.NewManager(logger.WithConsole(true), logger.WithLevel(logger.ErrorLevel))
+	if err != nil {
+		t.Fatalf("创建日志管理器失败: %v", err)
+	}
+
+	router := gin.New()
+	handlers := append(before, NewRateLimitMiddleware(redisMgr, logMgr, "test", rule), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/limited", handlers...)
+	return router, server
+}
+
+func doRateLimitRequest(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/limited", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitBurstAndHeaders(t *testing.T) {
+	// 每分钟补充 2 个令牌，即 30 秒一个，桶容量 3
+	router, _ := newRateLimitTestRouter(t, appconfig.RateLimitRule{Key: appconfig.RateLimitKeyIP, Rate: 2, Period: time.Minute, Burst: 3})
+
+	for i, remaining := range []string{"2", "1", "0"} {
+		w := doRateLimitRequest(router, "192.0.2.1:1234")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("第 %d 个请求状态码 = %d，桶内仍有令牌应放行", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "3" {
+			t.Fatalf("RateLimit-Limit = %q，期望桶容量 3", got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Fatalf("第 %d 个请求 RateLimit-Remaining = %q，期望 %s", i+1, got, remaining)
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Fatal("放行的请求不应返回 Retry-After")
+		}
+	}
+
+	w := doRateLimitRequest(router, "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("令牌耗尽后状态码 = %d，期望 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After = %q，期望距下一个令牌的 30 秒", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Fatalf("被限流时 RateLimit-Remaining = %q，期望 0", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "90" {
+		t.Fatalf("RateLimit-Reset = %q，期望距桶满的 90 秒", got)
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	router, server := newRateLimitTestRouter(t, appconfig.RateLimitRule{Key: appconfig.RateLimitKeyIP, Rate: 2, Period: time.Minute, Burst: 1})
+	bucket := rateLimitKeyPrefix + "test:ip:192.0.2.1"
+
+	if w := doRateLimitRequest(router, "192.0.2.1:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("首个请求状态码 = %d，期望放行", w.Code)
+	}
+	if w := doRateLimitRequest(router, "192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("令牌耗尽后状态码 = %d，期望 429", w.Code)
+	}
+	if ttl := server.TTL(bucket); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("令牌桶过期时间 = %v，期望为桶补满所需的时间", ttl)
+	}
+
+	// 把上次更新时间往前拨 30 秒，模拟经过一个令牌的补充时间
+	ts, err := strconv.ParseInt(server.HGet(bucket, "ts"), 10, 64)
+	if err != nil {
+		t.Fatalf("读取令牌桶更新时间失败: %v", err)
+	}
+	server.HSet(bucket, "ts", strconv.FormatInt(ts-30*1000, 10))
+	if w := doRateLimitRequest(router, "192.0.2.1:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("补充令牌后状态码 = %d，期望放行", w.Code)
+	}
+	if w := doRateLimitRequest(router, "192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("补充的令牌用完后状态码 = %d，期望 429", w.Code)
+	}
+}
+
+func TestRateLimitSubjects(t *testing.T) {
+	rule := appconfig.RateLimitRule{Key: appconfig.RateLimitKeyIP, Rate: 1, Period: time.Minute}
+	router, _ := newRateLimitTestRouter(t, rule)
+	doRateLimitRequest(router, "192.0.2.1:1234")
+	if w := doRateLimitRequest(router, "192.0.2.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("同一 IP 第二个请求状态码 = %d，期望 429", w.Code)
+	}
+	if w := doRateLimitRequest(router, "192.0.2.2:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("其他 IP 状态码 = %d，各 IP 应使用独立的令牌桶", w.Code)
+	}
+
+	// 按客户端限流时同一客户端跨 IP 共用令牌桶，未认证的请求回退到 IP
+	rule.Key = appconfig.RateLimitKeyClientID
+	router, server := newRateLimitTestRouter(t, rule, func(c *gin.Context) {
+		if clientID := c.GetHeader("X-Test-Client"); clientID != "" {
+			c.Set("authenticated_client_id", clientID)
+		}
+	})
+	doClient := func(clientID, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/limited", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Test-Client", clientID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := doClient("client-a", "192.0.2.1:1234"); code != http.StatusNoContent {
+		t.Fatalf("客户端首个请求状态码 = %d，期望放行", code)
+	}
+	if code := doClient("client-a", "192.0.2.2:1234"); code != http.StatusTooManyRequests {
+		t.Fatalf("同一客户端换 IP 后状态码 = %d，期望 429", code)
+	}
+	if code := doClient("client-b", "192.0.2.1:1234"); code != http.StatusNoContent {
+		t.Fatalf("其他客户端状态码 = %d，各客户端应使用独立的令牌桶", code)
+	}
+	if code := doClient("", "192.0.2.1:1234"); code != http.StatusNoContent {
+		t.Fatalf("未认证请求状态码 = %d，应回退到按 IP 的令牌桶", code)
+	}
+	if !server.Exists(rateLimitKeyPrefix+"test:client:client-a") || !server.Exists(rateLimitKeyPrefix+"test:ip:192.0.2.1") {
+		t.Fatalf("令牌桶键不符合预期: %v", server.Keys())
+	}
+}
+
+func TestRateLimitRedisUnavailable(t *testing.T) {
+	router, server := newRateLimitTestRouter(t, appconfig.RateLimitRule{Key: appconfig.RateLimitKeyIP, Rate: 1, Period: time.Minute})
+	server.Close()
+
+	// Redis 不可用时放行且不返回限流头
+	for i := 0; i < 3; i++ {
+		w := doRateLimitRequest(router, "192.0.2.1:1234")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Redis 不可用时状态码 = %d，期望放行", w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatal("Redis 不可用时不应返回限流头")
+		}
+	}
+}
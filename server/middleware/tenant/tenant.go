@@ -0,0 +1,66 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/3086953492/gokit/ginx/problem"
+	"github.com/3086953492/gokit/logger"
+	"github.com/gin-gonic/gin"
+
+	"goauth/apperrors"
+	"goauth/services"
+	"goauth/utils"
+)
+
+// realmPathPrefix 租户路径前缀，/realms/{name}/api/v1/... 访问租户 name，其余路径访问默认租户
+const realmPathPrefix = "/realms/"
+
+// realmContextKey 请求路径中的租户标识在 context 中的键
+type realmContextKey struct{}
+
+// RealmHandler 在路由匹配之前去掉路径中的 /realms/{name} 前缀，并把租户标识写入请求 context
+// 各租户共用同一套路由，授权策略与路由模板不因租户而不同
+func RealmHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, realmPathPrefix)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name, path, _ := strings.Cut(rest, "/")
+		if name == "" {
+			http.NotFound(w, r)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), realmContextKey{}, name))
+		r.URL.Path = "/" + path
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TenantMiddleware 解析请求所属的租户并写入 context，仓库层据此限定查询范围
+// 租户不存在或已停用时返回 404，不区分两者，避免探测租户是否存在
+func TenantMiddleware(tenantService *services.TenantService, logMgr *logger.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, _ := c.Request.Context().Value(realmContextKey{}).(string)
+		tenant, err := tenantService.Resolve(c.Request.Context(), name)
+		if err != nil {
+			if err == apperrors.ErrTenantNotFound || err == apperrors.ErrTenantDisabled {
+				problem.Fail(c, 404, "TENANT_NOT_FOUND", apperrors.ErrTenantNotFound.Error(), "about:blank")
+				c.Abort()
+				return
+			}
+			logMgr.Error("解析租户失败", "error", err, "realm", name)
+			problem.Fail(c, 503, "SERVICE_UNAVAILABLE", err.Error(), "about:blank")
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(utils.WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 审计事件类型，格式为 资源.动作
+const (
+	AuditLogin           = "login"              // 登录，含密码、通行密钥、外部身份与两步验证各步骤
+	AuditLogout          = "logout"             // 退出登录
+	AuditLogoutNotify    = "logout.backchannel" // 向客户端投递后端通道登出令牌
+	AuditSessionRefresh  = "session.refresh"    // 第一方刷新令牌轮换
+	AuditSessionRevoke   = "session.revoke"     // 注销登录会话
+	AuditTokenIssue      = "oauth.token.issue"  // OAuth 令牌签发，Details 中记录授权类型
+	AuditTokenRevoke     = "oauth.token.revoke" // OAuth 令牌撤销
+	AuditClientCreate    = "oauth_client.create"
+	AuditClientUpdate    = "oauth_client.update"
+	AuditClientDelete    = "oauth_client.delete"
+	AuditUserCreate      = "user.create"
+	AuditUserUpdate      = "user.update"
+	AuditUserDelete      = "user.delete"
+	AuditUserRolesUpdate = "user.roles.update"
+	AuditUserMFAReset    = "user.mfa.reset"
+	AuditUserLockout     = "user.lockout" // 登录失败次数过多被临时锁定，TargetType 为锁定维度（username 或 ip）
+	AuditUserUnlock      = "user.unlock"
+	AuditTenantCreate    = "tenant.create"
+	AuditTenantUpdate    = "tenant.update"
+	AuditWebhookCreate   = "webhook.create"
+	AuditWebhookUpdate   = "webhook.update"
+	AuditWebhookDelete   = "webhook.delete"
+)
+
+// 审计事件结果
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// 审计事件的操作者类型
+const (
+	AuditActorUser      = "user"      // 已登录用户，或登录事件中的登录账号
+	AuditActorClient    = "client"    // 以客户端身份调用的 OAuth 客户端
+	AuditActorAnonymous = "anonymous" // 未认证的请求，如登录失败、自助注册
+)
+
+// AuditEvent 安全审计事件，只追加不修改
+// ActorID 为执行操作的用户，UserID 为事件涉及的用户；登录事件中两者相同，管理员删除用户时前者为管理员
+type AuditEvent struct {
+	ID         uint           `gorm:"type:bigint;comment:事件ID;primaryKey" json:"id"`
+	TenantID   uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index:idx_audit_events_tenant_created,priority:1" json:"-"`
+	CreatedAt  time.Time      `gorm:"type:datetime(3);comment:发生时间;index:idx_audit_events_tenant_created,priority:2" json:"created_at"`
+	Type       string         `gorm:"type:varchar(64);comment:事件类型;index;not null" json:"type"`
+	Outcome    string         `gorm:"type:varchar(16);comment:结果;not null" json:"outcome"`
+	ActorType  string         `gorm:"type:varchar(16);comment:操作者类型;not null" json:"actor_type"`
+	ActorID    *uint          `gorm:"type:bigint;comment:操作者用户ID;index" json:"actor_id"`
+	UserID     *uint          `gorm:"type:bigint;comment:涉及的用户ID;index" json:"user_id"`
+	Username   string         `gorm:"type:varchar(255);comment:登录时提交的用户名" json:"username"`
+	ClientID   string         `gorm:"type:varchar(64);comment:客户端ID;index" json:"client_id"`
+	TargetType string         `gorm:"type:varchar(32);comment:操作对象类型" json:"target_type"`
+	TargetID   string         `gorm:"type:varchar(64);comment:操作对象ID" json:"target_id"`
+	IP         string         `gorm:"type:varchar(64);comment:IP地址" json:"ip"`
+	UserAgent  string         `gorm:"type:varchar(500);comment:User-Agent" json:"user_agent"`
+	Reason     string         `gorm:"type:varchar(255);comment:失败原因" json:"reason"`
+	Details    datatypes.JSON `gorm:"type:json;comment:附加信息" json:"details"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
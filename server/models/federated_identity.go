@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// FederatedIdentity 外部身份提供方的账号与本地用户的关联
+// 同一租户内同一外部身份（签发方 + 主体标识）只能关联一个本地用户，同一用户在每个提供方最多关联一个外部身份
+type FederatedIdentity struct {
+	ID        uint      `gorm:"type:bigint;comment:关联ID;primaryKey" json:"id"`
+	TenantID  uint      `gorm:"type:bigint;comment:租户ID;default:1;not null;uniqueIndex:idx_federated_identity_tenant_issuer_subject,priority:1" json:"-"`
+	CreatedAt time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt time.Time `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	UserID    uint      `gorm:"type:bigint;comment:用户ID;not null;uniqueIndex:idx_federated_identity_user_provider,priority:1" json:"user_id"`
+	Provider  string    `gorm:"type:varchar(50);comment:提供方标识;not null;uniqueIndex:idx_federated_identity_user_provider,priority:2" json:"provider"`
+	Issuer    string    `gorm:"type:varchar(255);comment:签发方;not null;uniqueIndex:idx_federated_identity_tenant_issuer_subject,priority:2" json:"issuer"`
+	Subject   string    `gorm:"type:varchar(255);comment:外部主体标识;not null;uniqueIndex:idx_federated_identity_tenant_issuer_subject,priority:3" json:"subject"`
+
+	// Email、Username 最近一次登录时提供方返回的信息，仅用于展示
+	Email    string `gorm:"type:varchar(255);comment:外部邮箱" json:"email"`
+	Username string `gorm:"type:varchar(255);comment:外部用户名" json:"username"`
+
+	LastLoginAt *time.Time `gorm:"type:datetime;comment:最近登录时间" json:"last_login_at"`
+}
+
+func (FederatedIdentity) TableName() string {
+	return "user_federated_identities"
+}
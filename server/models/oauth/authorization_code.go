@@ -8,14 +8,17 @@ import (
 
 type OAuthAuthorizationCode struct {
 	ID          uint           `gorm:"type:bigint;comment:授权码ID;primaryKey" json:"id"`
+	TenantID    uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"-"`
 	CreatedAt   time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
 	UpdatedAt   time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
-	Code        string         `gorm:"type:varchar(100);comment:授权码;uniqueIndex;not null" json:"code"`
+	CodeHash    string         `gorm:"type:char(64);comment:授权码摘要;uniqueIndex;not null" json:"-"`
 	UserID      uint           `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
 	ClientID    string         `gorm:"type:varchar(100);comment:客户端ID;index;not null" json:"client_id"`
 	RedirectURI string         `gorm:"type:varchar(500);comment:回调地址;not null" json:"redirect_uri"`
 	Scope       string         `gorm:"type:varchar(500);comment:权限范围" json:"scope"`
+	SessionID   string         `gorm:"type:varchar(64);comment:授权时的登录会话标识" json:"-"` // 由此换取的令牌归属于该会话，会话结束时通知客户端
+	Nonce       string         `gorm:"type:varchar(255);comment:OpenID Connect nonce" json:"-"`
 	ExpiresAt   time.Time      `gorm:"type:datetime;comment:过期时间;index;not null" json:"expires_at"`
 	Used        bool           `gorm:"type:tinyint(1);comment:是否已使用;default:false" json:"used"`
 }
@@ -23,4 +26,3 @@ type OAuthAuthorizationCode struct {
 func (OAuthAuthorizationCode) TableName() string {
 	return "oauth_authorization_codes"
 }
-
@@ -9,6 +9,7 @@ import (
 
 type OAuthClient struct {
 	ID                 uint           `gorm:"type:bigint;comment:客户端ID;primaryKey" json:"id"`
+	TenantID           uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"tenant_id"`
 	ClientSecret       string         `gorm:"type:text;comment:客户端密钥;not null" json:"-"`
 	RedirectURIs       datatypes.JSON `gorm:"type:json;comment:回调地址列表" json:"redirect_uris"`
 	GrantTypes         datatypes.JSON `gorm:"type:json;comment:支持的授权类型" json:"grant_types"`
@@ -22,9 +23,15 @@ type OAuthClient struct {
 	Description        string         `gorm:"type:text;comment:应用描述" json:"description"`
 	Logo               string         `gorm:"type:varchar(500);comment:应用Logo URL" json:"logo"`
 	Status             int            `gorm:"type:tinyint;comment:状态;default:1" json:"status"` // 1:启用 0:禁用
-	CreatedAt          time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
-	UpdatedAt          time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
-	DeletedAt          gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
+
+	// OpenID Connect 登出（RP-Initiated / Back-Channel / Front-Channel Logout）
+	PostLogoutRedirectURIs datatypes.JSON `gorm:"type:json;comment:登出后回调地址列表" json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   string         `gorm:"type:varchar(500);comment:后端通道登出地址" json:"backchannel_logout_uri"`
+	FrontchannelLogoutURI  string         `gorm:"type:varchar(500);comment:前端通道登出地址" json:"frontchannel_logout_uri"`
+
+	CreatedAt time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
 }
 
 func (OAuthClient) TableName() string {
@@ -7,20 +7,21 @@ import (
 )
 
 type OAuthRefreshToken struct {
-	ID              uint           `gorm:"type:bigint;comment:刷新令牌ID;primaryKey" json:"id"`
-	CreatedAt       time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
-	UpdatedAt       time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
-	RefreshToken    string         `gorm:"type:varchar(500);comment:刷新令牌;uniqueIndex;not null" json:"refresh_token"`
-	AccessTokenID   uint           `gorm:"type:bigint;comment:关联的访问令牌ID;index" json:"access_token_id"`
-	ClientID        string         `gorm:"type:varchar(100);comment:客户端ID;index;not null" json:"client_id"`
-	UserID          uint           `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
-	Scope           string         `gorm:"type:varchar(500);comment:权限范围" json:"scope"`
-	ExpiresAt       time.Time      `gorm:"type:datetime;comment:过期时间;index;not null" json:"expires_at"`
-	Revoked         bool           `gorm:"type:tinyint(1);comment:是否已撤销;default:false" json:"revoked"`
+	ID            uint           `gorm:"type:bigint;comment:刷新令牌ID;primaryKey" json:"id"`
+	TenantID      uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"-"`
+	CreatedAt     time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
+	TokenHash     string         `gorm:"type:char(64);comment:刷新令牌摘要;uniqueIndex;not null" json:"-"`
+	AccessTokenID uint           `gorm:"type:bigint;comment:关联的访问令牌ID;index" json:"access_token_id"`
+	ClientID      string         `gorm:"type:varchar(100);comment:客户端ID;index;not null" json:"client_id"`
+	UserID        uint           `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
+	Scope         string         `gorm:"type:varchar(500);comment:权限范围" json:"scope"`
+	SessionID     string         `gorm:"type:varchar(64);comment:授权时的登录会话标识;index" json:"-"` // 刷新后的令牌沿用同一会话
+	ExpiresAt     time.Time      `gorm:"type:datetime;comment:过期时间;index;not null" json:"expires_at"`
+	Revoked       bool           `gorm:"type:tinyint(1);comment:是否已撤销;default:false" json:"revoked"`
 }
 
 func (OAuthRefreshToken) TableName() string {
 	return "oauth_refresh_tokens"
 }
-
@@ -0,0 +1,58 @@
+package oauthmodels
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SAML NameID 格式，取值均为用户标识 Subject
+const (
+	SAMLNameIDFormatPersistent  = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	SAMLNameIDFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+// SAML 属性的取值来源
+const (
+	SAMLAttributeSourceSubject  = "subject"
+	SAMLAttributeSourceUsername = "username"
+	SAMLAttributeSourceNickname = "nickname"
+	SAMLAttributeSourceEmail    = "email"
+	SAMLAttributeSourceRole     = "role"
+)
+
+// SAMLServiceProvider 接入 goauth 的 SAML 2.0 服务提供方
+// 断言中只包含 Attributes 中列出的属性，未配置时只下发 NameID
+type SAMLServiceProvider struct {
+	ID          uint   `gorm:"type:bigint;comment:服务提供方ID;primaryKey" json:"id"`
+	TenantID    uint   `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"tenant_id"`
+	EntityID    string `gorm:"type:varchar(255);comment:实体ID;index;not null" json:"entity_id"`
+	Name        string `gorm:"type:varchar(100);comment:应用名称;not null" json:"name"`
+	Description string `gorm:"type:text;comment:应用描述" json:"description"`
+	Logo        string `gorm:"type:varchar(500);comment:应用Logo URL" json:"logo"`
+	// Metadata 服务提供方元数据 XML，断言消费地址与加密证书从中读取
+	Metadata     string         `gorm:"type:mediumtext;comment:元数据;not null" json:"metadata"`
+	NameIDFormat string         `gorm:"type:varchar(100);comment:NameID格式;not null" json:"name_id_format"`
+	Attributes   datatypes.JSON `gorm:"type:json;comment:下发的属性" json:"attributes"`
+	// AllowIDPInitiated 是否允许从 goauth 直接登录到该应用（IdP 发起）
+	AllowIDPInitiated bool           `gorm:"type:tinyint(1);comment:是否允许IdP发起登录;default:false;not null" json:"allow_idp_initiated"`
+	DefaultRelayState string         `gorm:"type:varchar(500);comment:IdP发起登录时的默认RelayState" json:"default_relay_state"`
+	Status            int            `gorm:"type:tinyint;comment:状态;default:1" json:"status"` // 1:启用 0:禁用
+	CreatedAt         time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
+}
+
+// SAMLAttribute 下发给服务提供方的一个属性
+type SAMLAttribute struct {
+	// Name 属性名，如 email 或 urn:oid:0.9.2342.19200300.100.1.3
+	Name         string `json:"name"`
+	FriendlyName string `json:"friendly_name,omitempty"`
+	// Source 取值来源：subject、username、nickname、email 或 role
+	Source string `json:"source"`
+}
+
+func (SAMLServiceProvider) TableName() string {
+	return "saml_service_providers"
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// UserPasswordHistory 用户用过的密码哈希，用于禁止重复使用最近的密码
+// 修改密码时写入被替换的旧密码，只保留策略要求的条数
+type UserPasswordHistory struct {
+	ID           uint      `gorm:"type:bigint;comment:ID;primaryKey" json:"-"`
+	CreatedAt    time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UserID       uint      `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
+	PasswordHash string    `gorm:"type:varchar(255);comment:密码哈希;not null" json:"-"`
+}
+
+func (UserPasswordHistory) TableName() string {
+	return "user_password_histories"
+}
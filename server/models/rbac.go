@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// 内置角色，启动时自动创建，不能改名；admin 始终拥有全部权限，user 是新账号的默认角色
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// 权限，格式为 资源:操作；接口按权限而不是角色鉴权
+const (
+	PermissionUserRead           = "user:read"
+	PermissionUserWrite          = "user:write"
+	PermissionRegistrationReview = "registration:review"
+	PermissionOAuthClientRead    = "oauth_client:read"
+	PermissionOAuthClientWrite   = "oauth_client:write"
+	PermissionSAMLSPRead         = "saml_sp:read"
+	PermissionSAMLSPWrite        = "saml_sp:write"
+	PermissionRBACRead           = "rbac:read"
+	PermissionRBACWrite          = "rbac:write"
+	PermissionPolicyRead         = "policy:read"
+	PermissionTenantRead         = "tenant:read"
+	PermissionTenantWrite        = "tenant:write"
+	PermissionAuditRead          = "audit:read"
+	PermissionWebhookRead        = "webhook:read"
+	PermissionWebhookWrite       = "webhook:write"
+)
+
+// Permissions 系统定义的全部权限，启动时同步到权限表
+var Permissions = []Permission{
+	{Name: PermissionUserRead, Description: "查看用户列表与账号锁定状态"},
+	{Name: PermissionUserWrite, Description: "修改、禁用、删除用户，重置两步验证，解除锁定"},
+	{Name: PermissionRegistrationReview, Description: "审核注册申请"},
+	{Name: PermissionOAuthClientRead, Description: "查看 OAuth 客户端"},
+	{Name: PermissionOAuthClientWrite, Description: "创建、修改、删除 OAuth 客户端"},
+	{Name: PermissionSAMLSPRead, Description: "查看 SAML 服务提供方"},
+	{Name: PermissionSAMLSPWrite, Description: "创建、修改、删除 SAML 服务提供方"},
+	{Name: PermissionRBACRead, Description: "查看角色、权限与用户组"},
+	{Name: PermissionRBACWrite, Description: "管理角色、用户组及其分配"},
+	{Name: PermissionPolicyRead, Description: "查看授权策略与访问矩阵"},
+	{Name: PermissionTenantRead, Description: "查看租户（仅默认租户）"},
+	{Name: PermissionTenantWrite, Description: "创建、修改、停用租户（仅默认租户）"},
+	{Name: PermissionAuditRead, Description: "查询安全审计事件"},
+	{Name: PermissionWebhookRead, Description: "查看 Webhook 订阅与投递记录"},
+	{Name: PermissionWebhookWrite, Description: "管理 Webhook 订阅，重新投递失败的记录"},
+}
+
+// Role 角色，一组权限的集合，可分配给同一租户内的用户或用户组
+// 每个租户各有一套内置角色，租户的管理员只能管理本租户
+type Role struct {
+	ID          uint      `gorm:"type:bigint;comment:角色ID;primaryKey" json:"id"`
+	TenantID    uint      `gorm:"type:bigint;comment:租户ID;default:1;not null;uniqueIndex:idx_roles_tenant_name,priority:1" json:"-"`
+	Name        string    `gorm:"type:varchar(50);comment:名称;uniqueIndex:idx_roles_tenant_name,priority:2;not null" json:"name"`
+	Description string    `gorm:"type:varchar(255);comment:描述" json:"description"`
+	Builtin     bool      `gorm:"type:tinyint(1);comment:是否内置;default:false;not null" json:"builtin"`
+	CreatedAt   time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Permission 权限，由代码定义，不能通过接口增删
+type Permission struct {
+	ID          uint      `gorm:"type:bigint;comment:权限ID;primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100);comment:名称;uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"type:varchar(255);comment:描述" json:"description"`
+	CreatedAt   time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// RolePermission 角色拥有的权限
+type RolePermission struct {
+	RoleID       uint      `gorm:"type:bigint;comment:角色ID;primaryKey" json:"role_id"`
+	PermissionID uint      `gorm:"type:bigint;comment:权限ID;primaryKey;index" json:"permission_id"`
+	CreatedAt    time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// UserRole 直接分配给用户的角色
+type UserRole struct {
+	UserID    uint      `gorm:"type:bigint;comment:用户ID;primaryKey" json:"user_id"`
+	RoleID    uint      `gorm:"type:bigint;comment:角色ID;primaryKey;index" json:"role_id"`
+	CreatedAt time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
+
+// UserGroupRole 分配给用户组的角色，组内成员都拥有该角色
+type UserGroupRole struct {
+	GroupID   uint      `gorm:"type:bigint;comment:用户组ID;primaryKey" json:"group_id"`
+	RoleID    uint      `gorm:"type:bigint;comment:角色ID;primaryKey;index" json:"role_id"`
+	CreatedAt time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+}
+
+func (UserGroupRole) TableName() string {
+	return "user_group_roles"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken 第一方刷新令牌，每次使用后轮换；同一会话的令牌构成一个令牌族，
+// 已轮换的令牌再次出现视为泄露，整个会话随之注销
+type RefreshToken struct {
+	ID         uint       `gorm:"type:bigint;comment:刷新令牌ID;primaryKey" json:"-"`
+	CreatedAt  time.Time  `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	SessionID  string     `gorm:"type:varchar(64);comment:会话标识;index;not null" json:"session_id"`
+	UserID     uint       `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
+	TokenHash  string     `gorm:"type:char(64);comment:刷新令牌摘要;uniqueIndex;not null" json:"-"`
+	ReplacedAt *time.Time `gorm:"type:datetime;comment:轮换时间" json:"replaced_at"`
+	ExpiresAt  time.Time  `gorm:"type:datetime;comment:过期时间;index;not null" json:"expires_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "user_refresh_tokens"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Session 第一方登录会话，Cookie 中的访问令牌通过 sid 引用，刷新令牌只保存摘要
+type Session struct {
+	ID               uint      `gorm:"type:bigint;comment:会话ID;primaryKey" json:"-"`
+	CreatedAt        time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	SessionID        string    `gorm:"type:varchar(64);comment:会话标识;uniqueIndex;not null" json:"session_id"`
+	UserID           uint      `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
+	RefreshTokenHash string    `gorm:"type:char(64);comment:刷新令牌摘要;uniqueIndex;not null" json:"-"`
+	Device           string    `gorm:"type:varchar(100);comment:设备" json:"device"`
+	IP               string    `gorm:"type:varchar(64);comment:IP地址" json:"ip"`
+	UserAgent        string    `gorm:"type:varchar(500);comment:User-Agent" json:"user_agent"`
+	LastSeenAt       time.Time `gorm:"type:datetime;comment:最后活跃时间" json:"last_seen_at"`
+	ExpiresAt        time.Time `gorm:"type:datetime;comment:过期时间;index;not null" json:"expires_at"`
+	Revoked          bool      `gorm:"type:tinyint(1);comment:是否已撤销;default:false" json:"revoked"`
+}
+
+func (Session) TableName() string {
+	return "user_sessions"
+}
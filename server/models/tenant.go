@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// 默认租户，升级前的数据全部归入默认租户，未带租户前缀的请求同样访问默认租户
+const (
+	DefaultTenantID   uint = 1
+	DefaultTenantName      = "default"
+)
+
+// 租户状态
+const (
+	TenantStatusDisabled = 0 // 已停用，该租户的全部请求均被拒绝
+	TenantStatusActive   = 1 // 正常
+)
+
+// Tenant 租户（realm），用户、OAuth 客户端、令牌、角色与用户组均归属于某个租户，租户之间相互隔离
+type Tenant struct {
+	ID          uint      `gorm:"type:bigint;comment:租户ID;primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(50);comment:标识;uniqueIndex;not null" json:"name"` // 出现在 /realms/{name} 路径与发行方地址中
+	DisplayName string    `gorm:"type:varchar(100);comment:名称;not null" json:"display_name"`
+	Status      int       `gorm:"type:tinyint;comment:状态;default:1;not null" json:"status"` // 见 TenantStatus* 常量
+	CreatedAt   time.Time `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+
+	// SigningSecret 第一方令牌的签名密钥，加密保存；默认租户沿用配置文件中的密钥
+	SigningSecret string `gorm:"type:text;comment:令牌签名密钥" json:"-"`
+	// SAMLCertificate、SAMLPrivateKey SAML 断言的签名证书与私钥（PEM），私钥加密保存；首次签发断言时生成，默认租户沿用配置文件中的证书
+	SAMLCertificate string `gorm:"type:text;comment:SAML签名证书" json:"-"`
+	SAMLPrivateKey  string `gorm:"type:text;comment:SAML签名私钥" json:"-"`
+}
+
+func (Tenant) TableName() string {
+	return "tenants"
+}
@@ -6,18 +6,55 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户状态
+// 未激活与已禁用分开记录：前者是注册流程尚未完成，后者是管理员的处置
+const (
+	UserStatusDisabled        = 0 // 已被管理员禁用
+	UserStatusActive          = 1 // 正常
+	UserStatusPendingEmail    = 2 // 待通过邮件链接激活
+	UserStatusPendingApproval = 3 // 待管理员审核
+	UserStatusRejected        = 4 // 注册申请被管理员拒绝
+)
+
+// 账号的认证来源
+const (
+	UserAuthSourceLocal = "local" // 本地密码
+	UserAuthSourceLDAP  = "ldap"  // 企业目录，密码由目录校验与管理
+)
+
 type User struct {
 	ID        uint           `gorm:"type:bigint;comment:用户ID;primaryKey" json:"id"`
+	TenantID  uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;uniqueIndex:idx_users_tenant_username,priority:1;uniqueIndex:idx_users_tenant_email,priority:1" json:"tenant_id"`
 	Subject   string         `gorm:"type:varchar(255);comment:用户标识;uniqueIndex;not null" json:"subject"`
 	CreatedAt time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
 	UpdatedAt time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
-	Username  string         `gorm:"type:varchar(50);comment:用户名;uniqueIndex;not null" json:"username"`
+	Username  string         `gorm:"type:varchar(50);comment:用户名;uniqueIndex:idx_users_tenant_username,priority:2;not null" json:"username"` // 租户内唯一
 	Password  string         `gorm:"type:varchar(255);comment:密码哈希;not null" json:"-"`
 	Nickname  string         `gorm:"type:varchar(100);comment:昵称" json:"nickname"`
 	Avatar    string         `gorm:"type:varchar(500);comment:头像URL" json:"avatar"`
-	Status    int            `gorm:"type:tinyint;comment:状态;default:0" json:"status"` // 1:正常 0:禁用
-	Role      string         `gorm:"type:varchar(50);comment:角色" json:"role"`
+	Status    int            `gorm:"type:tinyint;comment:状态;default:0" json:"status"` // 见 UserStatus* 常量
+
+	// Email 邮箱，统一保存为小写，租户内唯一；未设置时为 NULL，唯一索引不限制多个空值
+	Email *string `gorm:"type:varchar(255);comment:邮箱;uniqueIndex:idx_users_tenant_email,priority:2" json:"email"`
+	// EmailVerifiedAt 邮箱验证时间，修改邮箱后清空
+	EmailVerifiedAt *time.Time `gorm:"type:datetime;comment:邮箱验证时间" json:"email_verified_at"`
+
+	// MFAEnabled 是否已启用两步验证，启用后登录须在密码之外再完成一次验证
+	MFAEnabled bool `gorm:"type:tinyint(1);comment:是否启用两步验证;default:false" json:"mfa_enabled"`
+
+	// PasswordChangedAt 最近一次设置密码的时间，为空时以创建时间计算密码使用期限
+	PasswordChangedAt *time.Time `gorm:"type:datetime;comment:密码修改时间" json:"-"`
+	// PasswordUnset 通过外部身份注册、尚未设置本地密码的账号，数据库中保存的是随机密码的哈希
+	PasswordUnset bool `gorm:"type:tinyint(1);comment:是否未设置本地密码;default:false;not null" json:"-"`
+	// AuthSource 认证来源，见 UserAuthSource* 常量；目录账号只能通过目录校验密码
+	AuthSource string `gorm:"type:varchar(20);comment:认证来源;default:local;not null" json:"auth_source"`
+
+	// ExternalID 预配方（SCIM）中的账号标识，由预配方写入，本系统不做解释
+	ExternalID *string `gorm:"type:varchar(255);comment:外部标识;index" json:"-"`
+
+	// TokenEpoch 令牌纪元（毫秒时间戳），签发时间早于该值的令牌一律失效
+	TokenEpoch int64 `gorm:"type:bigint;comment:令牌纪元;default:0;not null" json:"-"`
 }
 
 func (User) TableName() string {
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserGroup 用户组，由管理员或预配方（SCIM）维护，分配给用户组的角色对全部成员生效，成员与角色限于同一租户
+type UserGroup struct {
+	ID          uint           `gorm:"type:bigint;comment:用户组ID;primaryKey" json:"id"`
+	TenantID    uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"-"`
+	DisplayName string         `gorm:"type:varchar(255);comment:名称;index;not null" json:"display_name"`
+	ExternalID  *string        `gorm:"type:varchar(255);comment:外部标识;index" json:"-"`
+	CreatedAt   time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"type:datetime;comment:删除时间;index" json:"-"`
+}
+
+func (UserGroup) TableName() string {
+	return "user_groups"
+}
+
+// UserGroupMember 用户组成员
+type UserGroupMember struct {
+	GroupID   uint      `gorm:"type:bigint;comment:用户组ID;primaryKey" json:"group_id"`
+	UserID    uint      `gorm:"type:bigint;comment:用户ID;primaryKey;index" json:"user_id"`
+	CreatedAt time.Time `gorm:"type:datetime;comment:加入时间" json:"created_at"`
+}
+
+func (UserGroupMember) TableName() string {
+	return "user_group_members"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// UserTOTP 用户的 TOTP 验证器绑定，每个用户最多一条
+// 密钥加密保存；未确认的绑定不参与登录校验，重新发起绑定会覆盖
+type UserTOTP struct {
+	ID           uint       `gorm:"type:bigint;comment:ID;primaryKey" json:"-"`
+	CreatedAt    time.Time  `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	UserID       uint       `gorm:"type:bigint;comment:用户ID;uniqueIndex;not null" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(255);comment:加密后的TOTP密钥;not null" json:"-"`
+	ConfirmedAt  *time.Time `gorm:"type:datetime;comment:确认绑定时间" json:"confirmed_at"`
+	LastUsedStep int64      `gorm:"type:bigint;comment:最近一次通过校验的时间步;default:0;not null" json:"-"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totp"
+}
+
+// UserRecoveryCode 两步验证恢复码，只保存摘要，每个恢复码只能使用一次
+type UserRecoveryCode struct {
+	ID        uint       `gorm:"type:bigint;comment:ID;primaryKey" json:"-"`
+	CreatedAt time.Time  `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UserID    uint       `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);comment:恢复码摘要;uniqueIndex;not null" json:"-"`
+	UsedAt    *time.Time `gorm:"type:datetime;comment:使用时间" json:"used_at"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
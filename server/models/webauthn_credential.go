@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// WebAuthnCredential 用户注册的通行密钥（WebAuthn 凭证）
+// 既可用于无密码登录，也可作为两步验证方式
+type WebAuthnCredential struct {
+	ID           uint       `gorm:"type:bigint;comment:ID;primaryKey" json:"id"`
+	CreatedAt    time.Time  `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+	UserID       uint       `gorm:"type:bigint;comment:用户ID;index;not null" json:"user_id"`
+	Name         string     `gorm:"type:varchar(50);comment:凭证名称;not null" json:"name"`
+	CredentialID string     `gorm:"type:varchar(255);comment:凭证ID（base64url）;uniqueIndex;not null" json:"-"`
+	PublicKey    []byte     `gorm:"type:blob;comment:COSE编码的公钥;not null" json:"-"`
+	SignCount    uint32     `gorm:"type:int unsigned;comment:签名计数器;default:0;not null" json:"-"`
+	AAGUID       string     `gorm:"type:char(32);comment:认证器型号标识;not null" json:"-"`
+	Transports   string     `gorm:"type:varchar(100);comment:传输方式，逗号分隔;not null" json:"-"`
+	LastUsedAt   *time.Time `gorm:"type:datetime;comment:最近使用时间" json:"last_used_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "user_webauthn_credentials"
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Webhook 事件类型，格式为 资源.动作
+const (
+	WebhookUserCreated   = "user.created"
+	WebhookUserUpdated   = "user.updated"
+	WebhookUserDisabled  = "user.disabled" // 用户被停用，取代同一次变更的 user.updated
+	WebhookUserDeleted   = "user.deleted"
+	WebhookClientCreated = "oauth_client.created"
+	WebhookClientUpdated = "oauth_client.updated"
+	WebhookClientDeleted = "oauth_client.deleted"
+	WebhookGrantRevoked  = "grant.revoked" // 客户端按 RFC7009 撤销令牌，或客户端、用户的令牌被批量撤销
+)
+
+// WebhookBackchannelLogout 后端通道登出通知，投递到客户端登记的登出地址而不是订阅，不可订阅
+const WebhookBackchannelLogout = "oauth.backchannel_logout"
+
+// WebhookEvents 可订阅的全部事件类型
+var WebhookEvents = []string{
+	WebhookUserCreated,
+	WebhookUserUpdated,
+	WebhookUserDisabled,
+	WebhookUserDeleted,
+	WebhookClientCreated,
+	WebhookClientUpdated,
+	WebhookClientDeleted,
+	WebhookGrantRevoked,
+}
+
+// Webhook 投递状态
+const (
+	WebhookDeliveryPending   = "pending"   // 等待投递或等待重试
+	WebhookDeliveryDelivered = "delivered" // 接收方返回 2xx
+	WebhookDeliveryDead      = "dead"      // 重试次数用尽或订阅已停用、删除，可由管理员重新投递
+)
+
+// WebhookSubscription Webhook 订阅，事件发生时向 URL 推送 HMAC-SHA256 签名的 JSON
+type WebhookSubscription struct {
+	ID        uint           `gorm:"type:bigint;comment:订阅ID;primaryKey" json:"id"`
+	TenantID  uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"-"`
+	Name      string         `gorm:"type:varchar(100);comment:名称;not null" json:"name"`
+	URL       string         `gorm:"type:varchar(500);comment:接收地址;not null" json:"url"`
+	Events    datatypes.JSON `gorm:"type:json;comment:订阅的事件类型" json:"events"`
+	Enabled   bool           `gorm:"comment:是否启用;not null" json:"enabled"`
+	CreatedAt time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+
+	// Secret 签名密钥，加密保存，只在创建时返回一次
+	Secret string `gorm:"type:text;comment:签名密钥;not null" json:"-"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookDelivery Webhook 投递记录（outbox），与触发它的变更在同一事务中写入，由后台任务投递
+// 后端通道登出通知同样经此投递，此时 SubscriptionID 为 0，接收方为 ClientID 对应客户端的登出地址
+type WebhookDelivery struct {
+	ID             uint           `gorm:"type:bigint;comment:投递ID;primaryKey" json:"id"`
+	TenantID       uint           `gorm:"type:bigint;comment:租户ID;default:1;not null;index" json:"-"`
+	SubscriptionID uint           `gorm:"type:bigint;comment:订阅ID;not null;index" json:"subscription_id"`
+	ClientID       string         `gorm:"type:varchar(100);comment:后端通道登出的客户端ID;index" json:"client_id"`
+	EventID        string         `gorm:"type:varchar(64);comment:事件ID;not null;index" json:"event_id"` // 同一事件投递给各订阅时相同，接收方据此去重
+	EventType      string         `gorm:"type:varchar(64);comment:事件类型;not null" json:"event_type"`
+	Payload        datatypes.JSON `gorm:"type:json;comment:请求体" json:"payload"`
+	Status         string         `gorm:"type:varchar(16);comment:状态;not null;index:idx_webhook_deliveries_due,priority:1" json:"status"` // 见 WebhookDelivery* 常量
+	NextAttemptAt  time.Time      `gorm:"type:datetime(3);comment:下次投递时间;not null;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	Attempts       int            `gorm:"type:int;comment:已投递次数;default:0;not null" json:"attempts"`
+	LastStatusCode int            `gorm:"type:int;comment:最近一次响应状态码" json:"last_status_code"`
+	LastError      string         `gorm:"type:varchar(500);comment:最近一次失败原因" json:"last_error"`
+	DeliveredAt    *time.Time     `gorm:"type:datetime;comment:投递成功时间" json:"delivered_at"`
+	CreatedAt      time.Time      `gorm:"type:datetime;comment:创建时间" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"type:datetime;comment:更新时间" json:"updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// AuditEventRepository 审计事件仓库实现，事件只追加不修改
+type AuditEventRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditEventRepository 创建审计事件仓库实例
+func NewAuditEventRepository(db *gorm.DB) *AuditEventRepository {
+	return &AuditEventRepository{
+		db: db,
+	}
+}
+
+// Create 写入审计事件，归属于 context 所属的租户
+func (r *AuditEventRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	event.TenantID = utils.TenantID(ctx)
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// List 分页查询审计事件，按发生时间倒序
+func (r *AuditEventRepository) List(ctx context.Context, page, pageSize int, conds map[string]any) ([]models.AuditEvent, int64, error) {
+	var events []models.AuditEvent
+	var total int64
+
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.AuditEvent{})
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// FederatedIdentityRepository 外部身份关联仓库实现
+type FederatedIdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewFederatedIdentityRepository 创建外部身份关联仓库实例
+func NewFederatedIdentityRepository(db *gorm.DB) *FederatedIdentityRepository {
+	return &FederatedIdentityRepository{
+		db: db,
+	}
+}
+
+// Create 创建外部身份关联
+func (r *FederatedIdentityRepository) Create(ctx context.Context, identity *models.FederatedIdentity) error {
+	identity.TenantID = utils.TenantID(ctx)
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// CreateWithTx 在事务中创建外部身份关联
+func (r *FederatedIdentityRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, identity *models.FederatedIdentity) error {
+	identity.TenantID = utils.TenantID(ctx)
+	return tx.WithContext(ctx).Create(identity).Error
+}
+
+// Get 根据传入的条件查询外部身份关联
+func (r *FederatedIdentityRepository) Get(ctx context.Context, conds map[string]any) (*models.FederatedIdentity, error) {
+	var identity models.FederatedIdentity
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.FederatedIdentity{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&identity).Error; err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// Find 根据传入的条件查询外部身份关联列表，按创建时间排序
+func (r *FederatedIdentityRepository) Find(ctx context.Context, conds map[string]any) ([]models.FederatedIdentity, error) {
+	var identities []models.FederatedIdentity
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.FederatedIdentity{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// Count 根据传入的条件统计外部身份关联数量
+func (r *FederatedIdentityRepository) Count(ctx context.Context, conds map[string]any) (int64, error) {
+	var count int64
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.FederatedIdentity{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Update 根据ID更新外部身份关联
+func (r *FederatedIdentityRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.FederatedIdentity{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 根据条件删除外部身份关联，返回删除的行数
+func (r *FederatedIdentityRepository) Delete(ctx context.Context, conds map[string]any) (int64, error) {
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx))
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	result := query.Delete(&models.FederatedIdentity{})
+	return result.RowsAffected, result.Error
+}
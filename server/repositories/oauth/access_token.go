@@ -2,10 +2,13 @@ package oauthrepositories
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 
 	"goauth/models/oauth"
+	"goauth/repositories"
+	"goauth/utils"
 )
 
 // OAuthAccessTokenRepository OAuth访问令牌仓库实现
@@ -22,18 +25,20 @@ func NewOAuthAccessTokenRepository(db *gorm.DB) *OAuthAccessTokenRepository {
 
 // Create 创建OAuth访问令牌
 func (r *OAuthAccessTokenRepository) Create(ctx context.Context, token *oauthmodels.OAuthAccessToken) error {
+	token.TenantID = utils.TenantID(ctx)
 	return r.db.WithContext(ctx).Create(token).Error
 }
 
 // CreateWithTx 在事务中创建OAuth访问令牌
 func (r *OAuthAccessTokenRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, token *oauthmodels.OAuthAccessToken) error {
+	token.TenantID = utils.TenantID(ctx)
 	return tx.WithContext(ctx).Create(token).Error
 }
 
 // Get 根据传入的条件查询OAuth访问令牌
 func (r *OAuthAccessTokenRepository) Get(ctx context.Context, conds map[string]any) (*oauthmodels.OAuthAccessToken, error) {
 	var token oauthmodels.OAuthAccessToken
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthAccessToken{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAccessToken{})
 
 	for key, value := range conds {
 		query = query.Where(key, value)
@@ -46,14 +51,62 @@ func (r *OAuthAccessTokenRepository) Get(ctx context.Context, conds map[string]a
 	return &token, nil
 }
 
+// Find 根据传入的条件查询全部OAuth访问令牌
+func (r *OAuthAccessTokenRepository) Find(ctx context.Context, conds map[string]any) ([]oauthmodels.OAuthAccessToken, error) {
+	var tokens []oauthmodels.OAuthAccessToken
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAccessToken{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// ListTokenHashes 根据传入的条件查询OAuth访问令牌摘要
+func (r *OAuthAccessTokenRepository) ListTokenHashes(ctx context.Context, conds map[string]any) ([]string, error) {
+	var tokenHashes []string
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAccessToken{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Pluck("token_hash", &tokenHashes).Error; err != nil {
+		return nil, err
+	}
+
+	return tokenHashes, nil
+}
+
+// ListClientIDs 根据传入的条件查询OAuth访问令牌所属的客户端ID（去重）
+func (r *OAuthAccessTokenRepository) ListClientIDs(ctx context.Context, conds map[string]any) ([]string, error) {
+	var clientIDs []string
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAccessToken{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Distinct("client_id").Pluck("client_id", &clientIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return clientIDs, nil
+}
+
 // Update 更新OAuth访问令牌信息
 func (r *OAuthAccessTokenRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
-	return r.db.WithContext(ctx).Model(&oauthmodels.OAuthAccessToken{}).Where("id = ?", id).Updates(updates).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAccessToken{}).Where("id = ?", id).Updates(updates).Error
 }
 
 // Delete 软删除OAuth访问令牌
 func (r *OAuthAccessTokenRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&oauthmodels.OAuthAccessToken{}, id).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Delete(&oauthmodels.OAuthAccessToken{}, id).Error
 }
 
 // List 分页查询OAuth访问令牌列表
@@ -62,7 +115,7 @@ func (r *OAuthAccessTokenRepository) List(ctx context.Context, page, pageSize in
 	var total int64
 
 	// 计算总数
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthAccessToken{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAccessToken{})
 	for key, value := range conds {
 		query = query.Where(key, value)
 	}
@@ -83,3 +136,21 @@ func (r *OAuthAccessTokenRepository) List(ctx context.Context, page, pageSize in
 
 	return tokens, total, nil
 }
+
+// PurgeStale 物理删除一批 before 之前已过期、已撤销或已软删除的OAuth访问令牌，返回删除的行数
+func (r *OAuthAccessTokenRepository) PurgeStale(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Unscoped().
+		Model(&oauthmodels.OAuthAccessToken{}).
+		Where("expires_at < ? OR (revoked = ? AND updated_at < ?) OR deleted_at < ?", before, true, before, before).
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Unscoped().Delete(&oauthmodels.OAuthAccessToken{}, ids)
+	return result.RowsAffected, result.Error
+}
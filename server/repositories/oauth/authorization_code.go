@@ -7,6 +7,8 @@ import (
 	"gorm.io/gorm"
 
 	"goauth/models/oauth"
+	"goauth/repositories"
+	"goauth/utils"
 )
 
 // OAuthAuthorizationCodeRepository OAuth授权码仓库实现
@@ -23,13 +25,14 @@ func NewOAuthAuthorizationCodeRepository(db *gorm.DB) *OAuthAuthorizationCodeRep
 
 // Create 创建OAuth授权码
 func (r *OAuthAuthorizationCodeRepository) Create(ctx context.Context, code *oauthmodels.OAuthAuthorizationCode) error {
+	code.TenantID = utils.TenantID(ctx)
 	return r.db.WithContext(ctx).Create(code).Error
 }
 
 // Get 根据传入的条件查询OAuth授权码
 func (r *OAuthAuthorizationCodeRepository) Get(ctx context.Context, conds map[string]any) (*oauthmodels.OAuthAuthorizationCode, error) {
 	var code oauthmodels.OAuthAuthorizationCode
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthAuthorizationCode{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAuthorizationCode{})
 
 	for key, value := range conds {
 		query = query.Where(key, value)
@@ -44,26 +47,26 @@ func (r *OAuthAuthorizationCodeRepository) Get(ctx context.Context, conds map[st
 
 // Update 更新OAuth授权码信息
 func (r *OAuthAuthorizationCodeRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
-	return r.db.WithContext(ctx).Model(&oauthmodels.OAuthAuthorizationCode{}).Where("id = ?", id).Updates(updates).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAuthorizationCode{}).Where("id = ?", id).Updates(updates).Error
 }
 
 // MarkAsUsed 标记授权码为已使用
 func (r *OAuthAuthorizationCodeRepository) MarkAsUsed(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Model(&oauthmodels.OAuthAuthorizationCode{}).
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAuthorizationCode{}).
 		Where("id = ?", id).
 		Update("used", true).Error
 }
 
 // MarkAsUsedWithTx 在事务中标记授权码为已使用
 func (r *OAuthAuthorizationCodeRepository) MarkAsUsedWithTx(ctx context.Context, tx *gorm.DB, id uint) error {
-	return tx.WithContext(ctx).Model(&oauthmodels.OAuthAuthorizationCode{}).
+	return tx.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthAuthorizationCode{}).
 		Where("id = ?", id).
 		Update("used", true).Error
 }
 
 // Delete 软删除OAuth授权码
 func (r *OAuthAuthorizationCodeRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&oauthmodels.OAuthAuthorizationCode{}, id).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Delete(&oauthmodels.OAuthAuthorizationCode{}, id).Error
 }
 
 // DeleteExpired 清理过期的授权码
@@ -72,3 +75,21 @@ func (r *OAuthAuthorizationCodeRepository) DeleteExpired(ctx context.Context) er
 		Where("expires_at < ?", time.Now()).
 		Delete(&oauthmodels.OAuthAuthorizationCode{}).Error
 }
+
+// PurgeStale 物理删除一批 before 之前已过期、已使用或已软删除的OAuth授权码，返回删除的行数
+func (r *OAuthAuthorizationCodeRepository) PurgeStale(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Unscoped().
+		Model(&oauthmodels.OAuthAuthorizationCode{}).
+		Where("expires_at < ? OR (used = ? AND updated_at < ?) OR deleted_at < ?", before, true, before, before).
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Unscoped().Delete(&oauthmodels.OAuthAuthorizationCode{}, ids)
+	return result.RowsAffected, result.Error
+}
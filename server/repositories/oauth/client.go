@@ -6,6 +6,8 @@ import (
 	"gorm.io/gorm"
 
 	"goauth/models/oauth"
+	"goauth/repositories"
+	"goauth/utils"
 )
 
 // OAuthClientRepository OAuth客户端仓库实现
@@ -22,13 +24,25 @@ func NewOAuthClientRepository(db *gorm.DB) *OAuthClientRepository {
 
 // Create 创建OAuth客户端
 func (r *OAuthClientRepository) Create(ctx context.Context, client *oauthmodels.OAuthClient) error {
+	client.TenantID = utils.TenantID(ctx)
 	return r.db.WithContext(ctx).Create(client).Error
 }
 
+// CreateWithTx 在事务中创建OAuth客户端
+func (r *OAuthClientRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, client *oauthmodels.OAuthClient) error {
+	client.TenantID = utils.TenantID(ctx)
+	return tx.WithContext(ctx).Create(client).Error
+}
+
+// DB 返回数据库连接实例
+func (r *OAuthClientRepository) DB() *gorm.DB {
+	return r.db
+}
+
 // Get 根据传入的条件查询OAuth客户端
 func (r *OAuthClientRepository) Get(ctx context.Context, conds map[string]any) (*oauthmodels.OAuthClient, error) {
 	var client oauthmodels.OAuthClient
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthClient{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthClient{})
 
 	for key, value := range conds {
 		query = query.Where(key, value)
@@ -43,12 +57,22 @@ func (r *OAuthClientRepository) Get(ctx context.Context, conds map[string]any) (
 
 // Update 更新OAuth客户端信息
 func (r *OAuthClientRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
-	return r.db.WithContext(ctx).Model(&oauthmodels.OAuthClient{}).Where("id = ?", id).Updates(updates).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthClient{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// UpdateWithTx 在事务中更新OAuth客户端信息
+func (r *OAuthClientRepository) UpdateWithTx(ctx context.Context, tx *gorm.DB, id uint, updates map[string]any) error {
+	return tx.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthClient{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// DeleteWithTx 在事务中软删除OAuth客户端
+func (r *OAuthClientRepository) DeleteWithTx(ctx context.Context, tx *gorm.DB, id uint) error {
+	return tx.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Delete(&oauthmodels.OAuthClient{}, id).Error
 }
 
 // Delete 软删除OAuth客户端
 func (r *OAuthClientRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&oauthmodels.OAuthClient{}, id).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Delete(&oauthmodels.OAuthClient{}, id).Error
 }
 
 // List 分页查询OAuth客户端列表
@@ -57,7 +81,7 @@ func (r *OAuthClientRepository) List(ctx context.Context, page, pageSize int, co
 	var total int64
 
 	// 计算总数
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthClient{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthClient{})
 	for key, value := range conds {
 		query = query.Where(key, value)
 	}
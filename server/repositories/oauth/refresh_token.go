@@ -2,10 +2,13 @@ package oauthrepositories
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 
 	"goauth/models/oauth"
+	"goauth/repositories"
+	"goauth/utils"
 )
 
 // OAuthRefreshTokenRepository OAuth刷新令牌仓库实现
@@ -22,18 +25,20 @@ func NewOAuthRefreshTokenRepository(db *gorm.DB) *OAuthRefreshTokenRepository {
 
 // Create 创建OAuth刷新令牌
 func (r *OAuthRefreshTokenRepository) Create(ctx context.Context, token *oauthmodels.OAuthRefreshToken) error {
+	token.TenantID = utils.TenantID(ctx)
 	return r.db.WithContext(ctx).Create(token).Error
 }
 
 // CreateWithTx 在事务中创建OAuth刷新令牌
 func (r *OAuthRefreshTokenRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, token *oauthmodels.OAuthRefreshToken) error {
+	token.TenantID = utils.TenantID(ctx)
 	return tx.WithContext(ctx).Create(token).Error
 }
 
 // Get 根据传入的条件查询OAuth刷新令牌
 func (r *OAuthRefreshTokenRepository) Get(ctx context.Context, conds map[string]any) (*oauthmodels.OAuthRefreshToken, error) {
 	var token oauthmodels.OAuthRefreshToken
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthRefreshToken{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthRefreshToken{})
 
 	for key, value := range conds {
 		query = query.Where(key, value)
@@ -46,14 +51,30 @@ func (r *OAuthRefreshTokenRepository) Get(ctx context.Context, conds map[string]
 	return &token, nil
 }
 
+// ListClientIDs 根据传入的条件查询OAuth刷新令牌所属的客户端ID（去重）
+func (r *OAuthRefreshTokenRepository) ListClientIDs(ctx context.Context, conds map[string]any) ([]string, error) {
+	var clientIDs []string
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthRefreshToken{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Distinct("client_id").Pluck("client_id", &clientIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return clientIDs, nil
+}
+
 // Update 更新OAuth刷新令牌信息
 func (r *OAuthRefreshTokenRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
-	return r.db.WithContext(ctx).Model(&oauthmodels.OAuthRefreshToken{}).Where("id = ?", id).Updates(updates).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthRefreshToken{}).Where("id = ?", id).Updates(updates).Error
 }
 
 // Delete 软删除OAuth刷新令牌
 func (r *OAuthRefreshTokenRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&oauthmodels.OAuthRefreshToken{}, id).Error
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Delete(&oauthmodels.OAuthRefreshToken{}, id).Error
 }
 
 // List 分页查询OAuth刷新令牌列表
@@ -62,7 +83,7 @@ func (r *OAuthRefreshTokenRepository) List(ctx context.Context, page, pageSize i
 	var total int64
 
 	// 计算总数
-	query := r.db.WithContext(ctx).Model(&oauthmodels.OAuthRefreshToken{})
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.OAuthRefreshToken{})
 	for key, value := range conds {
 		query = query.Where(key, value)
 	}
@@ -84,3 +105,20 @@ func (r *OAuthRefreshTokenRepository) List(ctx context.Context, page, pageSize i
 	return tokens, total, nil
 }
 
+// PurgeStale 物理删除一批 before 之前已过期、已撤销或已软删除的OAuth刷新令牌，返回删除的行数
+func (r *OAuthRefreshTokenRepository) PurgeStale(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Unscoped().
+		Model(&oauthmodels.OAuthRefreshToken{}).
+		Where("expires_at < ? OR (revoked = ? AND updated_at < ?) OR deleted_at < ?", before, true, before, before).
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Unscoped().Delete(&oauthmodels.OAuthRefreshToken{}, ids)
+	return result.RowsAffected, result.Error
+}
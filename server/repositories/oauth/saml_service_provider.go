@@ -0,0 +1,89 @@
+package oauthrepositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models/oauth"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// SAMLServiceProviderRepository SAML服务提供方仓库实现
+type SAMLServiceProviderRepository struct {
+	db *gorm.DB
+}
+
+// NewSAMLServiceProviderRepository 创建SAML服务提供方仓库实例
+func NewSAMLServiceProviderRepository(db *gorm.DB) *SAMLServiceProviderRepository {
+	return &SAMLServiceProviderRepository{
+		db: db,
+	}
+}
+
+// Create 创建SAML服务提供方
+func (r *SAMLServiceProviderRepository) Create(ctx context.Context, sp *oauthmodels.SAMLServiceProvider) error {
+	sp.TenantID = utils.TenantID(ctx)
+	return r.db.WithContext(ctx).Create(sp).Error
+}
+
+// Get 根据传入的条件查询SAML服务提供方
+func (r *SAMLServiceProviderRepository) Get(ctx context.Context, conds map[string]any) (*oauthmodels.SAMLServiceProvider, error) {
+	var sp oauthmodels.SAMLServiceProvider
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.SAMLServiceProvider{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&sp).Error; err != nil {
+		return nil, err
+	}
+
+	return &sp, nil
+}
+
+// Count 统计符合条件的SAML服务提供方数量
+func (r *SAMLServiceProviderRepository) Count(ctx context.Context, conds map[string]any) (int64, error) {
+	var count int64
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.SAMLServiceProvider{})
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Update 更新SAML服务提供方信息
+func (r *SAMLServiceProviderRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.SAMLServiceProvider{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 软删除SAML服务提供方
+func (r *SAMLServiceProviderRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Delete(&oauthmodels.SAMLServiceProvider{}, id).Error
+}
+
+// List 分页查询SAML服务提供方列表
+func (r *SAMLServiceProviderRepository) List(ctx context.Context, page, pageSize int, conds map[string]any) ([]oauthmodels.SAMLServiceProvider, int64, error) {
+	var sps []oauthmodels.SAMLServiceProvider
+	var total int64
+
+	query := r.db.WithContext(ctx).Scopes(repositories.TenantScope(ctx)).Model(&oauthmodels.SAMLServiceProvider{})
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&sps).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return sps, total, nil
+}
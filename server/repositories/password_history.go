@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// PasswordHistoryRepository 密码历史仓库实现
+type PasswordHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordHistoryRepository 创建密码历史仓库实例
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{
+		db: db,
+	}
+}
+
+// FindRecent 获取用户最近的 limit 条密码历史，按时间倒序
+func (r *PasswordHistoryRepository) FindRecent(ctx context.Context, userID uint, limit int) ([]models.UserPasswordHistory, error) {
+	var histories []models.UserPasswordHistory
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&histories).Error
+	return histories, err
+}
+
+// CreateWithTx 在事务中写入一条密码历史
+func (r *PasswordHistoryRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, history *models.UserPasswordHistory) error {
+	return tx.WithContext(ctx).Create(history).Error
+}
+
+// PruneWithTx 在事务中只保留用户最近的 keep 条密码历史
+func (r *PasswordHistoryRepository) PruneWithTx(ctx context.Context, tx *gorm.DB, userID uint, keep int) error {
+	var ids []uint
+	if keep > 0 {
+		if err := tx.WithContext(ctx).Model(&models.UserPasswordHistory{}).Where("user_id = ?", userID).Order("id DESC").Limit(keep).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+	}
+	query := tx.WithContext(ctx).Where("user_id = ?", userID)
+	if len(ids) > 0 {
+		query = query.Where("id NOT IN ?", ids)
+	}
+	return query.Delete(&models.UserPasswordHistory{}).Error
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// PermissionRepository 权限仓库实现，权限由启动时同步写入，这里只做查询
+type PermissionRepository struct {
+	db *gorm.DB
+}
+
+// NewPermissionRepository 创建权限仓库实例
+func NewPermissionRepository(db *gorm.DB) *PermissionRepository {
+	return &PermissionRepository{
+		db: db,
+	}
+}
+
+// List 查询全部权限，按ID排序
+func (r *PermissionRepository) List(ctx context.Context) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&permissions).Error
+	return permissions, err
+}
+
+// FindByNames 按名称批量查询权限
+func (r *PermissionRepository) FindByNames(ctx context.Context, names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	err := r.db.WithContext(ctx).Where("name IN ?", names).Order("id ASC").Find(&permissions).Error
+	return permissions, err
+}
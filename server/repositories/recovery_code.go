@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// RecoveryCodeRepository 两步验证恢复码仓库实现
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository 创建恢复码仓库实例
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{
+		db: db,
+	}
+}
+
+// ReplaceWithTx 在事务中作废用户的全部旧恢复码并写入新的一组
+func (r *RecoveryCodeRepository) ReplaceWithTx(ctx context.Context, tx *gorm.DB, userID uint, codes []models.UserRecoveryCode) error {
+	if err := r.DeleteByUserWithTx(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.WithContext(ctx).Create(&codes).Error
+}
+
+// DeleteByUserWithTx 在事务中删除用户的全部恢复码
+func (r *RecoveryCodeRepository) DeleteByUserWithTx(ctx context.Context, tx *gorm.DB, userID uint) error {
+	return tx.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error
+}
+
+// Use 将用户未使用的恢复码标记为已使用，返回 0 表示恢复码不存在或已被使用
+func (r *RecoveryCodeRepository) Use(ctx context.Context, userID uint, codeHash string, usedAt time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", usedAt)
+	return result.RowsAffected, result.Error
+}
+
+// CountUnused 统计用户剩余可用的恢复码数量
+func (r *RecoveryCodeRepository) CountUnused(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// RefreshTokenRepository 第一方刷新令牌仓库实现
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository 创建第一方刷新令牌仓库实例
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: db,
+	}
+}
+
+// CreateWithTx 在事务中创建刷新令牌
+func (r *RefreshTokenRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, refreshToken *models.RefreshToken) error {
+	return tx.WithContext(ctx).Create(refreshToken).Error
+}
+
+// Get 根据传入的条件查询刷新令牌
+func (r *RefreshTokenRepository) Get(ctx context.Context, conds map[string]any) (*models.RefreshToken, error) {
+	var refreshToken models.RefreshToken
+	query := r.db.WithContext(ctx).Model(&models.RefreshToken{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+
+	return &refreshToken, nil
+}
+
+// MarkReplacedWithTx 在事务中将未轮换的刷新令牌标记为已轮换，返回受影响行数；
+// 返回 0 表示令牌已被并发请求抢先轮换
+func (r *RefreshTokenRepository) MarkReplacedWithTx(ctx context.Context, tx *gorm.DB, id uint, replacedAt time.Time) (int64, error) {
+	result := tx.WithContext(ctx).Model(&models.RefreshToken{}).Where("id = ? AND replaced_at IS NULL", id).Update("replaced_at", replacedAt)
+	return result.RowsAffected, result.Error
+}
+
+// PurgeStale 物理删除一批 before 之前已过期或已轮换的刷新令牌，返回删除的行数
+// 已轮换的令牌保留到 before 之后才删除，保留期内仍可用于重复使用检测
+func (r *RefreshTokenRepository) PurgeStale(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("expires_at < ? OR replaced_at < ?", before, before).
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Delete(&models.RefreshToken{}, ids)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,354 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// UserHasRoleCondition 筛选拥有指定角色的用户（直接分配或经由用户组），参数为角色名称，用作用户列表的查询条件
+const UserHasRoleCondition = "id IN (SELECT t.user_id FROM (" +
+	"SELECT user_id, role_id FROM user_roles UNION " +
+	"SELECT user_group_members.user_id, user_group_roles.role_id FROM user_group_members " +
+	"JOIN user_group_roles ON user_group_roles.group_id = user_group_members.group_id " +
+	"JOIN user_groups ON user_groups.id = user_group_members.group_id AND user_groups.deleted_at IS NULL" +
+	") t JOIN roles ON roles.id = t.role_id WHERE roles.name = ?)"
+
+// RoleRepository 角色仓库实现，同时维护角色与权限、用户、用户组的关联
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository 创建角色仓库实例
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{
+		db: db,
+	}
+}
+
+// Create 创建角色并写入权限
+func (r *RoleRepository) Create(ctx context.Context, role *models.Role, permissionIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		role.TenantID = utils.TenantID(ctx)
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		return r.addPermissions(tx, role.ID, permissionIDs)
+	})
+}
+
+// Get 根据传入的条件查询角色
+func (r *RoleRepository) Get(ctx context.Context, conds map[string]any) (*models.Role, error) {
+	var role models.Role
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.Role{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&role).Error; err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// List 查询全部角色，按ID排序
+func (r *RoleRepository) List(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Order("id ASC").Find(&roles).Error
+	return roles, err
+}
+
+// FindByNames 按名称批量查询角色
+func (r *RoleRepository) FindByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	var roles []models.Role
+	if len(names) == 0 {
+		return roles, nil
+	}
+	err := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Where("name IN ?", names).Order("id ASC").Find(&roles).Error
+	return roles, err
+}
+
+// FindByIDs 按ID批量查询角色
+func (r *RoleRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Role, error) {
+	var roles []models.Role
+	if len(ids) == 0 {
+		return roles, nil
+	}
+	err := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Where("id IN ?", ids).Order("id ASC").Find(&roles).Error
+	return roles, err
+}
+
+// Update 更新角色，permissionIDs 不为 nil 时整体替换角色的权限
+func (r *RoleRepository) Update(ctx context.Context, id uint, updates map[string]any, permissionIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if updates == nil {
+			updates = map[string]any{}
+		}
+		updates["updated_at"] = time.Now()
+		result := tx.Scopes(TenantScope(ctx)).Model(&models.Role{}).Where("id = ?", id).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if permissionIDs == nil {
+			return nil
+		}
+		if err := tx.Where("role_id = ?", id).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return r.addPermissions(tx, id, permissionIDs)
+	})
+}
+
+// Delete 删除角色，并解除与权限、用户、用户组的全部关联
+func (r *RoleRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(TenantScope(ctx)).Delete(&models.Role{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for _, link := range []any{&models.RolePermission{}, &models.UserRole{}, &models.UserGroupRole{}} {
+			if err := tx.Where("role_id = ?", id).Delete(link).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SeedBuiltinWithTx 在事务中为 context 所属的租户创建内置角色，已存在时保留，并把尚未授予的权限补给管理员角色
+// 权限表须已同步；启动时对每个租户执行一次，新建租户时同样调用
+func (r *RoleRepository) SeedBuiltinWithTx(ctx context.Context, tx *gorm.DB) error {
+	tenantID := utils.TenantID(ctx)
+	roles := []models.Role{
+		{TenantID: tenantID, Name: models.RoleAdmin, Description: "管理员，拥有全部权限", Builtin: true},
+		{TenantID: tenantID, Name: models.RoleUser, Description: "普通用户，新账号的默认角色", Builtin: true},
+	}
+	if err := tx.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]any{"builtin": true}),
+	}).Create(&roles).Error; err != nil {
+		return err
+	}
+
+	return tx.WithContext(ctx).Exec(
+		"INSERT INTO role_permissions (role_id, permission_id, created_at) "+
+			"SELECT roles.id, permissions.id, ? FROM roles CROSS JOIN permissions "+
+			"WHERE roles.tenant_id = ? AND roles.name = ? "+
+			"AND NOT EXISTS (SELECT 1 FROM role_permissions rp WHERE rp.role_id = roles.id AND rp.permission_id = permissions.id)",
+		time.Now(), tenantID, models.RoleAdmin,
+	).Error
+}
+
+// ListRolePermissions 查询角色拥有的权限
+func (r *RoleRepository) ListRolePermissions(ctx context.Context, roleIDs []uint) (map[uint][]models.Permission, error) {
+	result := make(map[uint][]models.Permission)
+	if len(roleIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		models.Permission
+		OwnerRoleID uint
+	}
+	err := r.db.WithContext(ctx).Model(&models.Permission{}).
+		Select("permissions.*, role_permissions.role_id AS owner_role_id").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Where("role_permissions.role_id IN ?", roleIDs).
+		Order("permissions.id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.OwnerRoleID] = append(result[row.OwnerRoleID], row.Permission)
+	}
+	return result, nil
+}
+
+// ListPermissionNames 查询一组角色拥有的权限名称，已去重
+func (r *RoleRepository) ListPermissionNames(ctx context.Context, roleIDs []uint) ([]string, error) {
+	var names []string
+	if len(roleIDs) == 0 {
+		return names, nil
+	}
+	err := r.db.WithContext(ctx).Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Where("role_permissions.role_id IN ?", roleIDs).
+		Order("permissions.name ASC").
+		Pluck("permissions.name", &names).Error
+	return names, err
+}
+
+// ListUserRoles 查询直接分配给用户的角色
+func (r *RoleRepository) ListUserRoles(ctx context.Context, userIDs []uint) (map[uint][]models.Role, error) {
+	result := make(map[uint][]models.Role)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var links []models.UserRole
+	if err := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	owners := make([]roleOwner, len(links))
+	for i, link := range links {
+		owners[i] = roleOwner{owner: link.UserID, roleID: link.RoleID}
+	}
+	return r.groupRoles(ctx, owners, result)
+}
+
+// ListEffectiveRoles 查询用户实际拥有的角色：直接分配的角色，以及所属用户组（未删除）的角色
+func (r *RoleRepository) ListEffectiveRoles(ctx context.Context, userIDs []uint) (map[uint][]models.Role, error) {
+	result := make(map[uint][]models.Role)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var links []models.UserRole
+	err := r.db.WithContext(ctx).Raw(
+		"SELECT user_id, role_id FROM user_roles WHERE user_id IN ? UNION "+
+			"SELECT user_group_members.user_id, user_group_roles.role_id FROM user_group_members "+
+			"JOIN user_group_roles ON user_group_roles.group_id = user_group_members.group_id "+
+			"JOIN user_groups ON user_groups.id = user_group_members.group_id AND user_groups.deleted_at IS NULL "+
+			"WHERE user_group_members.user_id IN ?",
+		userIDs, userIDs,
+	).Scan(&links).Error
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]roleOwner, len(links))
+	for i, link := range links {
+		owners[i] = roleOwner{owner: link.UserID, roleID: link.RoleID}
+	}
+	return r.groupRoles(ctx, owners, result)
+}
+
+// SetUserRolesWithTx 在事务中整体替换直接分配给用户的角色
+func (r *RoleRepository) SetUserRolesWithTx(ctx context.Context, tx *gorm.DB, userID uint, roleIDs []uint) error {
+	if err := tx.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+	if len(roleIDs) == 0 {
+		return nil
+	}
+	links := make([]models.UserRole, len(roleIDs))
+	for i, roleID := range roleIDs {
+		links[i] = models.UserRole{UserID: userID, RoleID: roleID}
+	}
+	return tx.WithContext(ctx).Create(&links).Error
+}
+
+// SetUserRoles 整体替换直接分配给用户的角色
+func (r *RoleRepository) SetUserRoles(ctx context.Context, userID uint, roleIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return r.SetUserRolesWithTx(ctx, tx, userID, roleIDs)
+	})
+}
+
+// AddUserRoleByNameWithTx 在事务中按名称为用户添加角色，角色不存在时不做处理
+func (r *RoleRepository) AddUserRoleByNameWithTx(ctx context.Context, tx *gorm.DB, userID uint, name string) error {
+	return tx.WithContext(ctx).Exec(
+		"INSERT INTO user_roles (user_id, role_id, created_at) SELECT ?, id, ? FROM roles WHERE tenant_id = ? AND name = ?",
+		userID, time.Now(), utils.TenantID(ctx), name,
+	).Error
+}
+
+// ListGroupRoles 查询分配给用户组的角色
+func (r *RoleRepository) ListGroupRoles(ctx context.Context, groupIDs []uint) (map[uint][]models.Role, error) {
+	result := make(map[uint][]models.Role)
+	if len(groupIDs) == 0 {
+		return result, nil
+	}
+
+	var links []models.UserGroupRole
+	if err := r.db.WithContext(ctx).Where("group_id IN ?", groupIDs).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	owners := make([]roleOwner, len(links))
+	for i, link := range links {
+		owners[i] = roleOwner{owner: link.GroupID, roleID: link.RoleID}
+	}
+	return r.groupRoles(ctx, owners, result)
+}
+
+// SetGroupRoles 整体替换分配给用户组的角色
+func (r *RoleRepository) SetGroupRoles(ctx context.Context, groupID uint, roleIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", groupID).Delete(&models.UserGroupRole{}).Error; err != nil {
+			return err
+		}
+		if len(roleIDs) == 0 {
+			return nil
+		}
+		links := make([]models.UserGroupRole, len(roleIDs))
+		for i, roleID := range roleIDs {
+			links[i] = models.UserGroupRole{GroupID: groupID, RoleID: roleID}
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+// DeleteGroupRoles 解除用户组的全部角色，用户组删除时调用
+func (r *RoleRepository) DeleteGroupRoles(ctx context.Context, groupID uint) error {
+	return r.db.WithContext(ctx).Where("group_id = ?", groupID).Delete(&models.UserGroupRole{}).Error
+}
+
+// roleOwner 角色与其所属的用户或用户组
+type roleOwner struct {
+	owner  uint
+	roleID uint
+}
+
+// groupRoles 按关联关系加载角色，并按所属的用户或用户组归类，每组内按角色ID排序
+func (r *RoleRepository) groupRoles(ctx context.Context, owners []roleOwner, result map[uint][]models.Role) (map[uint][]models.Role, error) {
+	if len(owners) == 0 {
+		return result, nil
+	}
+
+	owned := make(map[uint]map[uint]bool)
+	var roleIDs []uint
+	for _, o := range owners {
+		if owned[o.owner] == nil {
+			owned[o.owner] = make(map[uint]bool)
+		}
+		owned[o.owner][o.roleID] = true
+		roleIDs = append(roleIDs, o.roleID)
+	}
+	roles, err := r.FindByIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		for owner, ids := range owned {
+			if ids[role.ID] {
+				result[owner] = append(result[owner], role)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *RoleRepository) addPermissions(tx *gorm.DB, roleID uint, permissionIDs []uint) error {
+	if len(permissionIDs) == 0 {
+		return nil
+	}
+	links := make([]models.RolePermission, len(permissionIDs))
+	for i, permissionID := range permissionIDs {
+		links[i] = models.RolePermission{RoleID: roleID, PermissionID: permissionID}
+	}
+	return tx.Create(&links).Error
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// SessionRepository 登录会话仓库实现
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository 创建登录会话仓库实例
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+// Create 创建登录会话
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+// CreateWithTx 在事务中创建登录会话
+func (r *SessionRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, session *models.Session) error {
+	return tx.WithContext(ctx).Create(session).Error
+}
+
+// Get 根据传入的条件查询登录会话
+func (r *SessionRepository) Get(ctx context.Context, conds map[string]any) (*models.Session, error) {
+	var session models.Session
+	query := r.db.WithContext(ctx).Model(&models.Session{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Find 根据传入的条件查询全部登录会话，按最后活跃时间倒序
+func (r *SessionRepository) Find(ctx context.Context, conds map[string]any) ([]models.Session, error) {
+	var sessions []models.Session
+	query := r.db.WithContext(ctx).Model(&models.Session{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Update 更新登录会话信息
+func (r *SessionRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// UpdateWithTx 在事务中更新登录会话信息
+func (r *SessionRepository) UpdateWithTx(ctx context.Context, tx *gorm.DB, id uint, updates map[string]any) error {
+	return tx.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// UpdateByConds 按条件批量更新登录会话
+func (r *SessionRepository) UpdateByConds(ctx context.Context, conds map[string]any, updates map[string]any) error {
+	query := r.db.WithContext(ctx).Model(&models.Session{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	return query.Updates(updates).Error
+}
+
+// DB 返回数据库连接实例
+func (r *SessionRepository) DB() *gorm.DB {
+	return r.db
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// TenantScope 把查询限定在 context 所属的租户内，归属于租户的表在仓库中的每条查询都须带上
+// 条件与调用方传入的 conds 以 AND 连接，调用方无法借助 conds 越过租户边界
+func TenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenantID := utils.TenantID(ctx)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"}, Value: tenantID})
+	}
+}
+
+// TenantRepository 租户仓库实现，租户表本身不按租户隔离
+type TenantRepository struct {
+	db *gorm.DB
+}
+
+// NewTenantRepository 创建租户仓库实例
+func NewTenantRepository(db *gorm.DB) *TenantRepository {
+	return &TenantRepository{
+		db: db,
+	}
+}
+
+// CreateWithTx 在事务中创建租户
+func (r *TenantRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, tenant *models.Tenant) error {
+	return tx.WithContext(ctx).Create(tenant).Error
+}
+
+// Get 根据传入的条件查询租户
+func (r *TenantRepository) Get(ctx context.Context, conds map[string]any) (*models.Tenant, error) {
+	var tenant models.Tenant
+	query := r.db.WithContext(ctx).Model(&models.Tenant{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&tenant).Error; err != nil {
+		return nil, err
+	}
+
+	return &tenant, nil
+}
+
+// List 查询全部租户，按ID排序
+func (r *TenantRepository) List(ctx context.Context) ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&tenants).Error
+	return tenants, err
+}
+
+// Update 更新租户信息
+func (r *TenantRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Model(&models.Tenant{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// SetSAMLKeyPairIfEmpty 租户尚无 SAML 签名证书时写入，返回是否写入；并发生成时只有一份生效
+func (r *TenantRepository) SetSAMLKeyPairIfEmpty(ctx context.Context, id uint, certificate, privateKey string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Tenant{}).
+		Where("id = ? AND (saml_certificate IS NULL OR saml_certificate = '')", id).
+		Updates(map[string]any{"saml_certificate": certificate, "saml_private_key": privateKey})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DB 返回数据库连接实例
+func (r *TenantRepository) DB() *gorm.DB {
+	return r.db
+}
@@ -6,6 +6,7 @@ import (
 	"gorm.io/gorm"
 
 	"goauth/models"
+	"goauth/utils"
 )
 
 // UserRepository 用户仓库实现
@@ -22,17 +23,19 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 
 // Create 创建用户
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	user.TenantID = utils.TenantID(ctx)
 	return r.db.WithContext(ctx).Create(user).Error
 }
 
 func (r *UserRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, user *models.User) error {
+	user.TenantID = utils.TenantID(ctx)
 	return tx.WithContext(ctx).Create(user).Error
 }
 
 // Get 根据传入的条件查询用户
 func (r *UserRepository) Get(ctx context.Context, conds map[string]any) (*models.User, error) {
 	var user models.User
-	query := r.db.WithContext(ctx).Model(&models.User{})
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.User{})
 
 	for key, value := range conds {
 		query = query.Where(key, value)
@@ -48,7 +51,7 @@ func (r *UserRepository) Get(ctx context.Context, conds map[string]any) (*models
 // GetWithDeleted 根据传入的条件查询用户（包含已软删除的记录）
 func (r *UserRepository) GetWithDeleted(ctx context.Context, conds map[string]any) (*models.User, error) {
 	var user models.User
-	query := r.db.WithContext(ctx).Unscoped().Model(&models.User{})
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Unscoped().Model(&models.User{})
 
 	for key, value := range conds {
 		query = query.Where(key, value)
@@ -63,11 +66,17 @@ func (r *UserRepository) GetWithDeleted(ctx context.Context, conds map[string]an
 
 // Update 更新用户信息
 func (r *UserRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
-	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+	return r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
 }
 
 func (r *UserRepository) UpdateWithTx(ctx context.Context, tx *gorm.DB, id uint, updates map[string]any) error {
-	return tx.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+	return tx.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// UpdateStatus 仅在当前状态为 from 时改为 to，返回 0 表示状态已被修改
+func (r *UserRepository) UpdateStatus(ctx context.Context, id uint, from, to int) (int64, error) {
+	result := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.User{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	return result.RowsAffected, result.Error
 }
 
 // DB 返回数据库连接实例
@@ -77,7 +86,12 @@ func (r *UserRepository) DB() *gorm.DB {
 
 // Delete 软删除用户
 func (r *UserRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
+	return r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Delete(&models.User{}, id).Error
+}
+
+// DeleteWithTx 在事务中软删除用户
+func (r *UserRepository) DeleteWithTx(ctx context.Context, tx *gorm.DB, id uint) error {
+	return tx.WithContext(ctx).Scopes(TenantScope(ctx)).Delete(&models.User{}, id).Error
 }
 
 // List 分页查询用户列表
@@ -86,7 +100,7 @@ func (r *UserRepository) List(ctx context.Context, page, pageSize int, conds map
 	var total int64
 
 	// 计算总数
-	query := r.db.WithContext(ctx).Model(&models.User{})
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.User{})
 	for key, value := range conds {
 		query = query.Where(key, value)
 	}
@@ -107,3 +121,37 @@ func (r *UserRepository) List(ctx context.Context, page, pageSize int, conds map
 
 	return users, total, nil
 }
+
+// Search 按查询表达式分页查询用户，按ID排序，供 SCIM 过滤使用
+func (r *UserRepository) Search(ctx context.Context, offset, limit int, query string, args ...any) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	db := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.User{})
+	if query != "" {
+		db = db.Where(query, args...)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 {
+		return users, total, nil
+	}
+
+	if err := db.Order("id ASC").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// FindByIDs 按ID批量查询用户
+func (r *UserRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,209 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// UserGroupRepository 用户组仓库实现
+type UserGroupRepository struct {
+	db *gorm.DB
+}
+
+// NewUserGroupRepository 创建用户组仓库实例
+func NewUserGroupRepository(db *gorm.DB) *UserGroupRepository {
+	return &UserGroupRepository{
+		db: db,
+	}
+}
+
+// Create 创建用户组并写入成员
+func (r *UserGroupRepository) Create(ctx context.Context, group *models.UserGroup, userIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group.TenantID = utils.TenantID(ctx)
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return r.addMembers(tx, group.ID, userIDs)
+	})
+}
+
+// Get 根据传入的条件查询用户组
+func (r *UserGroupRepository) Get(ctx context.Context, conds map[string]any) (*models.UserGroup, error) {
+	var group models.UserGroup
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.UserGroup{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&group).Error; err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// Count 根据传入的条件统计用户组数量
+func (r *UserGroupRepository) Count(ctx context.Context, conds map[string]any) (int64, error) {
+	var count int64
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.UserGroup{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Search 按查询表达式分页查询用户组，按ID排序
+func (r *UserGroupRepository) Search(ctx context.Context, offset, limit int, query string, args ...any) ([]models.UserGroup, int64, error) {
+	var groups []models.UserGroup
+	var total int64
+
+	db := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.UserGroup{})
+	if query != "" {
+		db = db.Where(query, args...)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if limit == 0 {
+		return groups, total, nil
+	}
+
+	if err := db.Order("id ASC").Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// Update 更新用户组，userIDs 不为 nil 时同步成员，成员变化同样刷新更新时间
+func (r *UserGroupRepository) Update(ctx context.Context, id uint, updates map[string]any, userIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if updates == nil {
+			updates = map[string]any{}
+		}
+		updates["updated_at"] = time.Now()
+		result := tx.Scopes(TenantScope(ctx)).Model(&models.UserGroup{}).Where("id = ?", id).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if userIDs == nil {
+			return nil
+		}
+
+		var current []uint
+		if err := tx.Model(&models.UserGroupMember{}).Where("group_id = ?", id).Pluck("user_id", &current).Error; err != nil {
+			return err
+		}
+		wanted := make(map[uint]bool, len(userIDs))
+		for _, userID := range userIDs {
+			wanted[userID] = true
+		}
+		var removed []uint
+		for _, userID := range current {
+			if !wanted[userID] {
+				removed = append(removed, userID)
+			}
+			delete(wanted, userID)
+		}
+		if len(removed) > 0 {
+			if err := tx.Where("group_id = ? AND user_id IN ?", id, removed).Delete(&models.UserGroupMember{}).Error; err != nil {
+				return err
+			}
+		}
+		added := make([]uint, 0, len(wanted))
+		for userID := range wanted {
+			added = append(added, userID)
+		}
+		return r.addMembers(tx, id, added)
+	})
+}
+
+// Delete 软删除用户组并移除全部成员与角色
+func (r *UserGroupRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(TenantScope(ctx)).Delete(&models.UserGroup{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for _, link := range []any{&models.UserGroupMember{}, &models.UserGroupRole{}} {
+			if err := tx.Where("group_id = ?", id).Delete(link).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListMembers 查询用户组的成员，已删除的用户不计入
+func (r *UserGroupRepository) ListMembers(ctx context.Context, groupIDs []uint) ([]models.UserGroupMember, error) {
+	var members []models.UserGroupMember
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+	err := r.db.WithContext(ctx).Model(&models.UserGroupMember{}).
+		Joins("JOIN users ON users.id = user_group_members.user_id AND users.deleted_at IS NULL").
+		Where("user_group_members.group_id IN ?", groupIDs).
+		Order("user_group_members.user_id ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// ListUserGroups 查询用户所属的用户组
+func (r *UserGroupRepository) ListUserGroups(ctx context.Context, userIDs []uint) (map[uint][]models.UserGroup, error) {
+	result := make(map[uint][]models.UserGroup)
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		models.UserGroup
+		MemberUserID uint
+	}
+	err := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.UserGroup{}).
+		Select("user_groups.*, user_group_members.user_id AS member_user_id").
+		Joins("JOIN user_group_members ON user_group_members.group_id = user_groups.id").
+		Where("user_group_members.user_id IN ?", userIDs).
+		Order("user_groups.id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.MemberUserID] = append(result[row.MemberUserID], row.UserGroup)
+	}
+	return result, nil
+}
+
+// DeleteUserMemberships 将用户从所有用户组中移除
+func (r *UserGroupRepository) DeleteUserMemberships(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.UserGroupMember{}).Error
+}
+
+func (r *UserGroupRepository) addMembers(tx *gorm.DB, groupID uint, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	members := make([]models.UserGroupMember, len(userIDs))
+	for i, userID := range userIDs {
+		members[i] = models.UserGroupMember{GroupID: groupID, UserID: userID}
+	}
+	return tx.Create(&members).Error
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// UserTOTPRepository TOTP 验证器绑定仓库实现
+type UserTOTPRepository struct {
+	db *gorm.DB
+}
+
+// NewUserTOTPRepository 创建 TOTP 验证器绑定仓库实例
+func NewUserTOTPRepository(db *gorm.DB) *UserTOTPRepository {
+	return &UserTOTPRepository{
+		db: db,
+	}
+}
+
+// Get 根据传入的条件查询 TOTP 绑定
+func (r *UserTOTPRepository) Get(ctx context.Context, conds map[string]any) (*models.UserTOTP, error) {
+	var totp models.UserTOTP
+	query := r.db.WithContext(ctx).Model(&models.UserTOTP{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&totp).Error; err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// Replace 覆盖用户的 TOTP 绑定（删除旧记录后新建）
+func (r *UserTOTPRepository) Replace(ctx context.Context, totp *models.UserTOTP) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.DeleteByUserWithTx(ctx, tx, totp.UserID); err != nil {
+			return err
+		}
+		return tx.Create(totp).Error
+	})
+}
+
+// UpdateWithTx 在事务中更新 TOTP 绑定
+func (r *UserTOTPRepository) UpdateWithTx(ctx context.Context, tx *gorm.DB, id uint, updates map[string]any) error {
+	return tx.WithContext(ctx).Model(&models.UserTOTP{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// AdvanceStep 记录最近通过校验的时间步，只允许前进，返回 0 表示该时间步已被使用（重放）
+func (r *UserTOTPRepository) AdvanceStep(ctx context.Context, id uint, step int64) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.UserTOTP{}).Where("id = ? AND last_used_step < ?", id, step).Update("last_used_step", step)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByUserWithTx 在事务中删除用户的 TOTP 绑定
+func (r *UserTOTPRepository) DeleteByUserWithTx(ctx context.Context, tx *gorm.DB, userID uint) error {
+	return tx.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+)
+
+// WebAuthnCredentialRepository 通行密钥仓库实现
+type WebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnCredentialRepository 创建通行密钥仓库实例
+func NewWebAuthnCredentialRepository(db *gorm.DB) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{
+		db: db,
+	}
+}
+
+// Create 创建通行密钥
+func (r *WebAuthnCredentialRepository) Create(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+// Get 根据传入的条件查询通行密钥
+func (r *WebAuthnCredentialRepository) Get(ctx context.Context, conds map[string]any) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	query := r.db.WithContext(ctx).Model(&models.WebAuthnCredential{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&credential).Error; err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// Find 根据传入的条件查询通行密钥列表，按创建时间排序
+func (r *WebAuthnCredentialRepository) Find(ctx context.Context, conds map[string]any) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	query := r.db.WithContext(ctx).Model(&models.WebAuthnCredential{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Order("created_at ASC").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// Count 根据传入的条件统计通行密钥数量
+func (r *WebAuthnCredentialRepository) Count(ctx context.Context, conds map[string]any) (int64, error) {
+	var count int64
+	query := r.db.WithContext(ctx).Model(&models.WebAuthnCredential{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RecordUse 记录一次成功的断言，签名计数器以比较并交换方式更新
+// 返回 0 表示计数器已被并发请求更新
+func (r *WebAuthnCredentialRepository) RecordUse(ctx context.Context, id uint, oldSignCount, newSignCount uint32, at time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, oldSignCount).
+		Updates(map[string]any{"sign_count": newSignCount, "last_used_at": at})
+	return result.RowsAffected, result.Error
+}
+
+// Update 根据ID更新通行密钥
+func (r *WebAuthnCredentialRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 根据条件删除通行密钥，返回删除的行数
+func (r *WebAuthnCredentialRepository) Delete(ctx context.Context, conds map[string]any) (int64, error) {
+	query := r.db.WithContext(ctx)
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	result := query.Delete(&models.WebAuthnCredential{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByUserWithTx 在事务中删除用户的全部通行密钥
+func (r *WebAuthnCredentialRepository) DeleteByUserWithTx(ctx context.Context, tx *gorm.DB, userID uint) error {
+	return tx.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.WebAuthnCredential{}).Error
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// WebhookDeliveryRepository Webhook 投递记录（outbox）仓库实现
+type WebhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookDeliveryRepository 创建 Webhook 投递记录仓库实例
+func NewWebhookDeliveryRepository(db *gorm.DB) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{
+		db: db,
+	}
+}
+
+// CreateWithTx 在事务中批量写入投递记录，归属于 context 所属的租户
+func (r *WebhookDeliveryRepository) CreateWithTx(ctx context.Context, tx *gorm.DB, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	tenantID := utils.TenantID(ctx)
+	for i := range deliveries {
+		deliveries[i].TenantID = tenantID
+	}
+	return tx.WithContext(ctx).Create(&deliveries).Error
+}
+
+// DB 返回数据库连接实例
+func (r *WebhookDeliveryRepository) DB() *gorm.DB {
+	return r.db
+}
+
+// FindDue 查询一批已到投递时间的待投递记录，按投递时间排序，不区分租户，供后台投递使用
+func (r *WebhookDeliveryRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Update 更新投递结果，不区分租户，供后台投递使用
+func (r *WebhookDeliveryRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Requeue 将当前租户中投递失败的记录重新放回队列，返回受影响行数；记录不存在或不是 dead 状态时为 0
+func (r *WebhookDeliveryRepository) Requeue(ctx context.Context, id uint, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ?", id, models.WebhookDeliveryDead).
+		Updates(map[string]any{"status": models.WebhookDeliveryPending, "attempts": 0, "next_attempt_at": now, "last_error": ""})
+	return result.RowsAffected, result.Error
+}
+
+// List 分页查询当前租户的投递记录，按ID倒序
+func (r *WebhookDeliveryRepository) List(ctx context.Context, page, pageSize int, conds map[string]any) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.WebhookDelivery{})
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// PurgeStale 物理删除一批 before 之前已投递成功的记录，返回删除的行数；投递失败的记录保留，供管理员处理
+func (r *WebhookDeliveryRepository) PurgeStale(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("status = ? AND delivered_at < ?", models.WebhookDeliveryDelivered, before).
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Delete(&models.WebhookDelivery{}, ids)
+	return result.RowsAffected, result.Error
+}
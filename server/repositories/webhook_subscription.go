@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"goauth/models"
+	"goauth/utils"
+)
+
+// WebhookSubscriptionRepository Webhook 订阅仓库实现
+type WebhookSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookSubscriptionRepository 创建 Webhook 订阅仓库实例
+func NewWebhookSubscriptionRepository(db *gorm.DB) *WebhookSubscriptionRepository {
+	return &WebhookSubscriptionRepository{
+		db: db,
+	}
+}
+
+// Create 创建订阅，归属于 context 所属的租户
+func (r *WebhookSubscriptionRepository) Create(ctx context.Context, subscription *models.WebhookSubscription) error {
+	subscription.TenantID = utils.TenantID(ctx)
+	return r.db.WithContext(ctx).Create(subscription).Error
+}
+
+// Get 根据传入的条件查询订阅
+func (r *WebhookSubscriptionRepository) Get(ctx context.Context, conds map[string]any) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	query := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.WebhookSubscription{})
+
+	for key, value := range conds {
+		query = query.Where(key, value)
+	}
+
+	if err := query.First(&subscription).Error; err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+// List 查询当前租户的全部订阅，按ID排序
+func (r *WebhookSubscriptionRepository) List(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Order("id ASC").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// FindEnabledWithTx 在事务中查询当前租户已启用的订阅
+func (r *WebhookSubscriptionRepository) FindEnabledWithTx(ctx context.Context, tx *gorm.DB) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := tx.WithContext(ctx).Scopes(TenantScope(ctx)).Where("enabled = ?", true).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// FindByIDs 按ID批量查询订阅，不区分租户，供后台投递使用
+func (r *WebhookSubscriptionRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if len(ids) == 0 {
+		return subscriptions, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// Update 更新订阅
+func (r *WebhookSubscriptionRepository) Update(ctx context.Context, id uint, updates map[string]any) error {
+	return r.db.WithContext(ctx).Scopes(TenantScope(ctx)).Model(&models.WebhookSubscription{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 删除订阅及其全部投递记录
+func (r *WebhookSubscriptionRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(TenantScope(ctx)).Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Scopes(TenantScope(ctx)).Delete(&models.WebhookSubscription{}, id).Error
+	})
+}
@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadAccountRoutes(router *gin.Engine, ctrl *controllers.AccountController, m *middleware.Manager) {
+	// 找回密码、邮箱验证与激活链接均无需登录
+	authRouter := router.Group("/api/v1/auth")
+	authRouter.POST("/password/forgot", m.RateLimit("password_reset"), ctrl.ForgotPasswordHandler)
+	authRouter.POST("/password/reset", m.RateLimit("password_reset"), ctrl.ResetPasswordHandler)
+	authRouter.POST("/email/verify", ctrl.VerifyEmailHandler)
+	authRouter.POST("/activation/resend", m.RateLimit("activation_resend"), ctrl.ResendActivationHandler)
+
+	// 重新发送邮箱验证邮件
+	router.POST("/api/v1/users/:user_id/email/verification", m.Auth(), m.Authorize(), m.RateLimit("email_verification"), ctrl.SendEmailVerificationHandler)
+}
@@ -0,0 +1,16 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadActivationRoutes(router *gin.Engine, ctrl *controllers.ActivationController, m *middleware.Manager) {
+	// 注册审核，需要审核权限
+	registrationRouter := router.Group("/api/v1/registrations", m.Auth(), m.Authorize())
+	registrationRouter.GET("", ctrl.ListPendingUsersHandler)
+	registrationRouter.POST("/:user_id/approve", ctrl.ApproveUserHandler)
+	registrationRouter.POST("/:user_id/reject", ctrl.RejectUserHandler)
+}
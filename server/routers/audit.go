@@ -0,0 +1,12 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadAuditRoutes(router *gin.Engine, ctrl *controllers.AuditController, m *middleware.Manager) {
+	router.GET("/api/v1/audit-events", m.Auth(), m.Authorize(), ctrl.ListAuditEventsHandler)
+}
@@ -9,7 +9,15 @@ import (
 
 func LoadAuthRoutes(router *gin.Engine, ctrl *controllers.AuthController, m *middleware.Manager) {
 	authRouter := router.Group("/api/v1/auth")
-	authRouter.POST("/login", ctrl.LoginHandler)
+	authRouter.POST("/login", m.RateLimit("login"), ctrl.LoginHandler)
+	authRouter.POST("/mfa/verify", m.RateLimit("mfa"), ctrl.VerifyMFAHandler)
+	authRouter.POST("/mfa/enroll", m.RateLimit("mfa"), ctrl.SetupMFAEnrollmentHandler)
+	authRouter.POST("/mfa/enroll/confirm", m.RateLimit("mfa"), ctrl.ConfirmMFAEnrollmentHandler)
+	authRouter.POST("/mfa/webauthn/options", m.RateLimit("mfa"), ctrl.BeginMFAWebAuthnHandler)
+	authRouter.POST("/mfa/webauthn/verify", m.RateLimit("mfa"), ctrl.VerifyMFAWebAuthnHandler)
+	authRouter.POST("/passkey/options", m.RateLimit("passkey"), ctrl.BeginPasskeyLoginHandler)
+	authRouter.POST("/passkey/login", m.RateLimit("passkey"), ctrl.FinishPasskeyLoginHandler)
+	authRouter.POST("/federated/login", m.RateLimit("login"), ctrl.FederatedLoginHandler)
 	authRouter.POST("/logout", m.Auth(), ctrl.LogoutHandler)
-	authRouter.POST("/refresh_token", ctrl.RefreshTokenHandler)
+	authRouter.POST("/refresh_token", m.RateLimit("refresh_token"), ctrl.RefreshTokenHandler)
 }
@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadFederationRoutes(router *gin.Engine, ctrl *controllers.FederationController, m *middleware.Manager) {
+	federatedRouter := router.Group("/api/v1/auth/federated")
+	federatedRouter.GET("/providers", ctrl.ListProvidersHandler)
+	federatedRouter.POST("/:provider/authorize", ctrl.AuthorizeHandler)
+	federatedRouter.GET("/:provider/callback", ctrl.CallbackHandler)
+
+	// 外部账号关联只能由本人管理
+	identityRouter := router.Group("/api/v1/federated-identities")
+	identityRouter.GET("", m.Auth(), ctrl.ListIdentitiesHandler)
+	identityRouter.POST("/:provider/link", m.Auth(), ctrl.LinkHandler)
+	identityRouter.DELETE("/:identity_id", m.Auth(), ctrl.UnlinkHandler)
+}
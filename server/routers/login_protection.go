@@ -0,0 +1,14 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadLoginProtectionRoutes(router *gin.Engine, ctrl *controllers.LoginProtectionController, m *middleware.Manager) {
+	// 管理员查看与解除用户的登录锁定
+	router.GET("/api/v1/users/:user_id/lockout", m.Auth(), m.Authorize(), ctrl.GetUserLockoutHandler)
+	router.DELETE("/api/v1/users/:user_id/lockout", m.Auth(), m.Authorize(), ctrl.UnlockUserHandler)
+}
@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadMFARoutes(router *gin.Engine, ctrl *controllers.MFAController, m *middleware.Manager) {
+	mfaRouter := router.Group("/api/v1/mfa")
+	mfaRouter.GET("", m.Auth(), ctrl.GetMFAStatusHandler)
+	mfaRouter.POST("/totp", m.Auth(), ctrl.SetupTOTPHandler)
+	mfaRouter.POST("/totp/confirm", m.Auth(), ctrl.ConfirmTOTPHandler)
+	mfaRouter.DELETE("/totp", m.Auth(), ctrl.DisableTOTPHandler)
+	mfaRouter.POST("/recovery_codes", m.Auth(), ctrl.RegenerateRecoveryCodesHandler)
+
+	// 管理员重置用户的两步验证
+	router.DELETE("/api/v1/users/:user_id/mfa", m.Auth(), m.Authorize(), ctrl.ResetUserMFAHandler)
+}
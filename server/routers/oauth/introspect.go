@@ -9,5 +9,5 @@ import (
 
 func LoadOAuthIntrospectRoutes(router *gin.Engine, oauthIntrospectController *oauthcontrollers.OAuthIntrospectController, m *middleware.Manager) {
 	oauthIntrospectRouter := router.Group("/api/v1/oauth/introspect")
-	oauthIntrospectRouter.POST("", oauthIntrospectController.IntrospectAccessTokenHandler)
+	oauthIntrospectRouter.POST("", m.RateLimit("oauth_client_auth"), m.ClientAuth(), m.RateLimit("oauth_introspect"), oauthIntrospectController.IntrospectAccessTokenHandler)
 }
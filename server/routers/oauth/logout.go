@@ -0,0 +1,15 @@
+package oauthrouters
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers/oauth"
+	"goauth/middleware"
+)
+
+// LoadOAuthLogoutRoutes 注册 OpenID Connect 依赖方发起的登出路由，未登录时同样可以访问
+func LoadOAuthLogoutRoutes(router *gin.Engine, oauthLogoutController *oauthcontrollers.OAuthLogoutController, m *middleware.Manager) {
+	oauthLogoutRouter := router.Group("/api/v1/oauth/logout")
+	oauthLogoutRouter.GET("", m.OptionalAuth(), oauthLogoutController.EndSessionHandler)
+	oauthLogoutRouter.POST("", m.OptionalAuth(), oauthLogoutController.EndSessionHandler)
+}
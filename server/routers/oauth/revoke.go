@@ -4,11 +4,12 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"goauth/controllers/oauth"
+	"goauth/middleware"
 )
 
 // LoadOAuthRevokeRoutes 注册令牌撤销路由（RFC7009）
-func LoadOAuthRevokeRoutes(router *gin.Engine, oauthRevokeController *oauthcontrollers.OAuthRevokeController) {
+func LoadOAuthRevokeRoutes(router *gin.Engine, oauthRevokeController *oauthcontrollers.OAuthRevokeController, m *middleware.Manager) {
 	oauthRevokeRouter := router.Group("/api/v1/oauth/revoke")
-	oauthRevokeRouter.POST("", oauthRevokeController.RevokeTokenHandler)
+	oauthRevokeRouter.POST("", m.RateLimit("oauth_client_auth"), m.ClientAuth(), m.RateLimit("oauth_revoke"), oauthRevokeController.RevokeTokenHandler)
 }
 
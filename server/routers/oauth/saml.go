@@ -0,0 +1,26 @@
+package oauthrouters
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers/oauth"
+	"goauth/middleware"
+)
+
+func LoadSAMLRoutes(router *gin.Engine, ctrl *oauthcontrollers.SAMLController, m *middleware.Manager) {
+	samlRouter := router.Group("/api/v1/saml")
+	samlRouter.GET("/metadata", ctrl.MetadataHandler)
+	samlRouter.GET("/sso", ctrl.SSOHandler)
+	samlRouter.POST("/sso", ctrl.SSOHandler)
+	samlRouter.GET("/sso/continue", m.Auth(), ctrl.ContinueSSOHandler)
+	samlRouter.GET("/idp-initiated/:id", m.Auth(), ctrl.IDPInitiatedHandler)
+}
+
+func LoadSAMLServiceProviderRoutes(router *gin.Engine, ctrl *oauthcontrollers.SAMLServiceProviderController, m *middleware.Manager) {
+	spRouter := router.Group("/api/v1/saml/service-providers")
+	spRouter.POST("", m.Auth(), m.Authorize(), ctrl.CreateSAMLServiceProviderHandler)
+	spRouter.GET("", m.Auth(), m.Authorize(), ctrl.ListSAMLServiceProvidersHandler)
+	spRouter.GET("/:id", m.Auth(), m.Authorize(), ctrl.GetSAMLServiceProviderHandler)
+	spRouter.PATCH("/:id", m.Auth(), m.Authorize(), ctrl.UpdateSAMLServiceProviderHandler)
+	spRouter.DELETE("/:id", m.Auth(), m.Authorize(), ctrl.DeleteSAMLServiceProviderHandler)
+}
@@ -9,5 +9,5 @@ import (
 
 func LoadOAuthTokenRoutes(router *gin.Engine, oauthTokenController *oauthcontrollers.OAuthTokenController, m *middleware.Manager) {
 	oauthTokenRouter := router.Group("/api/v1/oauth/token")
-	oauthTokenRouter.POST("", oauthTokenController.ExchangeAccessTokenHandler)
+	oauthTokenRouter.POST("", m.RateLimit("oauth_client_auth"), m.ClientAuth(), m.RateLimit("oauth_token"), oauthTokenController.ExchangeAccessTokenHandler)
 }
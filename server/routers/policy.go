@@ -0,0 +1,15 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadPolicyRoutes(router *gin.Engine, ctrl *controllers.PolicyController, m *middleware.Manager) {
+	// 供安全审查核对当前生效的访问矩阵
+	policyRouter := router.Group("/api/v1/policies", m.Auth(), m.Authorize())
+	policyRouter.GET("", ctrl.GetPolicyMatrixHandler)
+	policyRouter.POST("/explain", ctrl.ExplainPolicyHandler)
+}
@@ -0,0 +1,30 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadRBACRoutes(router *gin.Engine, ctrl *controllers.RBACController, m *middleware.Manager) {
+	router.GET("/api/v1/permissions", m.Auth(), m.Authorize(), ctrl.ListPermissionsHandler)
+
+	roleRouter := router.Group("/api/v1/roles", m.Auth(), m.Authorize())
+	roleRouter.GET("", ctrl.ListRolesHandler)
+	roleRouter.GET("/:id", ctrl.GetRoleHandler)
+	roleRouter.POST("", ctrl.CreateRoleHandler)
+	roleRouter.PATCH("/:id", ctrl.UpdateRoleHandler)
+	roleRouter.DELETE("/:id", ctrl.DeleteRoleHandler)
+
+	// 用户可以查看自己的角色与权限，分配角色需要管理权限，见授权策略 user_roles_read 与 rbac_write
+	router.GET("/api/v1/users/:user_id/roles", m.Auth(), m.Authorize(), ctrl.GetUserRolesHandler)
+	router.PUT("/api/v1/users/:user_id/roles", m.Auth(), m.Authorize(), ctrl.SetUserRolesHandler)
+
+	groupRouter := router.Group("/api/v1/groups", m.Auth(), m.Authorize())
+	groupRouter.GET("", ctrl.ListGroupsHandler)
+	groupRouter.GET("/:id", ctrl.GetGroupHandler)
+	groupRouter.POST("", ctrl.CreateGroupHandler)
+	groupRouter.PATCH("/:id", ctrl.UpdateGroupHandler)
+	groupRouter.DELETE("/:id", ctrl.DeleteGroupHandler)
+}
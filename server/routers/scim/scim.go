@@ -0,0 +1,31 @@
+package scimrouters
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers/scim"
+	"goauth/middleware"
+	"goauth/middleware/auth"
+)
+
+// LoadSCIMRoutes SCIM 2.0 预配接口，供持有 scim 范围客户端凭证令牌的预配方调用
+func LoadSCIMRoutes(router *gin.Engine, ctrl *scimcontrollers.SCIMController, m *middleware.Manager) {
+	scimRouter := router.Group("/scim/v2", m.AuthBearerOrCookie(auth.BearerAllowClient()), m.Authorize())
+	scimRouter.GET("/ServiceProviderConfig", ctrl.ServiceProviderConfigHandler)
+	scimRouter.GET("/ResourceTypes", ctrl.ResourceTypesHandler)
+	scimRouter.POST("/Bulk", ctrl.BulkHandler)
+
+	scimRouter.GET("/Users", ctrl.ListUsersHandler)
+	scimRouter.POST("/Users", ctrl.CreateUserHandler)
+	scimRouter.GET("/Users/:id", ctrl.GetUserHandler)
+	scimRouter.PUT("/Users/:id", ctrl.ReplaceUserHandler)
+	scimRouter.PATCH("/Users/:id", ctrl.PatchUserHandler)
+	scimRouter.DELETE("/Users/:id", ctrl.DeleteUserHandler)
+
+	scimRouter.GET("/Groups", ctrl.ListGroupsHandler)
+	scimRouter.POST("/Groups", ctrl.CreateGroupHandler)
+	scimRouter.GET("/Groups/:id", ctrl.GetGroupHandler)
+	scimRouter.PUT("/Groups/:id", ctrl.ReplaceGroupHandler)
+	scimRouter.PATCH("/Groups/:id", ctrl.PatchGroupHandler)
+	scimRouter.DELETE("/Groups/:id", ctrl.DeleteGroupHandler)
+}
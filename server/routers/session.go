@@ -0,0 +1,15 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadSessionRoutes(router *gin.Engine, ctrl *controllers.SessionController, m *middleware.Manager) {
+	sessionRouter := router.Group("/api/v1/sessions")
+	sessionRouter.GET("", m.Auth(), ctrl.ListSessionsHandler)
+	sessionRouter.DELETE("/:session_id", m.Auth(), ctrl.RevokeSessionHandler)
+	sessionRouter.DELETE("", m.Auth(), ctrl.RevokeAllSessionsHandler)
+}
@@ -0,0 +1,16 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadTenantRoutes(router *gin.Engine, ctrl *controllers.TenantController, m *middleware.Manager) {
+	// 租户只能由默认租户的管理员管理，其他租户访问时返回 403
+	tenantRouter := router.Group("/api/v1/tenants", m.Auth(), m.Authorize())
+	tenantRouter.GET("", ctrl.ListTenantsHandler)
+	tenantRouter.POST("", ctrl.CreateTenantHandler)
+	tenantRouter.PATCH("/:id", ctrl.UpdateTenantHandler)
+}
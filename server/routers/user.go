@@ -10,9 +10,9 @@ import (
 
 func LoadUserRoutes(router *gin.Engine, ctrl *controllers.UserController, m *middleware.Manager) {
 	userRouter := router.Group("/api/v1/users")
-	userRouter.POST("", ctrl.CreateUserHandler)
-	userRouter.GET("/:user_id", m.AuthBearerOrCookie(auth.BearerAllowClient()), m.ResourceOwner("param"), m.Scope("profile"), ctrl.GetUserHandler)
-	userRouter.PATCH("/:user_id", m.Auth(), m.ResourceOwner("param"), ctrl.UpdateUserHandler)
-	userRouter.GET("", m.Auth(), m.Role("admin"), ctrl.ListUsersHandler)
-	userRouter.DELETE("/:user_id", m.Auth(), m.Role("admin"), ctrl.DeleteUserHandler)
+	userRouter.POST("", m.RateLimit("register"), ctrl.CreateUserHandler)
+	userRouter.GET("/:user_id", m.AuthBearerOrCookie(auth.BearerAllowClient()), m.Authorize(), ctrl.GetUserHandler)
+	userRouter.PATCH("/:user_id", m.Auth(), m.Authorize(), ctrl.UpdateUserHandler)
+	userRouter.GET("", m.Auth(), m.Authorize(), ctrl.ListUsersHandler)
+	userRouter.DELETE("/:user_id", m.Auth(), m.Authorize(), ctrl.DeleteUserHandler)
 }
@@ -0,0 +1,17 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadWebAuthnRoutes(router *gin.Engine, ctrl *controllers.WebAuthnController, m *middleware.Manager) {
+	passkeyRouter := router.Group("/api/v1/passkeys")
+	passkeyRouter.GET("", m.Auth(), ctrl.ListPasskeysHandler)
+	passkeyRouter.POST("/options", m.Auth(), ctrl.BeginRegistrationHandler)
+	passkeyRouter.POST("", m.Auth(), ctrl.FinishRegistrationHandler)
+	passkeyRouter.PATCH("/:passkey_id", m.Auth(), ctrl.RenamePasskeyHandler)
+	passkeyRouter.DELETE("/:passkey_id", m.Auth(), ctrl.DeletePasskeyHandler)
+}
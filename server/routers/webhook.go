@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goauth/controllers"
+	"goauth/middleware"
+)
+
+func LoadWebhookRoutes(router *gin.Engine, ctrl *controllers.WebhookController, m *middleware.Manager) {
+	webhookRouter := router.Group("/api/v1/webhooks", m.Auth(), m.Authorize())
+	webhookRouter.GET("", ctrl.ListWebhooksHandler)
+	webhookRouter.GET("/:id", ctrl.GetWebhookHandler)
+	webhookRouter.POST("", ctrl.CreateWebhookHandler)
+	webhookRouter.PATCH("/:id", ctrl.UpdateWebhookHandler)
+	webhookRouter.DELETE("/:id", ctrl.DeleteWebhookHandler)
+
+	deliveryRouter := router.Group("/api/v1/webhook-deliveries", m.Auth(), m.Authorize())
+	deliveryRouter.GET("", ctrl.ListDeliveriesHandler)
+	deliveryRouter.POST("/:id/retry", ctrl.RetryDeliveryHandler)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/3086953492/gokit/cache"
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/models/oauth"
+	"goauth/repositories/oauth"
+	"goauth/utils"
+)
+
+const (
+	accessTokenCachePrefix          = "oauth_access_token"
+	accessTokenInvalidatedPrefix    = "oauth_access_token_invalidated"
+	accessTokenCacheMaxTTL          = 5 * time.Minute  // 命中记录的最长缓存时间，不超过令牌剩余有效期
+	accessTokenNegativeCacheTTL     = 30 * time.Second // 未找到记录的缓存时间
+	accessTokenInvalidatedMarkerTTL = accessTokenCacheMaxTTL
+)
+
+// cachedAccessToken 缓存条目，Found 为 false 表示数据库中不存在该令牌
+type cachedAccessToken struct {
+	Found bool                          `json:"found"`
+	Token *oauthmodels.OAuthAccessToken `json:"token,omitempty"`
+}
+
+// AccessTokenCache 访问令牌查询缓存，按令牌摘要缓存查库结果（包括未找到）
+// 令牌被撤销、客户端被禁用或删除、用户被删除时必须调用对应的 Invalidate 方法清除缓存
+// 清除时同时写入短期失效标记，撤销提交前读到旧记录的并发请求不会在清除之后把旧记录写回缓存
+// 使用的 cache.Manager 不应开启本地缓存，否则其它实例上的条目无法被及时清除
+type AccessTokenCache struct {
+	cacheMgr                   *cache.Manager
+	oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository
+	logMgr                     *logger.Manager
+}
+
+// NewAccessTokenCache 创建访问令牌查询缓存实例
+func NewAccessTokenCache(cacheMgr *cache.Manager, oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository, logMgr *logger.Manager) *AccessTokenCache {
+	return &AccessTokenCache{cacheMgr: cacheMgr, oauthAccessTokenRepository: oauthAccessTokenRepository, logMgr: logMgr}
+}
+
+// Get 按摘要查询访问令牌，记录不存在时返回 gorm.ErrRecordNotFound
+// 缓存不可用时直接查库
+func (c *AccessTokenCache) Get(ctx context.Context, tokenHash string) (*oauthmodels.OAuthAccessToken, error) {
+	builder := cache.NewBuilder[cachedAccessToken](c.cacheMgr).KeyWithParts(utils.TenantCacheKey(ctx, accessTokenCachePrefix), tokenHash)
+
+	entry, hit, err := builder.Get(ctx)
+	if err != nil {
+		c.logMgr.Warn("读取访问令牌缓存失败", "error", err)
+	}
+	if hit {
+		if !entry.Found {
+			return nil, gorm.ErrRecordNotFound
+		}
+		entry.Token.TokenHash = tokenHash
+		return entry.Token, nil
+	}
+
+	token, err := c.oauthAccessTokenRepository.Get(ctx, map[string]any{"token_hash": tokenHash})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := builder.Set(ctx, &cachedAccessToken{Found: false}, accessTokenNegativeCacheTTL); err != nil {
+				c.logMgr.Warn("写入访问令牌缓存失败", "error", err)
+			}
+		}
+		return nil, err
+	}
+
+	// 缓存时间不超过令牌剩余有效期，已过期的令牌不缓存
+	ttl := min(time.Until(token.ExpiresAt), accessTokenCacheMaxTTL)
+	if ttl > 0 {
+		c.setUnlessInvalidated(ctx, builder, tokenHash, token, ttl)
+	}
+
+	return token, nil
+}
+
+// setUnlessInvalidated 写入命中记录，存在失效标记时不写入
+// 写入后再检查一次失效标记：标记若在写入期间出现则删除刚写入的条目，标记若在检查之后才出现，随后的清除会删除该条目
+// 无法确认失效标记时不缓存，宁可多查一次库也不缓存可能已撤销的记录
+func (c *AccessTokenCache) setUnlessInvalidated(ctx context.Context, builder *cache.Builder[cachedAccessToken], tokenHash string, token *oauthmodels.OAuthAccessToken, ttl time.Duration) {
+	if c.invalidated(ctx, tokenHash) {
+		return
+	}
+	if err := builder.Set(ctx, &cachedAccessToken{Found: true, Token: token}, ttl); err != nil {
+		c.logMgr.Warn("写入访问令牌缓存失败", "error", err)
+		return
+	}
+	if c.invalidated(ctx, tokenHash) {
+		if err := builder.Delete(ctx); err != nil {
+			c.logMgr.Error("删除访问令牌缓存失败", "error", err)
+		}
+	}
+}
+
+// invalidated 检查令牌是否存在失效标记，读取失败时视为存在
+func (c *AccessTokenCache) invalidated(ctx context.Context, tokenHash string) bool {
+	exists, err := c.cacheMgr.Exists(ctx, cache.BuildKey(utils.TenantCacheKey(ctx, accessTokenInvalidatedPrefix), tokenHash))
+	if err != nil {
+		c.logMgr.Warn("读取访问令牌失效标记失败", "error", err)
+		return true
+	}
+	return exists
+}
+
+// Invalidate 清除指定摘要的缓存，先写入失效标记再删除条目
+func (c *AccessTokenCache) Invalidate(ctx context.Context, tokenHashes ...string) error {
+	for _, tokenHash := range tokenHashes {
+		if err := c.cacheMgr.Set(ctx, cache.BuildKey(utils.TenantCacheKey(ctx, accessTokenInvalidatedPrefix), tokenHash), true, accessTokenInvalidatedMarkerTTL); err != nil {
+			return err
+		}
+		if err := c.cacheMgr.Delete(ctx, cache.BuildKey(utils.TenantCacheKey(ctx, accessTokenCachePrefix), tokenHash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateByClient 清除客户端名下所有未过期令牌的缓存
+func (c *AccessTokenCache) InvalidateByClient(ctx context.Context, clientID string) error {
+	return c.invalidateByConds(ctx, map[string]any{"client_id": clientID})
+}
+
+// InvalidateByUser 清除用户名下所有未过期令牌的缓存
+func (c *AccessTokenCache) InvalidateByUser(ctx context.Context, userID uint) error {
+	return c.invalidateByConds(ctx, map[string]any{"user_id": userID})
+}
+
+func (c *AccessTokenCache) invalidateByConds(ctx context.Context, conds map[string]any) error {
+	conds["expires_at > ?"] = time.Now()
+	tokenHashes, err := c.oauthAccessTokenRepository.ListTokenHashes(ctx, conds)
+	if err != nil {
+		return err
+	}
+	return c.Invalidate(ctx, tokenHashes...)
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// AccountService 找回密码、邮箱验证与邮件激活
+// 重置密码令牌绑定签发时的密码哈希，邮箱验证令牌绑定签发时的邮箱，密码或邮箱变化后旧链接自动失效
+type AccountService struct {
+	userRepository *repositories.UserRepository
+	userService    *UserService
+	tokenManager   *AccountTokenManager
+	mailSender     utils.MailSender
+	logMgr         *logger.Manager
+	cfg            appconfig.AccountConfig
+	frontendURL    string
+}
+
+// NewAccountService 创建找回密码与邮箱验证服务实例，frontendURL 用于生成邮件中的链接
+func NewAccountService(userRepository *repositories.UserRepository, userService *UserService, tokenManager *AccountTokenManager, mailSender utils.MailSender, logMgr *logger.Manager, cfg appconfig.AccountConfig, frontendURL string) *AccountService {
+	return &AccountService{userRepository: userRepository, userService: userService, tokenManager: tokenManager, mailSender: mailSender, logMgr: logMgr, cfg: cfg, frontendURL: strings.TrimRight(frontendURL, "/")}
+}
+
+// RequestPasswordReset 向已验证的邮箱发送重置密码链接
+// 邮箱不存在、未验证或账号已禁用时同样返回成功，不暴露邮箱是否已注册
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepository.Get(ctx, map[string]any{"email": normalizeEmail(email)})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("获取用户失败", "error", err)
+			return apperrors.ErrUserSystemBusy
+		}
+		return nil
+	}
+	if user.EmailVerifiedAt == nil || user.Status != models.UserStatusActive {
+		s.logMgr.Info("邮箱未验证或账号不可用，不发送重置密码邮件", "user_id", user.ID)
+		return nil
+	}
+	if user.AuthSource != models.UserAuthSourceLocal {
+		s.logMgr.Info("目录账号的密码不能在本系统重置，不发送重置密码邮件", "user_id", user.ID)
+		return nil
+	}
+
+	token, err := s.tokenManager.Issue(AccountTokenPasswordReset, user.ID, s.tokenManager.Binding(user.Password), s.cfg.PasswordResetTTL)
+	if err != nil {
+		s.logMgr.Error("生成重置密码令牌失败", "error", err, "user_id", user.ID)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	link := s.frontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	if err := s.mailSender.Send(ctx, &utils.MailMessage{
+		To:      []string{*user.Email},
+		Subject: "重置密码",
+		Text: fmt.Sprintf("%s，你好：\n\n我们收到了重置你账号密码的请求，请在 %s 内打开以下链接设置新密码：\n\n%s\n\n如果这不是你本人的操作，请忽略本邮件，你的密码不会被修改。\n",
+			user.Nickname, formatTTL(s.cfg.PasswordResetTTL), link),
+	}); err != nil {
+		// 发送失败同样不返回错误，避免通过响应差异判断邮箱是否已注册
+		s.logMgr.Error("发送重置密码邮件失败", "error", err, "user_id", user.ID)
+		return nil
+	}
+
+	s.logMgr.Info("发送重置密码邮件", "event", "auth.password_reset_requested", "user_id", user.ID)
+	return nil
+}
+
+// ResetPassword 通过重置密码链接设置新密码，成功后该用户的全部登录会话与令牌失效
+func (s *AccountService) ResetPassword(ctx context.Context, req *dto.ResetPasswordRequest) error {
+	claims, err := s.tokenManager.Parse(req.Token, AccountTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	user, err := s.getTokenUser(ctx, claims)
+	if err != nil {
+		return err
+	}
+	// 密码已修改（包括已使用该链接重置过）时旧链接失效
+	if claims.Binding != s.tokenManager.Binding(user.Password) || user.Status != models.UserStatusActive {
+		return apperrors.ErrAccountTokenInvalid
+	}
+
+	if err := s.tokenManager.Consume(ctx, claims); err != nil {
+		return err
+	}
+	// 新密码不符合策略等失败时撤销使用标记，用户可以换一个密码重试
+	if err := s.userService.UpdateUser(ctx, user.ID, &dto.UpdateUserForm{Password: req.Password}, nil); err != nil {
+		s.tokenManager.Release(ctx, claims)
+		return err
+	}
+
+	s.logMgr.Info("通过邮件重置密码", "event", "auth.password_reset", "user_id", user.ID)
+	return nil
+}
+
+// SendEmailVerification 向用户当前的邮箱发送验证链接
+func (s *AccountService) SendEmailVerification(ctx context.Context, userID uint) error {
+	user, err := s.userService.GetUser(ctx, map[string]any{"id": userID})
+	if err != nil {
+		return err
+	}
+	if user.Email == nil {
+		return apperrors.ErrEmailNotSet
+	}
+	if user.EmailVerifiedAt != nil {
+		return apperrors.ErrEmailAlreadyVerified
+	}
+
+	token, err := s.tokenManager.Issue(AccountTokenEmailVerification, user.ID, s.tokenManager.Binding(*user.Email), s.cfg.EmailVerificationTTL)
+	if err != nil {
+		s.logMgr.Error("生成邮箱验证令牌失败", "error", err, "user_id", user.ID)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	link := s.frontendURL + "/verify-email?token=" + url.QueryEscape(token)
+	if err := s.mailSender.Send(ctx, &utils.MailMessage{
+		To:      []string{*user.Email},
+		Subject: "验证你的邮箱",
+		Text: fmt.Sprintf("%s，你好：\n\n请在 %s 内打开以下链接完成邮箱验证：\n\n%s\n\n验证后可以通过该邮箱找回密码。如果这不是你本人的操作，请忽略本邮件。\n",
+			user.Nickname, formatTTL(s.cfg.EmailVerificationTTL), link),
+	}); err != nil {
+		s.logMgr.Error("发送邮箱验证邮件失败", "error", err, "user_id", user.ID)
+		return apperrors.ErrMailSendFailed
+	}
+	return nil
+}
+
+// ResendActivation 向待激活账号重新发送激活邮件
+// 与申请重置密码相同，邮箱不存在或账号不在待激活状态时同样返回成功
+func (s *AccountService) ResendActivation(ctx context.Context, email string) error {
+	user, err := s.userRepository.Get(ctx, map[string]any{"email": normalizeEmail(email)})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("获取用户失败", "error", err)
+			return apperrors.ErrUserSystemBusy
+		}
+		return nil
+	}
+	if user.Status != models.UserStatusPendingEmail {
+		return nil
+	}
+
+	if err := s.SendEmailVerification(ctx, user.ID); err != nil {
+		s.logMgr.Error("重新发送激活邮件失败", "error", err, "user_id", user.ID)
+	}
+	return nil
+}
+
+// VerifyEmail 通过邮箱验证链接完成验证，待邮件激活的账号同时完成激活
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := s.tokenManager.Parse(token, AccountTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+	user, err := s.getTokenUser(ctx, claims)
+	if err != nil {
+		return err
+	}
+	// 链接签发后修改过邮箱时旧链接失效
+	if user.Email == nil || claims.Binding != s.tokenManager.Binding(*user.Email) {
+		return apperrors.ErrAccountTokenInvalid
+	}
+	if user.EmailVerifiedAt != nil {
+		return apperrors.ErrEmailAlreadyVerified
+	}
+
+	if err := s.tokenManager.Consume(ctx, claims); err != nil {
+		return err
+	}
+	updates := map[string]any{"email_verified_at": time.Now()}
+	if user.Status == models.UserStatusPendingEmail {
+		updates["status"] = models.UserStatusActive
+	}
+	if err := s.userRepository.Update(ctx, user.ID, updates); err != nil {
+		s.tokenManager.Release(ctx, claims)
+		s.logMgr.Error("更新邮箱验证状态失败", "error", err, "user_id", user.ID)
+		return apperrors.ErrUserUpdateFailed
+	}
+	s.userService.InvalidateUserCache(ctx, user)
+
+	s.logMgr.Info("邮箱验证成功", "event", "auth.email_verified", "user_id", user.ID)
+	if user.Status == models.UserStatusPendingEmail {
+		s.logMgr.Info("账号已通过邮件激活", "event", "user.activated", "user_id", user.ID)
+	}
+	return nil
+}
+
+// getTokenUser 获取令牌对应的用户，用户已删除时令牌无效
+func (s *AccountService) getTokenUser(ctx context.Context, claims *AccountTokenClaims) (*models.User, error) {
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": claims.UserID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrAccountTokenInvalid
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", claims.UserID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	return user, nil
+}
+
+// normalizeEmail 邮箱统一去除首尾空白并转为小写后保存与比较
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// userEmail 返回用户邮箱，未设置时为空字符串
+func userEmail(user *models.User) string {
+	if user.Email == nil {
+		return ""
+	}
+	return *user.Email
+}
+
+// formatTTL 将有效期格式化为邮件中显示的文字
+func formatTTL(ttl time.Duration) string {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		return fmt.Sprintf("%d 小时", int(ttl/time.Hour))
+	}
+	return fmt.Sprintf("%d 分钟", int(ttl/time.Minute))
+}
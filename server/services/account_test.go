@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/3086953492/gokit/cache"
+	"github.com/3086953492/gokit/security/password"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const testAccountPassword = "Original-Pass-1"
+
+// testTokenRevoker 在事务中执行用户变更，不撤销任何令牌
+type testTokenRevoker struct {
+	db *gorm.DB
+}
+
+func (r testTokenRevoker) RevokeUserTokens(ctx context.Context, _ uint, apply func(tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(apply)
+}
+
+type accountTestEnv struct {
+	service      *AccountService
+	userService  *UserService
+	tokenManager *AccountTokenManager
+	mailSender   *utils.MemoryMailSender
+	user         *models.User
+}
+
+func newAccountTestEnv(t *testing.T) *accountTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.UserPasswordHistory{}, &models.AuditEvent{}, &models.WebhookSubscription{}, &models.WebhookDelivery{})
+	redisMgr, _ := newTestRedis(t)
+	logMgr := newTestLogger(t)
+	tokenHasher := newTestTokenHasher(t)
+
+	cacheMgr, err := cache.NewManager(redisMgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordMgr, err := password.NewManager(password.WithCost(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := passwordMgr.Hash(testAccountPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userRepository := repositories.NewUserRepository(db)
+	email := "alice@example.com"
+	verifiedAt := time.Now()
+	user := &models.User{Subject: "subject-alice", Username: "alice", Nickname: "Alice", Password: hash, Status: models.UserStatusActive, Email: &email, EmailVerifiedAt: &verifiedAt, AuthSource: models.UserAuthSourceLocal}
+	if err := userRepository.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	auditService := NewAuditService(repositories.NewAuditEventRepository(db), nil, logMgr)
+	passwordPolicy := NewPasswordPolicy(repositories.NewPasswordHistoryRepository(db), passwordMgr, nil, logMgr, appconfig.PasswordPolicyConfig{MinLength: 8, MaxLength: 64, MinCharClasses: 3, HistoryCount: 3})
+	webhookService := NewWebhookService(repositories.NewWebhookSubscriptionRepository(db), repositories.NewWebhookDeliveryRepository(db), nil, logMgr, auditService)
+	userService := NewUserService(userRepository, nil, redisMgr, cacheMgr, logMgr, passwordMgr, nil, passwordPolicy, nil, auditService, webhookService, appconfig.ActivationModeAuto)
+	userService.SetTokenRevoker(testTokenRevoker{db: db})
+
+	tokenManager := NewAccountTokenManager(redisMgr, tokenHasher, logMgr)
+	mailSender := utils.NewMemoryMailSender()
+	cfg := appconfig.AccountConfig{PasswordResetTTL: 30 * time.Minute, EmailVerificationTTL: 24 * time.Hour}
+	service := NewAccountService(userRepository, userService, tokenManager, mailSender, logMgr, cfg, "https://app.example.com/")
+	return &accountTestEnv{service: service, userService: userService, tokenManager: tokenManager, mailSender: mailSender, user: user}
+}
+
+var mailTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// lastMailToken 取出最后一封邮件链接中的令牌
+func (env *accountTestEnv) lastMailToken(t *testing.T) string {
+	t.Helper()
+	messages := env.mailSender.Messages()
+	if len(messages) == 0 {
+		t.Fatal("没有发送邮件")
+	}
+	match := mailTokenPattern.FindStringSubmatch(messages[len(messages)-1].Text)
+	if match == nil {
+		t.Fatalf("邮件中没有链接: %q", messages[len(messages)-1].Text)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAccountPasswordReset(t *testing.T) {
+	env := newAccountTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.service.RequestPasswordReset(ctx, " Alice@Example.com "); err != nil {
+		t.Fatalf("申请重置密码失败: %v", err)
+	}
+	messages := env.mailSender.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "alice@example.com" {
+		t.Fatalf("重置密码邮件 = %+v", messages)
+	}
+	token := env.lastMailToken(t)
+
+	// 新密码不符合策略时不消耗令牌，换一个密码可以重试
+	if err := env.service.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, Password: "short"}); err == nil {
+		t.Fatal("不符合策略的新密码应被拒绝")
+	}
+	if err := env.service.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, Password: "Changed-Pass-2"}); err != nil {
+		t.Fatalf("重置密码失败: %v", err)
+	}
+
+	if err := env.service.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, Password: "Changed-Pass-3"}); !errors.Is(err, apperrors.ErrAccountTokenInvalid) {
+		t.Fatalf("再次使用重置链接 err = %v，期望 ErrAccountTokenInvalid", err)
+	}
+}
+
+func TestAccountPasswordResetUnknownEmail(t *testing.T) {
+	env := newAccountTestEnv(t)
+	if err := env.service.RequestPasswordReset(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("邮箱不存在时应同样返回成功: %v", err)
+	}
+	if messages := env.mailSender.Messages(); len(messages) != 0 {
+		t.Fatalf("邮箱不存在时不应发送邮件: %+v", messages)
+	}
+}
+
+func TestAccountPasswordResetInvalidatedByPasswordChange(t *testing.T) {
+	env := newAccountTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.service.RequestPasswordReset(ctx, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := env.lastMailToken(t)
+
+	if err := env.userService.UpdateUser(ctx, env.user.ID, &dto.UpdateUserForm{Password: "Changed-Pass-2"}, nil); err != nil {
+		t.Fatalf("修改密码失败: %v", err)
+	}
+	if err := env.service.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: token, Password: "Changed-Pass-3"}); !errors.Is(err, apperrors.ErrAccountTokenInvalid) {
+		t.Fatalf("修改密码后使用旧链接 err = %v，期望 ErrAccountTokenInvalid", err)
+	}
+}
+
+func TestAccountEmailVerification(t *testing.T) {
+	env := newAccountTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.service.SendEmailVerification(ctx, env.user.ID); !errors.Is(err, apperrors.ErrEmailAlreadyVerified) {
+		t.Fatalf("已验证的邮箱 err = %v，期望 ErrEmailAlreadyVerified", err)
+	}
+
+	// 修改邮箱后验证状态清空，可以重新发送验证邮件
+	if err := env.userService.UpdateUser(ctx, env.user.ID, &dto.UpdateUserForm{Email: "alice@example.org"}, nil); err != nil {
+		t.Fatalf("修改邮箱失败: %v", err)
+	}
+	if err := env.service.SendEmailVerification(ctx, env.user.ID); err != nil {
+		t.Fatalf("发送邮箱验证邮件失败: %v", err)
+	}
+	token := env.lastMailToken(t)
+
+	if err := env.service.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("验证邮箱失败: %v", err)
+	}
+	user, err := env.userService.GetUser(ctx, map[string]any{"id": env.user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Fatal("验证后邮箱验证时间不应为空")
+	}
+	if err := env.service.VerifyEmail(ctx, token); err == nil {
+		t.Fatal("验证链接不能重复使用")
+	}
+}
+
+func TestAccountEmailVerificationInvalidatedByEmailChange(t *testing.T) {
+	env := newAccountTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.userService.UpdateUser(ctx, env.user.ID, &dto.UpdateUserForm{Email: "alice@example.org"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.service.SendEmailVerification(ctx, env.user.ID); err != nil {
+		t.Fatal(err)
+	}
+	token := env.lastMailToken(t)
+
+	if err := env.userService.UpdateUser(ctx, env.user.ID, &dto.UpdateUserForm{Email: "alice@example.net"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.service.VerifyEmail(ctx, token); !errors.Is(err, apperrors.ErrAccountTokenInvalid) {
+		t.Fatalf("修改邮箱后使用旧链接 err = %v，期望 ErrAccountTokenInvalid", err)
+	}
+}
+
+func TestAccountTokenSingleUse(t *testing.T) {
+	env := newAccountTestEnv(t)
+	ctx := context.Background()
+
+	token, err := env.tokenManager.Issue(AccountTokenEmailVerification, env.user.ID, "binding", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := env.tokenManager.Parse(token, AccountTokenEmailVerification)
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+	if err := env.tokenManager.Consume(ctx, claims); err != nil {
+		t.Fatalf("首次使用令牌失败: %v", err)
+	}
+	if err := env.tokenManager.Consume(ctx, claims); !errors.Is(err, apperrors.ErrAccountTokenInvalid) {
+		t.Fatalf("再次使用令牌 err = %v，期望 ErrAccountTokenInvalid", err)
+	}
+
+	// 撤销使用标记后可以再次使用
+	env.tokenManager.Release(ctx, claims)
+	if err := env.tokenManager.Consume(ctx, claims); err != nil {
+		t.Fatalf("撤销使用标记后使用令牌失败: %v", err)
+	}
+}
+
+func TestAccountTokenRejected(t *testing.T) {
+	env := newAccountTestEnv(t)
+	ctx := context.Background()
+	binding := env.tokenManager.Binding(env.user.Password)
+
+	expired, err := env.tokenManager.Issue(AccountTokenPasswordReset, env.user.ID, binding, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := env.tokenManager.Issue(AccountTokenPasswordReset, env.user.ID, binding, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+	}{
+		{"Expired", expired},
+		{"TamperedSignature", valid[:len(valid)-1] + "x"},
+		{"Malformed", "not-a-token"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := env.service.ResetPassword(ctx, &dto.ResetPasswordRequest{Token: tc.token, Password: "Changed-Pass-2"}); !errors.Is(err, apperrors.ErrAccountTokenInvalid) {
+				t.Fatalf("err = %v，期望 ErrAccountTokenInvalid", err)
+			}
+		})
+	}
+
+	// 用途不同的令牌不能互相使用
+	if err := env.service.VerifyEmail(ctx, valid); !errors.Is(err, apperrors.ErrAccountTokenInvalid) {
+		t.Fatalf("重置密码令牌用于邮箱验证 err = %v，期望 ErrAccountTokenInvalid", err)
+	}
+}
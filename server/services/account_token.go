@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/3086953492/gokit/security/random"
+
+	"goauth/apperrors"
+	"goauth/utils"
+)
+
+// 账号令牌用途
+const (
+	AccountTokenPasswordReset     = "password_reset"
+	AccountTokenEmailVerification = "email_verification"
+)
+
+const accountTokenUsedKeyPrefix = "account_token:used:"
+
+// AccountTokenClaims 账号令牌内容
+type AccountTokenClaims struct {
+	Purpose   string `json:"p"`
+	UserID    uint   `json:"u"`
+	Binding   string `json:"b"` // 签发时账号状态的摘要，状态变化后令牌随之失效
+	ExpiresAt int64  `json:"e"`
+	Nonce     string `json:"n"`
+}
+
+// AccountTokenManager 找回密码与邮箱验证链接中的令牌
+// 令牌为 base64url(内容) + "." + 签名，签名与过期时间本地校验；
+// 使用后在 Redis 中记录，有效期内不能再次使用
+type AccountTokenManager struct {
+	redisMgr    *redis.Manager
+	tokenHasher *utils.TokenHasher
+	logMgr      *logger.Manager
+}
+
+// NewAccountTokenManager 创建账号令牌管理实例
+func NewAccountTokenManager(redisMgr *redis.Manager, tokenHasher *utils.TokenHasher, logMgr *logger.Manager) *AccountTokenManager {
+	return &AccountTokenManager{redisMgr: redisMgr, tokenHasher: tokenHasher, logMgr: logMgr}
+}
+
+// Binding 计算账号状态的摘要，用作令牌的 Binding
+func (m *AccountTokenManager) Binding(value string) string {
+	return m.tokenHasher.Hash("account_token:binding:" + value)[:32]
+}
+
+// Issue 签发令牌
+func (m *AccountTokenManager) Issue(purpose string, userID uint, binding string, ttl time.Duration) (string, error) {
+	nonce, err := random.URLSafe(16)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(&AccountTokenClaims{
+		Purpose:   purpose,
+		UserID:    userID,
+		Binding:   binding,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + m.sign(encoded), nil
+}
+
+// Parse 校验签名、用途与过期时间，失败时返回 ErrAccountTokenInvalid
+func (m *AccountTokenManager) Parse(token, purpose string) (*AccountTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(m.sign(encoded))) {
+		return nil, apperrors.ErrAccountTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, apperrors.ErrAccountTokenInvalid
+	}
+	var claims AccountTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, apperrors.ErrAccountTokenInvalid
+	}
+	if claims.Purpose != purpose || claims.UserID == 0 || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, apperrors.ErrAccountTokenInvalid
+	}
+	return &claims, nil
+}
+
+// Consume 标记令牌已使用，已被使用过时返回 ErrAccountTokenInvalid
+func (m *AccountTokenManager) Consume(ctx context.Context, claims *AccountTokenClaims) error {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return apperrors.ErrAccountTokenInvalid
+	}
+	ok, err := m.redisMgr.SetNX(ctx, m.usedKey(claims), "1", ttl)
+	if err != nil {
+		m.logMgr.Error("记录账号令牌使用失败", "error", err, "purpose", claims.Purpose)
+		return apperrors.ErrUserSystemBusy
+	}
+	if !ok {
+		return apperrors.ErrAccountTokenInvalid
+	}
+	return nil
+}
+
+// Release 撤销使用标记，用于令牌已标记但后续操作失败、允许用户重试的情况
+func (m *AccountTokenManager) Release(ctx context.Context, claims *AccountTokenClaims) {
+	if _, err := m.redisMgr.Del(ctx, m.usedKey(claims)); err != nil {
+		m.logMgr.Warn("撤销账号令牌使用标记失败", "error", err, "purpose", claims.Purpose)
+	}
+}
+
+func (m *AccountTokenManager) sign(encoded string) string {
+	return m.tokenHasher.Hash("account_token:" + encoded)
+}
+
+func (m *AccountTokenManager) usedKey(claims *AccountTokenClaims) string {
+	return accountTokenUsedKeyPrefix + claims.Purpose + ":" + claims.Nonce
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// CheckUserStatus 检查账号是否可以登录，不可登录时按状态返回对应的错误
+func CheckUserStatus(user *models.User) error {
+	switch user.Status {
+	case models.UserStatusActive:
+		return nil
+	case models.UserStatusPendingEmail:
+		return apperrors.ErrUserPendingActivation
+	case models.UserStatusPendingApproval:
+		return apperrors.ErrUserPendingApproval
+	case models.UserStatusRejected:
+		return apperrors.ErrUserRejected
+	default:
+		return apperrors.ErrUserDisabled
+	}
+}
+
+// ActivationService 注册审核
+// 激活方式为 approval 时新注册账号进入待审核状态，由管理员批准或拒绝
+type ActivationService struct {
+	userRepository *repositories.UserRepository
+	userService    *UserService
+	mailSender     utils.MailSender
+	logMgr         *logger.Manager
+	frontendURL    string
+}
+
+// NewActivationService 创建注册审核服务实例，frontendURL 用于生成通知邮件中的登录链接
+func NewActivationService(userRepository *repositories.UserRepository, userService *UserService, mailSender utils.MailSender, logMgr *logger.Manager, frontendURL string) *ActivationService {
+	return &ActivationService{userRepository: userRepository, userService: userService, mailSender: mailSender, logMgr: logMgr, frontendURL: strings.TrimRight(frontendURL, "/")}
+}
+
+// ListPending 分页获取待审核的注册申请
+func (s *ActivationService) ListPending(ctx context.Context, page, pageSize int) (*dto.PaginationResponse[dto.PendingUserResponse], error) {
+	users, total, err := s.userRepository.List(ctx, page, pageSize, map[string]any{"status": models.UserStatusPendingApproval})
+	if err != nil {
+		s.logMgr.Error("获取待审核用户列表失败", "error", err)
+		return nil, apperrors.ErrUserListFailed
+	}
+
+	items := make([]dto.PendingUserResponse, len(users))
+	for i, user := range users {
+		items[i] = dto.PendingUserResponse{
+			ID:            user.ID,
+			Username:      user.Username,
+			Nickname:      user.Nickname,
+			Avatar:        user.Avatar,
+			Email:         userEmail(&user),
+			EmailVerified: user.EmailVerifiedAt != nil,
+			CreatedAt:     user.CreatedAt,
+		}
+	}
+	return &dto.PaginationResponse[dto.PendingUserResponse]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
+
+// Approve 批准注册申请，账号随即可以登录
+func (s *ActivationService) Approve(ctx context.Context, userID uint) error {
+	user, err := s.review(ctx, userID, models.UserStatusActive)
+	if err != nil {
+		return err
+	}
+
+	s.logMgr.Info("批准注册申请", "event", "user.registration_approved", "user_id", user.ID)
+	s.notify(ctx, user, "注册申请已通过", fmt.Sprintf("%s，你好：\n\n你的注册申请已通过审核，现在可以登录了：\n\n%s\n", user.Nickname, s.frontendURL+"/login"))
+	return nil
+}
+
+// Reject 拒绝注册申请，reason 会写入通知邮件
+// 被拒绝的账号保留记录，用户名与邮箱不能再次注册，如需重新开放由管理员修改状态
+func (s *ActivationService) Reject(ctx context.Context, userID uint, reason string) error {
+	user, err := s.review(ctx, userID, models.UserStatusRejected)
+	if err != nil {
+		return err
+	}
+
+	s.logMgr.Info("拒绝注册申请", "event", "user.registration_rejected", "user_id", user.ID)
+	text := fmt.Sprintf("%s，你好：\n\n很抱歉，你的注册申请未通过审核。\n", user.Nickname)
+	if reason != "" {
+		text += "\n原因：" + reason + "\n"
+	}
+	s.notify(ctx, user, "注册申请未通过", text)
+	return nil
+}
+
+// review 将待审核账号改为审核结果对应的状态
+// 条件更新保证同一申请被并发审核时只有一次生效
+func (s *ActivationService) review(ctx context.Context, userID uint, status int) (*models.User, error) {
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if user.Status != models.UserStatusPendingApproval {
+		return nil, apperrors.ErrRegistrationNotPending
+	}
+
+	affected, err := s.userRepository.UpdateStatus(ctx, userID, models.UserStatusPendingApproval, status)
+	if err != nil {
+		s.logMgr.Error("更新用户状态失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserUpdateFailed
+	}
+	if affected == 0 {
+		return nil, apperrors.ErrRegistrationNotPending
+	}
+	s.userService.InvalidateUserCache(ctx, user)
+
+	user.Status = status
+	return user, nil
+}
+
+// notify 向申请人发送审核结果，未设置邮箱或发送失败时只记录日志
+func (s *ActivationService) notify(ctx context.Context, user *models.User, subject, text string) {
+	if user.Email == nil {
+		return
+	}
+	if err := s.mailSender.Send(ctx, &utils.MailMessage{To: []string{*user.Email}, Subject: subject, Text: text}); err != nil {
+		s.logMgr.Warn("发送审核结果邮件失败", "error", err, "user_id", user.ID)
+	}
+}
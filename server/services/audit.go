@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/3086953492/gokit/logger"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// AuditService 安全审计服务，记录登录、令牌签发与撤销、客户端与用户管理等事件
+// 事件写入数据库供管理员查询，并转发到配置的外部输出；记录失败只写日志，不影响业务结果
+type AuditService struct {
+	auditEventRepository *repositories.AuditEventRepository
+	sink                 utils.AuditSink
+	logMgr               *logger.Manager
+}
+
+// NewAuditService 创建审计服务实例，sink 为 nil 时只写入数据库
+func NewAuditService(auditEventRepository *repositories.AuditEventRepository, sink utils.AuditSink, logMgr *logger.Manager) *AuditService {
+	return &AuditService{auditEventRepository: auditEventRepository, sink: sink, logMgr: logMgr}
+}
+
+// Record 记录审计事件，err 为业务结果：nil 记为成功，否则记为失败并以错误信息作为原因
+// 未指定操作者时取 context 中的已认证主体，来源 IP 与 User-Agent 取自 context 中的请求来源
+func (s *AuditService) Record(ctx context.Context, event *models.AuditEvent, err error) {
+	event.Outcome = models.AuditOutcomeSuccess
+	if err != nil {
+		event.Outcome = models.AuditOutcomeFailure
+		event.Reason = truncate(err.Error(), 255)
+	}
+
+	if event.ActorType == "" {
+		event.ActorType = models.AuditActorAnonymous
+		if actor, ok := utils.ActorFromContext(ctx); ok {
+			if actor.UserID != 0 {
+				event.ActorType = models.AuditActorUser
+				event.ActorID = &actor.UserID
+			} else {
+				event.ActorType = models.AuditActorClient
+			}
+			if event.ClientID == "" {
+				event.ClientID = actor.ClientID
+			}
+		}
+	}
+
+	info := utils.RequestInfoFromContext(ctx)
+	event.IP = info.IP
+	event.UserAgent = truncate(info.UserAgent, 500)
+
+	// 请求可能在记录前结束，审计写入不随请求取消
+	ctx = context.WithoutCancel(ctx)
+	if err := s.auditEventRepository.Create(ctx, event); err != nil {
+		s.logMgr.Error("写入审计事件失败", "error", err, "type", event.Type, "outcome", event.Outcome)
+		return
+	}
+	if s.sink != nil {
+		if err := s.sink.Emit(ctx, event); err != nil {
+			s.logMgr.Error("输出审计事件失败", "error", err, "id", event.ID, "type", event.Type)
+		}
+	}
+}
+
+// RecordUser 记录以用户为操作对象的事件，userID 为 0 时（如创建失败）不记录对象
+func (s *AuditService) RecordUser(ctx context.Context, eventType string, userID uint, err error) {
+	event := &models.AuditEvent{Type: eventType, TargetType: "user"}
+	if userID != 0 {
+		event.UserID = &userID
+		event.TargetID = strconv.FormatUint(uint64(userID), 10)
+	}
+	s.Record(ctx, event, err)
+}
+
+// ListEvents 分页查询当前租户的审计事件，按发生时间倒序
+func (s *AuditService) ListEvents(ctx context.Context, page, pageSize int, query *dto.AuditEventQuery) (*dto.PaginationResponse[dto.AuditEventResponse], error) {
+	conds := map[string]any{}
+	if query.UserID != 0 {
+		conds["? IN (user_id, actor_id)"] = query.UserID
+	}
+	if query.ClientID != "" {
+		conds["client_id"] = query.ClientID
+	}
+	if query.Type != "" {
+		conds["type"] = query.Type
+	}
+	if query.Outcome != "" {
+		conds["outcome"] = query.Outcome
+	}
+	if !query.From.IsZero() {
+		conds["created_at >= ?"] = query.From
+	}
+	if !query.To.IsZero() {
+		conds["created_at < ?"] = query.To
+	}
+
+	events, total, err := s.auditEventRepository.List(ctx, page, pageSize, conds)
+	if err != nil {
+		s.logMgr.Error("查询审计事件失败", "error", err)
+		return nil, apperrors.ErrAuditQueryFailed
+	}
+
+	items := make([]dto.AuditEventResponse, len(events))
+	for i, event := range events {
+		items[i] = dto.AuditEventResponse{
+			ID:         event.ID,
+			Type:       event.Type,
+			Outcome:    event.Outcome,
+			ActorType:  event.ActorType,
+			ActorID:    event.ActorID,
+			UserID:     event.UserID,
+			Username:   event.Username,
+			ClientID:   event.ClientID,
+			TargetType: event.TargetType,
+			TargetID:   event.TargetID,
+			IP:         event.IP,
+			UserAgent:  event.UserAgent,
+			Reason:     event.Reason,
+			Details:    json.RawMessage(event.Details),
+			CreatedAt:  event.CreatedAt,
+		}
+	}
+	return &dto.PaginationResponse[dto.AuditEventResponse]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
+
+// AuditDetails 把附加信息编码为审计事件的 Details
+func AuditDetails(details map[string]any) []byte {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -4,110 +4,425 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"time"
 
 	"github.com/3086953492/gokit/config"
-	"github.com/3086953492/gokit/jwt"
 	"github.com/3086953492/gokit/logger"
-	"github.com/3086953492/gokit/security/password"
 	"gorm.io/gorm"
 
+	"goauth/apperrors"
 	"goauth/dto"
+	"goauth/models"
 	"goauth/repositories"
+	"goauth/utils"
 )
 
 // AuthService 授权服务实现
 type AuthService struct {
-	userRepository *repositories.UserRepository
-	userService    *UserService
-	logMgr         *logger.Manager
-	jwtManager     *jwt.Manager
-	passwordMgr    *password.Manager
-	cfg            *config.Config
+	userRepository    *repositories.UserRepository
+	userService       *UserService
+	rbacService       *RBACService
+	userTokenEpoch    *UserTokenEpoch
+	sessionService    *SessionService
+	mfaService        *MFAService
+	mfaChallengeStore *MFAChallengeStore
+	webAuthnService   *WebAuthnService
+	federationService *FederationService
+	loginProtection   *LoginProtectionService
+	passwordPolicy    *PasswordPolicy
+	logMgr            *logger.Manager
+	tenantService     *TenantService
+	authenticators    *AuthenticatorChain
+	auditService      *AuditService
+	cfg               *config.Config
 }
 
 // NewAuthService 创建授权服务实例
-func NewAuthService(userRepository *repositories.UserRepository, userService *UserService, logMgr *logger.Manager, jwtManager *jwt.Manager, passwordMgr *password.Manager, cfg *config.Config) *AuthService {
-	return &AuthService{userRepository: userRepository, userService: userService, logMgr: logMgr, jwtManager: jwtManager, passwordMgr: passwordMgr, cfg: cfg}
+func NewAuthService(userRepository *repositories.UserRepository, userService *UserService, rbacService *RBACService, userTokenEpoch *UserTokenEpoch, sessionService *SessionService, mfaService *MFAService, mfaChallengeStore *MFAChallengeStore, webAuthnService *WebAuthnService, federationService *FederationService, loginProtection *LoginProtectionService, passwordPolicy *PasswordPolicy, logMgr *logger.Manager, tenantService *TenantService, authenticators *AuthenticatorChain, auditService *AuditService, cfg *config.Config) *AuthService {
+	return &AuthService{userRepository: userRepository, userService: userService, rbacService: rbacService, userTokenEpoch: userTokenEpoch, sessionService: sessionService, mfaService: mfaService, mfaChallengeStore: mfaChallengeStore, webAuthnService: webAuthnService, federationService: federationService, loginProtection: loginProtection, passwordPolicy: passwordPolicy, logMgr: logMgr, tenantService: tenantService, authenticators: authenticators, auditService: auditService, cfg: cfg}
 }
 
-func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest) (accessToken string, accessTokenExpire int, refreshToken string, refreshTokenExpire int, userResp *dto.UserResponse, err error) {
+// LoginResult 登录结果
+// 需要两步验证时只返回 MFA 挑战，不签发任何令牌
+type LoginResult struct {
+	AccessToken        string
+	AccessTokenExpire  int
+	RefreshToken       string
+	RefreshTokenExpire int
+	User               *dto.UserResponse
+	MFA                *dto.MFAChallengeResponse
+	RecoveryCodes      []string
+	PasswordExpired    bool
+}
+
+// Login 通过认证后端链校验账号密码，未启用两步验证时直接创建登录会话，ip 与 userAgent 记录在会话中
+// 已启用两步验证或安全策略要求两步验证的账号只返回 MFA 挑战
+func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest, ip, userAgent string) (result *LoginResult, err error) {
+	var user *models.User
+	defer func() { s.auditLogin(ctx, "password", req.Username, user, result, err) }()
+
+	// 失败次数过多时直接拒绝，不再校验密码
+	if err := s.loginProtection.Check(ctx, req.Username, ip); err != nil {
+		return nil, err
+	}
+
+	// 不存在的用户名与密码错误返回相同的错误，同样计入失败次数
+	user, err = s.authenticators.Authenticate(ctx, req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrInvalidCredentials) {
+			s.loginProtection.RecordFailure(ctx, req.Username, ip)
+		}
+		return nil, err
+	}
+	s.loginProtection.RecordSuccess(ctx, req.Username)
+
+	if err := CheckUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	if user.MFAEnabled || s.userService.MFARequired(ctx, user) {
+		return s.createMFAChallenge(ctx, user)
+	}
+
+	return s.createLoginSession(ctx, user, ip, userAgent)
+}
+
+// BeginPasskeyLogin 发起通行密钥无密码登录
+func (s *AuthService) BeginPasskeyLogin(ctx context.Context) (*dto.WebAuthnRequestOptions, error) {
+	return s.webAuthnService.BeginLogin(ctx)
+}
+
+// FinishPasskeyLogin 校验通行密钥后创建登录会话
+// 无密码登录已同时验证持有与用户验证，不再要求两步验证；但须绑定验证器的管理员仍需先完成绑定
+func (s *AuthService) FinishPasskeyLogin(ctx context.Context, req *dto.WebAuthnLoginRequest, ip, userAgent string) (result *LoginResult, err error) {
+	var user *models.User
+	defer func() { s.auditLogin(ctx, "passkey", "", user, result, err) }()
+
+	user, err = s.webAuthnService.FinishLogin(ctx, &req.Credential)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckUserStatus(user); err != nil {
+		return nil, err
+	}
+
+	if s.userService.MFARequired(ctx, user) && !user.MFAEnabled {
+		return s.createMFAChallenge(ctx, user)
+	}
+
+	return s.createLoginSession(ctx, user, ip, userAgent)
+}
+
+// FederatedLogin 使用外部身份登录回调生成的一次性凭据完成登录
+// 外部身份只代替密码，已启用两步验证的账号仍需完成两步验证
+func (s *AuthService) FederatedLogin(ctx context.Context, ticket, ip, userAgent string) (result *LoginResult, err error) {
+	var user *models.User
+	defer func() { s.auditLogin(ctx, "federated", "", user, result, err) }()
+
+	userID, err := s.federationService.ConsumeTicket(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
 
-	user, err := s.userRepository.Get(ctx, map[string]any{"username": req.Username})
+	user, err = s.userRepository.Get(ctx, map[string]any{"id": userID})
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logMgr.Error("获取用户失败", "error", err)
-			return "", 0, "", 0, nil, errors.New("系统繁忙，请稍后再试")
+			return nil, errors.New("系统繁忙，请稍后再试")
 		}
-		return "", 0, "", 0, nil, errors.New("账号或密码错误")
+		return nil, apperrors.ErrFederatedTicketInvalid
 	}
 
-	if user.Status == 0 {
-		return "", 0, "", 0, nil, errors.New("账号未激活或已禁用，请联系管理员")
+	if err := CheckUserStatus(user); err != nil {
+		return nil, err
 	}
 
-	if err := s.passwordMgr.Compare(user.Password, req.Password); err != nil {
-		if errors.Is(err, password.ErrMismatch) {
-			return "", 0, "", 0, nil, errors.New("账号或密码错误")
+	if user.MFAEnabled || s.userService.MFARequired(ctx, user) {
+		return s.createMFAChallenge(ctx, user)
+	}
+
+	return s.createLoginSession(ctx, user, ip, userAgent)
+}
+
+// BeginMFAWebAuthn 在两步验证阶段发起通行密钥认证
+func (s *AuthService) BeginMFAWebAuthn(ctx context.Context, mfaToken string) (*dto.WebAuthnRequestOptions, error) {
+	challenge, _, err := s.getMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Enrollment {
+		return nil, apperrors.ErrTOTPNotSetup
+	}
+	return s.webAuthnService.BeginSecondFactor(ctx, challenge.UserID)
+}
+
+// VerifyMFAWebAuthn 使用通行密钥完成两步验证，验证通过后创建登录会话
+func (s *AuthService) VerifyMFAWebAuthn(ctx context.Context, req *dto.LoginMFAWebAuthnRequest, ip, userAgent string) (result *LoginResult, err error) {
+	var user *models.User
+	defer func() { s.auditLogin(ctx, "mfa_webauthn", "", user, result, err) }()
+
+	challenge, user, err := s.getMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Enrollment {
+		return nil, apperrors.ErrTOTPNotSetup
+	}
+
+	if err := s.webAuthnService.FinishSecondFactor(ctx, user.ID, &req.Credential); err != nil {
+		return nil, s.mfaFailure(ctx, req.MFAToken, err)
+	}
+	if err := s.mfaChallengeStore.Consume(ctx, req.MFAToken); err != nil {
+		return nil, err
+	}
+
+	return s.createLoginSession(ctx, user, ip, userAgent)
+}
+
+// VerifyMFA 完成登录的两步验证，验证通过后创建登录会话
+func (s *AuthService) VerifyMFA(ctx context.Context, req *dto.LoginMFARequest, ip, userAgent string) (result *LoginResult, err error) {
+	var user *models.User
+	defer func() { s.auditLogin(ctx, "mfa_totp", "", user, result, err) }()
+
+	challenge, user, err := s.getMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Enrollment {
+		return nil, apperrors.ErrTOTPNotSetup
+	}
+
+	if err := s.mfaService.Verify(ctx, user, req.Code, req.RecoveryCode); err != nil {
+		return nil, s.mfaFailure(ctx, req.MFAToken, err)
+	}
+	if err := s.mfaChallengeStore.Consume(ctx, req.MFAToken); err != nil {
+		return nil, err
+	}
+
+	return s.createLoginSession(ctx, user, ip, userAgent)
+}
+
+// SetupMFAEnrollment 为登录时须先绑定验证器的账号发起绑定
+func (s *AuthService) SetupMFAEnrollment(ctx context.Context, mfaToken string) (*dto.TOTPSetupResponse, error) {
+	challenge, _, err := s.getMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Enrollment {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+	return s.mfaService.SetupTOTP(ctx, challenge.UserID)
+}
+
+// ConfirmMFAEnrollment 确认登录时的验证器绑定并创建登录会话，结果中附带首批恢复码
+func (s *AuthService) ConfirmMFAEnrollment(ctx context.Context, req *dto.LoginMFAEnrollConfirmRequest, ip, userAgent string) (result *LoginResult, err error) {
+	var user *models.User
+	defer func() { s.auditLogin(ctx, "mfa_enrollment", "", user, result, err) }()
+
+	challenge, user, err := s.getMFAChallenge(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Enrollment {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	recoveryCodes, err := s.mfaService.ConfirmTOTP(ctx, user.ID, req.Code)
+	if err != nil {
+		return nil, s.mfaFailure(ctx, req.MFAToken, err)
+	}
+	if err := s.mfaChallengeStore.Consume(ctx, req.MFAToken); err != nil {
+		return nil, err
+	}
+
+	result, err = s.createLoginSession(ctx, user, ip, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+// createMFAChallenge 密码验证通过后创建两步验证挑战
+func (s *AuthService) createMFAChallenge(ctx context.Context, user *models.User) (*LoginResult, error) {
+	enrollment := !user.MFAEnabled
+
+	methods := []string{"totp"}
+	if !enrollment {
+		methods = append(methods, "recovery_code")
+		// 已注册的通行密钥可代替验证码
+		hasPasskeys, err := s.webAuthnService.HasCredentials(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if hasPasskeys {
+			methods = append(methods, "webauthn")
 		}
-		s.logMgr.Error("密码验证失败", "error", err)
-		return "", 0, "", 0, nil, errors.New("系统繁忙，请稍后再试")
 	}
 
-	userID := strconv.FormatUint(uint64(user.ID), 10)
-	accessToken, err = s.jwtManager.GenerateAccessToken(userID, map[string]any{"role": user.Role})
+	mfaToken, expiresAt, err := s.mfaChallengeStore.Create(ctx, &MFAChallenge{UserID: user.ID, Enrollment: enrollment})
 	if err != nil {
-		s.logMgr.Error("生成访问令牌失败", "error", err)
-		return "", 0, "", 0, nil, errors.New("生成访问令牌失败")
+		return nil, err
 	}
+	return &LoginResult{MFA: &dto.MFAChallengeResponse{
+		MFAToken:           mfaToken,
+		Methods:            methods,
+		EnrollmentRequired: enrollment,
+		ExpiresAt:          expiresAt,
+	}}, nil
+}
 
-	refreshToken, err = s.jwtManager.GenerateRefreshToken(userID)
+// getMFAChallenge 获取两步验证挑战及其用户，用户在挑战期间被禁用时挑战随之失效
+func (s *AuthService) getMFAChallenge(ctx context.Context, mfaToken string) (*MFAChallenge, *models.User, error) {
+	challenge, err := s.mfaChallengeStore.Get(ctx, mfaToken)
 	if err != nil {
-		s.logMgr.Error("生成刷新令牌失败", "error", err)
-		return "", 0, "", 0, nil, errors.New("生成刷新令牌失败")
+		return nil, nil, err
 	}
 
-	return accessToken, int(s.cfg.AuthToken.AccessTokenExpire.Seconds()), refreshToken, int(s.cfg.AuthToken.RefreshTokenExpire.Seconds()), &dto.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Nickname:  user.Nickname,
-		Avatar:    user.Avatar,
-		Role:      user.Role,
-		Status:    user.Status,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	}, nil
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": challenge.UserID})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("获取用户失败", "error", err)
+			return nil, nil, errors.New("系统繁忙，请稍后再试")
+		}
+		return nil, nil, apperrors.ErrMFAChallengeInvalid
+	}
+	if err := CheckUserStatus(user); err != nil {
+		return nil, nil, err
+	}
+	return challenge, user, nil
 }
 
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (accessToken string, accessTokenExpire int, err error) {
+// mfaFailure 验证码或通行密钥校验失败时累计失败次数，达到上限后挑战作废
+func (s *AuthService) mfaFailure(ctx context.Context, mfaToken string, err error) error {
+	if !errors.Is(err, apperrors.ErrMFACodeInvalid) && !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+		return err
+	}
+	if recordErr := s.mfaChallengeStore.RecordFailure(ctx, mfaToken); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+// auditLogin 记录登录结果，method 为本次校验的方式；返回两步验证挑战时登录尚未完成，不记录
+// 登录成功时操作者为登录的账号，失败时操作者为匿名，username 记录提交的用户名便于发现撞库
+func (s *AuthService) auditLogin(ctx context.Context, method, username string, user *models.User, result *LoginResult, err error) {
+	if err == nil && result.MFA != nil {
+		return
+	}
+	event := &models.AuditEvent{Type: models.AuditLogin, Username: username, Details: AuditDetails(map[string]any{"method": method})}
+	if user != nil {
+		event.UserID = &user.ID
+		event.Username = user.Username
+		if err == nil {
+			event.ActorType = models.AuditActorUser
+			event.ActorID = &user.ID
+		}
+	}
+	s.auditService.Record(ctx, event, err)
+}
 
-	claims, err := s.jwtManager.ParseRefreshToken(refreshToken)
+// Logout 注销当前登录会话并通知在该会话中取得授权的客户端，返回需由浏览器加载的前端通道登出地址
+func (s *AuthService) Logout(ctx context.Context, userID uint, sessionID string) ([]string, error) {
+	err := s.sessionService.revoke(ctx, userID, sessionID)
+	s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditLogout, UserID: &userID, TargetType: "session", TargetID: sessionID}, err)
 	if err != nil {
-		return "", 0, errors.New("无效的刷新令牌")
+		return nil, err
 	}
+	return s.sessionService.notifyLogout(ctx, userID, sessionID), nil
+}
 
-	if claims.TokenType != jwt.RefreshToken {
-		return "", 0, errors.New("无效的刷新令牌")
+// createLoginSession 创建登录会话并签发令牌
+func (s *AuthService) createLoginSession(ctx context.Context, user *models.User, ip, userAgent string) (*LoginResult, error) {
+	session, refreshToken, err := s.sessionService.CreateSession(ctx, user.ID, ip, userAgent)
+	if err != nil {
+		return nil, err
 	}
 
-	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	// 访问令牌携带会话ID，会话注销后立即失效；角色与权限由鉴权中间件按请求加载，不写入令牌
+	jwtManager, err := s.tenantService.JwtManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := jwtManager.GenerateAccessToken(strconv.FormatUint(uint64(user.ID), 10), map[string]any{"sid": session.SessionID, utils.IssuedAtMsClaim: time.Now().UnixMilli()})
 	if err != nil {
-		return "", 0, errors.New("用户ID格式错误")
+		s.logMgr.Error("生成访问令牌失败", "error", err)
+		return nil, errors.New("生成访问令牌失败")
 	}
 
-	_, err = s.userRepository.Get(ctx, map[string]any{"id": userID})
+	authz, err := s.rbacService.GetUserAuthorization(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		AccessToken:        accessToken,
+		AccessTokenExpire:  int(s.cfg.AuthToken.AccessTokenExpire.Seconds()),
+		RefreshToken:       refreshToken,
+		RefreshTokenExpire: int(s.cfg.AuthToken.RefreshTokenExpire.Seconds()),
+		User: &dto.UserResponse{
+			ID:            user.ID,
+			Username:      user.Username,
+			Nickname:      user.Nickname,
+			Avatar:        user.Avatar,
+			Roles:         authz.Roles,
+			Permissions:   authz.Permissions,
+			Status:        user.Status,
+			Email:         userEmail(user),
+			EmailVerified: user.EmailVerifiedAt != nil,
+			MFAEnabled:    user.MFAEnabled,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+		},
+		PasswordExpired: s.passwordPolicy.Expired(user),
+	}, nil
+}
+
+// RefreshToken 轮换刷新令牌并签发新的访问令牌，刷新令牌必须对应一个有效会话
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (accessToken string, accessTokenExpire int, newRefreshToken string, refreshTokenExpire int, err error) {
+	event := &models.AuditEvent{Type: models.AuditSessionRefresh}
+	defer func() { s.auditService.Record(ctx, event, err) }()
+
+	session, newRefreshToken, err := s.sessionService.RotateRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return "", 0, "", 0, err
+	}
+	// 持有有效刷新令牌即代表会话所属的用户
+	event.ActorType = models.AuditActorUser
+	event.ActorID = &session.UserID
+	event.UserID = &session.UserID
+	event.TargetType = "session"
+	event.TargetID = session.SessionID
+
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": session.UserID})
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logMgr.Error("获取用户失败", "error", err)
-			return "", 0, errors.New("系统繁忙，请稍后再试")
+			return "", 0, "", 0, errors.New("系统繁忙，请稍后再试")
 		}
-		return "", 0, errors.New("用户不存在")
+		return "", 0, "", 0, errors.New("用户不存在")
 	}
 
-	accessToken, err = s.jwtManager.RefreshAccessToken(ctx, refreshToken)
+	if err := CheckUserStatus(user); err != nil {
+		return "", 0, "", 0, err
+	}
+
+	// 登录后才获得管理权限的账号须重新登录并绑定验证器
+	if s.userService.MFARequired(ctx, user) && !user.MFAEnabled {
+		return "", 0, "", 0, errors.New("管理员账号必须启用两步验证，请重新登录")
+	}
+
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	jwtManager, err := s.tenantService.JwtManager(ctx)
+	if err != nil {
+		return "", 0, "", 0, err
+	}
+	accessToken, err = jwtManager.GenerateAccessToken(userID, map[string]any{"sid": session.SessionID, utils.IssuedAtMsClaim: time.Now().UnixMilli()})
 	if err != nil {
 		s.logMgr.Error("刷新令牌失败", "error", err)
-		return "", 0, errors.New("系统繁忙，请稍后再试")
+		return "", 0, "", 0, errors.New("系统繁忙，请稍后再试")
 	}
 
-	return accessToken, int(s.cfg.AuthToken.AccessTokenExpire.Seconds()), nil
+	return accessToken, int(s.cfg.AuthToken.AccessTokenExpire.Seconds()), newRefreshToken, int(time.Until(session.ExpiresAt).Seconds()), nil
 }
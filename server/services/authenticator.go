@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/security/password"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+)
+
+// PasswordAuthenticator 校验账号密码的认证后端
+// 用户名不属于该后端时返回 ErrAuthenticatorUserNotFound，由下一个后端继续尝试；
+// 密码错误返回 ErrInvalidCredentials；后端无法访问时返回 ErrAuthenticatorUnavailable
+type PasswordAuthenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// AuthenticatorChain 按顺序尝试各认证后端
+// 第一个后端之后的后端是否参与由回退策略决定：always 始终参与，unavailable 只在前面的后端不可用时参与
+type AuthenticatorChain struct {
+	backends []PasswordAuthenticator
+	fallback string
+	logMgr   *logger.Manager
+}
+
+// NewAuthenticatorChain 创建认证后端链
+func NewAuthenticatorChain(backends []PasswordAuthenticator, fallback string, logMgr *logger.Manager) *AuthenticatorChain {
+	return &AuthenticatorChain{backends: backends, fallback: fallback, logMgr: logMgr}
+}
+
+// Authenticate 校验账号密码，返回通过校验的本地用户
+// 用户名在所有后端都不存在时与密码错误返回相同的错误，不暴露账号是否存在
+func (c *AuthenticatorChain) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	unavailable := false
+	for i, backend := range c.backends {
+		if i > 0 && !c.fallbackAllowed(unavailable) {
+			break
+		}
+
+		user, err := backend.Authenticate(ctx, username, password)
+		switch {
+		case err == nil:
+			return user, nil
+		case errors.Is(err, apperrors.ErrAuthenticatorUserNotFound):
+			continue
+		case errors.Is(err, apperrors.ErrAuthenticatorUnavailable):
+			c.logMgr.Warn("认证后端不可用", "backend", backend.Name())
+			unavailable = true
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	if unavailable {
+		return nil, apperrors.ErrAuthenticatorUnavailable
+	}
+	return nil, apperrors.ErrInvalidCredentials
+}
+
+func (c *AuthenticatorChain) fallbackAllowed(unavailable bool) bool {
+	switch c.fallback {
+	case appconfig.LocalFallbackNever:
+		return false
+	case appconfig.LocalFallbackUnavailable:
+		return unavailable
+	default:
+		return true
+	}
+}
+
+// LocalAuthenticator 使用本地保存的密码哈希校验，目录账号不参与
+type LocalAuthenticator struct {
+	userRepository *repositories.UserRepository
+	passwordMgr    *password.Manager
+	logMgr         *logger.Manager
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+// NewLocalAuthenticator 创建本地密码认证后端
+func NewLocalAuthenticator(userRepository *repositories.UserRepository, passwordMgr *password.Manager, logMgr *logger.Manager) *LocalAuthenticator {
+	return &LocalAuthenticator{userRepository: userRepository, passwordMgr: passwordMgr, logMgr: logMgr}
+}
+
+func (a *LocalAuthenticator) Name() string {
+	return models.UserAuthSourceLocal
+}
+
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, pwd string) (*models.User, error) {
+	user, err := a.userRepository.Get(ctx, map[string]any{"username": username})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a.compareDummy(pwd)
+			return nil, apperrors.ErrAuthenticatorUserNotFound
+		}
+		a.logMgr.Error("获取用户失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if user.AuthSource != models.UserAuthSourceLocal {
+		a.compareDummy(pwd)
+		return nil, apperrors.ErrAuthenticatorUserNotFound
+	}
+
+	if err := a.passwordMgr.Compare(user.Password, pwd); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		a.logMgr.Error("密码验证失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	return user, nil
+}
+
+// compareDummy 对不存在或不属于本后端的用户名同样执行一次哈希比对，使响应耗时不暴露账号是否存在
+func (a *LocalAuthenticator) compareDummy(pwd string) {
+	a.dummyHashOnce.Do(func() {
+		hash, err := a.passwordMgr.Hash("goauth-dummy-password")
+		if err != nil {
+			a.logMgr.Error("生成占位密码哈希失败", "error", err)
+			return
+		}
+		a.dummyHash = hash
+	})
+	if a.dummyHash != "" {
+		_ = a.passwordMgr.Compare(a.dummyHash, pwd)
+	}
+}
@@ -0,0 +1,461 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/utils"
+)
+
+const (
+	federatedDiscoveryTTL     = 24 * time.Hour
+	federatedJWKSRefreshAfter = time.Minute // 遇到未知 kid 时重新获取 JWKS 的最短间隔
+	federatedResponseLimit    = 1 << 20
+)
+
+// GitHub 的默认地址
+const (
+	githubIssuer           = "https://github.com"
+	githubAuthorizationURL = "https://github.com/login/oauth/authorize"
+	githubTokenURL         = "https://github.com/login/oauth/access_token"
+	githubUserInfoURL      = "https://api.github.com/user"
+	githubEmailsURL        = "https://api.github.com/user/emails"
+)
+
+// FederatedProfile 身份提供方返回的用户信息，Issuer 与 Subject 唯一确定一个外部身份
+type FederatedProfile struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+	Picture       string
+}
+
+// oidcDiscovery OIDC 发现文档中用到的字段
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// federatedTokenResponse 令牌端点的响应
+type federatedTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// federatedIDTokenClaims ID Token 中用到的声明
+type federatedIDTokenClaims struct {
+	gojwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"` // 部分提供方返回字符串 "true"
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+}
+
+// federatedProvider 单个外部身份提供方的客户端
+// 授权码流程统一使用 PKCE；oidc 类型校验 ID Token，github 类型通过用户信息接口获取身份
+type federatedProvider struct {
+	cfg        appconfig.FederatedProviderConfig
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	discoveredAt  time.Time
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// newFederatedProvider 创建提供方客户端，未配置的地址与权限范围按类型补全默认值
+func newFederatedProvider(cfg appconfig.FederatedProviderConfig, httpClient *http.Client) *federatedProvider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = cfg.Name
+	}
+
+	if cfg.Type == appconfig.FederatedProviderGitHub {
+		if cfg.Issuer == "" {
+			cfg.Issuer = githubIssuer
+		}
+		if cfg.AuthorizationURL == "" {
+			cfg.AuthorizationURL = githubAuthorizationURL
+		}
+		if cfg.TokenURL == "" {
+			cfg.TokenURL = githubTokenURL
+		}
+		if cfg.UserInfoURL == "" {
+			cfg.UserInfoURL = githubUserInfoURL
+		}
+		if cfg.EmailsURL == "" {
+			cfg.EmailsURL = githubEmailsURL
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"read:user", "user:email"}
+		}
+	} else if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	} else if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+
+	return &federatedProvider{cfg: cfg, httpClient: httpClient}
+}
+
+// metadata 获取端点地址，oidc 类型从发现文档获取并缓存，配置中显式指定的地址优先
+func (p *federatedProvider) metadata(ctx context.Context) (*oidcDiscovery, error) {
+	if p.cfg.Type == appconfig.FederatedProviderGitHub {
+		return &oidcDiscovery{
+			Issuer:                p.cfg.Issuer,
+			AuthorizationEndpoint: p.cfg.AuthorizationURL,
+			TokenEndpoint:         p.cfg.TokenURL,
+			UserInfoEndpoint:      p.cfg.UserInfoURL,
+		}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil && time.Since(p.discoveredAt) < federatedDiscoveryTTL {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", "", &discovery); err != nil {
+		return nil, fmt.Errorf("获取发现文档失败: %w", err)
+	}
+	// 发现文档中的签发方须与配置一致，防止被替换为其他签发方
+	if strings.TrimRight(discovery.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("发现文档的 issuer 与配置不一致: %s", discovery.Issuer)
+	}
+	discovery.Issuer = p.cfg.Issuer
+	if p.cfg.AuthorizationURL != "" {
+		discovery.AuthorizationEndpoint = p.cfg.AuthorizationURL
+	}
+	if p.cfg.TokenURL != "" {
+		discovery.TokenEndpoint = p.cfg.TokenURL
+	}
+	if p.cfg.UserInfoURL != "" {
+		discovery.UserInfoEndpoint = p.cfg.UserInfoURL
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少必要的端点")
+	}
+
+	p.discovery = &discovery
+	p.discoveredAt = time.Now()
+	return p.discovery, nil
+}
+
+// authorizationURL 生成跳转到提供方的授权地址
+func (p *federatedProvider) authorizationURL(ctx context.Context, redirectURI, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("授权地址无效: %w", err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", utils.PKCEChallengeS256(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	if p.cfg.Type == appconfig.FederatedProviderOIDC {
+		query.Set("nonce", nonce)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// exchange 使用授权码换取令牌
+func (p *federatedProvider) exchange(ctx context.Context, code, redirectURI, codeVerifier string) (*federatedTokenResponse, error) {
+	metadata, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", codeVerifier)
+
+	// 发现文档未声明或声明支持 client_secret_basic 时使用 Basic 认证，否则在表单中携带
+	useBasic := p.cfg.Type == appconfig.FederatedProviderOIDC &&
+		(len(metadata.TokenAuthMethods) == 0 || slices.Contains(metadata.TokenAuthMethods, "client_secret_basic"))
+	if !useBasic {
+		form.Set("client_id", p.cfg.ClientID)
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasic {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var token federatedTokenResponse
+	status, err := p.do(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	// GitHub 在授权码无效时仍返回 200，错误放在响应体中
+	if token.Error != "" {
+		return nil, fmt.Errorf("令牌端点返回错误: %s %s", token.Error, token.ErrorDescription)
+	}
+	if status != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("令牌端点响应异常: status=%d", status)
+	}
+	if p.cfg.Type == appconfig.FederatedProviderOIDC && token.IDToken == "" {
+		return nil, errors.New("令牌端点未返回 id_token")
+	}
+	return &token, nil
+}
+
+// profile 根据令牌获取外部身份，身份校验失败时返回 ErrFederatedIdentityInvalid
+func (p *federatedProvider) profile(ctx context.Context, token *federatedTokenResponse, nonce string) (*FederatedProfile, error) {
+	if p.cfg.Type == appconfig.FederatedProviderGitHub {
+		return p.githubProfile(ctx, token.AccessToken)
+	}
+	return p.oidcProfile(ctx, token, nonce)
+}
+
+// oidcProfile 校验 ID Token 并提取身份，ID Token 中没有邮箱时从用户信息端点补充
+func (p *federatedProvider) oidcProfile(ctx context.Context, token *federatedTokenResponse, nonce string) (*FederatedProfile, error) {
+	metadata, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &federatedIDTokenClaims{}
+	parser := gojwt.NewParser(
+		gojwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		gojwt.WithIssuer(metadata.Issuer),
+		gojwt.WithAudience(p.cfg.ClientID),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithLeeway(time.Minute),
+	)
+	if _, err := parser.ParseWithClaims(token.IDToken, claims, func(t *gojwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.signingKey(ctx, metadata.JWKSURI, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %v", apperrors.ErrFederatedIdentityInvalid, err)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce 不匹配", apperrors.ErrFederatedIdentityInvalid)
+	}
+	// 多个受众时 azp 须为本客户端
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: azp 不匹配", apperrors.ErrFederatedIdentityInvalid)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: 缺少 sub", apperrors.ErrFederatedIdentityInvalid)
+	}
+
+	profile := &FederatedProfile{
+		Issuer:        metadata.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claimTrue(claims.EmailVerified),
+		Username:      claims.PreferredUsername,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}
+
+	if profile.Email == "" && metadata.UserInfoEndpoint != "" {
+		var userInfo struct {
+			Subject           string `json:"sub"`
+			Email             string `json:"email"`
+			EmailVerified     any    `json:"email_verified"`
+			Name              string `json:"name"`
+			PreferredUsername string `json:"preferred_username"`
+			Picture           string `json:"picture"`
+		}
+		if err := p.getJSON(ctx, metadata.UserInfoEndpoint, token.AccessToken, &userInfo); err != nil {
+			return nil, fmt.Errorf("获取用户信息失败: %w", err)
+		}
+		// 用户信息的 sub 须与 ID Token 一致，否则可能是被替换的响应
+		if userInfo.Subject != profile.Subject {
+			return nil, fmt.Errorf("%w: 用户信息的 sub 与 ID Token 不一致", apperrors.ErrFederatedIdentityInvalid)
+		}
+		profile.Email = userInfo.Email
+		profile.EmailVerified = claimTrue(userInfo.EmailVerified)
+		if profile.Username == "" {
+			profile.Username = userInfo.PreferredUsername
+		}
+		if profile.Name == "" {
+			profile.Name = userInfo.Name
+		}
+		if profile.Picture == "" {
+			profile.Picture = userInfo.Picture
+		}
+	}
+	return profile, nil
+}
+
+// githubProfile 通过 GitHub 用户信息接口获取身份，邮箱取已验证的主邮箱
+func (p *federatedProvider) githubProfile(ctx context.Context, accessToken string) (*FederatedProfile, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.getJSON(ctx, p.cfg.UserInfoURL, accessToken, &user); err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("%w: 缺少用户ID", apperrors.ErrFederatedIdentityInvalid)
+	}
+
+	profile := &FederatedProfile{
+		Issuer:   p.cfg.Issuer,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Username: user.Login,
+		Name:     user.Name,
+		Picture:  user.AvatarURL,
+	}
+
+	// 未授予 user:email 时获取不到邮箱，不影响登录
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.cfg.EmailsURL, accessToken, &emails); err == nil {
+		for _, email := range emails {
+			if email.Verified && (email.Primary || profile.Email == "") {
+				profile.Email = email.Email
+				profile.EmailVerified = true
+			}
+		}
+	}
+	return profile, nil
+}
+
+// signingKey 按 kid 查找签名公钥，未知 kid 时重新获取 JWKS，以支持提供方轮换密钥
+func (p *federatedProvider) signingKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetchedAt) < federatedJWKSRefreshAfter {
+		return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+	}
+
+	var set utils.JWKSet
+	if err := p.getJSON(ctx, jwksURI, "", &set); err != nil {
+		return nil, fmt.Errorf("获取 JWKS 失败: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+}
+
+// lookupKey 在已缓存的密钥中查找；ID Token 未指定 kid 时只接受唯一的密钥
+func (p *federatedProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok && kid != ""
+}
+
+// getJSON 发起 GET 请求并解析 JSON 响应，accessToken 不为空时以 Bearer 方式携带
+func (p *federatedProvider) getJSON(ctx context.Context, endpoint, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	status, err := p.do(req, v)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("响应状态异常: status=%d", status)
+	}
+	return nil
+}
+
+// do 发送请求并解析 JSON 响应体，响应体大小受限
+func (p *federatedProvider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, federatedResponseLimit))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// claimTrue 兼容布尔值与字符串形式的声明
+func claimTrue(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,580 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/3086953492/gokit/security/random"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const (
+	federatedStateKeyPrefix  = "federated:state:"
+	federatedTicketKeyPrefix = "federated:ticket:"
+	federatedTicketTTL       = 2 * time.Minute
+)
+
+// 发起外部身份授权的目的
+const (
+	federatedIntentLogin = "login" // 登录，首次登录时按配置创建本地账号
+	federatedIntentLink  = "link"  // 为已登录用户关联外部账号
+)
+
+// federatedState 一次跳转到提供方的授权流程，以 state 作为 Redis 键，只能使用一次
+type federatedState struct {
+	Provider     string `json:"provider"`
+	Intent       string `json:"intent"`
+	UserID       uint   `json:"user_id"` // 关联时为当前用户，登录时为 0
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	Redirect     string `json:"redirect"` // 登录完成后返回的前端路径
+}
+
+// FederationService 外部身份提供方登录与账号关联
+// 提供方回调后不直接签发令牌，而是生成一次性登录凭据交给前端换取登录结果，
+// 使两步验证等后续流程与密码登录保持一致
+type FederationService struct {
+	identityRepository *repositories.FederatedIdentityRepository
+	userRepository     *repositories.UserRepository
+	userService        *UserService
+	redisMgr           *redis.Manager
+	tokenHasher        *utils.TokenHasher
+	logMgr             *logger.Manager
+	providers          map[string]*federatedProvider
+	providerNames      []string // 保持配置中的顺序
+	stateTTL           time.Duration
+	baseURL            string
+	frontendURL        string
+}
+
+// NewFederationService 创建外部身份登录服务实例
+// baseURL 用于生成提供方回调地址，frontendURL 用于回调完成后跳转回前端
+func NewFederationService(identityRepository *repositories.FederatedIdentityRepository, userRepository *repositories.UserRepository, userService *UserService, redisMgr *redis.Manager, tokenHasher *utils.TokenHasher, logMgr *logger.Manager, cfg appconfig.FederationConfig, baseURL, frontendURL string) *FederationService {
+	httpClient := &http.Client{Timeout: cfg.HTTPTimeout}
+	providers := make(map[string]*federatedProvider, len(cfg.Providers))
+	names := make([]string, 0, len(cfg.Providers))
+	for _, providerCfg := range cfg.Providers {
+		providers[providerCfg.Name] = newFederatedProvider(providerCfg, httpClient)
+		names = append(names, providerCfg.Name)
+	}
+	return &FederationService{
+		identityRepository: identityRepository,
+		userRepository:     userRepository,
+		userService:        userService,
+		redisMgr:           redisMgr,
+		tokenHasher:        tokenHasher,
+		logMgr:             logMgr,
+		providers:          providers,
+		providerNames:      names,
+		stateTTL:           cfg.StateTTL,
+		baseURL:            strings.TrimRight(baseURL, "/"),
+		frontendURL:        strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// StateTTL 授权流程的有效期，state Cookie 使用相同的有效期
+func (s *FederationService) StateTTL() time.Duration {
+	return s.stateTTL
+}
+
+// Providers 获取可用的身份提供方
+func (s *FederationService) Providers() []dto.FederatedProviderResponse {
+	items := make([]dto.FederatedProviderResponse, 0, len(s.providerNames))
+	for _, name := range s.providerNames {
+		provider := s.providers[name]
+		items = append(items, dto.FederatedProviderResponse{Name: name, DisplayName: provider.cfg.DisplayName, Type: provider.cfg.Type})
+	}
+	return items
+}
+
+// BeginLogin 发起外部身份登录，返回提供方授权地址与 state
+// redirect 只接受站内路径，其他值忽略
+func (s *FederationService) BeginLogin(ctx context.Context, providerName, redirect string) (string, string, error) {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		redirect = ""
+	}
+	return s.begin(ctx, providerName, &federatedState{Intent: federatedIntentLogin, Redirect: redirect})
+}
+
+// BeginLink 为当前用户发起外部账号关联，返回提供方授权地址与 state
+func (s *FederationService) BeginLink(ctx context.Context, providerName string, userID uint) (string, string, error) {
+	count, err := s.identityRepository.Count(ctx, map[string]any{"user_id": userID, "provider": providerName})
+	if err != nil {
+		s.logMgr.Error("统计外部身份关联失败", "error", err, "user_id", userID)
+		return "", "", apperrors.ErrUserSystemBusy
+	}
+	if count > 0 {
+		return "", "", apperrors.ErrFederatedProviderLinked
+	}
+	return s.begin(ctx, providerName, &federatedState{Intent: federatedIntentLink, UserID: userID})
+}
+
+// begin 生成 state、nonce 与 PKCE 校验码并保存授权流程
+func (s *FederationService) begin(ctx context.Context, providerName string, st *federatedState) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", apperrors.ErrFederatedProviderNotFound
+	}
+	st.Provider = providerName
+
+	state, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成 state 失败", "error", err)
+		return "", "", apperrors.ErrUserSystemBusy
+	}
+	if st.Nonce, err = random.URLSafe(32); err != nil {
+		s.logMgr.Error("生成 nonce 失败", "error", err)
+		return "", "", apperrors.ErrUserSystemBusy
+	}
+	if st.CodeVerifier, err = random.URLSafe(48); err != nil {
+		s.logMgr.Error("生成 PKCE 校验码失败", "error", err)
+		return "", "", apperrors.ErrUserSystemBusy
+	}
+
+	authURL, err := provider.authorizationURL(ctx, s.callbackURL(ctx, providerName), state, st.Nonce, st.CodeVerifier)
+	if err != nil {
+		s.logMgr.Error("生成身份提供方授权地址失败", "error", err, "provider", providerName)
+		return "", "", apperrors.ErrFederatedUpstreamFailed
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return "", "", apperrors.ErrUserSystemBusy
+	}
+	if err := s.redisMgr.SetBytes(ctx, federatedStateKeyPrefix+s.tokenHasher.Hash(state), data, s.stateTTL); err != nil {
+		s.logMgr.Error("保存外部登录流程失败", "error", err, "provider", providerName)
+		return "", "", apperrors.ErrUserSystemBusy
+	}
+	return authURL, state, nil
+}
+
+// Callback 处理提供方的回调，返回跳转回前端的地址
+// stateCookie 为发起授权时写入浏览器的 state，与回调参数一致才能证明是同一浏览器发起的流程
+// 登录成功时地址中携带一次性登录凭据，失败时携带错误信息
+func (s *FederationService) Callback(ctx context.Context, providerName, state, stateCookie, code, upstreamError string) string {
+	st, err := s.takeState(ctx, state)
+	if err != nil {
+		return s.failureRedirect(federatedIntentLogin, err)
+	}
+	if st.Provider != providerName || subtle.ConstantTimeCompare([]byte(state), []byte(stateCookie)) != 1 {
+		return s.failureRedirect(st.Intent, apperrors.ErrFederatedStateInvalid)
+	}
+	if upstreamError != "" {
+		if upstreamError == "access_denied" {
+			return s.failureRedirect(st.Intent, apperrors.ErrFederatedCanceled)
+		}
+		s.logMgr.Warn("身份提供方返回错误", "provider", providerName, "error", upstreamError)
+		return s.failureRedirect(st.Intent, apperrors.ErrFederatedUpstreamFailed)
+	}
+	if code == "" {
+		return s.failureRedirect(st.Intent, apperrors.ErrFederatedStateInvalid)
+	}
+
+	provider := s.providers[providerName]
+	if provider == nil {
+		return s.failureRedirect(st.Intent, apperrors.ErrFederatedProviderNotFound)
+	}
+	token, err := provider.exchange(ctx, code, s.callbackURL(ctx, providerName), st.CodeVerifier)
+	if err != nil {
+		s.logMgr.Warn("身份提供方授权码换取令牌失败", "error", err, "provider", providerName)
+		return s.failureRedirect(st.Intent, apperrors.ErrFederatedUpstreamFailed)
+	}
+	profile, err := provider.profile(ctx, token, st.Nonce)
+	if err != nil {
+		s.logMgr.Warn("获取外部身份失败", "error", err, "provider", providerName)
+		if errors.Is(err, apperrors.ErrFederatedIdentityInvalid) {
+			return s.failureRedirect(st.Intent, apperrors.ErrFederatedIdentityInvalid)
+		}
+		return s.failureRedirect(st.Intent, apperrors.ErrFederatedUpstreamFailed)
+	}
+
+	if st.Intent == federatedIntentLink {
+		if err := s.link(ctx, provider, st.UserID, profile); err != nil {
+			return s.failureRedirect(st.Intent, err)
+		}
+		return s.frontendRedirect("/profile", url.Values{"federated_linked": {providerName}})
+	}
+
+	userID, err := s.login(ctx, provider, profile)
+	if err != nil {
+		return s.failureRedirect(st.Intent, err)
+	}
+	ticket, err := s.issueTicket(ctx, userID)
+	if err != nil {
+		return s.failureRedirect(st.Intent, err)
+	}
+	params := url.Values{"federated_ticket": {ticket}}
+	if st.Redirect != "" {
+		params.Set("redirect", st.Redirect)
+	}
+	return s.frontendRedirect("/login", params)
+}
+
+// ConsumeTicket 使用一次性登录凭据，返回对应的用户ID
+func (s *FederationService) ConsumeTicket(ctx context.Context, ticket string) (uint, error) {
+	key := federatedTicketKeyPrefix + s.tokenHasher.Hash(ticket)
+	data, err := s.redisMgr.GetBytes(ctx, key)
+	if err != nil {
+		s.logMgr.Error("读取外部登录凭据失败", "error", err)
+		return 0, apperrors.ErrUserSystemBusy
+	}
+	if data == nil {
+		return 0, apperrors.ErrFederatedTicketInvalid
+	}
+	n, err := s.redisMgr.Del(ctx, key)
+	if err != nil {
+		s.logMgr.Error("删除外部登录凭据失败", "error", err)
+		return 0, apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return 0, apperrors.ErrFederatedTicketInvalid
+	}
+
+	userID, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0, apperrors.ErrFederatedTicketInvalid
+	}
+	return uint(userID), nil
+}
+
+// ListIdentities 获取用户已关联的外部账号
+func (s *FederationService) ListIdentities(ctx context.Context, userID uint) ([]dto.FederatedIdentityResponse, error) {
+	identities, err := s.identityRepository.Find(ctx, map[string]any{"user_id": userID})
+	if err != nil {
+		s.logMgr.Error("获取外部身份关联失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+
+	items := make([]dto.FederatedIdentityResponse, len(identities))
+	for i, identity := range identities {
+		displayName := identity.Provider
+		if provider, ok := s.providers[identity.Provider]; ok {
+			displayName = provider.cfg.DisplayName
+		}
+		items[i] = dto.FederatedIdentityResponse{
+			ID:                  identity.ID,
+			Provider:            identity.Provider,
+			ProviderDisplayName: displayName,
+			Email:               identity.Email,
+			Username:            identity.Username,
+			CreatedAt:           identity.CreatedAt,
+			LastLoginAt:         identity.LastLoginAt,
+		}
+	}
+	return items, nil
+}
+
+// Unlink 解除外部账号关联，未设置本地密码的账号不能解除最后一个关联
+func (s *FederationService) Unlink(ctx context.Context, userID, identityID uint) error {
+	if _, err := s.identityRepository.Get(ctx, map[string]any{"id": identityID, "user_id": userID}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrFederatedIdentityNotFound
+		}
+		s.logMgr.Error("获取外部身份关联失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrUserNotFound
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if user.PasswordUnset {
+		count, err := s.identityRepository.Count(ctx, map[string]any{"user_id": userID})
+		if err != nil {
+			s.logMgr.Error("统计外部身份关联失败", "error", err, "user_id", userID)
+			return apperrors.ErrUserSystemBusy
+		}
+		if count <= 1 {
+			return apperrors.ErrFederatedLastLoginMethod
+		}
+	}
+
+	affected, err := s.identityRepository.Delete(ctx, map[string]any{"id": identityID, "user_id": userID})
+	if err != nil {
+		s.logMgr.Error("删除外部身份关联失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if affected == 0 {
+		return apperrors.ErrFederatedIdentityNotFound
+	}
+
+	s.logMgr.Info("解除外部身份关联", "event", "user.federated_identity_unlinked", "user_id", userID, "identity_id", identityID)
+	return nil
+}
+
+// login 查找外部身份关联的本地用户，尚未关联时按配置创建本地账号
+// 不按邮箱自动关联已有账号：提供方的邮箱即使已验证，也不能证明与本地账号属于同一人
+func (s *FederationService) login(ctx context.Context, provider *federatedProvider, profile *FederatedProfile) (uint, error) {
+	identity, err := s.identityRepository.Get(ctx, map[string]any{"issuer": profile.Issuer, "subject": profile.Subject})
+	if err == nil {
+		s.touchIdentity(ctx, identity, profile)
+		return identity.UserID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logMgr.Error("获取外部身份关联失败", "error", err)
+		return 0, apperrors.ErrUserSystemBusy
+	}
+
+	if !provider.cfg.AllowSignup {
+		return 0, apperrors.ErrFederatedSignupDisabled
+	}
+	return s.provision(ctx, provider, profile)
+}
+
+// provision 为首次登录的外部身份创建本地账号
+func (s *FederationService) provision(ctx context.Context, provider *federatedProvider, profile *FederatedProfile) (uint, error) {
+	user := &models.User{}
+
+	if profile.Email != "" {
+		email := normalizeEmail(profile.Email)
+		_, err := s.userRepository.GetWithDeleted(ctx, map[string]any{"email": email})
+		switch {
+		case err == nil:
+			// 提供方已验证邮箱时提示用户先登录原账号再关联；未验证的邮箱不能据此透露本地账号的存在
+			if profile.EmailVerified {
+				return 0, apperrors.ErrFederatedEmailConflict
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user.Email = &email
+			if profile.EmailVerified {
+				now := time.Now()
+				user.EmailVerifiedAt = &now
+			}
+		default:
+			s.logMgr.Error("获取用户失败", "error", err)
+			return 0, apperrors.ErrUserSystemBusy
+		}
+	}
+
+	username, err := s.availableUsername(ctx, profile)
+	if err != nil {
+		return 0, err
+	}
+	user.Username = username
+	user.Nickname = truncateRunes(profile.Name, 20)
+	if user.Nickname == "" {
+		user.Nickname = username
+	}
+	if len(profile.Picture) <= 500 {
+		user.Avatar = profile.Picture
+	}
+
+	now := time.Now()
+	err = s.userService.CreateFederatedUser(ctx, user, func(tx *gorm.DB, user *models.User) error {
+		identity := &models.FederatedIdentity{
+			UserID:      user.ID,
+			Provider:    provider.cfg.Name,
+			Issuer:      profile.Issuer,
+			Subject:     profile.Subject,
+			Email:       profile.Email,
+			Username:    profile.Username,
+			LastLoginAt: &now,
+		}
+		if err := s.identityRepository.CreateWithTx(ctx, tx, identity); err != nil {
+			s.logMgr.Error("创建外部身份关联失败", "error", err, "provider", provider.cfg.Name)
+			return apperrors.ErrUserCreateFailed
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.logMgr.Info("外部身份创建本地账号", "event", "user.federated_signup", "user_id", user.ID, "provider", provider.cfg.Name)
+	return user.ID, nil
+}
+
+// link 将外部身份关联到当前用户，重复关联同一外部身份视为成功
+func (s *FederationService) link(ctx context.Context, provider *federatedProvider, userID uint, profile *FederatedProfile) error {
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrUserNotFound
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if err := CheckUserStatus(user); err != nil {
+		return err
+	}
+
+	existing, err := s.identityRepository.Get(ctx, map[string]any{"issuer": profile.Issuer, "subject": profile.Subject})
+	if err == nil {
+		if existing.UserID != userID {
+			return apperrors.ErrFederatedIdentityTaken
+		}
+		s.touchIdentity(ctx, existing, profile)
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logMgr.Error("获取外部身份关联失败", "error", err)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	count, err := s.identityRepository.Count(ctx, map[string]any{"user_id": userID, "provider": provider.cfg.Name})
+	if err != nil {
+		s.logMgr.Error("统计外部身份关联失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if count > 0 {
+		return apperrors.ErrFederatedProviderLinked
+	}
+
+	identity := &models.FederatedIdentity{
+		UserID:   userID,
+		Provider: provider.cfg.Name,
+		Issuer:   profile.Issuer,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+		Username: profile.Username,
+	}
+	if err := s.identityRepository.Create(ctx, identity); err != nil {
+		s.logMgr.Error("创建外部身份关联失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	s.logMgr.Info("关联外部身份", "event", "user.federated_identity_linked", "user_id", userID, "provider", provider.cfg.Name)
+	return nil
+}
+
+// touchIdentity 记录登录时间并刷新展示用的外部信息，失败只记录日志
+func (s *FederationService) touchIdentity(ctx context.Context, identity *models.FederatedIdentity, profile *FederatedProfile) {
+	if err := s.identityRepository.Update(ctx, identity.ID, map[string]any{
+		"email":         profile.Email,
+		"username":      profile.Username,
+		"last_login_at": time.Now(),
+	}); err != nil {
+		s.logMgr.Warn("更新外部身份关联失败", "error", err, "identity_id", identity.ID)
+	}
+}
+
+// availableUsername 由外部用户名或邮箱生成未被占用的本地用户名，冲突时追加随机数字
+func (s *FederationService) availableUsername(ctx context.Context, profile *FederatedProfile) (string, error) {
+	base := sanitizeUsername(profile.Username)
+	if base == "" {
+		base = sanitizeUsername(strings.Split(profile.Email, "@")[0])
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	candidate := base
+	for range 5 {
+		_, err := s.userRepository.GetWithDeleted(ctx, map[string]any{"username": candidate})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			s.logMgr.Error("获取用户失败", "error", err)
+			return "", apperrors.ErrUserSystemBusy
+		}
+		candidate = fmt.Sprintf("%s_%04d", base, rand.IntN(10000))
+	}
+	return "", apperrors.ErrUserSystemBusy
+}
+
+// takeState 取出并作废 state 对应的授权流程
+func (s *FederationService) takeState(ctx context.Context, state string) (*federatedState, error) {
+	if state == "" {
+		return nil, apperrors.ErrFederatedStateInvalid
+	}
+	key := federatedStateKeyPrefix + s.tokenHasher.Hash(state)
+	data, err := s.redisMgr.GetBytes(ctx, key)
+	if err != nil {
+		s.logMgr.Error("读取外部登录流程失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if data == nil {
+		return nil, apperrors.ErrFederatedStateInvalid
+	}
+	n, err := s.redisMgr.Del(ctx, key)
+	if err != nil {
+		s.logMgr.Error("删除外部登录流程失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return nil, apperrors.ErrFederatedStateInvalid
+	}
+
+	var st federatedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, apperrors.ErrFederatedStateInvalid
+	}
+	return &st, nil
+}
+
+// issueTicket 生成一次性登录凭据
+func (s *FederationService) issueTicket(ctx context.Context, userID uint) (string, error) {
+	ticket, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成外部登录凭据失败", "error", err)
+		return "", apperrors.ErrUserSystemBusy
+	}
+	if err := s.redisMgr.SetBytes(ctx, federatedTicketKeyPrefix+s.tokenHasher.Hash(ticket), []byte(strconv.FormatUint(uint64(userID), 10)), federatedTicketTTL); err != nil {
+		s.logMgr.Error("保存外部登录凭据失败", "error", err, "user_id", userID)
+		return "", apperrors.ErrUserSystemBusy
+	}
+	return ticket, nil
+}
+
+// callbackURL 提供方回调地址，须与在提供方登记的地址一致
+func (s *FederationService) callbackURL(ctx context.Context, providerName string) string {
+	return utils.RealmURL(ctx, s.baseURL, "/api/v1/auth/federated/"+providerName+"/callback")
+}
+
+func (s *FederationService) frontendRedirect(path string, params url.Values) string {
+	return s.frontendURL + path + "?" + params.Encode()
+}
+
+// failureRedirect 失败时跳转回发起流程的页面：登录回到登录页，关联回到个人中心
+func (s *FederationService) failureRedirect(intent string, err error) string {
+	path := "/login"
+	if intent == federatedIntentLink {
+		path = "/profile"
+	}
+	return s.frontendRedirect(path, url.Values{"federated_error": {err.Error()}})
+}
+
+// sanitizeUsername 只保留字母、数字与下划线，截断到 15 个字符，为冲突时的数字后缀留出长度
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < utf8.RuneSelf && (r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+		}
+		if b.Len() == 15 {
+			break
+		}
+	}
+	return b.String()
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
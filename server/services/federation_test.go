@@ -0,0 +1,387 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const (
+	testIdPClientID     = "goauth-client"
+	testIdPClientSecret = "goauth-secret"
+	testIdPSubject      = "idp-user-1"
+	testIdPKeyID        = "idp-key-1"
+)
+
+// idpGrant 测试身份提供方签发的授权码对应的授权请求
+type idpGrant struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+}
+
+// testIdP 用 httptest 实现的 OIDC 身份提供方，签发 ES256 的 ID Token
+// 令牌端点按 RFC 7636 校验 code_verifier，与真实提供方一样拒绝不匹配的 PKCE
+type testIdP struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]idpGrant
+
+	// discoveryIssuer 发现文档中声明的 issuer，为空时使用服务地址
+	discoveryIssuer string
+	// omitEmail ID Token 中不携带邮箱，使依赖方从用户信息端点补充
+	omitEmail bool
+	// userInfoSubject 用户信息端点返回的 sub，为空时与 ID Token 一致
+	userInfoSubject string
+	// mutateClaims 签发前修改 ID Token 的声明
+	mutateClaims func(claims gojwt.MapClaims)
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, grants: make(map[string]idpGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("GET /jwks", idp.handleJWKS)
+	mux.HandleFunc("POST /token", idp.handleToken)
+	mux.HandleFunc("GET /userinfo", idp.handleUserInfo)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *testIdP) issuer() string {
+	return idp.server.URL
+}
+
+// authorize 模拟用户在提供方同意授权，校验授权请求后返回授权码
+func (idp *testIdP) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != testIdPClientID || query.Get("response_type") != "code" {
+		t.Fatalf("授权请求参数错误: %s", authURL)
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("授权请求未使用 PKCE: %s", authURL)
+	}
+	if query.Get("nonce") == "" || query.Get("state") == "" {
+		t.Fatalf("授权请求缺少 state 或 nonce: %s", authURL)
+	}
+
+	code := "code-" + query.Get("state")[:16]
+	idp.mu.Lock()
+	idp.grants[code] = idpGrant{redirectURI: query.Get("redirect_uri"), codeChallenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *testIdP) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	issuer := idp.discoveryIssuer
+	if issuer == "" {
+		issuer = idp.issuer()
+	}
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                idp.issuer() + "/authorize",
+		"token_endpoint":                        idp.issuer() + "/token",
+		"userinfo_endpoint":                     idp.issuer() + "/userinfo",
+		"jwks_uri":                              idp.issuer() + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+	})
+}
+
+func (idp *testIdP) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	pub := idp.key.PublicKey
+	writeTestJSON(w, http.StatusOK, utils.JWKSet{Keys: []utils.JWK{{
+		Kty: "EC",
+		Kid: testIdPKeyID,
+		Use: "sig",
+		Alg: "ES256",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+	}}})
+}
+
+func (idp *testIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != testIdPClientID || clientSecret != testIdPClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	idp.mu.Lock()
+	grant, ok := idp.grants[code]
+	delete(idp.grants, code)
+	idp.mu.Unlock()
+	if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") || utils.PKCEChallengeS256(r.PostForm.Get("code_verifier")) != grant.codeChallenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := gojwt.MapClaims{
+		"iss":   idp.issuer(),
+		"sub":   testIdPSubject,
+		"aud":   testIdPClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": grant.nonce,
+		"name":  "Alice",
+	}
+	if !idp.omitEmail {
+		claims["email"] = "alice@idp.example"
+		claims["email_verified"] = true
+	}
+	if idp.mutateClaims != nil {
+		idp.mutateClaims(claims)
+	}
+	token := gojwt.NewWithClaims(gojwt.SigningMethodES256, claims)
+	token.Header["kid"] = testIdPKeyID
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]any{"access_token": "access-" + code, "token_type": "Bearer", "id_token": idToken})
+}
+
+func (idp *testIdP) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer access-") {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	subject := idp.userInfoSubject
+	if subject == "" {
+		subject = testIdPSubject
+	}
+	writeTestJSON(w, http.StatusOK, map[string]any{"sub": subject, "email": "alice@idp.example", "email_verified": true})
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+type federationTestEnv struct {
+	service *FederationService
+	idp     *testIdP
+	user    *models.User
+}
+
+// newFederationTestEnv 创建连接到测试身份提供方的外部登录服务，外部身份已关联到本地用户
+func newFederationTestEnv(t *testing.T) *federationTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.FederatedIdentity{})
+	redisMgr, _ := newTestRedis(t)
+	idp := newTestIdP(t)
+
+	ctx := context.Background()
+	userRepository := repositories.NewUserRepository(db)
+	user := &models.User{Subject: "subject-alice", Username: "alice", Password: "x", Status: models.UserStatusActive}
+	if err := userRepository.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	identityRepository := repositories.NewFederatedIdentityRepository(db)
+	if err := identityRepository.Create(ctx, &models.FederatedIdentity{UserID: user.ID, Provider: "idp", Issuer: idp.issuer(), Subject: testIdPSubject}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := appconfig.FederationConfig{
+		StateTTL:    10 * time.Minute,
+		HTTPTimeout: 5 * time.Second,
+		Providers: []appconfig.FederatedProviderConfig{{
+			Name:         "idp",
+			Type:         appconfig.FederatedProviderOIDC,
+			Issuer:       idp.issuer(),
+			ClientID:     testIdPClientID,
+			ClientSecret: testIdPClientSecret,
+		}},
+	}
+	service := NewFederationService(identityRepository, userRepository, nil, redisMgr, newTestTokenHasher(t), newTestLogger(t), cfg, "https://auth.example.com", "https://app.example.com")
+	return &federationTestEnv{service: service, idp: idp, user: user}
+}
+
+// begin 发起登录并在提供方完成授权，返回 state 与授权码
+func (env *federationTestEnv) begin(t *testing.T) (string, string) {
+	t.Helper()
+	authURL, state, err := env.service.BeginLogin(context.Background(), "idp", "/dashboard")
+	if err != nil {
+		t.Fatalf("发起外部登录失败: %v", err)
+	}
+	return state, env.idp.authorize(t, authURL)
+}
+
+// callback 处理回调并解析跳转回前端的地址参数
+func (env *federationTestEnv) callback(t *testing.T, state, stateCookie, code string) url.Values {
+	t.Helper()
+	redirect := env.service.Callback(context.Background(), "idp", state, stateCookie, code, "")
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatalf("回调跳转地址无效: %s", redirect)
+	}
+	return u.Query()
+}
+
+func assertFederatedError(t *testing.T, params url.Values, want error) {
+	t.Helper()
+	if params.Get("federated_ticket") != "" {
+		t.Fatalf("期望登录失败，实际签发了登录凭据: %v", params)
+	}
+	if got := params.Get("federated_error"); got != want.Error() {
+		t.Fatalf("federated_error = %q，期望 %q", got, want.Error())
+	}
+}
+
+func TestFederationLogin(t *testing.T) {
+	env := newFederationTestEnv(t)
+	state, code := env.begin(t)
+
+	params := env.callback(t, state, state, code)
+	ticket := params.Get("federated_ticket")
+	if ticket == "" {
+		t.Fatalf("登录失败: %v", params)
+	}
+	if params.Get("redirect") != "/dashboard" {
+		t.Fatalf("redirect = %q，期望 /dashboard", params.Get("redirect"))
+	}
+
+	userID, err := env.service.ConsumeTicket(context.Background(), ticket)
+	if err != nil {
+		t.Fatalf("使用登录凭据失败: %v", err)
+	}
+	if userID != env.user.ID {
+		t.Fatalf("登录用户 = %d，期望 %d", userID, env.user.ID)
+	}
+	if _, err := env.service.ConsumeTicket(context.Background(), ticket); err != apperrors.ErrFederatedTicketInvalid {
+		t.Fatalf("重复使用登录凭据 err = %v，期望 ErrFederatedTicketInvalid", err)
+	}
+}
+
+func TestFederationStateMismatch(t *testing.T) {
+	env := newFederationTestEnv(t)
+
+	t.Run("Cookie", func(t *testing.T) {
+		state, code := env.begin(t)
+		assertFederatedError(t, env.callback(t, state, "other-browser-state", code), apperrors.ErrFederatedStateInvalid)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, code := env.begin(t)
+		assertFederatedError(t, env.callback(t, "forged-state", "forged-state", code), apperrors.ErrFederatedStateInvalid)
+	})
+	t.Run("Replay", func(t *testing.T) {
+		state, code := env.begin(t)
+		if params := env.callback(t, state, state, code); params.Get("federated_ticket") == "" {
+			t.Fatalf("首次回调失败: %v", params)
+		}
+		assertFederatedError(t, env.callback(t, state, state, code), apperrors.ErrFederatedStateInvalid)
+	})
+}
+
+func TestFederationPKCEMismatch(t *testing.T) {
+	env := newFederationTestEnv(t)
+
+	// 把另一次授权流程得到的授权码注入当前流程，code_verifier 与该授权码的 code_challenge 不匹配
+	_, injectedCode := env.begin(t)
+	state, _ := env.begin(t)
+	assertFederatedError(t, env.callback(t, state, state, injectedCode), apperrors.ErrFederatedUpstreamFailed)
+}
+
+func TestFederationIDTokenRejected(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(claims gojwt.MapClaims)
+	}{
+		{"NonceMismatch", func(claims gojwt.MapClaims) { claims["nonce"] = "other-nonce" }},
+		{"NonceMissing", func(claims gojwt.MapClaims) { delete(claims, "nonce") }},
+		{"IssuerMismatch", func(claims gojwt.MapClaims) { claims["iss"] = "https://evil.example.com" }},
+		{"AudienceMismatch", func(claims gojwt.MapClaims) { claims["aud"] = "other-client" }},
+		{"AuthorizedPartyMissing", func(claims gojwt.MapClaims) { claims["aud"] = []string{testIdPClientID, "other-client"} }},
+		{"AuthorizedPartyMismatch", func(claims gojwt.MapClaims) {
+			claims["aud"] = []string{testIdPClientID, "other-client"}
+			claims["azp"] = "other-client"
+		}},
+		{"Expired", func(claims gojwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"SubjectMissing", func(claims gojwt.MapClaims) { delete(claims, "sub") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newFederationTestEnv(t)
+			env.idp.mutateClaims = tc.mutate
+			state, code := env.begin(t)
+			assertFederatedError(t, env.callback(t, state, state, code), apperrors.ErrFederatedIdentityInvalid)
+		})
+	}
+}
+
+func TestFederationAuthorizedPartyMatches(t *testing.T) {
+	env := newFederationTestEnv(t)
+	env.idp.mutateClaims = func(claims gojwt.MapClaims) {
+		claims["aud"] = []string{testIdPClientID, "other-client"}
+		claims["azp"] = testIdPClientID
+	}
+	state, code := env.begin(t)
+	if params := env.callback(t, state, state, code); params.Get("federated_ticket") == "" {
+		t.Fatalf("azp 为本客户端时应登录成功: %v", params)
+	}
+}
+
+func TestFederationUserInfo(t *testing.T) {
+	t.Run("SubjectMatches", func(t *testing.T) {
+		env := newFederationTestEnv(t)
+		env.idp.omitEmail = true
+		state, code := env.begin(t)
+		if params := env.callback(t, state, state, code); params.Get("federated_ticket") == "" {
+			t.Fatalf("登录失败: %v", params)
+		}
+	})
+	t.Run("SubjectMismatch", func(t *testing.T) {
+		env := newFederationTestEnv(t)
+		env.idp.omitEmail = true
+		env.idp.userInfoSubject = "idp-user-2"
+		state, code := env.begin(t)
+		assertFederatedError(t, env.callback(t, state, state, code), apperrors.ErrFederatedIdentityInvalid)
+	})
+}
+
+func TestFederationDiscoveryIssuerMismatch(t *testing.T) {
+	env := newFederationTestEnv(t)
+	env.idp.discoveryIssuer = "https://evil.example.com"
+	if _, _, err := env.service.BeginLogin(context.Background(), "idp", ""); err != apperrors.ErrFederatedUpstreamFailed {
+		t.Fatalf("发现文档 issuer 不一致 err = %v，期望 ErrFederatedUpstreamFailed", err)
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+)
+
+// GroupService 用户组管理，供管理员维护用户组的成员与角色；预配方通过 SCIM 接口维护同一批用户组
+type GroupService struct {
+	userGroupRepository *repositories.UserGroupRepository
+	userRepository      *repositories.UserRepository
+	rbacService         *RBACService
+	logMgr              *logger.Manager
+}
+
+// NewGroupService 创建用户组管理服务实例
+func NewGroupService(userGroupRepository *repositories.UserGroupRepository, userRepository *repositories.UserRepository, rbacService *RBACService, logMgr *logger.Manager) *GroupService {
+	return &GroupService{userGroupRepository: userGroupRepository, userRepository: userRepository, rbacService: rbacService, logMgr: logMgr}
+}
+
+func (s *GroupService) ListGroups(ctx context.Context, page, pageSize int, displayName string) (*dto.PaginationResponse[dto.GroupListResponse], error) {
+	var query string
+	var args []any
+	if displayName != "" {
+		query, args = "display_name LIKE ?", []any{"%" + displayName + "%"}
+	}
+	groups, total, err := s.userGroupRepository.Search(ctx, (page-1)*pageSize, pageSize, query, args...)
+	if err != nil {
+		s.logMgr.Error("获取用户组列表失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+
+	groupIDs := make([]uint, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = group.ID
+	}
+	members, err := s.userGroupRepository.ListMembers(ctx, groupIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	memberCount := make(map[uint]int, len(groups))
+	for _, member := range members {
+		memberCount[member.GroupID]++
+	}
+	roles, err := s.rbacService.GroupRoleNames(ctx, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]dto.GroupListResponse, len(groups))
+	for i, group := range groups {
+		items[i] = dto.GroupListResponse{
+			ID:          group.ID,
+			DisplayName: group.DisplayName,
+			MemberCount: memberCount[group.ID],
+			Roles:       roles[group.ID],
+			CreatedAt:   group.CreatedAt,
+		}
+	}
+	return &dto.PaginationResponse[dto.GroupListResponse]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
+
+func (s *GroupService) GetGroup(ctx context.Context, id uint) (*dto.GroupDetailResponse, error) {
+	group, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.userGroupRepository.ListMembers(ctx, []uint{group.ID})
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err, "group_id", group.ID)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	userIDs := make([]uint, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	users, err := s.userRepository.FindByIDs(ctx, userIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err, "group_id", group.ID)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	roles, err := s.rbacService.GroupRoleNames(ctx, []uint{group.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.GroupDetailResponse{
+		ID:          group.ID,
+		DisplayName: group.DisplayName,
+		Members:     make([]dto.GroupMemberResponse, len(users)),
+		Roles:       roles[group.ID],
+		CreatedAt:   group.CreatedAt,
+		UpdatedAt:   group.UpdatedAt,
+	}
+	for i, user := range users {
+		resp.Members[i] = dto.GroupMemberResponse{ID: user.ID, Username: user.Username, Nickname: user.Nickname}
+	}
+	return resp, nil
+}
+
+func (s *GroupService) CreateGroup(ctx context.Context, req *dto.CreateGroupRequest) error {
+	displayName, err := s.checkDisplayName(ctx, req.DisplayName, 0)
+	if err != nil {
+		return err
+	}
+	userIDs, err := s.memberIDs(ctx, req.Members)
+	if err != nil {
+		return err
+	}
+	// 先校验角色，避免用户组已创建而角色无效
+	if _, err := s.rbacService.roleIDs(ctx, req.Roles); err != nil {
+		return err
+	}
+
+	group := &models.UserGroup{DisplayName: displayName}
+	if err := s.userGroupRepository.Create(ctx, group, userIDs); err != nil {
+		s.logMgr.Error("创建用户组失败", "error", err, "display_name", displayName)
+		return apperrors.ErrRBACSystemBusy
+	}
+	if len(req.Roles) > 0 {
+		if err := s.rbacService.SetGroupRoles(ctx, group.ID, req.Roles); err != nil {
+			return err
+		}
+	}
+
+	s.logMgr.Info("用户组创建成功", "group_id", group.ID, "members", len(userIDs))
+	return nil
+}
+
+// UpdateGroup 更新用户组，未提交的成员与角色保持不变
+func (s *GroupService) UpdateGroup(ctx context.Context, id uint, req *dto.UpdateGroupRequest) error {
+	group, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]any{}
+	if req.DisplayName != nil {
+		displayName, err := s.checkDisplayName(ctx, *req.DisplayName, group.ID)
+		if err != nil {
+			return err
+		}
+		updates["display_name"] = displayName
+	}
+	var userIDs []uint
+	if req.Members != nil {
+		if userIDs, err = s.memberIDs(ctx, *req.Members); err != nil {
+			return err
+		}
+	}
+	if req.Roles != nil {
+		// 先校验角色，避免成员已更新而角色无效
+		if _, err := s.rbacService.roleIDs(ctx, *req.Roles); err != nil {
+			return err
+		}
+	}
+
+	if err := s.userGroupRepository.Update(ctx, group.ID, updates, userIDs); err != nil {
+		s.logMgr.Error("更新用户组失败", "error", err, "group_id", group.ID)
+		return apperrors.ErrRBACSystemBusy
+	}
+	if req.Roles != nil {
+		if err := s.rbacService.SetGroupRoles(ctx, group.ID, *req.Roles); err != nil {
+			return err
+		}
+	} else if req.Members != nil {
+		s.rbacService.InvalidateAll(ctx)
+	}
+
+	s.logMgr.Info("用户组更新成功", "group_id", group.ID)
+	return nil
+}
+
+// DeleteGroup 删除用户组，成员随之失去经由该用户组获得的角色
+func (s *GroupService) DeleteGroup(ctx context.Context, id uint) error {
+	group, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.userGroupRepository.Delete(ctx, group.ID); err != nil {
+		s.logMgr.Error("删除用户组失败", "error", err, "group_id", group.ID)
+		return apperrors.ErrRBACSystemBusy
+	}
+
+	s.rbacService.InvalidateAll(ctx)
+	s.logMgr.Info("用户组删除成功", "group_id", group.ID)
+	return nil
+}
+
+func (s *GroupService) get(ctx context.Context, id uint) (*models.UserGroup, error) {
+	group, err := s.userGroupRepository.Get(ctx, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrGroupNotFound
+		}
+		s.logMgr.Error("获取用户组失败", "error", err, "group_id", id)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	return group, nil
+}
+
+// checkDisplayName 用户组名称在未删除的用户组中唯一，与 SCIM 接口的规则一致
+func (s *GroupService) checkDisplayName(ctx context.Context, name string, exceptID uint) (string, error) {
+	displayName := strings.TrimSpace(name)
+	count, err := s.userGroupRepository.Count(ctx, map[string]any{"display_name = ?": displayName, "id <> ?": exceptID})
+	if err != nil {
+		s.logMgr.Error("查询用户组失败", "error", err, "display_name", displayName)
+		return "", apperrors.ErrRBACSystemBusy
+	}
+	if count > 0 {
+		return "", apperrors.ErrGroupExists
+	}
+	return displayName, nil
+}
+
+// memberIDs 校验成员均为已存在的用户，返回去重后的用户ID，没有成员时返回空切片
+func (s *GroupService) memberIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	userIDs := make([]uint, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) == 0 {
+		return userIDs, nil
+	}
+
+	users, err := s.userRepository.FindByIDs(ctx, userIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	if len(users) != len(userIDs) {
+		return nil, apperrors.ErrUserNotFound
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+)
+
+// ldapProfile 目录中用户条目映射后的信息
+type ldapProfile struct {
+	DN       string
+	Username string
+	Nickname string
+	Email    string
+	Subject  string
+	Role     string
+}
+
+// LDAPAuthenticator 通过 LDAP / Active Directory 校验密码的认证后端
+// 校验通过后按属性映射同步本地账号的昵称、邮箱与角色，目录是这些字段的唯一来源
+type LDAPAuthenticator struct {
+	userRepository *repositories.UserRepository
+	userService    *UserService
+	rbacService    *RBACService
+	logMgr         *logger.Manager
+	cfg            appconfig.LDAPConfig
+}
+
+// NewLDAPAuthenticator 创建 LDAP 认证后端
+func NewLDAPAuthenticator(userRepository *repositories.UserRepository, userService *UserService, rbacService *RBACService, logMgr *logger.Manager, cfg appconfig.LDAPConfig) *LDAPAuthenticator {
+	return &LDAPAuthenticator{userRepository: userRepository, userService: userService, rbacService: rbacService, logMgr: logMgr, cfg: cfg}
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return models.UserAuthSourceLDAP
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// 空密码在多数目录上是匿名绑定，会被当作成功
+	if password == "" {
+		return nil, apperrors.ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		a.logMgr.Error("连接 LDAP 服务器失败", "error", err)
+		return nil, apperrors.ErrAuthenticatorUnavailable
+	}
+	defer conn.Close()
+
+	if a.cfg.BindDN != "" {
+		if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+			a.logMgr.Error("LDAP 服务账号绑定失败", "error", err)
+			return nil, apperrors.ErrAuthenticatorUnavailable
+		}
+	}
+
+	entry, err := a.search(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, apperrors.ErrInvalidCredentials
+		}
+		a.logMgr.Error("LDAP 用户绑定失败", "error", err, "dn", entry.DN)
+		return nil, apperrors.ErrAuthenticatorUnavailable
+	}
+
+	profile := a.profile(entry, username)
+	return a.syncUser(ctx, profile)
+}
+
+// dial 建立连接，按配置升级为 TLS
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(a.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(a.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.cfg.Timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(a.cfg.Timeout)
+
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// search 按用户名搜索唯一的用户条目
+func (a *LDAPAuthenticator) search(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	attributes := []string{"dn"}
+	for _, attribute := range []string{a.cfg.Attributes.Username, a.cfg.Attributes.Nickname, a.cfg.Attributes.Email, a.cfg.Attributes.Subject, a.cfg.GroupAttribute} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	// 最多取两条即可判断是否唯一
+	request := ldap.NewSearchRequest(
+		a.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.cfg.Timeout.Seconds()), false,
+		fmt.Sprintf(a.cfg.UserFilter, ldap.EscapeFilter(username)), attributes, nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		a.logMgr.Error("LDAP 搜索用户失败", "error", err)
+		return nil, apperrors.ErrAuthenticatorUnavailable
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, apperrors.ErrAuthenticatorUserNotFound
+	}
+	if len(result.Entries) > 1 || err != nil {
+		// 过滤器匹配到多个条目时无法确定是谁，按密码错误处理
+		a.logMgr.Warn("LDAP 用户名匹配到多个条目", "username", username)
+		return nil, apperrors.ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// profile 按属性映射提取用户信息，并按所属组确定角色
+func (a *LDAPAuthenticator) profile(entry *ldap.Entry, username string) *ldapProfile {
+	profile := &ldapProfile{DN: entry.DN, Username: username, Role: a.cfg.DefaultRole}
+	if a.cfg.Attributes.Username != "" {
+		if value := entry.GetAttributeValue(a.cfg.Attributes.Username); value != "" {
+			profile.Username = value
+		}
+	}
+	if a.cfg.Attributes.Nickname != "" {
+		profile.Nickname = truncateRunes(entry.GetAttributeValue(a.cfg.Attributes.Nickname), 20)
+	}
+	if a.cfg.Attributes.Email != "" {
+		profile.Email = entry.GetAttributeValue(a.cfg.Attributes.Email)
+	}
+	if a.cfg.Attributes.Subject != "" {
+		profile.Subject = ldapSubject(entry.GetRawAttributeValue(a.cfg.Attributes.Subject))
+	}
+
+	if a.cfg.GroupAttribute != "" {
+		groups := entry.GetAttributeValues(a.cfg.GroupAttribute)
+	mapping:
+		for _, mapping := range a.cfg.RoleMapping {
+			for _, group := range groups {
+				if strings.EqualFold(group, mapping.Group) {
+					profile.Role = mapping.Role
+					break mapping
+				}
+			}
+		}
+	}
+	return profile
+}
+
+// syncUser 查找或创建目录用户对应的本地账号，并同步映射的字段
+func (a *LDAPAuthenticator) syncUser(ctx context.Context, profile *ldapProfile) (*models.User, error) {
+	user, err := a.userRepository.Get(ctx, map[string]any{"username": profile.Username})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.logMgr.Error("获取用户失败", "error", err)
+			return nil, apperrors.ErrUserSystemBusy
+		}
+		if !a.cfg.AllowSignup {
+			return nil, apperrors.ErrDirectorySignupDisabled
+		}
+		return a.provision(ctx, profile)
+	}
+
+	// 同名的本地账号不能被目录账号接管，须由管理员处理
+	if user.AuthSource != models.UserAuthSourceLDAP {
+		a.logMgr.Warn("目录账号与本地账号同名", "username", profile.Username, "user_id", user.ID)
+		return nil, apperrors.ErrDirectoryAccountConflict
+	}
+
+	updates := make(map[string]any)
+	if profile.Nickname != "" && profile.Nickname != user.Nickname {
+		updates["nickname"] = profile.Nickname
+		user.Nickname = profile.Nickname
+	}
+	if email := a.availableEmail(ctx, profile.Email, user.ID); email != "" && (user.Email == nil || *user.Email != email) {
+		now := time.Now()
+		updates["email"] = email
+		updates["email_verified_at"] = now
+		user.Email = &email
+		user.EmailVerifiedAt = &now
+	}
+	a.syncRole(ctx, user.ID, profile.Role)
+	if len(updates) == 0 {
+		return user, nil
+	}
+
+	if err := a.userRepository.Update(ctx, user.ID, updates); err != nil {
+		// 同步失败不影响本次登录，下次登录时重试
+		a.logMgr.Warn("同步目录用户信息失败", "error", err, "user_id", user.ID)
+		return user, nil
+	}
+	a.userService.InvalidateUserCache(ctx, user)
+	return user, nil
+}
+
+// syncRole 将直接分配给目录用户的角色同步为按目录组映射的角色，同步失败不影响本次登录，下次登录时重试
+func (a *LDAPAuthenticator) syncRole(ctx context.Context, userID uint, role string) {
+	if role == "" {
+		return
+	}
+	current, err := a.rbacService.GetUserRoles(ctx, userID)
+	if err != nil {
+		return
+	}
+	if len(current.Roles) == 1 && current.Roles[0] == role {
+		return
+	}
+	if err := a.rbacService.SetUserRoles(ctx, userID, []string{role}); err != nil {
+		a.logMgr.Warn("同步目录用户角色失败", "error", err, "user_id", userID, "role", role)
+	}
+}
+
+// provision 为首次登录的目录用户创建本地账号
+func (a *LDAPAuthenticator) provision(ctx context.Context, profile *ldapProfile) (*models.User, error) {
+	user := &models.User{
+		Username: profile.Username,
+		Nickname: profile.Nickname,
+		Subject:  profile.Subject,
+	}
+	if user.Nickname == "" {
+		user.Nickname = truncateRunes(profile.Username, 20)
+	}
+	// 目录中的邮箱由企业维护，视为已验证
+	if email := a.availableEmail(ctx, profile.Email, 0); email != "" {
+		now := time.Now()
+		user.Email = &email
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := a.userService.CreateDirectoryUser(ctx, user, profile.Role); err != nil {
+		return nil, err
+	}
+	a.logMgr.Info("目录用户创建本地账号", "event", "user.ldap_signup", "user_id", user.ID, "dn", profile.DN)
+	return user, nil
+}
+
+// availableEmail 返回可以写入本地账号的邮箱，已被其他账号使用时返回空
+func (a *LDAPAuthenticator) availableEmail(ctx context.Context, email string, userID uint) string {
+	if email == "" {
+		return ""
+	}
+	email = normalizeEmail(email)
+	existing, err := a.userRepository.GetWithDeleted(ctx, map[string]any{"email": email})
+	if err == nil && existing.ID != userID {
+		a.logMgr.Warn("目录用户的邮箱已被其他账号使用", "email", email, "user_id", existing.ID)
+		return ""
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.logMgr.Warn("获取用户失败", "error", err)
+		return ""
+	}
+	return email
+}
+
+// ldapSubject 将标识属性转为字符串，objectGUID 等二进制属性使用十六进制
+func ldapSubject(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	if utf8.Valid(raw) && strings.IndexFunc(string(raw), func(r rune) bool { return !unicode.IsPrint(r) }) < 0 {
+		return string(raw)
+	}
+	return hex.EncodeToString(raw)
+}
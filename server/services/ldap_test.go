@@ -0,0 +1,564 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3086953492/gokit/cache"
+	"github.com/3086953492/gokit/security/password"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+)
+
+const (
+	testLDAPBaseDN       = "dc=example,dc=com"
+	testLDAPServiceDN    = "cn=svc,dc=example,dc=com"
+	testLDAPServicePass  = "svc-secret"
+	testLDAPAdminsGroup  = "cn=admins,ou=groups,dc=example,dc=com"
+	testLDAPStaffGroup   = "cn=staff,ou=groups,dc=example,dc=com"
+	testLDAPUserPassword = "directory-pass"
+)
+
+// ldapStubEntry 测试目录中的条目，属性名不区分大小写
+type ldapStubEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// ldapStub 进程内的 LDAP 服务器，只实现简单绑定与搜索
+// 过滤器支持与、或、非、等值与存在判断，足以覆盖认证后端使用的过滤器
+type ldapStub struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	entries []*ldapStubEntry
+	binds   []string // 收到的绑定请求的 DN，按顺序记录
+	filters []string // 收到的搜索过滤器，按 RFC 4515 格式记录
+}
+
+func newLDAPStub(t *testing.T, entries ...*ldapStubEntry) *ldapStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &ldapStub{listener: listener, entries: entries}
+	go stub.serve()
+	t.Cleanup(func() { listener.Close() })
+	return stub
+}
+
+func (s *ldapStub) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStub) recordedBinds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.binds)
+}
+
+func (s *ldapStub) recordedFilters() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.filters)
+}
+
+// update 修改目录中的条目，模拟管理员在目录中变更用户
+func (s *ldapStub) update(dn string, fn func(entry *ldapStubEntry)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if entry.dn == dn {
+			fn(entry)
+		}
+	}
+}
+
+func (s *ldapStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ldapStub) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = []*ber.Packet{s.bind(messageID, op)}
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(messageID, op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			continue
+		}
+		for _, response := range responses {
+			if _, err := conn.Write(response.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *ldapStub) bind(messageID int64, op *ber.Packet) *ber.Packet {
+	dn := ber.DecodeString(op.Children[1].Data.Bytes())
+	pwd := op.Children[2].Data.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.binds = append(s.binds, dn)
+
+	if dn == testLDAPServiceDN && pwd == testLDAPServicePass {
+		return ldapStubResult(messageID, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+	}
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.dn, dn) && pwd != "" && entry.password == pwd {
+			return ldapStubResult(messageID, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+		}
+	}
+	return ldapStubResult(messageID, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+}
+
+func (s *ldapStub) search(messageID int64, op *ber.Packet) []*ber.Packet {
+	baseDN := ber.DecodeString(op.Children[0].Data.Bytes())
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	var requested []string
+	for _, attribute := range op.Children[7].Children {
+		requested = append(requested, ber.DecodeString(attribute.Data.Bytes()))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if decompiled, err := ldap.DecompileFilter(filter); err == nil {
+		s.filters = append(s.filters, decompiled)
+	}
+
+	var responses []*ber.Packet
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), ","+strings.ToLower(baseDN)) || !ldapStubMatch(entry, filter) {
+			continue
+		}
+		if sizeLimit > 0 && int64(len(responses)) == sizeLimit {
+			return append(responses, ldapStubResult(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded))
+		}
+		responses = append(responses, ldapStubSearchEntry(messageID, entry, requested))
+	}
+	return append(responses, ldapStubResult(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+// ldapStubMatch 判断条目是否满足过滤器，属性名与值均不区分大小写
+func ldapStubMatch(entry *ldapStubEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !ldapStubMatch(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if ldapStubMatch(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !ldapStubMatch(entry, filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		attribute := ber.DecodeString(filter.Children[0].Data.Bytes())
+		value := ber.DecodeString(filter.Children[1].Data.Bytes())
+		return slices.ContainsFunc(entry.values(attribute), func(v string) bool { return strings.EqualFold(v, value) })
+	case ldap.FilterPresent:
+		return len(entry.values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+func (e *ldapStubEntry) values(attribute string) []string {
+	for name, values := range e.attrs {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+// ldapStubMessage 将完整构造的操作包装为 LDAP 消息，子节点追加后不能再修改
+func ldapStubMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	envelope.AppendChild(op)
+	return envelope
+}
+
+func ldapStubResult(messageID int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return ldapStubMessage(messageID, op)
+}
+
+func ldapStubSearchEntry(messageID int64, entry *ldapStubEntry, requested []string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "objectName"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for _, name := range requested {
+		values := entry.values(name)
+		if len(values) == 0 {
+			continue
+		}
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return ldapStubMessage(messageID, op)
+}
+
+// newLDAPStubUser 创建目录用户条目
+func newLDAPStubUser(uid, displayName, mail string, groups ...string) *ldapStubEntry {
+	return &ldapStubEntry{
+		dn:       "uid=" + uid + ",ou=people," + testLDAPBaseDN,
+		password: testLDAPUserPassword,
+		attrs: map[string][]string{
+			"objectClass": {"inetOrgPerson"},
+			"uid":         {uid},
+			"displayName": {displayName},
+			"mail":        {mail},
+			"memberOf":    groups,
+			"objectGUID":  {string([]byte{0x01, 0x02, 0xfe, 0xff})},
+		},
+	}
+}
+
+type ldapTestEnv struct {
+	stub           *ldapStub
+	authenticator  *LDAPAuthenticator
+	local          *LocalAuthenticator
+	userRepository *repositories.UserRepository
+	rbacService    *RBACService
+	passwordMgr    *password.Manager
+}
+
+func newLDAPTestEnv(t *testing.T, entries ...*ldapStubEntry) *ldapTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.RolePermission{}, &models.UserRole{},
+		&models.UserGroup{}, &models.UserGroupMember{}, &models.UserGroupRole{}, &models.AuditEvent{}, &models.WebhookSubscription{}, &models.WebhookDelivery{})
+	redisMgr, _ := newTestRedis(t)
+	logMgr := newTestLogger(t)
+
+	cacheMgr, err := cache.NewManager(redisMgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordMgr, err := password.NewManager(password.WithCost(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roleRepository := repositories.NewRoleRepository(db)
+	for _, name := range []string{"user", "staff", "admin"} {
+		if err := roleRepository.Create(context.Background(), &models.Role{Name: name}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auditService := NewAuditService(repositories.NewAuditEventRepository(db), nil, logMgr)
+	rbacService := NewRBACService(roleRepository, repositories.NewPermissionRepository(db), repositories.NewUserGroupRepository(db), cacheMgr, logMgr, auditService)
+	webhookService := NewWebhookService(repositories.NewWebhookSubscriptionRepository(db), repositories.NewWebhookDeliveryRepository(db), nil, logMgr, auditService)
+	userRepository := repositories.NewUserRepository(db)
+	userService := NewUserService(userRepository, nil, redisMgr, cacheMgr, logMgr, passwordMgr, nil, nil, rbacService, auditService, webhookService, appconfig.ActivationModeAuto)
+
+	stub := newLDAPStub(t, entries...)
+	cfg := appconfig.LDAPConfig{
+		Enabled:      true,
+		URL:          stub.url(),
+		Timeout:      5 * time.Second,
+		BindDN:       testLDAPServiceDN,
+		BindPassword: testLDAPServicePass,
+		BaseDN:       testLDAPBaseDN,
+		UserFilter:   "(&(objectClass=inetOrgPerson)(uid=%s))",
+		Attributes: appconfig.LDAPAttributeMapping{
+			Username: "uid",
+			Nickname: "displayName",
+			Email:    "mail",
+			Subject:  "objectGUID",
+		},
+		GroupAttribute: "memberOf",
+		RoleMapping: []appconfig.LDAPRoleMapping{
+			{Group: testLDAPAdminsGroup, Role: "admin"},
+			{Group: testLDAPStaffGroup, Role: "staff"},
+		},
+		DefaultRole:   "user",
+		AllowSignup:   true,
+		LocalFallback: appconfig.LocalFallbackAlways,
+	}
+	return &ldapTestEnv{
+		stub:           stub,
+		authenticator:  NewLDAPAuthenticator(userRepository, userService, rbacService, logMgr, cfg),
+		local:          NewLocalAuthenticator(userRepository, passwordMgr, logMgr),
+		userRepository: userRepository,
+		rbacService:    rbacService,
+		passwordMgr:    passwordMgr,
+	}
+}
+
+// directRoles 返回直接分配给用户的角色
+func (env *ldapTestEnv) directRoles(t *testing.T, userID uint) []string {
+	t.Helper()
+	roles, err := env.rbacService.GetUserRoles(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return roles.Roles
+}
+
+// createLocalUser 创建使用本地密码的账号
+func (env *ldapTestEnv) createLocalUser(t *testing.T, username, pwd string) *models.User {
+	t.Helper()
+	hash, err := env.passwordMgr.Hash(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Subject: "subject-" + username, Username: username, Password: hash, Status: models.UserStatusActive, AuthSource: models.UserAuthSourceLocal}
+	if err := env.userRepository.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestLDAPAuthenticateProvisionsUser(t *testing.T) {
+	alice := newLDAPStubUser("alice", "Alice Liddell", "Alice@Example.com", "CN=Admins,OU=Groups,DC=Example,DC=Com", testLDAPStaffGroup)
+	env := newLDAPTestEnv(t, alice)
+
+	user, err := env.authenticator.Authenticate(context.Background(), "alice", testLDAPUserPassword)
+	if err != nil {
+		t.Fatalf("目录用户登录失败: %v", err)
+	}
+
+	// 先以服务账号绑定搜索，再以用户条目的 DN 绑定校验密码
+	if binds := env.stub.recordedBinds(); !slices.Equal(binds, []string{testLDAPServiceDN, alice.dn}) {
+		t.Fatalf("绑定顺序 = %v", binds)
+	}
+	if user.AuthSource != models.UserAuthSourceLDAP || user.Username != "alice" || user.Nickname != "Alice Liddell" {
+		t.Fatalf("本地账号 = %+v", user)
+	}
+	if user.Email == nil || *user.Email != "alice@example.com" || user.EmailVerifiedAt == nil {
+		t.Fatalf("邮箱 = %v，验证时间 = %v，期望已验证的 alice@example.com", user.Email, user.EmailVerifiedAt)
+	}
+	if user.Subject != "0102feff" {
+		t.Fatalf("二进制标识属性应转为十六进制，subject = %q", user.Subject)
+	}
+	// 组 DN 不区分大小写，同时属于多个组时取映射中的第一个
+	if roles := env.directRoles(t, user.ID); !slices.Equal(roles, []string{"admin"}) {
+		t.Fatalf("角色 = %v，期望 [admin]", roles)
+	}
+}
+
+func TestLDAPAuthenticateSyncsUser(t *testing.T) {
+	alice := newLDAPStubUser("alice", "Alice", "alice@example.com", testLDAPAdminsGroup)
+	env := newLDAPTestEnv(t, alice)
+	ctx := context.Background()
+
+	first, err := env.authenticator.Authenticate(ctx, "alice", testLDAPUserPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.stub.update(alice.dn, func(entry *ldapStubEntry) {
+		entry.attrs["displayName"] = []string{"Alice L."}
+		entry.attrs["mail"] = []string{"alice@example.org"}
+		entry.attrs["memberOf"] = []string{testLDAPStaffGroup}
+	})
+	second, err := env.authenticator.Authenticate(ctx, "alice", testLDAPUserPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID || second.Nickname != "Alice L." || second.Email == nil || *second.Email != "alice@example.org" {
+		t.Fatalf("同步后的账号 = %+v", second)
+	}
+	if roles := env.directRoles(t, second.ID); !slices.Equal(roles, []string{"staff"}) {
+		t.Fatalf("角色 = %v，期望 [staff]", roles)
+	}
+
+	// 不属于任何映射的组时使用默认角色
+	env.stub.update(alice.dn, func(entry *ldapStubEntry) { entry.attrs["memberOf"] = nil })
+	if _, err := env.authenticator.Authenticate(ctx, "alice", testLDAPUserPassword); err != nil {
+		t.Fatal(err)
+	}
+	if roles := env.directRoles(t, second.ID); !slices.Equal(roles, []string{"user"}) {
+		t.Fatalf("角色 = %v，期望 [user]", roles)
+	}
+}
+
+func TestLDAPAuthenticateRejected(t *testing.T) {
+	env := newLDAPTestEnv(t, newLDAPStubUser("alice", "Alice", "alice@example.com"))
+	ctx := context.Background()
+
+	if _, err := env.authenticator.Authenticate(ctx, "alice", "wrong"); !errors.Is(err, apperrors.ErrInvalidCredentials) {
+		t.Fatalf("密码错误 err = %v，期望 ErrInvalidCredentials", err)
+	}
+	if _, err := env.authenticator.Authenticate(ctx, "nobody", "whatever"); !errors.Is(err, apperrors.ErrAuthenticatorUserNotFound) {
+		t.Fatalf("用户不存在 err = %v，期望 ErrAuthenticatorUserNotFound", err)
+	}
+
+	// 空密码在目录上是匿名绑定，不能发到服务器
+	binds := len(env.stub.recordedBinds())
+	if _, err := env.authenticator.Authenticate(ctx, "alice", ""); !errors.Is(err, apperrors.ErrInvalidCredentials) {
+		t.Fatalf("空密码 err = %v，期望 ErrInvalidCredentials", err)
+	}
+	if len(env.stub.recordedBinds()) != binds {
+		t.Fatal("空密码不应连接目录")
+	}
+}
+
+func TestLDAPFilterEscaping(t *testing.T) {
+	env := newLDAPTestEnv(t, newLDAPStubUser("alice", "Alice", "alice@example.com"), newLDAPStubUser("bob", "Bob", "bob@example.com"))
+
+	for _, tc := range []struct {
+		username string
+		filter   string
+	}{
+		{"*", `(&(objectClass=inetOrgPerson)(uid=\2a))`},
+		{"alice)(uid=*", `(&(objectClass=inetOrgPerson)(uid=alice\29\28uid=\2a))`},
+		{`alice\`, `(&(objectClass=inetOrgPerson)(uid=alice\5c))`},
+	} {
+		t.Run(tc.username, func(t *testing.T) {
+			// 未转义时 * 会匹配所有条目，转义后只能按字面值匹配，找不到用户
+			if _, err := env.authenticator.Authenticate(context.Background(), tc.username, testLDAPUserPassword); !errors.Is(err, apperrors.ErrAuthenticatorUserNotFound) {
+				t.Fatalf("err = %v，期望 ErrAuthenticatorUserNotFound", err)
+			}
+			filters := env.stub.recordedFilters()
+			if got := filters[len(filters)-1]; got != tc.filter {
+				t.Fatalf("过滤器 = %s，期望 %s", got, tc.filter)
+			}
+		})
+	}
+}
+
+func TestLDAPAmbiguousUser(t *testing.T) {
+	first := newLDAPStubUser("alice", "Alice", "shared@example.com")
+	second := newLDAPStubUser("alice2", "Alice 2", "shared@example.com")
+	env := newLDAPTestEnv(t, first, second)
+	env.authenticator.cfg.UserFilter = "(mail=%s)"
+
+	if _, err := env.authenticator.Authenticate(context.Background(), "shared@example.com", testLDAPUserPassword); !errors.Is(err, apperrors.ErrInvalidCredentials) {
+		t.Fatalf("匹配到多个条目 err = %v，期望 ErrInvalidCredentials", err)
+	}
+}
+
+func TestLDAPLocalAccountConflict(t *testing.T) {
+	env := newLDAPTestEnv(t, newLDAPStubUser("carol", "Carol", "carol@example.com"))
+	env.createLocalUser(t, "carol", "Local-Pass-1")
+
+	if _, err := env.authenticator.Authenticate(context.Background(), "carol", testLDAPUserPassword); !errors.Is(err, apperrors.ErrDirectoryAccountConflict) {
+		t.Fatalf("与本地账号同名 err = %v，期望 ErrDirectoryAccountConflict", err)
+	}
+}
+
+func TestLDAPSignupDisabled(t *testing.T) {
+	env := newLDAPTestEnv(t, newLDAPStubUser("alice", "Alice", "alice@example.com"))
+	env.authenticator.cfg.AllowSignup = false
+
+	if _, err := env.authenticator.Authenticate(context.Background(), "alice", testLDAPUserPassword); !errors.Is(err, apperrors.ErrDirectorySignupDisabled) {
+		t.Fatalf("关闭自动创建账号 err = %v，期望 ErrDirectorySignupDisabled", err)
+	}
+}
+
+func TestLDAPServiceBindFailure(t *testing.T) {
+	env := newLDAPTestEnv(t, newLDAPStubUser("alice", "Alice", "alice@example.com"))
+	env.authenticator.cfg.BindPassword = "wrong"
+
+	if _, err := env.authenticator.Authenticate(context.Background(), "alice", testLDAPUserPassword); !errors.Is(err, apperrors.ErrAuthenticatorUnavailable) {
+		t.Fatalf("服务账号绑定失败 err = %v，期望 ErrAuthenticatorUnavailable", err)
+	}
+}
+
+func TestLDAPLocalFallback(t *testing.T) {
+	for _, tc := range []struct {
+		fallback string
+		ldapDown bool
+		want     error // nil 表示通过本地密码登录成功
+	}{
+		{appconfig.LocalFallbackAlways, false, nil},
+		{appconfig.LocalFallbackAlways, true, nil},
+		{appconfig.LocalFallbackUnavailable, false, apperrors.ErrInvalidCredentials},
+		{appconfig.LocalFallbackUnavailable, true, nil},
+		{appconfig.LocalFallbackNever, false, apperrors.ErrInvalidCredentials},
+		{appconfig.LocalFallbackNever, true, apperrors.ErrAuthenticatorUnavailable},
+	} {
+		name := tc.fallback + "/ldap-up"
+		if tc.ldapDown {
+			name = tc.fallback + "/ldap-down"
+		}
+		t.Run(name, func(t *testing.T) {
+			env := newLDAPTestEnv(t, newLDAPStubUser("alice", "Alice", "alice@example.com"))
+			local := env.createLocalUser(t, "bob", "Local-Pass-1")
+			if tc.ldapDown {
+				env.stub.listener.Close()
+			}
+			chain := NewAuthenticatorChain([]PasswordAuthenticator{env.authenticator, env.local}, tc.fallback, newTestLogger(t))
+
+			user, err := chain.Authenticate(context.Background(), "bob", "Local-Pass-1")
+			if tc.want != nil {
+				if !errors.Is(err, tc.want) {
+					t.Fatalf("err = %v，期望 %v", err, tc.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("本地密码登录失败: %v", err)
+			}
+			if user.ID != local.ID {
+				t.Fatalf("登录用户 = %d，期望 %d", user.ID, local.ID)
+			}
+		})
+	}
+
+	// 目录账号不能通过本地后端登录，目录不可用时也不能
+	t.Run("DirectoryAccountNotLocal", func(t *testing.T) {
+		env := newLDAPTestEnv(t, newLDAPStubUser("alice", "Alice", "alice@example.com"))
+		if _, err := env.authenticator.Authenticate(context.Background(), "alice", testLDAPUserPassword); err != nil {
+			t.Fatal(err)
+		}
+		env.stub.listener.Close()
+		chain := NewAuthenticatorChain([]PasswordAuthenticator{env.authenticator, env.local}, appconfig.LocalFallbackAlways, newTestLogger(t))
+		if _, err := chain.Authenticate(context.Background(), "alice", testLDAPUserPassword); !errors.Is(err, apperrors.ErrAuthenticatorUnavailable) {
+			t.Fatalf("err = %v，期望 ErrAuthenticatorUnavailable", err)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/3086953492/gokit/security/random"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const (
+	loginFailuresKeyPrefix = "login:failures:"
+	loginLockKeyPrefix     = "login:lock:"
+)
+
+// loginCheckScript 返回锁定剩余毫秒数、窗口内失败次数与最近一次失败时间（毫秒）
+// KEYS[1] 锁定键，KEYS[2] 失败记录（有序集合，分值为失败时间）
+// ARGV[1] 当前时间，ARGV[2] 窗口长度
+const loginCheckScript = `
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 then
+	return {ttl, 0, 0}
+end
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", tonumber(ARGV[1]) - tonumber(ARGV[2]))
+local n = redis.call("ZCARD", KEYS[2])
+local last = redis.call("ZRANGE", KEYS[2], -1, -1, "WITHSCORES")
+return {0, n, tonumber(last[2] or "0")}
+`
+
+// loginFailureScript 记录一次失败，达到上限时加锁并清空失败记录，返回失败次数与是否本次加锁
+// KEYS[1] 锁定键，KEYS[2] 失败记录
+// ARGV[1] 当前时间，ARGV[2] 窗口长度，ARGV[3] 失败上限，ARGV[4] 锁定时长，ARGV[5] 记录成员
+const loginFailureScript = `
+local now = tonumber(ARGV[1])
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", now - tonumber(ARGV[2]))
+redis.call("ZADD", KEYS[2], now, ARGV[5])
+redis.call("PEXPIRE", KEYS[2], ARGV[2])
+local n = redis.call("ZCARD", KEYS[2])
+if n >= tonumber(ARGV[3]) then
+	redis.call("SET", KEYS[1], "1", "PX", ARGV[4])
+	redis.call("DEL", KEYS[2])
+	return {n, 1}
+end
+return {n, 0}
+`
+
+// LoginProtectionService 登录防暴力破解
+// 按用户名与 IP 分别在滑动窗口内统计失败次数，失败较多时要求逐次加倍等待，达到上限后临时锁定；
+// 用户名不论是否存在都同样计数，锁定状态不会暴露账号是否存在
+type LoginProtectionService struct {
+	redisMgr       *redis.Manager
+	userRepository *repositories.UserRepository
+	tokenHasher    *utils.TokenHasher
+	logMgr         *logger.Manager
+	auditService   *AuditService
+	cfg            appconfig.LoginProtectionConfig
+}
+
+// NewLoginProtectionService 创建登录防护服务实例
+func NewLoginProtectionService(redisMgr *redis.Manager, userRepository *repositories.UserRepository, tokenHasher *utils.TokenHasher, logMgr *logger.Manager, auditService *AuditService, cfg appconfig.LoginProtectionConfig) *LoginProtectionService {
+	return &LoginProtectionService{redisMgr: redisMgr, userRepository: userRepository, tokenHasher: tokenHasher, logMgr: logMgr, auditService: auditService, cfg: cfg}
+}
+
+// Check 校验是否允许本次登录尝试，被锁定或须等待时返回 *apperrors.LoginThrottledError
+// Redis 不可用时放行，避免登录整体不可用
+func (s *LoginProtectionService) Check(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, scope := range s.scopes(ctx, username, ip) {
+		wait, err := s.check(ctx, scope, now)
+		if err != nil {
+			s.logMgr.Error("检查登录限制失败", "error", err)
+			return nil
+		}
+		retryAfter = max(retryAfter, wait)
+	}
+	if retryAfter > 0 {
+		return &apperrors.LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次登录失败，达到上限时锁定并记录审计事件
+func (s *LoginProtectionService) RecordFailure(ctx context.Context, username, ip string) {
+	now := time.Now()
+	member, err := random.URLSafe(8)
+	if err != nil {
+		member = strconv.FormatInt(now.UnixNano(), 10)
+	}
+	member = strconv.FormatInt(now.UnixMilli(), 10) + ":" + member
+
+	for _, scope := range s.scopes(ctx, username, ip) {
+		result, err := s.redisMgr.Eval(ctx, loginFailureScript, []string{loginLockKeyPrefix + scope.key, loginFailuresKeyPrefix + scope.key},
+			now.UnixMilli(), s.cfg.Window.Milliseconds(), scope.maxFailures, s.cfg.LockoutDuration.Milliseconds(), member)
+		if err != nil {
+			s.logMgr.Error("记录登录失败次数失败", "error", err)
+			continue
+		}
+		values, _ := result.([]any)
+		if len(values) == 2 && toInt64(values[1]) == 1 {
+			s.logMgr.Warn("登录失败次数过多，已临时锁定", "event", "auth.lockout", "scope", scope.name, "username", username, "ip", ip, "failures", toInt64(values[0]), "duration", s.cfg.LockoutDuration.String())
+			s.recordLockout(ctx, scope, username, ip, toInt64(values[0]))
+		}
+	}
+}
+
+// recordLockout 记录锁定审计事件，不查询用户名对应的账号，账号不存在时同样记录
+func (s *LoginProtectionService) recordLockout(ctx context.Context, scope loginScope, username, ip string, failures int64) {
+	targetID := username
+	if scope.name == "ip" {
+		targetID = ip
+	}
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Type:       models.AuditUserLockout,
+		Username:   username,
+		TargetType: scope.name,
+		TargetID:   truncate(targetID, 64),
+		Details:    AuditDetails(map[string]any{"failures": failures, "duration": s.cfg.LockoutDuration.String()}),
+	}, nil)
+}
+
+// RecordSuccess 登录成功后清空该用户名的失败记录，IP 的记录保留
+func (s *LoginProtectionService) RecordSuccess(ctx context.Context, username string) {
+	if _, err := s.redisMgr.Del(ctx, loginFailuresKeyPrefix+s.usernameKey(ctx, username)); err != nil {
+		s.logMgr.Warn("清除登录失败记录失败", "error", err)
+	}
+}
+
+// GetLockout 获取用户的登录锁定状态
+func (s *LoginProtectionService) GetLockout(ctx context.Context, userID uint) (*dto.LoginLockoutResponse, error) {
+	username, err := s.getUsername(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.usernameKey(ctx, username)
+	result, err := s.redisMgr.Eval(ctx, loginCheckScript, []string{loginLockKeyPrefix + key, loginFailuresKeyPrefix + key}, time.Now().UnixMilli(), s.cfg.Window.Milliseconds())
+	if err != nil {
+		s.logMgr.Error("获取登录锁定状态失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	values, _ := result.([]any)
+	if len(values) != 3 {
+		return nil, apperrors.ErrUserSystemBusy
+	}
+
+	lockTTL := time.Duration(toInt64(values[0])) * time.Millisecond
+	lockout := &dto.LoginLockoutResponse{Locked: lockTTL > 0, Failures: toInt64(values[1])}
+	if lockout.Locked {
+		lockedUntil := time.Now().Add(lockTTL)
+		lockout.LockedUntil = &lockedUntil
+	}
+	return lockout, nil
+}
+
+// Unlock 管理员解除用户的登录锁定并清空失败记录，operatorID 为执行操作的管理员
+// 只解除用户名维度的锁定，同一 IP 的锁定不受影响
+func (s *LoginProtectionService) Unlock(ctx context.Context, userID, operatorID uint) (err error) {
+	defer func() { s.auditService.RecordUser(ctx, models.AuditUserUnlock, userID, err) }()
+
+	username, err := s.getUsername(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	key := s.usernameKey(ctx, username)
+	if _, err := s.redisMgr.Del(ctx, loginLockKeyPrefix+key, loginFailuresKeyPrefix+key); err != nil {
+		s.logMgr.Error("解除登录锁定失败", "error", err, "user_id", userID)
+		return apperrors.ErrLockoutFailed
+	}
+
+	s.logMgr.Info("解除登录锁定", "event", "auth.unlock", "user_id", userID, "operator_id", operatorID)
+	return nil
+}
+
+// loginScope 一个统计维度
+type loginScope struct {
+	name        string
+	key         string
+	maxFailures int
+}
+
+// scopes 用户名与 IP 两个统计维度，用户名只以摘要作为 Redis 键
+// 两个维度都按租户区分，不同租户的同名用户互不影响，一个租户的管理员解除锁定也不会波及其他租户
+func (s *LoginProtectionService) scopes(ctx context.Context, username, ip string) []loginScope {
+	return []loginScope{
+		{name: "username", key: s.usernameKey(ctx, username), maxFailures: s.cfg.UsernameMaxFailures},
+		{name: "ip", key: utils.TenantCacheKey(ctx, "ip:"+ip), maxFailures: s.cfg.IPMaxFailures},
+	}
+}
+
+// check 返回该维度须等待的时长，0 表示放行
+func (s *LoginProtectionService) check(ctx context.Context, scope loginScope, now time.Time) (time.Duration, error) {
+	result, err := s.redisMgr.Eval(ctx, loginCheckScript, []string{loginLockKeyPrefix + scope.key, loginFailuresKeyPrefix + scope.key}, now.UnixMilli(), s.cfg.Window.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	values, _ := result.([]any)
+	if len(values) != 3 {
+		return 0, errors.New("登录限制脚本返回值格式错误")
+	}
+
+	if lockTTL := toInt64(values[0]); lockTTL > 0 {
+		return time.Duration(lockTTL) * time.Millisecond, nil
+	}
+
+	failures := int(toInt64(values[1]))
+	if failures < s.cfg.DelayAfter {
+		return 0, nil
+	}
+	// 超过 DelayAfter 后每多失败一次等待时间翻倍
+	delay := s.cfg.BaseDelay << min(failures-s.cfg.DelayAfter, 16)
+	delay = min(delay, s.cfg.MaxDelay)
+	lastFailure := time.UnixMilli(toInt64(values[2]))
+	return max(time.Until(lastFailure.Add(delay)), 0), nil
+}
+
+func (s *LoginProtectionService) usernameKey(ctx context.Context, username string) string {
+	// 数据库用户名比较不区分大小写，统计时同样归一化，避免改变大小写绕过限制
+	return utils.TenantCacheKey(ctx, "username:"+s.tokenHasher.Hash(strings.ToLower(strings.TrimSpace(username))))
+}
+
+func (s *LoginProtectionService) getUsername(ctx context.Context, userID uint) (string, error) {
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", apperrors.ErrUserNotFound
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return "", apperrors.ErrUserSystemBusy
+	}
+	return user.Username, nil
+}
+
+// toInt64 转换 Lua 脚本返回的整数
+func toInt64(value any) int64 {
+	n, _ := value.(int64)
+	return n
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+type loginProtectionTestEnv struct {
+	service     *LoginProtectionService
+	server      *miniredis.Miniredis
+	auditEvents *repositories.AuditEventRepository
+	user        *models.User
+	cfg         appconfig.LoginProtectionConfig
+}
+
+func newLoginProtectionTestEnv(t *testing.T, cfg appconfig.LoginProtectionConfig) *loginProtectionTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.AuditEvent{})
+	redisMgr, server := newTestRedis(t)
+	logMgr := newTestLogger(t)
+
+	userRepository := repositories.NewUserRepository(db)
+	user := &models.User{Subject: "subject-alice", Username: "alice", Nickname: "Alice", Password: "-", Status: models.UserStatusActive, AuthSource: models.UserAuthSourceLocal}
+	if err := userRepository.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	auditEventRepository := repositories.NewAuditEventRepository(db)
+	auditService := NewAuditService(auditEventRepository, nil, logMgr)
+	service := NewLoginProtectionService(redisMgr, userRepository, newTestTokenHasher(t), logMgr, auditService, cfg)
+	return &loginProtectionTestEnv{service: service, server: server, auditEvents: auditEventRepository, user: user, cfg: cfg}
+}
+
+// loginProtectionTestConfig 用户名 3 次、IP 5 次失败后锁定，不要求逐次等待
+func loginProtectionTestConfig() appconfig.LoginProtectionConfig {
+	return appconfig.LoginProtectionConfig{
+		Window:              15 * time.Minute,
+		UsernameMaxFailures: 3,
+		IPMaxFailures:       5,
+		LockoutDuration:     10 * time.Minute,
+		DelayAfter:          100,
+		BaseDelay:           time.Second,
+		MaxDelay:            time.Minute,
+	}
+}
+
+// expireFailures 把失败记录的时间全部往前拨 d，模拟时间流逝
+func (e *loginProtectionTestEnv) expireFailures(t *testing.T, key string, d time.Duration) {
+	t.Helper()
+	key = loginFailuresKeyPrefix + key
+	if !e.server.Exists(key) {
+		return
+	}
+	members, err := e.server.ZMembers(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range members {
+		score, err := e.server.ZScore(key, member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.server.ZAdd(key, score-float64(d.Milliseconds()), member); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertLoginThrottled(t *testing.T, err error, maxRetryAfter time.Duration) {
+	t.Helper()
+	var throttled *apperrors.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Check = %v，期望 LoginThrottledError", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > maxRetryAfter {
+		t.Fatalf("RetryAfter = %v，期望在 (0, %v] 之间", throttled.RetryAfter, maxRetryAfter)
+	}
+}
+
+func TestLoginProtectionUsernameLockout(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+		if err := env.service.Check(ctx, "alice", "192.0.2.1"); err != nil {
+			t.Fatalf("第 %d 次失败后 Check = %v，未达到上限应放行", i+1, err)
+		}
+	}
+
+	// 第 3 次失败达到上限，换 IP、改变大小写都无法绕过用户名锁定
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	assertLoginThrottled(t, env.service.Check(ctx, "alice", "192.0.2.1"), env.cfg.LockoutDuration)
+	assertLoginThrottled(t, env.service.Check(ctx, " ALICE ", "192.0.2.9"), env.cfg.LockoutDuration)
+	if err := env.service.Check(ctx, "bob", "192.0.2.9"); err != nil {
+		t.Fatalf("其他用户名 Check = %v，期望放行", err)
+	}
+
+	lockout, err := env.service.GetLockout(ctx, env.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lockout.Locked || lockout.LockedUntil == nil {
+		t.Fatalf("锁定状态 = %+v，期望已锁定", lockout)
+	}
+
+	// 锁定记录审计事件
+	events, total, err := env.auditEvents.List(ctx, 1, 10, map[string]any{"type": models.AuditUserLockout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || events[0].TargetType != "username" || events[0].Username != "alice" {
+		t.Fatalf("锁定审计事件 = %+v，期望一条用户名维度的事件", events)
+	}
+
+	// 锁定到期后恢复，失败记录已在加锁时清空
+	env.server.FastForward(env.cfg.LockoutDuration)
+	if err := env.service.Check(ctx, "alice", "192.0.2.1"); err != nil {
+		t.Fatalf("锁定到期后 Check = %v，期望放行", err)
+	}
+}
+
+func TestLoginProtectionUnknownUsername(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	ctx := context.Background()
+
+	// 不存在的用户名同样计数与锁定，返回的错误与存在的账号相同
+	for i := 0; i < 3; i++ {
+		env.service.RecordFailure(ctx, "nobody", "192.0.2.1")
+		env.service.RecordFailure(ctx, "alice", "192.0.2.2")
+	}
+	unknownErr := env.service.Check(ctx, "nobody", "192.0.2.3")
+	knownErr := env.service.Check(ctx, "alice", "192.0.2.3")
+	assertLoginThrottled(t, unknownErr, env.cfg.LockoutDuration)
+	assertLoginThrottled(t, knownErr, env.cfg.LockoutDuration)
+	if unknownErr.Error() != knownErr.Error() {
+		t.Fatalf("锁定错误 %q 与 %q 不同，会暴露账号是否存在", unknownErr, knownErr)
+	}
+}
+
+func TestLoginProtectionIPLockout(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	ctx := context.Background()
+
+	// 同一 IP 尝试不同用户名，用户名维度都未达到上限，IP 维度达到上限后锁定
+	for _, username := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		env.service.RecordFailure(ctx, username, "192.0.2.1")
+	}
+	assertLoginThrottled(t, env.service.Check(ctx, "alice", "192.0.2.1"), env.cfg.LockoutDuration)
+	if err := env.service.Check(ctx, "alice", "192.0.2.2"); err != nil {
+		t.Fatalf("其他 IP Check = %v，期望放行", err)
+	}
+}
+
+func TestLoginProtectionWindowExpiry(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	ctx := context.Background()
+	key := env.service.usernameKey(ctx, "alice")
+
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+
+	// 早先的失败移出窗口后不再计数，再失败一次不会锁定
+	env.expireFailures(t, key, env.cfg.Window+time.Second)
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	if err := env.service.Check(ctx, "alice", "192.0.2.1"); err != nil {
+		t.Fatalf("窗口外的失败仍被计数: %v", err)
+	}
+	lockout, err := env.service.GetLockout(ctx, env.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockout.Locked || lockout.Failures != 1 {
+		t.Fatalf("锁定状态 = %+v，期望未锁定且窗口内只有 1 次失败", lockout)
+	}
+
+	// 失败记录的过期时间与窗口一致
+	if ttl := env.server.TTL(loginFailuresKeyPrefix + key); ttl <= 0 || ttl > env.cfg.Window {
+		t.Fatalf("失败记录过期时间 = %v，期望不超过窗口 %v", ttl, env.cfg.Window)
+	}
+}
+
+func TestLoginProtectionProgressiveDelay(t *testing.T) {
+	cfg := loginProtectionTestConfig()
+	cfg.UsernameMaxFailures = 10
+	cfg.IPMaxFailures = 10
+	cfg.DelayAfter = 2
+	cfg.BaseDelay = time.Second
+	cfg.MaxDelay = 3 * time.Second
+	env := newLoginProtectionTestEnv(t, cfg)
+	ctx := context.Background()
+
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	if err := env.service.Check(ctx, "alice", "192.0.2.1"); err != nil {
+		t.Fatalf("未达到 DelayAfter 时 Check = %v，期望放行", err)
+	}
+
+	// 第 2 次失败后等待 1 秒，之后逐次翻倍且不超过 MaxDelay
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+		var throttled *apperrors.LoginThrottledError
+		if err := env.service.Check(ctx, "alice", "192.0.2.1"); !errors.As(err, &throttled) {
+			t.Fatalf("Check = %v，期望要求等待", err)
+		}
+		if throttled.RetryAfter > want || throttled.RetryAfter < want-500*time.Millisecond {
+			t.Fatalf("RetryAfter = %v，期望约 %v", throttled.RetryAfter, want)
+		}
+	}
+}
+
+func TestLoginProtectionResetOnSuccess(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	}
+	env.service.RecordSuccess(ctx, "Alice")
+
+	// 用户名的失败记录清空，重新从 0 计数；IP 的记录保留
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	if err := env.service.Check(ctx, "alice", "192.0.2.1"); err != nil {
+		t.Fatalf("登录成功后失败次数未清零: %v", err)
+	}
+	ipKey := loginFailuresKeyPrefix + utils.TenantCacheKey(ctx, "ip:192.0.2.1")
+	if members, _ := env.server.ZMembers(ipKey); len(members) != 4 {
+		t.Fatalf("IP 失败记录 = %d 条，登录成功不应清空 IP 的记录", len(members))
+	}
+}
+
+func TestLoginProtectionUnlock(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		env.service.RecordFailure(ctx, "alice", "192.0.2.1")
+	}
+	assertLoginThrottled(t, env.service.Check(ctx, "alice", "192.0.2.2"), env.cfg.LockoutDuration)
+
+	if err := env.service.Unlock(ctx, env.user.ID, 99); err != nil {
+		t.Fatalf("解除锁定失败: %v", err)
+	}
+	if err := env.service.Check(ctx, "alice", "192.0.2.2"); err != nil {
+		t.Fatalf("解除锁定后 Check = %v，期望放行", err)
+	}
+	lockout, err := env.service.GetLockout(ctx, env.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockout.Locked || lockout.Failures != 0 {
+		t.Fatalf("解除锁定后状态 = %+v，期望未锁定且失败记录已清空", lockout)
+	}
+	if _, total, err := env.auditEvents.List(ctx, 1, 10, map[string]any{"type": models.AuditUserUnlock}); err != nil || total != 1 {
+		t.Fatalf("解除锁定审计事件 = %d 条（%v），期望 1 条", total, err)
+	}
+
+	if err := env.service.Unlock(ctx, 12345, 99); !errors.Is(err, apperrors.ErrUserNotFound) {
+		t.Fatalf("解除不存在用户的锁定 = %v，期望 ErrUserNotFound", err)
+	}
+}
+
+func TestLoginProtectionTenantIsolation(t *testing.T) {
+	env := newLoginProtectionTestEnv(t, loginProtectionTestConfig())
+	acmeCtx := utils.WithTenant(context.Background(), &models.Tenant{ID: 2, Name: "acme"})
+	globexCtx := utils.WithTenant(context.Background(), &models.Tenant{ID: 3, Name: "globex"})
+
+	for i := 0; i < 5; i++ {
+		env.service.RecordFailure(acmeCtx, "alice", "192.0.2.1")
+	}
+	assertLoginThrottled(t, env.service.Check(acmeCtx, "alice", "192.0.2.1"), env.cfg.LockoutDuration)
+	// 其他租户的同名用户与同一 IP 不受影响
+	if err := env.service.Check(globexCtx, "alice", "192.0.2.1"); err != nil {
+		t.Fatalf("其他租户 Check = %v，期望放行", err)
+	}
+}
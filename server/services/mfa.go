@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/security/password"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789" // 去掉易混淆的 i、l、o、0、1
+)
+
+// MFAService 两步验证服务，管理 TOTP 验证器绑定与恢复码
+type MFAService struct {
+	userRepository         *repositories.UserRepository
+	userTOTPRepository     *repositories.UserTOTPRepository
+	recoveryCodeRepository *repositories.RecoveryCodeRepository
+	userService            *UserService
+	secretBox              *utils.SecretBox
+	tokenHasher            *utils.TokenHasher
+	passwordMgr            *password.Manager
+	logMgr                 *logger.Manager
+	issuer                 string
+	tokenRevoker           UserTokenRevoker
+	auditService           *AuditService
+}
+
+// NewMFAService 创建两步验证服务实例，issuer 为验证器 App 中显示的发行方
+func NewMFAService(userRepository *repositories.UserRepository, userTOTPRepository *repositories.UserTOTPRepository, recoveryCodeRepository *repositories.RecoveryCodeRepository, userService *UserService, secretBox *utils.SecretBox, tokenHasher *utils.TokenHasher, passwordMgr *password.Manager, logMgr *logger.Manager, auditService *AuditService, issuer string) *MFAService {
+	return &MFAService{userRepository: userRepository, userTOTPRepository: userTOTPRepository, recoveryCodeRepository: recoveryCodeRepository, userService: userService, secretBox: secretBox, tokenHasher: tokenHasher, passwordMgr: passwordMgr, logMgr: logMgr, auditService: auditService, issuer: issuer}
+}
+
+// SetTokenRevoker 设置用户级令牌撤销实现，管理员重置两步验证时撤销该用户全部令牌
+func (s *MFAService) SetTokenRevoker(tokenRevoker UserTokenRevoker) {
+	s.tokenRevoker = tokenRevoker
+}
+
+// GetStatus 获取用户的两步验证状态
+func (s *MFAService) GetStatus(ctx context.Context, userID uint) (*dto.MFAStatusResponse, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &dto.MFAStatusResponse{Enabled: user.MFAEnabled, Required: s.userService.MFARequired(ctx, user)}
+	if user.MFAEnabled {
+		if status.RecoveryCodesRemaining, err = s.recoveryCodeRepository.CountUnused(ctx, userID); err != nil {
+			s.logMgr.Error("统计恢复码失败", "error", err, "user_id", userID)
+			return nil, apperrors.ErrUserSystemBusy
+		}
+	}
+	return status, nil
+}
+
+// SetupTOTP 发起验证器绑定，生成新密钥并覆盖尚未确认的绑定
+func (s *MFAService) SetupTOTP(ctx context.Context, userID uint) (*dto.TOTPSetupResponse, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		s.logMgr.Error("生成TOTP密钥失败", "error", err)
+		return nil, apperrors.ErrMFAUpdateFailed
+	}
+	sealed, err := s.secretBox.Seal(secret)
+	if err != nil {
+		s.logMgr.Error("加密TOTP密钥失败", "error", err)
+		return nil, apperrors.ErrMFAUpdateFailed
+	}
+	if err := s.userTOTPRepository.Replace(ctx, &models.UserTOTP{UserID: userID, Secret: sealed}); err != nil {
+		s.logMgr.Error("保存TOTP绑定失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrMFAUpdateFailed
+	}
+
+	return &dto.TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP 用验证码确认绑定并启用两步验证，返回首批恢复码（只返回这一次）
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, apperrors.ErrMFAAlreadyEnabled
+	}
+
+	totp, err := s.userTOTPRepository.Get(ctx, map[string]any{"user_id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrTOTPNotSetup
+		}
+		s.logMgr.Error("获取TOTP绑定失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if err := s.verifyTOTP(ctx, totp, code); err != nil {
+		return nil, err
+	}
+
+	codes, records, err := s.generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	txErr := s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.userTOTPRepository.UpdateWithTx(ctx, tx, totp.ID, map[string]any{"confirmed_at": time.Now()}); err != nil {
+			return err
+		}
+		if err := s.recoveryCodeRepository.ReplaceWithTx(ctx, tx, userID, records); err != nil {
+			return err
+		}
+		return s.userRepository.UpdateWithTx(ctx, tx, userID, map[string]any{"mfa_enabled": true})
+	})
+	if txErr != nil {
+		s.logMgr.Error("启用两步验证失败", "error", txErr, "user_id", userID)
+		return nil, apperrors.ErrMFAUpdateFailed
+	}
+
+	s.userService.InvalidateUserCache(ctx, user)
+	s.logMgr.Info("启用两步验证成功", "user_id", userID)
+	return codes, nil
+}
+
+// DisableTOTP 校验密码后关闭两步验证，管理员账号不允许关闭
+func (s *MFAService) DisableTOTP(ctx context.Context, userID uint, plainPassword string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled {
+		return apperrors.ErrMFANotEnabled
+	}
+	if s.userService.MFARequired(ctx, user) {
+		return apperrors.ErrMFARequiredForAdmin
+	}
+	if err := s.passwordMgr.Compare(user.Password, plainPassword); err != nil {
+		if errors.Is(err, password.ErrMismatch) {
+			return errors.New("密码错误")
+		}
+		s.logMgr.Error("密码验证失败", "error", err)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	if err := s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.clearWithTx(ctx, tx, userID)
+	}); err != nil {
+		s.logMgr.Error("关闭两步验证失败", "error", err, "user_id", userID)
+		return apperrors.ErrMFAUpdateFailed
+	}
+
+	s.userService.InvalidateUserCache(ctx, user)
+	s.logMgr.Info("关闭两步验证成功", "user_id", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，旧恢复码全部作废
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled {
+		return nil, apperrors.ErrMFANotEnabled
+	}
+	if err := s.Verify(ctx, user, code, ""); err != nil {
+		return nil, err
+	}
+
+	codes, records, err := s.generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.recoveryCodeRepository.ReplaceWithTx(ctx, tx, userID, records)
+	}); err != nil {
+		s.logMgr.Error("生成恢复码失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrMFAUpdateFailed
+	}
+	return codes, nil
+}
+
+// Verify 校验两步验证，code 为 TOTP 验证码，recoveryCode 为恢复码，二者取其一
+func (s *MFAService) Verify(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	if !user.MFAEnabled {
+		return apperrors.ErrMFANotEnabled
+	}
+
+	if code != "" {
+		totp, err := s.userTOTPRepository.Get(ctx, map[string]any{"user_id": user.ID})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.ErrMFACodeInvalid
+			}
+			s.logMgr.Error("获取TOTP绑定失败", "error", err, "user_id", user.ID)
+			return apperrors.ErrUserSystemBusy
+		}
+		if totp.ConfirmedAt == nil {
+			return apperrors.ErrMFACodeInvalid
+		}
+		return s.verifyTOTP(ctx, totp, code)
+	}
+
+	if recoveryCode != "" {
+		n, err := s.recoveryCodeRepository.Use(ctx, user.ID, s.tokenHasher.Hash(normalizeRecoveryCode(recoveryCode)), time.Now())
+		if err != nil {
+			s.logMgr.Error("使用恢复码失败", "error", err, "user_id", user.ID)
+			return apperrors.ErrUserSystemBusy
+		}
+		if n == 0 {
+			return apperrors.ErrMFACodeInvalid
+		}
+		s.logMgr.Info("使用恢复码完成两步验证", "user_id", user.ID)
+		return nil
+	}
+
+	return apperrors.ErrMFACodeInvalid
+}
+
+// ResetMFA 管理员重置用户的两步验证，同时撤销该用户全部令牌
+// 管理员账号被重置后，下次登录须重新绑定验证器
+func (s *MFAService) ResetMFA(ctx context.Context, userID uint) (err error) {
+	defer func() { s.auditService.RecordUser(ctx, models.AuditUserMFAReset, userID, err) }()
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenRevoker.RevokeUserTokens(ctx, userID, func(tx *gorm.DB) error {
+		return s.clearWithTx(ctx, tx, userID)
+	}); err != nil {
+		s.logMgr.Error("重置两步验证失败", "error", err, "user_id", userID)
+		return apperrors.ErrMFAUpdateFailed
+	}
+
+	s.userService.InvalidateUserCache(ctx, user)
+	s.logMgr.Info("重置两步验证成功", "user_id", userID)
+	return nil
+}
+
+// verifyTOTP 校验 TOTP 验证码，同一时间步的验证码只能使用一次
+func (s *MFAService) verifyTOTP(ctx context.Context, totp *models.UserTOTP, code string) error {
+	secret, err := s.secretBox.Open(totp.Secret)
+	if err != nil {
+		s.logMgr.Error("解密TOTP密钥失败", "error", err, "user_id", totp.UserID)
+		return apperrors.ErrUserSystemBusy
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return apperrors.ErrMFACodeInvalid
+	}
+	n, err := s.userTOTPRepository.AdvanceStep(ctx, totp.ID, step)
+	if err != nil {
+		s.logMgr.Error("更新TOTP时间步失败", "error", err, "user_id", totp.UserID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return apperrors.ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// clearWithTx 在事务中删除用户的验证器绑定与恢复码并关闭两步验证
+func (s *MFAService) clearWithTx(ctx context.Context, tx *gorm.DB, userID uint) error {
+	if err := s.userTOTPRepository.DeleteByUserWithTx(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := s.recoveryCodeRepository.DeleteByUserWithTx(ctx, tx, userID); err != nil {
+		return err
+	}
+	return s.userRepository.UpdateWithTx(ctx, tx, userID, map[string]any{"mfa_enabled": false})
+}
+
+// getUser 绕过缓存读取用户，两步验证状态必须以数据库为准
+func (s *MFAService) getUser(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	return user, nil
+}
+
+// generateRecoveryCodes 生成一组恢复码，返回展示给用户的原文与待保存的摘要记录
+func (s *MFAService) generateRecoveryCodes(userID uint) ([]string, []models.UserRecoveryCode, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.UserRecoveryCode, recoveryCodeCount)
+	buf := make([]byte, recoveryCodeLength)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			s.logMgr.Error("生成恢复码失败", "error", err)
+			return nil, nil, apperrors.ErrMFAUpdateFailed
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == recoveryCodeLength/2 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = sb.String()
+		records[i] = models.UserRecoveryCode{UserID: userID, CodeHash: s.tokenHasher.Hash(normalizeRecoveryCode(codes[i]))}
+	}
+	return codes, records, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格与分隔符，方便用户输入
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
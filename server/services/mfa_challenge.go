@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/3086953492/gokit/security/random"
+
+	"goauth/apperrors"
+	"goauth/utils"
+)
+
+const (
+	mfaChallengeKeyPrefix         = "mfa:challenge:"
+	mfaChallengeAttemptsKeyPrefix = "mfa:challenge:attempts:"
+)
+
+// mfaChallengeFailureScript 累加错误次数，首次累加时设置与挑战一致的过期时间
+const mfaChallengeFailureScript = `
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`
+
+// MFAChallenge 密码验证通过、等待两步验证的登录挑战
+type MFAChallenge struct {
+	UserID     uint `json:"user_id"`
+	Enrollment bool `json:"enrollment"` // 账号尚未绑定验证器，须先完成绑定
+}
+
+// MFAChallengeStore 登录挑战存储，挑战令牌只以摘要作为 Redis 键
+type MFAChallengeStore struct {
+	redisMgr    *redis.Manager
+	tokenHasher *utils.TokenHasher
+	logMgr      *logger.Manager
+	ttl         time.Duration
+	maxAttempts int
+}
+
+// NewMFAChallengeStore 创建登录挑战存储实例
+func NewMFAChallengeStore(redisMgr *redis.Manager, tokenHasher *utils.TokenHasher, logMgr *logger.Manager, ttl time.Duration, maxAttempts int) *MFAChallengeStore {
+	return &MFAChallengeStore{redisMgr: redisMgr, tokenHasher: tokenHasher, logMgr: logMgr, ttl: ttl, maxAttempts: maxAttempts}
+}
+
+// Create 创建登录挑战，返回挑战令牌与过期时间
+func (s *MFAChallengeStore) Create(ctx context.Context, challenge *MFAChallenge) (string, time.Time, error) {
+	token, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成两步验证令牌失败", "error", err)
+		return "", time.Time{}, apperrors.ErrMFAChallengeCreateFailed
+	}
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return "", time.Time{}, apperrors.ErrMFAChallengeCreateFailed
+	}
+	if err := s.redisMgr.SetBytes(ctx, mfaChallengeKeyPrefix+s.tokenHasher.Hash(token), data, s.ttl); err != nil {
+		s.logMgr.Error("保存两步验证挑战失败", "error", err, "user_id", challenge.UserID)
+		return "", time.Time{}, apperrors.ErrMFAChallengeCreateFailed
+	}
+	return token, time.Now().Add(s.ttl), nil
+}
+
+// Get 获取登录挑战，不存在或已过期时返回 ErrMFAChallengeInvalid
+func (s *MFAChallengeStore) Get(ctx context.Context, token string) (*MFAChallenge, error) {
+	data, err := s.redisMgr.GetBytes(ctx, mfaChallengeKeyPrefix+s.tokenHasher.Hash(token))
+	if err != nil {
+		s.logMgr.Error("读取两步验证挑战失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if data == nil {
+		return nil, apperrors.ErrMFAChallengeInvalid
+	}
+
+	var challenge MFAChallenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, apperrors.ErrMFAChallengeInvalid
+	}
+	return &challenge, nil
+}
+
+// RecordFailure 记录一次验证失败，达到上限后作废挑战并返回 ErrMFAChallengeExhausted
+func (s *MFAChallengeStore) RecordFailure(ctx context.Context, token string) error {
+	hash := s.tokenHasher.Hash(token)
+	result, err := s.redisMgr.Eval(ctx, mfaChallengeFailureScript, []string{mfaChallengeAttemptsKeyPrefix + hash}, s.ttl.Milliseconds())
+	if err != nil {
+		s.logMgr.Error("记录两步验证失败次数失败", "error", err)
+		return apperrors.ErrUserSystemBusy
+	}
+	if n, ok := result.(int64); ok && n >= int64(s.maxAttempts) {
+		s.delete(ctx, hash)
+		return apperrors.ErrMFAChallengeExhausted
+	}
+	return nil
+}
+
+// Consume 作废登录挑战，返回 ErrMFAChallengeInvalid 表示挑战已被并发请求使用
+func (s *MFAChallengeStore) Consume(ctx context.Context, token string) error {
+	n, err := s.redisMgr.Del(ctx, mfaChallengeKeyPrefix+s.tokenHasher.Hash(token))
+	if err != nil {
+		s.logMgr.Error("删除两步验证挑战失败", "error", err)
+		return apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return apperrors.ErrMFAChallengeInvalid
+	}
+	return nil
+}
+
+func (s *MFAChallengeStore) delete(ctx context.Context, hash string) {
+	if _, err := s.redisMgr.Del(ctx, mfaChallengeKeyPrefix+hash, mfaChallengeAttemptsKeyPrefix+hash); err != nil {
+		s.logMgr.Error("删除两步验证挑战失败", "error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package oauthservices
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/3086953492/gokit/jwt"
+	"github.com/3086953492/gokit/logger"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models/oauth"
+	"goauth/services"
+	"goauth/utils"
+)
+
+// OAuthAccessTokenService 访问令牌校验服务，供 Bearer 认证使用
+// stateful 模式按摘要查库判活（经查询缓存）；stateless 模式本地校验 JWT 签名、过期与受众，撤销通过 jti 黑名单判断
+type OAuthAccessTokenService struct {
+	accessTokenCache   *services.AccessTokenCache
+	userTokenEpoch     *services.UserTokenEpoch
+	oauthClientService *OAuthClientService
+	tenantService      *services.TenantService
+	denylist           *OAuthTokenDenylist
+	tokenHasher        *utils.TokenHasher
+	logMgr             *logger.Manager
+
+	mode     string
+	audience string
+}
+
+// NewOAuthAccessTokenService 创建访问令牌校验服务实例
+func NewOAuthAccessTokenService(
+	accessTokenCache *services.AccessTokenCache,
+	userTokenEpoch *services.UserTokenEpoch,
+	oauthClientService *OAuthClientService,
+	tenantService *services.TenantService,
+	denylist *OAuthTokenDenylist,
+	tokenHasher *utils.TokenHasher,
+	logMgr *logger.Manager,
+	mode string,
+	audience string,
+) *OAuthAccessTokenService {
+	return &OAuthAccessTokenService{
+		accessTokenCache:   accessTokenCache,
+		userTokenEpoch:     userTokenEpoch,
+		oauthClientService: oauthClientService,
+		tenantService:      tenantService,
+		denylist:           denylist,
+		tokenHasher:        tokenHasher,
+		logMgr:             logMgr,
+		mode:               mode,
+		audience:           audience,
+	}
+}
+
+// Verify 校验访问令牌，返回令牌对应的记录
+// stateless 模式下返回的记录由 JWT 声明还原，没有数据库 ID
+func (s *OAuthAccessTokenService) Verify(ctx context.Context, token string) (*oauthmodels.OAuthAccessToken, error) {
+	if s.mode == appconfig.BearerModeStateless {
+		return s.verifyStateless(ctx, token)
+	}
+	return s.verifyStateful(ctx, token)
+}
+
+// verifyStateful 按摘要查库校验访问令牌
+func (s *OAuthAccessTokenService) verifyStateful(ctx context.Context, token string) (*oauthmodels.OAuthAccessToken, error) {
+	accessToken, err := s.accessTokenCache.Get(ctx, s.tokenHasher.Hash(token))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("查询访问令牌失败", "error", err)
+		}
+		return nil, apperrors.ErrAccessTokenInvalid
+	}
+
+	if accessToken.Revoked {
+		return nil, apperrors.ErrAccessTokenRevoked
+	}
+
+	if accessToken.ExpiresAt.Before(time.Now()) {
+		return nil, apperrors.ErrAccessTokenExpired
+	}
+
+	return accessToken, nil
+}
+
+// verifyStateless 本地校验访问令牌，不访问数据库
+func (s *OAuthAccessTokenService) verifyStateless(ctx context.Context, token string) (*oauthmodels.OAuthAccessToken, error) {
+	// 先不验签读出 client_id，用于确定签名密钥；随后用该密钥完整验签
+	var unverified jwt.Claims
+	if _, _, err := gojwt.NewParser().ParseUnverified(token, &unverified); err != nil {
+		return nil, apperrors.ErrAccessTokenInvalid
+	}
+	clientID, _ := unverified.Extra["client_id"].(string)
+	clientIDUint, err := strconv.ParseUint(clientID, 10, 64)
+	if err != nil {
+		return nil, apperrors.ErrAccessTokenInvalid
+	}
+
+	client, err := s.oauthClientService.GetOAuthClientModel(ctx, uint(clientIDUint))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrAccessTokenInvalid
+		}
+		return nil, apperrors.ErrAccessTokenVerify
+	}
+	if client.Status == 0 {
+		return nil, apperrors.ErrOAuthClientDisabled
+	}
+
+	jwtManager, err := jwt.NewManager(jwt.WithAccessSecret(client.AccessTokenSecret), jwt.WithIssuer(s.tenantService.Issuer(ctx)))
+	if err != nil {
+		s.logMgr.Error("新建JWT管理器失败", "error", err)
+		return nil, apperrors.ErrAccessTokenVerify
+	}
+
+	claims, err := jwtManager.ParseAccessToken(token)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, apperrors.ErrAccessTokenExpired
+		}
+		return nil, apperrors.ErrAccessTokenInvalid
+	}
+
+	if aud, _ := claims.Extra["aud"].(string); aud != s.audience {
+		return nil, apperrors.ErrAccessTokenAudience
+	}
+	// 验签不检查发行方，其他租户签发的令牌在此拒绝
+	if claims.Issuer != s.tenantService.Issuer(ctx) {
+		return nil, apperrors.ErrAccessTokenInvalid
+	}
+
+	jti, _ := claims.Extra["jti"].(string)
+	if jti == "" {
+		return nil, apperrors.ErrAccessTokenInvalid
+	}
+	revoked, err := s.denylist.Contains(ctx, jti)
+	if err != nil {
+		// 黑名单不可用时拒绝放行，避免已撤销的令牌被接受
+		s.logMgr.Error("查询令牌黑名单失败", "error", err, "jti", jti)
+		return nil, apperrors.ErrAccessTokenVerify
+	}
+	if revoked {
+		return nil, apperrors.ErrAccessTokenRevoked
+	}
+
+	accessToken := &oauthmodels.OAuthAccessToken{
+		JTI:       jti,
+		TokenType: "Bearer",
+		ClientID:  clientID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	accessToken.Scope, _ = claims.Extra["scope"].(string)
+	// JSON 数字解码为 float64
+	if uid, ok := claims.Extra["uid"].(float64); ok && uid > 0 {
+		userID := uint(uid)
+		accessToken.UserID = &userID
+
+		// 用户被禁用、删除或修改密码后，此前签发的令牌失效
+		issuedAt, ok := utils.TokenIssuedAt(claims)
+		if !ok {
+			return nil, apperrors.ErrAccessTokenInvalid
+		}
+		valid, err := s.userTokenEpoch.Valid(ctx, userID, issuedAt)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrUserNotFound) {
+				return nil, apperrors.ErrAccessTokenRevoked
+			}
+			return nil, apperrors.ErrAccessTokenVerify
+		}
+		if !valid {
+			return nil, apperrors.ErrAccessTokenRevoked
+		}
+	}
+
+	return accessToken, nil
+}
@@ -9,21 +9,25 @@ import (
 	"github.com/3086953492/gokit/security/random"
 	"gorm.io/gorm"
 
+	"goauth/apperrors"
 	"goauth/models/oauth"
 	"goauth/repositories/oauth"
+	"goauth/utils"
 )
 
 type OAuthAuthorizeService struct {
 	oauthAuthorizationCodeRepository *oauthrepositories.OAuthAuthorizationCodeRepository
 	oauthClientService               *OAuthClientService
+	tokenHasher                      *utils.TokenHasher
 	logMgr                           *logger.Manager
 }
 
-func NewOAuthAuthorizeService(oauthAuthorizationCodeRepository *oauthrepositories.OAuthAuthorizationCodeRepository, oauthClientService *OAuthClientService, logMgr *logger.Manager) *OAuthAuthorizeService {
-	return &OAuthAuthorizeService{oauthAuthorizationCodeRepository: oauthAuthorizationCodeRepository, oauthClientService: oauthClientService, logMgr: logMgr}
+func NewOAuthAuthorizeService(oauthAuthorizationCodeRepository *oauthrepositories.OAuthAuthorizationCodeRepository, oauthClientService *OAuthClientService, tokenHasher *utils.TokenHasher, logMgr *logger.Manager) *OAuthAuthorizeService {
+	return &OAuthAuthorizeService{oauthAuthorizationCodeRepository: oauthAuthorizationCodeRepository, oauthClientService: oauthClientService, tokenHasher: tokenHasher, logMgr: logMgr}
 }
 
-func (s *OAuthAuthorizeService) GenerateAuthorizationCode(ctx context.Context, userID uint, clientID string, redirectURI string, scope string) (string, error) {
+// GenerateAuthorizationCode 为用户签发授权码，sessionID 为用户当前的登录会话，nonce 为 OpenID Connect 请求中的 nonce
+func (s *OAuthAuthorizeService) GenerateAuthorizationCode(ctx context.Context, userID uint, sessionID string, clientID string, redirectURI string, scope string, nonce string) (string, error) {
 
 	codeString, err := random.URLSafe(32)
 	if err != nil {
@@ -36,14 +40,18 @@ func (s *OAuthAuthorizeService) GenerateAuthorizationCode(ctx context.Context, u
 		s.logMgr.Error("获取OAuth客户端失败", "error", err)
 		return "", errors.New("系统繁忙，请稍后再试")
 	}
-	
+	if client.Status == 0 {
+		return "", apperrors.ErrOAuthClientDisabled
+	}
 
 	code := &oauthmodels.OAuthAuthorizationCode{
-		Code:        codeString,
+		CodeHash:    s.tokenHasher.Hash(codeString), // 只保存摘要，授权码原文仅返回给客户端
 		UserID:      userID,
 		ClientID:    clientID,
 		RedirectURI: redirectURI,
 		Scope:       scope,
+		SessionID:   sessionID,
+		Nonce:       nonce,
 		ExpiresAt:   time.Now().Add(time.Duration(client.AuthCodeExpire) * time.Second),
 	}
 	if err := s.oauthAuthorizationCodeRepository.Create(ctx, code); err != nil {
@@ -2,17 +2,24 @@ package oauthservices
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/3086953492/gokit/cache"
 	"github.com/3086953492/gokit/logger"
 	"gorm.io/gorm"
 
+	"goauth/apperrors"
 	"goauth/dto"
 	oauthdto "goauth/dto/oauth"
+	"goauth/models"
 	oauthmodels "goauth/models/oauth"
 	oauthrepositories "goauth/repositories/oauth"
+	"goauth/services"
+	"goauth/utils"
 )
 
 // 配置字段默认值（单位：秒）
@@ -25,14 +32,21 @@ const (
 type OAuthClientService struct {
 	oauthClientRepository *oauthrepositories.OAuthClientRepository
 	cacheMgr              *cache.Manager
+	oauthRevokeService    *OAuthRevokeService
 	logMgr                *logger.Manager
+	auditService          *services.AuditService
+	webhookService        *services.WebhookService
 }
 
-func NewOAuthClientService(oauthClientRepository *oauthrepositories.OAuthClientRepository, cacheMgr *cache.Manager, logMgr *logger.Manager) *OAuthClientService {
-	return &OAuthClientService{oauthClientRepository: oauthClientRepository, cacheMgr: cacheMgr, logMgr: logMgr}
+func NewOAuthClientService(oauthClientRepository *oauthrepositories.OAuthClientRepository, cacheMgr *cache.Manager, oauthRevokeService *OAuthRevokeService, logMgr *logger.Manager, auditService *services.AuditService, webhookService *services.WebhookService) *OAuthClientService {
+	return &OAuthClientService{oauthClientRepository: oauthClientRepository, cacheMgr: cacheMgr, oauthRevokeService: oauthRevokeService, logMgr: logMgr, auditService: auditService, webhookService: webhookService}
 }
 
 func (s *OAuthClientService) CreateOAuthClient(ctx context.Context, req *oauthdto.CreateOAuthClientRequest) error {
+	if err := validateLogoutURIs(req.PostLogoutRedirectURIs, req.BackchannelLogoutURI, req.FrontchannelLogoutURI); err != nil {
+		return err
+	}
+
 	// 配置字段应用默认值
 	authCodeExpire := DefaultAuthCodeExpire
 	if req.AuthCodeExpire != nil {
@@ -68,21 +82,37 @@ func (s *OAuthClientService) CreateOAuthClient(ctx context.Context, req *oauthdt
 		AuthCodeExpire:     authCodeExpire,
 		AccessTokenExpire:  accessTokenExpire,
 		RefreshTokenExpire: refreshTokenExpire,
+
+		// 登出字段
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:   req.BackchannelLogoutURI,
+		FrontchannelLogoutURI:  req.FrontchannelLogoutURI,
 	}
 
-	if err := s.oauthClientRepository.Create(ctx, client); err != nil {
+	// 创建客户端与写入 Webhook 事件在同一事务中完成
+	err := s.oauthClientRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.oauthClientRepository.CreateWithTx(ctx, tx, client); err != nil {
+			return err
+		}
+		data := dto.WebhookClientData{ClientID: strconv.FormatUint(uint64(client.ID), 10), Name: client.Name, Status: &client.Status}
+		return s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookClientCreated, data)
+	})
+	if err != nil {
 		s.logMgr.Error("创建OAuth客户端失败", "error", err, "client", client)
-		return errors.New("创建OAuth客户端失败")
+		err = errors.New("创建OAuth客户端失败")
+		s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditClientCreate, TargetType: "oauth_client"}, err)
+		return err
 	}
 	s.logMgr.Info("创建OAuth客户端成功", "client", client)
-	if err := s.cacheMgr.DeleteByPrefix(ctx, "list_oauth_clients:"); err != nil {
+	s.auditClient(ctx, models.AuditClientCreate, client.ID, nil)
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_oauth_clients:")); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
 	return nil
 }
 
 func (s *OAuthClientService) ListOAuthClients(ctx context.Context, page, pageSize int, conds map[string]any) (*dto.PaginationResponse[oauthdto.OAuthClientListResponse], error) {
-	oauthClientsPagination, err := cache.NewBuilder[dto.PaginationResponse[oauthdto.OAuthClientListResponse]](s.cacheMgr).KeyWithConds("list_oauth_clients", conds).TTL(10*time.Minute).GetOrSet(ctx, func() (*dto.PaginationResponse[oauthdto.OAuthClientListResponse], error) {
+	oauthClientsPagination, err := cache.NewBuilder[dto.PaginationResponse[oauthdto.OAuthClientListResponse]](s.cacheMgr).KeyWithConds(utils.TenantCacheKey(ctx, "list_oauth_clients"), conds).TTL(10*time.Minute).GetOrSet(ctx, func() (*dto.PaginationResponse[oauthdto.OAuthClientListResponse], error) {
 		oauthClients, total, err := s.oauthClientRepository.List(ctx, page, pageSize, conds)
 		if err != nil {
 			s.logMgr.Error("获取OAuth客户端列表失败", "error", err, "conds", conds)
@@ -112,7 +142,7 @@ func (s *OAuthClientService) ListOAuthClients(ctx context.Context, page, pageSiz
 }
 
 func (s *OAuthClientService) GetOAuthClient(ctx context.Context, conds map[string]any) (*oauthdto.OAuthClientDetailResponse, error) {
-	oauthClient, err := cache.NewBuilder[oauthdto.OAuthClientDetailResponse](s.cacheMgr).KeyWithConds("oauth_client", conds).TTL(10*time.Minute).GetOrSet(ctx, func() (*oauthdto.OAuthClientDetailResponse, error) {
+	oauthClient, err := cache.NewBuilder[oauthdto.OAuthClientDetailResponse](s.cacheMgr).KeyWithConds(utils.TenantCacheKey(ctx, "oauth_client"), conds).TTL(10*time.Minute).GetOrSet(ctx, func() (*oauthdto.OAuthClientDetailResponse, error) {
 		oauthClient, err := s.oauthClientRepository.Get(ctx, conds)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -138,6 +168,11 @@ func (s *OAuthClientService) GetOAuthClient(ctx context.Context, conds map[strin
 			RefreshTokenSecret: oauthClient.RefreshTokenSecret,
 			RefreshTokenExpire: oauthClient.RefreshTokenExpire,
 
+			// 登出字段
+			PostLogoutRedirectURIs: oauthClient.PostLogoutRedirectURIs,
+			BackchannelLogoutURI:   oauthClient.BackchannelLogoutURI,
+			FrontchannelLogoutURI:  oauthClient.FrontchannelLogoutURI,
+
 			CreatedAt: oauthClient.CreatedAt,
 			UpdatedAt: oauthClient.UpdatedAt,
 		}, nil
@@ -151,7 +186,9 @@ func (s *OAuthClientService) GetOAuthClient(ctx context.Context, conds map[strin
 	return oauthClient, nil
 }
 
-func (s *OAuthClientService) UpdateOAuthClient(ctx context.Context, id uint, req *oauthdto.UpdateOAuthClientRequest) error {
+func (s *OAuthClientService) UpdateOAuthClient(ctx context.Context, id uint, req *oauthdto.UpdateOAuthClientRequest) (err error) {
+	defer func() { s.auditClient(ctx, models.AuditClientUpdate, id, err) }()
+
 	updates := make(map[string]any)
 
 	// 基本字段
@@ -199,45 +236,135 @@ func (s *OAuthClientService) UpdateOAuthClient(ctx context.Context, id uint, req
 		updates["refresh_token_expire"] = *req.RefreshTokenExpire
 	}
 
-	if err := s.oauthClientRepository.Update(ctx, id, updates); err != nil {
+	// 登出字段
+	var postLogoutRedirectURIs []byte
+	var backchannelLogoutURI, frontchannelLogoutURI string
+	if req.PostLogoutRedirectURIs != nil {
+		postLogoutRedirectURIs = *req.PostLogoutRedirectURIs
+		updates["post_logout_redirect_uris"] = req.PostLogoutRedirectURIs
+	}
+	if req.BackchannelLogoutURI != nil {
+		backchannelLogoutURI = *req.BackchannelLogoutURI
+		updates["backchannel_logout_uri"] = *req.BackchannelLogoutURI
+	}
+	if req.FrontchannelLogoutURI != nil {
+		frontchannelLogoutURI = *req.FrontchannelLogoutURI
+		updates["frontchannel_logout_uri"] = *req.FrontchannelLogoutURI
+	}
+	if err := validateLogoutURIs(postLogoutRedirectURIs, backchannelLogoutURI, frontchannelLogoutURI); err != nil {
+		return err
+	}
+
+	// 更新客户端与写入 Webhook 事件在同一事务中完成，事件中只列出变更的字段名，不含密钥
+	err = s.oauthClientRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.oauthClientRepository.UpdateWithTx(ctx, tx, id, updates); err != nil {
+			return err
+		}
+		data := dto.WebhookClientData{ClientID: strconv.FormatUint(uint64(id), 10), Name: req.Name, Status: req.Status, Changed: services.WebhookChangedFields(updates)}
+		return s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookClientUpdated, data)
+	})
+	if err != nil {
 		s.logMgr.Error("更新OAuth客户端失败", "error", err, "id", id, "updates", updates)
 		return errors.New("更新OAuth客户端失败")
 	}
 
-	if err := s.cacheMgr.DeleteByPrefix(ctx, "list_oauth_clients:"); err != nil {
-		s.logMgr.Warn("删除缓存失败", "error", err)
-	}
-	if err := s.cacheMgr.DeleteByConds(ctx, "oauth_client", map[string]any{"id": id}); err != nil {
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_oauth_clients:")); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
-	if err := s.cacheMgr.DeleteByConds(ctx, "oauth_client_model", map[string]any{"id": id}); err != nil {
-		s.logMgr.Warn("删除缓存失败", "error", err)
+	s.invalidateClientCache(ctx, id)
+	// 禁用客户端时撤销其已签发的令牌与未使用的授权码
+	if req.Status != nil && *req.Status == 0 {
+		if err := s.oauthRevokeService.RevokeClientTokens(ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+			return errors.New("撤销客户端令牌失败，请重试")
+		}
 	}
 
 	return nil
 }
 
-func (s *OAuthClientService) DeleteOAuthClient(ctx context.Context, id uint) error {
-	if err := s.oauthClientRepository.Delete(ctx, id); err != nil {
+func (s *OAuthClientService) DeleteOAuthClient(ctx context.Context, id uint) (err error) {
+	defer func() { s.auditClient(ctx, models.AuditClientDelete, id, err) }()
+
+	// 先撤销客户端已签发的令牌与未使用的授权码，失败时不删除，便于重试
+	if err := s.oauthRevokeService.RevokeClientTokens(ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+		return errors.New("撤销客户端令牌失败，请重试")
+	}
+	err = s.oauthClientRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.oauthClientRepository.DeleteWithTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookClientDeleted, dto.WebhookClientData{ClientID: strconv.FormatUint(uint64(id), 10)})
+	})
+	if err != nil {
 		s.logMgr.Error("删除OAuth客户端失败", "error", err, "id", id)
 		return errors.New("删除OAuth客户端失败")
 	}
-	if err := s.cacheMgr.DeleteByPrefix(ctx, "list_oauth_clients:"); err != nil {
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_oauth_clients:")); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
-	if err := s.cacheMgr.DeleteByConds(ctx, "oauth_client", map[string]any{"id": id}); err != nil {
+	s.invalidateClientCache(ctx, id)
+	s.logMgr.Info("删除OAuth客户端成功", "id", id)
+	return nil
+}
+
+// invalidateClientCache 清除客户端的全部缓存条目
+// GetOAuthClient 按查询条件缓存，令牌、内省与撤销端点以 id+client_secret 查询的条目也须一并清除，
+// 否则客户端被禁用或删除后仍可在缓存有效期内通过认证
+func (s *OAuthClientService) invalidateClientCache(ctx context.Context, id uint) {
+	if err := s.cacheMgr.DeleteByConds(ctx, utils.TenantCacheKey(ctx, "oauth_client"), map[string]any{"id": id}); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
-	if err := s.cacheMgr.DeleteByConds(ctx, "oauth_client_model", map[string]any{"id": id}); err != nil {
+	if err := s.cacheMgr.DeleteByConds(ctx, utils.TenantCacheKey(ctx, "oauth_client_model"), map[string]any{"id": id}); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
-	s.logMgr.Info("删除OAuth客户端成功", "id", id)
+
+	// 条件按键名排序拼接，带密钥的键形如 oauth_client|client_secret=...&id=1
+	keys, err := s.cacheMgr.GetKeysByPrefix(ctx, cache.BuildKey(utils.TenantCacheKey(ctx, "oauth_client"), "client_secret="))
+	if err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err)
+		return
+	}
+	suffix := "&id=" + strconv.FormatUint(uint64(id), 10)
+	for _, key := range keys {
+		if !strings.HasSuffix(key, suffix) {
+			continue
+		}
+		if err := s.cacheMgr.Delete(ctx, key); err != nil {
+			s.logMgr.Warn("删除缓存失败", "error", err, "key", key)
+		}
+	}
+}
+
+// validateLogoutURIs 校验登出回调地址列表与前后端通道登出地址，空值表示未配置
+func validateLogoutURIs(postLogoutRedirectURIs []byte, backchannelLogoutURI, frontchannelLogoutURI string) error {
+	if len(postLogoutRedirectURIs) > 0 && string(postLogoutRedirectURIs) != "null" {
+		var uris []string
+		if err := json.Unmarshal(postLogoutRedirectURIs, &uris); err != nil {
+			return apperrors.ErrLogoutURIInvalid
+		}
+		for _, uri := range uris {
+			if !utils.IsLogoutURIValid(uri) {
+				return apperrors.ErrLogoutURIInvalid
+			}
+		}
+	}
+	for _, uri := range []string{backchannelLogoutURI, frontchannelLogoutURI} {
+		if uri != "" && !utils.IsLogoutURIValid(uri) {
+			return apperrors.ErrLogoutURIInvalid
+		}
+	}
 	return nil
 }
 
+// auditClient 记录客户端管理事件，客户端ID同时作为事件的对象与客户端
+func (s *OAuthClientService) auditClient(ctx context.Context, eventType string, id uint, err error) {
+	clientID := strconv.FormatUint(uint64(id), 10)
+	s.auditService.Record(ctx, &models.AuditEvent{Type: eventType, ClientID: clientID, TargetType: "oauth_client", TargetID: clientID}, err)
+}
+
 // 获取OAuth客户端在数据库中的完整记录，用于将密钥字段暴露给jwt管理器
 func (s *OAuthClientService) GetOAuthClientModel(ctx context.Context, id uint) (*oauthmodels.OAuthClient, error) {
-	oauthClient, err := cache.NewBuilder[oauthmodels.OAuthClient](s.cacheMgr).KeyWithConds("oauth_client_model", map[string]any{"id": id}).TTL(10*time.Minute).GetOrSet(ctx, func() (*oauthmodels.OAuthClient, error) {
+	oauthClient, err := cache.NewBuilder[oauthmodels.OAuthClient](s.cacheMgr).KeyWithConds(utils.TenantCacheKey(ctx, "oauth_client_model"), map[string]any{"id": id}).TTL(10*time.Minute).GetOrSet(ctx, func() (*oauthmodels.OAuthClient, error) {
 		oauthClient, err := s.oauthClientRepository.Get(ctx, map[string]any{"id": id})
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -5,22 +5,24 @@ import (
 	"time"
 
 	"goauth/dto/oauth"
-	"goauth/repositories/oauth"
 	"goauth/services"
+	"goauth/utils"
 )
 
 type OAuthIntrospectService struct {
-	oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository
-	userService                *services.UserService
+	accessTokenCache   *services.AccessTokenCache
+	userService        *services.UserService
+	oauthClientService *OAuthClientService
+	tokenHasher        *utils.TokenHasher
 }
 
-func NewOAuthIntrospectService(oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository, userService *services.UserService) *OAuthIntrospectService {
-	return &OAuthIntrospectService{oauthAccessTokenRepository: oauthAccessTokenRepository, userService: userService}
+func NewOAuthIntrospectService(accessTokenCache *services.AccessTokenCache, userService *services.UserService, oauthClientService *OAuthClientService, tokenHasher *utils.TokenHasher) *OAuthIntrospectService {
+	return &OAuthIntrospectService{accessTokenCache: accessTokenCache, userService: userService, oauthClientService: oauthClientService, tokenHasher: tokenHasher}
 }
 
 func (s *OAuthIntrospectService) IntrospectAccessToken(ctx context.Context, accessTokenString string) *oauthdto.IntrospectionResponse {
-	// 查询访问令牌
-	token, err := s.oauthAccessTokenRepository.Get(ctx, map[string]any{"access_token": accessTokenString})
+	// 按摘要查询访问令牌（经查询缓存）
+	token, err := s.accessTokenCache.Get(ctx, s.tokenHasher.Hash(accessTokenString))
 	if err != nil {
 		// 令牌不存在或查询错误，统一返回 active=false（RFC 7662 约定）
 		return &oauthdto.IntrospectionResponse{Active: false}
@@ -36,6 +38,12 @@ func (s *OAuthIntrospectService) IntrospectAccessToken(ctx context.Context, acce
 		return &oauthdto.IntrospectionResponse{Active: false}
 	}
 
+	// 签发令牌的客户端已禁用或不存在时视为无效
+	client, err := s.oauthClientService.GetOAuthClient(ctx, map[string]any{"id": token.ClientID})
+	if err != nil || client.Status == 0 {
+		return &oauthdto.IntrospectionResponse{Active: false}
+	}
+
 	// 构造有效令牌的响应
 	resp := &oauthdto.IntrospectionResponse{
 		Active:    true,
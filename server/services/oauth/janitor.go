@@ -0,0 +1,153 @@
+package oauthservices
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+
+	"goauth/appconfig"
+	"goauth/repositories"
+	"goauth/repositories/oauth"
+)
+
+// janitorLockKey 多副本部署时只允许一个实例执行清理
+const janitorLockKey = "oauth:janitor"
+
+// JanitorResult 单次清理删除的行数
+type JanitorResult struct {
+	AuthorizationCodes int64 `json:"authorization_codes"`
+	AccessTokens       int64 `json:"access_tokens"`
+	RefreshTokens      int64 `json:"refresh_tokens"`
+	UserRefreshTokens  int64 `json:"user_refresh_tokens"`
+	WebhookDeliveries  int64 `json:"webhook_deliveries"`
+}
+
+// OAuthJanitorService 清理过期、已撤销或已使用的授权码与令牌，以及第一方登录的过期或已轮换刷新令牌
+// 同时清理已投递成功的 Webhook 记录；超过保留时长的记录会被物理删除，可在服务内定时执行，也可通过 janitor 子命令手动执行
+type OAuthJanitorService struct {
+	oauthAuthorizationCodeRepository *oauthrepositories.OAuthAuthorizationCodeRepository
+	oauthAccessTokenRepository       *oauthrepositories.OAuthAccessTokenRepository
+	oauthRefreshTokenRepository      *oauthrepositories.OAuthRefreshTokenRepository
+	refreshTokenRepository           *repositories.RefreshTokenRepository
+	webhookDeliveryRepository        *repositories.WebhookDeliveryRepository
+	redisMgr                         *redis.Manager
+	logMgr                           *logger.Manager
+
+	cfg appconfig.JanitorConfig
+}
+
+// NewOAuthJanitorService 创建清理服务实例
+func NewOAuthJanitorService(
+	oauthAuthorizationCodeRepository *oauthrepositories.OAuthAuthorizationCodeRepository,
+	oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository,
+	oauthRefreshTokenRepository *oauthrepositories.OAuthRefreshTokenRepository,
+	refreshTokenRepository *repositories.RefreshTokenRepository,
+	webhookDeliveryRepository *repositories.WebhookDeliveryRepository,
+	redisMgr *redis.Manager,
+	logMgr *logger.Manager,
+	cfg appconfig.JanitorConfig,
+) *OAuthJanitorService {
+	return &OAuthJanitorService{
+		oauthAuthorizationCodeRepository: oauthAuthorizationCodeRepository,
+		oauthAccessTokenRepository:       oauthAccessTokenRepository,
+		oauthRefreshTokenRepository:      oauthRefreshTokenRepository,
+		refreshTokenRepository:           refreshTokenRepository,
+		webhookDeliveryRepository:        webhookDeliveryRepository,
+		redisMgr:                         redisMgr,
+		logMgr:                           logMgr,
+		cfg:                              cfg,
+	}
+}
+
+// Start 按配置间隔定时清理，ctx 取消后退出；未启用时直接返回
+func (s *OAuthJanitorService) Start(ctx context.Context) {
+	if !s.cfg.Enabled || s.cfg.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Run(ctx); err != nil && !errors.Is(err, redis.ErrLockAcquireFailed) {
+					s.logMgr.Error("清理过期令牌失败", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Run 执行一次清理
+// 其它实例正在清理时返回 redis.ErrLockAcquireFailed
+func (s *OAuthJanitorService) Run(ctx context.Context) (*JanitorResult, error) {
+	// 锁的过期时间取清理间隔，进程异常退出时锁也会自动释放
+	lockTTL := s.cfg.Interval
+	if lockTTL <= 0 {
+		lockTTL = time.Hour
+	}
+	lock := s.redisMgr.NewDistributedLock(janitorLockKey, lockTTL)
+	if err := lock.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer lock.Release(ctx)
+
+	before := time.Now().Add(-s.cfg.Retention)
+	result := &JanitorResult{}
+
+	var err error
+	if result.AuthorizationCodes, err = s.purge(ctx, before, s.oauthAuthorizationCodeRepository.PurgeStale); err != nil {
+		return result, err
+	}
+	if result.AccessTokens, err = s.purge(ctx, before, s.oauthAccessTokenRepository.PurgeStale); err != nil {
+		return result, err
+	}
+	if result.RefreshTokens, err = s.purge(ctx, before, s.oauthRefreshTokenRepository.PurgeStale); err != nil {
+		return result, err
+	}
+	if result.UserRefreshTokens, err = s.purge(ctx, before, s.refreshTokenRepository.PurgeStale); err != nil {
+		return result, err
+	}
+	if result.WebhookDeliveries, err = s.purge(ctx, before, s.webhookDeliveryRepository.PurgeStale); err != nil {
+		return result, err
+	}
+
+	s.logMgr.Info("清理过期令牌完成",
+		"authorization_codes", result.AuthorizationCodes,
+		"access_tokens", result.AccessTokens,
+		"refresh_tokens", result.RefreshTokens,
+		"user_refresh_tokens", result.UserRefreshTokens,
+		"webhook_deliveries", result.WebhookDeliveries,
+	)
+	return result, nil
+}
+
+// purge 逐批删除直到没有满足条件的记录，返回删除总数
+func (s *OAuthJanitorService) purge(ctx context.Context, before time.Time, purgeBatch func(context.Context, time.Time, int) (int64, error)) (int64, error) {
+	batchSize := s.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		n, err := purgeBatch(ctx, before, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package oauthservices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	oauthdto "goauth/dto/oauth"
+	"goauth/models"
+	oauthmodels "goauth/models/oauth"
+	oauthrepositories "goauth/repositories/oauth"
+	"goauth/services"
+	"goauth/utils"
+)
+
+// backchannelLogoutEvent 登出令牌 events 声明中的事件标识（OpenID Connect Back-Channel Logout 1.0）
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// OIDCLogoutService OpenID Connect 登出服务
+// 处理依赖方发起的登出，并在登录会话结束时通知在该会话中取得有效授权（未撤销且未过期的访问令牌或刷新令牌）的客户端：
+// 后端通道登出通知写入 Webhook 投递表，由投递任务发送并按退避策略重试；前端通道登出地址返回给调用方，由浏览器在隐藏的 iframe 中加载
+// 单个会话结束时登出令牌带有与 ID Token 相同的 sid，注销用户全部会话时只含 sub，客户端应结束该用户的全部会话
+type OIDCLogoutService struct {
+	oauthAccessTokenRepository  *oauthrepositories.OAuthAccessTokenRepository
+	oauthRefreshTokenRepository *oauthrepositories.OAuthRefreshTokenRepository
+	oauthClientService          *OAuthClientService
+	authService                 *services.AuthService
+	userService                 *services.UserService
+	tenantService               *services.TenantService
+	webhookService              *services.WebhookService
+	auditService                *services.AuditService
+	tokenHasher                 *utils.TokenHasher
+	httpClient                  *http.Client
+	logMgr                      *logger.Manager
+
+	cfg appconfig.LogoutConfig
+}
+
+// NewOIDCLogoutService 创建 OpenID Connect 登出服务实例
+func NewOIDCLogoutService(
+	oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository,
+	oauthRefreshTokenRepository *oauthrepositories.OAuthRefreshTokenRepository,
+	oauthClientService *OAuthClientService,
+	authService *services.AuthService,
+	userService *services.UserService,
+	tenantService *services.TenantService,
+	webhookService *services.WebhookService,
+	auditService *services.AuditService,
+	tokenHasher *utils.TokenHasher,
+	logMgr *logger.Manager,
+	cfg appconfig.LogoutConfig,
+) *OIDCLogoutService {
+	httpClient := &http.Client{
+		Timeout: cfg.BackchannelTimeout,
+		// 不跟随重定向，避免登出令牌被转发到登记以外的地址
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &OIDCLogoutService{
+		oauthAccessTokenRepository:  oauthAccessTokenRepository,
+		oauthRefreshTokenRepository: oauthRefreshTokenRepository,
+		oauthClientService:          oauthClientService,
+		authService:                 authService,
+		userService:                 userService,
+		tenantService:               tenantService,
+		webhookService:              webhookService,
+		auditService:                auditService,
+		tokenHasher:                 tokenHasher,
+		httpClient:                  httpClient,
+		logMgr:                      logMgr,
+		cfg:                         cfg,
+	}
+}
+
+// EndSessionResult 依赖方发起登出的处理结果
+type EndSessionResult struct {
+	RedirectURL            string   // 登出后跳转的地址（已附带 state），未指定 post_logout_redirect_uri 时为空
+	FrontchannelLogoutURIs []string // 需由浏览器加载的前端通道登出地址
+	LoggedOut              bool     // 是否注销了当前登录会话，为 true 时须清除登录 Cookie
+}
+
+// EndSession 处理依赖方发起的登出（end_session_endpoint）
+// post_logout_redirect_uri 须在 id_token_hint 或 client_id 确定的客户端中登记
+// 只有 id_token_hint 对应当前登录用户时才注销会话，其余情况只校验请求并返回跳转地址
+// id_token_hint 带有 sid 时须为当前会话签发，其他会话的 ID Token 不会注销当前会话
+func (s *OIDCLogoutService) EndSession(ctx context.Context, form *oauthdto.EndSessionForm, userID uint, sessionID string) (*EndSessionResult, error) {
+	var client *oauthmodels.OAuthClient
+	var hintUserID uint
+	var hintSessionID string
+	if form.IDTokenHint != "" {
+		var err error
+		client, hintUserID, hintSessionID, err = s.parseIDTokenHint(ctx, form.IDTokenHint)
+		if err != nil {
+			return nil, err
+		}
+		if form.ClientID != "" && form.ClientID != strconv.FormatUint(uint64(client.ID), 10) {
+			return nil, apperrors.ErrLogoutClientMismatch
+		}
+	} else if form.ClientID != "" {
+		var err error
+		if client, err = s.getClient(ctx, form.ClientID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &EndSessionResult{}
+	if form.PostLogoutRedirectURI != "" {
+		if client == nil {
+			return nil, apperrors.ErrLogoutClientRequired
+		}
+		if client.Status == 0 {
+			return nil, apperrors.ErrOAuthClientDisabled
+		}
+		if !utils.IsRedirectURIValid(form.PostLogoutRedirectURI, client.PostLogoutRedirectURIs) {
+			return nil, apperrors.ErrPostLogoutRedirectURIInvalid
+		}
+		redirectURL, err := url.Parse(form.PostLogoutRedirectURI)
+		if err != nil {
+			return nil, apperrors.ErrPostLogoutRedirectURIInvalid
+		}
+		if form.State != "" {
+			query := redirectURL.Query()
+			query.Set("state", form.State)
+			redirectURL.RawQuery = query.Encode()
+		}
+		result.RedirectURL = redirectURL.String()
+	}
+
+	// 未登录或会话已失效时，此前退出登录时已通知过客户端；没有 id_token_hint 的请求可能来自任意第三方页面，不注销会话
+	if userID == 0 || hintUserID == 0 {
+		return result, nil
+	}
+	if hintUserID != userID {
+		return nil, apperrors.ErrLogoutUserMismatch
+	}
+	if hintSessionID != "" && hintSessionID != oidcSessionID(s.tokenHasher, sessionID) {
+		return result, nil
+	}
+
+	frontchannelLogoutURIs, err := s.authService.Logout(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	result.LoggedOut = true
+	result.FrontchannelLogoutURIs = frontchannelLogoutURIs
+	return result, nil
+}
+
+// NotifyLogout 通知持有用户有效授权的客户端该用户已退出登录，返回前端通道登出地址
+// sessionID 非空时只通知在该会话中取得授权的客户端，登出令牌带有该会话的 sid
+// 后端通道登出通知写入投递表后即返回，不阻塞退出登录；投递结果记录在审计事件中
+func (s *OIDCLogoutService) NotifyLogout(ctx context.Context, userID uint, sessionID string) []string {
+	clients, err := s.activeClients(ctx, userID, sessionID)
+	if err != nil {
+		s.logMgr.Error("查询用户已授权的客户端失败", "error", err, "user_id", userID)
+		return nil
+	}
+	if len(clients) == 0 {
+		return nil
+	}
+
+	var frontchannelLogoutURIs []string
+	var subject string
+	sid := oidcSessionID(s.tokenHasher, sessionID)
+	for _, client := range clients {
+		if client.FrontchannelLogoutURI != "" {
+			frontchannelLogoutURIs = append(frontchannelLogoutURIs, client.FrontchannelLogoutURI)
+		}
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+
+		// 登出令牌的 sub 与访问令牌一致，使用用户的 Subject
+		if subject == "" {
+			user, err := s.userService.GetUser(ctx, map[string]any{"id": userID})
+			if err != nil {
+				s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+				return frontchannelLogoutURIs
+			}
+			subject = user.Subject
+		}
+		clientID := strconv.FormatUint(uint64(client.ID), 10)
+		if err := s.webhookService.EnqueueForClient(ctx, clientID, models.WebhookBackchannelLogout, oauthdto.BackchannelLogoutData{UserID: userID, Subject: subject, SessionID: sid}); err != nil {
+			s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditLogoutNotify, UserID: &userID, ClientID: clientID, TargetType: "oauth_client", TargetID: clientID}, err)
+		}
+	}
+	return frontchannelLogoutURIs
+}
+
+// activeClients 查询用户持有未撤销且未过期令牌的启用客户端，sessionID 非空时只查询在该会话中签发的令牌
+func (s *OIDCLogoutService) activeClients(ctx context.Context, userID uint, sessionID string) ([]*oauthmodels.OAuthClient, error) {
+	conds := map[string]any{"user_id": userID, "revoked": false, "expires_at > ?": time.Now()}
+	if sessionID != "" {
+		conds["session_id"] = sessionID
+	}
+	accessClientIDs, err := s.oauthAccessTokenRepository.ListClientIDs(ctx, conds)
+	if err != nil {
+		return nil, err
+	}
+	refreshClientIDs, err := s.oauthRefreshTokenRepository.ListClientIDs(ctx, conds)
+	if err != nil {
+		return nil, err
+	}
+	clientIDs := append(accessClientIDs, refreshClientIDs...)
+	slices.Sort(clientIDs)
+	clientIDs = slices.Compact(clientIDs)
+
+	clients := make([]*oauthmodels.OAuthClient, 0, len(clientIDs))
+	for _, clientID := range clientIDs {
+		client, err := s.getClient(ctx, clientID)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrLogoutClientNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if client.Status == 0 {
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// getClient 按客户端ID获取客户端的完整记录
+func (s *OIDCLogoutService) getClient(ctx context.Context, clientID string) (*oauthmodels.OAuthClient, error) {
+	id, err := strconv.ParseUint(clientID, 10, 64)
+	if err != nil {
+		return nil, apperrors.ErrLogoutClientNotFound
+	}
+	client, err := s.oauthClientService.GetOAuthClientModel(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrLogoutClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// parseIDTokenHint 解析 id_token_hint，返回令牌所属的客户端、用户与登录会话的 sid
+// 按 aud 取客户端的访问令牌密钥验签并校验发行方；带有 token_type 声明的访问令牌与刷新令牌不是 ID Token，不予接受
+// 提示只用于确定客户端、用户与会话，已过期的令牌同样接受
+func (s *OIDCLogoutService) parseIDTokenHint(ctx context.Context, hint string) (*oauthmodels.OAuthClient, uint, string, error) {
+	var unverified idTokenClaims
+	if _, _, err := gojwt.NewParser().ParseUnverified(hint, &unverified); err != nil || len(unverified.Audience) != 1 {
+		return nil, 0, "", apperrors.ErrLogoutIDTokenHintInvalid
+	}
+	client, err := s.getClient(ctx, unverified.Audience[0])
+	if err != nil {
+		if errors.Is(err, apperrors.ErrLogoutClientNotFound) {
+			return nil, 0, "", apperrors.ErrLogoutIDTokenHintInvalid
+		}
+		return nil, 0, "", err
+	}
+
+	var claims idTokenClaims
+	if _, err := gojwt.ParseWithClaims(hint, &claims, func(*gojwt.Token) (any, error) {
+		return []byte(client.AccessTokenSecret), nil
+	}, gojwt.WithValidMethods([]string{gojwt.SigningMethodHS256.Alg()}), gojwt.WithoutClaimsValidation()); err != nil {
+		return nil, 0, "", apperrors.ErrLogoutIDTokenHintInvalid
+	}
+	if claims.TokenType != "" || claims.Issuer != s.tenantService.Issuer(ctx) || claims.Subject == "" {
+		return nil, 0, "", apperrors.ErrLogoutIDTokenHintInvalid
+	}
+	user, err := s.userService.GetUser(ctx, map[string]any{"subject": claims.Subject})
+	if err != nil {
+		return nil, 0, "", apperrors.ErrLogoutIDTokenHintInvalid
+	}
+	return client, user.ID, claims.SessionID, nil
+}
+
+// logoutToken 生成发给客户端的登出令牌
+// 使用客户端的访问令牌密钥以 HS256 签名，与客户端本地校验访问令牌使用的密钥相同
+// sid 为空时省略，表示结束该用户在客户端的全部会话
+func (s *OIDCLogoutService) logoutToken(ctx context.Context, client *oauthmodels.OAuthClient, subject, sid string) (string, error) {
+	now := time.Now()
+	claims := gojwt.MapClaims{
+		"iss":    s.tenantService.Issuer(ctx),
+		"sub":    subject,
+		"aud":    strconv.FormatUint(uint64(client.ID), 10),
+		"iat":    now.Unix(),
+		"exp":    now.Add(s.cfg.LogoutTokenTTL).Unix(),
+		"jti":    uuid.NewString(),
+		"events": map[string]any{backchannelLogoutEvent: map[string]any{}},
+	}
+	if sid != "" {
+		claims["sid"] = sid
+	}
+	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims)
+	token.Header["typ"] = "logout+jwt"
+	return token.SignedString([]byte(client.AccessTokenSecret))
+}
+
+// Send 实现 services.WebhookDeliveryHandler，签发登出令牌并推送到客户端的后端通道登出地址
+// 登出令牌有效期较短，每次发送时重新签发，重试时不会因令牌过期而被客户端拒绝
+func (s *OIDCLogoutService) Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	ctx, data, err := s.deliveryContext(ctx, delivery)
+	if err != nil {
+		return 0, err
+	}
+	client, err := s.getClient(ctx, delivery.ClientID)
+	if err != nil {
+		return 0, fmt.Errorf("获取客户端失败: %w", err)
+	}
+	if client.Status == 0 || client.BackchannelLogoutURI == "" {
+		return 0, errors.New("客户端已停用或未登记后端通道登出地址")
+	}
+	logoutToken, err := s.logoutToken(ctx, client, data.Subject, data.SessionID)
+	if err != nil {
+		return 0, fmt.Errorf("生成登出令牌失败: %w", err)
+	}
+	return s.send(ctx, client.BackchannelLogoutURI, logoutToken)
+}
+
+// Finish 实现 services.WebhookDeliveryHandler，记录投递结果的审计事件
+func (s *OIDCLogoutService) Finish(ctx context.Context, delivery *models.WebhookDelivery, err error) {
+	ctx, data, parseErr := s.deliveryContext(ctx, delivery)
+	if parseErr != nil {
+		s.logMgr.Error("解析后端通道登出通知失败", "error", parseErr, "id", delivery.ID)
+		return
+	}
+	if err != nil {
+		s.logMgr.Warn("投递登出令牌失败", "error", err, "client_id", delivery.ClientID, "user_id", data.UserID)
+	}
+	s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditLogoutNotify, UserID: &data.UserID, ClientID: delivery.ClientID, TargetType: "oauth_client", TargetID: delivery.ClientID}, err)
+}
+
+// deliveryContext 解析投递记录中的通知数据，并把通知所属的租户写入 context
+func (s *OIDCLogoutService) deliveryContext(ctx context.Context, delivery *models.WebhookDelivery) (context.Context, *oauthdto.BackchannelLogoutData, error) {
+	var event struct {
+		Tenant string                         `json:"tenant"`
+		Data   oauthdto.BackchannelLogoutData `json:"data"`
+	}
+	if err := json.Unmarshal(delivery.Payload, &event); err != nil {
+		return nil, nil, fmt.Errorf("解析通知数据失败: %w", err)
+	}
+	tenant, err := s.tenantService.Resolve(ctx, event.Tenant)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取租户失败: %w", err)
+	}
+	return utils.WithTenant(ctx, tenant), &event.Data, nil
+}
+
+// send 以表单 POST 推送登出令牌，返回响应状态码；客户端返回 2xx 视为成功
+func (s *OIDCLogoutService) send(ctx context.Context, backchannelLogoutURI, logoutToken string) (int, error) {
+	body := url.Values{"logout_token": {logoutToken}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, backchannelLogoutURI, strings.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "goauth-logout")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("客户端返回 %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
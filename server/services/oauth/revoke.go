@@ -3,8 +3,14 @@ package oauthservices
 import (
 	"context"
 	"errors"
+	"time"
+
+	"goauth/dto"
+	"goauth/models"
 	"goauth/models/oauth"
 	"goauth/repositories/oauth"
+	"goauth/services"
+	"goauth/utils"
 
 	"github.com/3086953492/gokit/logger"
 	"gorm.io/gorm"
@@ -15,7 +21,13 @@ type OAuthRevokeService struct {
 	db                          *gorm.DB
 	oauthAccessTokenRepository  *oauthrepositories.OAuthAccessTokenRepository
 	oauthRefreshTokenRepository *oauthrepositories.OAuthRefreshTokenRepository
+	denylist                    *OAuthTokenDenylist
+	accessTokenCache            *services.AccessTokenCache
+	userTokenEpoch              *services.UserTokenEpoch
+	tokenHasher                 *utils.TokenHasher
 	logMgr                      *logger.Manager
+	auditService                *services.AuditService
+	webhookService              *services.WebhookService
 }
 
 // NewOAuthRevokeService 创建令牌撤销服务实例
@@ -23,48 +35,73 @@ func NewOAuthRevokeService(
 	db *gorm.DB,
 	oauthAccessTokenRepository *oauthrepositories.OAuthAccessTokenRepository,
 	oauthRefreshTokenRepository *oauthrepositories.OAuthRefreshTokenRepository,
+	denylist *OAuthTokenDenylist,
+	accessTokenCache *services.AccessTokenCache,
+	userTokenEpoch *services.UserTokenEpoch,
+	tokenHasher *utils.TokenHasher,
 	logMgr *logger.Manager,
+	auditService *services.AuditService,
+	webhookService *services.WebhookService,
 ) *OAuthRevokeService {
 	return &OAuthRevokeService{
 		db:                          db,
 		oauthAccessTokenRepository:  oauthAccessTokenRepository,
 		oauthRefreshTokenRepository: oauthRefreshTokenRepository,
+		denylist:                    denylist,
+		accessTokenCache:            accessTokenCache,
+		userTokenEpoch:              userTokenEpoch,
+		tokenHasher:                 tokenHasher,
 		logMgr:                      logMgr,
+		auditService:                auditService,
+		webhookService:              webhookService,
 	}
 }
 
 // RevokeToken 撤销令牌（支持 access_token 和 refresh_token，refresh 撤销时级联撤销关联的 access token）
 // 按 RFC7009 约定：token 不存在或不属于该 client 时，无差别返回 nil（200）不泄露信息
 func (s *OAuthRevokeService) RevokeToken(ctx context.Context, token string, tokenTypeHint string, clientID string) error {
+	err := s.revokeToken(ctx, token, tokenTypeHint, clientID, true)
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Type:      models.AuditTokenRevoke,
+		ActorType: models.AuditActorClient,
+		ClientID:  clientID,
+		Details:   services.AuditDetails(map[string]any{"token_type_hint": tokenTypeHint}),
+	}, err)
+	return err
+}
+
+// revokeToken 撤销令牌，不记录审计事件；刷新令牌轮换时撤销旧令牌也使用此方法
+// notify 为 true 时在撤销的事务中写入 grant.revoked 的 Webhook 事件，轮换不属于撤销授权，不通知
+func (s *OAuthRevokeService) revokeToken(ctx context.Context, token string, tokenTypeHint string, clientID string, notify bool) error {
 	// 根据 hint 决定查询顺序
 	switch tokenTypeHint {
 	case "access_token":
 		// 先查 access，再查 refresh
-		if s.tryRevokeAccessToken(ctx, token, clientID) {
+		if s.tryRevokeAccessToken(ctx, token, clientID, notify) {
 			return nil
 		}
-		s.tryRevokeRefreshToken(ctx, token, clientID)
+		s.tryRevokeRefreshToken(ctx, token, clientID, notify)
 		return nil
 	case "refresh_token":
 		// 先查 refresh，再查 access
-		if s.tryRevokeRefreshToken(ctx, token, clientID) {
+		if s.tryRevokeRefreshToken(ctx, token, clientID, notify) {
 			return nil
 		}
-		s.tryRevokeAccessToken(ctx, token, clientID)
+		s.tryRevokeAccessToken(ctx, token, clientID, notify)
 		return nil
 	default:
 		// hint 为空或其他：优先 refresh（级联更完整）
-		if s.tryRevokeRefreshToken(ctx, token, clientID) {
+		if s.tryRevokeRefreshToken(ctx, token, clientID, notify) {
 			return nil
 		}
-		s.tryRevokeAccessToken(ctx, token, clientID)
+		s.tryRevokeAccessToken(ctx, token, clientID, notify)
 		return nil
 	}
 }
 
 // tryRevokeAccessToken 尝试撤销 access token，成功返回 true
-func (s *OAuthRevokeService) tryRevokeAccessToken(ctx context.Context, token string, clientID string) bool {
-	accessToken, err := s.oauthAccessTokenRepository.Get(ctx, map[string]any{"access_token": token})
+func (s *OAuthRevokeService) tryRevokeAccessToken(ctx context.Context, token string, clientID string, notify bool) bool {
+	accessToken, err := s.oauthAccessTokenRepository.Get(ctx, map[string]any{"token_hash": s.tokenHasher.Hash(token)})
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logMgr.Error("查询access token失败", "error", err)
@@ -81,16 +118,36 @@ func (s *OAuthRevokeService) tryRevokeAccessToken(ctx context.Context, token str
 		return true
 	}
 	// 撤销
-	if err := s.oauthAccessTokenRepository.Update(ctx, accessToken.ID, map[string]any{"revoked": true}); err != nil {
-		s.logMgr.Error("撤销access token失败", "error", err, "id", accessToken.ID)
+	txErr := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(ctx).Model(&oauthmodels.OAuthAccessToken{}).Where("id = ?", accessToken.ID).Update("revoked", true).Error; err != nil {
+			return err
+		}
+		if !notify {
+			return nil
+		}
+		return s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookGrantRevoked, dto.WebhookGrantData{ClientID: clientID, UserID: accessToken.UserID, TokenType: "access_token", Scope: accessToken.Scope})
+	})
+	if txErr != nil {
+		s.logMgr.Error("撤销access token失败", "error", txErr, "id", accessToken.ID)
 		return false
 	}
+	s.invalidateAccessToken(ctx, accessToken)
 	return true
 }
 
+// invalidateAccessToken 清除已撤销 access token 的查询缓存，并写入黑名单供 stateless 模式拒绝
+func (s *OAuthRevokeService) invalidateAccessToken(ctx context.Context, accessToken *oauthmodels.OAuthAccessToken) {
+	if err := s.accessTokenCache.Invalidate(ctx, accessToken.TokenHash); err != nil {
+		s.logMgr.Error("清除访问令牌缓存失败", "error", err, "id", accessToken.ID)
+	}
+	if err := s.denylist.Add(ctx, accessToken.JTI, accessToken.ExpiresAt); err != nil {
+		s.logMgr.Error("写入令牌黑名单失败", "error", err, "id", accessToken.ID, "jti", accessToken.JTI)
+	}
+}
+
 // tryRevokeRefreshToken 尝试撤销 refresh token，并级联撤销关联的 access token，成功返回 true
-func (s *OAuthRevokeService) tryRevokeRefreshToken(ctx context.Context, token string, clientID string) bool {
-	refreshToken, err := s.oauthRefreshTokenRepository.Get(ctx, map[string]any{"refresh_token": token})
+func (s *OAuthRevokeService) tryRevokeRefreshToken(ctx context.Context, token string, clientID string, notify bool) bool {
+	refreshToken, err := s.oauthRefreshTokenRepository.Get(ctx, map[string]any{"token_hash": s.tokenHasher.Hash(token)})
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logMgr.Error("查询refresh token失败", "error", err)
@@ -117,11 +174,164 @@ func (s *OAuthRevokeService) tryRevokeRefreshToken(ctx context.Context, token st
 				return err
 			}
 		}
-		return nil
+		if !notify {
+			return nil
+		}
+		return s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookGrantRevoked, dto.WebhookGrantData{ClientID: clientID, UserID: &refreshToken.UserID, TokenType: "refresh_token", Scope: refreshToken.Scope})
 	})
 	if txErr != nil {
 		s.logMgr.Error("撤销refresh token失败", "error", txErr, "id", refreshToken.ID)
 		return false
 	}
+	if refreshToken.AccessTokenID != 0 {
+		accessToken, err := s.oauthAccessTokenRepository.Get(ctx, map[string]any{"id": refreshToken.AccessTokenID})
+		if err != nil {
+			s.logMgr.Error("查询access token失败", "error", err, "id", refreshToken.AccessTokenID)
+		} else {
+			s.invalidateAccessToken(ctx, accessToken)
+		}
+	}
 	return true
 }
+
+// RevokeClientTokens 撤销客户端名下全部未过期的 access token、refresh token 与未使用的授权码
+// 客户端被禁用或删除时调用，已签发的令牌随即失效
+func (s *OAuthRevokeService) RevokeClientTokens(ctx context.Context, clientID string) error {
+	// 先记下需要失效的 access token，撤销后逐个清除缓存并写入黑名单
+	accessTokens, err := s.oauthAccessTokenRepository.Find(ctx, map[string]any{"client_id": clientID, "revoked": false, "expires_at > ?": time.Now()})
+	if err != nil {
+		s.logMgr.Error("查询客户端access token失败", "error", err, "client_id", clientID)
+		return err
+	}
+
+	txErr := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.enqueueGrantsRevokedWithTx(ctx, tx, "client_id", clientID, "client"); err != nil {
+			return err
+		}
+		return revokeTokensWithTx(ctx, tx, "client_id", clientID)
+	})
+	if txErr != nil {
+		s.logMgr.Error("撤销客户端令牌失败", "error", txErr, "client_id", clientID)
+		return txErr
+	}
+
+	for i := range accessTokens {
+		s.invalidateAccessToken(ctx, &accessTokens[i])
+	}
+	// 兜底清除查询期间新签发令牌的缓存
+	if err := s.accessTokenCache.InvalidateByClient(ctx, clientID); err != nil {
+		s.logMgr.Error("清除访问令牌缓存失败", "error", err, "client_id", clientID)
+	}
+
+	s.logMgr.Info("撤销客户端令牌成功", "client_id", clientID, "access_tokens", len(accessTokens))
+	return nil
+}
+
+// RevokeUserTokens 推进用户令牌纪元并撤销其全部登录会话、OAuth 令牌与未使用的授权码
+// apply 为调用方对用户的变更（禁用、改密、删除），与撤销在同一事务中执行；
+// 纪元推进后，签发于此前的 Cookie 令牌与 stateless 模式的 Bearer 令牌一并失效
+func (s *OAuthRevokeService) RevokeUserTokens(ctx context.Context, userID uint, apply func(tx *gorm.DB) error) error {
+	accessTokens, err := s.oauthAccessTokenRepository.Find(ctx, map[string]any{"user_id": userID, "revoked": false, "expires_at > ?": time.Now()})
+	if err != nil {
+		s.logMgr.Error("查询用户access token失败", "error", err, "user_id", userID)
+		return err
+	}
+
+	// 先删除纪元副本，事务提交前的查询只能回源数据库
+	if err := s.userTokenEpoch.Delete(ctx, userID); err != nil {
+		s.logMgr.Error("删除令牌纪元失败", "error", err, "user_id", userID)
+		return err
+	}
+
+	epoch := time.Now().UnixMilli()
+	txErr := s.db.Transaction(func(tx *gorm.DB) error {
+		if apply != nil {
+			if err := apply(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.WithContext(ctx).Unscoped().Model(&models.User{}).Where("id = ?", userID).Update("token_epoch", epoch).Error; err != nil {
+			return err
+		}
+		// 会话在 Redis 中的副本由纪元校验兜底，无需逐个删除
+		if err := tx.WithContext(ctx).Model(&models.Session{}).Where("user_id = ? AND revoked = ?", userID, false).Update("revoked", true).Error; err != nil {
+			return err
+		}
+		if err := s.enqueueGrantsRevokedWithTx(ctx, tx, "user_id", userID, "user"); err != nil {
+			return err
+		}
+		return revokeTokensWithTx(ctx, tx, "user_id", userID)
+	})
+	if txErr != nil {
+		s.logMgr.Error("撤销用户令牌失败", "error", txErr, "user_id", userID)
+		return txErr
+	}
+
+	for i := range accessTokens {
+		s.invalidateAccessToken(ctx, &accessTokens[i])
+	}
+	if err := s.accessTokenCache.InvalidateByUser(ctx, userID); err != nil {
+		s.logMgr.Error("清除访问令牌缓存失败", "error", err, "user_id", userID)
+	}
+	// 纪元副本无法更新时此前签发的令牌仍可能通过校验，返回错误由调用方重试
+	if err := s.userTokenEpoch.Set(ctx, userID, epoch); err != nil {
+		s.logMgr.Error("写入令牌纪元失败", "error", err, "user_id", userID)
+		return err
+	}
+
+	s.logMgr.Info("撤销用户令牌成功", "user_id", userID, "access_tokens", len(accessTokens))
+	return nil
+}
+
+// enqueueGrantsRevokedWithTx 在撤销令牌前查询按列匹配的有效授权，每个客户端与用户的授权写入一条 grant.revoked 事件
+// 须与 revokeTokensWithTx 在同一事务中先于其执行，事务回滚时事件一并丢弃
+func (s *OAuthRevokeService) enqueueGrantsRevokedWithTx(ctx context.Context, tx *gorm.DB, column string, value any, reason string) error {
+	type grant struct {
+		ClientID string
+		UserID   *uint
+	}
+	// 访问令牌与刷新令牌中的同一授权只通知一次，客户端凭证模式的授权没有用户，以 0 区分
+	type grantKey struct {
+		clientID string
+		userID   uint
+	}
+	var grants []grant
+	seen := make(map[grantKey]bool)
+	now := time.Now()
+	for _, model := range []any{&oauthmodels.OAuthAccessToken{}, &oauthmodels.OAuthRefreshToken{}} {
+		var found []grant
+		if err := tx.WithContext(ctx).Model(model).Distinct("client_id", "user_id").Where(column+" = ? AND revoked = ? AND expires_at > ?", value, false, now).Scan(&found).Error; err != nil {
+			return err
+		}
+		for _, g := range found {
+			key := grantKey{clientID: g.ClientID}
+			if g.UserID != nil {
+				key.userID = *g.UserID
+			}
+			if !seen[key] {
+				seen[key] = true
+				grants = append(grants, g)
+			}
+		}
+	}
+	for _, g := range grants {
+		if err := s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookGrantRevoked, dto.WebhookGrantData{ClientID: g.ClientID, UserID: g.UserID, Reason: reason}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeTokensWithTx 在事务中按列撤销 access token、refresh token，并将未使用的授权码标记为已使用
+func revokeTokensWithTx(ctx context.Context, tx *gorm.DB, column string, value any) error {
+	if err := tx.WithContext(ctx).Model(&oauthmodels.OAuthAccessToken{}).Where(column+" = ? AND revoked = ?", value, false).Update("revoked", true).Error; err != nil {
+		return err
+	}
+	if err := tx.WithContext(ctx).Model(&oauthmodels.OAuthRefreshToken{}).Where(column+" = ? AND revoked = ?", value, false).Update("revoked", true).Error; err != nil {
+		return err
+	}
+	if err := tx.WithContext(ctx).Model(&oauthmodels.OAuthAuthorizationCode{}).Where(column+" = ? AND used = ?", value, false).Update("used", true).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package oauthservices
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/3086953492/gokit/security/random"
+	"github.com/crewjam/saml"
+	samllogger "github.com/crewjam/saml/logger"
+	dsig "github.com/russellhaering/goxmldsig"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	oauthmodels "goauth/models/oauth"
+	"goauth/repositories"
+	oauthrepositories "goauth/repositories/oauth"
+	"goauth/services"
+	"goauth/utils"
+)
+
+// samlRequestKeyPrefix 暂存的服务提供方认证请求，键为请求凭据的摘要
+const samlRequestKeyPrefix = "saml:request:"
+
+// samlPendingRequest 等待用户登录的服务提供方认证请求
+type samlPendingRequest struct {
+	Request    []byte    `json:"request"`
+	RelayState string    `json:"relay_state"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// SAMLIdentityProviderService goauth 作为 SAML 2.0 身份提供方，为已登录的用户向服务提供方签发断言
+// 服务提供方发起的登录先暂存认证请求，前端确认登录状态后再凭一次性凭据换取断言
+// 每个租户是独立的身份提供方：实体ID与地址带租户路径前缀，断言用租户自己的证书签名
+type SAMLIdentityProviderService struct {
+	samlServiceProviderRepository *oauthrepositories.SAMLServiceProviderRepository
+	sessionRepository             *repositories.SessionRepository
+	userService                   *services.UserService
+	rbacService                   *services.RBACService
+	tenantService                 *services.TenantService
+	redisMgr                      *redis.Manager
+	tokenHasher                   *utils.TokenHasher
+	logMgr                        *logger.Manager
+	cfg                           appconfig.SAMLConfig
+	idp                           *saml.IdentityProvider // 默认租户，使用配置文件中的证书
+	baseURL                       string
+	frontendURL                   string
+
+	idps sync.Map // 租户ID -> *saml.IdentityProvider
+}
+
+// NewSAMLIdentityProviderService 创建 SAML 身份提供方服务，未启用时 keyPair 为 nil
+func NewSAMLIdentityProviderService(samlServiceProviderRepository *oauthrepositories.SAMLServiceProviderRepository, sessionRepository *repositories.SessionRepository, userService *services.UserService, rbacService *services.RBACService, tenantService *services.TenantService, redisMgr *redis.Manager, tokenHasher *utils.TokenHasher, logMgr *logger.Manager, cfg appconfig.SAMLConfig, keyPair *utils.SigningKeyPair, baseURL, frontendURL string) *SAMLIdentityProviderService {
+	s := &SAMLIdentityProviderService{
+		samlServiceProviderRepository: samlServiceProviderRepository,
+		sessionRepository:             sessionRepository,
+		userService:                   userService,
+		rbacService:                   rbacService,
+		tenantService:                 tenantService,
+		redisMgr:                      redisMgr,
+		tokenHasher:                   tokenHasher,
+		logMgr:                        logMgr,
+		cfg:                           cfg,
+		baseURL:                       baseURL,
+		frontendURL:                   frontendURL,
+	}
+	if !cfg.Enabled || keyPair == nil {
+		return s
+	}
+	s.idp = s.newIdentityProvider(context.Background(), keyPair)
+	return s
+}
+
+// identityProvider 当前租户的身份提供方，非默认租户首次使用时载入或生成租户的签名证书
+func (s *SAMLIdentityProviderService) identityProvider(ctx context.Context) (*saml.IdentityProvider, error) {
+	if s.idp == nil {
+		return nil, apperrors.ErrSAMLDisabled
+	}
+	tenantID := utils.TenantID(ctx)
+	if tenantID == models.DefaultTenantID {
+		return s.idp, nil
+	}
+	if idp, ok := s.idps.Load(tenantID); ok {
+		return idp.(*saml.IdentityProvider), nil
+	}
+
+	keyPair, err := s.tenantService.SAMLKeyPair(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := s.idps.LoadOrStore(tenantID, s.newIdentityProvider(ctx, keyPair))
+	return actual.(*saml.IdentityProvider), nil
+}
+
+// newIdentityProvider 按 ctx 所属租户的地址与给定证书创建身份提供方，元数据地址即断言的 Issuer
+func (s *SAMLIdentityProviderService) newIdentityProvider(ctx context.Context, keyPair *utils.SigningKeyPair) *saml.IdentityProvider {
+	metadataURL, _ := url.Parse(utils.RealmURL(ctx, s.baseURL, "/api/v1/saml/metadata"))
+	ssoURL, _ := url.Parse(utils.RealmURL(ctx, s.baseURL, "/api/v1/saml/sso"))
+	signatureMethod := dsig.RSASHA256SignatureMethod
+	if _, ok := keyPair.Signer.(*ecdsa.PrivateKey); ok {
+		signatureMethod = dsig.ECDSASHA256SignatureMethod
+	}
+	validDuration := s.cfg.MetadataValidDuration
+	return &saml.IdentityProvider{
+		Signer:                  keyPair.Signer,
+		Certificate:             keyPair.Certificate,
+		Logger:                  samllogger.DefaultLogger,
+		MetadataURL:             *metadataURL,
+		SSOURL:                  *ssoURL,
+		ServiceProviderProvider: s,
+		SignatureMethod:         signatureMethod,
+		ValidDuration:           &validDuration,
+	}
+}
+
+// Metadata 返回当前租户的身份提供方元数据 XML
+func (s *SAMLIdentityProviderService) Metadata(ctx context.Context) ([]byte, error) {
+	idp, err := s.identityProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata := idp.Metadata()
+	// NameID 取值为用户标识，声明支持的格式以便服务提供方配置
+	metadata.IDPSSODescriptors[0].NameIDFormats = []saml.NameIDFormat{oauthmodels.SAMLNameIDFormatPersistent, oauthmodels.SAMLNameIDFormatUnspecified}
+	data, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		s.logMgr.Error("生成 SAML 元数据失败", "error", err)
+		return nil, apperrors.ErrSAMLResponseFailed
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// BeginSSO 校验服务提供方发来的认证请求并暂存，返回前端登录确认页地址
+// 认证请求须在短时间内处理，用户登录可能耗时较长，因此先校验再暂存，换取断言时不再检查请求时间
+func (s *SAMLIdentityProviderService) BeginSSO(ctx context.Context, r *http.Request) (string, error) {
+	idp, err := s.identityProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := saml.NewIdpAuthnRequest(idp, r.WithContext(ctx))
+	if err != nil {
+		s.logMgr.Warn("解析 SAML 认证请求失败", "error", err)
+		return "", apperrors.ErrSAMLRequestInvalid
+	}
+	if err := req.Validate(); err != nil {
+		s.logMgr.Warn("SAML 认证请求校验失败", "error", err)
+		return "", apperrors.ErrSAMLRequestInvalid
+	}
+
+	token, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成 SAML 请求凭据失败", "error", err)
+		return "", apperrors.ErrUserSystemBusy
+	}
+	data, err := json.Marshal(samlPendingRequest{Request: req.RequestBuffer, RelayState: req.RelayState, ReceivedAt: req.Now})
+	if err != nil {
+		return "", apperrors.ErrUserSystemBusy
+	}
+	if err := s.redisMgr.SetBytes(ctx, s.requestKey(ctx, token), data, s.cfg.RequestTTL); err != nil {
+		s.logMgr.Error("暂存 SAML 认证请求失败", "error", err)
+		return "", apperrors.ErrUserSystemBusy
+	}
+
+	return s.frontendURL + "/saml/sso?request=" + url.QueryEscape(token), nil
+}
+
+// ContinueSSO 用户登录后凭一次性凭据取回暂存的认证请求，签发断言并返回自动提交到服务提供方的表单
+func (s *SAMLIdentityProviderService) ContinueSSO(ctx context.Context, r *http.Request, token string, userID uint, sessionID string) (*saml.IdpAuthnRequestForm, error) {
+	idp, err := s.identityProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.takeRequest(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按收到请求的时间重新校验，服务提供方配置在此期间被修改或禁用时同样拒绝
+	req := &saml.IdpAuthnRequest{
+		IDP:           idp,
+		HTTPRequest:   r.WithContext(ctx),
+		RelayState:    pending.RelayState,
+		RequestBuffer: pending.Request,
+		Now:           pending.ReceivedAt,
+	}
+	if err := req.Validate(); err != nil {
+		s.logMgr.Warn("SAML 认证请求校验失败", "error", err)
+		return nil, apperrors.ErrSAMLRequestInvalid
+	}
+	req.Now = saml.TimeNow()
+
+	sp, err := s.serviceProvider(ctx, map[string]any{"entity_id": req.ServiceProviderMetadata.EntityID})
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, req, sp, userID, sessionID)
+}
+
+// IDPInitiated 由 goauth 发起登录到指定服务提供方，断言发送到元数据中的第一个 HTTP-POST 断言消费地址
+func (s *SAMLIdentityProviderService) IDPInitiated(ctx context.Context, r *http.Request, spID uint, relayState string, userID uint, sessionID string) (*saml.IdpAuthnRequestForm, error) {
+	idp, err := s.identityProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := s.serviceProvider(ctx, map[string]any{"id": spID})
+	if err != nil {
+		return nil, err
+	}
+	if !sp.AllowIDPInitiated {
+		return nil, apperrors.ErrSAMLIDPInitiatedDisabled
+	}
+	metadata, err := parseSAMLMetadata(sp.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if relayState == "" {
+		relayState = sp.DefaultRelayState
+	}
+
+	req := &saml.IdpAuthnRequest{
+		IDP:                     idp,
+		HTTPRequest:             r.WithContext(ctx),
+		RelayState:              relayState,
+		ServiceProviderMetadata: metadata,
+		Now:                     saml.TimeNow(),
+	}
+	for i := range metadata.SPSSODescriptors {
+		descriptor := &metadata.SPSSODescriptors[i]
+		for j := range descriptor.AssertionConsumerServices {
+			if descriptor.AssertionConsumerServices[j].Binding == saml.HTTPPostBinding {
+				req.SPSSODescriptor = descriptor
+				req.ACSEndpoint = &descriptor.AssertionConsumerServices[j]
+				break
+			}
+		}
+		if req.ACSEndpoint != nil {
+			break
+		}
+	}
+	return s.issue(ctx, req, sp, userID, sessionID)
+}
+
+// GetServiceProvider 实现 saml.ServiceProviderProvider，按实体ID查找启用的服务提供方元数据
+func (s *SAMLIdentityProviderService) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	sp, err := s.serviceProvider(r.Context(), map[string]any{"entity_id": serviceProviderID})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrSAMLServiceProviderNotFound) || errors.Is(err, apperrors.ErrSAMLServiceProviderDisabled) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	return parseSAMLMetadata(sp.Metadata)
+}
+
+// issue 为用户生成签名断言，NameID 为用户标识，属性按服务提供方配置下发
+// 断言的 Issuer 与签名证书取自当前租户的身份提供方，不同租户签发的断言互不通用
+func (s *SAMLIdentityProviderService) issue(ctx context.Context, req *saml.IdpAuthnRequest, sp *oauthmodels.SAMLServiceProvider, userID uint, sessionID string) (*saml.IdpAuthnRequestForm, error) {
+	idp, err := s.identityProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.IDP = idp
+	if req.ACSEndpoint == nil || req.ACSEndpoint.Binding != saml.HTTPPostBinding {
+		s.logMgr.Warn("SAML 服务提供方没有可用的 HTTP-POST 断言消费地址", "entity_id", sp.EntityID)
+		return nil, apperrors.ErrSAMLRequestInvalid
+	}
+
+	user, err := s.userService.GetUser(ctx, map[string]any{"id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if err := services.CheckUserStatus(user); err != nil {
+		return nil, err
+	}
+	authz, err := s.rbacService.GetUserAuthorization(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 认证时间取登录会话的创建时间，会话索引不直接暴露会话标识
+	authnInstant := req.Now
+	if session, err := s.sessionRepository.Get(ctx, map[string]any{"session_id": sessionID}); err == nil {
+		authnInstant = session.CreatedAt
+	}
+	session := &saml.Session{
+		CreateTime:   authnInstant,
+		Index:        s.tokenHasher.Hash("saml:" + sessionID),
+		NameID:       user.Subject,
+		NameIDFormat: sp.NameIDFormat,
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		s.logMgr.Error("生成 SAML 断言失败", "error", err, "entity_id", sp.EntityID)
+		return nil, apperrors.ErrSAMLResponseFailed
+	}
+	// 默认实现会按服务提供方请求的属性填充，这里只保留配置允许下发的属性
+	req.Assertion.AttributeStatements = nil
+	if attributes := samlUserAttributes(user, authz.Roles, samlAttributes(sp)); len(attributes) > 0 {
+		req.Assertion.AttributeStatements = []saml.AttributeStatement{{Attributes: attributes}}
+	}
+
+	form, err := req.PostBinding()
+	if err != nil {
+		s.logMgr.Error("签名 SAML 响应失败", "error", err, "entity_id", sp.EntityID)
+		return nil, apperrors.ErrSAMLResponseFailed
+	}
+	s.logMgr.Info("签发 SAML 断言", "event", "saml.assertion_issued", "user_id", user.ID, "entity_id", sp.EntityID, "idp_initiated", req.Request.ID == "")
+	return &form, nil
+}
+
+// serviceProvider 获取启用的服务提供方
+func (s *SAMLIdentityProviderService) serviceProvider(ctx context.Context, conds map[string]any) (*oauthmodels.SAMLServiceProvider, error) {
+	sp, err := s.samlServiceProviderRepository.Get(ctx, conds)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrSAMLServiceProviderNotFound
+		}
+		s.logMgr.Error("获取SAML服务提供方失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if sp.Status != 1 {
+		return nil, apperrors.ErrSAMLServiceProviderDisabled
+	}
+	return sp, nil
+}
+
+// takeRequest 取出并作废暂存的认证请求
+func (s *SAMLIdentityProviderService) takeRequest(ctx context.Context, token string) (*samlPendingRequest, error) {
+	if token == "" {
+		return nil, apperrors.ErrSAMLRequestExpired
+	}
+	key := s.requestKey(ctx, token)
+	data, err := s.redisMgr.GetBytes(ctx, key)
+	if err != nil {
+		s.logMgr.Error("读取 SAML 认证请求失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if data == nil {
+		return nil, apperrors.ErrSAMLRequestExpired
+	}
+	n, err := s.redisMgr.Del(ctx, key)
+	if err != nil {
+		s.logMgr.Error("删除 SAML 认证请求失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return nil, apperrors.ErrSAMLRequestExpired
+	}
+
+	var pending samlPendingRequest
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, apperrors.ErrSAMLRequestExpired
+	}
+	return &pending, nil
+}
+
+// requestKey 暂存认证请求的键，带租户前缀，一个租户的请求凭据不能在其他租户换取断言
+func (s *SAMLIdentityProviderService) requestKey(ctx context.Context, token string) string {
+	return utils.TenantCacheKey(ctx, samlRequestKeyPrefix) + s.tokenHasher.Hash(token)
+}
+
+// samlUserAttributes 按属性配置取用户字段，值为空的属性不下发；角色为多值属性，每个角色一个值
+func samlUserAttributes(user *models.User, roles []string, configured []oauthmodels.SAMLAttribute) []saml.Attribute {
+	var attributes []saml.Attribute
+	for _, attribute := range configured {
+		var values []string
+		switch attribute.Source {
+		case oauthmodels.SAMLAttributeSourceSubject:
+			values = []string{user.Subject}
+		case oauthmodels.SAMLAttributeSourceUsername:
+			values = []string{user.Username}
+		case oauthmodels.SAMLAttributeSourceNickname:
+			values = []string{user.Nickname}
+		case oauthmodels.SAMLAttributeSourceEmail:
+			// 未验证的邮箱不能作为身份依据
+			if user.Email != nil && user.EmailVerifiedAt != nil {
+				values = []string{*user.Email}
+			}
+		case oauthmodels.SAMLAttributeSourceRole:
+			values = roles
+		}
+		if len(values) == 0 || values[0] == "" {
+			continue
+		}
+
+		nameFormat := "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"
+		if u, err := url.Parse(attribute.Name); err == nil && u.Scheme != "" {
+			nameFormat = "urn:oasis:names:tc:SAML:2.0:attrname-format:uri"
+		}
+		attributeValues := make([]saml.AttributeValue, len(values))
+		for i, value := range values {
+			attributeValues[i] = saml.AttributeValue{Type: "xs:string", Value: value}
+		}
+		attributes = append(attributes, saml.Attribute{
+			FriendlyName: attribute.FriendlyName,
+			Name:         attribute.Name,
+			NameFormat:   nameFormat,
+			Values:       attributeValues,
+		})
+	}
+	return attributes
+}
@@ -8,15 +8,27 @@ import (
 
 	"github.com/3086953492/gokit/jwt"
 	"github.com/3086953492/gokit/logger"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"goauth/apperrors"
 	oauthdto "goauth/dto/oauth"
+	"goauth/models"
 	oauthmodels "goauth/models/oauth"
 	oauthrepositories "goauth/repositories/oauth"
 	"goauth/services"
 	"goauth/utils"
 )
 
+// 授予后在访问令牌与用户信息中附带授权信息的范围
+const (
+	ScopeRoles  = "roles"  // 用户实际拥有的角色
+	ScopeGroups = "groups" // 用户所属的用户组
+)
+
+// ScopeOpenID 授予后在授权码换取令牌的响应中附带 ID Token
+const ScopeOpenID = "openid"
+
 type OAuthTokenService struct {
 	db *gorm.DB
 
@@ -26,10 +38,16 @@ type OAuthTokenService struct {
 	oauthAuthorizeService *OAuthAuthorizeService
 	oauthRevokeService    *OAuthRevokeService
 	userService           *services.UserService
+	rbacService           *services.RBACService
 
 	oauthClientService *OAuthClientService
+	tenantService      *services.TenantService
+	auditService       *services.AuditService
 
-	logMgr *logger.Manager
+	tokenHasher *utils.TokenHasher
+	logMgr      *logger.Manager
+
+	audience string
 }
 
 func NewOAuthTokenService(
@@ -39,8 +57,13 @@ func NewOAuthTokenService(
 	oauthAuthorizeService *OAuthAuthorizeService,
 	oauthRevokeService *OAuthRevokeService,
 	userService *services.UserService,
+	rbacService *services.RBACService,
 	oauthClientService *OAuthClientService,
+	tenantService *services.TenantService,
+	auditService *services.AuditService,
+	tokenHasher *utils.TokenHasher,
 	logMgr *logger.Manager,
+	audience string,
 ) *OAuthTokenService {
 	return &OAuthTokenService{
 		db:                          db,
@@ -49,9 +72,47 @@ func NewOAuthTokenService(
 		oauthAuthorizeService:       oauthAuthorizeService,
 		oauthRevokeService:          oauthRevokeService,
 		userService:                 userService,
+		rbacService:                 rbacService,
 		oauthClientService:          oauthClientService,
+		tenantService:               tenantService,
+		auditService:                auditService,
+		tokenHasher:                 tokenHasher,
 		logMgr:                      logMgr,
+		audience:                    audience,
+	}
+}
+
+// accessTokenExtra 构造访问令牌的扩展声明
+// stateless 模式下资源端不查库，依靠这些声明还原主体、校验受众并按 jti 判断撤销
+// 用户令牌授予 roles、groups 范围时附带签发时用户的角色与所属用户组
+func (s *OAuthTokenService) accessTokenExtra(ctx context.Context, jti, clientID, scope string, userID *uint) (map[string]any, error) {
+	extra := map[string]any{
+		"jti":                 jti,
+		"aud":                 s.audience,
+		"client_id":           clientID,
+		"scope":               scope,
+		utils.IssuedAtMsClaim: time.Now().UnixMilli(),
+	}
+	if userID == nil {
+		return extra, nil
+	}
+	extra["uid"] = *userID
+
+	if utils.HasScope(scope, ScopeRoles) {
+		authz, err := s.rbacService.GetUserAuthorization(ctx, *userID)
+		if err != nil {
+			return nil, err
+		}
+		extra["roles"] = authz.Roles
+	}
+	if utils.HasScope(scope, ScopeGroups) {
+		groups, err := s.rbacService.GroupNamesByUser(ctx, *userID)
+		if err != nil {
+			return nil, err
+		}
+		extra["groups"] = groups
 	}
+	return extra, nil
 }
 
 func (s *OAuthTokenService) accessTokenJwtManager(ctx context.Context, clientID string) *jwt.Manager {
@@ -66,7 +127,7 @@ func (s *OAuthTokenService) accessTokenJwtManager(ctx context.Context, clientID
 		return nil
 	}
 	jwtManager, err := jwt.NewManager(jwt.WithSecret(oauthClient.AccessTokenSecret),
-		jwt.WithIssuer(oauthClient.Name),
+		jwt.WithIssuer(s.tenantService.Issuer(ctx)),
 		jwt.WithAccessTTL(time.Duration(oauthClient.AccessTokenExpire)*time.Second))
 	if err != nil {
 		s.logMgr.Error("新建JWT管理器失败", "error", err)
@@ -87,7 +148,7 @@ func (s *OAuthTokenService) refreshTokenJwtManager(ctx context.Context, clientID
 		return nil
 	}
 	jwtManager, err := jwt.NewManager(jwt.WithSecret(oauthClient.RefreshTokenSecret),
-		jwt.WithIssuer(oauthClient.Name),
+		jwt.WithIssuer(s.tenantService.Issuer(ctx)),
 		jwt.WithRefreshTTL(time.Duration(oauthClient.RefreshTokenExpire)*time.Second))
 	if err != nil {
 		s.logMgr.Error("新建JWT管理器失败", "error", err)
@@ -96,18 +157,25 @@ func (s *OAuthTokenService) refreshTokenJwtManager(ctx context.Context, clientID
 	return jwtManager
 }
 
-func (s *OAuthTokenService) ExchangeAccessToken(ctx context.Context, form *oauthdto.ExchangeAccessTokenForm, clientID, clientSecret string) (*oauthdto.ExchangeAccessTokenResponse, error) {
+func (s *OAuthTokenService) ExchangeAccessToken(ctx context.Context, form *oauthdto.ExchangeAccessTokenForm, clientID, clientSecret string) (_ *oauthdto.ExchangeAccessTokenResponse, err error) {
+	event := &models.AuditEvent{ClientID: clientID}
+	var scope string
+	defer func() { s.auditIssue(ctx, event, "authorization_code", scope, err) }()
 
 	oauthClient, err := s.oauthClientService.GetOAuthClient(ctx, map[string]any{"id": clientID, "client_secret": clientSecret})
 	if err != nil {
 		return nil, err
 	}
 
+	if oauthClient.Status == 0 {
+		return nil, apperrors.ErrOAuthClientDisabled
+	}
+
 	if form.GrantType != "authorization_code" || !utils.IsGrantTypeValid("authorization_code", oauthClient.GrantTypes) {
 		return nil, errors.New("授权类型不支持")
 	}
 
-	oauthAuthorizationCode, err := s.oauthAuthorizeService.GetOAuthAuthorizationCode(ctx, map[string]any{"code": form.Code})
+	oauthAuthorizationCode, err := s.oauthAuthorizeService.GetOAuthAuthorizationCode(ctx, map[string]any{"code_hash": s.tokenHasher.Hash(form.Code)})
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +195,8 @@ func (s *OAuthTokenService) ExchangeAccessToken(ctx context.Context, form *oauth
 	if oauthAuthorizationCode.ClientID != clientID {
 		return nil, errors.New("授权码客户端ID不匹配")
 	}
+	event.UserID = &oauthAuthorizationCode.UserID
+	scope = oauthAuthorizationCode.Scope
 
 	user, err := s.userService.GetUser(ctx, map[string]any{"id": oauthAuthorizationCode.UserID})
 	if err != nil {
@@ -138,19 +208,34 @@ func (s *OAuthTokenService) ExchangeAccessToken(ctx context.Context, form *oauth
 		return nil, errors.New("系统繁忙，请稍后再试")
 	}
 
-	accessTokenString, err := jwtManager.GenerateAccessToken(user.Subject, map[string]any{})
+	jti := uuid.NewString()
+	extra, err := s.accessTokenExtra(ctx, jti, clientID, oauthAuthorizationCode.Scope, &oauthAuthorizationCode.UserID)
+	if err != nil {
+		return nil, err
+	}
+	accessTokenString, err := jwtManager.GenerateAccessToken(user.Subject, extra)
 	if err != nil {
 		s.logMgr.Error("生成访问令牌失败", "error", err)
 		return nil, errors.New("生成访问令牌失败")
 	}
 
 	accessToken := &oauthmodels.OAuthAccessToken{
-		AccessToken: accessTokenString,
-		TokenType:   "Bearer",
-		ExpiresAt:   time.Now().Add(time.Duration(oauthClient.AccessTokenExpire) * time.Second),
-		ClientID:    oauthAuthorizationCode.ClientID,
-		Scope:       oauthAuthorizationCode.Scope,
-		UserID:      &oauthAuthorizationCode.UserID,
+		TokenHash: s.tokenHasher.Hash(accessTokenString),
+		JTI:       jti,
+		TokenType: "Bearer",
+		ExpiresAt: time.Now().Add(time.Duration(oauthClient.AccessTokenExpire) * time.Second),
+		ClientID:  oauthAuthorizationCode.ClientID,
+		Scope:     oauthAuthorizationCode.Scope,
+		UserID:    &oauthAuthorizationCode.UserID,
+		SessionID: oauthAuthorizationCode.SessionID,
+	}
+
+	var idTokenString string
+	if utils.HasScope(oauthAuthorizationCode.Scope, ScopeOpenID) {
+		if idTokenString, err = s.idToken(ctx, oauthClient, user.Subject, oauthAuthorizationCode.SessionID, oauthAuthorizationCode.Nonce); err != nil {
+			s.logMgr.Error("生成ID令牌失败", "error", err)
+			return nil, errors.New("生成ID令牌失败")
+		}
 	}
 
 	// 用于在事务中保存 refresh token 字符串
@@ -171,7 +256,7 @@ func (s *OAuthTokenService) ExchangeAccessToken(ctx context.Context, form *oauth
 
 		// 在事务中生成并保存 refresh token（JWT sub 用 user.Subject，数据库存 userID）
 		var genErr error
-		refreshTokenString, genErr = s.GenerateRefreshTokenWithTx(ctx, tx, accessToken.ID, accessToken.ClientID, accessToken.Scope, oauthAuthorizationCode.UserID, user.Subject)
+		refreshTokenString, genErr = s.GenerateRefreshTokenWithTx(ctx, tx, accessToken.ID, accessToken.ClientID, accessToken.Scope, oauthAuthorizationCode.UserID, user.Subject, accessToken.SessionID)
 		if genErr != nil {
 			return genErr
 		}
@@ -194,23 +279,32 @@ func (s *OAuthTokenService) ExchangeAccessToken(ctx context.Context, form *oauth
 		},
 		Scope:     accessToken.Scope,
 		TokenType: "Bearer",
+		IDToken:   idTokenString,
 	}, nil
 }
 
-func (s *OAuthTokenService) RefreshAccessToken(ctx context.Context, form *oauthdto.RefreshAccessTokenForm, clientID, clientSecret string) (*oauthdto.ExchangeAccessTokenResponse, error) {
+func (s *OAuthTokenService) RefreshAccessToken(ctx context.Context, form *oauthdto.RefreshAccessTokenForm, clientID, clientSecret string) (_ *oauthdto.ExchangeAccessTokenResponse, err error) {
+	event := &models.AuditEvent{ClientID: clientID}
+	var scope string
+	defer func() { s.auditIssue(ctx, event, "refresh_token", scope, err) }()
+
 	// 校验客户端合法性
 	oauthClient, err := s.oauthClientService.GetOAuthClient(ctx, map[string]any{"id": clientID, "client_secret": clientSecret})
 	if err != nil {
 		return nil, err
 	}
 
+	if oauthClient.Status == 0 {
+		return nil, apperrors.ErrOAuthClientDisabled
+	}
+
 	// 校验客户端是否支持 refresh_token 授权类型
 	if !utils.IsGrantTypeValid("refresh_token", oauthClient.GrantTypes) {
 		return nil, errors.New("客户端不支持refresh_token授权类型")
 	}
 
 	// 查询刷新令牌
-	refreshToken, err := s.oauthRefreshTokenRepository.Get(ctx, map[string]any{"refresh_token": form.RefreshToken})
+	refreshToken, err := s.oauthRefreshTokenRepository.Get(ctx, map[string]any{"token_hash": s.tokenHasher.Hash(form.RefreshToken)})
 	if err != nil {
 		return nil, err
 	}
@@ -229,6 +323,8 @@ func (s *OAuthTokenService) RefreshAccessToken(ctx context.Context, form *oauthd
 	if refreshToken.ClientID != clientID {
 		return nil, errors.New("刷新令牌客户端ID不匹配")
 	}
+	event.UserID = &refreshToken.UserID
+	scope = refreshToken.Scope
 
 	// 查询用户信息
 	user, err := s.userService.GetUser(ctx, map[string]any{"id": refreshToken.UserID})
@@ -236,25 +332,42 @@ func (s *OAuthTokenService) RefreshAccessToken(ctx context.Context, form *oauthd
 		return nil, err
 	}
 
-	jwtManager := s.refreshTokenJwtManager(ctx, clientID)
-	if jwtManager == nil {
+	refreshJwtManager := s.refreshTokenJwtManager(ctx, clientID)
+	if refreshJwtManager == nil {
+		return nil, errors.New("系统繁忙，请稍后再试")
+	}
+
+	// 校验刷新令牌签名
+	if _, err := refreshJwtManager.ParseRefreshToken(form.RefreshToken); err != nil {
+		return nil, errors.New("无效的刷新令牌")
+	}
+
+	accessJwtManager := s.accessTokenJwtManager(ctx, clientID)
+	if accessJwtManager == nil {
 		return nil, errors.New("系统繁忙，请稍后再试")
 	}
 
 	// 生成新的访问令牌
-	accessTokenString, err := jwtManager.RefreshAccessToken(ctx, form.RefreshToken)
+	jti := uuid.NewString()
+	extra, err := s.accessTokenExtra(ctx, jti, clientID, refreshToken.Scope, &refreshToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+	accessTokenString, err := accessJwtManager.GenerateAccessToken(user.Subject, extra)
 	if err != nil {
 		s.logMgr.Error("刷新访问令牌失败", "error", err)
 		return nil, errors.New("刷新访问令牌失败")
 	}
 
 	accessToken := &oauthmodels.OAuthAccessToken{
-		AccessToken: accessTokenString,
-		TokenType:   "Bearer",
-		ExpiresAt:   time.Now().Add(time.Duration(oauthClient.AccessTokenExpire) * time.Second),
-		ClientID:    refreshToken.ClientID,
-		Scope:       refreshToken.Scope,
-		UserID:      &refreshToken.UserID,
+		TokenHash: s.tokenHasher.Hash(accessTokenString),
+		JTI:       jti,
+		TokenType: "Bearer",
+		ExpiresAt: time.Now().Add(time.Duration(oauthClient.AccessTokenExpire) * time.Second),
+		ClientID:  refreshToken.ClientID,
+		Scope:     refreshToken.Scope,
+		UserID:    &refreshToken.UserID,
+		SessionID: refreshToken.SessionID,
 	}
 
 	// 用于在事务中保存新的 refresh token 字符串
@@ -269,13 +382,13 @@ func (s *OAuthTokenService) RefreshAccessToken(ctx context.Context, form *oauthd
 		}
 
 		// 在事务中撤销旧的 refresh token
-		if err := s.oauthRevokeService.RevokeToken(ctx, refreshToken.RefreshToken, "refresh_token", refreshToken.ClientID); err != nil {
+		if err := s.oauthRevokeService.revokeToken(ctx, form.RefreshToken, "refresh_token", refreshToken.ClientID, false); err != nil {
 			return err
 		}
 
 		// 在事务中生成新的 refresh token（JWT sub 用 user.Subject，数据库存 userID）
 		var genErr error
-		newRefreshTokenString, genErr = s.GenerateRefreshTokenWithTx(ctx, tx, accessToken.ID, refreshToken.ClientID, refreshToken.Scope, refreshToken.UserID, user.Subject)
+		newRefreshTokenString, genErr = s.GenerateRefreshTokenWithTx(ctx, tx, accessToken.ID, refreshToken.ClientID, refreshToken.Scope, refreshToken.UserID, user.Subject, refreshToken.SessionID)
 		if genErr != nil {
 			return genErr
 		}
@@ -301,7 +414,7 @@ func (s *OAuthTokenService) RefreshAccessToken(ctx context.Context, form *oauthd
 	}, nil
 }
 
-func (s *OAuthTokenService) GenerateRefreshToken(ctx context.Context, accessTokenID uint, clientID string, scope string, userID uint, subject string) (string, error) {
+func (s *OAuthTokenService) GenerateRefreshToken(ctx context.Context, accessTokenID uint, clientID string, scope string, userID uint, subject string, sessionID string) (string, error) {
 	jwtManager := s.refreshTokenJwtManager(ctx, clientID)
 	if jwtManager == nil {
 		return "", errors.New("系统繁忙，请稍后再试")
@@ -320,11 +433,12 @@ func (s *OAuthTokenService) GenerateRefreshToken(ctx context.Context, accessToke
 	}
 
 	refreshToken := &oauthmodels.OAuthRefreshToken{
-		RefreshToken:  refreshTokenString,
+		TokenHash:     s.tokenHasher.Hash(refreshTokenString),
 		AccessTokenID: accessTokenID,
 		ClientID:      clientID,
 		Scope:         scope,
 		UserID:        userID, // 数据库仍存 userID（对内用主键）
+		SessionID:     sessionID,
 		ExpiresAt:     time.Now().Add(time.Duration(oauthClient.RefreshTokenExpire) * time.Second),
 	}
 
@@ -337,7 +451,7 @@ func (s *OAuthTokenService) GenerateRefreshToken(ctx context.Context, accessToke
 }
 
 // GenerateRefreshTokenWithTx 在事务中生成并保存刷新令牌
-func (s *OAuthTokenService) GenerateRefreshTokenWithTx(ctx context.Context, tx *gorm.DB, accessTokenID uint, clientID string, scope string, userID uint, subject string) (string, error) {
+func (s *OAuthTokenService) GenerateRefreshTokenWithTx(ctx context.Context, tx *gorm.DB, accessTokenID uint, clientID string, scope string, userID uint, subject string, sessionID string) (string, error) {
 	jwtManager := s.refreshTokenJwtManager(ctx, clientID)
 	if jwtManager == nil {
 		return "", errors.New("系统繁忙，请稍后再试")
@@ -356,11 +470,12 @@ func (s *OAuthTokenService) GenerateRefreshTokenWithTx(ctx context.Context, tx *
 	}
 
 	refreshToken := &oauthmodels.OAuthRefreshToken{
-		RefreshToken:  refreshTokenString,
+		TokenHash:     s.tokenHasher.Hash(refreshTokenString),
 		AccessTokenID: accessTokenID,
 		ClientID:      clientID,
 		Scope:         scope,
 		UserID:        userID, // 数据库仍存 userID（对内用主键）
+		SessionID:     sessionID,
 		ExpiresAt:     time.Now().Add(time.Duration(oauthClient.RefreshTokenExpire) * time.Second),
 	}
 
@@ -386,13 +501,21 @@ func (s *OAuthTokenService) GetOAuthRefreshToken(ctx context.Context, conds map[
 }
 
 // IssueClientCredentialsAccessToken 客户端凭证模式签发 access token（不签发 refresh token）
-func (s *OAuthTokenService) IssueClientCredentialsAccessToken(ctx context.Context, form *oauthdto.ClientCredentialsAccessTokenForm, clientID, clientSecret string) (*oauthdto.ClientCredentialsAccessTokenResponse, error) {
+func (s *OAuthTokenService) IssueClientCredentialsAccessToken(ctx context.Context, form *oauthdto.ClientCredentialsAccessTokenForm, clientID, clientSecret string) (_ *oauthdto.ClientCredentialsAccessTokenResponse, err error) {
+	defer func() {
+		s.auditIssue(ctx, &models.AuditEvent{ClientID: clientID}, "client_credentials", form.Scope, err)
+	}()
+
 	// 校验客户端凭证
 	oauthClient, err := s.oauthClientService.GetOAuthClient(ctx, map[string]any{"id": clientID, "client_secret": clientSecret})
 	if err != nil {
 		return nil, err
 	}
 
+	if oauthClient.Status == 0 {
+		return nil, apperrors.ErrOAuthClientDisabled
+	}
+
 	// 校验客户端是否支持 client_credentials 授权类型
 	if !utils.IsGrantTypeValid("client_credentials", oauthClient.GrantTypes) {
 		return nil, errors.New("客户端不支持client_credentials授权类型")
@@ -411,7 +534,12 @@ func (s *OAuthTokenService) IssueClientCredentialsAccessToken(ctx context.Contex
 
 	// 生成 access token，sub 使用 "client:<client_id>"
 	subject := "client:" + clientID
-	accessTokenString, err := jwtManager.GenerateAccessToken(subject, map[string]any{})
+	jti := uuid.NewString()
+	extra, err := s.accessTokenExtra(ctx, jti, clientID, form.Scope, nil)
+	if err != nil {
+		return nil, err
+	}
+	accessTokenString, err := jwtManager.GenerateAccessToken(subject, extra)
 	if err != nil {
 		s.logMgr.Error("生成访问令牌失败", "error", err)
 		return nil, errors.New("生成访问令牌失败")
@@ -419,12 +547,13 @@ func (s *OAuthTokenService) IssueClientCredentialsAccessToken(ctx context.Contex
 
 	// 构建 access token 模型（UserID 为空）
 	accessToken := &oauthmodels.OAuthAccessToken{
-		AccessToken: accessTokenString,
-		TokenType:   "Bearer",
-		ExpiresAt:   time.Now().Add(time.Duration(oauthClient.AccessTokenExpire) * time.Second),
-		ClientID:    clientID,
-		Scope:       form.Scope,
-		UserID:      nil, // 客户端凭证模式无用户
+		TokenHash: s.tokenHasher.Hash(accessTokenString),
+		JTI:       jti,
+		TokenType: "Bearer",
+		ExpiresAt: time.Now().Add(time.Duration(oauthClient.AccessTokenExpire) * time.Second),
+		ClientID:  clientID,
+		Scope:     form.Scope,
+		UserID:    nil, // 客户端凭证模式无用户
 	}
 
 	// 落库
@@ -440,3 +569,13 @@ func (s *OAuthTokenService) IssueClientCredentialsAccessToken(ctx context.Contex
 		Scope:       form.Scope,
 	}, nil
 }
+
+// auditIssue 记录令牌签发事件；客户端认证通过前的失败无法确认调用方，操作者记为匿名
+func (s *OAuthTokenService) auditIssue(ctx context.Context, event *models.AuditEvent, grantType, scope string, err error) {
+	event.Type = models.AuditTokenIssue
+	if err == nil {
+		event.ActorType = models.AuditActorClient
+	}
+	event.Details = services.AuditDetails(map[string]any{"grant_type": grantType, "scope": scope})
+	s.auditService.Record(ctx, event, err)
+}
@@ -0,0 +1,36 @@
+package oauthservices
+
+import (
+	"context"
+	"time"
+
+	"github.com/3086953492/gokit/redis"
+)
+
+// denylistKeyPrefix 黑名单 key 前缀，完整 key 为 oauth:denylist:<jti>
+const denylistKeyPrefix = "oauth:denylist:"
+
+// OAuthTokenDenylist 基于 Redis 的访问令牌黑名单（按 jti）
+// stateless 模式下资源端不查库，撤销状态只能通过黑名单判断
+type OAuthTokenDenylist struct {
+	redisMgr *redis.Manager
+}
+
+// NewOAuthTokenDenylist 创建访问令牌黑名单实例
+func NewOAuthTokenDenylist(redisMgr *redis.Manager) *OAuthTokenDenylist {
+	return &OAuthTokenDenylist{redisMgr: redisMgr}
+}
+
+// Add 将 jti 加入黑名单，TTL 与令牌剩余有效期一致，令牌过期后自动移除
+func (d *OAuthTokenDenylist) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return d.redisMgr.SetBytes(ctx, denylistKeyPrefix+jti, []byte("1"), ttl)
+}
+
+// Contains 判断 jti 是否已被撤销
+func (d *OAuthTokenDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	return d.redisMgr.Exists(ctx, denylistKeyPrefix+jti)
+}
@@ -5,26 +5,42 @@ import (
 
 	"goauth/dto/oauth"
 	"goauth/services"
+	"goauth/utils"
 )
 
 type OAuthUserInfoService struct {
 	userService *services.UserService
+	rbacService *services.RBACService
 }
 
-func NewOAuthUserInfoService(userService *services.UserService) *OAuthUserInfoService {
-	return &OAuthUserInfoService{userService: userService}
+func NewOAuthUserInfoService(userService *services.UserService, rbacService *services.RBACService) *OAuthUserInfoService {
+	return &OAuthUserInfoService{userService: userService, rbacService: rbacService}
 }
 
-func (s *OAuthUserInfoService) GetUserInfo(ctx context.Context, username string) *oauthdto.UserInfoResponse {
+// GetUserInfo 获取令牌对应用户的信息，授予 roles、groups 范围时附带角色与所属用户组
+func (s *OAuthUserInfoService) GetUserInfo(ctx context.Context, username, scope string) *oauthdto.UserInfoResponse {
 	user, err := s.userService.GetUser(ctx, map[string]any{"username": username})
 	if err != nil {
 		return nil
 	}
 
-	return &oauthdto.UserInfoResponse{
+	userInfo := &oauthdto.UserInfoResponse{
 		Sub:       user.Subject,
 		Nickname:  user.Nickname,
 		Picture:   user.Avatar,
 		UpdatedAt: user.UpdatedAt.Unix(),
 	}
+	if utils.HasScope(scope, ScopeRoles) {
+		authz, err := s.rbacService.GetUserAuthorization(ctx, user.ID)
+		if err != nil {
+			return nil
+		}
+		userInfo.Roles = authz.Roles
+	}
+	if utils.HasScope(scope, ScopeGroups) {
+		if userInfo.Groups, err = s.rbacService.GroupNamesByUser(ctx, user.ID); err != nil {
+			return nil
+		}
+	}
+	return userInfo
 }
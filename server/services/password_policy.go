@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/security/password"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// bcryptMaxPasswordBytes bcrypt 只处理密码的前 72 字节，超出部分会被拒绝
+const bcryptMaxPasswordBytes = 72
+
+// userInfoMinLength 用户名、昵称短于该长度时不参与包含检查，避免单个字符的昵称误伤正常密码
+const userInfoMinLength = 3
+
+// PasswordPolicy 密码策略
+// 长度、字符类别与泄露检查在表单验证时执行，密码历史与用户信息检查在修改密码时由用户服务执行
+type PasswordPolicy struct {
+	passwordHistoryRepository *repositories.PasswordHistoryRepository
+	passwordMgr               *password.Manager
+	breachedChecker           *utils.BreachedPasswordChecker
+	logMgr                    *logger.Manager
+	cfg                       appconfig.PasswordPolicyConfig
+}
+
+// NewPasswordPolicy 创建密码策略实例，breachedChecker 为 nil 时不检查泄露密码
+func NewPasswordPolicy(passwordHistoryRepository *repositories.PasswordHistoryRepository, passwordMgr *password.Manager, breachedChecker *utils.BreachedPasswordChecker, logMgr *logger.Manager, cfg appconfig.PasswordPolicyConfig) *PasswordPolicy {
+	return &PasswordPolicy{passwordHistoryRepository: passwordHistoryRepository, passwordMgr: passwordMgr, breachedChecker: breachedChecker, logMgr: logMgr, cfg: cfg}
+}
+
+// Describe 返回面向用户的策略说明，用作表单验证失败的提示
+func (p *PasswordPolicy) Describe() string {
+	desc := fmt.Sprintf("长度须为 %d-%d 位", p.cfg.MinLength, p.cfg.MaxLength)
+	if p.cfg.MinCharClasses > 1 {
+		desc += fmt.Sprintf("，至少包含大写字母、小写字母、数字、符号中的 %d 类", p.cfg.MinCharClasses)
+	}
+	if p.cfg.DisallowUserInfo {
+		desc += "，且不能包含用户名或昵称"
+	}
+	return desc
+}
+
+// Validate 校验长度、字符类别，以及密码是否包含 userInfo 中的用户名、昵称等信息
+func (p *PasswordPolicy) Validate(pwd string, userInfo ...string) error {
+	length := utf8.RuneCountInString(pwd)
+	if length < p.cfg.MinLength {
+		return fmt.Errorf("%w，至少 %d 位", apperrors.ErrPasswordTooShort, p.cfg.MinLength)
+	}
+	if length > p.cfg.MaxLength || len(pwd) > bcryptMaxPasswordBytes {
+		return fmt.Errorf("%w，最多 %d 位", apperrors.ErrPasswordTooLong, p.cfg.MaxLength)
+	}
+	if passwordCharClasses(pwd) < p.cfg.MinCharClasses {
+		return fmt.Errorf("%w，至少包含大写字母、小写字母、数字、符号中的 %d 类", apperrors.ErrPasswordTooWeak, p.cfg.MinCharClasses)
+	}
+
+	if p.cfg.DisallowUserInfo {
+		lower := strings.ToLower(pwd)
+		for _, info := range userInfo {
+			info = strings.ToLower(strings.TrimSpace(info))
+			if utf8.RuneCountInString(info) >= userInfoMinLength && strings.Contains(lower, info) {
+				return apperrors.ErrPasswordContainsUserInfo
+			}
+		}
+	}
+	return nil
+}
+
+// Breached 判断密码是否在泄露密码列表中，未启用检查时始终返回 false
+func (p *PasswordPolicy) Breached(pwd string) bool {
+	return p.breachedChecker != nil && p.breachedChecker.Breached(pwd)
+}
+
+// CheckReuse 校验新密码与当前密码及最近的历史密码均不相同
+func (p *PasswordPolicy) CheckReuse(ctx context.Context, user *models.User, pwd string) error {
+	if p.cfg.HistoryCount <= 0 {
+		return nil
+	}
+
+	hashes := []string{user.Password}
+	if p.cfg.HistoryCount > 1 {
+		histories, err := p.passwordHistoryRepository.FindRecent(ctx, user.ID, p.cfg.HistoryCount-1)
+		if err != nil {
+			p.logMgr.Error("获取密码历史失败", "error", err, "user_id", user.ID)
+			return apperrors.ErrUserSystemBusy
+		}
+		for _, history := range histories {
+			hashes = append(hashes, history.PasswordHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		err := p.passwordMgr.Compare(hash, pwd)
+		if err == nil {
+			return apperrors.ErrPasswordReused
+		}
+		if !errors.Is(err, password.ErrMismatch) {
+			p.logMgr.Error("比对历史密码失败", "error", err, "user_id", user.ID)
+			return apperrors.ErrUserSystemBusy
+		}
+	}
+	return nil
+}
+
+// RecordWithTx 在修改密码的事务中写入被替换的旧密码，并清理超出保留条数的历史
+func (p *PasswordPolicy) RecordWithTx(ctx context.Context, tx *gorm.DB, userID uint, oldHash string) error {
+	// 当前密码本身占一条，历史表只需保留 HistoryCount-1 条
+	keep := p.cfg.HistoryCount - 1
+	if keep > 0 && oldHash != "" {
+		if err := p.passwordHistoryRepository.CreateWithTx(ctx, tx, &models.UserPasswordHistory{UserID: userID, PasswordHash: oldHash}); err != nil {
+			return err
+		}
+	}
+	return p.passwordHistoryRepository.PruneWithTx(ctx, tx, userID, max(keep, 0))
+}
+
+// Expired 判断用户密码是否已超过最长使用期限，目录账号的密码期限由目录管理
+func (p *PasswordPolicy) Expired(user *models.User) bool {
+	if p.cfg.MaxAge <= 0 || user.AuthSource != models.UserAuthSourceLocal {
+		return false
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > p.cfg.MaxAge
+}
+
+// passwordCharClasses 统计密码包含的字符类别数，大小写字母与数字以外的字符都算作符号
+func passwordCharClasses(pwd string) int {
+	var upper, lower, digit, symbol int
+	for _, r := range pwd {
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return upper + lower + digit + symbol
+}
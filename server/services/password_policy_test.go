@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3086953492/gokit/security/password"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := NewPasswordPolicy(nil, nil, nil, newTestLogger(t), appconfig.PasswordPolicyConfig{MinLength: 8, MaxLength: 64, MinCharClasses: 3, DisallowUserInfo: true})
+
+	tests := []struct {
+		name     string
+		password string
+		userInfo []string
+		want     error
+	}{
+		{name: "三类字符", password: "Passw0rdly"},
+		{name: "四类字符", password: "Pa$$w0rd"},
+		{name: "过短", password: "Pa$w0rd", want: apperrors.ErrPasswordTooShort},
+		{name: "按字符计长度", password: "密码密码Ab1", want: apperrors.ErrPasswordTooShort},
+		{name: "过长", password: "Aa1" + strings.Repeat("x", 62), want: apperrors.ErrPasswordTooLong},
+		{name: "超过 bcrypt 字节上限", password: "Aa1" + strings.Repeat("密", 24), want: apperrors.ErrPasswordTooLong},
+		{name: "只有两类字符", password: "password123", want: apperrors.ErrPasswordTooWeak},
+		{name: "只有一类字符", password: "abcdefghij", want: apperrors.ErrPasswordTooWeak},
+		{name: "中文算作符号", password: "密码Passw0rd"},
+		{name: "包含用户名", password: "Alice2024!", userInfo: []string{"alice", "Alice W"}, want: apperrors.ErrPasswordContainsUserInfo},
+		{name: "包含昵称", password: "xWonderland1", userInfo: []string{"alice", " wonderland "}, want: apperrors.ErrPasswordContainsUserInfo},
+		{name: "过短的昵称不参与检查", password: "Alb3rtAl!", userInfo: []string{"al"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.userInfo...)
+			if tt.want == nil && err != nil {
+				t.Fatalf("Validate(%q) = %v，期望通过", tt.password, err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Validate(%q) = %v，期望 %v", tt.password, err, tt.want)
+			}
+		})
+	}
+
+	// 未开启用户信息检查时允许包含用户名
+	policy = NewPasswordPolicy(nil, nil, nil, newTestLogger(t), appconfig.PasswordPolicyConfig{MinLength: 8, MaxLength: 64, MinCharClasses: 3})
+	if err := policy.Validate("Alice2024!", "alice"); err != nil {
+		t.Fatalf("未开启用户信息检查时 Validate = %v，期望通过", err)
+	}
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+	db := newTestDB(t, &models.UserPasswordHistory{})
+	passwordMgr, err := password.NewManager(password.WithCost(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := NewPasswordPolicy(repositories.NewPasswordHistoryRepository(db), passwordMgr, nil, newTestLogger(t), appconfig.PasswordPolicyConfig{MinLength: 8, MaxLength: 64, HistoryCount: 3})
+	ctx := context.Background()
+
+	// 依次改用 p0 到 p4，每次修改把被替换的旧密码写入历史
+	passwords := []string{"Passw0rd-0", "Passw0rd-1", "Passw0rd-2", "Passw0rd-3", "Passw0rd-4"}
+	user := &models.User{ID: 1}
+	for _, pwd := range passwords {
+		hash, err := passwordMgr.Hash(pwd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := policy.RecordWithTx(ctx, db, user.ID, user.Password); err != nil {
+			t.Fatalf("写入密码历史失败: %v", err)
+		}
+		user.Password = hash
+	}
+
+	// 当前密码占一条，历史表只保留最近 2 条
+	var count int64
+	if err := db.Model(&models.UserPasswordHistory{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("密码历史条数 = %d，期望 HistoryCount-1 = 2", count)
+	}
+
+	for i, pwd := range passwords {
+		err := policy.CheckReuse(ctx, user, pwd)
+		if i >= 2 && !errors.Is(err, apperrors.ErrPasswordReused) {
+			t.Fatalf("CheckReuse(%q) = %v，最近 3 个密码不能重复使用", pwd, err)
+		}
+		if i < 2 && err != nil {
+			t.Fatalf("CheckReuse(%q) = %v，超出历史条数的旧密码应允许使用", pwd, err)
+		}
+	}
+	if err := policy.CheckReuse(ctx, user, "Brand-new-1"); err != nil {
+		t.Fatalf("CheckReuse 新密码 = %v，期望通过", err)
+	}
+
+	// 其他用户的历史互不影响
+	if err := policy.CheckReuse(ctx, &models.User{ID: 2, Password: user.Password}, passwords[2]); err != nil {
+		t.Fatalf("其他用户 CheckReuse = %v，不应读取别人的密码历史", err)
+	}
+}
+
+func TestPasswordPolicyExpired(t *testing.T) {
+	policy := NewPasswordPolicy(nil, nil, nil, newTestLogger(t), appconfig.PasswordPolicyConfig{MaxAge: 90 * 24 * time.Hour})
+	old := time.Now().Add(-91 * 24 * time.Hour)
+	recent := time.Now().Add(-24 * time.Hour)
+
+	if !policy.Expired(&models.User{AuthSource: models.UserAuthSourceLocal, CreatedAt: old}) {
+		t.Fatal("从未改过且超过期限的密码应过期")
+	}
+	if policy.Expired(&models.User{AuthSource: models.UserAuthSourceLocal, CreatedAt: old, PasswordChangedAt: &recent}) {
+		t.Fatal("最近改过的密码不应过期")
+	}
+	if policy.Expired(&models.User{AuthSource: models.UserAuthSourceLDAP, CreatedAt: old}) {
+		t.Fatal("目录账号的密码期限由目录管理")
+	}
+}
+
+func TestBreachedPasswordChecker(t *testing.T) {
+	// 额外列表支持注释、空行与 "摘要:次数" 格式
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	sha1Hex := func(pwd string) string {
+		digest := sha1.Sum([]byte(pwd))
+		return strings.ToUpper(hex.EncodeToString(digest[:]))
+	}
+	extra := "# 自定义列表\n\n" + sha1Hex("Tr0ub4dor&3") + ":42\n" + strings.ToLower(sha1Hex("correct horse battery staple")) + "\n"
+	if err := os.WriteFile(path, []byte(extra), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := utils.NewBreachedPasswordChecker(path)
+	if err != nil {
+		t.Fatalf("载入泄露密码列表失败: %v", err)
+	}
+
+	for _, pwd := range []string{"password", "123456", "qwerty", "iloveyou", "Tr0ub4dor&3", "correct horse battery staple"} {
+		if !checker.Breached(pwd) {
+			t.Fatalf("Breached(%q) = false，列表中的密码不能漏判", pwd)
+		}
+	}
+	if checker.Breached("Password") {
+		t.Fatal("摘要区分大小写，Password 不在列表中")
+	}
+
+	policy := NewPasswordPolicy(nil, nil, checker, newTestLogger(t), appconfig.PasswordPolicyConfig{})
+	if !policy.Breached("password") || policy.Breached("kX9#vQ2!mZ7@") {
+		t.Fatal("密码策略的泄露检查结果与列表不一致")
+	}
+	if NewPasswordPolicy(nil, nil, nil, newTestLogger(t), appconfig.PasswordPolicyConfig{}).Breached("password") {
+		t.Fatal("未启用泄露检查时不应判定为泄露")
+	}
+
+	if err := os.WriteFile(path, []byte("not-a-digest\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.NewBreachedPasswordChecker(path); err == nil {
+		t.Fatal("无效的摘要行应返回错误")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10000
+	filter := utils.NewBloomFilter(n, 0.001)
+	digest := func(prefix string, i int) []byte {
+		d := sha1.Sum([]byte(prefix + strconv.Itoa(i)))
+		return d[:]
+	}
+	for i := 0; i < n; i++ {
+		filter.Add(digest("member-", i))
+	}
+
+	// 加入过的元素一定判定为存在
+	for i := 0; i < n; i++ {
+		if !filter.Test(digest("member-", i)) {
+			t.Fatalf("第 %d 个元素漏判，布隆过滤器不能有假阴性", i)
+		}
+	}
+
+	// 未加入的元素误判率应在设定值附近
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if filter.Test(digest("absent-", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 0.005 {
+		t.Fatalf("误判率 = %.4f，远高于设定的 0.001", rate)
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+)
+
+// PolicySubject 参与授权判断的主体与请求信息，由鉴权中间件从认证结果构造
+type PolicySubject struct {
+	Kind        string // user 或 client
+	UserID      uint64
+	ClientID    string // 仅 Bearer 认证有值
+	Scope       string // 空格分隔，仅 Bearer 认证有值
+	Roles       []string
+	Permissions []string
+	PathUserID  uint64 // 路径参数 user_id，缺失或格式错误时为 0
+	QueryUserID uint64 // 查询参数 user_id，缺失或格式错误时为 0
+}
+
+// PolicyRoute 已注册的路由，Path 为路由模板
+type PolicyRoute struct {
+	Method string
+	Path   string
+}
+
+// PolicyEngine 接口授权策略引擎，按配置把路由绑定到策略并判断主体能否访问
+type PolicyEngine struct {
+	config      appconfig.AuthorizationConfig
+	rbacService *RBACService
+
+	bindings map[string]string // "方法 路由模板" -> 策略名
+	routes   []PolicyRoute     // 已绑定策略的路由，按注册顺序
+}
+
+// NewPolicyEngine 创建授权策略引擎实例，须在路由注册完成后调用 BindRoutes
+func NewPolicyEngine(config appconfig.AuthorizationConfig, rbacService *RBACService) *PolicyEngine {
+	return &PolicyEngine{config: config, rbacService: rbacService, bindings: make(map[string]string)}
+}
+
+// BindRoutes 把策略中的路由模式解析到已注册的路由上
+// 同一路由命中多个模式时，方法与路径都精确匹配的优先，其次是路径精确匹配，最后是最长的前缀匹配；
+// 没有命中任何路由的模式视为配置错误，避免策略因拼写错误而悄悄失效
+func (e *PolicyEngine) BindRoutes(routes []PolicyRoute) error {
+	type binding struct {
+		policy string
+		rank   int
+	}
+	best := make(map[string]binding)
+	var unmatched []string
+
+	for name, policy := range e.config.Policies {
+		for _, pattern := range policy.Routes {
+			method, path, _ := strings.Cut(pattern, " ")
+			matched := false
+			for _, route := range routes {
+				rank, ok := matchRoute(method, path, route)
+				if !ok {
+					continue
+				}
+				matched = true
+				key := route.Method + " " + route.Path
+				if current, exists := best[key]; !exists || rank > current.rank {
+					best[key] = binding{policy: name, rank: rank}
+				}
+			}
+			if !matched {
+				unmatched = append(unmatched, fmt.Sprintf("%s(%s)", pattern, name))
+			}
+		}
+	}
+	if len(unmatched) > 0 {
+		sort.Strings(unmatched)
+		return fmt.Errorf("授权策略中的路由未匹配到任何已注册路由: %s", strings.Join(unmatched, ", "))
+	}
+
+	e.routes = e.routes[:0]
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if b, ok := best[key]; ok {
+			e.bindings[key] = b.policy
+			e.routes = append(e.routes, route)
+		}
+	}
+	return nil
+}
+
+// matchRoute 判断路由模式能否匹配路由，返回匹配的优先级
+func matchRoute(method, path string, route PolicyRoute) (int, bool) {
+	if method != "*" && !strings.EqualFold(method, route.Method) {
+		return 0, false
+	}
+	rank := 0
+	if method != "*" {
+		rank = 1
+	}
+	if prefix, ok := strings.CutSuffix(path, "*"); ok {
+		if !strings.HasPrefix(route.Path, prefix) {
+			return 0, false
+		}
+		// 前缀越长越具体，精确匹配的优先级总高于前缀匹配
+		return len(prefix)*2 + rank, true
+	}
+	if path != route.Path {
+		return 0, false
+	}
+	return 1<<20 + rank, true
+}
+
+// PolicyFor 查找路由绑定的策略名
+func (e *PolicyEngine) PolicyFor(method, path string) (string, bool) {
+	name, ok := e.bindings[method+" "+path]
+	return name, ok
+}
+
+// Evaluate 按策略判断主体能否访问，记录每条规则、每个条件的判断过程；策略不存在时拒绝
+func (e *PolicyEngine) Evaluate(name string, subject PolicySubject) dto.PolicyDecision {
+	policy := e.config.Policies[name]
+	decision := dto.PolicyDecision{
+		Policy:      name,
+		Enforced:    e.config.Mode == appconfig.AuthorizationModeEnforce && !policy.DryRun,
+		MatchedRule: -1,
+		Rules:       make([]dto.PolicyRuleResult, len(policy.Rules)),
+	}
+	for i, rule := range policy.Rules {
+		result := evaluateRule(rule, subject)
+		result.Index = i
+		decision.Rules[i] = result
+		if result.Passed && !decision.Allowed {
+			decision.Allowed = true
+			decision.MatchedRule = i
+		}
+	}
+	return decision
+}
+
+// evaluateRule 逐个判断规则中已配置的条件
+func evaluateRule(rule appconfig.PolicyRule, subject PolicySubject) dto.PolicyRuleResult {
+	var conditions []dto.PolicyConditionResult
+	add := func(name, expected, actual string, passed bool) {
+		conditions = append(conditions, dto.PolicyConditionResult{Name: name, Expected: expected, Actual: actual, Passed: passed})
+	}
+
+	if rule.Principal != "" {
+		add("principal", rule.Principal, subject.Kind, subject.Kind == rule.Principal)
+	}
+	if len(rule.Scopes) > 0 {
+		// Cookie 登录不是经由 OAuth 授权的访问，不受范围限制
+		if subject.ClientID == "" {
+			add("scopes", strings.Join(rule.Scopes, " "), "Cookie 登录不受范围限制", true)
+		} else {
+			granted := strings.Fields(subject.Scope)
+			passed := true
+			for _, scope := range rule.Scopes {
+				if !slices.Contains(granted, scope) {
+					passed = false
+					break
+				}
+			}
+			add("scopes", strings.Join(rule.Scopes, " "), subject.Scope, passed)
+		}
+	}
+	if len(rule.Roles) > 0 {
+		passed := slices.ContainsFunc(rule.Roles, func(role string) bool { return slices.Contains(subject.Roles, role) })
+		add("roles", "任一 "+strings.Join(rule.Roles, ", "), strings.Join(subject.Roles, ", "), passed)
+	}
+	if len(rule.Permissions) > 0 {
+		passed := true
+		for _, permission := range rule.Permissions {
+			if !slices.Contains(subject.Permissions, permission) {
+				passed = false
+				break
+			}
+		}
+		add("permissions", "全部 "+strings.Join(rule.Permissions, ", "), strings.Join(subject.Permissions, ", "), passed)
+	}
+	if rule.Owner != "" {
+		target := subject.PathUserID
+		if rule.Owner == appconfig.PolicyOwnerQuery {
+			target = subject.QueryUserID
+		}
+		passed := subject.Kind == appconfig.PolicyPrincipalUser && subject.UserID != 0 && subject.UserID == target
+		add("owner", rule.Owner+" user_id="+strconv.FormatUint(target, 10), "user_id="+strconv.FormatUint(subject.UserID, 10), passed)
+	}
+	if len(rule.ClientIDs) > 0 {
+		add("client_ids", "任一 "+strings.Join(rule.ClientIDs, ", "), subject.ClientID, slices.Contains(rule.ClientIDs, subject.ClientID))
+	}
+
+	result := dto.PolicyRuleResult{Passed: true, Conditions: conditions}
+	for _, condition := range conditions {
+		if !condition.Passed {
+			result.Passed = false
+			break
+		}
+	}
+	return result
+}
+
+// Explain 解释主体访问某条策略或路由的授权决策，供安全审查核对配置
+func (e *PolicyEngine) Explain(ctx context.Context, req *dto.PolicyExplainRequest) (*dto.PolicyDecision, error) {
+	name := req.Policy
+	if name == "" {
+		var ok bool
+		if name, ok = e.PolicyFor(strings.ToUpper(req.Method), req.Path); !ok {
+			return nil, apperrors.ErrPolicyRouteNotFound
+		}
+	} else if _, ok := e.config.Policies[name]; !ok {
+		return nil, apperrors.ErrPolicyNotFound
+	}
+
+	subject := PolicySubject{
+		Kind:        req.Principal,
+		UserID:      req.UserID,
+		ClientID:    req.ClientID,
+		Scope:       req.Scope,
+		Roles:       []string{},
+		Permissions: []string{},
+		PathUserID:  req.PathUserID,
+		QueryUserID: req.QueryUserID,
+	}
+	// 与鉴权中间件一致：只有 Cookie 登录的用户带有角色与权限
+	if req.Principal == appconfig.PolicyPrincipalUser && req.ClientID == "" {
+		if req.Roles == nil && req.Permissions == nil && req.UserID != 0 {
+			authz, err := e.rbacService.GetUserAuthorization(ctx, uint(req.UserID))
+			if err != nil {
+				return nil, err
+			}
+			subject.Roles, subject.Permissions = authz.Roles, authz.Permissions
+		} else {
+			if req.Roles != nil {
+				subject.Roles = *req.Roles
+			}
+			if req.Permissions != nil {
+				subject.Permissions = *req.Permissions
+			}
+		}
+	}
+
+	decision := e.Evaluate(name, subject)
+	return &decision, nil
+}
+
+// Matrix 生成当前生效的授权策略与访问矩阵
+func (e *PolicyEngine) Matrix(ctx context.Context) (*dto.PolicyMatrixResponse, error) {
+	roles, err := e.rbacService.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(e.config.Policies))
+	for name := range e.config.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matrix := &dto.PolicyMatrixResponse{
+		Mode:     e.config.Mode,
+		Policies: make([]dto.PolicyResponse, len(names)),
+		Routes:   make([]dto.PolicyRouteResponse, len(e.routes)),
+	}
+	for i, name := range names {
+		policy := e.config.Policies[name]
+		item := dto.PolicyResponse{
+			Name:        name,
+			Description: policy.Description,
+			DryRun:      policy.DryRun,
+			Routes:      policy.Routes,
+			Rules:       make([]dto.PolicyRuleResponse, len(policy.Rules)),
+		}
+		for j, rule := range policy.Rules {
+			item.Rules[j] = dto.PolicyRuleResponse{
+				Principal:   rule.Principal,
+				Scopes:      rule.Scopes,
+				Roles:       rule.Roles,
+				Permissions: rule.Permissions,
+				Owner:       rule.Owner,
+				ClientIDs:   rule.ClientIDs,
+			}
+		}
+		matrix.Policies[i] = item
+	}
+
+	// 以用户 1 访问用户 2 的资源模拟非所有者，以用户 1 访问自己的资源模拟所有者
+	for i, route := range e.routes {
+		name := e.bindings[route.Method+" "+route.Path]
+		row := dto.PolicyRouteResponse{Method: route.Method, Path: route.Path, Policy: name, Roles: []string{}}
+		for _, role := range roles {
+			subject := PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 1, Roles: []string{role.Name}, Permissions: role.Permissions, PathUserID: 2, QueryUserID: 2}
+			if decision := e.Evaluate(name, subject); decision.Allowed {
+				row.Roles = append(row.Roles, role.Name)
+			}
+		}
+		owner := e.Evaluate(name, PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 1, PathUserID: 1, QueryUserID: 1})
+		row.OwnerAllowed = owner.Allowed
+		row.Enforced = owner.Enforced
+		row.ClientAllowed = slices.ContainsFunc(e.config.Policies[name].Rules, func(rule appconfig.PolicyRule) bool {
+			return rule.Principal != appconfig.PolicyPrincipalUser && rule.Owner == "" && len(rule.Roles) == 0 && len(rule.Permissions) == 0
+		})
+		matrix.Routes[i] = row
+	}
+	return matrix, nil
+}
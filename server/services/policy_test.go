@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+)
+
+var testPolicyRoutes = []PolicyRoute{
+	{Method: "GET", Path: "/api/v1/users"},
+	{Method: "GET", Path: "/api/v1/users/:user_id"},
+	{Method: "PUT", Path: "/api/v1/users/:user_id"},
+	{Method: "DELETE", Path: "/api/v1/users/:user_id"},
+	{Method: "GET", Path: "/api/v1/roles"},
+	{Method: "POST", Path: "/api/v1/roles"},
+	{Method: "GET", Path: "/api/v1/sessions"},
+	{Method: "GET", Path: "/health"},
+}
+
+// testPolicies 覆盖各类条件的策略，路由模式按优先级相互覆盖
+func testPolicies() map[string]appconfig.PolicyConfig {
+	return map[string]appconfig.PolicyConfig{
+		"api": {
+			Routes: []string{"* /api/v1/*"},
+			Rules:  []appconfig.PolicyRule{{Principal: appconfig.PolicyPrincipalUser, Roles: []string{"admin", "superadmin"}}},
+		},
+		"api.read": {
+			Routes: []string{"GET /api/v1/*"},
+			Rules:  []appconfig.PolicyRule{{Principal: appconfig.PolicyPrincipalClient, ClientIDs: []string{"7"}}},
+		},
+		"users": {
+			Routes: []string{"* /api/v1/users*"},
+			Rules:  []appconfig.PolicyRule{{Principal: appconfig.PolicyPrincipalUser, Permissions: []string{"user:read", "user:list"}}},
+		},
+		"users.write": {
+			Routes: []string{"* /api/v1/users/:user_id"},
+			Rules: []appconfig.PolicyRule{
+				{Principal: appconfig.PolicyPrincipalUser, Owner: appconfig.PolicyOwnerParam},
+				{Principal: appconfig.PolicyPrincipalUser, Permissions: []string{"user:write"}},
+			},
+		},
+		"users.read": {
+			Routes: []string{"GET /api/v1/users/:user_id"},
+			Rules: []appconfig.PolicyRule{
+				{Principal: appconfig.PolicyPrincipalUser, Owner: appconfig.PolicyOwnerParam, Scopes: []string{"profile"}},
+				{Principal: appconfig.PolicyPrincipalUser, Permissions: []string{"user:read"}, Scopes: []string{"profile"}},
+				{Principal: appconfig.PolicyPrincipalClient, Scopes: []string{"profile"}},
+			},
+		},
+		"sessions": {
+			Routes: []string{"GET /api/v1/sessions"},
+			Rules:  []appconfig.PolicyRule{{Principal: appconfig.PolicyPrincipalUser, Owner: appconfig.PolicyOwnerQuery}},
+		},
+		"deny": {},
+	}
+}
+
+func newTestPolicyEngine(t *testing.T, mode string, policies map[string]appconfig.PolicyConfig) *PolicyEngine {
+	t.Helper()
+	engine := NewPolicyEngine(appconfig.AuthorizationConfig{Mode: mode, Policies: policies}, nil)
+	if err := engine.BindRoutes(testPolicyRoutes); err != nil {
+		t.Fatalf("绑定路由失败: %v", err)
+	}
+	return engine
+}
+
+func TestPolicyBindRoutes(t *testing.T) {
+	// 策略以 map 保存，遍历顺序随机，多次绑定验证结果不依赖遍历顺序
+	for range 20 {
+		engine := newTestPolicyEngine(t, appconfig.AuthorizationModeEnforce, testPolicies())
+		for _, tt := range []struct {
+			method, path, policy string
+		}{
+			{"GET", "/api/v1/users/:user_id", "users.read"},  // 方法与路径都精确匹配
+			{"PUT", "/api/v1/users/:user_id", "users.write"}, // 路径精确匹配优先于前缀
+			{"GET", "/api/v1/users", "users"},                // 较长的前缀优先
+			{"GET", "/api/v1/roles", "api.read"},             // 前缀相同时指定方法的优先
+			{"POST", "/api/v1/roles", "api"},                 // 方法不符时回落到通配方法
+			{"GET", "/api/v1/sessions", "sessions"},          // 精确匹配优先于任何前缀
+		} {
+			if policy, ok := engine.PolicyFor(tt.method, tt.path); !ok || policy != tt.policy {
+				t.Fatalf("%s %s 绑定到 %q，期望 %q", tt.method, tt.path, policy, tt.policy)
+			}
+		}
+		if policy, ok := engine.PolicyFor("GET", "/health"); ok {
+			t.Fatalf("未配置的路由绑定到了策略 %q", policy)
+		}
+		if _, ok := engine.PolicyFor("get", "/api/v1/users"); ok {
+			t.Fatal("方法须与注册的路由一致")
+		}
+	}
+}
+
+func TestPolicyBindRoutesUnmatched(t *testing.T) {
+	policies := testPolicies()
+	policies["typo"] = appconfig.PolicyConfig{Routes: []string{"GET /api/v1/user/:user_id", "POST /api/v1/users"}}
+	engine := NewPolicyEngine(appconfig.AuthorizationConfig{Mode: appconfig.AuthorizationModeEnforce, Policies: policies}, nil)
+	err := engine.BindRoutes(testPolicyRoutes)
+	if err == nil {
+		t.Fatal("策略中的路由未匹配到已注册路由时应报错")
+	}
+	for _, pattern := range []string{"GET /api/v1/user/:user_id(typo)", "POST /api/v1/users(typo)"} {
+		if !strings.Contains(err.Error(), pattern) {
+			t.Fatalf("错误 %q 未列出 %s", err, pattern)
+		}
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	engine := newTestPolicyEngine(t, appconfig.AuthorizationModeEnforce, testPolicies())
+	cookieUser := func(userID, pathUserID uint64, permissions ...string) PolicySubject {
+		return PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: userID, PathUserID: pathUserID, Permissions: permissions}
+	}
+	bearerUser := func(userID, pathUserID uint64, scope string) PolicySubject {
+		return PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: userID, PathUserID: pathUserID, ClientID: "9", Scope: scope}
+	}
+
+	tests := []struct {
+		name    string
+		policy  string
+		subject PolicySubject
+		matched int // 期望第一条通过的规则，-1 表示拒绝
+	}{
+		{"Cookie 登录的所有者不受范围限制", "users.read", cookieUser(5, 5), 0},
+		{"非所有者", "users.read", cookieUser(5, 6), -1},
+		{"非所有者具备权限", "users.read", cookieUser(5, 6, "user:read"), 1},
+		{"Bearer 令牌缺少范围", "users.read", bearerUser(5, 5, "openid email"), -1},
+		{"Bearer 令牌具备范围", "users.read", bearerUser(5, 5, "openid profile"), 0},
+		{"Bearer 令牌的权限同样受范围限制", "users.read", PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 5, PathUserID: 6, ClientID: "9", Scope: "openid", Permissions: []string{"user:read"}}, -1},
+		{"客户端凭证具备范围", "users.read", PolicySubject{Kind: appconfig.PolicyPrincipalClient, ClientID: "9", Scope: "profile"}, 2},
+		{"客户端不是资源所有者", "users.write", PolicySubject{Kind: appconfig.PolicyPrincipalClient, ClientID: "9", PathUserID: 0}, -1},
+		{"未登录用户不匹配缺失的路径参数", "users.write", cookieUser(0, 0), -1},
+		{"所有者来自查询参数", "sessions", PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 5, QueryUserID: 5}, 0},
+		{"查询参数缺失时不以路径参数判断", "sessions", PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 5, PathUserID: 5}, -1},
+		{"权限须全部具备", "users", cookieUser(5, 0, "user:read"), -1},
+		{"具备全部权限", "users", cookieUser(5, 0, "user:list", "user:read"), 0},
+		{"具备任一角色", "api", PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 5, Roles: []string{"editor", "superadmin"}}, 0},
+		{"不具备角色", "api", PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 5, Roles: []string{"editor"}}, -1},
+		{"客户端在允许列表中", "api.read", PolicySubject{Kind: appconfig.PolicyPrincipalClient, ClientID: "7"}, 0},
+		{"客户端不在允许列表中", "api.read", PolicySubject{Kind: appconfig.PolicyPrincipalClient, ClientID: "8"}, -1},
+		{"没有规则的策略", "deny", cookieUser(5, 5), -1},
+		{"策略不存在", "missing", cookieUser(5, 5), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(tt.policy, tt.subject)
+			if decision.Allowed != (tt.matched >= 0) || decision.MatchedRule != tt.matched {
+				t.Fatalf("allowed = %v, matched_rule = %d，期望 %d；规则 %+v", decision.Allowed, decision.MatchedRule, tt.matched, decision.Rules)
+			}
+			if !decision.Enforced {
+				t.Fatal("enforce 模式下的策略应强制执行")
+			}
+		})
+	}
+}
+
+func TestPolicyEnforcement(t *testing.T) {
+	policies := testPolicies()
+	dryRun := policies["api"]
+	dryRun.DryRun = true
+	policies["api"] = dryRun
+
+	for _, tt := range []struct {
+		mode, policy string
+		enforced     bool
+	}{
+		{appconfig.AuthorizationModeEnforce, "users", true},
+		{appconfig.AuthorizationModeEnforce, "api", false},
+		{appconfig.AuthorizationModeDryRun, "users", false},
+		{appconfig.AuthorizationModeDryRun, "api", false},
+	} {
+		engine := newTestPolicyEngine(t, tt.mode, policies)
+		decision := engine.Evaluate(tt.policy, PolicySubject{Kind: appconfig.PolicyPrincipalUser, UserID: 5})
+		if decision.Allowed {
+			t.Fatalf("%s 模式下 %s 不应允许", tt.mode, tt.policy)
+		}
+		if decision.Enforced != tt.enforced {
+			t.Fatalf("%s 模式下 %s enforced = %v，期望 %v", tt.mode, tt.policy, decision.Enforced, tt.enforced)
+		}
+	}
+}
+
+func TestPolicyExplain(t *testing.T) {
+	engine := newTestPolicyEngine(t, appconfig.AuthorizationModeEnforce, testPolicies())
+	ctx := context.Background()
+	roles := []string{"admin"}
+	noPermissions := []string{}
+
+	// 按路由查找策略，方法不区分大小写；逐条规则列出条件的期望值与实际值
+	decision, err := engine.Explain(ctx, &dto.PolicyExplainRequest{Method: "get", Path: "/api/v1/users/:user_id", Principal: "user", UserID: 5, ClientID: "9", Scope: "openid", PathUserID: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &dto.PolicyDecision{
+		Policy:      "users.read",
+		Allowed:     false,
+		Enforced:    true,
+		MatchedRule: -1,
+		Rules: []dto.PolicyRuleResult{
+			{Index: 0, Passed: false, Conditions: []dto.PolicyConditionResult{
+				{Name: "principal", Expected: "user", Actual: "user", Passed: true},
+				{Name: "scopes", Expected: "profile", Actual: "openid", Passed: false},
+				{Name: "owner", Expected: "param user_id=5", Actual: "user_id=5", Passed: true},
+			}},
+			{Index: 1, Passed: false, Conditions: []dto.PolicyConditionResult{
+				{Name: "principal", Expected: "user", Actual: "user", Passed: true},
+				{Name: "scopes", Expected: "profile", Actual: "openid", Passed: false},
+				{Name: "permissions", Expected: "全部 user:read", Actual: "", Passed: false},
+			}},
+			{Index: 2, Passed: false, Conditions: []dto.PolicyConditionResult{
+				{Name: "principal", Expected: "client", Actual: "user", Passed: false},
+				{Name: "scopes", Expected: "profile", Actual: "openid", Passed: false},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(decision, want) {
+		t.Fatalf("decision = %+v\n期望 %+v", decision, want)
+	}
+
+	// Cookie 登录的用户使用请求中给出的角色；Bearer 令牌的用户不带角色
+	decision, err = engine.Explain(ctx, &dto.PolicyExplainRequest{Policy: "api", Principal: "user", UserID: 5, Roles: &roles, Permissions: &noPermissions})
+	if err != nil || !decision.Allowed || decision.MatchedRule != 0 {
+		t.Fatalf("Cookie 登录的管理员 decision = %+v, err = %v", decision, err)
+	}
+	if got := decision.Rules[0].Conditions[0]; got.Name != "principal" || !got.Passed {
+		t.Fatalf("条件 = %+v", got)
+	}
+	decision, err = engine.Explain(ctx, &dto.PolicyExplainRequest{Policy: "api", Principal: "user", UserID: 5, ClientID: "9", Roles: &roles})
+	if err != nil || decision.Allowed {
+		t.Fatalf("Bearer 令牌的用户 decision = %+v, err = %v", decision, err)
+	}
+	decision, err = engine.Explain(ctx, &dto.PolicyExplainRequest{Policy: "users.read", Principal: "user", UserID: 5, PathUserID: 5, Roles: &roles})
+	if err != nil || !decision.Allowed || decision.Rules[0].Conditions[1].Actual != "Cookie 登录不受范围限制" {
+		t.Fatalf("Cookie 登录 decision = %+v, err = %v", decision, err)
+	}
+
+	if _, err := engine.Explain(ctx, &dto.PolicyExplainRequest{Method: "GET", Path: "/health", Principal: "user"}); !errors.Is(err, apperrors.ErrPolicyRouteNotFound) {
+		t.Fatalf("未绑定策略的路由 err = %v", err)
+	}
+	if _, err := engine.Explain(ctx, &dto.PolicyExplainRequest{Policy: "missing", Principal: "user"}); !errors.Is(err, apperrors.ErrPolicyNotFound) {
+		t.Fatalf("不存在的策略 err = %v", err)
+	}
+}
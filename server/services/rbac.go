@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/3086953492/gokit/cache"
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// userAuthorizationCacheKey 用户授权信息缓存键前缀
+const userAuthorizationCacheKey = "user_authz"
+
+// UserAuthorization 用户实际拥有的角色与权限，角色包括经由用户组获得的
+type UserAuthorization struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// RBACService 角色与权限服务，维护角色定义以及用户、用户组的角色分配
+type RBACService struct {
+	roleRepository       *repositories.RoleRepository
+	permissionRepository *repositories.PermissionRepository
+	userGroupRepository  *repositories.UserGroupRepository
+	cacheMgr             *cache.Manager
+	logMgr               *logger.Manager
+	auditService         *AuditService
+}
+
+// NewRBACService 创建角色与权限服务实例
+func NewRBACService(roleRepository *repositories.RoleRepository, permissionRepository *repositories.PermissionRepository, userGroupRepository *repositories.UserGroupRepository, cacheMgr *cache.Manager, logMgr *logger.Manager, auditService *AuditService) *RBACService {
+	return &RBACService{roleRepository: roleRepository, permissionRepository: permissionRepository, userGroupRepository: userGroupRepository, cacheMgr: cacheMgr, logMgr: logMgr, auditService: auditService}
+}
+
+// GetUserAuthorization 获取用户实际拥有的角色与权限，鉴权中间件每次请求都会调用，结果带缓存
+func (s *RBACService) GetUserAuthorization(ctx context.Context, userID uint) (*UserAuthorization, error) {
+	return cache.NewBuilder[UserAuthorization](s.cacheMgr).KeyWithParts(utils.TenantCacheKey(ctx, userAuthorizationCacheKey), userID).TTL(10*time.Minute).GetOrSet(ctx, func() (*UserAuthorization, error) {
+		effective, err := s.roleRepository.ListEffectiveRoles(ctx, []uint{userID})
+		if err != nil {
+			s.logMgr.Error("查询用户角色失败", "error", err, "user_id", userID)
+			return nil, apperrors.ErrRBACSystemBusy
+		}
+		roles := effective[userID]
+		roleIDs := make([]uint, len(roles))
+		authz := &UserAuthorization{Roles: make([]string, len(roles))}
+		for i, role := range roles {
+			roleIDs[i] = role.ID
+			authz.Roles[i] = role.Name
+		}
+		if authz.Permissions, err = s.roleRepository.ListPermissionNames(ctx, roleIDs); err != nil {
+			s.logMgr.Error("查询用户权限失败", "error", err, "user_id", userID)
+			return nil, apperrors.ErrRBACSystemBusy
+		}
+		return authz, nil
+	})
+}
+
+// MFARequired 安全策略要求拥有任一管理权限的账号必须启用两步验证，查询失败时按需要处理
+func (s *RBACService) MFARequired(ctx context.Context, userID uint) bool {
+	authz, err := s.GetUserAuthorization(ctx, userID)
+	if err != nil {
+		return true
+	}
+	return len(authz.Permissions) > 0
+}
+
+// AssignRoleWithTx 在创建账号的事务中按名称为用户分配角色，角色不存在时不分配
+func (s *RBACService) AssignRoleWithTx(ctx context.Context, tx *gorm.DB, userID uint, role string) error {
+	return s.roleRepository.AddUserRoleByNameWithTx(ctx, tx, userID, role)
+}
+
+// GetUserRoles 获取用户直接分配的角色以及实际拥有的角色与权限
+func (s *RBACService) GetUserRoles(ctx context.Context, userID uint) (*dto.UserRolesResponse, error) {
+	direct, err := s.roleRepository.ListUserRoles(ctx, []uint{userID})
+	if err != nil {
+		s.logMgr.Error("查询用户角色失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	authz, err := s.GetUserAuthorization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.UserRolesResponse{
+		Roles:          roleNames(direct[userID]),
+		EffectiveRoles: authz.Roles,
+		Permissions:    authz.Permissions,
+	}, nil
+}
+
+// SetUserRoles 整体替换直接分配给用户的角色
+func (s *RBACService) SetUserRoles(ctx context.Context, userID uint, names []string) (err error) {
+	defer func() {
+		s.auditService.Record(ctx, &models.AuditEvent{
+			Type:       models.AuditUserRolesUpdate,
+			UserID:     &userID,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(userID), 10),
+			Details:    AuditDetails(map[string]any{"roles": names}),
+		}, err)
+	}()
+
+	roleIDs, err := s.roleIDs(ctx, names)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepository.SetUserRoles(ctx, userID, roleIDs); err != nil {
+		s.logMgr.Error("分配用户角色失败", "error", err, "user_id", userID)
+		return apperrors.ErrRBACSystemBusy
+	}
+
+	s.InvalidateUser(ctx, userID)
+	s.logMgr.Info("用户角色已更新", "user_id", userID, "roles", names)
+	return nil
+}
+
+// RoleNamesByUsers 批量查询用户实际拥有的角色名称
+func (s *RBACService) RoleNamesByUsers(ctx context.Context, userIDs []uint) (map[uint][]string, error) {
+	effective, err := s.roleRepository.ListEffectiveRoles(ctx, userIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户角色失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	result := make(map[uint][]string, len(effective))
+	for userID, roles := range effective {
+		result[userID] = roleNames(roles)
+	}
+	return result, nil
+}
+
+// GroupNamesByUser 查询用户所属用户组的名称
+func (s *RBACService) GroupNamesByUser(ctx context.Context, userID uint) ([]string, error) {
+	groups, err := s.userGroupRepository.ListUserGroups(ctx, []uint{userID})
+	if err != nil {
+		s.logMgr.Error("查询用户所属用户组失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	names := make([]string, len(groups[userID]))
+	for i, group := range groups[userID] {
+		names[i] = group.DisplayName
+	}
+	return names, nil
+}
+
+func (s *RBACService) ListPermissions(ctx context.Context) ([]dto.PermissionResponse, error) {
+	permissions, err := s.permissionRepository.List(ctx)
+	if err != nil {
+		s.logMgr.Error("获取权限列表失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	items := make([]dto.PermissionResponse, len(permissions))
+	for i, permission := range permissions {
+		items[i] = dto.PermissionResponse{Name: permission.Name, Description: permission.Description}
+	}
+	return items, nil
+}
+
+func (s *RBACService) ListRoles(ctx context.Context) ([]dto.RoleResponse, error) {
+	roles, err := s.roleRepository.List(ctx)
+	if err != nil {
+		s.logMgr.Error("获取角色列表失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	return s.render(ctx, roles)
+}
+
+func (s *RBACService) GetRole(ctx context.Context, id uint) (*dto.RoleResponse, error) {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.render(ctx, []models.Role{*role})
+	if err != nil {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+func (s *RBACService) CreateRole(ctx context.Context, req *dto.CreateRoleRequest) error {
+	if err := s.checkRoleName(ctx, req.Name, 0); err != nil {
+		return err
+	}
+	permissionIDs, err := s.permissionIDs(ctx, req.Permissions)
+	if err != nil {
+		return err
+	}
+
+	role := &models.Role{Name: req.Name, Description: req.Description}
+	if err := s.roleRepository.Create(ctx, role, permissionIDs); err != nil {
+		s.logMgr.Error("创建角色失败", "error", err, "name", req.Name)
+		return apperrors.ErrRBACSystemBusy
+	}
+
+	s.logMgr.Info("角色创建成功", "role_id", role.ID, "name", role.Name)
+	return nil
+}
+
+// UpdateRole 更新角色，内置角色不能改名，管理员角色的权限固定为全部权限
+func (s *RBACService) UpdateRole(ctx context.Context, id uint, req *dto.UpdateRoleRequest) error {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]any{}
+	if req.Name != nil && *req.Name != role.Name {
+		if role.Builtin {
+			return apperrors.ErrRoleBuiltin
+		}
+		if err := s.checkRoleName(ctx, *req.Name, role.ID); err != nil {
+			return err
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	var permissionIDs []uint
+	if req.Permissions != nil {
+		if role.Name == models.RoleAdmin {
+			return apperrors.ErrRoleBuiltin
+		}
+		if permissionIDs, err = s.permissionIDs(ctx, *req.Permissions); err != nil {
+			return err
+		}
+		if permissionIDs == nil {
+			permissionIDs = []uint{}
+		}
+	}
+
+	if err := s.roleRepository.Update(ctx, role.ID, updates, permissionIDs); err != nil {
+		s.logMgr.Error("更新角色失败", "error", err, "role_id", role.ID)
+		return apperrors.ErrRBACSystemBusy
+	}
+
+	s.InvalidateAll(ctx)
+	s.logMgr.Info("角色更新成功", "role_id", role.ID)
+	return nil
+}
+
+// DeleteRole 删除角色，已分配该角色的用户与用户组随之失去该角色
+func (s *RBACService) DeleteRole(ctx context.Context, id uint) error {
+	role, err := s.getRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return apperrors.ErrRoleBuiltin
+	}
+
+	if err := s.roleRepository.Delete(ctx, role.ID); err != nil {
+		s.logMgr.Error("删除角色失败", "error", err, "role_id", role.ID)
+		return apperrors.ErrRBACSystemBusy
+	}
+
+	s.InvalidateAll(ctx)
+	s.logMgr.Info("角色删除成功", "role_id", role.ID, "name", role.Name)
+	return nil
+}
+
+// GroupRoleNames 批量查询分配给用户组的角色名称
+func (s *RBACService) GroupRoleNames(ctx context.Context, groupIDs []uint) (map[uint][]string, error) {
+	groupRoles, err := s.roleRepository.ListGroupRoles(ctx, groupIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组角色失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	result := make(map[uint][]string, len(groupRoles))
+	for groupID, roles := range groupRoles {
+		result[groupID] = roleNames(roles)
+	}
+	return result, nil
+}
+
+// SetGroupRoles 整体替换分配给用户组的角色
+func (s *RBACService) SetGroupRoles(ctx context.Context, groupID uint, names []string) error {
+	roleIDs, err := s.roleIDs(ctx, names)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepository.SetGroupRoles(ctx, groupID, roleIDs); err != nil {
+		s.logMgr.Error("分配用户组角色失败", "error", err, "group_id", groupID)
+		return apperrors.ErrRBACSystemBusy
+	}
+
+	s.InvalidateAll(ctx)
+	return nil
+}
+
+// InvalidateUser 删除用户的授权信息缓存，供直接修改用户角色后调用
+func (s *RBACService) InvalidateUser(ctx context.Context, userID uint) {
+	if err := s.cacheMgr.Delete(ctx, cache.BuildKey(utils.TenantCacheKey(ctx, userAuthorizationCacheKey), userID)); err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err, "user_id", userID)
+	}
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_users:")); err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err)
+	}
+}
+
+// InvalidateAll 删除全部用户的授权信息缓存，角色权限、用户组成员或用户组角色变化时影响的用户不止一个
+func (s *RBACService) InvalidateAll(ctx context.Context) {
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, userAuthorizationCacheKey)+"|"); err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err)
+	}
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_users:")); err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err)
+	}
+}
+
+func (s *RBACService) getRole(ctx context.Context, id uint) (*models.Role, error) {
+	role, err := s.roleRepository.Get(ctx, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrRoleNotFound
+		}
+		s.logMgr.Error("获取角色失败", "error", err, "role_id", id)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	return role, nil
+}
+
+// checkRoleName 角色名称唯一，exceptID 为正在更新的角色
+func (s *RBACService) checkRoleName(ctx context.Context, name string, exceptID uint) error {
+	role, err := s.roleRepository.Get(ctx, map[string]any{"name": name})
+	if err == nil && role.ID != exceptID {
+		return apperrors.ErrRoleExists
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logMgr.Error("获取角色失败", "error", err, "name", name)
+		return apperrors.ErrRBACSystemBusy
+	}
+	return nil
+}
+
+// roleIDs 按名称查询角色ID，存在未知的角色名称时返回 ErrRoleNotFound
+func (s *RBACService) roleIDs(ctx context.Context, names []string) ([]uint, error) {
+	names = uniqueStrings(names)
+	roles, err := s.roleRepository.FindByNames(ctx, names)
+	if err != nil {
+		s.logMgr.Error("查询角色失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	if len(roles) != len(names) {
+		return nil, apperrors.ErrRoleNotFound
+	}
+	ids := make([]uint, len(roles))
+	for i, role := range roles {
+		ids[i] = role.ID
+	}
+	return ids, nil
+}
+
+// permissionIDs 按名称查询权限ID，存在未知的权限名称时返回 ErrPermissionNotFound
+func (s *RBACService) permissionIDs(ctx context.Context, names []string) ([]uint, error) {
+	names = uniqueStrings(names)
+	permissions, err := s.permissionRepository.FindByNames(ctx, names)
+	if err != nil {
+		s.logMgr.Error("查询权限失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+	if len(permissions) != len(names) {
+		return nil, apperrors.ErrPermissionNotFound
+	}
+	ids := make([]uint, len(permissions))
+	for i, permission := range permissions {
+		ids[i] = permission.ID
+	}
+	return ids, nil
+}
+
+func (s *RBACService) render(ctx context.Context, roles []models.Role) ([]dto.RoleResponse, error) {
+	roleIDs := make([]uint, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
+	permissions, err := s.roleRepository.ListRolePermissions(ctx, roleIDs)
+	if err != nil {
+		s.logMgr.Error("查询角色权限失败", "error", err)
+		return nil, apperrors.ErrRBACSystemBusy
+	}
+
+	items := make([]dto.RoleResponse, len(roles))
+	for i, role := range roles {
+		names := make([]string, len(permissions[role.ID]))
+		for j, permission := range permissions[role.ID] {
+			names[j] = permission.Name
+		}
+		items[i] = dto.RoleResponse{
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Builtin:     role.Builtin,
+			Permissions: names,
+			CreatedAt:   role.CreatedAt,
+			UpdatedAt:   role.UpdatedAt,
+		}
+	}
+	return items, nil
+}
+
+func roleNames(roles []models.Role) []string {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return names
+}
+
+func uniqueStrings(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
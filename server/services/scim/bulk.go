@@ -0,0 +1,213 @@
+package scimservices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/3086953492/gokit/logger"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+)
+
+const (
+	// MaxBulkOperations 单个批量请求最多包含的操作数
+	MaxBulkOperations = 1000
+	// MaxBulkPayloadSize 批量请求体的最大字节数
+	MaxBulkPayloadSize = 1 << 20
+)
+
+// bulkReference 匹配路径与数据中的 bulkId:xxx 引用
+var bulkReference = regexp.MustCompile(`bulkId:([^"/?\s]+)`)
+
+// BulkResult 单个批量操作的执行结果，Err 不为空时由控制器映射为错误响应
+type BulkResult struct {
+	scimdto.BulkOperationResult
+	Err error
+
+	id string
+}
+
+// SCIMBulkService 按 RFC 7644 3.7 依次执行批量操作
+type SCIMBulkService struct {
+	scimUserService  *SCIMUserService
+	scimGroupService *SCIMGroupService
+	logMgr           *logger.Manager
+}
+
+func NewSCIMBulkService(scimUserService *SCIMUserService, scimGroupService *SCIMGroupService, logMgr *logger.Manager) *SCIMBulkService {
+	return &SCIMBulkService{scimUserService: scimUserService, scimGroupService: scimGroupService, logMgr: logMgr}
+}
+
+// Process 执行批量操作，引用了尚未创建资源的操作推迟执行，直到引用可以解析
+// 出错的操作数达到 failOnErrors 后不再执行剩余操作
+func (s *SCIMBulkService) Process(ctx context.Context, req *scimdto.BulkRequest) ([]BulkResult, error) {
+	if len(req.Operations) > MaxBulkOperations {
+		return nil, fmt.Errorf("%w：最多 %d 个操作", apperrors.ErrSCIMTooMany, MaxBulkOperations)
+	}
+
+	resolved := make(map[string]string)
+	results := make([]BulkResult, 0, len(req.Operations))
+	failures := 0
+	pending := make([]int, len(req.Operations))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for len(pending) > 0 {
+		var deferred []int
+		for _, i := range pending {
+			op := req.Operations[i]
+			path, data, ok := resolveBulkReferences(op, resolved)
+			if !ok {
+				deferred = append(deferred, i)
+				continue
+			}
+
+			result := s.execute(ctx, op, path, data)
+			if result.Err == nil && op.BulkID != "" && strings.EqualFold(op.Method, http.MethodPost) {
+				resolved[op.BulkID] = result.id
+			}
+			results = append(results, result)
+			if result.Err != nil {
+				failures++
+				if req.FailOnErrors > 0 && failures >= req.FailOnErrors {
+					return results, nil
+				}
+			}
+		}
+
+		// 一轮中没有任何操作被执行，剩余的引用无法解析（引用不存在、创建失败或循环引用）
+		if len(deferred) == len(pending) {
+			for _, i := range deferred {
+				op := req.Operations[i]
+				results = append(results, BulkResult{
+					BulkOperationResult: scimdto.BulkOperationResult{Method: op.Method, BulkID: op.BulkID},
+					Err:                 apperrors.ErrSCIMBulkReference,
+				})
+			}
+			break
+		}
+		pending = deferred
+	}
+
+	s.logMgr.Info("SCIM批量操作完成", "operations", len(req.Operations), "failures", failures)
+	return results, nil
+}
+
+func (s *SCIMBulkService) execute(ctx context.Context, op scimdto.BulkOperation, path string, data []byte) BulkResult {
+	result := BulkResult{BulkOperationResult: scimdto.BulkOperationResult{Method: op.Method, BulkID: op.BulkID}}
+	fail := func(err error) BulkResult {
+		result.Err = err
+		return result
+	}
+
+	method := strings.ToUpper(op.Method)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	endpoint, id := segments[0], ""
+	if len(segments) == 2 {
+		id = segments[1]
+	}
+	if len(segments) > 2 || (endpoint != "Users" && endpoint != "Groups") || (method == http.MethodPost) != (id == "") {
+		return fail(fmt.Errorf("%w：path %q", apperrors.ErrSCIMInvalidPath, op.Path))
+	}
+	if method != http.MethodDelete && len(data) == 0 {
+		return fail(fmt.Errorf("%w：缺少 data", apperrors.ErrSCIMInvalidSyntax))
+	}
+
+	var meta *scimdto.Meta
+	var err error
+	switch method {
+	case http.MethodPost, http.MethodPut:
+		if endpoint == "Users" {
+			var user scimdto.User
+			if err := json.Unmarshal(data, &user); err != nil {
+				return fail(fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidSyntax, err.Error()))
+			}
+			var resource *scimdto.User
+			if method == http.MethodPost {
+				resource, err = s.scimUserService.Create(ctx, &user)
+			} else {
+				resource, err = s.scimUserService.Replace(ctx, id, &user, op.Version)
+			}
+			if resource != nil {
+				meta, result.id = resource.Meta, resource.ID
+			}
+		} else {
+			var group scimdto.Group
+			if err := json.Unmarshal(data, &group); err != nil {
+				return fail(fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidSyntax, err.Error()))
+			}
+			var resource *scimdto.Group
+			if method == http.MethodPost {
+				resource, err = s.scimGroupService.Create(ctx, &group)
+			} else {
+				resource, err = s.scimGroupService.Replace(ctx, id, &group, op.Version)
+			}
+			if resource != nil {
+				meta, result.id = resource.Meta, resource.ID
+			}
+		}
+	case http.MethodPatch:
+		var patch scimdto.PatchRequest
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return fail(fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidSyntax, err.Error()))
+		}
+		if endpoint == "Users" {
+			var resource *scimdto.User
+			if resource, err = s.scimUserService.Patch(ctx, id, &patch, op.Version); resource != nil {
+				meta = resource.Meta
+			}
+		} else {
+			var resource *scimdto.Group
+			if resource, err = s.scimGroupService.Patch(ctx, id, &patch, op.Version); resource != nil {
+				meta = resource.Meta
+			}
+		}
+	case http.MethodDelete:
+		if endpoint == "Users" {
+			err = s.scimUserService.Delete(ctx, id, op.Version)
+		} else {
+			err = s.scimGroupService.Delete(ctx, id, op.Version)
+		}
+	default:
+		return fail(fmt.Errorf("%w：不支持的方法 %q", apperrors.ErrSCIMInvalidSyntax, op.Method))
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	switch method {
+	case http.MethodPost:
+		result.Status = "201"
+	case http.MethodDelete:
+		result.Status = "204"
+	default:
+		result.Status = "200"
+	}
+	if meta != nil {
+		result.Location = meta.Location
+		result.Version = meta.Version
+	}
+	return result
+}
+
+// resolveBulkReferences 将路径与数据中的 bulkId 引用替换为已创建资源的ID，存在未解析的引用时返回 false
+func resolveBulkReferences(op scimdto.BulkOperation, resolved map[string]string) (string, []byte, bool) {
+	ok := true
+	replace := func(match string) string {
+		id, found := resolved[bulkReference.FindStringSubmatch(match)[1]]
+		if !found {
+			ok = false
+			return match
+		}
+		return id
+	}
+	path := bulkReference.ReplaceAllStringFunc(op.Path, replace)
+	data := []byte(bulkReference.ReplaceAllStringFunc(string(op.Data), replace))
+	return path, data, ok
+}
@@ -0,0 +1,243 @@
+package scimservices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/3086953492/gokit/cache"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/services"
+)
+
+type bulkTestEnv struct {
+	service      *SCIMBulkService
+	groupService *SCIMGroupService
+	userIDs      []string
+}
+
+// newBulkTestEnv 只接入用户组资源，用户作为成员预先写入数据库
+func newBulkTestEnv(t *testing.T) *bulkTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.UserGroup{}, &models.UserGroupMember{})
+	logMgr := newTestLogger(t)
+	cacheMgr, err := cache.NewManager(newTestRedis(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userRepository := repositories.NewUserRepository(db)
+	userGroupRepository := repositories.NewUserGroupRepository(db)
+	env := &bulkTestEnv{}
+	for _, username := range []string{"alice", "bob"} {
+		user := &models.User{Subject: "subject-" + username, Username: username, Nickname: username, Status: models.UserStatusActive}
+		if err := userRepository.Create(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+		env.userIDs = append(env.userIDs, strconv.FormatUint(uint64(user.ID), 10))
+	}
+
+	rbacService := services.NewRBACService(nil, nil, userGroupRepository, cacheMgr, logMgr, nil)
+	env.groupService = NewSCIMGroupService(userGroupRepository, userRepository, rbacService, logMgr, "https://auth.example.com")
+	env.service = NewSCIMBulkService(nil, env.groupService, logMgr)
+	return env
+}
+
+func bulkData(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestBulkForwardReference(t *testing.T) {
+	env := newBulkTestEnv(t)
+	ctx := context.Background()
+
+	// PATCH 引用的用户组在其后才创建，推迟到引用可以解析时执行；数据中的引用同样替换
+	results, err := env.service.Process(ctx, &scimdto.BulkRequest{Operations: []scimdto.BulkOperation{
+		{Method: "PATCH", Path: "/Groups/bulkId:eng", Data: bulkData(t, map[string]any{
+			"schemas":    []string{scimdto.SchemaPatchOp},
+			"Operations": []map[string]any{{"op": "add", "path": "members", "value": []map[string]string{{"value": env.userIDs[1]}}}},
+		})},
+		{Method: "POST", BulkID: "eng", Path: "/Groups", Data: bulkData(t, map[string]any{
+			"displayName": "Engineering",
+			"members":     []map[string]string{{"value": env.userIDs[0]}},
+		})},
+		{Method: "POST", BulkID: "ops", Path: "/Groups", Data: json.RawMessage(`{"displayName": "Ops", "externalId": "bulkId:eng"}`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("结果数 = %d，期望 3", len(results))
+	}
+	for i, want := range []struct{ method, status string }{{"POST", "201"}, {"POST", "201"}, {"PATCH", "200"}} {
+		if results[i].Err != nil || results[i].Method != want.method || results[i].Status != want.status {
+			t.Fatalf("结果 %d = %+v，期望 %s %s", i, results[i], want.method, want.status)
+		}
+	}
+
+	engID := results[0].id
+	eng, err := env.groupService.Get(ctx, engID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eng.Members) != 2 {
+		t.Fatalf("成员 = %+v，期望两个成员", eng.Members)
+	}
+	if results[2].Location != eng.Meta.Location || results[2].Version != eng.Meta.Version {
+		t.Fatalf("PATCH 结果的 location/version = %s %s，期望与用户组一致", results[2].Location, results[2].Version)
+	}
+	ops, err := env.groupService.Get(ctx, results[1].id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops.ExternalID != engID {
+		t.Fatalf("externalId = %q，期望替换为 %q", ops.ExternalID, engID)
+	}
+}
+
+func TestBulkUnresolvedReference(t *testing.T) {
+	env := newBulkTestEnv(t)
+
+	// 引用不存在的 bulkId、引用创建失败的操作与循环引用都无法解析
+	results, err := env.service.Process(context.Background(), &scimdto.BulkRequest{Operations: []scimdto.BulkOperation{
+		{Method: "DELETE", Path: "/Groups/bulkId:missing"},
+		{Method: "POST", BulkID: "bad", Path: "/Groups", Data: json.RawMessage(`{"displayName": ""}`)},
+		{Method: "DELETE", Path: "/Groups/bulkId:bad"},
+		{Method: "POST", BulkID: "a", Path: "/Groups", Data: json.RawMessage(`{"displayName": "A", "externalId": "bulkId:b"}`)},
+		{Method: "POST", BulkID: "b", Path: "/Groups", Data: json.RawMessage(`{"displayName": "B", "externalId": "bulkId:a"}`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("结果数 = %d，期望 5", len(results))
+	}
+	if !errors.Is(results[0].Err, apperrors.ErrSCIMInvalidValue) || results[0].BulkID != "bad" {
+		t.Fatalf("结果 0 = %+v，期望创建失败", results[0])
+	}
+	for _, result := range results[1:] {
+		if !errors.Is(result.Err, apperrors.ErrSCIMBulkReference) {
+			t.Fatalf("结果 %+v，期望 ErrSCIMBulkReference", result)
+		}
+	}
+}
+
+func TestBulkFailOnErrors(t *testing.T) {
+	operations := []scimdto.BulkOperation{
+		{Method: "POST", Path: "/Widgets", Data: json.RawMessage(`{}`)},
+		{Method: "POST", Path: "/Groups"},
+		{Method: "POST", Path: "/Groups", Data: json.RawMessage(`{"displayName": "Engineering"}`)},
+	}
+
+	t.Run("达到上限后停止", func(t *testing.T) {
+		env := newBulkTestEnv(t)
+		results, err := env.service.Process(context.Background(), &scimdto.BulkRequest{FailOnErrors: 2, Operations: operations})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("结果数 = %d，期望在第二个错误后停止", len(results))
+		}
+		if !errors.Is(results[0].Err, apperrors.ErrSCIMInvalidPath) || !errors.Is(results[1].Err, apperrors.ErrSCIMInvalidSyntax) {
+			t.Fatalf("结果 = %+v", results)
+		}
+		list, err := env.groupService.List(context.Background(), "", 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list.TotalResults != 0 {
+			t.Fatalf("停止后仍创建了 %d 个用户组", list.TotalResults)
+		}
+	})
+
+	t.Run("未设置时执行全部操作", func(t *testing.T) {
+		env := newBulkTestEnv(t)
+		results, err := env.service.Process(context.Background(), &scimdto.BulkRequest{Operations: operations})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 3 || results[2].Err != nil || results[2].Status != "201" {
+			t.Fatalf("结果 = %+v", results)
+		}
+	})
+}
+
+func TestBulkVersion(t *testing.T) {
+	env := newBulkTestEnv(t)
+	ctx := context.Background()
+	group, err := env.groupService.Create(ctx, &scimdto.Group{DisplayName: "Engineering"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/Groups/" + group.ID
+	data := json.RawMessage(`{"displayName": "Platform"}`)
+
+	results, err := env.service.Process(ctx, &scimdto.BulkRequest{Operations: []scimdto.BulkOperation{
+		{Method: "PUT", Path: path, Version: `W/"0000000000000000"`, Data: data},
+		{Method: "PUT", Path: path, Version: group.Meta.Version, Data: data},
+		{Method: "DELETE", Path: path, Version: group.Meta.Version},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(results[0].Err, apperrors.ErrSCIMPreconditionFailed) {
+		t.Fatalf("版本不符 err = %v", results[0].Err)
+	}
+	if results[1].Err != nil || results[1].Version == group.Meta.Version {
+		t.Fatalf("结果 = %+v，期望更新成功且版本变化", results[1])
+	}
+	// 更新后旧版本失效
+	if !errors.Is(results[2].Err, apperrors.ErrSCIMPreconditionFailed) {
+		t.Fatalf("以旧版本删除 err = %v", results[2].Err)
+	}
+}
+
+func TestBulkTooManyOperations(t *testing.T) {
+	env := newBulkTestEnv(t)
+	operations := make([]scimdto.BulkOperation, MaxBulkOperations+1)
+	if _, err := env.service.Process(context.Background(), &scimdto.BulkRequest{Operations: operations}); !errors.Is(err, apperrors.ErrSCIMTooMany) {
+		t.Fatalf("err = %v，期望 ErrSCIMTooMany", err)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	const version = `W/"0123456789abcdef"`
+	tests := []struct {
+		ifMatch string
+		ok      bool
+	}{
+		{"", true},
+		{"*", true},
+		{version, true},
+		{`"0123456789abcdef"`, true},
+		{`W/"other", ` + version, true},
+		{`W/"other"`, false},
+		{`"0123456789abcdeF"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ifMatch, func(t *testing.T) {
+			err := checkVersion(tt.ifMatch, version)
+			if tt.ok != (err == nil) {
+				t.Fatalf("checkVersion(%q) = %v", tt.ifMatch, err)
+			}
+			if err != nil && !errors.Is(err, apperrors.ErrSCIMPreconditionFailed) {
+				t.Fatalf("err = %v，期望 ErrSCIMPreconditionFailed", err)
+			}
+		})
+	}
+
+	if NotModified("", version) || !NotModified(`"0123456789abcdef"`, version) || NotModified(`W/"other"`, version) {
+		t.Fatal("If-None-Match 判断错误")
+	}
+}
@@ -0,0 +1,576 @@
+package scimservices
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"goauth/apperrors"
+	"goauth/models"
+)
+
+// RFC 7644 3.4.2.2 过滤表达式的语法树
+
+type filterNode interface{}
+
+type logicalExpr struct {
+	op          string // and / or
+	left, right filterNode
+}
+
+type notExpr struct {
+	expr filterNode
+}
+
+type compareExpr struct {
+	attr  string // 小写、去掉核心 schema 前缀的属性路径
+	op    string // eq ne co sw ew gt ge lt le pr
+	value any    // string / bool / float64 / nil
+}
+
+type valuePathExpr struct {
+	attr   string
+	filter filterNode
+}
+
+var compareOps = map[string]bool{"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "gt": true, "ge": true, "lt": true, "le": true}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	schema string
+}
+
+// parseFilter 解析过滤表达式，schema 为资源的核心 schema，属性名可带该前缀
+func parseFilter(filter, schema string) (filterNode, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, schema: schema}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, filterError("多余的内容 %q", p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+func filterError(format string, args ...any) error {
+	return fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidFilter, fmt.Sprintf(format, args...))
+}
+
+func tokenizeFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '[' || r == ']':
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+		case r == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, filterError("字符串缺少结束引号")
+			}
+			tokens = append(tokens, filterToken{text: b.String(), quoted: true})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()[]\"", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) keyword(word string) bool {
+	tok, ok := p.peek()
+	if ok && !tok.quoted && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(text string) error {
+	tok, ok := p.peek()
+	if !ok || tok.quoted || tok.text != text {
+		return filterError("缺少 %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.keyword("not") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: expr}, nil
+	}
+
+	tok, ok := p.peek()
+	if !ok {
+		return nil, filterError("表达式不完整")
+	}
+	if !tok.quoted && tok.text == "(" {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	if tok.quoted || strings.ContainsAny(tok.text, "()[]") {
+		return nil, filterError("缺少属性名")
+	}
+	p.pos++
+	attr := normalizeAttr(tok.text, p.schema)
+
+	if next, ok := p.peek(); ok && !next.quoted && next.text == "[" {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &valuePathExpr{attr: attr, filter: inner}, nil
+	}
+
+	opTok, ok := p.peek()
+	if !ok || opTok.quoted {
+		return nil, filterError("属性 %s 缺少运算符", tok.text)
+	}
+	op := strings.ToLower(opTok.text)
+	p.pos++
+	if op == "pr" {
+		return &compareExpr{attr: attr, op: op}, nil
+	}
+	if !compareOps[op] {
+		return nil, filterError("不支持的运算符 %q", opTok.text)
+	}
+
+	valTok, ok := p.peek()
+	if !ok {
+		return nil, filterError("属性 %s 缺少比较值", tok.text)
+	}
+	p.pos++
+	value, err := filterValue(valTok)
+	if err != nil {
+		return nil, err
+	}
+	return &compareExpr{attr: attr, op: op, value: value}, nil
+}
+
+func filterValue(tok filterToken) (any, error) {
+	if tok.quoted {
+		return tok.text, nil
+	}
+	switch strings.ToLower(tok.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(tok.text, 64)
+	if err != nil {
+		return nil, filterError("无效的比较值 %q", tok.text)
+	}
+	return n, nil
+}
+
+// normalizeAttr 去掉核心 schema 前缀并转为小写，SCIM 属性名不区分大小写
+func normalizeAttr(name, schema string) string {
+	lower := strings.ToLower(name)
+	if prefix := strings.ToLower(schema) + ":"; schema != "" && strings.HasPrefix(lower, prefix) {
+		lower = lower[len(prefix):]
+	}
+	return lower
+}
+
+// 过滤属性对应的列类型
+type columnKind int
+
+const (
+	columnString columnKind = iota
+	columnID
+	columnActive
+	columnTime
+	columnMember
+)
+
+// filterColumn 过滤属性与数据库列的映射
+// columnMember 为多值引用，column 为 EXISTS 子查询，包含一个 %s 占位用于追加取值条件，valueColumn 为取值列
+type filterColumn struct {
+	column      string
+	kind        columnKind
+	valueColumn string
+}
+
+// compileFilter 将过滤表达式转换为 SQL 条件
+func compileFilter(node filterNode, columns map[string]filterColumn) (string, []any, error) {
+	switch n := node.(type) {
+	case *logicalExpr:
+		left, leftArgs, err := compileFilter(n.left, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := compileFilter(n.right, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(n.op) + " " + right + ")", append(leftArgs, rightArgs...), nil
+	case *notExpr:
+		expr, args, err := compileFilter(n.expr, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + expr + ")", args, nil
+	case *valuePathExpr:
+		return compileFilter(prefixAttrs(n.filter, n.attr), columns)
+	case *compareExpr:
+		column, ok := columns[n.attr]
+		if !ok {
+			return "", nil, filterError("不支持按属性 %s 过滤", n.attr)
+		}
+		return compileCompare(n, column)
+	default:
+		return "", nil, filterError("表达式无效")
+	}
+}
+
+// prefixAttrs 将 emails[value eq "x"] 中的子属性展开为 emails.value
+func prefixAttrs(node filterNode, prefix string) filterNode {
+	switch n := node.(type) {
+	case *logicalExpr:
+		return &logicalExpr{op: n.op, left: prefixAttrs(n.left, prefix), right: prefixAttrs(n.right, prefix)}
+	case *notExpr:
+		return &notExpr{expr: prefixAttrs(n.expr, prefix)}
+	case *compareExpr:
+		return &compareExpr{attr: prefix + "." + n.attr, op: n.op, value: n.value}
+	default:
+		return node
+	}
+}
+
+func compileCompare(n *compareExpr, column filterColumn) (string, []any, error) {
+	col := column.column
+	switch column.kind {
+	case columnActive:
+		if n.op == "pr" {
+			return "1 = 1", nil, nil
+		}
+		active, ok := n.value.(bool)
+		if !ok || (n.op != "eq" && n.op != "ne") {
+			return "", nil, filterError("active 只支持与 true / false 比较")
+		}
+		if active == (n.op == "eq") {
+			return col + " = ?", []any{models.UserStatusActive}, nil
+		}
+		return col + " <> ?", []any{models.UserStatusActive}, nil
+
+	case columnMember:
+		if n.op == "pr" {
+			return fmt.Sprintf(col, ""), nil, nil
+		}
+		if n.op != "eq" {
+			return "", nil, filterError("%s 只支持 eq 与 pr", n.attr)
+		}
+		id, ok := resourceID(n.value)
+		if !ok {
+			return "1 = 0", nil, nil
+		}
+		return fmt.Sprintf(col, " AND "+column.valueColumn+" = ?"), []any{id}, nil
+
+	case columnID:
+		if n.op == "pr" {
+			return "1 = 1", nil, nil
+		}
+		if !isOrderingOp(n.op) && n.op != "eq" && n.op != "ne" {
+			return "", nil, filterError("id 不支持运算符 %s", n.op)
+		}
+		id, ok := resourceID(n.value)
+		if !ok {
+			// 不是本系统签发的ID，不会匹配任何资源
+			if n.op == "ne" {
+				return "1 = 1", nil, nil
+			}
+			return "1 = 0", nil, nil
+		}
+		return col + " " + sqlOperator(n.op) + " ?", []any{id}, nil
+
+	case columnTime:
+		if n.op == "pr" {
+			return col + " IS NOT NULL", nil, nil
+		}
+		s, ok := n.value.(string)
+		if !ok {
+			return "", nil, filterError("%s 须与时间字符串比较", n.attr)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", nil, filterError("无效的时间 %q", s)
+		}
+		if !isOrderingOp(n.op) && n.op != "eq" && n.op != "ne" {
+			return "", nil, filterError("%s 不支持运算符 %s", n.attr, n.op)
+		}
+		return col + " " + sqlOperator(n.op) + " ?", []any{t}, nil
+
+	default:
+		if n.op == "pr" {
+			return "(" + col + " IS NOT NULL AND " + col + " <> '')", nil, nil
+		}
+		if n.value == nil && (n.op == "eq" || n.op == "ne") {
+			if n.op == "eq" {
+				return "(" + col + " IS NULL OR " + col + " = '')", nil, nil
+			}
+			return "(" + col + " IS NOT NULL AND " + col + " <> '')", nil, nil
+		}
+		s, ok := n.value.(string)
+		if !ok {
+			return "", nil, filterError("%s 须与字符串比较", n.attr)
+		}
+		switch n.op {
+		case "eq":
+			return col + " = ?", []any{s}, nil
+		case "ne":
+			return "(" + col + " IS NULL OR " + col + " <> ?)", []any{s}, nil
+		case "co":
+			return col + " LIKE ?", []any{"%" + escapeLike(s) + "%"}, nil
+		case "sw":
+			return col + " LIKE ?", []any{escapeLike(s) + "%"}, nil
+		case "ew":
+			return col + " LIKE ?", []any{"%" + escapeLike(s)}, nil
+		default:
+			return col + " " + sqlOperator(n.op) + " ?", []any{s}, nil
+		}
+	}
+}
+
+func isOrderingOp(op string) bool {
+	return op == "gt" || op == "ge" || op == "lt" || op == "le"
+}
+
+func sqlOperator(op string) string {
+	switch op {
+	case "ne":
+		return "<>"
+	case "gt":
+		return ">"
+	case "ge":
+		return ">="
+	case "lt":
+		return "<"
+	case "le":
+		return "<="
+	default:
+		return "="
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// resourceID 解析资源ID，ID 以字符串形式对外暴露
+func resourceID(value any) (uint, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return 0, false
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// matchFilter 在内存中对多值属性的元素求值，用于 PATCH 路径中的值过滤
+func matchFilter(node filterNode, elem map[string]any) (bool, error) {
+	switch n := node.(type) {
+	case *logicalExpr:
+		left, err := matchFilter(n.left, elem)
+		if err != nil {
+			return false, err
+		}
+		right, err := matchFilter(n.right, elem)
+		if err != nil {
+			return false, err
+		}
+		if n.op == "and" {
+			return left && right, nil
+		}
+		return left || right, nil
+	case *notExpr:
+		matched, err := matchFilter(n.expr, elem)
+		return !matched, err
+	case *compareExpr:
+		actual, exists := lookupKey(elem, n.attr)
+		if n.op == "pr" {
+			return exists && actual != nil && actual != "", nil
+		}
+		return compareValues(actual, n.op, n.value), nil
+	default:
+		return false, filterError("值过滤不支持嵌套")
+	}
+}
+
+func compareValues(actual any, op string, expected any) bool {
+	switch e := expected.(type) {
+	case nil:
+		isNull := actual == nil || actual == ""
+		return (op == "eq") == isNull
+	case bool:
+		a, ok := actual.(bool)
+		if op == "ne" {
+			return !ok || a != e
+		}
+		return ok && op == "eq" && a == e
+	case float64:
+		a, ok := actual.(float64)
+		if !ok {
+			return op == "ne"
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+		return false
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return op == "ne"
+		}
+		a, e = strings.ToLower(a), strings.ToLower(e)
+		switch op {
+		case "eq":
+			return a == e
+		case "ne":
+			return a != e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	}
+	return false
+}
+
+// lookupKey 不区分大小写地查找 JSON 对象中的属性
+func lookupKey(m map[string]any, name string) (any, bool) {
+	key, ok := findKey(m, name)
+	if !ok {
+		return nil, false
+	}
+	return m[key], true
+}
+
+func findKey(m map[string]any, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,187 @@
+package scimservices
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+	"goauth/models"
+)
+
+func TestTokenizeFilter(t *testing.T) {
+	tokens, err := tokenizeFilter(`emails[type eq "wo\"rk"] and (active eq true)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []filterToken{
+		{text: "emails"}, {text: "["}, {text: "type"}, {text: "eq"}, {text: `wo"rk`, quoted: true}, {text: "]"},
+		{text: "and"}, {text: "("}, {text: "active"}, {text: "eq"}, {text: "true"}, {text: ")"},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("tokens = %+v，期望 %+v", tokens, want)
+	}
+
+	if _, err := tokenizeFilter(`userName eq "bjensen`); !errors.Is(err, apperrors.ErrSCIMInvalidFilter) {
+		t.Fatalf("缺少结束引号 err = %v", err)
+	}
+}
+
+func TestCompileUserFilter(t *testing.T) {
+	lastModified, _ := time.Parse(time.RFC3339, "2024-01-02T03:04:05Z")
+
+	tests := []struct {
+		filter string
+		query  string
+		args   []any
+	}{
+		{`userName eq "bjensen"`, "users.username = ?", []any{"bjensen"}},
+		{`USERNAME EQ "bjensen"`, "users.username = ?", []any{"bjensen"}},
+		{scimdto.SchemaUser + `:userName sw "J"`, "users.username LIKE ?", []any{"J%"}},
+		{`displayName co "50%_off"`, "users.nickname LIKE ?", []any{`%50\%\_off%`}},
+		{`displayName ew "a\\b"`, "users.nickname LIKE ?", []any{`%a\\b`}},
+		{`externalId ne "x"`, "(users.external_id IS NULL OR users.external_id <> ?)", []any{"x"}},
+		{`externalId eq null`, "(users.external_id IS NULL OR users.external_id = '')", nil},
+		{`emails pr`, "(users.email IS NOT NULL AND users.email <> '')", nil},
+		{`emails[value ew "@example.com"]`, "users.email LIKE ?", []any{"%@example.com"}},
+		{`active eq true`, "users.status = ?", []any{models.UserStatusActive}},
+		{`active eq false`, "users.status <> ?", []any{models.UserStatusActive}},
+		{`active ne false`, "users.status = ?", []any{models.UserStatusActive}},
+		{`id eq "12"`, "users.id = ?", []any{uint(12)}},
+		{`id eq "abc"`, "1 = 0", nil},
+		{`id ne "abc"`, "1 = 1", nil},
+		{`meta.lastModified gt "2024-01-02T03:04:05Z"`, "users.updated_at > ?", []any{lastModified}},
+		{`groups eq "7"`, "EXISTS (SELECT 1 FROM user_group_members WHERE user_group_members.user_id = users.id AND user_group_members.group_id = ?)", []any{uint(7)}},
+		{`groups pr`, "EXISTS (SELECT 1 FROM user_group_members WHERE user_group_members.user_id = users.id)", nil},
+		{`groups.value eq "x"`, "1 = 0", nil},
+		// and 的优先级高于 or
+		{
+			`userName eq "a" or userName eq "b" and externalId eq "c"`,
+			"(users.username = ? OR (users.username = ? AND users.external_id = ?))",
+			[]any{"a", "b", "c"},
+		},
+		{
+			`(userName eq "a" or userName eq "b") and not (emails pr)`,
+			"((users.username = ? OR users.username = ?) AND NOT ((users.email IS NOT NULL AND users.email <> '')))",
+			[]any{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			node, err := parseFilter(tt.filter, scimdto.SchemaUser)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			query, args, err := compileFilter(node, userColumns)
+			if err != nil {
+				t.Fatalf("转换失败: %v", err)
+			}
+			if query != tt.query {
+				t.Fatalf("query = %q，期望 %q", query, tt.query)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Fatalf("args = %#v，期望 %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestCompileGroupFilter(t *testing.T) {
+	node, err := parseFilter(`members[value eq "3"] and displayName sw "eng"`, scimdto.SchemaGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := compileFilter(node, groupColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "(EXISTS (SELECT 1 FROM user_group_members JOIN users ON users.id = user_group_members.user_id AND users.deleted_at IS NULL WHERE user_group_members.group_id = user_groups.id AND user_group_members.user_id = ?) AND user_groups.display_name LIKE ?)"
+	if query != want {
+		t.Fatalf("query = %q，期望 %q", query, want)
+	}
+	if !reflect.DeepEqual(args, []any{uint(3), "eng%"}) {
+		t.Fatalf("args = %#v", args)
+	}
+}
+
+func TestParseFilterInvalid(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName xx "a"`,
+		`userName eq "a" extra`,
+		`userName eq bareword`,
+		`(userName eq "a"`,
+		`userName eq "a")`,
+		`not userName eq "a"`,
+		`"userName" eq "a"`,
+		`emails[value eq "a"`,
+		`userName eq "a" and`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			if _, err := parseFilter(filter, scimdto.SchemaUser); !errors.Is(err, apperrors.ErrSCIMInvalidFilter) {
+				t.Fatalf("err = %v，期望 ErrSCIMInvalidFilter", err)
+			}
+		})
+	}
+}
+
+func TestCompileFilterInvalid(t *testing.T) {
+	for _, filter := range []string{
+		`password eq "secret"`,
+		`nickName eq "a"`,
+		`active eq "yes"`,
+		`active gt true`,
+		`meta.created gt "yesterday"`,
+		`meta.created gt 5`,
+		`groups co "1"`,
+		`userName gt 5`,
+		`id co "1"`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			node, err := parseFilter(filter, scimdto.SchemaUser)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if _, _, err := compileFilter(node, userColumns); !errors.Is(err, apperrors.ErrSCIMInvalidFilter) {
+				t.Fatalf("err = %v，期望 ErrSCIMInvalidFilter", err)
+			}
+		})
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	elem := map[string]any{"type": "work", "Value": "Babs@Example.com", "primary": true}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`type eq "WORK"`, true},
+		{`type ne "work"`, false},
+		{`value ew "@example.com"`, true},
+		{`value sw "babs" and not (type eq "home")`, true},
+		{`type eq "home" or primary eq true`, true},
+		{`primary eq false`, false},
+		{`display pr`, false},
+		{`type pr`, true},
+		{`display eq null`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			node, err := parseFilter(tt.filter, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := matchFilter(node, elem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("matchFilter = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
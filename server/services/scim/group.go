@@ -0,0 +1,346 @@
+package scimservices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/services"
+	"goauth/utils"
+)
+
+const groupMembersExists = "EXISTS (SELECT 1 FROM user_group_members JOIN users ON users.id = user_group_members.user_id AND users.deleted_at IS NULL WHERE user_group_members.group_id = user_groups.id%s)"
+
+var groupColumns = map[string]filterColumn{
+	"id":                {column: "user_groups.id", kind: columnID},
+	"externalid":        {column: "user_groups.external_id"},
+	"displayname":       {column: "user_groups.display_name"},
+	"meta.created":      {column: "user_groups.created_at", kind: columnTime},
+	"meta.lastmodified": {column: "user_groups.updated_at", kind: columnTime},
+	"members":           {column: groupMembersExists, kind: columnMember, valueColumn: "user_group_members.user_id"},
+	"members.value":     {column: groupMembersExists, kind: columnMember, valueColumn: "user_group_members.user_id"},
+}
+
+// SCIMGroupService SCIM 用户组资源，成员只能是用户，不支持嵌套用户组
+type SCIMGroupService struct {
+	userGroupRepository *repositories.UserGroupRepository
+	userRepository      *repositories.UserRepository
+	rbacService         *services.RBACService
+	logMgr              *logger.Manager
+	baseURL             string
+}
+
+func NewSCIMGroupService(userGroupRepository *repositories.UserGroupRepository, userRepository *repositories.UserRepository, rbacService *services.RBACService, logMgr *logger.Manager, baseURL string) *SCIMGroupService {
+	return &SCIMGroupService{userGroupRepository: userGroupRepository, userRepository: userRepository, rbacService: rbacService, logMgr: logMgr, baseURL: baseURL}
+}
+
+func (s *SCIMGroupService) Get(ctx context.Context, id string) (*scimdto.Group, error) {
+	group, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.render(ctx, []models.UserGroup{*group})
+	if err != nil {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+func (s *SCIMGroupService) List(ctx context.Context, filter string, startIndex, count int) (*scimdto.ListResponse, error) {
+	var query string
+	var args []any
+	if filter != "" {
+		node, err := parseFilter(filter, scimdto.SchemaGroup)
+		if err != nil {
+			return nil, err
+		}
+		if query, args, err = compileFilter(node, groupColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	offset, limit, start := page(startIndex, count)
+	groups, total, err := s.userGroupRepository.Search(ctx, offset, limit, query, args...)
+	if err != nil {
+		s.logMgr.Error("查询SCIM用户组失败", "error", err, "filter", filter)
+		return nil, errors.New("查询用户组失败")
+	}
+	groupResources, err := s.render(ctx, groups)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]any, len(groupResources))
+	for i, resource := range groupResources {
+		resources[i] = resource
+	}
+	return listResponse(total, start, resources), nil
+}
+
+func (s *SCIMGroupService) Create(ctx context.Context, req *scimdto.Group) (*scimdto.Group, error) {
+	displayName, err := s.checkDisplayName(ctx, 0, req.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	userIDs, err := s.memberIDs(ctx, req.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.UserGroup{
+		DisplayName: displayName,
+		ExternalID:  optionalString(req.ExternalID),
+	}
+	if err := s.userGroupRepository.Create(ctx, group, userIDs); err != nil {
+		s.logMgr.Error("创建用户组失败", "error", err, "display_name", displayName)
+		return nil, errors.New("创建用户组失败")
+	}
+
+	s.logMgr.Info("SCIM创建用户组成功", "group_id", group.ID, "members", len(userIDs))
+	return s.Get(ctx, strconv.FormatUint(uint64(group.ID), 10))
+}
+
+// Replace 以请求替换用户组，未提交的 externalId 与成员视为清除
+func (s *SCIMGroupService) Replace(ctx context.Context, id string, req *scimdto.Group, ifMatch string) (*scimdto.Group, error) {
+	group, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ifMatch != "" {
+		current, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.replace(ctx, group, req); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Patch 在用户组当前的表示上应用 PATCH 操作，再按替换处理
+func (s *SCIMGroupService) Patch(ctx context.Context, id string, req *scimdto.PatchRequest, ifMatch string) (*scimdto.Group, error) {
+	group, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	resource, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPatch(resource, groupAttrs, req.Operations); err != nil {
+		return nil, err
+	}
+	var patched scimdto.Group
+	if err := fromMap(resource, &patched); err != nil {
+		return nil, err
+	}
+
+	if err := s.replace(ctx, group, &patched); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *SCIMGroupService) Delete(ctx context.Context, id string, ifMatch string) error {
+	group, err := s.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" {
+		current, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+			return err
+		}
+	}
+	if err := s.userGroupRepository.Delete(ctx, group.ID); err != nil {
+		s.logMgr.Error("删除用户组失败", "error", err, "group_id", group.ID)
+		return errors.New("删除用户组失败")
+	}
+	// 成员随之失去经由该用户组获得的角色
+	s.rbacService.InvalidateAll(ctx)
+
+	s.logMgr.Info("SCIM删除用户组成功", "group_id", group.ID)
+	return nil
+}
+
+func (s *SCIMGroupService) replace(ctx context.Context, group *models.UserGroup, req *scimdto.Group) error {
+	displayName, err := s.checkDisplayName(ctx, group.ID, req.DisplayName)
+	if err != nil {
+		return err
+	}
+	userIDs, err := s.memberIDs(ctx, req.Members)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]any{
+		"display_name": displayName,
+		"external_id":  optionalString(req.ExternalID),
+	}
+	if err := s.userGroupRepository.Update(ctx, group.ID, updates, userIDs); err != nil {
+		s.logMgr.Error("更新用户组失败", "error", err, "group_id", group.ID)
+		return errors.New("更新用户组失败")
+	}
+	// 成员变化会改变经由用户组获得的角色
+	s.rbacService.InvalidateAll(ctx)
+
+	s.logMgr.Info("SCIM更新用户组成功", "group_id", group.ID, "members", len(userIDs))
+	return nil
+}
+
+func (s *SCIMGroupService) load(ctx context.Context, id string) (*models.UserGroup, error) {
+	groupID, ok := resourceID(id)
+	if !ok {
+		return nil, apperrors.ErrSCIMNotFound
+	}
+	group, err := s.userGroupRepository.Get(ctx, map[string]any{"id": groupID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrSCIMNotFound
+		}
+		s.logMgr.Error("查询SCIM用户组失败", "error", err, "id", id)
+		return nil, errors.New("查询用户组失败")
+	}
+	return group, nil
+}
+
+// checkDisplayName 用户组名称在未删除的用户组中唯一，exceptID 为正在更新的用户组
+func (s *SCIMGroupService) checkDisplayName(ctx context.Context, exceptID uint, name string) (string, error) {
+	displayName := strings.TrimSpace(name)
+	if displayName == "" {
+		return "", fmt.Errorf("%w：displayName 不能为空", apperrors.ErrSCIMInvalidValue)
+	}
+	if utf8.RuneCountInString(displayName) > 255 {
+		return "", fmt.Errorf("%w：displayName 不能超过255个字符", apperrors.ErrSCIMInvalidValue)
+	}
+
+	count, err := s.userGroupRepository.Count(ctx, map[string]any{"display_name = ?": displayName, "id <> ?": exceptID})
+	if err != nil {
+		s.logMgr.Error("查询用户组失败", "error", err, "display_name", displayName)
+		return "", errors.New("查询用户组失败")
+	}
+	if count > 0 {
+		return "", fmt.Errorf("%w：displayName", apperrors.ErrSCIMUniqueness)
+	}
+	return displayName, nil
+}
+
+// memberIDs 校验成员均为已存在的用户，返回去重后的用户ID，没有成员时返回空切片
+func (s *SCIMGroupService) memberIDs(ctx context.Context, members []scimdto.Member) ([]uint, error) {
+	userIDs := make([]uint, 0, len(members))
+	seen := make(map[uint]bool, len(members))
+	for _, member := range members {
+		if member.Type != "" && !strings.EqualFold(member.Type, "User") {
+			return nil, fmt.Errorf("%w：成员只能是用户", apperrors.ErrSCIMInvalidValue)
+		}
+		userID, ok := resourceID(member.Value)
+		if !ok {
+			return nil, fmt.Errorf("%w：成员 %q 不存在", apperrors.ErrSCIMInvalidValue, member.Value)
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return userIDs, nil
+	}
+
+	users, err := s.userRepository.FindByIDs(ctx, userIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err)
+		return nil, errors.New("查询用户失败")
+	}
+	if len(users) != len(userIDs) {
+		return nil, fmt.Errorf("%w：部分成员不存在", apperrors.ErrSCIMInvalidValue)
+	}
+	return userIDs, nil
+}
+
+func (s *SCIMGroupService) render(ctx context.Context, groups []models.UserGroup) ([]*scimdto.Group, error) {
+	groupIDs := make([]uint, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = group.ID
+	}
+	members, err := s.userGroupRepository.ListMembers(ctx, groupIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err)
+		return nil, errors.New("查询用户组失败")
+	}
+
+	userIDs := make([]uint, 0, len(members))
+	seen := make(map[uint]bool, len(members))
+	for _, member := range members {
+		if !seen[member.UserID] {
+			seen[member.UserID] = true
+			userIDs = append(userIDs, member.UserID)
+		}
+	}
+	users, err := s.userRepository.FindByIDs(ctx, userIDs)
+	if err != nil {
+		s.logMgr.Error("查询用户组成员失败", "error", err)
+		return nil, errors.New("查询用户组失败")
+	}
+	nicknames := make(map[uint]string, len(users))
+	for _, user := range users {
+		nicknames[user.ID] = user.Nickname
+	}
+	endpoint := utils.RealmURL(ctx, s.baseURL, "/scim/v2")
+	groupMembers := make(map[uint][]scimdto.Member, len(groups))
+	for _, member := range members {
+		userID := strconv.FormatUint(uint64(member.UserID), 10)
+		groupMembers[member.GroupID] = append(groupMembers[member.GroupID], scimdto.Member{
+			Value:   userID,
+			Ref:     endpoint + "/Users/" + userID,
+			Display: nicknames[member.UserID],
+			Type:    "User",
+		})
+	}
+
+	resources := make([]*scimdto.Group, len(groups))
+	for i, group := range groups {
+		id := strconv.FormatUint(uint64(group.ID), 10)
+		resource := &scimdto.Group{
+			Schemas:     []string{scimdto.SchemaGroup},
+			ID:          id,
+			DisplayName: group.DisplayName,
+			Members:     groupMembers[group.ID],
+			Meta: &scimdto.Meta{
+				ResourceType: "Group",
+				Created:      formatTime(group.CreatedAt),
+				LastModified: formatTime(group.UpdatedAt),
+				Location:     endpoint + "/Groups/" + id,
+			},
+		}
+		if group.ExternalID != nil {
+			resource.ExternalID = *group.ExternalID
+		}
+		setVersion(resource, resource.Meta)
+		resources[i] = resource
+	}
+	return resources, nil
+}
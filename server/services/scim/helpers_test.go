@@ -0,0 +1,81 @@
+package scimservices
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// testDialector 测试用的 SQLite 方言，与 services 包的测试相同：
+// bigint 自增主键改写为 integer，带精度的 datetime(n) 改写为 datetime
+type testDialector struct {
+	sqlite.Dialector
+}
+
+func (d testDialector) DataTypeOf(field *schema.Field) string {
+	if field.PrimaryKey && field.AutoIncrement {
+		return "integer"
+	}
+	dataType := d.Dialector.DataTypeOf(field)
+	if strings.HasPrefix(dataType, "datetime(") {
+		return "datetime"
+	}
+	return dataType
+}
+
+func (d testDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlite.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d, CreateIndexAfterCreateTable: true}}}
+}
+
+// newTestDB 创建测试用的内存数据库并迁移给定模型，每个测试独占一个库
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared"
+	db, err := gorm.Open(testDialector{Dialector: sqlite.Dialector{DSN: dsn}}, &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db
+}
+
+// newTestRedis 启动内存 Redis，返回连接到它的 Manager
+func newTestRedis(t *testing.T) *redis.Manager {
+	t.Helper()
+	server := miniredis.RunT(t)
+	redisMgr := redis.NewManager(redis.WithAddress(server.Addr()))
+	if err := redisMgr.Connect(context.Background()); err != nil {
+		t.Fatalf("连接测试 Redis 失败: %v", err)
+	}
+	t.Cleanup(func() { redisMgr.Close() })
+	return redisMgr
+}
+
+// newTestLogger 创建只输出错误日志的日志管理器
+func newTestLogger(t *testing.T) *logger.Manager {
+	t.Helper()
+	logMgr, err := logger.NewManager(logger.WithConsole(true), logger.WithLevel(logger.ErrorLevel))
+	if err != nil {
+		t.Fatalf("创建日志管理器失败: %v", err)
+	}
+	return logMgr
+}
@@ -0,0 +1,372 @@
+package scimservices
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+)
+
+// resourceAttrs 资源可被 PATCH 的顶层属性，names 的键为小写属性名，值为 JSON 中的属性名
+type resourceAttrs struct {
+	schema   string
+	names    map[string]string
+	readOnly map[string]bool
+}
+
+var userAttrs = resourceAttrs{
+	schema: scimdto.SchemaUser,
+	names: map[string]string{
+		"id":          "id",
+		"externalid":  "externalId",
+		"username":    "userName",
+		"name":        "name",
+		"displayname": "displayName",
+		"emails":      "emails",
+		"active":      "active",
+		"groups":      "groups",
+		"meta":        "meta",
+		"password":    "password",
+	},
+	readOnly: map[string]bool{"id": true, "groups": true, "meta": true},
+}
+
+var groupAttrs = resourceAttrs{
+	schema: scimdto.SchemaGroup,
+	names: map[string]string{
+		"id":          "id",
+		"externalid":  "externalId",
+		"displayname": "displayName",
+		"members":     "members",
+		"meta":        "meta",
+	},
+	readOnly: map[string]bool{"id": true, "meta": true},
+}
+
+// subAttrNames 复合属性与多值属性元素的子属性
+var subAttrNames = map[string]string{
+	"formatted":  "formatted",
+	"familyname": "familyName",
+	"givenname":  "givenName",
+	"value":      "value",
+	"type":       "type",
+	"primary":    "primary",
+	"display":    "display",
+	"$ref":       "$ref",
+}
+
+type patchPath struct {
+	attr   string
+	filter filterNode
+	sub    string
+}
+
+// applyPatch 将 RFC 7644 3.5.2 的 PATCH 操作依次应用到资源的 JSON 表示上
+// 未支持的属性（如扩展 schema 中的属性）与 PUT 中的未知属性一样被忽略
+func applyPatch(resource map[string]any, attrs resourceAttrs, ops []scimdto.PatchOperation) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("%w：缺少 Operations", apperrors.ErrSCIMInvalidSyntax)
+	}
+
+	for _, operation := range ops {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return fmt.Errorf("%w：不支持的操作 %q", apperrors.ErrSCIMInvalidSyntax, operation.Op)
+		}
+		var value any
+		if len(operation.Value) > 0 {
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidSyntax, err.Error())
+			}
+		}
+
+		if operation.Path == "" {
+			if op == "remove" {
+				return fmt.Errorf("%w：remove 操作必须指定 path", apperrors.ErrSCIMNoTarget)
+			}
+			obj, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w：未指定 path 时 value 须为对象", apperrors.ErrSCIMInvalidValue)
+			}
+			for name, v := range obj {
+				path, err := parsePatchPath(name, attrs.schema)
+				if err != nil {
+					return err
+				}
+				// 未指定 path 时只读属性视为原样提交，与 PUT 一致地忽略
+				if attrs.readOnly[path.attr] {
+					continue
+				}
+				if err := attrs.apply(resource, op, path, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if op != "remove" && len(operation.Value) == 0 {
+			return fmt.Errorf("%w：%s 操作缺少 value", apperrors.ErrSCIMInvalidValue, operation.Op)
+		}
+		path, err := parsePatchPath(operation.Path, attrs.schema)
+		if err != nil {
+			return err
+		}
+		if err := attrs.apply(resource, op, path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePatchPath 解析 attr、attr.sub、attr[filter] 与 attr[filter].sub 形式的路径
+func parsePatchPath(path, schema string) (patchPath, error) {
+	p := path
+	if prefix := strings.ToLower(schema) + ":"; strings.HasPrefix(strings.ToLower(p), prefix) {
+		p = p[len(prefix):]
+	}
+	// 扩展 schema 中的属性整体作为一个未支持的属性
+	if strings.Contains(p, ":") {
+		return patchPath{attr: strings.ToLower(p)}, nil
+	}
+
+	var result patchPath
+	if i := strings.Index(p, "["); i >= 0 {
+		j := strings.LastIndex(p, "]")
+		if j < i {
+			return result, fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidPath, path)
+		}
+		filter, err := parseFilter(p[i+1:j], "")
+		if err != nil {
+			return result, err
+		}
+		result.attr, result.filter = p[:i], filter
+		if rest := p[j+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return result, fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidPath, path)
+			}
+			result.sub = rest[1:]
+		}
+	} else if i := strings.Index(p, "."); i >= 0 {
+		result.attr, result.sub = p[:i], p[i+1:]
+	} else {
+		result.attr = p
+	}
+
+	if result.attr == "" || (result.sub == "" && strings.HasSuffix(p, ".")) || strings.ContainsAny(result.sub, ".[]") {
+		return result, fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidPath, path)
+	}
+	result.attr, result.sub = strings.ToLower(result.attr), strings.ToLower(result.sub)
+	return result, nil
+}
+
+func (a resourceAttrs) apply(resource map[string]any, op string, path patchPath, value any) error {
+	key, known := a.names[path.attr]
+	if !known {
+		return nil
+	}
+	if a.readOnly[path.attr] {
+		return fmt.Errorf("%w：%s", apperrors.ErrSCIMMutability, key)
+	}
+	current, exists := resource[key]
+
+	if path.filter == nil {
+		if path.sub != "" {
+			switch cur := current.(type) {
+			case map[string]any:
+				setSubAttr(cur, op, path.sub, value)
+			case []any:
+				for _, item := range cur {
+					if elem, ok := item.(map[string]any); ok {
+						setSubAttr(elem, op, path.sub, value)
+					}
+				}
+			default:
+				if op != "remove" {
+					obj := map[string]any{}
+					setSubAttr(obj, op, path.sub, value)
+					resource[key] = obj
+				}
+			}
+			return nil
+		}
+
+		items, isArray := current.([]any)
+		switch {
+		case op == "remove" && isArray && value != nil:
+			// 部分预配方以 value 指定要移除的元素，如移除用户组中的部分成员
+			resource[key] = removeItems(items, value)
+		case op == "remove":
+			delete(resource, key)
+		case op == "add" && isArray:
+			resource[key] = appendItems(items, value)
+		default:
+			cur, curIsObj := current.(map[string]any)
+			obj, valueIsObj := value.(map[string]any)
+			if curIsObj && valueIsObj {
+				mergeObject(cur, obj)
+			} else {
+				resource[key] = value
+			}
+		}
+		return nil
+	}
+
+	items, _ := current.([]any)
+	result := make([]any, 0, len(items))
+	matched := false
+	for _, item := range items {
+		elem, ok := item.(map[string]any)
+		if !ok {
+			result = append(result, item)
+			continue
+		}
+		hit, err := matchFilter(path.filter, elem)
+		if err != nil {
+			return err
+		}
+		if !hit {
+			result = append(result, elem)
+			continue
+		}
+		matched = true
+		switch {
+		case op == "remove" && path.sub == "":
+			continue
+		case path.sub != "":
+			setSubAttr(elem, op, path.sub, value)
+		default:
+			obj, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w：%s 的元素须为对象", apperrors.ErrSCIMInvalidValue, key)
+			}
+			mergeObject(elem, obj)
+		}
+		result = append(result, elem)
+	}
+
+	if !matched {
+		switch {
+		case op == "remove":
+			return nil
+		case op == "replace" && exists && len(items) > 0:
+			return fmt.Errorf("%w：%s", apperrors.ErrSCIMNoTarget, key)
+		}
+		// 属性不存在时按过滤条件新建元素，如 emails[type eq "work"].value
+		elem := elementFromFilter(path.filter)
+		if path.sub != "" {
+			setSubAttr(elem, op, path.sub, value)
+		} else if obj, ok := value.(map[string]any); ok {
+			mergeObject(elem, obj)
+		} else {
+			return fmt.Errorf("%w：%s 的元素须为对象", apperrors.ErrSCIMInvalidValue, key)
+		}
+		result = append(result, elem)
+	}
+	resource[key] = result
+	return nil
+}
+
+func setSubAttr(obj map[string]any, op, sub string, value any) {
+	key, ok := findKey(obj, sub)
+	if !ok {
+		if key, ok = subAttrNames[sub]; !ok {
+			return
+		}
+	}
+	if op == "remove" {
+		delete(obj, key)
+		return
+	}
+	obj[key] = value
+}
+
+func mergeObject(dst, src map[string]any) {
+	for name, value := range src {
+		key, ok := findKey(dst, name)
+		if !ok {
+			if key, ok = subAttrNames[strings.ToLower(name)]; !ok {
+				key = name
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// elementFromFilter 取过滤条件中以 and 连接的 eq 比较作为新元素的子属性
+func elementFromFilter(node filterNode) map[string]any {
+	elem := map[string]any{}
+	var walk func(filterNode)
+	walk = func(n filterNode) {
+		switch expr := n.(type) {
+		case *logicalExpr:
+			if expr.op == "and" {
+				walk(expr.left)
+				walk(expr.right)
+			}
+		case *compareExpr:
+			if key, ok := subAttrNames[expr.attr]; ok && expr.op == "eq" {
+				elem[key] = expr.value
+			}
+		}
+	}
+	walk(node)
+	return elem
+}
+
+func patchValues(value any) []any {
+	if values, ok := value.([]any); ok {
+		return values
+	}
+	return []any{value}
+}
+
+// sameItem 多值属性的元素以 value 子属性区分
+func sameItem(a, b any) bool {
+	objA, okA := a.(map[string]any)
+	objB, okB := b.(map[string]any)
+	if okA && okB {
+		valueA, hasA := lookupKey(objA, "value")
+		valueB, hasB := lookupKey(objB, "value")
+		if hasA && hasB {
+			return fmt.Sprint(valueA) == fmt.Sprint(valueB)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func appendItems(items []any, value any) []any {
+	for _, v := range patchValues(value) {
+		duplicate := false
+		for _, item := range items {
+			if sameItem(item, v) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+func removeItems(items []any, value any) []any {
+	values := patchValues(value)
+	result := make([]any, 0, len(items))
+	for _, item := range items {
+		removed := false
+		for _, v := range values {
+			if sameItem(item, v) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,246 @@
+package scimservices
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+)
+
+const testPatchUser = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "1",
+	"userName": "bjensen",
+	"displayName": "Babs",
+	"name": {"givenName": "Barbara", "familyName": "Jensen"},
+	"emails": [
+		{"value": "b@work.example.com", "type": "work", "primary": true},
+		{"value": "b@home.example.com", "type": "home"}
+	],
+	"active": true
+}`
+
+const testPatchGroup = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+	"id": "10",
+	"displayName": "Engineering",
+	"members": [
+		{"value": "2", "type": "User"},
+		{"value": "3", "type": "User"}
+	]
+}`
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource string
+		attrs    resourceAttrs
+		ops      string
+		// 期望的顶层属性（JSON），空字符串表示属性不存在；未列出的属性不检查
+		want map[string]string
+	}{
+		{
+			name: "replace 单值属性", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "replace", "path": "displayName", "value": "Barbara"}]`,
+			want: map[string]string{"displayName": `"Barbara"`},
+		},
+		{
+			name: "带核心 schema 前缀的路径", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "replace", "path": "urn:ietf:params:scim:schemas:core:2.0:User:displayName", "value": "Barbara"}]`,
+			want: map[string]string{"displayName": `"Barbara"`},
+		},
+		{
+			name: "replace 复合属性的子属性", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "replace", "path": "name.GivenName", "value": "Babs"}]`,
+			want: map[string]string{"name": `{"givenName": "Babs", "familyName": "Jensen"}`},
+		},
+		{
+			name: "remove 复合属性的子属性", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "remove", "path": "name.familyName"}]`,
+			want: map[string]string{"name": `{"givenName": "Barbara"}`},
+		},
+		{
+			name: "remove 单值属性", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "remove", "path": "displayName"}]`,
+			want: map[string]string{"displayName": ""},
+		},
+		{
+			name: "add 多值属性追加元素并按 value 去重", resource: testPatchUser, attrs: userAttrs,
+			ops: `[{"op": "add", "path": "emails", "value": [{"value": "b@home.example.com"}, {"value": "b@new.example.com", "type": "other"}]}]`,
+			want: map[string]string{"emails": `[
+				{"value": "b@work.example.com", "type": "work", "primary": true},
+				{"value": "b@home.example.com", "type": "home"},
+				{"value": "b@new.example.com", "type": "other"}
+			]`},
+		},
+		{
+			name: "replace 多值属性整体替换", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "replace", "path": "emails", "value": [{"value": "only@example.com"}]}]`,
+			want: map[string]string{"emails": `[{"value": "only@example.com"}]`},
+		},
+		{
+			name: "replace 过滤匹配元素的子属性", resource: testPatchUser, attrs: userAttrs,
+			ops: `[{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "x@work.example.com"}]`,
+			want: map[string]string{"emails": `[
+				{"value": "x@work.example.com", "type": "work", "primary": true},
+				{"value": "b@home.example.com", "type": "home"}
+			]`},
+		},
+		{
+			name: "replace 过滤匹配的元素合并对象", resource: testPatchUser, attrs: userAttrs,
+			ops: `[{"op": "replace", "path": "emails[type eq \"home\"]", "value": {"primary": false}}]`,
+			want: map[string]string{"emails": `[
+				{"value": "b@work.example.com", "type": "work", "primary": true},
+				{"value": "b@home.example.com", "type": "home", "primary": false}
+			]`},
+		},
+		{
+			name: "remove 过滤匹配的元素", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "remove", "path": "emails[type eq \"home\"]"}]`,
+			want: map[string]string{"emails": `[{"value": "b@work.example.com", "type": "work", "primary": true}]`},
+		},
+		{
+			name: "remove 各元素的子属性", resource: testPatchUser, attrs: userAttrs,
+			ops: `[{"op": "remove", "path": "emails.type"}]`,
+			want: map[string]string{"emails": `[
+				{"value": "b@work.example.com", "primary": true},
+				{"value": "b@home.example.com"}
+			]`},
+		},
+		{
+			name: "add 过滤无匹配时按条件新建元素", resource: testPatchUser, attrs: userAttrs,
+			ops: `[{"op": "add", "path": "emails[type eq \"other\"].value", "value": "o@example.com"}]`,
+			want: map[string]string{"emails": `[
+				{"value": "b@work.example.com", "type": "work", "primary": true},
+				{"value": "b@home.example.com", "type": "home"},
+				{"type": "other", "value": "o@example.com"}
+			]`},
+		},
+		{
+			name: "remove 过滤无匹配时不报错", resource: testPatchUser, attrs: userAttrs,
+			ops: `[{"op": "remove", "path": "emails[type eq \"other\"]"}]`,
+			want: map[string]string{"emails": `[
+				{"value": "b@work.example.com", "type": "work", "primary": true},
+				{"value": "b@home.example.com", "type": "home"}
+			]`},
+		},
+		{
+			name: "未指定 path 时按对象逐个属性处理并忽略只读属性", resource: testPatchUser, attrs: userAttrs,
+			ops:  `[{"op": "replace", "value": {"displayName": "X", "id": "9", "active": false, "name": {"familyName": "J"}}}]`,
+			want: map[string]string{"displayName": `"X"`, "id": `"1"`, "active": "false", "name": `{"givenName": "Barbara", "familyName": "J"}`},
+		},
+		{
+			name: "未支持的属性与扩展 schema 的属性被忽略", resource: testPatchUser, attrs: userAttrs,
+			ops: `[
+				{"op": "replace", "path": "nickName", "value": "b"},
+				{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", "value": "42"}
+			]`,
+			want: map[string]string{"nickName": "", "displayName": `"Babs"`},
+		},
+		{
+			name: "多个操作依次应用", resource: testPatchUser, attrs: userAttrs,
+			ops: `[
+				{"op": "replace", "path": "active", "value": false},
+				{"op": "remove", "path": "emails[type eq \"work\"]"},
+				{"op": "replace", "path": "emails[type eq \"home\"].primary", "value": true}
+			]`,
+			want: map[string]string{"active": "false", "emails": `[{"value": "b@home.example.com", "type": "home", "primary": true}]`},
+		},
+		{
+			name: "remove 以 value 指定要移除的成员", resource: testPatchGroup, attrs: groupAttrs,
+			ops:  `[{"op": "remove", "path": "members", "value": [{"value": "2"}]}]`,
+			want: map[string]string{"members": `[{"value": "3", "type": "User"}]`},
+		},
+		{
+			name: "remove 过滤匹配的成员", resource: testPatchGroup, attrs: groupAttrs,
+			ops:  `[{"op": "remove", "path": "members[value eq \"3\"]"}]`,
+			want: map[string]string{"members": `[{"value": "2", "type": "User"}]`},
+		},
+		{
+			name: "remove 全部成员", resource: testPatchGroup, attrs: groupAttrs,
+			ops:  `[{"op": "remove", "path": "members"}]`,
+			want: map[string]string{"members": ""},
+		},
+		{
+			name: "add 成员", resource: testPatchGroup, attrs: groupAttrs,
+			ops:  `[{"op": "add", "path": "members", "value": [{"value": "4"}, {"value": "2"}]}]`,
+			want: map[string]string{"members": `[{"value": "2", "type": "User"}, {"value": "3", "type": "User"}, {"value": "4"}]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := decodePatchResource(t, tt.resource)
+			if err := applyPatch(resource, tt.attrs, decodePatchOps(t, tt.ops)); err != nil {
+				t.Fatalf("applyPatch 失败: %v", err)
+			}
+			for key, want := range tt.want {
+				got, exists := resource[key]
+				if want == "" {
+					if exists {
+						t.Fatalf("%s = %#v，期望已移除", key, got)
+					}
+					continue
+				}
+				var wantValue any
+				if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, wantValue) {
+					gotJSON, _ := json.Marshal(got)
+					t.Fatalf("%s = %s，期望 %s", key, gotJSON, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPatchInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  string
+		err  error
+	}{
+		{"缺少操作", `[]`, apperrors.ErrSCIMInvalidSyntax},
+		{"不支持的操作", `[{"op": "move", "path": "displayName", "value": "x"}]`, apperrors.ErrSCIMInvalidSyntax},
+		{"remove 未指定 path", `[{"op": "remove"}]`, apperrors.ErrSCIMNoTarget},
+		{"未指定 path 时 value 不是对象", `[{"op": "replace", "value": "x"}]`, apperrors.ErrSCIMInvalidValue},
+		{"replace 缺少 value", `[{"op": "replace", "path": "displayName"}]`, apperrors.ErrSCIMInvalidValue},
+		{"修改只读属性", `[{"op": "replace", "path": "id", "value": "9"}]`, apperrors.ErrSCIMMutability},
+		{"过滤缺少右括号", `[{"op": "remove", "path": "emails[type eq \"work\""}]`, apperrors.ErrSCIMInvalidPath},
+		{"过滤后不是子属性", `[{"op": "remove", "path": "emails[type eq \"work\"]value"}]`, apperrors.ErrSCIMInvalidPath},
+		{"子属性嵌套过深", `[{"op": "replace", "path": "name.givenName.first", "value": "x"}]`, apperrors.ErrSCIMInvalidPath},
+		{"路径以点结尾", `[{"op": "replace", "path": "name.", "value": "x"}]`, apperrors.ErrSCIMInvalidPath},
+		{"路径中的过滤无效", `[{"op": "remove", "path": "emails[type xx \"work\"]"}]`, apperrors.ErrSCIMInvalidFilter},
+		{"replace 过滤无匹配", `[{"op": "replace", "path": "emails[type eq \"other\"].value", "value": "x"}]`, apperrors.ErrSCIMNoTarget},
+		{"过滤匹配的元素以非对象替换", `[{"op": "replace", "path": "emails[type eq \"work\"]", "value": "x"}]`, apperrors.ErrSCIMInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := decodePatchResource(t, testPatchUser)
+			if err := applyPatch(resource, userAttrs, decodePatchOps(t, tt.ops)); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v，期望 %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func decodePatchResource(t *testing.T, data string) map[string]any {
+	t.Helper()
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(data), &resource); err != nil {
+		t.Fatal(err)
+	}
+	return resource
+}
+
+func decodePatchOps(t *testing.T, data string) []scimdto.PatchOperation {
+	t.Helper()
+	var ops []scimdto.PatchOperation
+	if err := json.Unmarshal([]byte(data), &ops); err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
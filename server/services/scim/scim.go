@@ -0,0 +1,120 @@
+package scimservices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+)
+
+const (
+	// MaxResults 单次查询最多返回的资源数
+	MaxResults = 200
+	// DefaultCount 未指定 count 时每页返回的资源数
+	DefaultCount = 100
+)
+
+// page 将 1 起始的 startIndex 与 count 转换为偏移量，count 为 0 时只统计总数
+func page(startIndex, count int) (offset, limit, start int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > MaxResults {
+		count = MaxResults
+	}
+	return startIndex - 1, count, startIndex
+}
+
+func listResponse(total int64, start int, resources []any) *scimdto.ListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+	return &scimdto.ListResponse{
+		Schemas:      []string{scimdto.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   start,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// setVersion 以资源表示的摘要作为弱 ETag，资源或其成员关系变化时随之变化
+func setVersion(resource any, meta *scimdto.Meta) {
+	meta.Version = ""
+	data, _ := json.Marshal(resource)
+	sum := sha256.Sum256(data)
+	meta.Version = `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkVersion 校验 If-Match，为空或 * 时不做限制
+func checkVersion(ifMatch, version string) error {
+	if ifMatch == "" {
+		return nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(version, "W/") {
+			return nil
+		}
+	}
+	return apperrors.ErrSCIMPreconditionFailed
+}
+
+// NotModified 判断 If-None-Match 是否与当前版本一致
+func NotModified(ifNoneMatch, version string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(version, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// toMap 将资源转换为 JSON 对象，供 PATCH 操作修改
+func toMap(resource any) (map[string]any, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// fromMap 将 PATCH 后的 JSON 对象转换回资源，类型不符时视为属性值无效
+func fromMap(m map[string]any, resource any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidValue, err.Error())
+	}
+	if err := json.Unmarshal(data, resource); err != nil {
+		return fmt.Errorf("%w：%s", apperrors.ErrSCIMInvalidValue, err.Error())
+	}
+	return nil
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
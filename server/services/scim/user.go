@@ -0,0 +1,429 @@
+package scimservices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/3086953492/gokit/logger"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto/scim"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/services"
+	"goauth/utils"
+)
+
+var userColumns = map[string]filterColumn{
+	"id":                {column: "users.id", kind: columnID},
+	"username":          {column: "users.username"},
+	"externalid":        {column: "users.external_id"},
+	"displayname":       {column: "users.nickname"},
+	"name.formatted":    {column: "users.nickname"},
+	"emails":            {column: "users.email"},
+	"emails.value":      {column: "users.email"},
+	"active":            {column: "users.status", kind: columnActive},
+	"meta.created":      {column: "users.created_at", kind: columnTime},
+	"meta.lastmodified": {column: "users.updated_at", kind: columnTime},
+	"groups":            {column: "EXISTS (SELECT 1 FROM user_group_members WHERE user_group_members.user_id = users.id%s)", kind: columnMember, valueColumn: "user_group_members.group_id"},
+	"groups.value":      {column: "EXISTS (SELECT 1 FROM user_group_members WHERE user_group_members.user_id = users.id%s)", kind: columnMember, valueColumn: "user_group_members.group_id"},
+}
+
+// SCIMUserService SCIM 用户资源，映射到本系统的用户
+// 预配方推送的账号视为由其统一管理：邮箱视为已验证，active 对应账号是否可登录
+type SCIMUserService struct {
+	userService         *services.UserService
+	userRepository      *repositories.UserRepository
+	userGroupRepository *repositories.UserGroupRepository
+	logMgr              *logger.Manager
+	baseURL             string
+}
+
+func NewSCIMUserService(userService *services.UserService, userRepository *repositories.UserRepository, userGroupRepository *repositories.UserGroupRepository, logMgr *logger.Manager, baseURL string) *SCIMUserService {
+	return &SCIMUserService{userService: userService, userRepository: userRepository, userGroupRepository: userGroupRepository, logMgr: logMgr, baseURL: baseURL}
+}
+
+func (s *SCIMUserService) Get(ctx context.Context, id string) (*scimdto.User, error) {
+	user, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.render(ctx, user)
+}
+
+func (s *SCIMUserService) List(ctx context.Context, filter string, startIndex, count int) (*scimdto.ListResponse, error) {
+	var query string
+	var args []any
+	if filter != "" {
+		node, err := parseFilter(filter, scimdto.SchemaUser)
+		if err != nil {
+			return nil, err
+		}
+		if query, args, err = compileFilter(node, userColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	offset, limit, start := page(startIndex, count)
+	users, total, err := s.userRepository.Search(ctx, offset, limit, query, args...)
+	if err != nil {
+		s.logMgr.Error("查询SCIM用户失败", "error", err, "filter", filter)
+		return nil, errors.New("查询用户失败")
+	}
+	resources, err := s.renderList(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+	return listResponse(total, start, resources), nil
+}
+
+func (s *SCIMUserService) Create(ctx context.Context, req *scimdto.User) (*scimdto.User, error) {
+	username, err := validateUserName(req.UserName)
+	if err != nil {
+		return nil, err
+	}
+	email, err := primaryEmail(req.Emails)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUnique(ctx, 0, username, email); err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:   username,
+		Nickname:   userNickname(req),
+		ExternalID: optionalString(req.ExternalID),
+		Status:     models.UserStatusActive,
+	}
+	if req.Active != nil && !*req.Active {
+		user.Status = models.UserStatusDisabled
+	}
+	if email != nil {
+		now := time.Now()
+		user.Email = email
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.userService.CreateProvisionedUser(ctx, user, req.Password); err != nil {
+		return nil, err
+	}
+
+	s.logMgr.Info("SCIM创建用户成功", "user_id", user.ID, "external_id", req.ExternalID)
+	return s.Get(ctx, strconv.FormatUint(uint64(user.ID), 10))
+}
+
+// Replace 以请求替换用户的可写属性，未提交的 externalId 与邮箱视为清除，未提交的 active 与密码保持不变
+func (s *SCIMUserService) Replace(ctx context.Context, id string, req *scimdto.User, ifMatch string) (*scimdto.User, error) {
+	user, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVersion(ctx, user, ifMatch); err != nil {
+		return nil, err
+	}
+	if err := s.replace(ctx, user, req); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Patch 在用户当前的表示上应用 PATCH 操作，再按替换处理
+func (s *SCIMUserService) Patch(ctx context.Context, id string, req *scimdto.PatchRequest, ifMatch string) (*scimdto.User, error) {
+	user, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.render(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	resource, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPatch(resource, userAttrs, req.Operations); err != nil {
+		return nil, err
+	}
+	// 部分预配方将 active 以字符串 "True" / "False" 提交
+	if active, ok := resource["active"].(string); ok {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return nil, fmt.Errorf("%w：active", apperrors.ErrSCIMInvalidValue)
+		}
+		resource["active"] = value
+	}
+	var patched scimdto.User
+	if err := fromMap(resource, &patched); err != nil {
+		return nil, err
+	}
+
+	if err := s.replace(ctx, user, &patched); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete 删除用户并将其从所有用户组中移除
+func (s *SCIMUserService) Delete(ctx context.Context, id string, ifMatch string) error {
+	user, err := s.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkVersion(ctx, user, ifMatch); err != nil {
+		return err
+	}
+	if err := s.userService.DeleteUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.userGroupRepository.DeleteUserMemberships(ctx, user.ID); err != nil {
+		s.logMgr.Warn("移除用户组成员关系失败", "error", err, "user_id", user.ID)
+	}
+
+	s.logMgr.Info("SCIM删除用户成功", "user_id", user.ID)
+	return nil
+}
+
+func (s *SCIMUserService) replace(ctx context.Context, user *models.User, req *scimdto.User) error {
+	username, err := validateUserName(req.UserName)
+	if err != nil {
+		return err
+	}
+	email, err := primaryEmail(req.Emails)
+	if err != nil {
+		return err
+	}
+
+	updates := make(map[string]any)
+	var checkUsername string
+	var checkEmail *string
+	if username != user.Username {
+		updates["username"] = username
+		checkUsername = username
+	}
+	if nickname := userNickname(req); nickname != user.Nickname {
+		updates["nickname"] = nickname
+	}
+	if externalID := optionalString(req.ExternalID); !equalOptional(externalID, user.ExternalID) {
+		updates["external_id"] = externalID
+	}
+	if !equalOptional(email, user.Email) {
+		updates["email"] = email
+		updates["email_verified_at"] = nil
+		if email != nil {
+			updates["email_verified_at"] = time.Now()
+			checkEmail = email
+		}
+	}
+	if req.Active != nil {
+		switch {
+		case *req.Active && user.Status != models.UserStatusActive:
+			updates["status"] = models.UserStatusActive
+		case !*req.Active && user.Status == models.UserStatusActive:
+			updates["status"] = models.UserStatusDisabled
+		}
+	}
+	if err := s.checkUnique(ctx, user.ID, checkUsername, checkEmail); err != nil {
+		return err
+	}
+
+	if err := s.userService.UpdateProvisionedUser(ctx, user, updates, req.Password); err != nil {
+		return err
+	}
+	if len(updates) > 0 {
+		s.logMgr.Info("SCIM更新用户成功", "user_id", user.ID)
+	}
+	return nil
+}
+
+// load 直接读取数据库而不经过用户缓存，保证版本与数据库一致
+func (s *SCIMUserService) load(ctx context.Context, id string) (*models.User, error) {
+	userID, ok := resourceID(id)
+	if !ok {
+		return nil, apperrors.ErrSCIMNotFound
+	}
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrSCIMNotFound
+		}
+		s.logMgr.Error("查询SCIM用户失败", "error", err, "id", id)
+		return nil, errors.New("查询用户失败")
+	}
+	return user, nil
+}
+
+func (s *SCIMUserService) checkVersion(ctx context.Context, user *models.User, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+	current, err := s.render(ctx, user)
+	if err != nil {
+		return err
+	}
+	return checkVersion(ifMatch, current.Meta.Version)
+}
+
+// checkUnique 用户名与邮箱的唯一索引包含已删除的账号，exceptID 为正在更新的用户
+func (s *SCIMUserService) checkUnique(ctx context.Context, exceptID uint, username string, email *string) error {
+	if username != "" {
+		existing, err := s.userRepository.GetWithDeleted(ctx, map[string]any{"username": username})
+		if err == nil && existing.ID != exceptID {
+			return fmt.Errorf("%w：userName", apperrors.ErrSCIMUniqueness)
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("查询用户失败", "error", err, "username", username)
+			return errors.New("查询用户失败")
+		}
+	}
+	if email != nil {
+		existing, err := s.userRepository.GetWithDeleted(ctx, map[string]any{"email": *email})
+		if err == nil && existing.ID != exceptID {
+			return fmt.Errorf("%w：emails", apperrors.ErrSCIMUniqueness)
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("查询用户失败", "error", err, "email", *email)
+			return errors.New("查询用户失败")
+		}
+	}
+	return nil
+}
+
+func (s *SCIMUserService) render(ctx context.Context, user *models.User) (*scimdto.User, error) {
+	groups, err := s.userGroupRepository.ListUserGroups(ctx, []uint{user.ID})
+	if err != nil {
+		s.logMgr.Error("查询用户所属用户组失败", "error", err, "user_id", user.ID)
+		return nil, errors.New("查询用户失败")
+	}
+	return s.toResource(ctx, user, groups[user.ID]), nil
+}
+
+func (s *SCIMUserService) renderList(ctx context.Context, users []models.User) ([]any, error) {
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	groups, err := s.userGroupRepository.ListUserGroups(ctx, ids)
+	if err != nil {
+		s.logMgr.Error("查询用户所属用户组失败", "error", err)
+		return nil, errors.New("查询用户失败")
+	}
+	resources := make([]any, len(users))
+	for i := range users {
+		resources[i] = s.toResource(ctx, &users[i], groups[users[i].ID])
+	}
+	return resources, nil
+}
+
+func (s *SCIMUserService) toResource(ctx context.Context, user *models.User, groups []models.UserGroup) *scimdto.User {
+	endpoint := utils.RealmURL(ctx, s.baseURL, "/scim/v2")
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	active := user.Status == models.UserStatusActive
+	resource := &scimdto.User{
+		Schemas:     []string{scimdto.SchemaUser},
+		ID:          id,
+		UserName:    user.Username,
+		Name:        &scimdto.Name{Formatted: user.Nickname},
+		DisplayName: user.Nickname,
+		Active:      &active,
+		Meta: &scimdto.Meta{
+			ResourceType: "User",
+			Created:      formatTime(user.CreatedAt),
+			LastModified: formatTime(user.UpdatedAt),
+			Location:     endpoint + "/Users/" + id,
+		},
+	}
+	if user.ExternalID != nil {
+		resource.ExternalID = *user.ExternalID
+	}
+	if user.Email != nil {
+		resource.Emails = []scimdto.Email{{Value: *user.Email, Type: "work", Primary: true}}
+	}
+	for _, group := range groups {
+		groupID := strconv.FormatUint(uint64(group.ID), 10)
+		resource.Groups = append(resource.Groups, scimdto.GroupRef{
+			Value:   groupID,
+			Ref:     endpoint + "/Groups/" + groupID,
+			Display: group.DisplayName,
+			Type:    "direct",
+		})
+	}
+	setVersion(resource, resource.Meta)
+	return resource
+}
+
+// validateUserName 用户名长度与本地注册一致，不能包含空白字符
+func validateUserName(userName string) (string, error) {
+	username := strings.TrimSpace(userName)
+	if username == "" {
+		return "", fmt.Errorf("%w：userName 不能为空", apperrors.ErrSCIMInvalidValue)
+	}
+	if utf8.RuneCountInString(username) > 50 || strings.IndexFunc(username, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("%w：userName 不能超过50个字符且不能包含空白字符", apperrors.ErrSCIMInvalidValue)
+	}
+	return username, nil
+}
+
+// primaryEmail 取主邮箱，未标记主邮箱时取第一个，统一保存为小写
+func primaryEmail(emails []scimdto.Email) (*string, error) {
+	var value string
+	for _, email := range emails {
+		if email.Value == "" {
+			continue
+		}
+		if value == "" || email.Primary {
+			value = email.Value
+		}
+		if email.Primary {
+			break
+		}
+	}
+	if value == "" {
+		return nil, nil
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value || len(value) > 255 {
+		return nil, fmt.Errorf("%w：emails", apperrors.ErrSCIMInvalidValue)
+	}
+	return &value, nil
+}
+
+// userNickname 依次取 displayName、name.formatted、姓名与用户名，截断到昵称允许的长度
+func userNickname(user *scimdto.User) string {
+	nickname := strings.TrimSpace(user.DisplayName)
+	if nickname == "" && user.Name != nil {
+		nickname = strings.TrimSpace(user.Name.Formatted)
+		if nickname == "" {
+			nickname = strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName)
+		}
+	}
+	if nickname == "" {
+		nickname = strings.TrimSpace(user.UserName)
+	}
+	return truncateRunes(nickname, 20)
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func equalOptional(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"github.com/3086953492/gokit/security/random"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const (
+	sessionKeyPrefix     = "session:"      // 有效会话，值为用户ID，TTL 为会话剩余有效期
+	sessionSeenKeyPrefix = "session:seen:" // 最后活跃时间的写库节流
+	sessionSeenInterval  = time.Minute
+
+	// refreshTokenReuseGrace 已轮换刷新令牌的重复使用宽限期，容忍多个标签页同时刷新
+	refreshTokenReuseGrace = 10 * time.Second
+)
+
+// LogoutNotifier 通知持有用户有效授权的客户端该用户已退出登录，返回需由浏览器加载的前端通道登出地址
+// sessionID 非空时只通知在该登录会话中取得授权的客户端
+// 由 OIDC 登出服务实现，该服务位于 oauth 包并依赖本包，只能在创建后注入
+type LogoutNotifier interface {
+	NotifyLogout(ctx context.Context, userID uint, sessionID string) []string
+}
+
+// SessionService 第一方登录会话服务
+// 会话记录保存在数据库，Redis 中保存有效会话的副本供每次请求校验，注销时同时删除
+type SessionService struct {
+	sessionRepository      *repositories.SessionRepository
+	refreshTokenRepository *repositories.RefreshTokenRepository
+	redisMgr               *redis.Manager
+	tokenHasher            *utils.TokenHasher
+	logMgr                 *logger.Manager
+	auditService           *AuditService
+	logoutNotifier         LogoutNotifier
+	ttl                    time.Duration
+}
+
+// NewSessionService 创建登录会话服务实例，ttl 为会话（即刷新令牌）有效期
+func NewSessionService(sessionRepository *repositories.SessionRepository, refreshTokenRepository *repositories.RefreshTokenRepository, redisMgr *redis.Manager, tokenHasher *utils.TokenHasher, logMgr *logger.Manager, auditService *AuditService, ttl time.Duration) *SessionService {
+	return &SessionService{sessionRepository: sessionRepository, refreshTokenRepository: refreshTokenRepository, redisMgr: redisMgr, tokenHasher: tokenHasher, logMgr: logMgr, auditService: auditService, ttl: ttl}
+}
+
+// SetLogoutNotifier 设置退出登录时通知客户端的实现
+func (s *SessionService) SetLogoutNotifier(logoutNotifier LogoutNotifier) {
+	s.logoutNotifier = logoutNotifier
+}
+
+// CreateSession 创建登录会话并签发首个刷新令牌，返回刷新令牌原文（数据库只保存摘要）
+func (s *SessionService) CreateSession(ctx context.Context, userID uint, ip, userAgent string) (*models.Session, string, error) {
+	refreshToken, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成刷新令牌失败", "error", err)
+		return nil, "", apperrors.ErrSessionCreateFailed
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		SessionID:        uuid.NewString(),
+		UserID:           userID,
+		RefreshTokenHash: s.tokenHasher.Hash(refreshToken),
+		Device:           utils.DeviceFromUserAgent(userAgent),
+		IP:               ip,
+		UserAgent:        truncate(userAgent, 500),
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(s.ttl),
+	}
+	txErr := s.sessionRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.sessionRepository.CreateWithTx(ctx, tx, session); err != nil {
+			return err
+		}
+		return s.refreshTokenRepository.CreateWithTx(ctx, tx, &models.RefreshToken{
+			SessionID: session.SessionID,
+			UserID:    userID,
+			TokenHash: session.RefreshTokenHash,
+			ExpiresAt: session.ExpiresAt,
+		})
+	})
+	if txErr != nil {
+		s.logMgr.Error("创建会话失败", "error", txErr, "user_id", userID)
+		return nil, "", apperrors.ErrSessionCreateFailed
+	}
+
+	s.remember(ctx, session)
+	return session, refreshToken, nil
+}
+
+// RotateRefreshToken 使用刷新令牌换取新的刷新令牌，旧令牌随即作废
+// 会话过期时间在创建时确定，轮换出的令牌沿用会话过期时间，不随使用顺延，被盗用的令牌最迟随会话一同过期
+// 已轮换的令牌在宽限期内再次出现视为并发刷新，仅拒绝本次请求；超过宽限期视为令牌泄露，注销整个会话
+func (s *SessionService) RotateRefreshToken(ctx context.Context, refreshToken string) (*models.Session, string, error) {
+	record, err := s.refreshTokenRepository.Get(ctx, map[string]any{"token_hash": s.tokenHasher.Hash(refreshToken)})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", apperrors.ErrSessionInvalid
+		}
+		s.logMgr.Error("获取刷新令牌失败", "error", err)
+		return nil, "", apperrors.ErrUserSystemBusy
+	}
+	if record.ReplacedAt != nil {
+		return nil, "", s.handleRefreshTokenReuse(ctx, record)
+	}
+	if record.ExpiresAt.Before(time.Now()) {
+		return nil, "", apperrors.ErrSessionInvalid
+	}
+
+	session, err := s.sessionRepository.Get(ctx, map[string]any{"session_id": record.SessionID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", apperrors.ErrSessionInvalid
+		}
+		s.logMgr.Error("获取会话失败", "error", err, "session_id", record.SessionID)
+		return nil, "", apperrors.ErrUserSystemBusy
+	}
+	if session.Revoked || session.ExpiresAt.Before(time.Now()) {
+		return nil, "", apperrors.ErrSessionInvalid
+	}
+
+	newRefreshToken, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成刷新令牌失败", "error", err)
+		return nil, "", apperrors.ErrUserSystemBusy
+	}
+
+	now := time.Now()
+	newTokenHash := s.tokenHasher.Hash(newRefreshToken)
+	txErr := s.sessionRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows, err := s.refreshTokenRepository.MarkReplacedWithTx(ctx, tx, record.ID, now)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return apperrors.ErrRefreshTokenRotated
+		}
+		if err := s.refreshTokenRepository.CreateWithTx(ctx, tx, &models.RefreshToken{
+			SessionID: session.SessionID,
+			UserID:    session.UserID,
+			TokenHash: newTokenHash,
+			ExpiresAt: session.ExpiresAt,
+		}); err != nil {
+			return err
+		}
+		return s.sessionRepository.UpdateWithTx(ctx, tx, session.ID, map[string]any{"refresh_token_hash": newTokenHash})
+	})
+	if txErr != nil {
+		if errors.Is(txErr, apperrors.ErrRefreshTokenRotated) {
+			return nil, "", txErr
+		}
+		s.logMgr.Error("轮换刷新令牌失败", "error", txErr, "session_id", session.SessionID)
+		return nil, "", apperrors.ErrUserSystemBusy
+	}
+
+	session.RefreshTokenHash = newTokenHash
+	s.remember(ctx, session)
+	return session, newRefreshToken, nil
+}
+
+// handleRefreshTokenReuse 处理已轮换刷新令牌的再次使用
+func (s *SessionService) handleRefreshTokenReuse(ctx context.Context, record *models.RefreshToken) error {
+	if time.Since(*record.ReplacedAt) <= refreshTokenReuseGrace {
+		return apperrors.ErrRefreshTokenRotated
+	}
+
+	s.logMgr.Warn("检测到刷新令牌重复使用，注销会话", "session_id", record.SessionID, "user_id", record.UserID)
+	if err := s.RevokeSession(ctx, record.UserID, record.SessionID); err != nil && !errors.Is(err, apperrors.ErrSessionNotFound) {
+		return err
+	}
+	return apperrors.ErrRefreshTokenReused
+}
+
+// ValidateSession 校验会话属于该用户且仍然有效，并按节流间隔记录最后活跃时间与 IP
+func (s *SessionService) ValidateSession(ctx context.Context, sessionID string, userID uint, ip string) error {
+	if sessionID == "" {
+		return apperrors.ErrSessionInvalid
+	}
+
+	data, err := s.redisMgr.GetBytes(ctx, sessionKeyPrefix+sessionID)
+	if err != nil {
+		s.logMgr.Warn("读取会话失败", "error", err, "session_id", sessionID)
+	}
+	if data != nil {
+		if string(data) != strconv.FormatUint(uint64(userID), 10) {
+			return apperrors.ErrSessionInvalid
+		}
+	} else {
+		// Redis 中没有副本时回源数据库
+		session, err := s.sessionRepository.Get(ctx, map[string]any{"session_id": sessionID})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.ErrSessionInvalid
+			}
+			s.logMgr.Error("获取会话失败", "error", err, "session_id", sessionID)
+			return apperrors.ErrUserSystemBusy
+		}
+		if session.UserID != userID || session.Revoked || session.ExpiresAt.Before(time.Now()) {
+			return apperrors.ErrSessionInvalid
+		}
+		s.remember(ctx, session)
+	}
+
+	s.touch(ctx, sessionID, ip)
+	return nil
+}
+
+// ListSessions 列出用户的有效会话，currentSessionID 对应的会话标记为当前会话
+func (s *SessionService) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]dto.SessionResponse, error) {
+	sessions, err := s.sessionRepository.Find(ctx, map[string]any{"user_id": userID, "revoked": false, "expires_at > ?": time.Now()})
+	if err != nil {
+		s.logMgr.Error("获取会话列表失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+
+	sessionsResponse := make([]dto.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		sessionsResponse[i] = dto.SessionResponse{
+			SessionID:  session.SessionID,
+			Device:     session.Device,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			LastSeenAt: session.LastSeenAt,
+			CreatedAt:  session.CreatedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.SessionID == currentSessionID,
+		}
+	}
+	return sessionsResponse, nil
+}
+
+// RevokeSession 注销用户的指定会话，并通知在该会话中取得授权的客户端
+// 会话可能属于其他设备，前端通道登出地址无法由当前浏览器加载，只发送后端通道登出通知
+func (s *SessionService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	err := s.revoke(ctx, userID, sessionID)
+	s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditSessionRevoke, UserID: &userID, TargetType: "session", TargetID: sessionID}, err)
+	if err != nil {
+		return err
+	}
+	s.notifyLogout(ctx, userID, sessionID)
+	return nil
+}
+
+// revoke 注销会话，不记录审计事件，由调用方按场景（注销会话或退出登录）记录
+func (s *SessionService) revoke(ctx context.Context, userID uint, sessionID string) error {
+	session, err := s.sessionRepository.Get(ctx, map[string]any{"session_id": sessionID, "user_id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrSessionNotFound
+		}
+		s.logMgr.Error("获取会话失败", "error", err, "session_id", sessionID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if !session.Revoked {
+		if err := s.sessionRepository.Update(ctx, session.ID, map[string]any{"revoked": true}); err != nil {
+			s.logMgr.Error("注销会话失败", "error", err, "session_id", sessionID)
+			return apperrors.ErrSessionRevokeFailed
+		}
+	}
+	// 已注销的会话同样删除副本，上次删除失败时重试即可生效
+	if err := s.forget(ctx, sessionID); err != nil {
+		return apperrors.ErrSessionRevokeFailed
+	}
+	return nil
+}
+
+// RevokeAllSessions 注销用户的全部会话并通知客户端，返回前端通道登出地址
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID uint) (frontchannelLogoutURIs []string, err error) {
+	defer func() {
+		s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditSessionRevoke, UserID: &userID, TargetType: "session", TargetID: "*"}, err)
+	}()
+
+	// 包含已注销但未过期的会话，上次删除副本失败时重试即可生效
+	sessions, err := s.sessionRepository.Find(ctx, map[string]any{"user_id": userID, "expires_at > ?": time.Now()})
+	if err != nil {
+		s.logMgr.Error("获取会话列表失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+
+	if err := s.sessionRepository.UpdateByConds(ctx, map[string]any{"user_id": userID, "revoked": false}, map[string]any{"revoked": true}); err != nil {
+		s.logMgr.Error("注销会话失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrSessionRevokeFailed
+	}
+	sessionIDs := make([]string, len(sessions))
+	for i, session := range sessions {
+		sessionIDs[i] = session.SessionID
+	}
+	if err := s.forget(ctx, sessionIDs...); err != nil {
+		return nil, apperrors.ErrSessionRevokeFailed
+	}
+	return s.notifyLogout(ctx, userID, ""), nil
+}
+
+// notifyLogout 通知客户端用户已退出登录，返回前端通道登出地址；未设置通知实现时不通知
+// sessionID 为空时通知持有该用户有效授权的全部客户端，否则只通知在该会话中取得授权的客户端
+func (s *SessionService) notifyLogout(ctx context.Context, userID uint, sessionID string) []string {
+	if s.logoutNotifier == nil {
+		return nil
+	}
+	return s.logoutNotifier.NotifyLogout(ctx, userID, sessionID)
+}
+
+// remember 在 Redis 中记录有效会话，过期时间与会话一致
+func (s *SessionService) remember(ctx context.Context, session *models.Session) {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+	if err := s.redisMgr.SetBytes(ctx, sessionKeyPrefix+session.SessionID, []byte(strconv.FormatUint(uint64(session.UserID), 10)), ttl); err != nil {
+		s.logMgr.Warn("写入会话失败", "error", err, "session_id", session.SessionID)
+	}
+}
+
+// forget 删除 Redis 中的会话副本
+// 副本未删除时已注销的会话在其过期前仍能通过校验，失败时返回错误，由调用方告知用户重试
+func (s *SessionService) forget(ctx context.Context, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(sessionIDs)*2)
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, sessionKeyPrefix+sessionID, sessionSeenKeyPrefix+sessionID)
+	}
+	if _, err := s.redisMgr.Del(ctx, keys...); err != nil {
+		s.logMgr.Error("删除会话失败", "error", err, "session_ids", sessionIDs)
+		return err
+	}
+	return nil
+}
+
+// touch 记录会话最后活跃时间与 IP，每个会话在节流间隔内最多写库一次
+func (s *SessionService) touch(ctx context.Context, sessionID, ip string) {
+	ok, err := s.redisMgr.SetNX(ctx, sessionSeenKeyPrefix+sessionID, "1", sessionSeenInterval)
+	if err != nil || !ok {
+		return
+	}
+	if err := s.sessionRepository.UpdateByConds(ctx, map[string]any{"session_id": sessionID}, map[string]any{"last_seen_at": time.Now(), "ip": ip}); err != nil {
+		s.logMgr.Warn("更新会话活跃时间失败", "error", err, "session_id", sessionID)
+	}
+}
+
+// truncate 按字节截断字符串，避免超出列宽
+func truncate(str string, n int) string {
+	if len(str) <= n {
+		return str
+	}
+	return str[:n]
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3086953492/gokit/cache"
+	"github.com/3086953492/gokit/config"
+	"github.com/3086953492/gokit/jwt"
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/security/random"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// tenantNamePattern 租户标识出现在路径与发行方地址中，只允许小写字母、数字与连字符
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// TenantService 租户服务，解析请求所属的租户，并提供租户的发行方地址与第一方令牌签名
+// 默认租户沿用配置文件中的发行方与签名密钥，升级前签发的令牌继续有效；其他租户各有独立的签名密钥
+type TenantService struct {
+	tenantRepository *repositories.TenantRepository
+	roleRepository   *repositories.RoleRepository
+	userService      *UserService
+	secretBox        *utils.SecretBox
+	cacheMgr         *cache.Manager
+	logMgr           *logger.Manager
+	jwtManager       *jwt.Manager // 默认租户的签名
+	auditService     *AuditService
+	cfg              *config.Config
+
+	managers     sync.Map // 租户ID -> *jwt.Manager
+	samlKeyPairs sync.Map // 租户ID -> *utils.SigningKeyPair
+}
+
+// tenantSAMLCertificateValidity 租户 SAML 签名证书的有效期，服务提供方通过元数据获取证书
+const tenantSAMLCertificateValidity = 10 * 365 * 24 * time.Hour
+
+// NewTenantService 创建租户服务实例，jwtManager 为默认租户使用的第一方令牌签名
+func NewTenantService(tenantRepository *repositories.TenantRepository, roleRepository *repositories.RoleRepository, userService *UserService, secretBox *utils.SecretBox, cacheMgr *cache.Manager, logMgr *logger.Manager, jwtManager *jwt.Manager, auditService *AuditService, cfg *config.Config) *TenantService {
+	return &TenantService{tenantRepository: tenantRepository, roleRepository: roleRepository, userService: userService, secretBox: secretBox, cacheMgr: cacheMgr, logMgr: logMgr, jwtManager: jwtManager, auditService: auditService, cfg: cfg}
+}
+
+// Resolve 按标识查询租户，name 为空时返回默认租户；停用的租户返回 ErrTenantDisabled，结果带缓存
+func (s *TenantService) Resolve(ctx context.Context, name string) (*models.Tenant, error) {
+	if name == "" {
+		name = models.DefaultTenantName
+	}
+	tenant, err := cache.NewBuilder[models.Tenant](s.cacheMgr).KeyWithConds("tenant", map[string]any{"name": name}).TTL(10*time.Minute).GetOrSet(ctx, func() (*models.Tenant, error) {
+		tenant, err := s.tenantRepository.Get(ctx, map[string]any{"name": name})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apperrors.ErrTenantNotFound
+			}
+			s.logMgr.Error("获取租户失败", "error", err, "name", name)
+			return nil, apperrors.ErrTenantSystemBusy
+		}
+		return tenant, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Status != models.TenantStatusActive {
+		return nil, apperrors.ErrTenantDisabled
+	}
+	return tenant, nil
+}
+
+// Issuer 当前租户的发行方地址，默认租户为配置文件中的 issuer
+func (s *TenantService) Issuer(ctx context.Context) string {
+	tenant := utils.TenantFromContext(ctx)
+	if tenant == nil || tenant.ID == models.DefaultTenantID {
+		return s.cfg.AuthToken.Issuer
+	}
+	return strings.TrimRight(s.cfg.Server.BaseURL, "/") + utils.RealmPath(ctx)
+}
+
+// JwtManager 当前租户签发与校验第一方令牌使用的 JWT 管理器
+// 租户之间的签名密钥不同，一个租户签发的令牌在其他租户无法通过验签
+func (s *TenantService) JwtManager(ctx context.Context) (*jwt.Manager, error) {
+	tenantID := utils.TenantID(ctx)
+	if tenantID == models.DefaultTenantID {
+		return s.jwtManager, nil
+	}
+	if manager, ok := s.managers.Load(tenantID); ok {
+		return manager.(*jwt.Manager), nil
+	}
+
+	// 缓存中的租户不含签名密钥，从数据库读取
+	tenant, err := s.tenantRepository.Get(ctx, map[string]any{"id": tenantID})
+	if err != nil {
+		s.logMgr.Error("获取租户失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	secret, err := s.secretBox.Open(tenant.SigningSecret)
+	if err != nil {
+		s.logMgr.Error("解密租户签名密钥失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	manager, err := jwt.NewManager(jwt.WithSecret(secret),
+		jwt.WithIssuer(s.Issuer(ctx)),
+		jwt.WithAccessTTL(s.cfg.AuthToken.AccessTokenExpire),
+		jwt.WithRefreshTTL(s.cfg.AuthToken.RefreshTokenExpire))
+	if err != nil {
+		s.logMgr.Error("新建JWT管理器失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	manager.SetExtraResolver(s.userService)
+
+	actual, _ := s.managers.LoadOrStore(tenantID, manager)
+	return actual.(*jwt.Manager), nil
+}
+
+// SAMLKeyPair 当前租户签名 SAML 断言的证书与私钥，租户尚无证书时生成并保存
+// 默认租户沿用配置文件中的证书，不经过此方法
+func (s *TenantService) SAMLKeyPair(ctx context.Context) (*utils.SigningKeyPair, error) {
+	tenantID := utils.TenantID(ctx)
+	if keyPair, ok := s.samlKeyPairs.Load(tenantID); ok {
+		return keyPair.(*utils.SigningKeyPair), nil
+	}
+
+	keyPair, err := s.loadSAMLKeyPair(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if keyPair == nil {
+		if keyPair, err = s.createSAMLKeyPair(ctx, tenantID); err != nil {
+			return nil, err
+		}
+	}
+	actual, _ := s.samlKeyPairs.LoadOrStore(tenantID, keyPair)
+	return actual.(*utils.SigningKeyPair), nil
+}
+
+// loadSAMLKeyPair 从数据库读取租户的 SAML 签名证书，尚未生成时返回 nil
+func (s *TenantService) loadSAMLKeyPair(ctx context.Context, tenantID uint) (*utils.SigningKeyPair, error) {
+	tenant, err := s.tenantRepository.Get(ctx, map[string]any{"id": tenantID})
+	if err != nil {
+		s.logMgr.Error("获取租户失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	if tenant.SAMLCertificate == "" {
+		return nil, nil
+	}
+	privateKey, err := s.secretBox.Open(tenant.SAMLPrivateKey)
+	if err != nil {
+		s.logMgr.Error("解密租户 SAML 签名私钥失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	keyPair, err := utils.ParseSigningKeyPair([]byte(tenant.SAMLCertificate), []byte(privateKey))
+	if err != nil {
+		s.logMgr.Error("解析租户 SAML 签名证书失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	return keyPair, nil
+}
+
+// createSAMLKeyPair 为租户生成自签名的 SAML 签名证书，其他实例已先写入时改用已保存的证书
+func (s *TenantService) createSAMLKeyPair(ctx context.Context, tenantID uint) (*utils.SigningKeyPair, error) {
+	keyPair, err := utils.GenerateSigningKeyPair(s.Issuer(ctx), tenantSAMLCertificateValidity)
+	if err != nil {
+		s.logMgr.Error("生成租户 SAML 签名证书失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	certPEM, keyPEM, err := keyPair.MarshalPEM()
+	if err != nil {
+		s.logMgr.Error("编码租户 SAML 签名证书失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	sealed, err := s.secretBox.Seal(string(keyPEM))
+	if err != nil {
+		s.logMgr.Error("加密租户 SAML 签名私钥失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	stored, err := s.tenantRepository.SetSAMLKeyPairIfEmpty(ctx, tenantID, string(certPEM), sealed)
+	if err != nil {
+		s.logMgr.Error("保存租户 SAML 签名证书失败", "error", err, "tenant_id", tenantID)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	if !stored {
+		keyPair, err := s.loadSAMLKeyPair(ctx, tenantID)
+		if err == nil && keyPair == nil {
+			s.logMgr.Error("租户 SAML 签名证书写入冲突后仍不存在", "tenant_id", tenantID)
+			return nil, apperrors.ErrTenantSystemBusy
+		}
+		return keyPair, err
+	}
+	s.logMgr.Info("生成租户 SAML 签名证书", "tenant_id", tenantID, "not_after", keyPair.Certificate.NotAfter)
+	return keyPair, nil
+}
+
+// ListTenants 查询全部租户，仅默认租户的管理员可以调用
+func (s *TenantService) ListTenants(ctx context.Context) ([]dto.TenantResponse, error) {
+	if utils.TenantID(ctx) != models.DefaultTenantID {
+		return nil, apperrors.ErrTenantAdminOnly
+	}
+	tenants, err := s.tenantRepository.List(ctx)
+	if err != nil {
+		s.logMgr.Error("查询租户列表失败", "error", err)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	result := make([]dto.TenantResponse, len(tenants))
+	for i := range tenants {
+		result[i] = s.render(&tenants[i])
+	}
+	return result, nil
+}
+
+// CreateTenant 创建租户，生成独立的签名密钥，写入内置角色，并创建拥有管理员角色的初始账号
+// 仅默认租户的管理员可以调用
+func (s *TenantService) CreateTenant(ctx context.Context, req *dto.CreateTenantRequest) (*dto.TenantResponse, error) {
+	result, err := s.createTenant(ctx, req)
+	s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditTenantCreate, TargetType: "tenant", TargetID: req.Name}, err)
+	return result, err
+}
+
+func (s *TenantService) createTenant(ctx context.Context, req *dto.CreateTenantRequest) (*dto.TenantResponse, error) {
+	if utils.TenantID(ctx) != models.DefaultTenantID {
+		return nil, apperrors.ErrTenantAdminOnly
+	}
+	if !tenantNamePattern.MatchString(req.Name) {
+		return nil, apperrors.ErrTenantNameInvalid
+	}
+	if _, err := s.tenantRepository.Get(ctx, map[string]any{"name": req.Name}); err == nil {
+		return nil, apperrors.ErrTenantExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logMgr.Error("获取租户失败", "error", err)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+
+	secret, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成租户签名密钥失败", "error", err)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+	sealed, err := s.secretBox.Seal(secret)
+	if err != nil {
+		s.logMgr.Error("加密租户签名密钥失败", "error", err)
+		return nil, apperrors.ErrTenantSystemBusy
+	}
+
+	tenant := &models.Tenant{Name: req.Name, DisplayName: req.DisplayName, Status: models.TenantStatusActive, SigningSecret: sealed}
+	admin := newTenantAdmin(&req.Admin)
+	// 租户、内置角色与初始管理员在同一事务中创建，管理员创建失败时不会留下没有管理员的租户
+	err = s.tenantRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.tenantRepository.CreateWithTx(ctx, tx, tenant); err != nil {
+			s.logMgr.Error("创建租户失败", "error", err, "name", req.Name)
+			return apperrors.ErrTenantSystemBusy
+		}
+		tenantCtx := utils.WithTenant(ctx, tenant)
+		if err := s.roleRepository.SeedBuiltinWithTx(tenantCtx, tx); err != nil {
+			s.logMgr.Error("写入租户内置角色失败", "error", err, "name", req.Name)
+			return apperrors.ErrTenantSystemBusy
+		}
+		if err := s.userService.CreateTenantAdminWithTx(tenantCtx, tx, admin, req.Admin.Password); err != nil {
+			s.logMgr.Error("创建租户初始管理员失败", "error", err, "name", req.Name)
+			return fmt.Errorf("%w：%w", apperrors.ErrTenantAdminFailed, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logMgr.Info("租户创建成功", "tenant_id", tenant.ID, "name", tenant.Name, "admin_id", admin.ID)
+	s.auditService.RecordUser(utils.WithTenant(ctx, tenant), models.AuditUserCreate, admin.ID, nil)
+
+	result := s.render(tenant)
+	return &result, nil
+}
+
+// UpdateTenant 修改租户名称或状态，停用后该租户的全部请求均被拒绝；默认租户不能停用
+func (s *TenantService) UpdateTenant(ctx context.Context, id uint, req *dto.UpdateTenantRequest) (err error) {
+	defer func() {
+		s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditTenantUpdate, TargetType: "tenant", TargetID: strconv.FormatUint(uint64(id), 10)}, err)
+	}()
+
+	if utils.TenantID(ctx) != models.DefaultTenantID {
+		return apperrors.ErrTenantAdminOnly
+	}
+	tenant, err := s.tenantRepository.Get(ctx, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperrors.ErrTenantNotFound
+		}
+		s.logMgr.Error("获取租户失败", "error", err, "tenant_id", id)
+		return apperrors.ErrTenantSystemBusy
+	}
+
+	updates := make(map[string]any)
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.Status != nil {
+		if tenant.ID == models.DefaultTenantID && *req.Status != models.TenantStatusActive {
+			return apperrors.ErrTenantDefault
+		}
+		updates["status"] = *req.Status
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := s.tenantRepository.Update(ctx, id, updates); err != nil {
+		s.logMgr.Error("更新租户失败", "error", err, "tenant_id", id)
+		return apperrors.ErrTenantSystemBusy
+	}
+
+	if err := s.cacheMgr.DeleteByConds(ctx, "tenant", map[string]any{"name": tenant.Name}); err != nil {
+		s.logMgr.Warn("删除租户缓存失败", "error", err, "tenant_id", id)
+	}
+	return nil
+}
+
+// newTenantAdmin 按请求构造租户的初始管理员，邮箱由创建租户的管理员提供，视为已验证
+func newTenantAdmin(req *dto.TenantAdminRequest) *models.User {
+	user := &models.User{Username: req.Username, Nickname: req.Username, Status: models.UserStatusActive}
+	if req.Email != "" {
+		now := time.Now()
+		email := normalizeEmail(req.Email)
+		user.Email = &email
+		user.EmailVerifiedAt = &now
+	}
+	return user
+}
+
+func (s *TenantService) render(tenant *models.Tenant) dto.TenantResponse {
+	return dto.TenantResponse{
+		ID:          tenant.ID,
+		Name:        tenant.Name,
+		DisplayName: tenant.DisplayName,
+		Status:      tenant.Status,
+		Issuer:      s.Issuer(utils.WithTenant(context.Background(), tenant)),
+		CreatedAt:   tenant.CreatedAt,
+		UpdatedAt:   tenant.UpdatedAt,
+	}
+}
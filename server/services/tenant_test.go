@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/3086953492/gokit/config"
+	"github.com/3086953492/gokit/security/password"
+	"github.com/3086953492/gokit/security/subject"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+func newTenantTestService(t *testing.T, tenantRepository *repositories.TenantRepository) *TenantService {
+	t.Helper()
+	secretBox, err := utils.NewSecretBox("test-secret-box-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Server.BaseURL = "https://auth.example.com"
+	return NewTenantService(tenantRepository, nil, nil, secretBox, nil, newTestLogger(t), nil, nil, cfg)
+}
+
+func TestTenantSAMLKeyPair(t *testing.T) {
+	db := newTestDB(t, &models.Tenant{})
+	tenantRepository := repositories.NewTenantRepository(db)
+	acme := &models.Tenant{Name: "acme", DisplayName: "Acme", Status: models.TenantStatusActive}
+	globex := &models.Tenant{Name: "globex", DisplayName: "Globex", Status: models.TenantStatusActive}
+	for _, tenant := range []*models.Tenant{{Name: models.DefaultTenantName, DisplayName: "Default"}, acme, globex} {
+		if err := tenantRepository.CreateWithTx(context.Background(), db, tenant); err != nil {
+			t.Fatal(err)
+		}
+	}
+	acmeCtx := utils.WithTenant(context.Background(), acme)
+
+	keyPair, err := newTenantTestService(t, tenantRepository).SAMLKeyPair(acmeCtx)
+	if err != nil {
+		t.Fatalf("生成租户 SAML 签名证书失败: %v", err)
+	}
+	if keyPair.Certificate.Subject.CommonName != "https://auth.example.com/realms/acme" {
+		t.Fatalf("证书主题 = %q，期望租户发行方地址", keyPair.Certificate.Subject.CommonName)
+	}
+
+	// 私钥加密保存，其他实例读取到同一份证书
+	stored, err := tenantRepository.Get(context.Background(), map[string]any{"id": acme.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SAMLCertificate == "" || bytes.Contains([]byte(stored.SAMLPrivateKey), []byte("PRIVATE KEY")) {
+		t.Fatalf("证书未保存或私钥未加密: %+v", stored)
+	}
+	loaded, err := newTenantTestService(t, tenantRepository).SAMLKeyPair(acmeCtx)
+	if err != nil {
+		t.Fatalf("载入租户 SAML 签名证书失败: %v", err)
+	}
+	if !loaded.Certificate.Equal(keyPair.Certificate) {
+		t.Fatal("再次载入的证书与生成的不一致")
+	}
+
+	// 已有证书时不会被覆盖
+	if written, err := tenantRepository.SetSAMLKeyPairIfEmpty(context.Background(), acme.ID, "other", "other"); err != nil || written {
+		t.Fatalf("覆盖已有证书 written = %v, err = %v", written, err)
+	}
+
+	other, err := newTenantTestService(t, tenantRepository).SAMLKeyPair(utils.WithTenant(context.Background(), globex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Certificate.Equal(keyPair.Certificate) {
+		t.Fatal("不同租户不应共用签名证书")
+	}
+}
+
+func TestCreateTenantWithAdmin(t *testing.T) {
+	db := newTestDB(t, &models.Tenant{}, &models.User{}, &models.Role{}, &models.Permission{}, &models.RolePermission{}, &models.UserRole{}, &models.UserGroup{}, &models.UserGroupMember{}, &models.UserGroupRole{},
+		&models.UserPasswordHistory{}, &models.AuditEvent{}, &models.WebhookSubscription{}, &models.WebhookDelivery{})
+	logMgr := newTestLogger(t)
+	tenantRepository := repositories.NewTenantRepository(db)
+	defaultTenant := &models.Tenant{Name: models.DefaultTenantName, DisplayName: "Default", Status: models.TenantStatusActive}
+	if err := tenantRepository.CreateWithTx(context.Background(), db, defaultTenant); err != nil {
+		t.Fatal(err)
+	}
+
+	passwordMgr, err := password.NewManager(password.WithCost(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjectMgr, err := subject.NewManager(subject.WithSecretString("test-subject-secret-0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretBox, err := utils.NewSecretBox("test-secret-box-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleRepository := repositories.NewRoleRepository(db)
+	auditService := NewAuditService(repositories.NewAuditEventRepository(db), nil, logMgr)
+	rbacService := NewRBACService(roleRepository, nil, nil, nil, logMgr, auditService)
+	passwordPolicy := NewPasswordPolicy(repositories.NewPasswordHistoryRepository(db), passwordMgr, nil, logMgr, appconfig.PasswordPolicyConfig{MinLength: 8, MaxLength: 64, MinCharClasses: 3, DisallowUserInfo: true})
+	webhookService := NewWebhookService(repositories.NewWebhookSubscriptionRepository(db), repositories.NewWebhookDeliveryRepository(db), nil, logMgr, auditService)
+	userService := NewUserService(repositories.NewUserRepository(db), nil, nil, nil, logMgr, passwordMgr, subjectMgr, passwordPolicy, rbacService, auditService, webhookService, appconfig.ActivationModeAuto)
+	cfg := &config.Config{}
+	cfg.Server.BaseURL = "https://auth.example.com"
+	service := NewTenantService(tenantRepository, roleRepository, userService, secretBox, nil, logMgr, nil, auditService, cfg)
+	ctx := utils.WithTenant(context.Background(), defaultTenant)
+
+	// 初始管理员的密码包含用户名，管理员创建失败时租户与内置角色随事务回滚
+	_, err = service.CreateTenant(ctx, &dto.CreateTenantRequest{Name: "acme", DisplayName: "Acme", Admin: dto.TenantAdminRequest{Username: "root", Password: "Root-pass-1"}})
+	if !errors.Is(err, apperrors.ErrTenantAdminFailed) || !errors.Is(err, apperrors.ErrPasswordContainsUserInfo) {
+		t.Fatalf("CreateTenant = %v，期望初始管理员创建失败", err)
+	}
+	var tenants, roles, users int64
+	db.Model(&models.Tenant{}).Where("name = ?", "acme").Count(&tenants)
+	db.Model(&models.Role{}).Where("tenant_id <> ?", models.DefaultTenantID).Count(&roles)
+	db.Model(&models.User{}).Count(&users)
+	if tenants != 0 || roles != 0 || users != 0 {
+		t.Fatalf("管理员创建失败后残留租户 %d 个、角色 %d 个、用户 %d 个", tenants, roles, users)
+	}
+
+	// 重试时租户标识未被占用
+	result, err := service.CreateTenant(ctx, &dto.CreateTenantRequest{Name: "acme", DisplayName: "Acme", Admin: dto.TenantAdminRequest{Username: "root", Email: "Root@Acme.example", Password: "Str0ng-passphrase"}})
+	if err != nil {
+		t.Fatalf("创建租户失败: %v", err)
+	}
+	if result.Issuer != "https://auth.example.com/realms/acme" {
+		t.Fatalf("租户发行方 = %q", result.Issuer)
+	}
+
+	var admin models.User
+	if err := db.Where("tenant_id = ? AND username = ?", result.ID, "root").First(&admin).Error; err != nil {
+		t.Fatalf("查询初始管理员失败: %v", err)
+	}
+	if admin.Subject == "" || admin.AuthSource != models.UserAuthSourceLocal || admin.Email == nil || *admin.Email != "root@acme.example" || admin.EmailVerifiedAt == nil {
+		t.Fatalf("初始管理员 = %+v", admin)
+	}
+	tenantCtx := utils.WithTenant(context.Background(), &models.Tenant{ID: result.ID, Name: result.Name})
+	roleNames, err := rbacService.RoleNamesByUsers(tenantCtx, []uint{admin.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := roleNames[admin.ID]; len(got) != 1 || got[0] != models.RoleAdmin {
+		t.Fatalf("初始管理员角色 = %v，期望只有 %s", got, models.RoleAdmin)
+	}
+}
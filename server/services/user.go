@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"strconv"
 	"time"
 
@@ -11,17 +12,25 @@ import (
 	"github.com/3086953492/gokit/logger"
 	"github.com/3086953492/gokit/redis"
 	"github.com/3086953492/gokit/security/password"
+	"github.com/3086953492/gokit/security/random"
 	"github.com/3086953492/gokit/security/subject"
 	"github.com/3086953492/gokit/storage"
 	"gorm.io/gorm"
 
 	"goauth/dto"
+	"goauth/appconfig"
 	"goauth/apperrors"
 	"goauth/models"
 	"goauth/repositories"
 	"goauth/utils"
 )
 
+// UserEmailVerifier 发送邮箱验证邮件
+// 由找回密码与邮箱验证服务实现，该服务依赖用户服务，只能在两者都创建后注入
+type UserEmailVerifier interface {
+	SendEmailVerification(ctx context.Context, userID uint) error
+}
+
 // UserService 用户服务实现
 type UserService struct {
 	userRepository *repositories.UserRepository
@@ -31,27 +40,71 @@ type UserService struct {
 	logMgr         *logger.Manager
 	passwordMgr    *password.Manager
 	subjectMgr     *subject.Manager
+	tokenRevoker   UserTokenRevoker
+	passwordPolicy *PasswordPolicy
+	rbacService    *RBACService
+	emailVerifier  UserEmailVerifier
+	auditService   *AuditService
+	webhookService *WebhookService
+	activationMode string
 }
 
-// NewUserService 创建用户服务实例
-func NewUserService(userRepository *repositories.UserRepository, storageManager *storage.Manager, redisMgr *redis.Manager, cacheMgr *cache.Manager, logMgr *logger.Manager, passwordMgr *password.Manager, subjectMgr *subject.Manager) *UserService {
-	return &UserService{userRepository: userRepository, storageManager: storageManager, redisMgr: redisMgr, cacheMgr: cacheMgr, logMgr: logMgr, passwordMgr: passwordMgr, subjectMgr: subjectMgr}
+// NewUserService 创建用户服务实例，activationMode 决定新注册账号的初始状态
+func NewUserService(userRepository *repositories.UserRepository, storageManager *storage.Manager, redisMgr *redis.Manager, cacheMgr *cache.Manager, logMgr *logger.Manager, passwordMgr *password.Manager, subjectMgr *subject.Manager, passwordPolicy *PasswordPolicy, rbacService *RBACService, auditService *AuditService, webhookService *WebhookService, activationMode string) *UserService {
+	return &UserService{userRepository: userRepository, storageManager: storageManager, redisMgr: redisMgr, cacheMgr: cacheMgr, logMgr: logMgr, passwordMgr: passwordMgr, subjectMgr: subjectMgr, passwordPolicy: passwordPolicy, rbacService: rbacService, auditService: auditService, webhookService: webhookService, activationMode: activationMode}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, req *dto.CreateUserForm, avatarFile *utils.FormFileResult) error {
+// SetTokenRevoker 设置用户级令牌撤销实现
+// 撤销服务依赖用户服务，只能在两者都创建后注入
+func (s *UserService) SetTokenRevoker(tokenRevoker UserTokenRevoker) {
+	s.tokenRevoker = tokenRevoker
+}
 
-	// 对用户名加锁，防止并发创建相同用户名。
-	lockKey := fmt.Sprintf("user:create:%s", req.Username)
+// SetEmailVerifier 设置邮箱验证邮件的发送实现
+func (s *UserService) SetEmailVerifier(emailVerifier UserEmailVerifier) {
+	s.emailVerifier = emailVerifier
+}
+
+// CreateUser 注册用户，返回的用户状态取决于激活方式
+func (s *UserService) CreateUser(ctx context.Context, req *dto.CreateUserForm, avatarFile *utils.FormFileResult) (*models.User, error) {
+	user, err := s.createUser(ctx, req, avatarFile)
+	var userID uint
+	if user != nil {
+		userID = user.ID
+	}
+	s.auditService.RecordUser(ctx, models.AuditUserCreate, userID, err)
+	return user, err
+}
+
+func (s *UserService) createUser(ctx context.Context, req *dto.CreateUserForm, avatarFile *utils.FormFileResult) (*models.User, error) {
+
+	// 邮件激活须有邮箱接收激活链接
+	if s.activationMode == appconfig.ActivationModeEmail && req.Email == "" {
+		return nil, apperrors.ErrEmailRequired
+	}
+
+	// 对用户名加锁，防止并发创建相同用户名。用户名只在租户内唯一
+	lockKey := fmt.Sprintf("user:create:%d:%s", utils.TenantID(ctx), req.Username)
 	lock := s.redisMgr.NewDistributedLock(lockKey, 10*time.Second)
 	if err := lock.Acquire(ctx); err != nil {
-		return apperrors.ErrUserSystemBusy
+		return nil, apperrors.ErrUserSystemBusy
 	}
 	defer lock.Release(ctx)
 
+	// 唯一性在租户内检查，包括已删除的用户，与数据库唯一索引一致
+	if err := s.checkUnique(ctx, map[string]any{"username": req.Username}, apperrors.ErrUsernameTaken); err != nil {
+		return nil, err
+	}
+	if req.Email != "" {
+		if err := s.checkUnique(ctx, map[string]any{"email": normalizeEmail(req.Email)}, apperrors.ErrEmailTaken); err != nil {
+			return nil, err
+		}
+	}
+
 	hashedPassword, err := s.passwordMgr.Hash(req.Password)
 	if err != nil {
 		s.logMgr.Error("密码哈希失败", "error", err)
-		return apperrors.ErrUserPasswordHashFailed
+		return nil, apperrors.ErrUserPasswordHashFailed
 	}
 
 	var avatarURL string
@@ -59,7 +112,7 @@ func (s *UserService) CreateUser(ctx context.Context, req *dto.CreateUserForm, a
 		f, err := avatarFile.FileHeader.Open()
 		if err != nil {
 			s.logMgr.Error("文件读取失败", "error", err)
-			return apperrors.ErrUserFileReadFailed
+			return nil, apperrors.ErrUserFileReadFailed
 		}
 		defer f.Close()
 
@@ -67,20 +120,26 @@ func (s *UserService) CreateUser(ctx context.Context, req *dto.CreateUserForm, a
 		meta, err := s.storageManager.Upload(ctx, objectKey, f, storage.WithContentType(avatarFile.ContentType))
 		if err != nil {
 			s.logMgr.Error("头像上传失败", "error", err)
-			return apperrors.ErrUserAvatarUploadFailed
+			return nil, apperrors.ErrUserAvatarUploadFailed
 		}
 		avatarURL = meta.URL
 	}
 
+	passwordChangedAt := time.Now()
 	user := &models.User{
-		Username: req.Username,
-		Password: hashedPassword,
-		Nickname: req.Nickname,
-		Avatar:   avatarURL,
-		Role:     "user",
+		Username:          req.Username,
+		Password:          hashedPassword,
+		PasswordChangedAt: &passwordChangedAt,
+		Nickname:          req.Nickname,
+		Avatar:            avatarURL,
+		Status:            s.initialStatus(),
+	}
+	if req.Email != "" {
+		email := normalizeEmail(req.Email)
+		user.Email = &email
 	}
 
-	// 使用事务创建用户并更新 subject
+	// 使用事务创建用户、更新 subject、分配默认角色并写入 Webhook 事件
 	err = s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := s.userRepository.CreateWithTx(ctx, tx, user); err != nil {
 			s.logMgr.Error("创建用户失败", "error", err, "user", user)
@@ -99,19 +158,277 @@ func (s *UserService) CreateUser(ctx context.Context, req *dto.CreateUserForm, a
 		}
 
 		user.Subject = subject
+
+		if err := s.rbacService.AssignRoleWithTx(ctx, tx, user.ID, models.RoleUser); err != nil {
+			s.logMgr.Error("分配默认角色失败", "error", err)
+			return apperrors.ErrUserCreateFailed
+		}
+
+		if err := s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookUserCreated, webhookUserData(user, nil)); err != nil {
+			return apperrors.ErrUserCreateFailed
+		}
 		return nil
 	})
 	if err != nil {
+		return nil, err
+	}
+
+	s.logMgr.Info("用户注册成功", "userID", user.ID, "status", user.Status)
+
+	// 邮件激活方式下验证邮件同时是激活邮件
+	if user.Email != nil {
+		s.sendEmailVerification(ctx, user.ID)
+	}
+
+	return user, nil
+}
+
+// checkUnique 按条件查询当前租户内（包括已删除）的用户，已存在时返回 taken
+func (s *UserService) checkUnique(ctx context.Context, conds map[string]any, taken error) error {
+	_, err := s.userRepository.GetWithDeleted(ctx, conds)
+	if err == nil {
+		return taken
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logMgr.Error("获取用户失败", "error", err)
+		return apperrors.ErrUserSystemBusy
+	}
+	return nil
+}
+
+// CreateFederatedUser 为首次登录的外部身份创建本地账号，link 在同一事务中写入外部身份关联
+// 账号不设本地密码；提供方已验证的邮箱视为已验证，邮件激活方式下同时视为已激活
+func (s *UserService) CreateFederatedUser(ctx context.Context, user *models.User, link func(tx *gorm.DB, user *models.User) error) error {
+	if s.activationMode == appconfig.ActivationModeEmail && user.Email == nil {
+		return apperrors.ErrEmailRequired
+	}
+
+	user.Status = s.initialStatus()
+	if user.Status == models.UserStatusPendingEmail && user.EmailVerifiedAt != nil {
+		user.Status = models.UserStatusActive
+	}
+	if err := s.createExternalUser(ctx, user, models.RoleUser, link); err != nil {
+		return err
+	}
+
+	s.logMgr.Info("外部身份注册成功", "userID", user.ID, "status", user.Status)
+
+	if user.Email != nil && user.EmailVerifiedAt == nil {
+		s.sendEmailVerification(ctx, user.ID)
+	}
+	return nil
+}
+
+// CreateDirectoryUser 为首次登录的目录用户创建本地账号
+// 账号由目录管理，直接激活，不设本地密码；昵称、邮箱与标识由调用方按目录属性填写，标识为空时自动生成，role 为按目录组映射的角色
+func (s *UserService) CreateDirectoryUser(ctx context.Context, user *models.User, role string) error {
+	user.AuthSource = models.UserAuthSourceLDAP
+	user.Status = models.UserStatusActive
+	if err := s.createExternalUser(ctx, user, role, nil); err != nil {
+		return err
+	}
+
+	s.logMgr.Info("目录用户注册成功", "userID", user.ID)
+	return nil
+}
+
+// CreateProvisionedUser 为预配方（SCIM）推送的账号创建本地账号
+// 账号直接激活，邮箱由预配方维护视为已验证；password 为空时不设本地密码，用户可通过找回密码自行设置
+func (s *UserService) CreateProvisionedUser(ctx context.Context, user *models.User, password string) error {
+	err := s.createProvisionedUser(ctx, user, password)
+	s.auditService.RecordUser(ctx, models.AuditUserCreate, user.ID, err)
+	return err
+}
+
+func (s *UserService) createProvisionedUser(ctx context.Context, user *models.User, password string) error {
+	if password != "" {
+		if err := s.passwordPolicy.Validate(password, user.Username, user.Nickname); err != nil {
+			return err
+		}
+		hashedPassword, err := s.passwordMgr.Hash(password)
+		if err != nil {
+			s.logMgr.Error("密码哈希失败", "error", err)
+			return apperrors.ErrUserPasswordHashFailed
+		}
+		now := time.Now()
+		user.Password = hashedPassword
+		user.PasswordChangedAt = &now
+	}
+
+	user.AuthSource = models.UserAuthSourceLocal
+	if err := s.createExternalUser(ctx, user, models.RoleUser, nil); err != nil {
+		return err
+	}
+
+	s.logMgr.Info("预配用户创建成功", "userID", user.ID, "status", user.Status)
+	return nil
+}
+
+// UpdateProvisionedUser 按预配方（SCIM）推送的结果更新账号，password 不为空时同时设置本地密码
+// 停用或修改密码时，更新与撤销该用户全部令牌在同一事务中完成
+func (s *UserService) UpdateProvisionedUser(ctx context.Context, existingUser *models.User, updates map[string]any, password string) error {
+	err := s.updateProvisionedUser(ctx, existingUser, updates, password)
+	s.auditService.RecordUser(ctx, models.AuditUserUpdate, existingUser.ID, err)
+	return err
+}
+
+func (s *UserService) updateProvisionedUser(ctx context.Context, existingUser *models.User, updates map[string]any, password string) error {
+	if password != "" {
+		if existingUser.AuthSource != models.UserAuthSourceLocal {
+			return apperrors.ErrPasswordManagedByDirectory
+		}
+		username, _ := updates["username"].(string)
+		nickname, _ := updates["nickname"].(string)
+		if err := s.passwordPolicy.Validate(password, existingUser.Username, existingUser.Nickname, username, nickname); err != nil {
+			return err
+		}
+		if err := s.passwordPolicy.CheckReuse(ctx, existingUser, password); err != nil {
+			return err
+		}
+		hashedPassword, err := s.passwordMgr.Hash(password)
+		if err != nil {
+			s.logMgr.Error("密码哈希失败", "error", err)
+			return apperrors.ErrUserPasswordHashFailed
+		}
+		updates["password"] = hashedPassword
+		updates["password_changed_at"] = time.Now()
+		updates["password_unset"] = false
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	var err error
+	eventType := webhookUserEvent(updates)
+	if password != "" || eventType == models.WebhookUserDisabled {
+		err = s.tokenRevoker.RevokeUserTokens(ctx, existingUser.ID, func(tx *gorm.DB) error {
+			if err := s.userRepository.UpdateWithTx(ctx, tx, existingUser.ID, updates); err != nil {
+				return err
+			}
+			if password != "" {
+				if err := s.passwordPolicy.RecordWithTx(ctx, tx, existingUser.ID, existingUser.Password); err != nil {
+					return err
+				}
+			}
+			return s.webhookService.EnqueueWithTx(ctx, tx, eventType, webhookUserData(existingUser, updates))
+		})
+	} else {
+		err = s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := s.userRepository.UpdateWithTx(ctx, tx, existingUser.ID, updates); err != nil {
+				return err
+			}
+			return s.webhookService.EnqueueWithTx(ctx, tx, eventType, webhookUserData(existingUser, updates))
+		})
+	}
+	if err != nil {
+		s.logMgr.Error("更新用户失败", "error", err, "user_id", existingUser.ID)
+		return apperrors.ErrUserUpdateFailed
+	}
+
+	s.InvalidateUserCache(ctx, existingUser)
+	return nil
+}
+
+// createExternalUser 创建由外部来源管理的账号并分配角色 role，link 不为空时在同一事务中执行，Webhook 事件同样在该事务中写入
+// 调用方未设置密码哈希时使用随机密码，账号视为未设置本地密码
+func (s *UserService) createExternalUser(ctx context.Context, user *models.User, role string, link func(tx *gorm.DB, user *models.User) error) error {
+	lockKey := fmt.Sprintf("user:create:%d:%s", utils.TenantID(ctx), user.Username)
+	lock := s.redisMgr.NewDistributedLock(lockKey, 10*time.Second)
+	if err := lock.Acquire(ctx); err != nil {
+		return apperrors.ErrUserSystemBusy
+	}
+	defer lock.Release(ctx)
+
+	// 随机密码只为满足非空约束，无人知晓，账号只能通过外部身份登录，直到用户自行设置密码
+	if user.Password == "" {
+		randomPassword, err := random.URLSafe(32)
+		if err != nil {
+			s.logMgr.Error("生成随机密码失败", "error", err)
+			return apperrors.ErrUserPasswordHashFailed
+		}
+		hashedPassword, err := s.passwordMgr.Hash(randomPassword)
+		if err != nil {
+			s.logMgr.Error("密码哈希失败", "error", err)
+			return apperrors.ErrUserPasswordHashFailed
+		}
+		user.Password = hashedPassword
+		user.PasswordUnset = true
+	}
+
+	return s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return s.createUserWithTx(ctx, tx, user, role, link)
+	})
+}
+
+// CreateTenantAdminWithTx 在创建租户的事务中创建该租户的初始管理员，管理员创建失败时租户随事务一并回滚
+// 新租户中还没有其他账号，不需要加锁检查用户名
+func (s *UserService) CreateTenantAdminWithTx(ctx context.Context, tx *gorm.DB, user *models.User, password string) error {
+	if err := s.passwordPolicy.Validate(password, user.Username, user.Nickname); err != nil {
 		return err
 	}
+	hashedPassword, err := s.passwordMgr.Hash(password)
+	if err != nil {
+		s.logMgr.Error("密码哈希失败", "error", err)
+		return apperrors.ErrUserPasswordHashFailed
+	}
+	now := time.Now()
+	user.Password = hashedPassword
+	user.PasswordChangedAt = &now
+	user.AuthSource = models.UserAuthSourceLocal
+	return s.createUserWithTx(ctx, tx, user, models.RoleAdmin, nil)
+}
 
-	s.logMgr.Info("用户注册成功", "userID", user.ID)
+// createUserWithTx 在事务中创建账号、生成标识、分配角色 role 并写入 Webhook 事件，link 不为空时同样在该事务中执行
+func (s *UserService) createUserWithTx(ctx context.Context, tx *gorm.DB, user *models.User, role string, link func(tx *gorm.DB, user *models.User) error) error {
+	if err := s.userRepository.CreateWithTx(ctx, tx, user); err != nil {
+		s.logMgr.Error("创建用户失败", "error", err, "username", user.Username)
+		return apperrors.ErrUserCreateFailed
+	}
+
+	if user.Subject == "" {
+		subject, err := s.subjectMgr.Sub(strconv.FormatUint(uint64(user.ID), 10))
+		if err != nil {
+			s.logMgr.Error("生成用户标识失败", "error", err)
+			return apperrors.ErrUserSubjectGenFailed
+		}
+		if err := s.userRepository.UpdateWithTx(ctx, tx, user.ID, map[string]any{"subject": subject}); err != nil {
+			s.logMgr.Error("更新用户标识失败", "error", err)
+			return apperrors.ErrUserSubjectUpdateFailed
+		}
+		user.Subject = subject
+	}
+
+	if err := s.rbacService.AssignRoleWithTx(ctx, tx, user.ID, role); err != nil {
+		s.logMgr.Error("分配默认角色失败", "error", err)
+		return apperrors.ErrUserCreateFailed
+	}
+
+	if link != nil {
+		if err := link(tx, user); err != nil {
+			return err
+		}
+	}
 
+	if err := s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookUserCreated, webhookUserData(user, nil)); err != nil {
+		return apperrors.ErrUserCreateFailed
+	}
 	return nil
 }
 
+// initialStatus 新注册账号的初始状态
+func (s *UserService) initialStatus() int {
+	switch s.activationMode {
+	case appconfig.ActivationModeEmail:
+		return models.UserStatusPendingEmail
+	case appconfig.ActivationModeApproval:
+		return models.UserStatusPendingApproval
+	default:
+		return models.UserStatusActive
+	}
+}
+
 func (s *UserService) GetUser(ctx context.Context, conds map[string]any) (*models.User, error) {
-	user, err := cache.NewBuilder[models.User](s.cacheMgr).KeyWithConds("user", conds).TTL(10*time.Minute).GetOrSet(ctx, func() (*models.User, error) {
+	user, err := cache.NewBuilder[models.User](s.cacheMgr).KeyWithConds(utils.TenantCacheKey(ctx, "user"), conds).TTL(10*time.Minute).GetOrSet(ctx, func() (*models.User, error) {
 		user, err := s.userRepository.Get(ctx, conds)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -142,6 +459,12 @@ func (s *UserService) GetUserWithDeleted(ctx context.Context, conds map[string]a
 }
 
 func (s *UserService) UpdateUser(ctx context.Context, userID uint, user *dto.UpdateUserForm, avatarFile *utils.FormFileResult) error {
+	err := s.updateUser(ctx, userID, user, avatarFile)
+	s.auditService.RecordUser(ctx, models.AuditUserUpdate, userID, err)
+	return err
+}
+
+func (s *UserService) updateUser(ctx context.Context, userID uint, user *dto.UpdateUserForm, avatarFile *utils.FormFileResult) error {
 
 	// 获取更新前的用户信息
 	existingUser, err := s.GetUser(ctx, map[string]any{"id": userID})
@@ -156,13 +479,50 @@ func (s *UserService) UpdateUser(ctx context.Context, userID uint, user *dto.Upd
 		updates["nickname"] = user.Nickname
 	}
 
+	// 修改邮箱后须重新验证；邮箱未变化时不做处理，避免清除已有的验证状态
+	emailChanged := false
+	if user.Email != "" {
+		email := normalizeEmail(user.Email)
+		if existingUser.Email == nil || *existingUser.Email != email {
+			if _, err := s.userRepository.GetWithDeleted(ctx, map[string]any{"email": email}); err == nil {
+				return apperrors.ErrEmailTaken
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				s.logMgr.Error("获取用户失败", "error", err)
+				return apperrors.ErrUserSystemBusy
+			}
+			updates["email"] = email
+			updates["email_verified_at"] = nil
+			emailChanged = true
+		}
+	}
+
 	if user.Password != "" {
+		// 目录账号的密码由目录校验，本地密码不会被使用
+		if existingUser.AuthSource != models.UserAuthSourceLocal {
+			return apperrors.ErrPasswordManagedByDirectory
+		}
+		// 缓存中的用户不含密码哈希，比对与记录历史密码须读取数据库中的当前密码
+		current, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+		if err != nil {
+			s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+			return apperrors.ErrUserSystemBusy
+		}
+		existingUser.Password = current.Password
+		// 表单中没有用户名，包含用户信息的检查在这里按数据库中的用户补做
+		if err := s.passwordPolicy.Validate(user.Password, existingUser.Username, existingUser.Nickname, user.Nickname); err != nil {
+			return err
+		}
+		if err := s.passwordPolicy.CheckReuse(ctx, existingUser, user.Password); err != nil {
+			return err
+		}
 		hashedPassword, err := s.passwordMgr.Hash(user.Password)
 		if err != nil {
 			s.logMgr.Error("密码哈希失败", "error", err)
 			return apperrors.ErrUserPasswordHashFailed
 		}
 		updates["password"] = hashedPassword
+		updates["password_changed_at"] = time.Now()
+		updates["password_unset"] = false
 	}
 
 	if avatarFile != nil {
@@ -189,34 +549,80 @@ func (s *UserService) UpdateUser(ctx context.Context, userID uint, user *dto.Upd
 		updates["status"] = *user.Status
 	}
 
-	if user.Role != "" {
-		updates["role"] = user.Role
+	// 禁用或修改密码时，更新与撤销该用户全部令牌在同一事务中完成；Webhook 事件与更新在同一事务中写入
+	eventType := webhookUserEvent(updates)
+	if user.Password != "" || eventType == models.WebhookUserDisabled {
+		err = s.tokenRevoker.RevokeUserTokens(ctx, userID, func(tx *gorm.DB) error {
+			if err := s.userRepository.UpdateWithTx(ctx, tx, userID, updates); err != nil {
+				return err
+			}
+			if user.Password != "" {
+				if err := s.passwordPolicy.RecordWithTx(ctx, tx, userID, existingUser.Password); err != nil {
+					return err
+				}
+			}
+			return s.webhookService.EnqueueWithTx(ctx, tx, eventType, webhookUserData(existingUser, updates))
+		})
+	} else {
+		err = s.userRepository.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := s.userRepository.UpdateWithTx(ctx, tx, userID, updates); err != nil {
+				return err
+			}
+			return s.webhookService.EnqueueWithTx(ctx, tx, eventType, webhookUserData(existingUser, updates))
+		})
 	}
-
-	// 执行更新
-	if err := s.userRepository.Update(ctx, userID, updates); err != nil {
+	if err != nil {
 		s.logMgr.Error("更新用户失败", "error", err, "user", user)
 		return apperrors.ErrUserUpdateFailed
 	}
 
 	// 删除相关缓存
-	if err := s.cacheMgr.DeleteByContainsList(ctx, "user", []map[string]any{{"id": userID}, {"nickname": existingUser.Nickname}, {"username": existingUser.Username}, {"nickname": user.Nickname}}); err != nil {
+	if err := s.cacheMgr.DeleteByContainsList(ctx, utils.TenantCacheKey(ctx, "user"), []map[string]any{{"id": userID}, {"nickname": existingUser.Nickname}, {"username": existingUser.Username}, {"nickname": user.Nickname}}); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err, "user_id", userID)
 	}
-	if err := s.cacheMgr.DeleteByPrefix(ctx, "list_users:"); err != nil {
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_users:")); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
 
+	if emailChanged {
+		s.sendEmailVerification(ctx, userID)
+	}
+
 	return nil
 }
 
+// sendEmailVerification 设置邮箱后发送验证邮件，发送失败不影响注册或更新结果，用户可稍后重新发送
+func (s *UserService) sendEmailVerification(ctx context.Context, userID uint) {
+	if s.emailVerifier == nil {
+		return
+	}
+	if err := s.emailVerifier.SendEmailVerification(ctx, userID); err != nil {
+		s.logMgr.Warn("发送邮箱验证邮件失败", "error", err, "user_id", userID)
+	}
+}
+
 func (s *UserService) ListUsers(ctx context.Context, page, pageSize int, conds map[string]any) (*dto.PaginationResponse[dto.UserListResponse], error) {
-	usersPagination, err := cache.NewBuilder[dto.PaginationResponse[dto.UserListResponse]](s.cacheMgr).Key(fmt.Sprintf("list_users:%v", conds)).TTL(10*time.Minute).GetOrSet(ctx, func() (*dto.PaginationResponse[dto.UserListResponse], error) {
-		users, total, err := s.userRepository.List(ctx, page, pageSize, conds)
+	usersPagination, err := cache.NewBuilder[dto.PaginationResponse[dto.UserListResponse]](s.cacheMgr).Key(utils.TenantCacheKey(ctx, fmt.Sprintf("list_users:%v", conds))).TTL(10*time.Minute).GetOrSet(ctx, func() (*dto.PaginationResponse[dto.UserListResponse], error) {
+		// 角色不在用户表中，按角色筛选时包括经由用户组获得该角色的用户
+		query := conds
+		if role, ok := conds["role"]; ok {
+			query = maps.Clone(conds)
+			delete(query, "role")
+			query[repositories.UserHasRoleCondition] = role
+		}
+		users, total, err := s.userRepository.List(ctx, page, pageSize, query)
 		if err != nil {
 			s.logMgr.Error("获取用户列表失败", "error", err, "conds", conds)
 			return nil, apperrors.ErrUserListFailed
 		}
+		userIDs := make([]uint, len(users))
+		for i, user := range users {
+			userIDs[i] = user.ID
+		}
+		roles, err := s.rbacService.RoleNamesByUsers(ctx, userIDs)
+		if err != nil {
+			return nil, apperrors.ErrUserListFailed
+		}
 		usersResponse := make([]dto.UserListResponse, len(users))
 		for i, user := range users {
 			usersResponse[i] = dto.UserListResponse{
@@ -224,7 +630,7 @@ func (s *UserService) ListUsers(ctx context.Context, page, pageSize int, conds m
 				Nickname: user.Nickname,
 				Avatar:   user.Avatar,
 				Status:   user.Status,
-				Role:     user.Role,
+				Roles:    roles[user.ID],
 			}
 		}
 		return &dto.PaginationResponse[dto.UserListResponse]{
@@ -242,6 +648,12 @@ func (s *UserService) ListUsers(ctx context.Context, page, pageSize int, conds m
 }
 
 func (s *UserService) DeleteUser(ctx context.Context, userID uint) error {
+	err := s.deleteUser(ctx, userID)
+	s.auditService.RecordUser(ctx, models.AuditUserDelete, userID, err)
+	return err
+}
+
+func (s *UserService) deleteUser(ctx context.Context, userID uint) error {
 	lockKey := fmt.Sprintf("user:delete:%v", userID)
 	lock := s.redisMgr.NewDistributedLock(lockKey, 10*time.Second)
 	if err := lock.Acquire(ctx); err != nil {
@@ -254,31 +666,83 @@ func (s *UserService) DeleteUser(ctx context.Context, userID uint) error {
 		return err
 	}
 
-	if err := s.userRepository.Delete(ctx, userID); err != nil {
+	// 删除用户、撤销其全部令牌与写入 Webhook 事件在同一事务中完成
+	err = s.tokenRevoker.RevokeUserTokens(ctx, userID, func(tx *gorm.DB) error {
+		if err := s.userRepository.DeleteWithTx(ctx, tx, userID); err != nil {
+			return err
+		}
+		return s.webhookService.EnqueueWithTx(ctx, tx, models.WebhookUserDeleted, webhookUserData(user, nil))
+	})
+	if err != nil {
 		s.logMgr.Error("删除用户失败", "error", err, "user_id", userID)
 		return apperrors.ErrUserDeleteFailed
 	}
 
-	if err := s.cacheMgr.DeleteByContainsList(ctx, "user", []map[string]any{{"id": userID}, {"nickname": user.Nickname}, {"username": user.Username}}); err != nil {
+	if err := s.cacheMgr.DeleteByContainsList(ctx, utils.TenantCacheKey(ctx, "user"), []map[string]any{{"id": userID}, {"nickname": user.Nickname}, {"username": user.Username}}); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err, "user_id", userID)
 	}
-	if err := s.cacheMgr.DeleteByPrefix(ctx, "list_users:"); err != nil {
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_users:")); err != nil {
 		s.logMgr.Warn("删除缓存失败", "error", err)
 	}
 	s.logMgr.Info("用户删除成功", "userID", userID)
 	return nil
 }
 
+// webhookUserData 用户事件的数据，updates 不为空时按变更后的值填写，并列出变更的字段
+func webhookUserData(user *models.User, updates map[string]any) dto.WebhookUserData {
+	data := dto.WebhookUserData{UserID: user.ID, Subject: user.Subject, Username: user.Username, Email: user.Email, Status: user.Status}
+	if updates == nil {
+		return data
+	}
+	if username, ok := updates["username"].(string); ok {
+		data.Username = username
+	}
+	switch email := updates["email"].(type) {
+	case string:
+		data.Email = &email
+	case *string:
+		data.Email = email
+	}
+	if status, ok := updates["status"].(int); ok {
+		data.Status = status
+	}
+	data.Changed = WebhookChangedFields(updates, "password_changed_at", "password_unset", "email_verified_at")
+	return data
+}
+
+// webhookUserEvent 停用账号时为 user.disabled，其它修改为 user.updated
+func webhookUserEvent(updates map[string]any) string {
+	if status, ok := updates["status"].(int); ok && status == models.UserStatusDisabled {
+		return models.WebhookUserDisabled
+	}
+	return models.WebhookUserUpdated
+}
+
+// MFARequired 安全策略要求拥有任一管理权限的账号必须启用两步验证
+func (s *UserService) MFARequired(ctx context.Context, user *models.User) bool {
+	return s.rbacService.MFARequired(ctx, user.ID)
+}
+
+// InvalidateUserCache 删除用户详情与列表缓存，供其它服务修改用户字段后调用
+func (s *UserService) InvalidateUserCache(ctx context.Context, user *models.User) {
+	if err := s.cacheMgr.DeleteByContainsList(ctx, utils.TenantCacheKey(ctx, "user"), []map[string]any{{"id": user.ID}, {"nickname": user.Nickname}, {"username": user.Username}}); err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err, "user_id", user.ID)
+	}
+	if err := s.cacheMgr.DeleteByPrefix(ctx, utils.TenantCacheKey(ctx, "list_users:")); err != nil {
+		s.logMgr.Warn("删除缓存失败", "error", err)
+	}
+}
+
+// ResolveExtra 令牌扩展信息，角色与权限在每次请求时按用户加载，不写入令牌
 func (s *UserService) ResolveExtra(ctx context.Context, userIDStr string) (map[string]any, error) {
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
-	user, err := s.GetUser(ctx, map[string]any{"id": userID})
-	if err != nil {
+	if _, err := s.GetUser(ctx, map[string]any{"id": userID}); err != nil {
 		return nil, err
 	}
 
-	return map[string]any{"role": user.Role}, nil
+	return map[string]any{}, nil
 }
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/repositories"
+)
+
+const (
+	userTokenEpochKeyPrefix   = "user:token_epoch:"
+	userTokenEpochTTL         = 24 * time.Hour
+	userTokenEpochSetAttempts = 3
+)
+
+// UserTokenRevoker 用户级令牌撤销，由 OAuth 撤销服务实现
+// apply 与撤销在同一事务中执行，用户信息的变更（禁用、改密、删除）与令牌失效同时生效
+type UserTokenRevoker interface {
+	RevokeUserTokens(ctx context.Context, userID uint, apply func(tx *gorm.DB) error) error
+}
+
+// UserTokenEpoch 用户令牌纪元查询
+// 纪元以数据库为准，Redis 中保存一份副本供每次请求校验；推进纪元后由撤销方覆盖写入
+type UserTokenEpoch struct {
+	redisMgr       *redis.Manager
+	userRepository *repositories.UserRepository
+	logMgr         *logger.Manager
+}
+
+// NewUserTokenEpoch 创建用户令牌纪元查询实例
+func NewUserTokenEpoch(redisMgr *redis.Manager, userRepository *repositories.UserRepository, logMgr *logger.Manager) *UserTokenEpoch {
+	return &UserTokenEpoch{redisMgr: redisMgr, userRepository: userRepository, logMgr: logMgr}
+}
+
+// Get 获取用户当前的令牌纪元，用户不存在（含已删除）时返回 apperrors.ErrUserNotFound
+func (e *UserTokenEpoch) Get(ctx context.Context, userID uint) (int64, error) {
+	key := userTokenEpochKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+
+	data, err := e.redisMgr.GetBytes(ctx, key)
+	if err != nil {
+		e.logMgr.Warn("读取令牌纪元失败", "error", err, "user_id", userID)
+	}
+	if data != nil {
+		if epoch, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+			return epoch, nil
+		}
+	}
+
+	user, err := e.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, apperrors.ErrUserNotFound
+		}
+		e.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return 0, apperrors.ErrUserSystemBusy
+	}
+
+	// 只在不存在时写入，避免覆盖撤销方刚写入的新纪元
+	if _, err := e.redisMgr.SetNX(ctx, key, strconv.FormatInt(user.TokenEpoch, 10), userTokenEpochTTL); err != nil {
+		e.logMgr.Warn("写入令牌纪元失败", "error", err, "user_id", userID)
+	}
+	return user.TokenEpoch, nil
+}
+
+// Set 写入推进后的令牌纪元，须在数据库事务提交后调用
+// 写入失败时重试，仍失败则删除副本使下次查询回源数据库；删除也失败时返回错误，此时旧纪元可能仍在缓存中
+func (e *UserTokenEpoch) Set(ctx context.Context, userID uint, epoch int64) error {
+	key := userTokenEpochKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+	var err error
+	for attempt := 1; attempt <= userTokenEpochSetAttempts; attempt++ {
+		if err = e.redisMgr.SetBytes(ctx, key, []byte(strconv.FormatInt(epoch, 10)), userTokenEpochTTL); err == nil {
+			return nil
+		}
+		e.logMgr.Warn("写入令牌纪元失败", "error", err, "user_id", userID, "attempt", attempt)
+	}
+	if _, delErr := e.redisMgr.Del(ctx, key); delErr != nil {
+		return errors.Join(err, delErr)
+	}
+	return nil
+}
+
+// Delete 删除 Redis 中的纪元副本，下次查询回源数据库
+func (e *UserTokenEpoch) Delete(ctx context.Context, userID uint) error {
+	_, err := e.redisMgr.Del(ctx, userTokenEpochKeyPrefix+strconv.FormatUint(uint64(userID), 10))
+	return err
+}
+
+// Valid 判断签发于 issuedAt 的令牌在当前纪元下是否仍然有效
+func (e *UserTokenEpoch) Valid(ctx context.Context, userID uint, issuedAt time.Time) (bool, error) {
+	epoch, err := e.Get(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.UnixMilli() >= epoch, nil
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const webauthnCeremonyKeyPrefix = "webauthn:ceremony:"
+
+// webauthnCeremony 一次注册或认证流程，以挑战作为 Redis 键，只能使用一次
+type webauthnCeremony struct {
+	Type             string `json:"type"`              // webauthn.create 或 webauthn.get
+	UserID           uint   `json:"user_id"`           // 无密码登录时为 0，由凭证确定用户
+	UserVerification bool   `json:"user_verification"` // 是否要求认证器完成用户验证
+}
+
+// WebAuthnService 通行密钥服务，负责注册与认证流程
+// 通行密钥可用于无密码登录（要求用户验证），也可作为已启用两步验证账号的第二因素
+type WebAuthnService struct {
+	credentialRepository *repositories.WebAuthnCredentialRepository
+	userRepository       *repositories.UserRepository
+	redisMgr             *redis.Manager
+	tokenHasher          *utils.TokenHasher
+	logMgr               *logger.Manager
+	cfg                  appconfig.WebAuthnConfig
+}
+
+// NewWebAuthnService 创建通行密钥服务实例，cfg 中的 RPID 与 Origins 须已填充
+func NewWebAuthnService(credentialRepository *repositories.WebAuthnCredentialRepository, userRepository *repositories.UserRepository, redisMgr *redis.Manager, tokenHasher *utils.TokenHasher, logMgr *logger.Manager, cfg appconfig.WebAuthnConfig) *WebAuthnService {
+	return &WebAuthnService{credentialRepository: credentialRepository, userRepository: userRepository, redisMgr: redisMgr, tokenHasher: tokenHasher, logMgr: logMgr, cfg: cfg}
+}
+
+// BeginRegistration 发起通行密钥注册，返回浏览器 navigator.credentials.create 的参数
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID uint) (*dto.WebAuthnCreationOptions, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepository.Find(ctx, map[string]any{"user_id": userID})
+	if err != nil {
+		s.logMgr.Error("获取通行密钥失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if len(credentials) >= s.cfg.MaxCredentials {
+		return nil, apperrors.ErrWebAuthnLimitExceeded
+	}
+
+	challenge, err := s.saveCeremony(ctx, &webauthnCeremony{Type: utils.WebAuthnTypeCreate, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.Nickname
+	if displayName == "" {
+		displayName = user.Username
+	}
+	return &dto.WebAuthnCreationOptions{
+		Challenge: challenge,
+		RP:        dto.WebAuthnRelyingParty{ID: s.cfg.RPID, Name: s.cfg.RPName},
+		User:      dto.WebAuthnUserEntity{ID: s.userHandle(userID), Name: user.Username, DisplayName: displayName},
+		PubKeyCredParams: []dto.WebAuthnCredentialParameter{
+			{Type: "public-key", Alg: utils.COSEAlgES256},
+			{Type: "public-key", Alg: utils.COSEAlgEdDSA},
+			{Type: "public-key", Alg: utils.COSEAlgRS256},
+		},
+		Timeout:            s.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(credentials),
+		// 要求可发现凭证，无密码登录时无需先输入用户名
+		AuthenticatorSelection: dto.WebAuthnAuthenticatorSelection{ResidentKey: "required", UserVerification: "preferred"},
+		Attestation:            "none",
+	}, nil
+}
+
+// FinishRegistration 校验认证器的注册结果并保存通行密钥
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID uint, req *dto.WebAuthnRegisterRequest) (*dto.PasskeyResponse, error) {
+	clientDataJSON, err := utils.WebAuthnEncoding.DecodeString(req.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	clientData, err := utils.ParseWebAuthnClientData(clientDataJSON, utils.WebAuthnTypeCreate, s.cfg.Origins)
+	if err != nil {
+		s.logMgr.Info("通行密钥注册校验失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	ceremony, err := s.takeCeremony(ctx, clientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.Type != utils.WebAuthnTypeCreate || ceremony.UserID != userID {
+		return nil, apperrors.ErrWebAuthnCeremonyInvalid
+	}
+
+	attestationObject, err := utils.WebAuthnEncoding.DecodeString(req.Credential.Response.AttestationObject)
+	if err != nil {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	rawAuthData, err := utils.ParseWebAuthnAttestationObject(attestationObject)
+	if err != nil {
+		s.logMgr.Info("通行密钥注册校验失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	authData, err := utils.ParseWebAuthnAuthenticatorData(rawAuthData)
+	if err == nil && authData.CredentialID == nil {
+		err = utils.ErrWebAuthnAuthData
+	}
+	if err == nil {
+		err = authData.Verify(s.cfg.RPID, ceremony.UserVerification)
+	}
+	if err == nil && utils.WebAuthnEncoding.EncodeToString(authData.CredentialID) != req.Credential.ID {
+		err = utils.ErrWebAuthnCredentialID
+	}
+	if err == nil {
+		_, _, err = utils.ParseCOSEKey(authData.PublicKey)
+	}
+	if err != nil {
+		s.logMgr.Info("通行密钥注册校验失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+
+	// 同一认证器重复注册时浏览器会按 excludeCredentials 拦截，这里兜底
+	if _, err := s.getCredential(ctx, map[string]any{"credential_id": req.Credential.ID}); err == nil {
+		return nil, apperrors.ErrWebAuthnCredentialExists
+	} else if !errors.Is(err, apperrors.ErrWebAuthnNotFound) {
+		return nil, err
+	}
+
+	credential := &models.WebAuthnCredential{
+		UserID:       userID,
+		Name:         req.Name,
+		CredentialID: req.Credential.ID,
+		PublicKey:    authData.PublicKey,
+		SignCount:    authData.SignCount,
+		AAGUID:       hex.EncodeToString(authData.AAGUID),
+		Transports:   strings.Join(req.Credential.Response.Transports, ","),
+	}
+	if err := s.credentialRepository.Create(ctx, credential); err != nil {
+		s.logMgr.Error("保存通行密钥失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+
+	s.logMgr.Info("注册通行密钥成功", "user_id", userID, "credential_id", credential.ID)
+	return toPasskeyResponse(credential), nil
+}
+
+// ListCredentials 获取用户的通行密钥列表
+func (s *WebAuthnService) ListCredentials(ctx context.Context, userID uint) ([]dto.PasskeyResponse, error) {
+	credentials, err := s.credentialRepository.Find(ctx, map[string]any{"user_id": userID})
+	if err != nil {
+		s.logMgr.Error("获取通行密钥失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+
+	items := make([]dto.PasskeyResponse, 0, len(credentials))
+	for i := range credentials {
+		items = append(items, *toPasskeyResponse(&credentials[i]))
+	}
+	return items, nil
+}
+
+// RenameCredential 修改通行密钥名称
+func (s *WebAuthnService) RenameCredential(ctx context.Context, userID, id uint, name string) error {
+	if _, err := s.getCredential(ctx, map[string]any{"id": id, "user_id": userID}); err != nil {
+		return err
+	}
+	if err := s.credentialRepository.Update(ctx, id, map[string]any{"name": name}); err != nil {
+		s.logMgr.Error("修改通行密钥失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+	return nil
+}
+
+// DeleteCredential 删除通行密钥
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, id uint) error {
+	n, err := s.credentialRepository.Delete(ctx, map[string]any{"id": id, "user_id": userID})
+	if err != nil {
+		s.logMgr.Error("删除通行密钥失败", "error", err, "user_id", userID)
+		return apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return apperrors.ErrWebAuthnNotFound
+	}
+	s.logMgr.Info("删除通行密钥成功", "user_id", userID, "credential_id", id)
+	return nil
+}
+
+// HasCredentials 判断用户是否注册了通行密钥
+func (s *WebAuthnService) HasCredentials(ctx context.Context, userID uint) (bool, error) {
+	count, err := s.credentialRepository.Count(ctx, map[string]any{"user_id": userID})
+	if err != nil {
+		s.logMgr.Error("统计通行密钥失败", "error", err, "user_id", userID)
+		return false, apperrors.ErrUserSystemBusy
+	}
+	return count > 0, nil
+}
+
+// BeginLogin 发起无密码登录，不限定凭证，由浏览器列出可发现凭证
+// 通行密钥单独作为登录凭据时必须完成用户验证
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*dto.WebAuthnRequestOptions, error) {
+	challenge, err := s.saveCeremony(ctx, &webauthnCeremony{Type: utils.WebAuthnTypeGet, UserVerification: true})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.cfg.Timeout.Milliseconds(),
+		RPID:             s.cfg.RPID,
+		AllowCredentials: []dto.WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin 校验无密码登录的断言，返回凭证所属用户
+func (s *WebAuthnService) FinishLogin(ctx context.Context, credential *dto.WebAuthnCredential) (*models.User, error) {
+	stored, err := s.verifyAssertion(ctx, credential, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": stored.UserID})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logMgr.Error("获取用户失败", "error", err, "user_id", stored.UserID)
+			return nil, apperrors.ErrUserSystemBusy
+		}
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	return user, nil
+}
+
+// BeginSecondFactor 发起两步验证阶段的通行密钥认证，只允许该用户已注册的凭证
+func (s *WebAuthnService) BeginSecondFactor(ctx context.Context, userID uint) (*dto.WebAuthnRequestOptions, error) {
+	credentials, err := s.credentialRepository.Find(ctx, map[string]any{"user_id": userID})
+	if err != nil {
+		s.logMgr.Error("获取通行密钥失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if len(credentials) == 0 {
+		return nil, apperrors.ErrWebAuthnNoCredentials
+	}
+
+	challenge, err := s.saveCeremony(ctx, &webauthnCeremony{Type: utils.WebAuthnTypeGet, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return &dto.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		Timeout:          s.cfg.Timeout.Milliseconds(),
+		RPID:             s.cfg.RPID,
+		AllowCredentials: credentialDescriptors(credentials),
+		UserVerification: "preferred",
+	}, nil
+}
+
+// FinishSecondFactor 校验两步验证阶段的断言，凭证必须属于该用户
+func (s *WebAuthnService) FinishSecondFactor(ctx context.Context, userID uint, credential *dto.WebAuthnCredential) error {
+	_, err := s.verifyAssertion(ctx, credential, userID)
+	return err
+}
+
+// verifyAssertion 校验认证断言并更新签名计数器，userID 为 0 表示无密码登录
+func (s *WebAuthnService) verifyAssertion(ctx context.Context, credential *dto.WebAuthnCredential, userID uint) (*models.WebAuthnCredential, error) {
+	clientDataJSON, err := utils.WebAuthnEncoding.DecodeString(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	clientData, err := utils.ParseWebAuthnClientData(clientDataJSON, utils.WebAuthnTypeGet, s.cfg.Origins)
+	if err != nil {
+		s.logMgr.Info("通行密钥认证校验失败", "error", err)
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	ceremony, err := s.takeCeremony(ctx, clientData.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.Type != utils.WebAuthnTypeGet || ceremony.UserID != userID {
+		return nil, apperrors.ErrWebAuthnCeremonyInvalid
+	}
+
+	stored, err := s.getCredential(ctx, map[string]any{"credential_id": credential.ID})
+	if err != nil {
+		if errors.Is(err, apperrors.ErrWebAuthnNotFound) {
+			return nil, apperrors.ErrWebAuthnVerifyFailed
+		}
+		return nil, err
+	}
+	if userID != 0 && stored.UserID != userID {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	// 可发现凭证会返回用户句柄，须与凭证所属用户一致
+	if handle := credential.Response.UserHandle; handle != "" && subtle.ConstantTimeCompare([]byte(handle), []byte(s.userHandle(stored.UserID))) != 1 {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+
+	rawAuthData, err := utils.WebAuthnEncoding.DecodeString(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	signature, err := utils.WebAuthnEncoding.DecodeString(credential.Response.Signature)
+	if err != nil {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	authData, err := utils.ParseWebAuthnAuthenticatorData(rawAuthData)
+	if err == nil {
+		err = authData.Verify(s.cfg.RPID, ceremony.UserVerification)
+	}
+	if err == nil {
+		err = utils.VerifyWebAuthnSignature(stored.PublicKey, rawAuthData, clientDataJSON, signature)
+	}
+	if err != nil {
+		s.logMgr.Info("通行密钥认证校验失败", "error", err, "user_id", stored.UserID)
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+
+	// 计数器不递增说明存在凭证被复制的可能；两端均为 0 表示认证器不支持计数器
+	if (authData.SignCount != 0 || stored.SignCount != 0) && authData.SignCount <= stored.SignCount {
+		s.logMgr.Warn("通行密钥签名计数异常", "user_id", stored.UserID, "credential_id", stored.ID, "stored", stored.SignCount, "received", authData.SignCount)
+		return nil, apperrors.ErrWebAuthnCredentialCloned
+	}
+	n, err := s.credentialRepository.RecordUse(ctx, stored.ID, stored.SignCount, authData.SignCount, time.Now())
+	if err != nil {
+		s.logMgr.Error("更新通行密钥计数器失败", "error", err, "user_id", stored.UserID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return nil, apperrors.ErrWebAuthnVerifyFailed
+	}
+	return stored, nil
+}
+
+// saveCeremony 生成挑战并保存流程状态，返回 base64url 编码的挑战
+func (s *WebAuthnService) saveCeremony(ctx context.Context, ceremony *webauthnCeremony) (string, error) {
+	challenge, err := utils.GenerateWebAuthnChallenge()
+	if err != nil {
+		s.logMgr.Error("生成通行密钥挑战失败", "error", err)
+		return "", apperrors.ErrWebAuthnCeremonyFailed
+	}
+	data, err := json.Marshal(ceremony)
+	if err != nil {
+		return "", apperrors.ErrWebAuthnCeremonyFailed
+	}
+	if err := s.redisMgr.SetBytes(ctx, webauthnCeremonyKeyPrefix+s.tokenHasher.Hash(challenge), data, s.cfg.Timeout); err != nil {
+		s.logMgr.Error("保存通行密钥挑战失败", "error", err, "user_id", ceremony.UserID)
+		return "", apperrors.ErrWebAuthnCeremonyFailed
+	}
+	return challenge, nil
+}
+
+// takeCeremony 取出并作废挑战对应的流程，校验失败时挑战同样作废
+func (s *WebAuthnService) takeCeremony(ctx context.Context, challenge string) (*webauthnCeremony, error) {
+	key := webauthnCeremonyKeyPrefix + s.tokenHasher.Hash(challenge)
+	data, err := s.redisMgr.GetBytes(ctx, key)
+	if err != nil {
+		s.logMgr.Error("读取通行密钥挑战失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if data == nil {
+		return nil, apperrors.ErrWebAuthnCeremonyInvalid
+	}
+	n, err := s.redisMgr.Del(ctx, key)
+	if err != nil {
+		s.logMgr.Error("删除通行密钥挑战失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	if n == 0 {
+		return nil, apperrors.ErrWebAuthnCeremonyInvalid
+	}
+
+	var ceremony webauthnCeremony
+	if err := json.Unmarshal(data, &ceremony); err != nil {
+		return nil, apperrors.ErrWebAuthnCeremonyInvalid
+	}
+	return &ceremony, nil
+}
+
+// userHandle 用户句柄，由用户ID派生，不暴露用户ID本身
+func (s *WebAuthnService) userHandle(userID uint) string {
+	digest, _ := hex.DecodeString(s.tokenHasher.Hash("webauthn:" + strconv.FormatUint(uint64(userID), 10)))
+	return utils.WebAuthnEncoding.EncodeToString(digest)
+}
+
+func (s *WebAuthnService) getUser(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.userRepository.Get(ctx, map[string]any{"id": userID})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUserNotFound
+		}
+		s.logMgr.Error("获取用户失败", "error", err, "user_id", userID)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	return user, nil
+}
+
+func (s *WebAuthnService) getCredential(ctx context.Context, conds map[string]any) (*models.WebAuthnCredential, error) {
+	credential, err := s.credentialRepository.Get(ctx, conds)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrWebAuthnNotFound
+		}
+		s.logMgr.Error("获取通行密钥失败", "error", err)
+		return nil, apperrors.ErrUserSystemBusy
+	}
+	return credential, nil
+}
+
+// credentialDescriptors 转换为浏览器可识别的凭证描述
+func credentialDescriptors(credentials []models.WebAuthnCredential) []dto.WebAuthnCredentialDescriptor {
+	descriptors := make([]dto.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := dto.WebAuthnCredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+		if credential.Transports != "" {
+			descriptor.Transports = strings.Split(credential.Transports, ",")
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors
+}
+
+func toPasskeyResponse(credential *models.WebAuthnCredential) *dto.PasskeyResponse {
+	return &dto.PasskeyResponse{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"goauth/appconfig"
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+// softAuthenticator 软件认证器，按 WebAuthn 规范生成注册与认证数据，用于端到端校验整个流程
+type softAuthenticator struct {
+	alg          int64
+	rpID         string
+	origin       string
+	credentialID []byte
+	ecKey        *ecdsa.PrivateKey
+	edKey        ed25519.PrivateKey
+	signCount    uint32
+	userVerified bool
+}
+
+func newSoftAuthenticator(t *testing.T, alg int64) *softAuthenticator {
+	t.Helper()
+	a := &softAuthenticator{alg: alg, rpID: testRPID, origin: testOrigin, credentialID: make([]byte, 16), userVerified: true}
+	if _, err := rand.Read(a.credentialID); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	switch alg {
+	case utils.COSEAlgES256:
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case utils.COSEAlgEdDSA:
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("不支持的算法 %d", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// cosePublicKey COSE 编码的公钥
+func (a *softAuthenticator) cosePublicKey() []byte {
+	if a.alg == utils.COSEAlgEdDSA {
+		return cborMap(
+			cborInt(1), cborInt(1),
+			cborInt(3), cborInt(utils.COSEAlgEdDSA),
+			cborInt(-1), cborInt(6),
+			cborInt(-2), cborBytes(a.edKey.Public().(ed25519.PublicKey)),
+		)
+	}
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.ecKey.X.FillBytes(x)
+	a.ecKey.Y.FillBytes(y)
+	return cborMap(
+		cborInt(1), cborInt(2),
+		cborInt(3), cborInt(utils.COSEAlgES256),
+		cborInt(-1), cborInt(1),
+		cborInt(-2), cborBytes(x),
+		cborInt(-3), cborBytes(y),
+	)
+}
+
+// authenticatorData 认证器数据，注册时附带凭证ID与公钥
+func (a *softAuthenticator) authenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(0x01)
+	if a.userVerified {
+		flags |= 0x04
+	}
+	if attested {
+		flags |= 0x40
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.cosePublicKey()...)
+	}
+	return data
+}
+
+func (a *softAuthenticator) clientDataJSON(typ, challenge string) []byte {
+	data, _ := json.Marshal(utils.WebAuthnClientData{Type: typ, Challenge: challenge, Origin: a.origin})
+	return data
+}
+
+// create 模拟 navigator.credentials.create
+func (a *softAuthenticator) create(challenge string) dto.WebAuthnCredential {
+	attestationObject := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(a.authenticatorData(true)),
+	)
+	id := utils.WebAuthnEncoding.EncodeToString(a.credentialID)
+	return dto.WebAuthnCredential{
+		ID:    id,
+		RawID: id,
+		Type:  "public-key",
+		Response: dto.WebAuthnCredentialResponse{
+			ClientDataJSON:    utils.WebAuthnEncoding.EncodeToString(a.clientDataJSON(utils.WebAuthnTypeCreate, challenge)),
+			AttestationObject: utils.WebAuthnEncoding.EncodeToString(attestationObject),
+			Transports:        []string{"internal"},
+		},
+	}
+}
+
+// get 模拟 navigator.credentials.get，每次断言前递增签名计数器
+func (a *softAuthenticator) get(t *testing.T, challenge, userHandle string) *dto.WebAuthnCredential {
+	t.Helper()
+	a.signCount++
+	return a.getWithCount(t, challenge, userHandle)
+}
+
+// getWithCount 使用当前计数器生成断言
+func (a *softAuthenticator) getWithCount(t *testing.T, challenge, userHandle string) *dto.WebAuthnCredential {
+	t.Helper()
+	authData := a.authenticatorData(false)
+	clientDataJSON := a.clientDataJSON(utils.WebAuthnTypeGet, challenge)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var signature []byte
+	if a.alg == utils.COSEAlgEdDSA {
+		signature = ed25519.Sign(a.edKey, signed)
+	} else {
+		digest := sha256.Sum256(signed)
+		var err error
+		if signature, err = ecdsa.SignASN1(rand.Reader, a.ecKey, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	id := utils.WebAuthnEncoding.EncodeToString(a.credentialID)
+	return &dto.WebAuthnCredential{
+		ID:    id,
+		RawID: id,
+		Type:  "public-key",
+		Response: dto.WebAuthnCredentialResponse{
+			ClientDataJSON:    utils.WebAuthnEncoding.EncodeToString(clientDataJSON),
+			AuthenticatorData: utils.WebAuthnEncoding.EncodeToString(authData),
+			Signature:         utils.WebAuthnEncoding.EncodeToString(signature),
+			UserHandle:        userHandle,
+		},
+	}
+}
+
+// CBOR 编码，只覆盖测试所需的定长子集
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+	return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+}
+
+func cborInt(v int64) []byte {
+	if v < 0 {
+		return cborHead(1, uint64(-1-v))
+	}
+	return cborHead(0, uint64(v))
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, uint64(len(b))), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, uint64(len(s))), s...)
+}
+
+func cborMap(pairs ...[]byte) []byte {
+	out := cborHead(5, uint64(len(pairs)/2))
+	for _, p := range pairs {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// webauthnTestEnv 通行密钥服务及其依赖
+type webauthnTestEnv struct {
+	service *WebAuthnService
+	user    *models.User
+}
+
+func newWebAuthnTestEnv(t *testing.T) *webauthnTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.User{}, &models.WebAuthnCredential{})
+	redisMgr, _ := newTestRedis(t)
+	userRepository := repositories.NewUserRepository(db)
+
+	user := &models.User{Subject: "subject-alice", Username: "alice", Password: "x", Status: models.UserStatusActive}
+	if err := userRepository.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := appconfig.WebAuthnConfig{RPID: testRPID, RPName: "goauth", Origins: []string{testOrigin}, Timeout: time.Minute, MaxCredentials: 5}
+	service := NewWebAuthnService(repositories.NewWebAuthnCredentialRepository(db), userRepository, redisMgr, newTestTokenHasher(t), newTestLogger(t), cfg)
+	return &webauthnTestEnv{service: service, user: user}
+}
+
+// register 完成一次注册流程，返回用户句柄
+func (env *webauthnTestEnv) register(t *testing.T, authenticator *softAuthenticator) string {
+	t.Helper()
+	ctx := context.Background()
+	options, err := env.service.BeginRegistration(ctx, env.user.ID)
+	if err != nil {
+		t.Fatalf("发起注册失败: %v", err)
+	}
+	if _, err := env.service.FinishRegistration(ctx, env.user.ID, &dto.WebAuthnRegisterRequest{Name: "key", Credential: authenticator.create(options.Challenge)}); err != nil {
+		t.Fatalf("完成注册失败: %v", err)
+	}
+	return options.User.ID
+}
+
+// login 完成一次无密码登录流程
+func (env *webauthnTestEnv) login(t *testing.T, authenticator *softAuthenticator, userHandle string) (*models.User, error) {
+	t.Helper()
+	options, err := env.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("发起登录失败: %v", err)
+	}
+	return env.service.FinishLogin(context.Background(), authenticator.get(t, options.Challenge, userHandle))
+}
+
+func TestWebAuthnRegisterAndLogin(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		alg  int64
+	}{
+		{"ES256", utils.COSEAlgES256},
+		{"EdDSA", utils.COSEAlgEdDSA},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newWebAuthnTestEnv(t)
+			authenticator := newSoftAuthenticator(t, tc.alg)
+			userHandle := env.register(t, authenticator)
+
+			user, err := env.login(t, authenticator, userHandle)
+			if err != nil {
+				t.Fatalf("无密码登录失败: %v", err)
+			}
+			if user.ID != env.user.ID {
+				t.Fatalf("登录用户 = %d，期望 %d", user.ID, env.user.ID)
+			}
+
+			// 同一凭证用于两步验证
+			ctx := context.Background()
+			options, err := env.service.BeginSecondFactor(ctx, env.user.ID)
+			if err != nil {
+				t.Fatalf("发起两步验证失败: %v", err)
+			}
+			if len(options.AllowCredentials) != 1 || options.AllowCredentials[0].ID != utils.WebAuthnEncoding.EncodeToString(authenticator.credentialID) {
+				t.Fatalf("allowCredentials = %+v", options.AllowCredentials)
+			}
+			if err := env.service.FinishSecondFactor(ctx, env.user.ID, authenticator.get(t, options.Challenge, "")); err != nil {
+				t.Fatalf("两步验证失败: %v", err)
+			}
+		})
+	}
+}
+
+func TestWebAuthnRejectsRPIDMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("注册", func(t *testing.T) {
+		env := newWebAuthnTestEnv(t)
+		authenticator := newSoftAuthenticator(t, utils.COSEAlgES256)
+		authenticator.rpID = "evil.example"
+		options, err := env.service.BeginRegistration(ctx, env.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = env.service.FinishRegistration(ctx, env.user.ID, &dto.WebAuthnRegisterRequest{Name: "key", Credential: authenticator.create(options.Challenge)})
+		if !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+			t.Fatalf("err = %v，期望 %v", err, apperrors.ErrWebAuthnVerifyFailed)
+		}
+	})
+
+	t.Run("登录", func(t *testing.T) {
+		env := newWebAuthnTestEnv(t)
+		authenticator := newSoftAuthenticator(t, utils.COSEAlgES256)
+		userHandle := env.register(t, authenticator)
+		authenticator.rpID = "evil.example"
+		if _, err := env.login(t, authenticator, userHandle); !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+			t.Fatalf("err = %v，期望 %v", err, apperrors.ErrWebAuthnVerifyFailed)
+		}
+	})
+}
+
+func TestWebAuthnRejectsOriginMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("注册", func(t *testing.T) {
+		env := newWebAuthnTestEnv(t)
+		authenticator := newSoftAuthenticator(t, utils.COSEAlgEdDSA)
+		authenticator.origin = "https://evil.example"
+		options, err := env.service.BeginRegistration(ctx, env.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = env.service.FinishRegistration(ctx, env.user.ID, &dto.WebAuthnRegisterRequest{Name: "key", Credential: authenticator.create(options.Challenge)})
+		if !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+			t.Fatalf("err = %v，期望 %v", err, apperrors.ErrWebAuthnVerifyFailed)
+		}
+	})
+
+	t.Run("登录", func(t *testing.T) {
+		env := newWebAuthnTestEnv(t)
+		authenticator := newSoftAuthenticator(t, utils.COSEAlgEdDSA)
+		userHandle := env.register(t, authenticator)
+		authenticator.origin = "http://example.com"
+		if _, err := env.login(t, authenticator, userHandle); !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+			t.Fatalf("err = %v，期望 %v", err, apperrors.ErrWebAuthnVerifyFailed)
+		}
+	})
+}
+
+func TestWebAuthnRejectsChallengeReuse(t *testing.T) {
+	ctx := context.Background()
+	env := newWebAuthnTestEnv(t)
+	authenticator := newSoftAuthenticator(t, utils.COSEAlgES256)
+
+	options, err := env.service.BeginRegistration(ctx, env.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := authenticator.create(options.Challenge)
+	if _, err := env.service.FinishRegistration(ctx, env.user.ID, &dto.WebAuthnRegisterRequest{Name: "key", Credential: credential}); err != nil {
+		t.Fatalf("完成注册失败: %v", err)
+	}
+	if _, err := env.service.FinishRegistration(ctx, env.user.ID, &dto.WebAuthnRegisterRequest{Name: "key", Credential: credential}); !errors.Is(err, apperrors.ErrWebAuthnCeremonyInvalid) {
+		t.Fatalf("重放注册 err = %v，期望 %v", err, apperrors.ErrWebAuthnCeremonyInvalid)
+	}
+
+	loginOptions, err := env.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := authenticator.get(t, loginOptions.Challenge, options.User.ID)
+	if _, err := env.service.FinishLogin(ctx, assertion); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if _, err := env.service.FinishLogin(ctx, assertion); !errors.Is(err, apperrors.ErrWebAuthnCeremonyInvalid) {
+		t.Fatalf("重放断言 err = %v，期望 %v", err, apperrors.ErrWebAuthnCeremonyInvalid)
+	}
+
+	// 注册流程的挑战不能用于登录
+	registerOptions, err := env.service.BeginRegistration(ctx, env.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.service.FinishLogin(ctx, authenticator.get(t, registerOptions.Challenge, options.User.ID)); !errors.Is(err, apperrors.ErrWebAuthnCeremonyInvalid) {
+		t.Fatalf("跨流程使用挑战 err = %v，期望 %v", err, apperrors.ErrWebAuthnCeremonyInvalid)
+	}
+}
+
+func TestWebAuthnRejectsSignCountRegression(t *testing.T) {
+	env := newWebAuthnTestEnv(t)
+	authenticator := newSoftAuthenticator(t, utils.COSEAlgEdDSA)
+	authenticator.signCount = 5
+	userHandle := env.register(t, authenticator)
+
+	if _, err := env.login(t, authenticator, userHandle); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	// 复制出的凭证计数器落后于已记录的值
+	for _, signCount := range []uint32{authenticator.signCount, authenticator.signCount - 2} {
+		options, err := env.service.BeginLogin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.signCount = signCount
+		_, err = env.service.FinishLogin(context.Background(), authenticator.getWithCount(t, options.Challenge, userHandle))
+		if !errors.Is(err, apperrors.ErrWebAuthnCredentialCloned) {
+			t.Fatalf("计数器 %d: err = %v，期望 %v", signCount, err, apperrors.ErrWebAuthnCredentialCloned)
+		}
+	}
+}
+
+func TestWebAuthnAllowsZeroSignCount(t *testing.T) {
+	env := newWebAuthnTestEnv(t)
+	authenticator := newSoftAuthenticator(t, utils.COSEAlgES256)
+	userHandle := env.register(t, authenticator)
+
+	// 不支持计数器的认证器始终返回 0
+	for i := 0; i < 2; i++ {
+		options, err := env.service.BeginLogin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := env.service.FinishLogin(context.Background(), authenticator.getWithCount(t, options.Challenge, userHandle)); err != nil {
+			t.Fatalf("第 %d 次登录失败: %v", i+1, err)
+		}
+	}
+}
+
+func TestWebAuthnLoginRequiresUserVerification(t *testing.T) {
+	ctx := context.Background()
+	env := newWebAuthnTestEnv(t)
+	authenticator := newSoftAuthenticator(t, utils.COSEAlgES256)
+	userHandle := env.register(t, authenticator)
+	authenticator.userVerified = false
+
+	if _, err := env.login(t, authenticator, userHandle); !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+		t.Fatalf("未完成用户验证的无密码登录 err = %v，期望 %v", err, apperrors.ErrWebAuthnVerifyFailed)
+	}
+
+	// 作为第二因素时只要求用户在场
+	options, err := env.service.BeginSecondFactor(ctx, env.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.service.FinishSecondFactor(ctx, env.user.ID, authenticator.get(t, options.Challenge, "")); err != nil {
+		t.Fatalf("两步验证失败: %v", err)
+	}
+}
+
+func TestWebAuthnRejectsTamperedAssertion(t *testing.T) {
+	env := newWebAuthnTestEnv(t)
+	authenticator := newSoftAuthenticator(t, utils.COSEAlgES256)
+	userHandle := env.register(t, authenticator)
+
+	options, err := env.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := authenticator.get(t, options.Challenge, userHandle)
+
+	// 签名之后改动认证器数据（提高计数器）
+	authData, _ := utils.WebAuthnEncoding.DecodeString(assertion.Response.AuthenticatorData)
+	binary.BigEndian.PutUint32(authData[33:37], 100)
+	assertion.Response.AuthenticatorData = utils.WebAuthnEncoding.EncodeToString(authData)
+
+	if _, err := env.service.FinishLogin(context.Background(), assertion); !errors.Is(err, apperrors.ErrWebAuthnVerifyFailed) {
+		t.Fatalf("err = %v，期望 %v", err, apperrors.ErrWebAuthnVerifyFailed)
+	}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/security/random"
+	"gorm.io/gorm"
+
+	"goauth/apperrors"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// WebhookService Webhook 订阅管理，并在业务事务中写入待投递的事件（outbox）
+// 事件随变更一同提交或回滚，投递由 WebhookDispatcher 在事务之外完成
+type WebhookService struct {
+	webhookSubscriptionRepository *repositories.WebhookSubscriptionRepository
+	webhookDeliveryRepository     *repositories.WebhookDeliveryRepository
+	secretBox                     *utils.SecretBox
+	logMgr                        *logger.Manager
+	auditService                  *AuditService
+}
+
+// NewWebhookService 创建 Webhook 服务实例
+func NewWebhookService(webhookSubscriptionRepository *repositories.WebhookSubscriptionRepository, webhookDeliveryRepository *repositories.WebhookDeliveryRepository, secretBox *utils.SecretBox, logMgr *logger.Manager, auditService *AuditService) *WebhookService {
+	return &WebhookService{webhookSubscriptionRepository: webhookSubscriptionRepository, webhookDeliveryRepository: webhookDeliveryRepository, secretBox: secretBox, logMgr: logMgr, auditService: auditService}
+}
+
+// EnqueueWithTx 在事务中为订阅了 eventType 的已启用订阅各写入一条投递记录，没有订阅时不写入
+// 写入失败时调用方应回滚事务，保证变更与事件一致
+func (s *WebhookService) EnqueueWithTx(ctx context.Context, tx *gorm.DB, eventType string, data any) error {
+	subscriptions, err := s.webhookSubscriptionRepository.FindEnabledWithTx(ctx, tx)
+	if err != nil {
+		s.logMgr.Error("查询 Webhook 订阅失败", "error", err, "event", eventType)
+		return apperrors.ErrWebhookEventEnqueueFailed
+	}
+
+	var deliveries []models.WebhookDelivery
+	var event *dto.WebhookEvent
+	var payload []byte
+	for i := range subscriptions {
+		if !slices.Contains(webhookEvents(&subscriptions[i]), eventType) {
+			continue
+		}
+		if event == nil {
+			if event, payload, err = newWebhookEvent(ctx, eventType, data); err != nil {
+				s.logMgr.Error("生成 Webhook 事件失败", "error", err, "event", eventType)
+				return apperrors.ErrWebhookEventEnqueueFailed
+			}
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: subscriptions[i].ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  event.CreatedAt,
+		})
+	}
+
+	if err := s.webhookDeliveryRepository.CreateWithTx(ctx, tx, deliveries); err != nil {
+		s.logMgr.Error("写入 Webhook 投递记录失败", "error", err, "event", eventType)
+		return apperrors.ErrWebhookEventEnqueueFailed
+	}
+	return nil
+}
+
+// EnqueueForClient 写入一条投递给 OAuth 客户端（而不是订阅）的记录，由对应事件类型的投递实现发送
+// 记录在事务外写入，调用方无需与其他变更保持一致
+func (s *WebhookService) EnqueueForClient(ctx context.Context, clientID, eventType string, data any) error {
+	event, payload, err := newWebhookEvent(ctx, eventType, data)
+	if err != nil {
+		s.logMgr.Error("生成 Webhook 事件失败", "error", err, "event", eventType)
+		return apperrors.ErrWebhookEventEnqueueFailed
+	}
+	delivery := models.WebhookDelivery{
+		ClientID:      clientID,
+		EventID:       event.ID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: event.CreatedAt,
+	}
+	if err := s.webhookDeliveryRepository.CreateWithTx(ctx, s.webhookDeliveryRepository.DB(), []models.WebhookDelivery{delivery}); err != nil {
+		s.logMgr.Error("写入 Webhook 投递记录失败", "error", err, "event", eventType, "client_id", clientID)
+		return apperrors.ErrWebhookEventEnqueueFailed
+	}
+	return nil
+}
+
+// newWebhookEvent 生成事件及其请求体，同一事件投递给各订阅时内容相同
+func newWebhookEvent(ctx context.Context, eventType string, data any) (*dto.WebhookEvent, []byte, error) {
+	id, err := random.URLSafe(16)
+	if err != nil {
+		return nil, nil, err
+	}
+	event := &dto.WebhookEvent{ID: id, Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+	if tenant := utils.TenantFromContext(ctx); tenant != nil {
+		event.Tenant = tenant.Name
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	return event, payload, nil
+}
+
+// ListWebhooks 查询当前租户的全部订阅
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]dto.WebhookResponse, error) {
+	subscriptions, err := s.webhookSubscriptionRepository.List(ctx)
+	if err != nil {
+		s.logMgr.Error("查询 Webhook 订阅失败", "error", err)
+		return nil, apperrors.ErrWebhookSystemBusy
+	}
+	result := make([]dto.WebhookResponse, len(subscriptions))
+	for i := range subscriptions {
+		result[i] = renderWebhook(&subscriptions[i])
+	}
+	return result, nil
+}
+
+// GetWebhook 查询订阅，不返回签名密钥
+func (s *WebhookService) GetWebhook(ctx context.Context, id uint) (*dto.WebhookResponse, error) {
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := renderWebhook(subscription)
+	return &result, nil
+}
+
+// CreateWebhook 创建订阅并生成签名密钥，密钥只在此时返回
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *dto.CreateWebhookRequest) (result *dto.CreateWebhookResponse, err error) {
+	defer func() {
+		event := &models.AuditEvent{Type: models.AuditWebhookCreate, TargetType: "webhook"}
+		if result != nil {
+			event.TargetID = strconv.FormatUint(uint64(result.ID), 10)
+		}
+		s.auditService.Record(ctx, event, err)
+	}()
+
+	if err := validateWebhook(req.URL, req.Events); err != nil {
+		return nil, err
+	}
+
+	secret, err := random.URLSafe(32)
+	if err != nil {
+		s.logMgr.Error("生成 Webhook 签名密钥失败", "error", err)
+		return nil, apperrors.ErrWebhookSystemBusy
+	}
+	sealed, err := s.secretBox.Seal(secret)
+	if err != nil {
+		s.logMgr.Error("加密 Webhook 签名密钥失败", "error", err)
+		return nil, apperrors.ErrWebhookSystemBusy
+	}
+	events, _ := json.Marshal(req.Events)
+
+	subscription := &models.WebhookSubscription{Name: req.Name, URL: req.URL, Events: events, Enabled: true, Secret: sealed}
+	if req.Enabled != nil {
+		subscription.Enabled = *req.Enabled
+	}
+	if err := s.webhookSubscriptionRepository.Create(ctx, subscription); err != nil {
+		s.logMgr.Error("创建 Webhook 订阅失败", "error", err)
+		return nil, apperrors.ErrWebhookSystemBusy
+	}
+	s.logMgr.Info("创建 Webhook 订阅成功", "id", subscription.ID, "url", subscription.URL)
+
+	return &dto.CreateWebhookResponse{WebhookResponse: renderWebhook(subscription), Secret: secret}, nil
+}
+
+// UpdateWebhook 修改订阅，停用后不再产生新的投递，已产生的待投递记录在投递时记为失败
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uint, req *dto.UpdateWebhookRequest) (err error) {
+	defer func() {
+		s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditWebhookUpdate, TargetType: "webhook", TargetID: strconv.FormatUint(uint64(id), 10)}, err)
+	}()
+
+	subscription, err := s.getSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	updates := make(map[string]any)
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	webhookURL := subscription.URL
+	if req.URL != nil {
+		webhookURL = *req.URL
+		updates["url"] = webhookURL
+	}
+	events := webhookEvents(subscription)
+	if req.Events != nil {
+		events = req.Events
+		data, _ := json.Marshal(req.Events)
+		updates["events"] = data
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := validateWebhook(webhookURL, events); err != nil {
+		return err
+	}
+
+	if err := s.webhookSubscriptionRepository.Update(ctx, id, updates); err != nil {
+		s.logMgr.Error("更新 Webhook 订阅失败", "error", err, "id", id)
+		return apperrors.ErrWebhookSystemBusy
+	}
+	return nil
+}
+
+// DeleteWebhook 删除订阅及其全部投递记录
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) (err error) {
+	defer func() {
+		s.auditService.Record(ctx, &models.AuditEvent{Type: models.AuditWebhookDelete, TargetType: "webhook", TargetID: strconv.FormatUint(uint64(id), 10)}, err)
+	}()
+
+	if _, err := s.getSubscription(ctx, id); err != nil {
+		return err
+	}
+	if err := s.webhookSubscriptionRepository.Delete(ctx, id); err != nil {
+		s.logMgr.Error("删除 Webhook 订阅失败", "error", err, "id", id)
+		return apperrors.ErrWebhookSystemBusy
+	}
+	s.logMgr.Info("删除 Webhook 订阅成功", "id", id)
+	return nil
+}
+
+// ListDeliveries 分页查询投递记录，status 为 dead 时即为死信列表
+func (s *WebhookService) ListDeliveries(ctx context.Context, page, pageSize int, subscriptionID uint, status string) (*dto.PaginationResponse[dto.WebhookDeliveryResponse], error) {
+	conds := map[string]any{}
+	if subscriptionID != 0 {
+		conds["subscription_id"] = subscriptionID
+	}
+	if status != "" {
+		conds["status"] = status
+	}
+
+	deliveries, total, err := s.webhookDeliveryRepository.List(ctx, page, pageSize, conds)
+	if err != nil {
+		s.logMgr.Error("查询 Webhook 投递记录失败", "error", err)
+		return nil, apperrors.ErrWebhookSystemBusy
+	}
+
+	items := make([]dto.WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		items[i] = dto.WebhookDeliveryResponse{
+			ID:             delivery.ID,
+			SubscriptionID: delivery.SubscriptionID,
+			ClientID:       delivery.ClientID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        json.RawMessage(delivery.Payload),
+			Status:         delivery.Status,
+			Attempts:       delivery.Attempts,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			DeliveredAt:    delivery.DeliveredAt,
+			CreatedAt:      delivery.CreatedAt,
+		}
+	}
+	return &dto.PaginationResponse[dto.WebhookDeliveryResponse]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	}, nil
+}
+
+// RetryDelivery 将投递失败的记录重新放回队列，重新计算重试次数
+func (s *WebhookService) RetryDelivery(ctx context.Context, id uint) error {
+	affected, err := s.webhookDeliveryRepository.Requeue(ctx, id, time.Now())
+	if err != nil {
+		s.logMgr.Error("重新投递 Webhook 失败", "error", err, "id", id)
+		return apperrors.ErrWebhookSystemBusy
+	}
+	if affected == 0 {
+		return apperrors.ErrWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+func (s *WebhookService) getSubscription(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	subscription, err := s.webhookSubscriptionRepository.Get(ctx, map[string]any{"id": id})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrWebhookNotFound
+		}
+		s.logMgr.Error("获取 Webhook 订阅失败", "error", err, "id", id)
+		return nil, apperrors.ErrWebhookSystemBusy
+	}
+	return subscription, nil
+}
+
+// validateWebhook 校验接收地址与订阅的事件类型
+func validateWebhook(webhookURL string, events []string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apperrors.ErrWebhookURLInvalid
+	}
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return apperrors.ErrWebhookEventInvalid
+		}
+	}
+	return nil
+}
+
+// webhookEvents 解析订阅的事件类型，格式错误时视为未订阅任何事件
+func webhookEvents(subscription *models.WebhookSubscription) []string {
+	var events []string
+	_ = json.Unmarshal(subscription.Events, &events)
+	return events
+}
+
+// WebhookChangedFields 返回 updates 中的字段名并排序，skip 中的字段随其它字段一同变化，不单独列出
+func WebhookChangedFields(updates map[string]any, skip ...string) []string {
+	fields := make([]string, 0, len(updates))
+	for field := range updates {
+		if !slices.Contains(skip, field) {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+func renderWebhook(subscription *models.WebhookSubscription) dto.WebhookResponse {
+	return dto.WebhookResponse{
+		ID:        subscription.ID,
+		Name:      subscription.Name,
+		URL:       subscription.URL,
+		Events:    webhookEvents(subscription),
+		Enabled:   subscription.Enabled,
+		CreatedAt: subscription.CreatedAt,
+		UpdatedAt: subscription.UpdatedAt,
+	}
+}
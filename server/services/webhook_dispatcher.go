@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/3086953492/gokit/logger"
+	"github.com/3086953492/gokit/redis"
+
+	"goauth/appconfig"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// webhookLockKey 多副本部署时只允许一个实例投递，避免同一记录被重复投递
+const webhookLockKey = "webhook:dispatcher"
+
+// WebhookDeliveryHandler 发送不属于订阅的投递记录（如后端通道登出通知），按事件类型注册到投递任务
+// 由 oauth 包中的服务实现，该包依赖本包，只能在创建后注入
+type WebhookDeliveryHandler interface {
+	// Send 发送一条记录，返回响应状态码；非 2xx 响应或请求失败时返回错误
+	Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error)
+	// Finish 记录投递成功或重试次数用尽，err 为最后一次失败的原因
+	Finish(ctx context.Context, delivery *models.WebhookDelivery, err error)
+}
+
+// WebhookDispatcher 定时投递到期的 Webhook 记录
+// 接收方返回 2xx 视为成功；其它响应或请求失败时按指数退避重试，次数用尽后记为 dead
+type WebhookDispatcher struct {
+	webhookSubscriptionRepository *repositories.WebhookSubscriptionRepository
+	webhookDeliveryRepository     *repositories.WebhookDeliveryRepository
+	secretBox                     *utils.SecretBox
+	redisMgr                      *redis.Manager
+	httpClient                    *http.Client
+	logMgr                        *logger.Manager
+	handlers                      map[string]WebhookDeliveryHandler
+
+	cfg appconfig.WebhookConfig
+}
+
+// NewWebhookDispatcher 创建 Webhook 投递任务实例
+func NewWebhookDispatcher(webhookSubscriptionRepository *repositories.WebhookSubscriptionRepository, webhookDeliveryRepository *repositories.WebhookDeliveryRepository, secretBox *utils.SecretBox, redisMgr *redis.Manager, logMgr *logger.Manager, cfg appconfig.WebhookConfig) *WebhookDispatcher {
+	httpClient := &http.Client{
+		Timeout: cfg.Timeout,
+		// 不跟随重定向，3xx 按失败处理，避免签名请求被转发到订阅以外的地址
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &WebhookDispatcher{
+		webhookSubscriptionRepository: webhookSubscriptionRepository,
+		webhookDeliveryRepository:     webhookDeliveryRepository,
+		secretBox:                     secretBox,
+		redisMgr:                      redisMgr,
+		httpClient:                    httpClient,
+		logMgr:                        logMgr,
+		handlers:                      make(map[string]WebhookDeliveryHandler),
+		cfg:                           cfg,
+	}
+}
+
+// SetHandler 设置 eventType 类型记录的发送实现，须在 Start 之前调用
+func (d *WebhookDispatcher) SetHandler(eventType string, handler WebhookDeliveryHandler) {
+	d.handlers[eventType] = handler
+}
+
+// Start 按配置间隔定时投递，ctx 取消后退出；未启用时直接返回
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	if !d.cfg.Enabled || d.cfg.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(d.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := d.Run(ctx); err != nil && !errors.Is(err, redis.ErrLockAcquireFailed) {
+					d.logMgr.Error("投递 Webhook 失败", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Run 投递一批到期的记录，返回处理的记录数
+// 其它实例正在投递时返回 redis.ErrLockAcquireFailed
+func (d *WebhookDispatcher) Run(ctx context.Context) (int, error) {
+	// 逐条投递，锁的过期时间取一批请求全部超时的耗时，进程异常退出时锁也会自动释放
+	lock := d.redisMgr.NewDistributedLock(webhookLockKey, d.cfg.Timeout*time.Duration(d.cfg.BatchSize)+d.cfg.Interval)
+	if err := lock.Acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer lock.Release(ctx)
+
+	deliveries, err := d.webhookDeliveryRepository.FindDue(ctx, time.Now(), d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if delivery.SubscriptionID != 0 {
+			ids = append(ids, delivery.SubscriptionID)
+		}
+	}
+	subscriptions, err := d.webhookSubscriptionRepository.FindByIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	subscriptionsByID := make(map[uint]*models.WebhookSubscription, len(subscriptions))
+	for i := range subscriptions {
+		subscriptionsByID[subscriptions[i].ID] = &subscriptions[i]
+	}
+
+	for i := range deliveries {
+		d.deliver(ctx, &deliveries[i], subscriptionsByID[deliveries[i].SubscriptionID])
+	}
+	return len(deliveries), nil
+}
+
+// deliver 投递一条记录并写回结果
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery, subscription *models.WebhookSubscription) {
+	now := time.Now()
+	updates := map[string]any{"attempts": delivery.Attempts + 1}
+	handler := d.handlers[delivery.EventType]
+
+	switch {
+	case handler == nil && subscription == nil:
+		updates["status"] = models.WebhookDeliveryDead
+		updates["last_error"] = "订阅已删除"
+	case handler == nil && !subscription.Enabled:
+		updates["status"] = models.WebhookDeliveryDead
+		updates["last_error"] = "订阅已停用"
+	default:
+		var statusCode int
+		var err error
+		if handler != nil {
+			statusCode, err = handler.Send(ctx, delivery)
+		} else {
+			statusCode, err = d.send(ctx, delivery, subscription)
+		}
+		updates["last_status_code"] = statusCode
+		if err == nil {
+			updates["status"] = models.WebhookDeliveryDelivered
+			updates["last_error"] = ""
+			updates["delivered_at"] = now
+			if handler != nil {
+				handler.Finish(ctx, delivery, nil)
+			}
+			break
+		}
+		updates["last_error"] = truncateRunes(err.Error(), 500)
+		if delivery.Attempts+1 >= d.cfg.MaxAttempts {
+			updates["status"] = models.WebhookDeliveryDead
+			d.logMgr.Warn("Webhook 投递重试次数用尽", "id", delivery.ID, "subscription_id", delivery.SubscriptionID, "client_id", delivery.ClientID, "error", err)
+			if handler != nil {
+				handler.Finish(ctx, delivery, err)
+			}
+		} else {
+			updates["next_attempt_at"] = now.Add(d.backoff(delivery.Attempts + 1))
+		}
+	}
+
+	if err := d.webhookDeliveryRepository.Update(ctx, delivery.ID, updates); err != nil {
+		d.logMgr.Error("更新 Webhook 投递结果失败", "error", err, "id", delivery.ID)
+	}
+}
+
+// send 以签名的 POST 请求推送事件，返回响应状态码；非 2xx 响应返回错误
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *models.WebhookDelivery, subscription *models.WebhookSubscription) (int, error) {
+	secret, err := d.secretBox.Open(subscription.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("解密签名密钥失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goauth-webhook")
+	req.Header.Set("X-Goauth-Event", delivery.EventType)
+	req.Header.Set("X-Goauth-Event-Id", delivery.EventID)
+	req.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhook(secret, time.Now().Unix(), delivery.Payload))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("接收方返回 %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff 第 attempts 次失败后的等待时间，从 BackoffBase 起每次翻倍，不超过 BackoffMax
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.BackoffBase
+	for i := 1; i < attempts && wait < d.cfg.BackoffMax; i++ {
+		wait *= 2
+	}
+	return min(wait, d.cfg.BackoffMax)
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goauth/appconfig"
+	"goauth/dto"
+	"goauth/models"
+	"goauth/repositories"
+	"goauth/utils"
+)
+
+// webhookReceiver 记录收到的 Webhook 请求，按 statuses 依次返回状态码，用完后返回最后一个
+type webhookReceiver struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []webhookRequest
+}
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	r := &webhookReceiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, webhookRequest{header: req.Header.Clone(), body: body})
+		status := r.statuses[min(len(r.requests), len(r.statuses))-1]
+		r.mu.Unlock()
+		if status == http.StatusFound {
+			w.Header().Set("Location", "/elsewhere")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *webhookReceiver) received() []webhookRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookRequest(nil), r.requests...)
+}
+
+type webhookTestEnv struct {
+	db         *gorm.DB
+	service    *WebhookService
+	dispatcher *WebhookDispatcher
+	cfg        appconfig.WebhookConfig
+}
+
+func newWebhookTestEnv(t *testing.T) *webhookTestEnv {
+	t.Helper()
+	db := newTestDB(t, &models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.AuditEvent{})
+	redisMgr, _ := newTestRedis(t)
+	logMgr := newTestLogger(t)
+	secretBox, err := utils.NewSecretBox("test-secret-box-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := appconfig.WebhookConfig{Enabled: true, Interval: time.Second, BatchSize: 10, Timeout: 2 * time.Second, MaxAttempts: 3, BackoffBase: time.Minute, BackoffMax: 3 * time.Minute}
+	subscriptionRepository := repositories.NewWebhookSubscriptionRepository(db)
+	deliveryRepository := repositories.NewWebhookDeliveryRepository(db)
+	auditService := NewAuditService(repositories.NewAuditEventRepository(db), nil, logMgr)
+	return &webhookTestEnv{
+		db:         db,
+		service:    NewWebhookService(subscriptionRepository, deliveryRepository, secretBox, logMgr, auditService),
+		dispatcher: NewWebhookDispatcher(subscriptionRepository, deliveryRepository, secretBox, redisMgr, logMgr, cfg),
+		cfg:        cfg,
+	}
+}
+
+// subscribe 创建订阅 user.created 的订阅，返回签名密钥
+func (env *webhookTestEnv) subscribe(t *testing.T, receiverURL string) (uint, string) {
+	t.Helper()
+	result, err := env.service.CreateWebhook(context.Background(), &dto.CreateWebhookRequest{Name: "receiver", URL: receiverURL, Events: []string{models.WebhookUserCreated}})
+	if err != nil {
+		t.Fatalf("创建订阅失败: %v", err)
+	}
+	return result.ID, result.Secret
+}
+
+func (env *webhookTestEnv) enqueue(t *testing.T) {
+	t.Helper()
+	if err := env.service.EnqueueWithTx(context.Background(), env.db, models.WebhookUserCreated, dto.WebhookUserData{UserID: 7}); err != nil {
+		t.Fatalf("写入投递记录失败: %v", err)
+	}
+}
+
+func (env *webhookTestEnv) run(t *testing.T) {
+	t.Helper()
+	if _, err := env.dispatcher.Run(context.Background()); err != nil {
+		t.Fatalf("投递失败: %v", err)
+	}
+}
+
+// delivery 读取唯一的一条投递记录
+func (env *webhookTestEnv) delivery(t *testing.T) models.WebhookDelivery {
+	t.Helper()
+	var deliveries []models.WebhookDelivery
+	if err := env.db.Find(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("投递记录数 = %d，期望 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+// makeDue 把投递记录的下次投递时间提前到现在，模拟退避时间已过
+func (env *webhookTestEnv) makeDue(t *testing.T) {
+	t.Helper()
+	if err := env.db.Model(&models.WebhookDelivery{}).Where("1 = 1").Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verifyWebhookSignature 按接收方的方式校验签名头
+func verifyWebhookSignature(secret string, header string, body []byte) (int64, bool) {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return t, hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t, http.StatusNoContent)
+	_, secret := env.subscribe(t, receiver.server.URL)
+	env.enqueue(t)
+	env.run(t)
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("收到请求数 = %d，期望 1", len(requests))
+	}
+	req := requests[0]
+	timestamp, ok := verifyWebhookSignature(secret, req.header.Get(utils.WebhookSignatureHeader), req.body)
+	if !ok {
+		t.Fatalf("签名校验失败: %q", req.header.Get(utils.WebhookSignatureHeader))
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); d < -time.Minute || d > time.Minute {
+		t.Fatalf("签名时间戳偏差过大: %v", d)
+	}
+	if _, ok := verifyWebhookSignature("wrong-secret", req.header.Get(utils.WebhookSignatureHeader), req.body); ok {
+		t.Fatal("错误的密钥不应通过签名校验")
+	}
+
+	var event dto.WebhookEvent
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != models.WebhookUserCreated || req.header.Get("X-Goauth-Event") != event.Type || req.header.Get("X-Goauth-Event-Id") != event.ID {
+		t.Fatalf("事件 = %+v，请求头 = %v", event, req.header)
+	}
+
+	delivery := env.delivery(t)
+	if delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusNoContent || delivery.DeliveredAt == nil {
+		t.Fatalf("投递记录 = %+v", delivery)
+	}
+
+	// 已投递的记录不再投递
+	env.run(t)
+	if len(receiver.received()) != 1 {
+		t.Fatal("已投递的记录被重复投递")
+	}
+}
+
+func TestWebhookDeliveryBackoffAndDeadLetter(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError)
+	env.subscribe(t, receiver.server.URL)
+	env.enqueue(t)
+
+	// 每次失败后按指数退避安排下次投递，未到时间时不投递
+	for attempt, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		before := time.Now()
+		env.run(t)
+		delivery := env.delivery(t)
+		if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != attempt+1 || delivery.LastStatusCode != http.StatusInternalServerError || delivery.LastError == "" {
+			t.Fatalf("第 %d 次失败后投递记录 = %+v", attempt+1, delivery)
+		}
+		if delivery.NextAttemptAt.Before(before.Add(wait)) || delivery.NextAttemptAt.After(time.Now().Add(wait)) {
+			t.Fatalf("第 %d 次失败后下次投递时间 = %v，期望约 %v 后", attempt+1, delivery.NextAttemptAt, wait)
+		}
+
+		env.run(t)
+		if got := len(receiver.received()); got != attempt+1 {
+			t.Fatalf("退避期间收到请求数 = %d，期望 %d", got, attempt+1)
+		}
+		env.makeDue(t)
+	}
+
+	// 达到 MaxAttempts 后记为 dead，不再投递
+	env.run(t)
+	delivery := env.delivery(t)
+	if delivery.Status != models.WebhookDeliveryDead || delivery.Attempts != env.cfg.MaxAttempts {
+		t.Fatalf("重试次数用尽后投递记录 = %+v", delivery)
+	}
+	env.makeDue(t)
+	env.run(t)
+	if got := len(receiver.received()); got != env.cfg.MaxAttempts {
+		t.Fatalf("收到请求数 = %d，期望 %d", got, env.cfg.MaxAttempts)
+	}
+
+	// 管理员重新投递后从头计算重试次数
+	if err := env.service.RetryDelivery(context.Background(), delivery.ID); err != nil {
+		t.Fatalf("重新投递失败: %v", err)
+	}
+	receiver.mu.Lock()
+	receiver.statuses = []int{http.StatusOK}
+	receiver.mu.Unlock()
+	env.run(t)
+	if delivery := env.delivery(t); delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 1 {
+		t.Fatalf("重新投递后投递记录 = %+v", delivery)
+	}
+}
+
+func TestWebhookDeliveryRedirectNotFollowed(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t, http.StatusFound)
+	env.subscribe(t, receiver.server.URL)
+	env.enqueue(t)
+	env.run(t)
+
+	if got := len(receiver.received()); got != 1 {
+		t.Fatalf("收到请求数 = %d，重定向不应被跟随", got)
+	}
+	if delivery := env.delivery(t); delivery.Status != models.WebhookDeliveryPending || delivery.LastStatusCode != http.StatusFound {
+		t.Fatalf("投递记录 = %+v，3xx 应按失败处理", delivery)
+	}
+}
+
+func TestWebhookDeliveryDisabledSubscription(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	receiver := newWebhookReceiver(t, http.StatusOK)
+	id, _ := env.subscribe(t, receiver.server.URL)
+	env.enqueue(t)
+
+	disabled := false
+	if err := env.service.UpdateWebhook(context.Background(), id, &dto.UpdateWebhookRequest{Enabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	env.run(t)
+
+	if got := len(receiver.received()); got != 0 {
+		t.Fatalf("停用的订阅收到 %d 个请求", got)
+	}
+	if delivery := env.delivery(t); delivery.Status != models.WebhookDeliveryDead {
+		t.Fatalf("投递记录 = %+v，订阅停用后应记为 dead", delivery)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := &WebhookDispatcher{cfg: appconfig.WebhookConfig{BackoffBase: time.Minute, BackoffMax: 5 * time.Minute}}
+	for attempts, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 4: 5 * time.Minute, 10: 5 * time.Minute} {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v，期望 %v", attempts, got, want)
+		}
+	}
+}
+
+// testDeliveryHandler 记录发送与结束调用，按 statuses 依次返回状态码
+type testDeliveryHandler struct {
+	statuses []int
+	sent     int
+	finished []error
+}
+
+func (h *testDeliveryHandler) Send(_ context.Context, delivery *models.WebhookDelivery) (int, error) {
+	status := h.statuses[min(h.sent, len(h.statuses)-1)]
+	h.sent++
+	if status >= 300 {
+		return status, errors.New("接收方返回 " + strconv.Itoa(status))
+	}
+	return status, nil
+}
+
+func (h *testDeliveryHandler) Finish(_ context.Context, _ *models.WebhookDelivery, err error) {
+	h.finished = append(h.finished, err)
+}
+
+func TestWebhookDeliveryForClient(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	handler := &testDeliveryHandler{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	env.dispatcher.SetHandler(models.WebhookBackchannelLogout, handler)
+
+	if err := env.service.EnqueueForClient(context.Background(), "42", models.WebhookBackchannelLogout, map[string]any{"user_id": 7}); err != nil {
+		t.Fatalf("写入投递记录失败: %v", err)
+	}
+	delivery := env.delivery(t)
+	if delivery.SubscriptionID != 0 || delivery.ClientID != "42" {
+		t.Fatalf("投递记录 = %+v", delivery)
+	}
+
+	// 失败时与订阅一样退避重试，成功后结束
+	env.run(t)
+	if delivery := env.delivery(t); delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 || len(handler.finished) != 0 {
+		t.Fatalf("首次失败后投递记录 = %+v，结束调用 %v", delivery, handler.finished)
+	}
+	env.makeDue(t)
+	env.run(t)
+	if delivery := env.delivery(t); delivery.Status != models.WebhookDeliveryDelivered || len(handler.finished) != 1 || handler.finished[0] != nil {
+		t.Fatalf("重试成功后投递记录 = %+v，结束调用 %v", delivery, handler.finished)
+	}
+}
+
+func TestWebhookDeliveryForClientDeadLetter(t *testing.T) {
+	env := newWebhookTestEnv(t)
+	handler := &testDeliveryHandler{statuses: []int{http.StatusBadGateway}}
+	env.dispatcher.SetHandler(models.WebhookBackchannelLogout, handler)
+
+	if err := env.service.EnqueueForClient(context.Background(), "42", models.WebhookBackchannelLogout, map[string]any{"user_id": 7}); err != nil {
+		t.Fatal(err)
+	}
+	for range env.cfg.MaxAttempts {
+		env.run(t)
+		env.makeDue(t)
+	}
+	if delivery := env.delivery(t); delivery.Status != models.WebhookDeliveryDead || handler.sent != env.cfg.MaxAttempts {
+		t.Fatalf("投递记录 = %+v，发送 %d 次", delivery, handler.sent)
+	}
+	if len(handler.finished) != 1 || handler.finished[0] == nil {
+		t.Fatalf("重试次数用尽后结束调用 = %v，期望一次失败", handler.finished)
+	}
+}
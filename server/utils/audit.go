@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"goauth/models"
+)
+
+// AuditSink 审计事件的外部输出，事件写入数据库后再转发，用于接入 SIEM 或日志采集
+type AuditSink interface {
+	Emit(ctx context.Context, event *models.AuditEvent) error
+}
+
+// WriterAuditSink 把审计事件按行写成 JSON（JSON Lines），可写到标准输出或文件，由日志采集程序转发
+type WriterAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterAuditSink 创建写入 w 的审计输出
+func NewWriterAuditSink(w io.Writer) *WriterAuditSink {
+	return &WriterAuditSink{w: w}
+}
+
+// NewFileAuditSink 创建追加写入文件的审计输出，文件不存在时创建
+func NewFileAuditSink(path string) (*WriterAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志文件失败: %w", err)
+	}
+	return NewWriterAuditSink(file), nil
+}
+
+// Emit 写入一行事件，多个请求并发写入时整行写出，不会相互穿插
+func (s *WriterAuditSink) Emit(_ context.Context, event *models.AuditEvent) error {
+	line, err := json.Marshal(struct {
+		*models.AuditEvent
+		TenantID uint `json:"tenant_id"`
+	}{AuditEvent: event, TenantID: event.TenantID})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
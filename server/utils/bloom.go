@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// BloomFilter 布隆过滤器，用于大规模集合的近似成员判断
+// 判断为不存在时一定不存在，判断为存在时有 fpRate 左右的误判概率；
+// 元素须为均匀分布的摘要（至少 16 字节），直接取其中两段作为双重哈希的种子
+type BloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// NewBloomFilter 按预计元素数与可接受的误判率创建布隆过滤器
+func NewBloomFilter(n int, fpRate float64) *BloomFilter {
+	n = max(n, 1)
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	k = min(max(k, 1), 30)
+	return &BloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// Add 加入一个摘要
+func (f *BloomFilter) Add(digest []byte) {
+	h1, h2 := bloomSeeds(digest)
+	for i := uint64(0); i < f.k; i++ {
+		idx := (h1 + i*h2) % f.m
+		f.bits[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+// Test 判断摘要是否可能在集合中
+func (f *BloomFilter) Test(digest []byte) bool {
+	h1, h2 := bloomSeeds(digest)
+	for i := uint64(0); i < f.k; i++ {
+		idx := (h1 + i*h2) % f.m
+		if f.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func bloomSeeds(digest []byte) (uint64, uint64) {
+	if len(digest) < 16 {
+		digest = append(make([]byte, 16-len(digest)), digest...)
+	}
+	// h2 取奇数，保证与 m 互素的概率更高，各轮下标不至于过早重复
+	return binary.BigEndian.Uint64(digest[:8]), binary.BigEndian.Uint64(digest[8:16]) | 1
+}
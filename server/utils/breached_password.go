@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// breachedPasswords 内置的常见泄露密码，每行一个大写 SHA-1 摘要
+//
+//go:embed breached_passwords.txt
+var breachedPasswords []byte
+
+// breachedPasswordFPRate 泄露密码过滤器的误判率，误判只会让极少数正常密码被要求更换
+const breachedPasswordFPRate = 0.0001
+
+// BreachedPasswordChecker 离线泄露密码检查
+// 列表与 Have I Been Pwned 一样以 SHA-1 摘要表示，整体载入布隆过滤器，检查时不需要访问外部服务
+type BreachedPasswordChecker struct {
+	filter *BloomFilter
+}
+
+// NewBreachedPasswordChecker 载入内置列表与 path 指定的额外列表，path 为空时只使用内置列表
+func NewBreachedPasswordChecker(path string) (*BreachedPasswordChecker, error) {
+	// 按每行约 45 字节估算额外列表的条数，用于确定过滤器大小
+	n := bytes.Count(breachedPasswords, []byte("\n")) + 1
+	var file *os.File
+	if path != "" {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("打开泄露密码列表失败: %w", err)
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("读取泄露密码列表失败: %w", err)
+		}
+		n += int(info.Size()/45) + 1
+	}
+
+	checker := &BreachedPasswordChecker{filter: NewBloomFilter(n, breachedPasswordFPRate)}
+	if err := checker.load(bytes.NewReader(breachedPasswords)); err != nil {
+		return nil, fmt.Errorf("载入内置泄露密码列表失败: %w", err)
+	}
+	if file != nil {
+		if err := checker.load(file); err != nil {
+			return nil, fmt.Errorf("载入泄露密码列表失败: %w", err)
+		}
+	}
+	return checker, nil
+}
+
+// Breached 判断密码是否在泄露列表中
+func (c *BreachedPasswordChecker) Breached(password string) bool {
+	digest := sha1.Sum([]byte(password))
+	return c.filter.Test(digest[:])
+}
+
+// load 逐行读取摘要，忽略空行与 "#" 开头的注释，"摘要:次数" 格式只取摘要
+func (c *BreachedPasswordChecker) load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text, _, _ = strings.Cut(text, ":")
+		digest, err := hex.DecodeString(text)
+		if err != nil || len(digest) != sha1.Size {
+			return fmt.Errorf("第 %d 行不是有效的 SHA-1 摘要", line)
+		}
+		c.filter.Add(digest)
+	}
+	return scanner.Err()
+}
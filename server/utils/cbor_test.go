@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		data   []byte
+		want   any
+		remain []byte
+	}{
+		{"小整数", []byte{0x17}, int64(23), nil},
+		{"单字节整数", []byte{0x18, 0x64}, int64(100), nil},
+		{"负整数", []byte{0x26}, int64(-7), nil},
+		{"两字节负整数", []byte{0x39, 0x01, 0x00}, int64(-257), nil},
+		{"字节串", []byte{0x43, 0x01, 0x02, 0x03}, []byte{1, 2, 3}, nil},
+		{"文本串", []byte{0x63, 'f', 'm', 't'}, "fmt", nil},
+		{"数组", []byte{0x82, 0x01, 0xf5}, []any{int64(1), true}, nil},
+		{"映射", []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0xf6}, map[any]any{int64(1): int64(2), "a": nil}, nil},
+		{"标签", []byte{0xc0, 0x01}, int64(1), nil},
+		{"剩余字节", []byte{0x01, 0xff, 0xfe}, int64(1), []byte{0xff, 0xfe}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, remain, err := DecodeCBOR(tc.data)
+			if err != nil {
+				t.Fatalf("DecodeCBOR(%x) err = %v", tc.data, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("DecodeCBOR(%x) = %#v，期望 %#v", tc.data, got, tc.want)
+			}
+			if !bytes.Equal(remain, tc.remain) {
+				t.Fatalf("剩余字节 = %x，期望 %x", remain, tc.remain)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORRejectsMalformed(t *testing.T) {
+	nested := bytes.Repeat([]byte{0x81}, cborMaxDepth+2)
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"空数据", nil},
+		{"参数截断", []byte{0x19, 0x01}},
+		{"字节串长度超出数据", []byte{0x45, 0x01}},
+		{"超大长度", []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"不定长字节串", []byte{0x5f, 0x41, 0x00, 0xff}},
+		{"数组元素缺失", []byte{0x83, 0x01}},
+		{"映射键为字节串", []byte{0xa1, 0x41, 0x00, 0x01}},
+		{"嵌套过深", nested},
+		{"负整数溢出", []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := DecodeCBOR(tc.data); !errors.Is(err, errCBORMalformed) {
+				t.Fatalf("DecodeCBOR(%x) err = %v，期望 %v", tc.data, err, errCBORMalformed)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyRejectsInvalidKeys(t *testing.T) {
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}，x、y 均为 32 字节
+	ec2Key := func(x, y []byte) []byte {
+		key := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+		key = append(key, x...)
+		key = append(key, 0x22, 0x58, 0x20)
+		return append(key, y...)
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"点不在曲线上", ec2Key(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32))},
+		{"未知算法", []byte{0xa2, 0x01, 0x02, 0x03, 0x20}},
+		{"Ed25519 公钥长度错误", []byte{0xa4, 0x01, 0x01, 0x03, 0x27, 0x20, 0x06, 0x21, 0x41, 0x00}},
+		{"不是映射", []byte{0x80}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := ParseCOSEKey(tc.data); !errors.Is(err, ErrWebAuthnPublicKey) {
+				t.Fatalf("err = %v，期望 %v", err, ErrWebAuthnPublicKey)
+			}
+		})
+	}
+}
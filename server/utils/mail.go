@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MailMessage 待发送的邮件，Text 与 HTML 至少提供一个，同时提供时以 multipart/alternative 发送
+type MailMessage struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// MailSender 邮件发送接口
+type MailSender interface {
+	Send(ctx context.Context, msg *MailMessage) error
+}
+
+// SMTP 连接加密方式
+const (
+	SMTPTLSStartTLS = "starttls" // 明文连接后升级，服务器不支持时拒绝发送
+	SMTPTLSImplicit = "tls"      // 直接建立 TLS 连接，通常为 465 端口
+	SMTPTLSNone     = "none"     // 不加密，仅用于本地调试
+)
+
+// SMTPOptions SMTP 发信配置
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string // 发件人地址
+	FromName string // 发件人显示名称
+	TLS      string // 加密方式，见 SMTPTLS* 常量
+	Timeout  time.Duration
+}
+
+// SMTPMailSender 通过 SMTP 发送邮件，每封邮件单独建立连接
+type SMTPMailSender struct {
+	opts SMTPOptions
+}
+
+// NewSMTPMailSender 创建 SMTP 发信实例
+func NewSMTPMailSender(opts SMTPOptions) (*SMTPMailSender, error) {
+	if opts.Host == "" || opts.Port == 0 {
+		return nil, errors.New("SMTP 地址不能为空")
+	}
+	if _, err := mail.ParseAddress(opts.From); err != nil {
+		return nil, fmt.Errorf("发件人地址无效: %w", err)
+	}
+	switch opts.TLS {
+	case "":
+		opts.TLS = SMTPTLSStartTLS
+	case SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone:
+	default:
+		return nil, fmt.Errorf("不支持的 SMTP 加密方式: %s", opts.TLS)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &SMTPMailSender{opts: opts}, nil
+}
+
+// Send 发送邮件
+func (s *SMTPMailSender) Send(ctx context.Context, msg *MailMessage) error {
+	if len(msg.To) == 0 {
+		return errors.New("收件人不能为空")
+	}
+	data, err := buildMailMessage(s.opts.From, s.opts.FromName, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.opts.Host, strconv.Itoa(s.opts.Port))
+	tlsConfig := &tls.Config{ServerName: s.opts.Host, MinVersion: tls.VersionTLS12}
+	var conn net.Conn
+	if s.opts.TLS == SMTPTLSImplicit {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	defer client.Close()
+
+	if s.opts.TLS == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("SMTP STARTTLS 失败: %w", err)
+		}
+	}
+	if s.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.opts.From); err != nil {
+		return fmt.Errorf("SMTP 发件人被拒绝: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP 收件人被拒绝: %w", err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP 发送失败: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("SMTP 发送失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP 发送失败: %w", err)
+	}
+	return client.Quit()
+}
+
+// MemoryMailSender 只把邮件保存在内存中，用于测试与未配置 SMTP 的开发环境
+type MemoryMailSender struct {
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+// NewMemoryMailSender 创建内存发信实例
+func NewMemoryMailSender() *MemoryMailSender {
+	return &MemoryMailSender{}
+}
+
+// Send 保存邮件
+func (s *MemoryMailSender) Send(_ context.Context, msg *MailMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, *msg)
+	return nil
+}
+
+// Messages 返回已发送邮件的副本，按发送顺序排列
+func (s *MemoryMailSender) Messages() []MailMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MailMessage(nil), s.messages...)
+}
+
+// Reset 清空已发送的邮件
+func (s *MemoryMailSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// buildMailMessage 生成 RFC 5322 邮件原文，正文统一使用 UTF-8 与 base64 编码
+func buildMailMessage(from, fromName string, msg *MailMessage) ([]byte, error) {
+	for _, to := range msg.To {
+		if _, err := mail.ParseAddress(to); err != nil || strings.ContainsAny(to, "\r\n") {
+			return nil, fmt.Errorf("收件人地址无效: %s", to)
+		}
+	}
+	if msg.Text == "" && msg.HTML == "" {
+		return nil, errors.New("邮件正文不能为空")
+	}
+
+	var buf bytes.Buffer
+	sender := mail.Address{Name: fromName, Address: from}
+	fmt.Fprintf(&buf, "From: %s\r\n", sender.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		boundary, err := mailBoundary()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+		writeMailPart(&buf, boundary, "text/plain", msg.Text)
+		writeMailPart(&buf, boundary, "text/html", msg.HTML)
+		fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	case msg.HTML != "":
+		writeMailBody(&buf, "text/html", msg.HTML)
+	default:
+		writeMailBody(&buf, "text/plain", msg.Text)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMailPart(buf *bytes.Buffer, boundary, contentType, body string) {
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	writeMailBody(buf, contentType, body)
+}
+
+func writeMailBody(buf *bytes.Buffer, contentType, body string) {
+	fmt.Fprintf(buf, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	// base64 正文按 76 个字符折行
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
+
+func mailBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "goauth-" + hex.EncodeToString(b), nil
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"net/url"
 	"slices"
 	"strings"
 )
@@ -16,6 +17,15 @@ func IsRedirectURIValid(redirectURI string, registeredURIsJSON []byte) bool {
 	return slices.Contains(registeredURIs, redirectURI)
 }
 
+// IsLogoutURIValid 验证登出相关地址是否为不含片段的 http(s) 绝对地址
+func IsLogoutURIValid(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || strings.Contains(uri, "#") {
+		return false
+	}
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
 // isScopeValid 验证请求的 scope 是否都在允许的 scope 列表中
 func IsScopeValid(requestedScope string, allowedScopesJSON []byte) bool {
 	if requestedScope == "" {
@@ -54,3 +64,8 @@ func IsGrantTypeValid(grantType string, allowedGrantTypesJSON []byte) bool {
 
 	return slices.Contains(allowedGrantTypes, grantType)
 }
+
+// HasScope 检查以空格分隔的 scope 中是否包含指定范围
+func HasScope(scope, required string) bool {
+	return slices.Contains(strings.Fields(scope), required)
+}
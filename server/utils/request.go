@@ -0,0 +1,42 @@
+package utils
+
+import "context"
+
+// RequestInfo 请求来源，由全局中间件写入 context，供审计事件记录
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// Actor 发起请求的已认证主体，由鉴权中间件写入 context
+// 以用户身份访问时 UserID 非零，以客户端身份访问时只有 ClientID
+type Actor struct {
+	UserID   uint
+	ClientID string
+}
+
+type requestInfoContextKey struct{}
+
+type actorContextKey struct{}
+
+// WithRequestInfo 把请求来源写入 context
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey{}, info)
+}
+
+// RequestInfoFromContext 读取 context 中的请求来源，后台任务中为空值
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoContextKey{}).(RequestInfo)
+	return info
+}
+
+// WithActor 把已认证主体写入 context
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext 读取 context 中的已认证主体，未认证时返回 false
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorContextKey{}).(Actor)
+	return actor, ok
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// SigningKeyPair 用于 XML 签名的证书与私钥
+type SigningKeyPair struct {
+	Certificate *x509.Certificate
+	Signer      crypto.Signer
+}
+
+// LoadSigningKeyPair 从 PEM 文件载入证书与私钥，私钥须与证书匹配，只支持 RSA（不少于 2048 位）与 ECDSA
+func LoadSigningKeyPair(certFile, keyFile string) (*SigningKeyPair, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("载入签名证书失败: %w", err)
+	}
+	return newSigningKeyPair(pair)
+}
+
+// ParseSigningKeyPair 从 PEM 数据解析证书与私钥，要求同 LoadSigningKeyPair
+func ParseSigningKeyPair(certPEM, keyPEM []byte) (*SigningKeyPair, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名证书失败: %w", err)
+	}
+	return newSigningKeyPair(pair)
+}
+
+// GenerateSigningKeyPair 生成 ECDSA P-256 私钥与自签名证书，commonName 写入证书主题
+func GenerateSigningKeyPair(commonName string, validity time.Duration) (*SigningKeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成签名私钥失败: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("生成证书序列号失败: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("生成签名证书失败: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名证书失败: %w", err)
+	}
+	return &SigningKeyPair{Certificate: cert, Signer: key}, nil
+}
+
+// MarshalPEM 把证书与私钥编码为 PEM，私钥为 PKCS#8 格式
+func (p *SigningKeyPair) MarshalPEM() (certPEM, keyPEM []byte, err error) {
+	der, err := x509.MarshalPKCS8PrivateKey(p.Signer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("编码签名私钥失败: %w", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.Certificate.Raw})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return certPEM, keyPEM, nil
+}
+
+func newSigningKeyPair(pair tls.Certificate) (*SigningKeyPair, error) {
+	var err error
+	cert := pair.Leaf
+	if cert == nil {
+		if cert, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("解析签名证书失败: %w", err)
+		}
+	}
+
+	switch key := pair.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA 签名私钥不能少于 2048 位")
+		}
+		return &SigningKeyPair{Certificate: cert, Signer: key}, nil
+	case *ecdsa.PrivateKey:
+		return &SigningKeyPair{Certificate: cert, Signer: key}, nil
+	default:
+		return nil, fmt.Errorf("不支持的签名私钥类型: %T", pair.PrivateKey)
+	}
+}
+
+// Expired 证书是否已过期，服务提供方通常会拒绝过期证书签名的消息
+func (p *SigningKeyPair) Expired() bool {
+	return time.Now().After(p.Certificate.NotAfter)
+}
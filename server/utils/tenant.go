@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"goauth/models"
+)
+
+// tenantContextKey 请求所属租户在 context 中的键
+type tenantContextKey struct{}
+
+// WithTenant 把租户写入 context，由租户中间件在请求开始时调用，仓库层据此限定查询范围
+func WithTenant(ctx context.Context, tenant *models.Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext 读取 context 中的租户，未设置时返回 nil
+func TenantFromContext(ctx context.Context) *models.Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(*models.Tenant)
+	return tenant
+}
+
+// TenantID 读取 context 中的租户ID，未设置时（启动任务、后台任务）视为默认租户
+func TenantID(ctx context.Context) uint {
+	if tenant := TenantFromContext(ctx); tenant != nil {
+		return tenant.ID
+	}
+	return models.DefaultTenantID
+}
+
+// TenantCacheKey 为缓存键加上租户前缀，不同租户的同名条件不会命中同一条缓存
+func TenantCacheKey(ctx context.Context, prefix string) string {
+	return fmt.Sprintf("tenant:%d:%s", TenantID(ctx), prefix)
+}
+
+// RealmPath 租户在地址中的路径前缀，默认租户没有前缀
+func RealmPath(ctx context.Context) string {
+	tenant := TenantFromContext(ctx)
+	if tenant == nil || tenant.ID == models.DefaultTenantID {
+		return ""
+	}
+	return "/realms/" + tenant.Name
+}
+
+// RealmURL 在服务地址后拼接租户路径前缀与 path，生成当前租户下的绝对地址
+func RealmURL(ctx context.Context, baseURL, path string) string {
+	return strings.TrimRight(baseURL, "/") + RealmPath(ctx) + path
+}
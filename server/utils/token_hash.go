@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// TokenHasher 计算令牌的带密钥摘要
+// 数据库只保存摘要，即使数据泄露也无法直接拿到可用的令牌
+type TokenHasher struct {
+	key []byte
+}
+
+// NewTokenHasher 创建令牌摘要计算器
+func NewTokenHasher(secret string) (*TokenHasher, error) {
+	if secret == "" {
+		return nil, errors.New("令牌摘要密钥不能为空")
+	}
+	return &TokenHasher{key: []byte(secret)}, nil
+}
+
+// Hash 返回令牌的 HMAC-SHA256 十六进制摘要（64 个字符）
+func (h *TokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/3086953492/gokit/jwt"
+)
+
+// IssuedAtMsClaim 令牌扩展字段中毫秒级签发时间的键名
+// jwt 库默认把 iat 截断到秒，与毫秒级的用户令牌纪元比较时会把同一秒内撤销前后签发的令牌混为一谈，
+// 因此签发时另行写入毫秒时间，不修改 jwt 库的全局时间精度
+const IssuedAtMsClaim = "iat_ms"
+
+// TokenIssuedAt 返回令牌的签发时间，优先使用毫秒级签发时间，缺失时退回秒级 iat
+func TokenIssuedAt(claims *jwt.Claims) (time.Time, bool) {
+	// JSON 数字解码为 float64，毫秒时间戳在 float64 精度范围内
+	if ms, ok := claims.Extra[IssuedAtMsClaim].(float64); ok && ms > 0 {
+		return time.UnixMilli(int64(ms)), true
+	}
+	if claims.IssuedAt == nil {
+		return time.Time{}, false
+	}
+	return claims.IssuedAt.Time, true
+}
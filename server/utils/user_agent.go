@@ -0,0 +1,43 @@
+package utils
+
+import "strings"
+
+// userAgentRule 按顺序匹配 User-Agent 中的关键字，先匹配到的生效
+type userAgentRule struct {
+	keyword string
+	name    string
+}
+
+var (
+	userAgentOSRules = []userAgentRule{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+	// Edge、Opera 的 UA 同时包含 Chrome，须排在 Chrome 之前；Chrome 的 UA 同时包含 Safari
+	userAgentBrowserRules = []userAgentRule{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+)
+
+// DeviceFromUserAgent 从 User-Agent 粗略识别设备，格式为 "操作系统 / 浏览器"，无法识别的部分为 "未知"
+func DeviceFromUserAgent(userAgent string) string {
+	return matchUserAgent(userAgent, userAgentOSRules) + " / " + matchUserAgent(userAgent, userAgentBrowserRules)
+}
+
+func matchUserAgent(userAgent string, rules []userAgentRule) string {
+	for _, rule := range rules {
+		if strings.Contains(userAgent, rule.keyword) {
+			return rule.name
+		}
+	}
+	return "未知"
+}
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+)
+
+// WebAuthn 验证实现参考 W3C Web Authentication Level 2 第 7 章
+// 只校验 RP 关心的部分：clientData、认证器数据、COSE 公钥与签名；
+// 不校验认证器证明（attestation statement），注册时统一请求 attestation=none
+
+// COSE 算法标识
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// 认证器数据标志位
+const (
+	webauthnFlagUserPresent  = 0x01
+	webauthnFlagUserVerified = 0x04
+	webauthnFlagAttested     = 0x40
+)
+
+// clientData 类型
+const (
+	WebAuthnTypeCreate = "webauthn.create"
+	WebAuthnTypeGet    = "webauthn.get"
+)
+
+var (
+	ErrWebAuthnClientData   = errors.New("clientDataJSON 校验失败")
+	ErrWebAuthnAuthData     = errors.New("认证器数据格式错误")
+	ErrWebAuthnRPID         = errors.New("RP ID 不匹配")
+	ErrWebAuthnUserPresence = errors.New("认证器未确认用户在场")
+	ErrWebAuthnUserVerify   = errors.New("认证器未完成用户验证")
+	ErrWebAuthnPublicKey    = errors.New("不支持的公钥格式")
+	ErrWebAuthnSignature    = errors.New("签名校验失败")
+	ErrWebAuthnAttestation  = errors.New("attestationObject 格式错误")
+	ErrWebAuthnCredentialID = errors.New("凭证ID与认证器数据不一致")
+)
+
+// WebAuthnEncoding WebAuthn JSON 中二进制字段统一使用无填充的 base64url
+var WebAuthnEncoding = base64.RawURLEncoding
+
+// GenerateWebAuthnChallenge 生成 32 字节随机挑战，返回 base64url 编码
+func GenerateWebAuthnChallenge() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return WebAuthnEncoding.EncodeToString(buf), nil
+}
+
+// WebAuthnClientData 浏览器生成的 clientDataJSON
+type WebAuthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ParseWebAuthnClientData 解析 clientDataJSON 并校验类型与来源，挑战由调用方按返回值查找校验
+func ParseWebAuthnClientData(raw []byte, typ string, origins []string) (*WebAuthnClientData, error) {
+	var clientData WebAuthnClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, ErrWebAuthnClientData
+	}
+	if clientData.Type != typ || clientData.Challenge == "" || clientData.CrossOrigin {
+		return nil, ErrWebAuthnClientData
+	}
+	if !slices.Contains(origins, clientData.Origin) {
+		return nil, ErrWebAuthnClientData
+	}
+	return &clientData, nil
+}
+
+// WebAuthnAuthenticatorData 认证器数据
+type WebAuthnAuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte // 以下字段仅注册时存在
+	CredentialID []byte
+	PublicKey    []byte // COSE 编码的公钥
+}
+
+// UserPresent 是否确认用户在场
+func (d *WebAuthnAuthenticatorData) UserPresent() bool {
+	return d.Flags&webauthnFlagUserPresent != 0
+}
+
+// UserVerified 是否完成用户验证（PIN、生物识别等）
+func (d *WebAuthnAuthenticatorData) UserVerified() bool {
+	return d.Flags&webauthnFlagUserVerified != 0
+}
+
+// Verify 校验 RP ID 摘要与用户在场、用户验证标志
+func (d *WebAuthnAuthenticatorData) Verify(rpID string, requireUserVerification bool) error {
+	expected := sha256.Sum256([]byte(rpID))
+	if subtle.ConstantTimeCompare(d.RPIDHash, expected[:]) != 1 {
+		return ErrWebAuthnRPID
+	}
+	if !d.UserPresent() {
+		return ErrWebAuthnUserPresence
+	}
+	if requireUserVerification && !d.UserVerified() {
+		return ErrWebAuthnUserVerify
+	}
+	return nil
+}
+
+// ParseWebAuthnAuthenticatorData 解析认证器数据
+func ParseWebAuthnAuthenticatorData(data []byte) (*WebAuthnAuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrWebAuthnAuthData
+	}
+	authData := &WebAuthnAuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.Flags&webauthnFlagAttested == 0 {
+		return authData, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, ErrWebAuthnAuthData
+	}
+	authData.AAGUID = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, ErrWebAuthnAuthData
+	}
+	authData.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	// 公钥之后可能紧跟扩展数据，按 CBOR 数据项边界截取
+	_, remain, err := DecodeCBOR(rest)
+	if err != nil {
+		return nil, ErrWebAuthnAuthData
+	}
+	authData.PublicKey = rest[:len(rest)-len(remain)]
+	return authData, nil
+}
+
+// ParseWebAuthnAttestationObject 解析 attestationObject，返回其中的认证器数据原文
+func ParseWebAuthnAttestationObject(raw []byte) ([]byte, error) {
+	item, _, err := DecodeCBOR(raw)
+	if err != nil {
+		return nil, ErrWebAuthnAttestation
+	}
+	object, ok := item.(map[any]any)
+	if !ok {
+		return nil, ErrWebAuthnAttestation
+	}
+	authData, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, ErrWebAuthnAttestation
+	}
+	return authData, nil
+}
+
+// ParseCOSEKey 解析 COSE 公钥，支持 ES256、EdDSA 与 RS256，返回公钥与算法标识
+func ParseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	item, _, err := DecodeCBOR(raw)
+	if err != nil {
+		return nil, 0, ErrWebAuthnPublicKey
+	}
+	key, ok := item.(map[any]any)
+	if !ok {
+		return nil, 0, ErrWebAuthnPublicKey
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrWebAuthnPublicKey
+		}
+		// 借助 ecdh 校验点是否在曲线上
+		point := append(append([]byte{0x04}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, ErrWebAuthnPublicKey
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, alg, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrWebAuthnPublicKey
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrWebAuthnPublicKey
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		if exponent < 3 {
+			return nil, 0, ErrWebAuthnPublicKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, alg, nil
+	}
+	return nil, 0, ErrWebAuthnPublicKey
+}
+
+// VerifyWebAuthnSignature 校验断言签名，签名内容为 authenticatorData || SHA-256(clientDataJSON)
+func VerifyWebAuthnSignature(coseKey, authData, clientDataJSON, signature []byte) error {
+	publicKey, alg, err := ParseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	switch alg {
+	case COSEAlgES256:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature) {
+			return ErrWebAuthnSignature
+		}
+	case COSEAlgEdDSA:
+		if !ed25519.Verify(publicKey.(ed25519.PublicKey), signed, signature) {
+			return ErrWebAuthnSignature
+		}
+	case COSEAlgRS256:
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) != nil {
+			return ErrWebAuthnSignature
+		}
+	default:
+		return ErrWebAuthnPublicKey
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// WebhookSignatureHeader Webhook 签名所在的请求头
+const WebhookSignatureHeader = "X-Goauth-Signature"
+
+// SignWebhook 计算 Webhook 请求的签名头，格式为 t=<unix 秒>,v1=<hex>
+// v1 为 HMAC-SHA256(secret, "<t>.<body>")；接收方按同样方式计算并以常量时间比较，
+// 同时检查 t 与当前时间的差值以拒绝重放
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
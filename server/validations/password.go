@@ -0,0 +1,54 @@
+package validations
+
+import (
+	"reflect"
+
+	"github.com/3086953492/gokit/validator"
+
+	"goauth/services"
+)
+
+// PasswordValidators 密码验证器实现
+type PasswordValidators struct {
+	passwordPolicy *services.PasswordPolicy
+}
+
+// NewPasswordValidators 创建密码验证器实例
+func NewPasswordValidators(passwordPolicy *services.PasswordPolicy) *PasswordValidators {
+	return &PasswordValidators{passwordPolicy: passwordPolicy}
+}
+
+// PasswordPolicy 密码策略验证 -> password_policy
+// 同一表单中的 Username、Nickname 字段一并作为不能出现在密码中的用户信息
+func (v *PasswordValidators) PasswordPolicy(fl validator.FieldLevel) bool {
+	pwd := fl.Field().String()
+	if pwd == "" {
+		return true
+	}
+	return v.passwordPolicy.Validate(pwd, siblingString(fl, "Username"), siblingString(fl, "Nickname")) == nil
+}
+
+// PasswordNotBreached 泄露密码验证 -> password_not_breached
+func (v *PasswordValidators) PasswordNotBreached(fl validator.FieldLevel) bool {
+	pwd := fl.Field().String()
+	if pwd == "" {
+		return true
+	}
+	return !v.passwordPolicy.Breached(pwd)
+}
+
+// siblingString 取同一结构体中的字符串字段，不存在时返回空字符串
+func siblingString(fl validator.FieldLevel, name string) string {
+	parent := fl.Parent()
+	if parent.Kind() == reflect.Pointer {
+		parent = parent.Elem()
+	}
+	if parent.Kind() != reflect.Struct {
+		return ""
+	}
+	field := parent.FieldByName(name)
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return ""
+	}
+	return field.String()
+}
@@ -0,0 +1,49 @@
+import request from './request'
+import type { ForgotPasswordRequest, ResetPasswordRequest, VerifyEmailRequest, ResendActivationRequest } from '@/types/account'
+import type { ApiResponse } from '@/types/common'
+
+/**
+ * 申请重置密码，向已验证的邮箱发送重置链接
+ * 无论邮箱是否已注册都返回成功
+ */
+export const forgotPassword = (data: ForgotPasswordRequest): Promise<ApiResponse> => {
+  return request({
+    url: '/api/v1/auth/password/forgot',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 通过邮件中的链接重置密码
+ */
+export const resetPassword = (data: ResetPasswordRequest): Promise<ApiResponse> => {
+  return request({
+    url: '/api/v1/auth/password/reset',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 通过邮件中的链接完成邮箱验证
+ */
+export const verifyEmail = (data: VerifyEmailRequest): Promise<ApiResponse> => {
+  return request({
+    url: '/api/v1/auth/email/verify',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 重新发送激活邮件
+ * 无论邮箱对应的账号是否待激活都返回成功
+ */
+export const resendActivation = (data: ResendActivationRequest): Promise<ApiResponse> => {
+  return request({
+    url: '/api/v1/auth/activation/resend',
+    method: 'post',
+    data
+  })
+}
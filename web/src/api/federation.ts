@@ -0,0 +1,67 @@
+import request from './request'
+import type { LoginResponse } from '@/types/auth'
+import type { FederatedAuthorizeResponse, FederatedIdentity, FederatedProvider } from '@/types/federation'
+import type { ApiResponse } from '@/types/common'
+
+/**
+ * 获取可用的外部身份提供方
+ */
+export const getFederatedProviders = (): Promise<ApiResponse<FederatedProvider[]>> => {
+  return request({
+    url: '/api/v1/auth/federated/providers',
+    method: 'get'
+  })
+}
+
+/**
+ * 发起外部身份登录，返回身份提供方的授权地址
+ * @param redirect 登录完成后返回的站内路径
+ */
+export const authorizeFederated = (provider: string, redirect?: string): Promise<ApiResponse<FederatedAuthorizeResponse>> => {
+  return request({
+    url: `/api/v1/auth/federated/${provider}/authorize`,
+    method: 'post',
+    data: { redirect }
+  })
+}
+
+/**
+ * 使用回调返回的一次性凭据完成登录（已启用两步验证时返回挑战）
+ */
+export const federatedLogin = (ticket: string): Promise<ApiResponse<LoginResponse>> => {
+  return request({
+    url: '/api/v1/auth/federated/login',
+    method: 'post',
+    data: { ticket }
+  })
+}
+
+/**
+ * 获取当前用户已关联的外部账号
+ */
+export const getFederatedIdentities = (): Promise<ApiResponse<FederatedIdentity[]>> => {
+  return request({
+    url: '/api/v1/federated-identities',
+    method: 'get'
+  })
+}
+
+/**
+ * 发起外部账号关联，返回身份提供方的授权地址
+ */
+export const linkFederatedIdentity = (provider: string): Promise<ApiResponse<FederatedAuthorizeResponse>> => {
+  return request({
+    url: `/api/v1/federated-identities/${provider}/link`,
+    method: 'post'
+  })
+}
+
+/**
+ * 解除外部账号关联
+ */
+export const unlinkFederatedIdentity = (id: number): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/federated-identities/${id}`,
+    method: 'delete'
+  })
+}
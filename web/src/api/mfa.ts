@@ -0,0 +1,107 @@
+import request from './request'
+import type { LoginResponse } from '@/types/auth'
+import type { MFAStatus, RecoveryCodesResponse, TOTPSetup } from '@/types/mfa'
+import type { ApiResponse } from '@/types/common'
+
+/**
+ * 登录第二步：提交验证码或恢复码
+ */
+export const verifyLoginMFA = (data: {
+  mfa_token: string
+  code?: string
+  recovery_code?: string
+}): Promise<ApiResponse<LoginResponse>> => {
+  return request({
+    url: '/api/v1/auth/mfa/verify',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 登录时发起验证器绑定（账号被要求启用两步验证但尚未绑定）
+ */
+export const setupLoginMFAEnrollment = (mfaToken: string): Promise<ApiResponse<TOTPSetup>> => {
+  return request({
+    url: '/api/v1/auth/mfa/enroll',
+    method: 'post',
+    data: { mfa_token: mfaToken }
+  })
+}
+
+/**
+ * 登录时确认验证器绑定，成功后直接完成登录
+ */
+export const confirmLoginMFAEnrollment = (data: {
+  mfa_token: string
+  code: string
+}): Promise<ApiResponse<LoginResponse>> => {
+  return request({
+    url: '/api/v1/auth/mfa/enroll/confirm',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 获取当前用户的两步验证状态
+ */
+export const getMFAStatus = (): Promise<ApiResponse<MFAStatus>> => {
+  return request({
+    url: '/api/v1/mfa',
+    method: 'get'
+  })
+}
+
+/**
+ * 发起验证器绑定
+ */
+export const setupTOTP = (): Promise<ApiResponse<TOTPSetup>> => {
+  return request({
+    url: '/api/v1/mfa/totp',
+    method: 'post'
+  })
+}
+
+/**
+ * 确认验证器绑定，返回首批恢复码
+ */
+export const confirmTOTP = (code: string): Promise<ApiResponse<RecoveryCodesResponse>> => {
+  return request({
+    url: '/api/v1/mfa/totp/confirm',
+    method: 'post',
+    data: { code }
+  })
+}
+
+/**
+ * 关闭两步验证（需要当前密码）
+ */
+export const disableTOTP = (password: string): Promise<ApiResponse> => {
+  return request({
+    url: '/api/v1/mfa/totp',
+    method: 'delete',
+    data: { password }
+  })
+}
+
+/**
+ * 重新生成恢复码（需要验证码），旧恢复码全部作废
+ */
+export const regenerateRecoveryCodes = (code: string): Promise<ApiResponse<RecoveryCodesResponse>> => {
+  return request({
+    url: '/api/v1/mfa/recovery_codes',
+    method: 'post',
+    data: { code }
+  })
+}
+
+/**
+ * 管理员重置用户的两步验证
+ */
+export const resetUserMFA = (userId: string | number): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/users/${userId}/mfa`,
+    method: 'delete'
+  })
+}
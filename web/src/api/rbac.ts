@@ -0,0 +1,143 @@
+import request from './request'
+import type {
+  Permission,
+  Role,
+  CreateRoleRequest,
+  UpdateRoleRequest,
+  UserRoles,
+  GroupListResponse,
+  GroupDetailResponse,
+  CreateGroupRequest,
+  UpdateGroupRequest
+} from '@/types/rbac'
+import type { ApiResponse, PaginationResponse } from '@/types/common'
+
+/**
+ * 获取系统定义的全部权限
+ */
+export const listPermissions = (): Promise<ApiResponse<Permission[]>> => {
+  return request({
+    url: '/api/v1/permissions',
+    method: 'get'
+  })
+}
+
+/**
+ * 获取角色列表
+ */
+export const listRoles = (): Promise<ApiResponse<Role[]>> => {
+  return request({
+    url: '/api/v1/roles',
+    method: 'get'
+  })
+}
+
+/**
+ * 创建角色
+ */
+export const createRole = (data: CreateRoleRequest): Promise<ApiResponse<null>> => {
+  return request({
+    url: '/api/v1/roles',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 更新角色，未提交的字段保持不变
+ */
+export const updateRole = (id: number, data: UpdateRoleRequest): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/roles/${id}`,
+    method: 'patch',
+    data
+  })
+}
+
+/**
+ * 删除角色，已分配该角色的用户与用户组随之失去该角色
+ */
+export const deleteRole = (id: number): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/roles/${id}`,
+    method: 'delete'
+  })
+}
+
+/**
+ * 获取用户的角色与权限
+ */
+export const getUserRoles = (userId: string | number): Promise<ApiResponse<UserRoles>> => {
+  return request({
+    url: `/api/v1/users/${userId}/roles`,
+    method: 'get'
+  })
+}
+
+/**
+ * 整体替换直接分配给用户的角色
+ */
+export const setUserRoles = (userId: string | number, roles: string[]): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/users/${userId}/roles`,
+    method: 'put',
+    data: { roles }
+  })
+}
+
+/**
+ * 获取用户组列表
+ */
+export const listGroups = (params?: {
+  page?: number
+  page_size?: number
+  display_name?: string
+}): Promise<ApiResponse<PaginationResponse<GroupListResponse>>> => {
+  return request({
+    url: '/api/v1/groups',
+    method: 'get',
+    params
+  })
+}
+
+/**
+ * 获取用户组详情
+ */
+export const getGroup = (id: number): Promise<ApiResponse<GroupDetailResponse>> => {
+  return request({
+    url: `/api/v1/groups/${id}`,
+    method: 'get'
+  })
+}
+
+/**
+ * 创建用户组
+ */
+export const createGroup = (data: CreateGroupRequest): Promise<ApiResponse<null>> => {
+  return request({
+    url: '/api/v1/groups',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 更新用户组，未提交的成员与角色保持不变
+ */
+export const updateGroup = (id: number, data: UpdateGroupRequest): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/groups/${id}`,
+    method: 'patch',
+    data
+  })
+}
+
+/**
+ * 删除用户组
+ */
+export const deleteGroup = (id: number): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/groups/${id}`,
+    method: 'delete'
+  })
+}
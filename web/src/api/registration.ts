@@ -0,0 +1,38 @@
+import request from './request'
+import type { PendingUser } from '@/types/user'
+import type { ApiResponse, PaginationResponse } from '@/types/common'
+
+/**
+ * 获取待审核的注册申请（管理员）
+ */
+export const listPendingRegistrations = (params?: {
+  page?: number
+  page_size?: number
+}): Promise<ApiResponse<PaginationResponse<PendingUser>>> => {
+  return request({
+    url: '/api/v1/registrations',
+    method: 'get',
+    params
+  })
+}
+
+/**
+ * 批准注册申请（管理员）
+ */
+export const approveRegistration = (userId: string | number): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/registrations/${userId}/approve`,
+    method: 'post'
+  })
+}
+
+/**
+ * 拒绝注册申请（管理员），原因会写入通知邮件
+ */
+export const rejectRegistration = (userId: string | number, reason?: string): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/registrations/${userId}/reject`,
+    method: 'post',
+    data: { reason: reason || '' }
+  })
+}
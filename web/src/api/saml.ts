@@ -0,0 +1,93 @@
+import request from './request'
+import { API_BASE_URL } from '@/constants'
+import type {
+  SAMLServiceProviderListResponse,
+  CreateSAMLServiceProviderRequest,
+  UpdateSAMLServiceProviderRequest,
+  SAMLServiceProviderDetailResponse
+} from '@/types/saml'
+import type { ApiResponse, PaginationResponse } from '@/types/common'
+
+/**
+ * 获取 SAML 服务提供方列表
+ */
+export const listSAMLServiceProviders = (params?: {
+  page?: number
+  page_size?: number
+  name?: string
+  status?: number | string
+}): Promise<ApiResponse<PaginationResponse<SAMLServiceProviderListResponse>>> => {
+  return request({
+    url: '/api/v1/saml/service-providers',
+    method: 'get',
+    params
+  })
+}
+
+/**
+ * 创建 SAML 服务提供方
+ */
+export const createSAMLServiceProvider = (data: CreateSAMLServiceProviderRequest): Promise<ApiResponse<null>> => {
+  return request({
+    url: '/api/v1/saml/service-providers',
+    method: 'post',
+    data
+  })
+}
+
+/**
+ * 获取 SAML 服务提供方详情
+ */
+export const getSAMLServiceProvider = (id: number): Promise<ApiResponse<SAMLServiceProviderDetailResponse>> => {
+  return request({
+    url: `/api/v1/saml/service-providers/${id}`,
+    method: 'get'
+  })
+}
+
+/**
+ * 更新 SAML 服务提供方
+ */
+export const updateSAMLServiceProvider = (id: number, data: UpdateSAMLServiceProviderRequest): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/saml/service-providers/${id}`,
+    method: 'patch',
+    data
+  })
+}
+
+/**
+ * 删除 SAML 服务提供方
+ */
+export const deleteSAMLServiceProvider = (id: number): Promise<ApiResponse<null>> => {
+  return request({
+    url: `/api/v1/saml/service-providers/${id}`,
+    method: 'delete'
+  })
+}
+
+/**
+ * 身份提供方元数据地址，供服务提供方配置
+ */
+export const SAML_METADATA_URL = `${API_BASE_URL}/api/v1/saml/metadata`
+
+/**
+ * 构建继续处理服务提供方认证请求的地址
+ * 浏览器直接跳转到该地址，后端返回自动提交到服务提供方的表单
+ */
+export const buildSAMLContinueUrl = (requestToken: string): string => {
+  const url = new URL(`${API_BASE_URL}/api/v1/saml/sso/continue`, window.location.origin)
+  url.searchParams.set('request', requestToken)
+  return url.toString()
+}
+
+/**
+ * 构建从本系统直接登录到服务提供方的地址
+ */
+export const buildSAMLIDPInitiatedUrl = (id: number | string, relayState?: string): string => {
+  const url = new URL(`${API_BASE_URL}/api/v1/saml/idp-initiated/${id}`, window.location.origin)
+  if (relayState) {
+    url.searchParams.set('relay_state', relayState)
+  }
+  return url.toString()
+}
@@ -0,0 +1,33 @@
+import request from './request'
+import type { Session } from '@/types/session'
+import type { ApiResponse } from '@/types/common'
+
+/**
+ * 获取当前用户的登录会话列表
+ */
+export const listSessions = (): Promise<ApiResponse<Session[]>> => {
+  return request({
+    url: '/api/v1/sessions',
+    method: 'get'
+  })
+}
+
+/**
+ * 注销指定会话
+ */
+export const revokeSession = (sessionId: string): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/sessions/${sessionId}`,
+    method: 'delete'
+  })
+}
+
+/**
+ * 注销全部会话（包括当前会话）
+ */
+export const revokeAllSessions = (): Promise<ApiResponse> => {
+  return request({
+    url: '/api/v1/sessions',
+    method: 'delete'
+  })
+}
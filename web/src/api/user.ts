@@ -1,5 +1,5 @@
 import request from './request'
-import type { User, RegisterFormValues, UpdateUserFormValues, UserListResponse } from '@/types/user'
+import type { User, RegisterFormValues, UpdateUserFormValues, UserListResponse, LoginLockout } from '@/types/user'
 import type { ApiResponse, PaginationResponse } from '@/types/common'
 
 /**
@@ -12,6 +12,9 @@ export const register = (values: RegisterFormValues): Promise<ApiResponse> => {
   formData.append('password', values.password)
   formData.append('confirm_password', values.confirmPassword)
   formData.append('nickname', values.nickname)
+  if (values.email) {
+    formData.append('email', values.email)
+  }
   if (values.avatar) {
     formData.append('avatar', values.avatar)
   }
@@ -44,6 +47,9 @@ export const updateUser = (userId: string | number, values: UpdateUserFormValues
   if (values.nickname !== undefined) {
     formData.append('nickname', values.nickname)
   }
+  if (values.email) {
+    formData.append('email', values.email)
+  }
   if (values.password) {
     formData.append('password', values.password)
     formData.append('confirm_password', values.confirmPassword ?? '')
@@ -51,9 +57,6 @@ export const updateUser = (userId: string | number, values: UpdateUserFormValues
   if (values.status !== undefined) {
     formData.append('status', String(values.status))
   }
-  if (values.role) {
-    formData.append('role', values.role)
-  }
   if (values.avatar) {
     formData.append('avatar', values.avatar)
   }
@@ -92,3 +95,33 @@ export const deleteUser = (userId: string | number): Promise<ApiResponse> => {
   })
 }
 
+
+/**
+ * 获取用户的登录锁定状态（管理员）
+ */
+export const getUserLockout = (userId: string | number): Promise<ApiResponse<LoginLockout>> => {
+  return request({
+    url: `/api/v1/users/${userId}/lockout`,
+    method: 'get'
+  })
+}
+
+/**
+ * 解除用户的登录锁定（管理员）
+ */
+export const unlockUser = (userId: string | number): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/users/${userId}/lockout`,
+    method: 'delete'
+  })
+}
+
+/**
+ * 重新发送邮箱验证邮件
+ */
+export const sendEmailVerification = (userId: string | number): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/users/${userId}/email/verification`,
+    method: 'post'
+  })
+}
@@ -0,0 +1,99 @@
+import request from './request'
+import type { LoginResponse } from '@/types/auth'
+import type { Passkey, PasskeyCreationOptions, PasskeyCredentialJSON, PasskeyRequestOptions } from '@/types/webauthn'
+import type { ApiResponse } from '@/types/common'
+
+/**
+ * 发起通行密钥无密码登录
+ */
+export const beginPasskeyLogin = (): Promise<ApiResponse<PasskeyRequestOptions>> => {
+  return request({
+    url: '/api/v1/auth/passkey/options',
+    method: 'post'
+  })
+}
+
+/**
+ * 提交通行密钥断言完成登录（管理员未绑定验证器时返回两步验证挑战）
+ */
+export const finishPasskeyLogin = (credential: PasskeyCredentialJSON): Promise<ApiResponse<LoginResponse>> => {
+  return request({
+    url: '/api/v1/auth/passkey/login',
+    method: 'post',
+    data: { credential }
+  })
+}
+
+/**
+ * 两步验证阶段发起通行密钥认证
+ */
+export const beginMFAPasskey = (mfaToken: string): Promise<ApiResponse<PasskeyRequestOptions>> => {
+  return request({
+    url: '/api/v1/auth/mfa/webauthn/options',
+    method: 'post',
+    data: { mfa_token: mfaToken }
+  })
+}
+
+/**
+ * 两步验证阶段提交通行密钥断言
+ */
+export const verifyMFAPasskey = (mfaToken: string, credential: PasskeyCredentialJSON): Promise<ApiResponse<LoginResponse>> => {
+  return request({
+    url: '/api/v1/auth/mfa/webauthn/verify',
+    method: 'post',
+    data: { mfa_token: mfaToken, credential }
+  })
+}
+
+/**
+ * 获取当前用户的通行密钥列表
+ */
+export const getPasskeys = (): Promise<ApiResponse<Passkey[]>> => {
+  return request({
+    url: '/api/v1/passkeys',
+    method: 'get'
+  })
+}
+
+/**
+ * 发起通行密钥注册
+ */
+export const beginPasskeyRegistration = (): Promise<ApiResponse<PasskeyCreationOptions>> => {
+  return request({
+    url: '/api/v1/passkeys/options',
+    method: 'post'
+  })
+}
+
+/**
+ * 提交认证器的注册结果
+ */
+export const finishPasskeyRegistration = (name: string, credential: PasskeyCredentialJSON): Promise<ApiResponse<Passkey>> => {
+  return request({
+    url: '/api/v1/passkeys',
+    method: 'post',
+    data: { name, credential }
+  })
+}
+
+/**
+ * 修改通行密钥名称
+ */
+export const renamePasskey = (id: number, name: string): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/passkeys/${id}`,
+    method: 'patch',
+    data: { name }
+  })
+}
+
+/**
+ * 删除通行密钥
+ */
+export const deletePasskey = (id: number): Promise<ApiResponse> => {
+  return request({
+    url: `/api/v1/passkeys/${id}`,
+    method: 'delete'
+  })
+}
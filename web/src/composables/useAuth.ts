@@ -1,9 +1,15 @@
 import { ref } from 'vue'
 import { login as loginApi, logout as logoutApi } from '@/api/auth'
 import { register as registerApi } from '@/api/user'
+import { verifyLoginMFA, confirmLoginMFAEnrollment } from '@/api/mfa'
+import { beginPasskeyLogin, finishPasskeyLogin, beginMFAPasskey, verifyMFAPasskey } from '@/api/webauthn'
+import { federatedLogin } from '@/api/federation'
+import { getPasskey } from '@/utils/webauthn'
 import { useAuthStore } from '@/stores/useAuthStore'
 import { startTokenRefresh, stopTokenRefresh } from '@/composables/useTokenRefresh'
 import type { RegisterFormValues } from '@/types/user'
+import type { LoginResponse } from '@/types/auth'
+import type { MFAChallenge } from '@/types/mfa'
 
 /**
  * 认证操作的返回结果类型
@@ -12,6 +18,10 @@ export interface AuthActionResult {
   success: boolean
   message?: string
   errorMessage?: string
+  /** 需要两步验证时返回的挑战，此时尚未登录 */
+  mfa?: MFAChallenge
+  /** 登录时完成验证器绑定后返回的恢复码 */
+  recoveryCodes?: string[]
 }
 
 /**
@@ -35,19 +45,16 @@ export function useAuth() {
     try {
       const response = await loginApi(loginForm)
 
-      // 后端返回 ApiResponse<LoginResponse>，包含 user 和令牌过期时间
-      if (response.data?.user) {
-        authStore.loginSuccess(
-          response.data.user,
-          response.data.access_token_expire_at,
-          response.data.refresh_token_expire_at
-        )
-        // 登录成功后启动令牌自动刷新
-        startTokenRefresh()
-      } else {
-         throw new Error('登录返回数据异常')
+      // 需要两步验证：返回挑战，由页面进入第二步
+      if (response.data?.mfa) {
+        return {
+          success: false,
+          mfa: response.data.mfa
+        }
       }
 
+      completeLogin(response.data)
+
       return {
         success: true,
         message: '登录成功'
@@ -63,6 +70,175 @@ export function useAuth() {
     }
   }
 
+  /**
+   * 处理登录第二步：提交验证码或恢复码
+   * @param mfaToken 两步验证挑战令牌
+   * @param payload 验证码或恢复码（二选一）
+   */
+  const handleVerifyMFA = async (
+    mfaToken: string,
+    payload: { code?: string; recoveryCode?: string }
+  ): Promise<AuthActionResult> => {
+    loading.value = true
+    try {
+      const response = await verifyLoginMFA({
+        mfa_token: mfaToken,
+        code: payload.code || undefined,
+        recovery_code: payload.recoveryCode || undefined
+      })
+      completeLogin(response.data)
+
+      return {
+        success: true,
+        message: '登录成功'
+      }
+    } catch (error: any) {
+      console.error('两步验证失败:', error)
+      return {
+        success: false,
+        errorMessage: error.message || '两步验证失败'
+      }
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 处理通行密钥无密码登录
+   * @returns 登录结果，管理员未绑定验证器时返回须完成绑定的挑战
+   */
+  const handlePasskeyLogin = async (): Promise<AuthActionResult> => {
+    loading.value = true
+    try {
+      const options = await beginPasskeyLogin()
+      const credential = await getPasskey(options.data)
+      const response = await finishPasskeyLogin(credential)
+
+      if (response.data?.mfa) {
+        return {
+          success: false,
+          mfa: response.data.mfa
+        }
+      }
+
+      completeLogin(response.data)
+
+      return {
+        success: true,
+        message: '登录成功'
+      }
+    } catch (error: any) {
+      console.error('通行密钥登录失败:', error)
+      return {
+        success: false,
+        errorMessage: error.message || '通行密钥登录失败'
+      }
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 处理外部身份登录：使用回调地址中的一次性凭据换取登录结果
+   * @param ticket 身份提供方回调后生成的登录凭据
+   * @returns 登录结果，已启用两步验证时返回挑战
+   */
+  const handleFederatedLogin = async (ticket: string): Promise<AuthActionResult> => {
+    loading.value = true
+    try {
+      const response = await federatedLogin(ticket)
+
+      if (response.data?.mfa) {
+        return {
+          success: false,
+          mfa: response.data.mfa
+        }
+      }
+
+      completeLogin(response.data)
+
+      return {
+        success: true,
+        message: '登录成功'
+      }
+    } catch (error: any) {
+      console.error('外部账号登录失败:', error)
+      return {
+        success: false,
+        errorMessage: error.message || '外部账号登录失败'
+      }
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 处理登录第二步：使用通行密钥代替验证码
+   * @param mfaToken 两步验证挑战令牌
+   */
+  const handleVerifyMFAPasskey = async (mfaToken: string): Promise<AuthActionResult> => {
+    loading.value = true
+    try {
+      const options = await beginMFAPasskey(mfaToken)
+      const credential = await getPasskey(options.data)
+      const response = await verifyMFAPasskey(mfaToken, credential)
+      completeLogin(response.data)
+
+      return {
+        success: true,
+        message: '登录成功'
+      }
+    } catch (error: any) {
+      console.error('通行密钥验证失败:', error)
+      return {
+        success: false,
+        errorMessage: error.message || '通行密钥验证失败'
+      }
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 处理登录时的验证器绑定确认，成功后完成登录并返回恢复码
+   * @param mfaToken 两步验证挑战令牌
+   * @param code 验证器显示的验证码
+   */
+  const handleConfirmEnrollment = async (mfaToken: string, code: string): Promise<AuthActionResult> => {
+    loading.value = true
+    try {
+      const response = await confirmLoginMFAEnrollment({ mfa_token: mfaToken, code })
+      completeLogin(response.data)
+
+      return {
+        success: true,
+        message: '已启用两步验证',
+        recoveryCodes: response.data.recovery_codes
+      }
+    } catch (error: any) {
+      console.error('绑定验证器失败:', error)
+      return {
+        success: false,
+        errorMessage: error.message || '绑定验证器失败'
+      }
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 保存登录态并启动令牌自动刷新
+   */
+  const completeLogin = (data: LoginResponse) => {
+    // 后端返回 ApiResponse<LoginResponse>，包含 user 和令牌过期时间
+    if (!data?.user) {
+      throw new Error('登录返回数据异常')
+    }
+    authStore.loginSuccess(data.user, data.access_token_expire_at, data.refresh_token_expire_at, !!data.password_expired)
+    // 登录成功后启动令牌自动刷新
+    startTokenRefresh()
+  }
+
   /**
    * 处理注册
    * @param registerForm 注册表单数据（avatar 为 File | null）
@@ -73,6 +249,7 @@ export function useAuth() {
     password: string
     confirmPassword: string
     nickname: string
+    email: string
     avatar: File | null
   }): Promise<AuthActionResult> => {
     loading.value = true
@@ -82,6 +259,7 @@ export function useAuth() {
         password: registerForm.password,
         confirmPassword: registerForm.confirmPassword,
         nickname: registerForm.nickname,
+        email: registerForm.email,
         avatar: registerForm.avatar
       }
 
@@ -129,6 +307,11 @@ export function useAuth() {
   return {
     loading,
     handleLogin,
+    handleVerifyMFA,
+    handlePasskeyLogin,
+    handleFederatedLogin,
+    handleVerifyMFAPasskey,
+    handleConfirmEnrollment,
     handleRegister,
     handleLogout
   }
@@ -0,0 +1,218 @@
+import { ref } from 'vue'
+import { ElMessage, ElMessageBox } from 'element-plus'
+import { listGroups, getGroup, createGroup, updateGroup, deleteGroup, listRoles } from '@/api/rbac'
+import { listUsers } from '@/api/user'
+import type { GroupListResponse, Role } from '@/types/rbac'
+
+/**
+ * 可选的成员，编辑时由用户组详情或用户搜索结果填充
+ */
+interface MemberOption {
+  id: number
+  label: string
+}
+
+/**
+ * 用户组管理相关的组合式函数
+ */
+export function useGroupManagement() {
+  const loading = ref(false)
+  const submitting = ref(false)
+  const groupList = ref<GroupListResponse[]>([])
+  const roleOptions = ref<Role[]>([])
+  const memberOptions = ref<MemberOption[]>([])
+  const searchingMembers = ref(false)
+
+  const filters = ref({
+    displayName: undefined as string | undefined
+  })
+
+  const pagination = ref({
+    page: 1,
+    pageSize: 10,
+    total: 0
+  })
+
+  // 编辑弹窗，editingId 为空时表示新建
+  const dialogVisible = ref(false)
+  const editingId = ref<number | null>(null)
+  const form = ref({
+    displayName: '',
+    members: [] as number[],
+    roles: [] as string[]
+  })
+
+  /**
+   * 获取用户组列表
+   */
+  const fetchGroupList = async () => {
+    loading.value = true
+    try {
+      const params: any = {
+        page: pagination.value.page,
+        page_size: pagination.value.pageSize
+      }
+      if (filters.value.displayName !== undefined && filters.value.displayName !== '') {
+        params.display_name = filters.value.displayName
+      }
+
+      const response = await listGroups(params)
+      groupList.value = response.data.items
+      pagination.value.total = response.data.total
+    } catch (error: any) {
+      console.error('获取用户组列表失败:', error)
+      // 错误已在拦截器中处理
+    } finally {
+      loading.value = false
+    }
+  }
+
+  const fetchRoleOptions = async () => {
+    try {
+      const response = await listRoles()
+      roleOptions.value = response.data
+    } catch (error: any) {
+      console.error('获取角色列表失败:', error)
+    }
+  }
+
+  /**
+   * 按昵称搜索用户作为成员候选，已选成员保留在选项中
+   */
+  const searchMembers = async (keyword: string) => {
+    const selected = memberOptions.value.filter(option => form.value.members.includes(option.id))
+    if (!keyword) {
+      memberOptions.value = selected
+      return
+    }
+
+    searchingMembers.value = true
+    try {
+      const response = await listUsers({ page: 1, page_size: 20, nickname: keyword })
+      const found = response.data.items
+        .filter(user => !form.value.members.includes(user.id))
+        .map(user => ({ id: user.id, label: `${user.nickname}（ID ${user.id}）` }))
+      memberOptions.value = [...selected, ...found]
+    } catch (error: any) {
+      console.error('搜索用户失败:', error)
+    } finally {
+      searchingMembers.value = false
+    }
+  }
+
+  const handleFilterChange = () => {
+    pagination.value.page = 1
+    fetchGroupList()
+  }
+
+  const handlePageChange = (page: number) => {
+    pagination.value.page = page
+    fetchGroupList()
+  }
+
+  const handleSizeChange = (size: number) => {
+    pagination.value.pageSize = size
+    pagination.value.page = 1
+    fetchGroupList()
+  }
+
+  const openCreateDialog = () => {
+    editingId.value = null
+    form.value = { displayName: '', members: [], roles: [] }
+    memberOptions.value = []
+    dialogVisible.value = true
+  }
+
+  const openEditDialog = async (group: GroupListResponse) => {
+    try {
+      const response = await getGroup(group.id)
+      const detail = response.data
+      editingId.value = detail.id
+      form.value = {
+        displayName: detail.display_name,
+        members: detail.members.map(member => member.id),
+        roles: [...detail.roles]
+      }
+      memberOptions.value = detail.members.map(member => ({
+        id: member.id,
+        label: `${member.nickname}（${member.username}）`
+      }))
+      dialogVisible.value = true
+    } catch (error: any) {
+      console.error('获取用户组详情失败:', error)
+    }
+  }
+
+  /**
+   * 保存用户组，编辑时整体替换成员与角色
+   */
+  const submitForm = async () => {
+    const displayName = form.value.displayName.trim()
+    if (!displayName) {
+      ElMessage.warning('请输入用户组名称')
+      return
+    }
+
+    submitting.value = true
+    try {
+      const data = { display_name: displayName, members: form.value.members, roles: form.value.roles }
+      const response = editingId.value
+        ? await updateGroup(editingId.value, data)
+        : await createGroup(data)
+      ElMessage.success(response.message || '用户组已保存')
+      dialogVisible.value = false
+      await fetchGroupList()
+    } catch (error: any) {
+      console.error('保存用户组失败:', error)
+    } finally {
+      submitting.value = false
+    }
+  }
+
+  /**
+   * 删除用户组
+   */
+  const handleDelete = async (group: GroupListResponse) => {
+    try {
+      await ElMessageBox.confirm(`确定删除用户组 ${group.display_name} 吗？成员将失去经由该用户组获得的角色。`, '删除用户组', {
+        confirmButtonText: '删除',
+        cancelButtonText: '取消',
+        type: 'warning'
+      })
+    } catch {
+      return
+    }
+
+    try {
+      const response = await deleteGroup(group.id)
+      ElMessage.success(response.message || '用户组已删除')
+      await fetchGroupList()
+    } catch (error: any) {
+      console.error('删除用户组失败:', error)
+    }
+  }
+
+  return {
+    loading,
+    submitting,
+    groupList,
+    roleOptions,
+    memberOptions,
+    searchingMembers,
+    filters,
+    pagination,
+    dialogVisible,
+    editingId,
+    form,
+    fetchGroupList,
+    fetchRoleOptions,
+    searchMembers,
+    handleFilterChange,
+    handlePageChange,
+    handleSizeChange,
+    openCreateDialog,
+    openEditDialog,
+    submitForm,
+    handleDelete
+  }
+}
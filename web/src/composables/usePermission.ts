@@ -2,6 +2,7 @@ import { computed } from 'vue'
 import { useRoute } from 'vue-router'
 import { useAuthStore } from '@/stores/useAuthStore'
 import { emitAuthEvent } from '@/utils/authFeedbackBus'
+import { PERMISSIONS } from '@/constants'
 
 /**
  * 权限检查相关的组合式函数
@@ -10,8 +11,15 @@ export function usePermission() {
   const route = useRoute()
   const authStore = useAuthStore()
 
-  // 是否是管理员
-  const isAdmin = computed(() => authStore.user?.role === 'admin')
+  /**
+   * 当前用户是否拥有指定权限，权限随登录接口返回
+   */
+  const hasPermission = (permission: string): boolean => {
+    return authStore.user?.permissions?.includes(permission) ?? false
+  }
+
+  // 是否可以管理其他用户
+  const canManageUsers = computed(() => hasPermission(PERMISSIONS.USER_WRITE))
 
   // 是否已登录
   const isLoggedIn = computed(() => authStore.isAuthenticated)
@@ -46,8 +54,8 @@ export function usePermission() {
     const currentId = currentUserId || authStore.user?.id.toString() || ''
     const targetId = targetUserId.toString()
 
-    // 管理员可以编辑任何人
-    if (isAdmin.value) {
+    // 拥有用户管理权限可以编辑任何人
+    if (canManageUsers.value) {
       return true
     }
 
@@ -65,17 +73,17 @@ export function usePermission() {
   }
 
   /**
-   * 检查用户是否有管理员权限
+   * 检查用户是否有指定权限
    */
-  const checkAdminPermission = (): boolean => {
+  const checkPermission = (permission: string): boolean => {
     if (!checkLogin()) {
       return false
     }
 
-    if (!isAdmin.value) {
+    if (!hasPermission(permission)) {
       // 通过事件总线发出权限不足事件
       emitAuthEvent('auth:forbidden', {
-        message: '您没有管理员权限',
+        message: '您没有访问该页面的权限',
         redirectPath: '/home'
       })
       return false
@@ -85,11 +93,12 @@ export function usePermission() {
   }
 
   return {
-    isAdmin,
+    canManageUsers,
     isLoggedIn,
+    hasPermission,
     checkLogin,
     checkEditPermission,
-    checkAdminPermission
+    checkPermission
   }
 }
 
@@ -0,0 +1,132 @@
+import { ref } from 'vue'
+import { ElMessage, ElMessageBox } from 'element-plus'
+import { listPendingRegistrations, approveRegistration, rejectRegistration } from '@/api/registration'
+import type { PendingUser } from '@/types/user'
+
+/**
+ * 注册审核相关的组合式函数
+ */
+export function useRegistrationReview() {
+  const loading = ref(false)
+  const pendingList = ref<PendingUser[]>([])
+  // 正在处理的申请 ID，用于按钮加载状态
+  const reviewingId = ref<number | null>(null)
+
+  const pagination = ref({
+    page: 1,
+    pageSize: 10,
+    total: 0
+  })
+
+  /**
+   * 获取待审核列表
+   */
+  const fetchPendingList = async () => {
+    loading.value = true
+    try {
+      const response = await listPendingRegistrations({
+        page: pagination.value.page,
+        page_size: pagination.value.pageSize
+      })
+      pendingList.value = response.data.items
+      pagination.value.total = response.data.total
+    } catch (error: any) {
+      console.error('获取待审核列表失败:', error)
+      // 错误已在拦截器中处理
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 处理页码变化
+   */
+  const handlePageChange = (page: number) => {
+    pagination.value.page = page
+    fetchPendingList()
+  }
+
+  /**
+   * 处理页面大小变化
+   */
+  const handleSizeChange = (size: number) => {
+    pagination.value.pageSize = size
+    pagination.value.page = 1
+    fetchPendingList()
+  }
+
+  /**
+   * 批准注册申请
+   */
+  const handleApprove = async (user: PendingUser) => {
+    try {
+      await ElMessageBox.confirm(`确定批准 ${user.nickname}（${user.username}）的注册申请吗？`, '批准注册', {
+        confirmButtonText: '批准',
+        cancelButtonText: '取消',
+        type: 'info'
+      })
+    } catch {
+      return
+    }
+
+    reviewingId.value = user.id
+    try {
+      const response = await approveRegistration(user.id)
+      ElMessage.success(response.message || '已批准注册申请')
+      await fetchPendingList()
+    } catch (error: any) {
+      console.error('批准注册申请失败:', error)
+      // 错误已在拦截器中处理；申请已被其他管理员处理时刷新列表
+      await fetchPendingList()
+    } finally {
+      reviewingId.value = null
+    }
+  }
+
+  /**
+   * 拒绝注册申请，可填写原因
+   */
+  const handleReject = async (user: PendingUser) => {
+    let reason = ''
+    try {
+      const result = await ElMessageBox.prompt(
+        `拒绝 ${user.nickname}（${user.username}）的注册申请，原因会通过邮件告知申请人（可不填）`,
+        '拒绝注册',
+        {
+          confirmButtonText: '拒绝',
+          cancelButtonText: '取消',
+          confirmButtonClass: 'el-button--danger',
+          inputPlaceholder: '拒绝原因',
+          inputValidator: (value: string) => !value || value.length <= 200 || '原因不能超过 200 个字符'
+        }
+      )
+      reason = result.value?.trim() ?? ''
+    } catch {
+      return
+    }
+
+    reviewingId.value = user.id
+    try {
+      const response = await rejectRegistration(user.id, reason)
+      ElMessage.success(response.message || '已拒绝注册申请')
+      await fetchPendingList()
+    } catch (error: any) {
+      console.error('拒绝注册申请失败:', error)
+      await fetchPendingList()
+    } finally {
+      reviewingId.value = null
+    }
+  }
+
+  return {
+    loading,
+    pendingList,
+    reviewingId,
+    pagination,
+    fetchPendingList,
+    handlePageChange,
+    handleSizeChange,
+    handleApprove,
+    handleReject
+  }
+}
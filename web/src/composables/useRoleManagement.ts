@@ -0,0 +1,135 @@
+import { ref, computed } from 'vue'
+import { ElMessage, ElMessageBox } from 'element-plus'
+import { listRoles, listPermissions, createRole, updateRole, deleteRole } from '@/api/rbac'
+import type { Role, Permission, CreateRoleRequest } from '@/types/rbac'
+
+/**
+ * 角色管理相关的组合式函数
+ */
+export function useRoleManagement() {
+  const loading = ref(false)
+  const submitting = ref(false)
+  const roleList = ref<Role[]>([])
+  const permissionOptions = ref<Permission[]>([])
+
+  // 编辑弹窗，editingRole 为空时表示新建
+  const dialogVisible = ref(false)
+  const editingRole = ref<Role | null>(null)
+  const form = ref({
+    name: '',
+    description: '',
+    permissions: [] as string[]
+  })
+
+  // 管理员角色始终拥有全部权限，不能修改
+  const permissionsLocked = computed(() => editingRole.value?.builtin === true && editingRole.value.name === 'admin')
+
+  /**
+   * 获取角色列表
+   */
+  const fetchRoleList = async () => {
+    loading.value = true
+    try {
+      const response = await listRoles()
+      roleList.value = response.data
+    } catch (error: any) {
+      console.error('获取角色列表失败:', error)
+      // 错误已在拦截器中处理
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 获取可分配的权限
+   */
+  const fetchPermissionOptions = async () => {
+    try {
+      const response = await listPermissions()
+      permissionOptions.value = response.data
+    } catch (error: any) {
+      console.error('获取权限列表失败:', error)
+    }
+  }
+
+  const openCreateDialog = () => {
+    editingRole.value = null
+    form.value = { name: '', description: '', permissions: [] }
+    dialogVisible.value = true
+  }
+
+  const openEditDialog = (role: Role) => {
+    editingRole.value = role
+    form.value = { name: role.name, description: role.description, permissions: [...role.permissions] }
+    dialogVisible.value = true
+  }
+
+  /**
+   * 保存角色，编辑时提交全部字段，权限整体替换
+   */
+  const submitForm = async () => {
+    const name = form.value.name.trim()
+    if (name.length < 2 || name.length > 50) {
+      ElMessage.warning('角色名称长度应为 2 到 50 个字符')
+      return
+    }
+
+    submitting.value = true
+    try {
+      const data: CreateRoleRequest = { name, description: form.value.description }
+      if (!permissionsLocked.value) {
+        data.permissions = form.value.permissions
+      }
+      const response = editingRole.value
+        ? await updateRole(editingRole.value.id, data)
+        : await createRole(data)
+      ElMessage.success(response.message || '角色已保存')
+      dialogVisible.value = false
+      await fetchRoleList()
+    } catch (error: any) {
+      console.error('保存角色失败:', error)
+    } finally {
+      submitting.value = false
+    }
+  }
+
+  /**
+   * 删除角色
+   */
+  const handleDelete = async (role: Role) => {
+    try {
+      await ElMessageBox.confirm(`确定删除角色 ${role.name} 吗？已分配该角色的用户与用户组将失去对应权限。`, '删除角色', {
+        confirmButtonText: '删除',
+        cancelButtonText: '取消',
+        type: 'warning'
+      })
+    } catch {
+      return
+    }
+
+    try {
+      const response = await deleteRole(role.id)
+      ElMessage.success(response.message || '角色已删除')
+      await fetchRoleList()
+    } catch (error: any) {
+      console.error('删除角色失败:', error)
+    }
+  }
+
+  return {
+    loading,
+    submitting,
+    roleList,
+    permissionOptions,
+    dialogVisible,
+    editingRole,
+    form,
+    permissionsLocked,
+    fetchRoleList,
+    fetchPermissionOptions,
+    openCreateDialog,
+    openEditDialog,
+    submitForm,
+    handleDelete
+  }
+}
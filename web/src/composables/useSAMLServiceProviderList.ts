@@ -0,0 +1,90 @@
+import { ref } from 'vue'
+import { ElMessage } from 'element-plus'
+import { listSAMLServiceProviders } from '@/api/saml'
+import type { SAMLServiceProviderListResponse } from '@/types/saml'
+
+/**
+ * SAML 服务提供方列表管理相关的组合式函数
+ */
+export function useSAMLServiceProviderList() {
+  const loading = ref(false)
+  const serviceProviderList = ref<SAMLServiceProviderListResponse[]>([])
+
+  const filters = ref({
+    name: undefined as string | undefined,
+    status: undefined as number | string | undefined
+  })
+
+  const pagination = ref({
+    page: 1,
+    pageSize: 10,
+    total: 0
+  })
+
+  /**
+   * 获取 SAML 服务提供方列表
+   */
+  const fetchServiceProviderList = async () => {
+    loading.value = true
+    try {
+      const params: any = {
+        page: pagination.value.page,
+        page_size: pagination.value.pageSize
+      }
+
+      if (filters.value.name !== undefined && filters.value.name !== '') {
+        params.name = filters.value.name
+      }
+      if (filters.value.status !== undefined && filters.value.status !== '') {
+        params.status = filters.value.status
+      }
+
+      const response = await listSAMLServiceProviders(params)
+      serviceProviderList.value = response.data.items
+      pagination.value.total = response.data.total
+      pagination.value.page = response.data.page
+      pagination.value.pageSize = response.data.pageSize
+    } catch (error: any) {
+      ElMessage.error(error.message || '获取 SAML 服务提供方列表失败')
+    } finally {
+      loading.value = false
+    }
+  }
+
+  /**
+   * 处理筛选变化
+   */
+  const handleFilterChange = () => {
+    pagination.value.page = 1
+    fetchServiceProviderList()
+  }
+
+  /**
+   * 处理页码变化
+   */
+  const handlePageChange = (page: number) => {
+    pagination.value.page = page
+    fetchServiceProviderList()
+  }
+
+  /**
+   * 处理页面大小变化
+   */
+  const handleSizeChange = (size: number) => {
+    pagination.value.pageSize = size
+    pagination.value.page = 1
+    fetchServiceProviderList()
+  }
+
+  return {
+    loading,
+    serviceProviderList,
+    filters,
+    pagination,
+    fetchServiceProviderList,
+    handleFilterChange,
+    handlePageChange,
+    handleSizeChange
+  }
+}
+
@@ -55,8 +55,8 @@ const doRefresh = async (): Promise<boolean> => {
     const response = await refreshTokenApi()
     
     if (response.data?.access_token_expire_at) {
-      // 更新 store 中的过期时间
-      authStore.updateAccessTokenExpireAt(response.data.access_token_expire_at)
+      // 更新 store 中的过期时间（刷新令牌每次使用后轮换）
+      authStore.updateAccessTokenExpireAt(response.data.access_token_expire_at, response.data.refresh_token_expire_at)
       return true
     }
     return false
@@ -2,7 +2,7 @@ import { ref, computed } from 'vue'
 import { useRouter, useRoute } from 'vue-router'
 import { ElMessage } from 'element-plus'
 import type { FormInstance } from 'element-plus'
-import { getUserInfo, updateUser } from '@/api/user'
+import { getUserInfo, updateUser, sendEmailVerification } from '@/api/user'
 import { useAuthStore } from '@/stores/useAuthStore'
 import type { User as UserType, UpdateUserFormValues } from '@/types/user'
 import { usePermission } from './usePermission'
@@ -15,18 +15,19 @@ export function useUserProfile() {
   const router = useRouter()
   const route = useRoute()
   const authStore = useAuthStore()
-  const { isAdmin, checkEditPermission } = usePermission()
+  const { canManageUsers, checkEditPermission } = usePermission()
 
   const pageLoading = ref(true)
   const submitLoading = ref(false)
+  const sendingVerification = ref(false)
   const targetUser = ref<UserType>({} as UserType)
 
   // 用户信息数据（不再包含 avatar URL，avatar 只用于显示当前头像）
   const userInfo = ref({
     nickname: '',
+    email: '',
     avatar: '',
-    status: 1,
-    role: 'user'
+    status: 1
   })
 
   // 新头像文件（可选）
@@ -39,10 +40,10 @@ export function useUserProfile() {
   })
 
   // 原始数据（用于对比变化，不包含 avatar）
-  let originalData: { nickname: string; status: number; role: string } = {
+  let originalData: { nickname: string; email: string; status: number } = {
     nickname: '',
-    status: 1,
-    role: 'user'
+    email: '',
+    status: 1
   }
 
   // 目标用户ID
@@ -81,16 +82,16 @@ export function useUserProfile() {
         // 填充用户信息表单
         userInfo.value = {
           nickname: response.data.nickname,
+          email: response.data.email || '',
           avatar: response.data.avatar || '',
-          status: response.data.status,
-          role: response.data.role
+          status: response.data.status
         }
 
         // 保存原始数据（不包含 avatar，因为 avatar 通过文件上传）
         originalData = {
           nickname: response.data.nickname,
-          status: response.data.status,
-          role: response.data.role
+          email: response.data.email || '',
+          status: response.data.status
         }
 
         // 清空头像文件选择
@@ -121,8 +122,8 @@ export function useUserProfile() {
           // 检测基本信息变化（不包含 avatar）
           const changes = detectChanges(originalData, userInfo.value, [
             'nickname',
-            'status',
-            'role'
+            'email',
+            'status'
           ])
 
           // 昵称变更
@@ -130,20 +131,20 @@ export function useUserProfile() {
             updateData.nickname = changes.nickname as string
           }
 
+          // 邮箱变更（后端不支持清空邮箱，留空视为不修改）
+          if ('email' in changes && changes.email) {
+            updateData.email = changes.email as string
+          }
+
           // 密码修改
           if (passwordData.value.password) {
             updateData.password = passwordData.value.password
             updateData.confirm_password = passwordData.value.confirmPassword
           }
 
-          // 管理员才能修改状态和角色
-          if (isAdmin.value) {
-            if ('status' in changes) {
-              updateData.status = changes.status as number
-            }
-            if ('role' in changes) {
-              updateData.role = changes.role as string
-            }
+          // 拥有用户管理权限才能修改状态
+          if (canManageUsers.value && 'status' in changes) {
+            updateData.status = changes.status as number
           }
 
           // 新头像文件
@@ -165,7 +166,12 @@ export function useUserProfile() {
           if (isEditingSelf.value) {
             const updatedUserResponse = await getUserInfo(targetUserId.value)
             if (updatedUserResponse.data) {
-              authStore.user = updatedUserResponse.data
+              // 用户详情不含角色与权限，沿用登录时获取的
+              authStore.user = {
+                ...updatedUserResponse.data,
+                roles: authStore.user?.roles,
+                permissions: authStore.user?.permissions
+              }
             }
           }
 
@@ -187,6 +193,22 @@ export function useUserProfile() {
     })
   }
 
+  /**
+   * 重新发送邮箱验证邮件
+   */
+  const resendEmailVerification = async () => {
+    sendingVerification.value = true
+    try {
+      const response = await sendEmailVerification(targetUserId.value)
+      ElMessage.success(response.message || '验证邮件已发送，请查收')
+    } catch (error: unknown) {
+      console.error('发送验证邮件失败:', error)
+      // 错误已在拦截器中处理
+    } finally {
+      sendingVerification.value = false
+    }
+  }
+
   /**
    * 取消/返回
    */
@@ -197,6 +219,7 @@ export function useUserProfile() {
   return {
     pageLoading,
     submitLoading,
+    sendingVerification,
     targetUser,
     userInfo,
     avatarFile,
@@ -204,9 +227,10 @@ export function useUserProfile() {
     formData,
     targetUserId,
     isEditingSelf,
-    isAdmin,
+    canManageUsers,
     loadUserInfo,
     submitForm,
+    resendEmailVerification,
     cancel
   }
 }
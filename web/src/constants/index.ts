@@ -1,13 +1,41 @@
-export const USER_ROLES = [
-  { label: '普通用户', value: 'user' },
-  { label: '管理员', value: 'admin' }
-]
+/**
+ * 权限名称，与后端定义一致，用于决定是否显示管理入口
+ */
+export const PERMISSIONS = {
+  USER_READ: 'user:read',
+  USER_WRITE: 'user:write',
+  REGISTRATION_REVIEW: 'registration:review',
+  OAUTH_CLIENT_READ: 'oauth_client:read',
+  OAUTH_CLIENT_WRITE: 'oauth_client:write',
+  SAML_SP_READ: 'saml_sp:read',
+  SAML_SP_WRITE: 'saml_sp:write',
+  RBAC_READ: 'rbac:read',
+  RBAC_WRITE: 'rbac:write',
+  POLICY_READ: 'policy:read',
+  TENANT_READ: 'tenant:read',
+  TENANT_WRITE: 'tenant:write',
+  AUDIT_READ: 'audit:read',
+  WEBHOOK_READ: 'webhook:read',
+  WEBHOOK_WRITE: 'webhook:write'
+} as const
 
 export const USER_STATUS = [
   { label: '启用', value: 1 },
   { label: '禁用', value: 0 }
 ]
 
+/**
+ * 用户状态的展示与筛选选项，包含注册流程中的状态
+ * 管理员可直接设置的只有 USER_STATUS 中的启用与禁用，待审核账号通过注册审核处理
+ */
+export const USER_STATUS_OPTIONS = [
+  { label: '正常', value: 1, tagType: 'success' },
+  { label: '禁用', value: 0, tagType: 'danger' },
+  { label: '待激活', value: 2, tagType: 'warning' },
+  { label: '待审核', value: 3, tagType: 'warning' },
+  { label: '已拒绝', value: 4, tagType: 'info' }
+] as const
+
 export const OAUTH_GRANT_TYPES = [
   { label: '授权码模式', value: 'authorization_code' },
   { label: '客户端凭证模式', value: 'client_credentials' },
@@ -16,7 +44,10 @@ export const OAUTH_GRANT_TYPES = [
 ]
 
 export const OAUTH_SCOPES = [
-  { label: '基本信息', value: 'profile' }
+  { label: '基本信息', value: 'profile' },
+  { label: 'SCIM 预配', value: 'scim' },
+  { label: '角色', value: 'roles' },
+  { label: '用户组', value: 'groups' }
 ]
 
 export const OAUTH_CLIENT_STATUS = [
@@ -24,4 +55,18 @@ export const OAUTH_CLIENT_STATUS = [
   { label: '禁用', value: 0 }
 ]
 
-export const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:9000'
\ No newline at end of file
+export const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:9000'
+// SAML NameID 格式
+export const SAML_NAME_ID_FORMATS = [
+  { label: '持久标识（persistent）', value: 'urn:oasis:names:tc:SAML:2.0:nameid-format:persistent' },
+  { label: '未指定（unspecified）', value: 'urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified' }
+]
+
+// SAML 下发属性的取值来源
+export const SAML_ATTRIBUTE_SOURCES = [
+  { label: '用户标识', value: 'subject' },
+  { label: '用户名', value: 'username' },
+  { label: '昵称', value: 'nickname' },
+  { label: '邮箱（已验证）', value: 'email' },
+  { label: '角色', value: 'role' }
+]
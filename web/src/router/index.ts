@@ -5,12 +5,16 @@ import { emitAuthEvent } from '@/utils/authFeedbackBus'
 import authRoutes from './modules/auth'
 import userRoutes from './modules/user'
 import oauthRoutes from './modules/oauth'
+import samlRoutes from './modules/saml'
+import rbacRoutes from './modules/rbac'
 import systemRoutes from './modules/system'
 
 const routes: RouteRecordRaw[] = [
   ...authRoutes,
   ...userRoutes,
   ...oauthRoutes,
+  ...samlRoutes,
+  ...rbacRoutes,
   ...systemRoutes
 ]
 
@@ -22,6 +22,42 @@ const authRoutes: RouteRecordRaw[] = [
       title: '用户登录',
       requiresAuth: false
     }
+  },
+  {
+    path: '/forgot-password',
+    name: 'ForgotPassword',
+    component: () => import('@/views/ForgotPassword.vue'),
+    meta: {
+      title: '找回密码',
+      requiresAuth: false
+    }
+  },
+  {
+    path: '/reset-password',
+    name: 'ResetPassword',
+    component: () => import('@/views/ResetPassword.vue'),
+    meta: {
+      title: '重置密码',
+      requiresAuth: false
+    }
+  },
+  {
+    path: '/verify-email',
+    name: 'VerifyEmail',
+    component: () => import('@/views/VerifyEmail.vue'),
+    meta: {
+      title: '邮箱验证',
+      requiresAuth: false
+    }
+  },
+  {
+    path: '/resend-activation',
+    name: 'ResendActivation',
+    component: () => import('@/views/ResendActivation.vue'),
+    meta: {
+      title: '重新发送激活邮件',
+      requiresAuth: false
+    }
   }
 ]
 
@@ -0,0 +1,24 @@
+import type { RouteRecordRaw } from 'vue-router'
+
+const rbacRoutes: RouteRecordRaw[] = [
+  {
+    path: '/roles',
+    name: 'Roles',
+    component: () => import('@/views/rbac/Roles.vue'),
+    meta: {
+      title: '角色管理',
+      requiresAuth: true
+    }
+  },
+  {
+    path: '/groups',
+    name: 'Groups',
+    component: () => import('@/views/rbac/Groups.vue'),
+    meta: {
+      title: '用户组',
+      requiresAuth: true
+    }
+  }
+]
+
+export default rbacRoutes
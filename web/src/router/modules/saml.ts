@@ -0,0 +1,24 @@
+import type { RouteRecordRaw } from 'vue-router'
+
+const samlRoutes: RouteRecordRaw[] = [
+  {
+    path: '/saml/service-providers',
+    name: 'SAMLServiceProviders',
+    component: () => import('@/views/saml/ServiceProviders.vue'),
+    meta: {
+      title: 'SAML 服务提供方管理',
+      requiresAuth: true
+    }
+  },
+  {
+    path: '/saml/sso',
+    name: 'SAMLSSO',
+    component: () => import('@/views/saml/SSO.vue'),
+    meta: {
+      title: '单点登录',
+      requiresAuth: false  // 页面内部处理登录检查
+    }
+  }
+]
+
+export default samlRoutes
@@ -27,6 +27,15 @@ const userRoutes: RouteRecordRaw[] = [
       title: '用户列表',
       requiresAuth: true
     }
+  },
+  {
+    path: '/registrations',
+    name: 'Registrations',
+    component: () => import('@/views/Registrations.vue'),
+    meta: {
+      title: '注册审核',
+      requiresAuth: true
+    }
   }
 ]
 
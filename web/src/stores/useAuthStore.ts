@@ -52,6 +52,8 @@ export const useAuthStore = defineStore('auth', () => {
   const accessTokenExpireAt = ref<number | null>(null)
   /** 刷新令牌过期时间戳（毫秒） */
   const refreshTokenExpireAt = ref<number | null>(null)
+  /** 本次登录时密码是否已超过最长使用期限（不持久化，只用于登录后的跳转提示） */
+  const passwordExpired = ref(false)
 
   // ========== 计算属性 ==========
   
@@ -69,14 +71,17 @@ export const useAuthStore = defineStore('auth', () => {
    * @param userData 用户信息
    * @param accessExpireAt 访问令牌过期时间（ISO 字符串）
    * @param refreshExpireAt 刷新令牌过期时间（ISO 字符串）
+   * @param isPasswordExpired 密码是否已超过最长使用期限
    */
   const loginSuccess = (
     userData: User,
     accessExpireAt: string,
-    refreshExpireAt: string
+    refreshExpireAt: string,
+    isPasswordExpired: boolean = false
   ) => {
     // 更新状态
     user.value = userData
+    passwordExpired.value = isPasswordExpired
     accessTokenExpireAt.value = new Date(accessExpireAt).getTime()
     refreshTokenExpireAt.value = new Date(refreshExpireAt).getTime()
     
@@ -89,11 +94,15 @@ export const useAuthStore = defineStore('auth', () => {
   }
 
   /**
-   * 刷新令牌成功后，更新访问令牌过期时间
+   * 刷新令牌成功后，更新访问令牌过期时间；刷新令牌轮换后同时更新其过期时间
    * @param accessExpireAt 新的访问令牌过期时间（ISO 字符串）
+   * @param refreshExpireAt 新的刷新令牌过期时间（ISO 字符串，可选）
    */
-  const updateAccessTokenExpireAt = (accessExpireAt: string) => {
+  const updateAccessTokenExpireAt = (accessExpireAt: string, refreshExpireAt?: string) => {
     accessTokenExpireAt.value = new Date(accessExpireAt).getTime()
+    if (refreshExpireAt) {
+      refreshTokenExpireAt.value = new Date(refreshExpireAt).getTime()
+    }
     
     // 更新 sessionStorage
     saveToSession({
@@ -141,6 +150,7 @@ export const useAuthStore = defineStore('auth', () => {
     user.value = null
     accessTokenExpireAt.value = null
     refreshTokenExpireAt.value = null
+    passwordExpired.value = false
     clearSession()
   }
 
@@ -149,6 +159,7 @@ export const useAuthStore = defineStore('auth', () => {
     user,
     accessTokenExpireAt,
     refreshTokenExpireAt,
+    passwordExpired,
     
     // 计算属性
     isAuthenticated,
@@ -0,0 +1,29 @@
+/**
+ * 申请重置密码请求
+ */
+export interface ForgotPasswordRequest {
+  email: string
+}
+
+/**
+ * 通过邮件链接重置密码请求
+ */
+export interface ResetPasswordRequest {
+  token: string
+  password: string
+  confirm_password: string
+}
+
+/**
+ * 邮箱验证请求
+ */
+export interface VerifyEmailRequest {
+  token: string
+}
+
+/**
+ * 重新发送激活邮件请求
+ */
+export interface ResendActivationRequest {
+  email: string
+}
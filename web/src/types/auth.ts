@@ -1,4 +1,5 @@
 import type { User } from './user'
+import type { MFAChallenge } from './mfa'
 
 export interface LoginRequest {
   username: string
@@ -7,13 +8,20 @@ export interface LoginRequest {
 
 /**
  * 登录响应（后端返回的用户信息 + 令牌过期时间）
+ * 需要两步验证时只返回 mfa，不包含用户信息
  */
 export interface LoginResponse {
-  user: User
+  user?: User
   /** 访问令牌过期时间（ISO 8601 格式字符串） */
   access_token_expire_at: string
   /** 刷新令牌过期时间（ISO 8601 格式字符串） */
   refresh_token_expire_at: string
+  /** 两步验证挑战 */
+  mfa?: MFAChallenge
+  /** 登录时完成验证器绑定后返回的恢复码 */
+  recovery_codes?: string[]
+  /** 密码已超过最长使用期限，应提示用户修改 */
+  password_expired?: boolean
 }
 
 /**
@@ -22,5 +30,7 @@ export interface LoginResponse {
 export interface RefreshTokenResponse {
   /** 新的访问令牌过期时间（ISO 8601 格式字符串） */
   access_token_expire_at: string
+  /** 轮换后的刷新令牌过期时间（ISO 8601 格式字符串） */
+  refresh_token_expire_at: string
 }
 
@@ -0,0 +1,28 @@
+/**
+ * 可用的外部身份提供方
+ */
+export interface FederatedProvider {
+  name: string
+  display_name: string
+  type: 'oidc' | 'github'
+}
+
+/**
+ * 跳转到身份提供方的授权地址
+ */
+export interface FederatedAuthorizeResponse {
+  authorization_url: string
+}
+
+/**
+ * 已关联的外部账号
+ */
+export interface FederatedIdentity {
+  id: number
+  provider: string
+  provider_display_name: string
+  email: string
+  username: string
+  created_at: string
+  last_login_at: string | null
+}
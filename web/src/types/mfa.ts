@@ -0,0 +1,38 @@
+/**
+ * 密码验证通过后返回的两步验证挑战
+ */
+export interface MFAChallenge {
+  mfa_token: string
+  /** 可用的验证方式：totp、recovery_code、webauthn */
+  methods: string[]
+  /** 账号须先绑定验证器才能完成登录（管理员强制两步验证） */
+  enrollment_required: boolean
+  expires_at: string
+}
+
+/**
+ * 发起验证器绑定的响应
+ */
+export interface TOTPSetup {
+  /** Base32 密钥，供无法扫码时手动输入 */
+  secret: string
+  /** otpauth URI，可生成二维码供验证器 App 扫描 */
+  otpauth_uri: string
+}
+
+/**
+ * 两步验证状态
+ */
+export interface MFAStatus {
+  enabled: boolean
+  /** 是否为安全策略强制启用（管理员） */
+  required: boolean
+  recovery_codes_remaining: number
+}
+
+/**
+ * 恢复码（仅在生成时返回一次）
+ */
+export interface RecoveryCodesResponse {
+  recovery_codes: string[]
+}
@@ -0,0 +1,72 @@
+export interface Permission {
+  name: string
+  description: string
+}
+
+export interface Role {
+  id: number
+  name: string
+  description: string
+  /** 内置角色不能改名或删除 */
+  builtin: boolean
+  permissions: string[]
+  created_at: string
+  updated_at: string
+}
+
+export interface CreateRoleRequest {
+  name: string
+  description?: string
+  permissions?: string[]
+}
+
+export interface UpdateRoleRequest {
+  name?: string
+  description?: string
+  permissions?: string[]
+}
+
+/**
+ * 用户的角色，roles 为直接分配的角色，effective_roles 另含经由用户组获得的角色
+ */
+export interface UserRoles {
+  roles: string[]
+  effective_roles: string[]
+  permissions: string[]
+}
+
+export interface GroupMember {
+  id: number
+  username: string
+  nickname: string
+}
+
+export interface GroupListResponse {
+  id: number
+  display_name: string
+  member_count: number
+  roles: string[]
+  created_at: string
+}
+
+export interface GroupDetailResponse {
+  id: number
+  display_name: string
+  members: GroupMember[]
+  roles: string[]
+  created_at: string
+  updated_at: string
+}
+
+export interface CreateGroupRequest {
+  display_name: string
+  /** 成员用户ID */
+  members?: number[]
+  roles?: string[]
+}
+
+export interface UpdateGroupRequest {
+  display_name?: string
+  members?: number[]
+  roles?: string[]
+}
@@ -0,0 +1,57 @@
+export type SAMLAttributeSource = 'subject' | 'username' | 'nickname' | 'email' | 'role'
+
+export interface SAMLAttribute {
+  name: string
+  friendly_name?: string
+  source: SAMLAttributeSource
+}
+
+export interface SAMLServiceProviderListResponse {
+  id: number
+  entity_id: string
+  name: string
+  logo: string
+  allow_idp_initiated: boolean
+  status: number
+}
+
+export interface CreateSAMLServiceProviderRequest {
+  // 必填基本字段，实体ID从元数据中读取
+  name: string
+  metadata: string
+  status: number
+
+  // 可选基本字段
+  description?: string
+  logo?: string
+
+  // 可选断言配置
+  name_id_format?: string
+  attributes?: SAMLAttribute[]
+  allow_idp_initiated?: boolean
+  default_relay_state?: string
+}
+
+export type UpdateSAMLServiceProviderRequest = Partial<CreateSAMLServiceProviderRequest>
+
+export interface SAMLServiceProviderDetailResponse {
+  id: number
+  entity_id: string
+  name: string
+  description: string
+  logo: string
+  metadata: string
+  name_id_format: string
+  attributes: SAMLAttribute[]
+  allow_idp_initiated: boolean
+  default_relay_state: string
+  status: number
+
+  // 从本系统直接登录到该应用的地址，未允许 IdP 发起登录时为空
+  idp_initiated_url: string
+
+  created_at: string
+  updated_at: string
+}
+
+export type SAMLServiceProviderFormMode = 'create' | 'edit'
@@ -0,0 +1,16 @@
+/**
+ * 登录会话
+ */
+export interface Session {
+  session_id: string
+  /** 设备描述，格式为 "操作系统 / 浏览器" */
+  device: string
+  ip: string
+  user_agent: string
+  /** 最后活跃时间（ISO 8601 格式字符串） */
+  last_seen_at: string
+  created_at: string
+  expires_at: string
+  /** 是否为当前浏览器的会话 */
+  current: boolean
+}
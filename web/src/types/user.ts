@@ -4,7 +4,20 @@ export interface User {
   nickname: string
   avatar: string
   status: number
-  role: string
+  /** 实际拥有的角色，包括经由用户组获得的（登录接口返回） */
+  roles?: string[]
+  /** 角色对应的全部权限，据此显示管理入口（登录接口返回） */
+  permissions?: string[]
+  /** 邮箱，未设置时为 null */
+  email?: string | null
+  /** 邮箱验证时间，未验证时为 null（用户详情接口返回） */
+  email_verified_at?: string | null
+  /** 邮箱是否已验证（登录接口返回） */
+  email_verified?: boolean
+  /** 是否已启用两步验证 */
+  mfa_enabled?: boolean
+  /** 认证来源：local 本地密码，ldap 企业目录（用户详情接口返回） */
+  auth_source?: 'local' | 'ldap'
   created_at: string
   updated_at: string
 }
@@ -17,6 +30,8 @@ export interface RegisterFormValues {
   password: string
   confirmPassword: string
   nickname: string
+  /** 邮箱（可选），填写后发送验证邮件 */
+  email?: string
   avatar?: File | null
 }
 
@@ -26,11 +41,12 @@ export interface RegisterFormValues {
  */
 export interface UpdateUserFormValues {
   nickname?: string
+  /** 修改后须重新验证 */
+  email?: string
   password?: string
   confirm_password?: string
   /** 状态：0=禁用，1=启用 */
   status?: number
-  role?: string
   /** 新头像文件（可选） */
   avatar?: File | null
 }
@@ -40,6 +56,30 @@ export interface UserListResponse {
   nickname: string
   avatar: string
   status: number
-  role: string
+  /** 实际拥有的角色，包括经由用户组获得的 */
+  roles: string[]
 }
 
+/**
+ * 待审核的注册申请
+ */
+export interface PendingUser {
+  id: number
+  username: string
+  nickname: string
+  avatar: string
+  email: string
+  email_verified: boolean
+  created_at: string
+}
+
+
+/**
+ * 登录锁定状态
+ */
+export interface LoginLockout {
+  locked: boolean
+  locked_until: string | null
+  /** 滑动窗口内的登录失败次数 */
+  failures: number
+}
@@ -0,0 +1,63 @@
+/**
+ * 凭证描述
+ */
+export interface PasskeyCredentialDescriptor {
+  type: 'public-key'
+  id: string
+  transports?: AuthenticatorTransport[]
+}
+
+/**
+ * 注册通行密钥的参数（WebAuthn JSON 格式）
+ */
+export interface PasskeyCreationOptions {
+  challenge: string
+  rp: { id: string; name: string }
+  user: { id: string; name: string; displayName: string }
+  pubKeyCredParams: { type: 'public-key'; alg: number }[]
+  timeout: number
+  excludeCredentials: PasskeyCredentialDescriptor[]
+  authenticatorSelection: {
+    residentKey: ResidentKeyRequirement
+    userVerification: UserVerificationRequirement
+  }
+  attestation: AttestationConveyancePreference
+}
+
+/**
+ * 使用通行密钥认证的参数（WebAuthn JSON 格式）
+ */
+export interface PasskeyRequestOptions {
+  challenge: string
+  timeout: number
+  rpId: string
+  allowCredentials: PasskeyCredentialDescriptor[]
+  userVerification: UserVerificationRequirement
+}
+
+/**
+ * 提交给后端的凭证（二进制字段为 base64url）
+ */
+export interface PasskeyCredentialJSON {
+  id: string
+  rawId: string
+  type: string
+  response: {
+    clientDataJSON: string
+    attestationObject?: string
+    authenticatorData?: string
+    signature?: string
+    userHandle?: string
+    transports?: string[]
+  }
+}
+
+/**
+ * 已注册的通行密钥
+ */
+export interface Passkey {
+  id: number
+  name: string
+  created_at: string
+  last_used_at: string | null
+}
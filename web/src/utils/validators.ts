@@ -17,21 +17,71 @@ export const nicknameRules: FormItemRule[] = [
   { min: 1, max: 100, message: '昵称长度在 1 到 100 个字符', trigger: 'blur' }
 ]
 
+/**
+ * 邮箱验证规则（可选）
+ */
+export const emailRules: FormItemRule[] = [
+  { type: 'email', message: '请输入有效的邮箱地址', trigger: 'blur' },
+  { max: 255, message: '邮箱长度不能超过 255 个字符', trigger: 'blur' }
+]
+
 /**
  * 密码验证规则（用于登录，必填）
+ * 登录不校验密码策略，策略收紧前设置的密码仍可登录
  */
 export const passwordRules: FormItemRule[] = [
   { required: true, message: '请输入密码', trigger: 'blur' },
-  { min: 6, max: 50, message: '密码长度在 6 到 50 个字符', trigger: 'blur' }
+  { max: 128, message: '密码长度不能超过 128 个字符', trigger: 'blur' }
 ]
 
+// 与服务端默认密码策略保持一致，是否泄露、是否与历史密码重复只能由服务端判断
+const PASSWORD_MIN_LENGTH = 8
+const PASSWORD_MAX_LENGTH = 64
+const PASSWORD_MIN_CHAR_CLASSES = 2
+
 /**
- * 密码验证规则（可选，用于修改密码场景）
+ * 统计密码包含的字符类别数（大写字母、小写字母、数字、符号）
+ */
+const countCharClasses = (value: string): number => {
+  return [/\p{Lu}/u, /\p{Ll}/u, /\p{Nd}/u, /[^\p{Lu}\p{Ll}\p{Nd}]/u].filter((re) => re.test(value)).length
+}
+
+/**
+ * 密码策略校验规则，密码为空时跳过，由 required 规则决定是否必填
  */
-export const createPasswordValidator = (): FormItemRule[] => [
-  { min: 6, max: 50, message: '密码长度在 6 到 50 个字符', trigger: 'blur' }
+const passwordPolicyRule: FormItemRule = {
+  validator: (_rule: any, value: any, callback: any) => {
+    if (!value) {
+      callback()
+      return
+    }
+    const length = Array.from(value as string).length
+    if (length < PASSWORD_MIN_LENGTH || length > PASSWORD_MAX_LENGTH) {
+      callback(new Error(`密码长度在 ${PASSWORD_MIN_LENGTH} 到 ${PASSWORD_MAX_LENGTH} 个字符`))
+      return
+    }
+    if (countCharClasses(value) < PASSWORD_MIN_CHAR_CLASSES) {
+      callback(new Error(`密码至少包含大写字母、小写字母、数字、符号中的 ${PASSWORD_MIN_CHAR_CLASSES} 类`))
+      return
+    }
+    callback()
+  },
+  trigger: 'blur'
+}
+
+/**
+ * 新密码验证规则（用于注册，必填）
+ */
+export const newPasswordRules: FormItemRule[] = [
+  { required: true, message: '请输入密码', trigger: 'blur' },
+  passwordPolicyRule
 ]
 
+/**
+ * 密码验证规则（可选，用于修改密码场景）
+ */
+export const createPasswordValidator = (): FormItemRule[] => [passwordPolicyRule]
+
 /**
  * 头像URL验证规则（用于 URL 输入场景，如个人资料编辑）
  */
@@ -0,0 +1,97 @@
+/**
+ * WebAuthn 工具函数
+ * 后端按 WebAuthn JSON 格式收发参数（二进制字段为无填充 base64url），
+ * 这里负责与浏览器 navigator.credentials 所需的 ArrayBuffer 互相转换
+ */
+import type { PasskeyCreationOptions, PasskeyRequestOptions, PasskeyCredentialJSON } from '@/types/webauthn'
+
+/**
+ * 当前浏览器是否支持通行密钥
+ */
+export const isWebAuthnSupported = (): boolean => {
+  return typeof window !== 'undefined' && !!window.PublicKeyCredential && !!navigator.credentials
+}
+
+const base64urlToBuffer = (value: string): ArrayBuffer => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4)
+  const binary = atob(padded)
+  const bytes = new Uint8Array(binary.length)
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i)
+  }
+  return bytes.buffer
+}
+
+const bufferToBase64url = (buffer: ArrayBuffer): string => {
+  const bytes = new Uint8Array(buffer)
+  let binary = ''
+  for (const byte of bytes) {
+    binary += String.fromCharCode(byte)
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+/**
+ * 创建通行密钥，返回可直接提交给后端的凭证
+ */
+export const createPasskey = async (options: PasskeyCreationOptions): Promise<PasskeyCredentialJSON> => {
+  const credential = (await navigator.credentials.create({
+    publicKey: {
+      ...options,
+      challenge: base64urlToBuffer(options.challenge),
+      user: { ...options.user, id: base64urlToBuffer(options.user.id) },
+      excludeCredentials: options.excludeCredentials.map((item) => ({
+        ...item,
+        id: base64urlToBuffer(item.id)
+      }))
+    }
+  })) as PublicKeyCredential | null
+  if (!credential) {
+    throw new Error('未创建通行密钥')
+  }
+
+  const response = credential.response as AuthenticatorAttestationResponse
+  return {
+    id: credential.id,
+    rawId: bufferToBase64url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: bufferToBase64url(response.clientDataJSON),
+      attestationObject: bufferToBase64url(response.attestationObject),
+      transports: response.getTransports?.() || []
+    }
+  }
+}
+
+/**
+ * 使用通行密钥认证，返回可直接提交给后端的断言
+ */
+export const getPasskey = async (options: PasskeyRequestOptions): Promise<PasskeyCredentialJSON> => {
+  const credential = (await navigator.credentials.get({
+    publicKey: {
+      ...options,
+      challenge: base64urlToBuffer(options.challenge),
+      allowCredentials: options.allowCredentials.map((item) => ({
+        ...item,
+        id: base64urlToBuffer(item.id)
+      }))
+    }
+  })) as PublicKeyCredential | null
+  if (!credential) {
+    throw new Error('未选择通行密钥')
+  }
+
+  const response = credential.response as AuthenticatorAssertionResponse
+  return {
+    id: credential.id,
+    rawId: bufferToBase64url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: bufferToBase64url(response.clientDataJSON),
+      authenticatorData: bufferToBase64url(response.authenticatorData),
+      signature: bufferToBase64url(response.signature),
+      userHandle: response.userHandle ? bufferToBase64url(response.userHandle) : undefined
+    }
+  }
+}